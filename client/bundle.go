@@ -0,0 +1,76 @@
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	store "github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// ExportBundle updates the repository from the remote server, then collects
+// the current timestamp, snapshot, targets and delegations metadata along with
+// every version of root the server has, so that clients without access to the
+// server can bootstrap trust or rotate to the current root.
+func (r *repository) ExportBundle() (*store.Bundle, error) {
+	if err := r.updateTUF(false); err != nil {
+		return nil, err
+	}
+	bundle := store.NewBundle(r.gun)
+
+	roles := []data.RoleName{data.CanonicalRootRole, data.CanonicalTimestampRole, data.CanonicalSnapshotRole}
+	for role := range r.tufRepo.Targets {
+		roles = append(roles, role)
+	}
+	// everything the update verified has been written to the cache, exactly
+	// as it was signed
+	for _, role := range roles {
+		raw, err := r.cache.GetSized(role.String(), store.NoSizeLimit)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Metadata[role.String()] = raw
+	}
+
+	currentVersion := r.tufRepo.Root.Signed.Version
+	bundle.Metadata[fmt.Sprintf("%d.%s", currentVersion, data.CanonicalRootRole)] = bundle.Metadata[data.CanonicalRootRole.String()]
+	remote := r.getRemoteStore()
+	for v := 1; v < currentVersion; v++ {
+		versionedRole := fmt.Sprintf("%d.%s", v, data.CanonicalRootRole)
+		raw, err := remote.GetSized(versionedRole, store.NoSizeLimit)
+		if err != nil {
+			switch err.(type) {
+			case store.ErrMetaNotFound, store.ErrOffline:
+				// clients which already trust a later root can still use the bundle
+				logrus.Warnf("unable to include %s in bundle: %s", versionedRole, err)
+				continue
+			}
+			return nil, err
+		}
+		bundle.Metadata[versionedRole] = raw
+	}
+	return bundle, nil
+}
+
+// ImportBundle loads the metadata in a bundle with the same checks that are
+// applied to metadata downloaded from a server, including root rotation and
+// trust pinning, and writes it to the TUF cache in baseDir.  The repository can
+// then be read without access to a notary server.
+func ImportBundle(baseDir string, bundle *store.Bundle, trustPinning trustpinning.TrustPinConfig) error {
+	cache, err := store.NewFileStore(
+		filepath.Join(baseDir, tufDir, filepath.FromSlash(bundle.GUN.String()), "metadata"),
+		"json",
+	)
+	if err != nil {
+		return err
+	}
+	_, _, err = LoadTUFRepo(TUFLoadOptions{
+		GUN:          bundle.GUN,
+		TrustPinning: trustPinning,
+		Cache:        cache,
+		RemoteStore:  store.NewBundleStore(bundle),
+	})
+	return err
+}
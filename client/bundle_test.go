@@ -0,0 +1,127 @@
+package client
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	store "github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func roundTripBundle(t *testing.T, bundle *store.Bundle) *store.Bundle {
+	var buf bytes.Buffer
+	require.NoError(t, bundle.Write(&buf))
+	bundle, err := store.ReadBundle(&buf)
+	require.NoError(t, err)
+	return bundle
+}
+
+// A bundle contains every version of root and the current version of every
+// other role, and can be used to bootstrap a client with no access to the
+// server, or to rotate a client which trusts an old root.
+func TestBundleExportImport(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	addTarget(t, repo, "current", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+
+	// a client which trusts the first version of root, but is offline
+	oldRepo, _, oldDir := newRepoToTestRepo(t, repo, "")
+	defer os.RemoveAll(oldDir)
+	require.NoError(t, oldRepo.updateTUF(false))
+
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false, nil))
+	addTarget(t, repo, "latest", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+
+	bundle, err := repo.ExportBundle()
+	require.NoError(t, err)
+	bundle = roundTripBundle(t, bundle)
+	require.Equal(t, repo.gun, bundle.GUN)
+	for _, name := range []string{"root", "1.root", "2.root", "timestamp", "snapshot", "targets"} {
+		require.Contains(t, bundle.Metadata, name)
+	}
+	require.Equal(t, bundle.Metadata["root"], bundle.Metadata["2.root"])
+
+	// the bundle can be used in place of a server
+	bundleRepo, err := NewRepository(repo.gun, "", store.NewBundleStore(bundle), store.NewMemoryStore(nil),
+		trustpinning.TrustPinConfig{}, repo.cryptoService, repo.changelist)
+	require.NoError(t, err)
+	targets, err := bundleRepo.ListTargets()
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+
+	// importing it into a fresh client trusts the current root on first use, and a
+	// client which trusts the old root rotates to the new one
+	freshDir := oldDir + "-fresh"
+	defer os.RemoveAll(freshDir)
+	for _, dir := range []string{freshDir, oldDir} {
+		require.NoError(t, ImportBundle(dir, bundle, trustpinning.TrustPinConfig{}))
+
+		offlineRepo, err := NewFileCachedRepository(dir, repo.gun, "https://notary.invalid", nil, passphraseRetriever, trustpinning.TrustPinConfig{})
+		require.NoError(t, err)
+		targets, err := offlineRepo.ListTargets()
+		require.NoError(t, err)
+		require.Len(t, targets, 2)
+		target, err := offlineRepo.GetTargetByName("latest")
+		require.NoError(t, err)
+		require.Equal(t, data.CanonicalTargetsRole, target.Role)
+		roles, err := offlineRepo.ListRoles()
+		require.NoError(t, err)
+		for _, role := range roles {
+			if role.Name == data.CanonicalRootRole {
+				require.Equal(t, repo.tufRepo.Root.Signed.Roles[data.CanonicalRootRole].KeyIDs, role.KeyIDs)
+			}
+		}
+	}
+}
+
+// A client which trusts an old root can't import a bundle which skips the
+// versions of root needed to rotate to the current one, and the metadata in a
+// bundle is subject to trust pinning.
+func TestBundleImportVerifiesMetadata(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	require.NoError(t, repo.Publish())
+
+	oldRepo, _, oldDir := newRepoToTestRepo(t, repo, "")
+	defer os.RemoveAll(oldDir)
+	require.NoError(t, oldRepo.updateTUF(false))
+
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false, nil))
+	require.NoError(t, repo.Publish())
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false, nil))
+	require.NoError(t, repo.Publish())
+
+	bundle, err := repo.ExportBundle()
+	require.NoError(t, err)
+	require.Contains(t, bundle.Metadata, "3.root")
+	delete(bundle.Metadata, "2.root")
+	require.Error(t, ImportBundle(oldDir, bundle, trustpinning.TrustPinConfig{}))
+
+	// tampered metadata is rejected
+	bundle, err = repo.ExportBundle()
+	require.NoError(t, err)
+	bundle.Metadata["targets"] = append([]byte(" "), bundle.Metadata["targets"]...)
+	require.Error(t, ImportBundle(oldDir+"-tampered", bundle, trustpinning.TrustPinConfig{}))
+	os.RemoveAll(oldDir + "-tampered")
+
+	// trust pinning applies to a bundle just as it would to a server
+	bundle, err = repo.ExportBundle()
+	require.NoError(t, err)
+	pinnedDir := oldDir + "-pinned"
+	defer os.RemoveAll(pinnedDir)
+	err = ImportBundle(pinnedDir, bundle, trustpinning.TrustPinConfig{
+		Certs: map[string][]string{repo.gun.String(): {"abc"}},
+	})
+	require.Error(t, err)
+}
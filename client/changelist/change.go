@@ -20,6 +20,7 @@ const (
 const (
 	TypeBaseRole          = "role"
 	TypeTargetsTarget     = "target"
+	TypeTargetsBatch      = "target_batch"
 	TypeTargetsDelegation = "delegation"
 	TypeWitness           = "witness"
 )
@@ -35,10 +36,12 @@ type TUFChange struct {
 }
 
 // TUFRootData represents a modification of the keys associated
-// with a role that appears in the root.json
+// with a role that appears in the root.json, and optionally of its
+// threshold.  A zero Threshold leaves the role's threshold unchanged.
 type TUFRootData struct {
-	Keys     data.KeyList  `json:"keys"`
-	RoleName data.RoleName `json:"role"`
+	Keys      data.KeyList  `json:"keys"`
+	RoleName  data.RoleName `json:"role"`
+	Threshold int           `json:"threshold,omitempty"`
 }
 
 // NewTUFChange initializes a TUFChange object
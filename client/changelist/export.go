@@ -0,0 +1,78 @@
+package changelist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// exportedChangelist is the format changelists are exported in, so that they
+// can be handed to someone holding other keys
+type exportedChangelist struct {
+	GUN     data.GUN     `json:"gun"`
+	Changes []*TUFChange `json:"changes"`
+}
+
+// Export writes the changes in the changelist, which are for the GUN, to w
+func Export(cl Changelist, gun data.GUN, w io.Writer) error {
+	exported := exportedChangelist{GUN: gun, Changes: []*TUFChange{}}
+	for _, c := range cl.List() {
+		exported.Changes = append(exported.Changes, NewTUFChange(c.Action(), c.Scope(), c.Type(), c.Path(), c.Content()))
+	}
+	out, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// Import adds the changes exported for the GUN from r to the changelist, after
+// the changes already in it, returning how many it added.  Either all of the
+// changes are added, or none are.
+func Import(cl Changelist, gun data.GUN, r io.Reader) (int, error) {
+	var exported exportedChangelist
+	if err := json.NewDecoder(r).Decode(&exported); err != nil {
+		return 0, fmt.Errorf("invalid changelist export: %v", err)
+	}
+	if exported.GUN != gun {
+		return 0, fmt.Errorf("the changelist was exported for %s, not %s", exported.GUN, gun)
+	}
+	for i, c := range exported.Changes {
+		if c == nil || c.Scope() == "" || c.Type() == "" {
+			return 0, fmt.Errorf("invalid changelist export: change %d is incomplete", i)
+		}
+		switch c.Action() {
+		case ActionCreate, ActionUpdate, ActionDelete:
+		default:
+			return 0, fmt.Errorf("invalid changelist export: change %d has unknown action %q", i, c.Action())
+		}
+	}
+
+	add := func(cl Changelist) error {
+		existing := len(cl.List())
+		for i, c := range exported.Changes {
+			if err := cl.Add(c); err != nil {
+				added := make([]int, 0, i)
+				for j := existing; j < existing+i; j++ {
+					added = append(added, j)
+				}
+				cl.Remove(added)
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if tcl, ok := cl.(Transactional); ok {
+		err = tcl.Transaction(add)
+	} else {
+		err = add(cl)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(exported.Changes), nil
+}
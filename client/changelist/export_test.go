@@ -0,0 +1,77 @@
+package changelist
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	from := NewMemChangelist()
+	require.NoError(t, from.Add(NewTUFChange(ActionCreate, "targets", "target", "first", []byte(`{"length":1}`))))
+	require.NoError(t, from.Add(NewTUFChange(ActionDelete, "targets/releases", "target", "second", nil)))
+
+	var exported bytes.Buffer
+	require.NoError(t, Export(from, "docker.com/notary", &exported))
+
+	tmpDir, err := ioutil.TempDir("", "test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	to, err := NewFileChangelist(tmpDir + "/changelist")
+	require.NoError(t, err)
+	require.NoError(t, to.Add(NewTUFChange(ActionCreate, "targets", "target", "existing", nil)))
+
+	_, err = Import(to, "docker.com/other", bytes.NewReader(exported.Bytes()))
+	require.Error(t, err)
+	require.Len(t, to.List(), 1)
+
+	count, err := Import(to, "docker.com/notary", bytes.NewReader(exported.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	changes := to.List()
+	require.Len(t, changes, 3)
+	require.Equal(t, "existing", changes[0].Path())
+	for i, expected := range from.List() {
+		require.Equal(t, expected, changes[i+1])
+	}
+
+	for _, invalid := range []string{
+		"",
+		`{"gun": "docker.com/notary", "changes": [{"action": "create"}]}`,
+		`{"gun": "docker.com/notary", "changes": [{"action": "replace", "role": "targets", "type": "target"}]}`,
+	} {
+		_, err := Import(to, "docker.com/notary", strings.NewReader(invalid))
+		require.Error(t, err, invalid)
+	}
+	require.Len(t, to.List(), 3)
+}
+
+// failingChangelist fails to add its second change
+type failingChangelist struct {
+	*memChangelist
+}
+
+func (cl failingChangelist) Add(c Change) error {
+	if len(cl.List()) > 0 {
+		return errors.New("disk full")
+	}
+	return cl.memChangelist.Add(c)
+}
+
+func TestImportIsAllOrNothing(t *testing.T) {
+	from := NewMemChangelist()
+	require.NoError(t, from.Add(NewTUFChange(ActionCreate, "targets", "target", "first", nil)))
+	require.NoError(t, from.Add(NewTUFChange(ActionCreate, "targets", "target", "second", nil)))
+	var exported bytes.Buffer
+	require.NoError(t, Export(from, "docker.com/notary", &exported))
+
+	to := failingChangelist{NewMemChangelist().(*memChangelist)}
+	_, err := Import(to, "docker.com/notary", &exported)
+	require.Error(t, err)
+	require.Empty(t, to.List())
+}
@@ -13,9 +13,14 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// FileChangelist stores all the changes as files
+// FileChangelist stores all the changes as files.  Adding, removing and
+// clearing changes locks the changelist, so that several processes can share
+// it.
 type FileChangelist struct {
 	dir string
+	// held is set on the changelist passed to a transaction, which already
+	// has the lock
+	held bool
 }
 
 // NewFileChangelist is a convenience method for returning FileChangeLists
@@ -91,12 +96,20 @@ func (cl FileChangelist) Add(c Change) error {
 	if err != nil {
 		return err
 	}
-	filename := fmt.Sprintf("%020d_%s.change", time.Now().UnixNano(), uuid.Generate())
-	return ioutil.WriteFile(filepath.Join(cl.dir, filename), cJSON, 0600)
+	return cl.locked(func() error {
+		filename := fmt.Sprintf("%020d_%s.change", time.Now().UnixNano(), uuid.Generate())
+		return ioutil.WriteFile(filepath.Join(cl.dir, filename), cJSON, 0600)
+	})
 }
 
 // Remove deletes the changes found at the given indices
 func (cl FileChangelist) Remove(idxs []int) error {
+	return cl.locked(func() error {
+		return cl.remove(idxs)
+	})
+}
+
+func (cl FileChangelist) remove(idxs []int) error {
 	fileInfos, err := getFileNames(cl.dir)
 	if err != nil {
 		return err
@@ -119,6 +132,10 @@ func (cl FileChangelist) Remove(idxs []int) error {
 // Clear clears the change list
 // N.B. archiving not currently implemented
 func (cl FileChangelist) Clear(archive string) error {
+	return cl.locked(cl.clear)
+}
+
+func (cl FileChangelist) clear() error {
 	dir, err := os.Open(cl.dir)
 	if err != nil {
 		return err
@@ -148,6 +165,32 @@ func (cl FileChangelist) Location() string {
 	return cl.dir
 }
 
+// Transaction calls f with the changelist locked, so that no other process
+// can change it until f returns
+func (cl FileChangelist) Transaction(f func(Changelist) error) error {
+	return cl.locked(func() error {
+		return f(FileChangelist{dir: cl.dir, held: true})
+	})
+}
+
+// locked calls f while holding the changelist's lock, which is a file next to
+// its directory so that clearing the changelist does not remove it
+func (cl FileChangelist) locked(f func() error) error {
+	if cl.held {
+		return f()
+	}
+	lock, err := os.OpenFile(filepath.Clean(cl.dir)+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+	return f()
+}
+
 // NewIterator creates an iterator from FileChangelist
 func (cl FileChangelist) NewIterator() (ChangeIterator, error) {
 	fileInfos, err := getFileNames(cl.dir)
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -167,3 +168,34 @@ func TestFileChangeIterator(t *testing.T) {
 	it, err = cl.NewIterator()
 	require.Error(t, err, "Initializing iterator without underlying file store")
 }
+
+func TestFileChangelistTransaction(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	cl, err := NewFileChangelist(filepath.Join(tmpDir, "changelist"))
+	require.NoError(t, err)
+	require.NoError(t, cl.Add(NewTUFChange(ActionCreate, "targets", "target", "first", nil)))
+
+	// changes made while a transaction holds the lock wait for it to finish
+	added := make(chan error)
+	err = cl.Transaction(func(locked Changelist) error {
+		go func() {
+			added <- cl.Add(NewTUFChange(ActionCreate, "targets", "target", "second", nil))
+		}()
+		select {
+		case <-added:
+			t.Fatal("change added while the changelist was locked")
+		case <-time.After(100 * time.Millisecond):
+		}
+		require.Len(t, locked.List(), 1)
+		return locked.Clear("")
+	})
+	require.NoError(t, err)
+	require.NoError(t, <-added)
+
+	changes := cl.List()
+	require.Len(t, changes, 1)
+	require.Equal(t, "second", changes[0].Path())
+}
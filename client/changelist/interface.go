@@ -32,6 +32,16 @@ type Changelist interface {
 	Location() string
 }
 
+// Transactional is a changelist which only one process at a time may change
+type Transactional interface {
+	Changelist
+
+	// Transaction calls f with a changelist which no other process can change
+	// until f returns, such as between publishing its changes and clearing
+	// them.  f must only use the changelist it is given.
+	Transaction(f func(Changelist) error) error
+}
+
 const (
 	// ActionCreate represents a Create action
 	ActionCreate = "create"
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// namedChangelistsDir is where, under a GUN's directory, the changelists
+// other than the default one are kept
+const namedChangelistsDir = "changelists"
+
+// changelistNameRegexp is what changelist names must look like, so that they
+// can be used as directory names
+var changelistNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// changelistDir returns the directory of the GUN's changelist with the name,
+// or of its default changelist if the name is empty
+func changelistDir(baseDir string, gun data.GUN, name string) string {
+	gunDir := filepath.Join(baseDir, tufDir, filepath.FromSlash(gun.String()))
+	if name == "" {
+		return filepath.Join(gunDir, "changelist")
+	}
+	return filepath.Join(gunDir, namedChangelistsDir, name)
+}
+
+// NewNamedFileChangelist opens the GUN's changelist with the name, creating it
+// if need be, so that changes can be staged separately from those in its
+// default changelist and published on their own.  An empty name opens the
+// default changelist.
+func NewNamedFileChangelist(baseDir string, gun data.GUN, name string) (*changelist.FileChangelist, error) {
+	if name != "" && !changelistNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid changelist name %q: names may only contain letters, digits, '.', '_' and '-'", name)
+	}
+	return changelist.NewFileChangelist(changelistDir(baseDir, gun, name))
+}
+
+// ListChangelists returns the sorted names of the GUN's named changelists
+func ListChangelists(baseDir string, gun data.GUN) ([]string, error) {
+	fileInfos, err := ioutil.ReadDir(filepath.Join(
+		baseDir, tufDir, filepath.FromSlash(gun.String()), namedChangelistsDir))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() {
+			names = append(names, fileInfo.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
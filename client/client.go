@@ -17,6 +17,7 @@ import (
 	"github.com/theupdateframework/notary/client/changelist"
 	"github.com/theupdateframework/notary/cryptoservice"
 	store "github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/trustpinning"
 	"github.com/theupdateframework/notary/tuf"
 	"github.com/theupdateframework/notary/tuf/data"
@@ -47,9 +48,24 @@ type repository struct {
 	invalid        *tuf.Repo // known data that was parsable but deemed invalid
 	roundTrip      http.RoundTripper
 	trustPinning   trustpinning.TrustPinConfig
+	expiryPolicy   *data.ExpiryPolicy
+	retryPolicy    PublishRetryPolicy
+	roleThresholds map[data.RoleName]int
 	LegacyVersions int // number of versions back to fetch roots to sign with
 }
 
+// PublishRetryPolicy decides how many times a publish which conflicted with
+// someone else's is retried, on top of the trust data they published, and how
+// long to wait before the first retry.  The wait doubles after each retry.
+type PublishRetryPolicy struct {
+	Retries int
+	Backoff time.Duration
+}
+
+// DefaultPublishRetryPolicy is the policy repositories retry publishes with
+// unless another is set
+var DefaultPublishRetryPolicy = PublishRetryPolicy{Retries: 3, Backoff: 500 * time.Millisecond}
+
 // NewFileCachedRepository is a wrapper for NewRepository that initializes
 // a file cache from the provided repository, local config information and a crypto service.
 // It also retrieves the remote store associated to the base directory under where all the
@@ -60,6 +76,16 @@ type repository struct {
 func NewFileCachedRepository(baseDir string, gun data.GUN, baseURL string, rt http.RoundTripper,
 	retriever notary.PassRetriever, trustPinning trustpinning.TrustPinConfig) (Repository, error) {
 
+	return NewFileCachedRepositoryWithKeyStores(baseDir, gun, baseURL, rt, retriever, trustPinning)
+}
+
+// NewFileCachedRepositoryWithKeyStores is NewFileCachedRepository, except
+// that private keys are looked up in, and new keys are added to, the provided
+// key stores (such as a PKCS#11 token) before the ones under baseDir.
+func NewFileCachedRepositoryWithKeyStores(baseDir string, gun data.GUN, baseURL string, rt http.RoundTripper,
+	retriever notary.PassRetriever, trustPinning trustpinning.TrustPinConfig,
+	keyStores ...trustmanager.KeyStore) (Repository, error) {
+
 	cache, err := store.NewFileStore(
 		filepath.Join(baseDir, tufDir, filepath.FromSlash(gun.String()), "metadata"),
 		"json",
@@ -68,11 +94,12 @@ func NewFileCachedRepository(baseDir string, gun data.GUN, baseURL string, rt ht
 		return nil, err
 	}
 
-	keyStores, err := getKeyStores(baseDir, retriever)
+	defaultKeyStores, err := getKeyStores(baseDir, retriever)
 	if err != nil {
 		return nil, err
 	}
 
+	keyStores = append(append([]trustmanager.KeyStore{}, keyStores...), defaultKeyStores...)
 	cryptoService := cryptoservice.NewCryptoService(keyStores...)
 
 	remoteStore, err := getRemoteStore(baseURL, gun, rt)
@@ -81,9 +108,7 @@ func NewFileCachedRepository(baseDir string, gun data.GUN, baseURL string, rt ht
 		return nil, err
 	}
 
-	cl, err := changelist.NewFileChangelist(filepath.Join(
-		filepath.Join(baseDir, tufDir, filepath.FromSlash(gun.String()), "changelist"),
-	))
+	cl, err := changelist.NewFileChangelist(changelistDir(baseDir, gun, ""))
 	if err != nil {
 		return nil, err
 	}
@@ -114,6 +139,7 @@ func NewRepository(gun data.GUN, baseURL string, remoteStore store.RemoteStore,
 		remoteStore:    remoteStore,
 		cryptoService:  cryptoService,
 		trustPinning:   trustPinning,
+		retryPolicy:    DefaultPublishRetryPolicy,
 		LegacyVersions: 0, // By default, don't sign with legacy roles
 	}
 
@@ -138,6 +164,7 @@ func (r *repository) updateTUF(forWrite bool) error {
 		return err
 	}
 	r.tufRepo = repo
+	r.tufRepo.SetExpiryPolicy(r.gun, r.expiryPolicy)
 	r.invalid = invalid
 	return nil
 }
@@ -158,6 +185,14 @@ func (r *repository) GetTargetByName(name string, roles ...data.RoleName) (*Targ
 	return NewReadOnly(r.tufRepo).GetTargetByName(name, roles...)
 }
 
+// GetTargetByNameIgnoringLifecycle calls update first before getting target by name
+func (r *repository) GetTargetByNameIgnoringLifecycle(name string, roles ...data.RoleName) (*TargetWithRole, error) {
+	if err := r.updateTUF(false); err != nil {
+		return nil, err
+	}
+	return NewReadOnly(r.tufRepo).GetTargetByNameIgnoringLifecycle(name, roles...)
+}
+
 // GetAllTargetMetadataByName calls update first before getting targets by name
 func (r *repository) GetAllTargetMetadataByName(name string) ([]TargetSignedStruct, error) {
 	if err := r.updateTUF(false); err != nil {
@@ -251,6 +286,20 @@ func (r *repository) initialize(rootKeyIDs []string, rootCerts []data.PublicKey,
 		}
 	}
 
+	thresholds := make(map[data.RoleName]int, len(data.BaseRoles))
+	for _, role := range data.BaseRoles {
+		serverManaged := role == data.CanonicalTimestampRole ||
+			(role == data.CanonicalSnapshotRole && serverManagesSnapshot)
+		threshold, err := r.roleThreshold(role, serverManaged)
+		if err != nil {
+			return err
+		}
+		if threshold == 0 {
+			threshold = notary.MinThreshold
+		}
+		thresholds[role] = threshold
+	}
+
 	// gets valid public keys corresponding to the rootKeyIDs or generate if necessary
 	var publicKeys []data.PublicKey
 	var err error
@@ -262,10 +311,14 @@ func (r *repository) initialize(rootKeyIDs []string, rootCerts []data.PublicKey,
 	if err != nil {
 		return err
 	}
+	if err := checkThreshold(data.NewBaseRole(data.CanonicalRootRole, thresholds[data.CanonicalRootRole], publicKeys...)); err != nil {
+		return err
+	}
 
 	//initialize repo with public keys
 	rootRole, targetsRole, snapshotRole, timestampRole, err := r.initializeRoles(
 		publicKeys,
+		thresholds,
 		locallyManagedKeys,
 		remotelyManagedKeys,
 	)
@@ -274,6 +327,7 @@ func (r *repository) initialize(rootKeyIDs []string, rootCerts []data.PublicKey,
 	}
 
 	r.tufRepo = tuf.NewRepo(r.GetCryptoService())
+	r.tufRepo.SetExpiryPolicy(r.gun, r.expiryPolicy)
 
 	if err := r.tufRepo.InitRoot(
 		rootRole,
@@ -402,35 +456,40 @@ func (r *repository) InitializeWithCertificate(rootKeyIDs []string, rootCerts []
 	return r.initialize(rootKeyIDs, rootCerts, serverManagedRoles...)
 }
 
-func (r *repository) initializeRoles(rootKeys []data.PublicKey, localRoles, remoteRoles []data.RoleName) (
+func (r *repository) initializeRoles(rootKeys []data.PublicKey, thresholds map[data.RoleName]int, localRoles, remoteRoles []data.RoleName) (
 	root, targets, snapshot, timestamp data.BaseRole, err error) {
 	root = data.NewBaseRole(
 		data.CanonicalRootRole,
-		notary.MinThreshold,
+		thresholds[data.CanonicalRootRole],
 		rootKeys...,
 	)
 
 	// we want to create all the local keys first so we don't have to
 	// make unnecessary network calls
 	for _, role := range localRoles {
+		// Create enough keys to meet the role's threshold.
 		// This is currently hardcoding the keys to ECDSA.
-		var key data.PublicKey
-		key, err = r.GetCryptoService().Create(role, r.gun, data.ECDSAKey)
-		if err != nil {
-			return
+		keys := make([]data.PublicKey, 0, thresholds[role])
+		for len(keys) < thresholds[role] {
+			var key data.PublicKey
+			key, err = r.GetCryptoService().Create(role, r.gun, data.ECDSAKey)
+			if err != nil {
+				return
+			}
+			keys = append(keys, key)
 		}
 		switch role {
 		case data.CanonicalSnapshotRole:
 			snapshot = data.NewBaseRole(
 				role,
-				notary.MinThreshold,
-				key,
+				thresholds[role],
+				keys...,
 			)
 		case data.CanonicalTargetsRole:
 			targets = data.NewBaseRole(
 				role,
-				notary.MinThreshold,
-				key,
+				thresholds[role],
+				keys...,
 			)
 		}
 	}
@@ -502,12 +561,12 @@ func addChange(cl changelist.Changelist, c changelist.Change, roles ...data.Role
 // in the repository when the changelist gets applied at publish time.
 // If roles are unspecified, the default role is "targets"
 func (r *repository) AddTarget(target *Target, roles ...data.RoleName) error {
-	if len(target.Hashes) == 0 {
-		return fmt.Errorf("no hashes specified for target \"%s\"", target.Name)
+	meta, err := targetMeta(target)
+	if err != nil {
+		return err
 	}
 	logrus.Debugf("Adding target \"%s\" with sha256 \"%x\" and size %d bytes.\n", target.Name, target.Hashes["sha256"], target.Length)
 
-	meta := data.FileMeta{Length: target.Length, Hashes: target.Hashes, Custom: target.Custom}
 	metaJSON, err := json.Marshal(meta)
 	if err != nil {
 		return err
@@ -529,6 +588,85 @@ func (r *repository) RemoveTarget(targetName string, roles ...data.RoleName) err
 	return addChange(r.changelist, template, roles...)
 }
 
+// AddTargets creates one changelist entry per role to add all of the targets
+// to it when the changelist gets applied at publish time.  Every target is
+// validated before anything is staged.  If roles are unspecified, the default
+// role is "targets"
+func (r *repository) AddTargets(targets []*Target, roles ...data.RoleName) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets specified")
+	}
+	files := make(data.Files, len(targets))
+	for _, target := range targets {
+		if _, ok := files[target.Name]; ok {
+			return fmt.Errorf("target \"%s\" specified more than once", target.Name)
+		}
+		meta, err := targetMeta(target)
+		if err != nil {
+			return err
+		}
+		files[target.Name] = meta
+	}
+	logrus.Debugf("Adding %d targets.\n", len(files))
+
+	filesJSON, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+
+	template := changelist.NewTUFChange(
+		changelist.ActionCreate, "", changelist.TypeTargetsBatch,
+		batchPath(len(files)), filesJSON)
+	return addChange(r.changelist, template, roles...)
+}
+
+// RemoveTargets creates one changelist entry per role to remove all of the
+// targets from it when the changelist gets applied at publish time.
+// If roles are unspecified, the default role is "targets"
+func (r *repository) RemoveTargets(targetNames []string, roles ...data.RoleName) error {
+	if len(targetNames) == 0 {
+		return fmt.Errorf("no targets specified")
+	}
+	seen := make(map[string]bool, len(targetNames))
+	for _, name := range targetNames {
+		if seen[name] {
+			return fmt.Errorf("target \"%s\" specified more than once", name)
+		}
+		seen[name] = true
+	}
+	logrus.Debugf("Removing %d targets.\n", len(targetNames))
+
+	namesJSON, err := json.Marshal(targetNames)
+	if err != nil {
+		return err
+	}
+
+	template := changelist.NewTUFChange(
+		changelist.ActionDelete, "", changelist.TypeTargetsBatch,
+		batchPath(len(targetNames)), namesJSON)
+	return addChange(r.changelist, template, roles...)
+}
+
+// targetMeta validates the target, returning the metadata to stage for it
+func targetMeta(target *Target) (data.FileMeta, error) {
+	if len(target.Hashes) == 0 {
+		return data.FileMeta{}, fmt.Errorf("no hashes specified for target \"%s\"", target.Name)
+	}
+	if _, err := target.Lifecycle(); err != nil {
+		return data.FileMeta{}, err
+	}
+	return data.FileMeta{Length: target.Length, Hashes: target.Hashes, Custom: target.Custom}, nil
+}
+
+// batchPath is the path of a batch change, which is only shown to people,
+// since the names of the targets are in its content
+func batchPath(count int) string {
+	if count == 1 {
+		return "1 target"
+	}
+	return fmt.Sprintf("%d targets", count)
+}
+
 // GetChangelist returns the list of the repository's unpublished changes
 func (r *repository) GetChangelist() (changelist.Changelist, error) {
 	return r.changelist, nil
@@ -549,21 +687,57 @@ func (r *repository) getRemoteStore() store.RemoteStore {
 // Publish pushes the local changes in signed material to the remote notary-server
 // Conceptually it performs an operation similar to a `git rebase`
 func (r *repository) Publish() error {
-	if err := r.publish(r.changelist); err != nil {
+	// changes staged by other processes while publishing must not be cleared
+	// without having been published, so hold the changelist if we can
+	if tcl, ok := r.changelist.(changelist.Transactional); ok {
+		return tcl.Transaction(r.publishAndClear)
+	}
+	return r.publishAndClear(r.changelist)
+}
+
+// publishAndClear publishes the changes in the changelist, then clears it
+func (r *repository) publishAndClear(cl changelist.Changelist) error {
+	if err := r.publishWithRetries(cl); err != nil {
 		return err
 	}
-	if err := r.changelist.Clear(""); err != nil {
+	if err := cl.Clear(""); err != nil {
 		// This is not a critical problem when only a single host is pushing
 		// but will cause weird behaviour if changelist cleanup is failing
 		// and there are multiple hosts writing to the repo.
-		logrus.Warn("Unable to clear changelist. You may want to manually delete the folder ", r.changelist.Location())
+		logrus.Warn("Unable to clear changelist. You may want to manually delete the folder ", cl.Location())
 	}
 	return nil
 }
 
+// publishWithRetries publishes the changes in the changelist and, if someone
+// else published at the same time, downloads what they published and tries
+// again with the changes applied on top of it, as the retry policy allows
+func (r *repository) publishWithRetries(cl changelist.Changelist) error {
+	backoff := r.retryPolicy.Backoff
+	err := r.publish(cl)
+	for retry := 1; retry <= r.retryPolicy.Retries; retry++ {
+		if _, ok := err.(store.ErrVersionConflict); !ok {
+			return err
+		}
+		logrus.Infof("newer trust data was published for %s, retrying publish (%d of %d) in %s",
+			r.gun.String(), retry, r.retryPolicy.Retries, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		err = r.publishChanges(cl, true)
+	}
+	return err
+}
+
 // publish pushes the changes in the given changelist to the remote notary-server
 // Conceptually it performs an operation similar to a `git rebase`
 func (r *repository) publish(cl changelist.Changelist) error {
+	return r.publishChanges(cl, false)
+}
+
+// publishChanges updates to the latest trust data, applies the changes in the
+// changelist and publishes the result.  When rebasing, a change which can no
+// longer be applied is reported as such.
+func (r *repository) publishChanges(cl changelist.Changelist, rebase bool) error {
 	var initialPublish bool
 	// update first before publishing
 	if err := r.updateTUF(true); err != nil {
@@ -593,10 +767,18 @@ func (r *repository) publish(cl changelist.Changelist) error {
 		}
 	}
 	// apply the changelist to the repo
-	if err := applyChangelist(r.tufRepo, r.invalid, cl); err != nil {
+	apply := applyChangelist
+	if rebase {
+		apply = rebaseChangelist
+	}
+	if err := apply(r.tufRepo, r.invalid, cl); err != nil {
 		logrus.Debug("Error applying changelist")
 		return err
 	}
+	// don't publish roles which could never be signed
+	if err := checkBaseRoleThresholds(r.tufRepo); err != nil {
+		return err
+	}
 
 	// these are the TUF files we will need to update, serialized as JSON before
 	// we send anything to remote
@@ -619,6 +801,12 @@ func (r *repository) publish(cl changelist.Changelist) error {
 		return err
 	}
 
+	return r.publishSigned(updatedFiles)
+}
+
+// publishSigned signs a new snapshot, if we have the key to do so, and then
+// sends it to the remote along with the given already signed metadata
+func (r *repository) publishSigned(updatedFiles map[data.RoleName][]byte) error {
 	// if we initialized the repo while designating the server as the snapshot
 	// signer, then there won't be a snapshots file.  However, we might now
 	// have a local key (if there was a rotation), so initialize one.
@@ -674,7 +862,29 @@ func (r *repository) oldKeysForLegacyClientSupport(legacyVersions int, initialPu
 	if initialPublish {
 		return nil, nil
 	}
+	oldRoots, err := r.legacyRoots(legacyVersions)
+	if err != nil {
+		return nil, err
+	}
+	oldKeys := make(map[string]data.PublicKey)
+	for _, oldRoot := range oldRoots {
+		// extract legacy versioned root keys
+		oldRootVersionKeys := getOldRootPublicKeys(oldRoot)
+		for _, oldKey := range oldRootVersionKeys {
+			oldKeys[oldKey.ID()] = oldKey
+		}
+	}
+	oldKeyList := make(data.KeyList, 0, len(oldKeys))
+	for _, key := range oldKeys {
+		oldKeyList = append(oldKeyList, key)
+	}
+	return oldKeyList, nil
+}
 
+// legacyRoots fetches back a `legacyVersions` number of root files from the
+// server, newest first, starting with the current version.  Versions which
+// cannot be downloaded are skipped.
+func (r *repository) legacyRoots(legacyVersions int) ([]*data.SignedRoot, error) {
 	var oldestVersion int
 	prevVersion := r.tufRepo.Root.Signed.Version
 
@@ -691,7 +901,6 @@ func (r *repository) oldKeysForLegacyClientSupport(legacyVersions int, initialPu
 	if prevVersion <= 1 || oldestVersion == prevVersion {
 		return nil, nil
 	}
-	oldKeys := make(map[string]data.PublicKey)
 
 	c, err := bootstrapClient(TUFLoadOptions{
 		GUN:                    r.gun,
@@ -706,6 +915,7 @@ func (r *repository) oldKeysForLegacyClientSupport(legacyVersions int, initialPu
 		return nil, err
 	}
 
+	var oldRoots []*data.SignedRoot
 	for v := prevVersion; v >= oldestVersion; v-- {
 		logrus.Debugf("fetching old keys from version %d", v)
 		// fetch old root version
@@ -725,21 +935,11 @@ func (r *repository) oldKeysForLegacyClientSupport(legacyVersions int, initialPu
 		if err != nil {
 			return nil, err
 		}
-
-		// extract legacy versioned root keys
-		oldRootVersionKeys := getOldRootPublicKeys(oldRootVersion)
-		for _, oldKey := range oldRootVersionKeys {
-			oldKeys[oldKey.ID()] = oldKey
-		}
-	}
-	oldKeyList := make(data.KeyList, 0, len(oldKeys))
-	for _, key := range oldKeys {
-		oldKeyList = append(oldKeyList, key)
+		oldRoots = append(oldRoots, oldRootVersion)
 	}
-	return oldKeyList, nil
+	return oldRoots, nil
 }
 
-// get all the saved previous roles keys < the current root version
 func getOldRootPublicKeys(root *data.SignedRoot) data.KeyList {
 	rootRole, err := root.BuildBaseRole(data.CanonicalRootRole)
 	if err != nil {
@@ -792,6 +992,7 @@ func (r *repository) bootstrapRepo() error {
 	tufRepo, _, err := b.Finish()
 	if err == nil {
 		r.tufRepo = tufRepo
+		r.tufRepo.SetExpiryPolicy(r.gun, r.expiryPolicy)
 	}
 	return nil
 }
@@ -812,7 +1013,7 @@ func (r *repository) saveMetadata(ignoreSnapshot bool) error {
 
 	targetsToSave := make(map[data.RoleName][]byte)
 	for t := range r.tufRepo.Targets {
-		signedTargets, err := r.tufRepo.SignTargets(t, data.DefaultExpires(data.CanonicalTargetsRole))
+		signedTargets, err := r.tufRepo.SignTargets(t, time.Time{})
 		if err != nil {
 			return err
 		}
@@ -841,29 +1042,59 @@ func (r *repository) saveMetadata(ignoreSnapshot bool) error {
 }
 
 // RotateKey removes all existing keys associated with the role. If no keys are
-// specified in keyList, then this creates and adds one new key or delegates
-// managing the key to the server. If key(s) are specified by keyList, then they are
-// used for signing the role.
+// specified in keyList, then this creates and adds enough new keys to meet the
+// role's threshold, or delegates managing the key to the server. If key(s) are
+// specified by keyList, then they are used for signing the role.  If a
+// threshold was set for the role with SetRoleThresholds, the role's threshold
+// is changed to it.  Handing the key to the server changes it to 1.
 // These changes are staged in a changelist until publish is called.
 func (r *repository) RotateKey(role data.RoleName, serverManagesKey bool, keyList []string) error {
 	if err := checkRotationInput(role, serverManagesKey); err != nil {
 		return err
 	}
+	threshold, err := r.roleThreshold(role, serverManagesKey)
+	if err != nil {
+		return err
+	}
 
-	pubKeyList, err := r.pubKeyListForRotation(role, serverManagesKey, keyList)
+	numKeys := threshold
+	if numKeys == 0 && len(keyList) == 0 && !serverManagesKey {
+		numKeys = r.currentThreshold(role)
+	}
+	pubKeyList, err := r.pubKeyListForRotation(role, serverManagesKey, keyList, numKeys)
 	if err != nil {
 		return err
 	}
+	if threshold != 0 {
+		if err := checkThreshold(data.NewBaseRole(role, threshold, pubKeyList...)); err != nil {
+			return err
+		}
+	}
 
 	cl := changelist.NewMemChangelist()
-	if err := r.rootFileKeyChange(cl, role, changelist.ActionCreate, pubKeyList); err != nil {
+	if err := r.rootFileKeyChange(cl, role, changelist.ActionCreate, pubKeyList, threshold); err != nil {
 		return err
 	}
 	return r.publish(cl)
 }
 
-// Given a set of new keys to rotate to and a set of keys to drop, returns the list of current keys to use
-func (r *repository) pubKeyListForRotation(role data.RoleName, serverManaged bool, newKeys []string) (pubKeyList data.KeyList, err error) {
+// currentThreshold returns the threshold of a base role, or the default
+// threshold if the repository cannot be updated, such as before it is first
+// published
+func (r *repository) currentThreshold(role data.RoleName) int {
+	if err := r.updateTUF(true); err != nil {
+		return notary.MinThreshold
+	}
+	baseRole, err := r.tufRepo.GetBaseRole(role)
+	if err != nil {
+		return notary.MinThreshold
+	}
+	return baseRole.Threshold
+}
+
+// Given a set of new keys to rotate to and a set of keys to drop, returns the list of current keys to use.
+// If no new keys are given, numKeys keys are generated.
+func (r *repository) pubKeyListForRotation(role data.RoleName, serverManaged bool, newKeys []string, numKeys int) (pubKeyList data.KeyList, err error) {
 	var pubKey data.PublicKey
 
 	// If server manages the key being rotated, request a rotation and return the new key
@@ -878,14 +1109,19 @@ func (r *repository) pubKeyListForRotation(role data.RoleName, serverManaged boo
 		return pubKeyList, nil
 	}
 
-	// If no new keys are passed in, we generate one
+	// If no new keys are passed in, we generate them
 	if len(newKeys) == 0 {
-		pubKeyList = make(data.KeyList, 0, 1)
-		pubKey, err = r.GetCryptoService().Create(role, r.gun, data.ECDSAKey)
-		pubKeyList = append(pubKeyList, pubKey)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("unable to generate key: %s", err)
+		if numKeys < notary.MinThreshold {
+			numKeys = notary.MinThreshold
+		}
+		pubKeyList = make(data.KeyList, 0, numKeys)
+		for len(pubKeyList) < numKeys {
+			pubKey, err = r.GetCryptoService().Create(role, r.gun, data.ECDSAKey)
+			if err != nil {
+				return nil, fmt.Errorf("unable to generate key: %s", err)
+			}
+			pubKeyList = append(pubKeyList, pubKey)
+		}
 	}
 
 	// If a list of keys to rotate to are provided, we add those
@@ -949,10 +1185,11 @@ func checkRotationInput(role data.RoleName, serverManaged bool) error {
 	return nil
 }
 
-func (r *repository) rootFileKeyChange(cl changelist.Changelist, role data.RoleName, action string, keyList []data.PublicKey) error {
+func (r *repository) rootFileKeyChange(cl changelist.Changelist, role data.RoleName, action string, keyList []data.PublicKey, threshold int) error {
 	meta := changelist.TUFRootData{
-		RoleName: role,
-		Keys:     keyList,
+		RoleName:  role,
+		Keys:      keyList,
+		Threshold: threshold,
 	}
 	metaJSON, err := json.Marshal(meta)
 	if err != nil {
@@ -996,3 +1233,78 @@ func DeleteTrustData(baseDir string, gun data.GUN, URL string, rt http.RoundTrip
 func (r *repository) SetLegacyVersions(n int) {
 	r.LegacyVersions = n
 }
+
+// SetRoleThresholds sets the thresholds of base roles to use when
+// initializing the repository and when rotating their keys.  Roles without a
+// threshold get a threshold of 1 when initializing, and keep their current
+// threshold when rotating.
+func (r *repository) SetRoleThresholds(thresholds map[data.RoleName]int) {
+	r.roleThresholds = thresholds
+}
+
+// roleThreshold returns the threshold set for a base role with
+// SetRoleThresholds, or 0 if none was set.  The server only manages a single
+// key for a role, so server managed roles always have a threshold of 1, even
+// if the role had a higher one while its keys were managed locally.
+func (r *repository) roleThreshold(role data.RoleName, serverManaged bool) (int, error) {
+	threshold := r.roleThresholds[role]
+	switch {
+	case threshold == 0 && serverManaged:
+		return notary.MinThreshold, nil
+	case threshold == 0:
+		return 0, nil
+	case threshold < notary.MinThreshold:
+		return 0, data.ErrInvalidRole{Role: role, Reason: fmt.Sprintf("threshold must be at least %d", notary.MinThreshold)}
+	case threshold > notary.MinThreshold && serverManaged:
+		return 0, data.ErrInvalidRole{Role: role, Reason: "the server manages a single key for it, so its threshold must be 1"}
+	}
+	return threshold, nil
+}
+
+// checkThreshold returns an error if a role has fewer keys than its threshold,
+// since nothing signed by the role could ever be trusted
+func checkThreshold(role data.BaseRole) error {
+	if len(role.Keys) < role.Threshold {
+		return data.ErrInvalidRole{
+			Role:   role.Name,
+			Reason: fmt.Sprintf("its threshold of %d cannot be met by its %d keys", role.Threshold, len(role.Keys)),
+		}
+	}
+	return nil
+}
+
+// checkBaseRoleThresholds checks that the thresholds of all the base roles
+// can be met
+func checkBaseRoleThresholds(repo *tuf.Repo) error {
+	for _, role := range data.BaseRoles {
+		baseRole, err := repo.GetBaseRole(role)
+		if err != nil {
+			return err
+		}
+		if err := checkThreshold(baseRole); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetChangelist sets the changelist which changes are staged in and
+// published from
+func (r *repository) SetChangelist(cl changelist.Changelist) {
+	r.changelist = cl
+}
+
+// SetPublishRetryPolicy sets how publishes which conflict with someone else's
+// are retried.  A policy with no retries fails such publishes immediately.
+func (r *repository) SetPublishRetryPolicy(policy PublishRetryPolicy) {
+	r.retryPolicy = policy
+}
+
+// SetExpiryPolicy sets the policy which decides when the metadata signed by
+// the repository expires.  A nil policy uses the default expiry times.
+func (r *repository) SetExpiryPolicy(policy *data.ExpiryPolicy) {
+	r.expiryPolicy = policy
+	if r.tufRepo != nil {
+		r.tufRepo.SetExpiryPolicy(r.gun, policy)
+	}
+}
@@ -12,11 +12,14 @@ import (
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -1150,6 +1153,176 @@ func TestRemoveTargetErrorWritingChanges(t *testing.T) {
 	})
 }
 
+// TestAddAndRemoveTargets stages several targets with one change per role,
+// publishes them, then removes them the same way.  Invalid batches stage
+// nothing.
+func TestAddAndRemoveTargets(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+
+	var targetCustom *json.RawMessage
+	first, err := NewTarget("first", "../fixtures/intermediate-ca.crt", targetCustom)
+	require.NoError(t, err)
+	second, err := NewTarget("second", "../fixtures/root-ca.crt", targetCustom)
+	require.NoError(t, err)
+	noHashes := &Target{Name: "third", Length: 1}
+
+	require.Error(t, repo.AddTargets(nil))
+	require.Error(t, repo.AddTargets([]*Target{first, first}))
+	require.Error(t, repo.AddTargets([]*Target{first, noHashes}))
+	require.IsType(t, data.ErrInvalidRole{}, repo.AddTargets([]*Target{first}, data.CanonicalRootRole))
+	require.Len(t, getChanges(t, repo), 0)
+
+	require.NoError(t, repo.AddTargets([]*Target{first, second}))
+	changes := getChanges(t, repo)
+	require.Len(t, changes, 1)
+	require.Equal(t, changelist.ActionCreate, changes[0].Action())
+	require.Equal(t, data.CanonicalTargetsRole, changes[0].Scope())
+	require.Equal(t, changelist.TypeTargetsBatch, changes[0].Type())
+	require.Equal(t, "2 targets", changes[0].Path())
+
+	require.NoError(t, repo.Publish())
+	targets, err := repo.ListTargets()
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	target, err := repo.GetTargetByName("second")
+	require.NoError(t, err)
+	require.Equal(t, second.Hashes, target.Hashes)
+
+	require.Error(t, repo.RemoveTargets(nil))
+	require.Error(t, repo.RemoveTargets([]string{"first", "first"}))
+	require.NoError(t, repo.RemoveTargets([]string{"first", "second"}))
+	changes = getChanges(t, repo)
+	require.Len(t, changes, 1)
+	require.Equal(t, changelist.ActionDelete, changes[0].Action())
+	require.Equal(t, changelist.TypeTargetsBatch, changes[0].Type())
+
+	require.NoError(t, repo.Publish())
+	targets, err = repo.ListTargets()
+	require.NoError(t, err)
+	require.Len(t, targets, 0)
+}
+
+// Changes staged in a named changelist are kept apart from the default
+// changelist, and only they are published when it is used
+func TestNamedChangelist(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	gun := data.GUN("docker.com/notary")
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, gun.String(), ts.URL, false)
+	defer os.RemoveAll(baseDir)
+
+	for _, invalid := range []string{".hidden", "a/b", "../a", "a b"} {
+		_, err := NewNamedFileChangelist(baseDir, gun, invalid)
+		require.Error(t, err, invalid)
+	}
+	names, err := ListChangelists(baseDir, gun)
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	var targetCustom *json.RawMessage
+	first, err := NewTarget("first", "../fixtures/intermediate-ca.crt", targetCustom)
+	require.NoError(t, err)
+	second, err := NewTarget("second", "../fixtures/root-ca.crt", targetCustom)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddTarget(first))
+
+	cl, err := NewNamedFileChangelist(baseDir, gun, "release-42")
+	require.NoError(t, err)
+	repo.SetChangelist(cl)
+	require.NoError(t, repo.AddTarget(second))
+	require.Len(t, getChanges(t, repo), 1)
+	require.NoError(t, repo.Publish())
+	require.Len(t, cl.List(), 0)
+
+	targets, err := repo.ListTargets()
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	require.Equal(t, "second", targets[0].Name)
+
+	names, err = ListChangelists(baseDir, gun)
+	require.NoError(t, err)
+	require.Equal(t, []string{"release-42"}, names)
+
+	// the default changelist still holds its change
+	defaultCL, err := NewNamedFileChangelist(baseDir, gun, "")
+	require.NoError(t, err)
+	require.Len(t, defaultCL.List(), 1)
+}
+
+// racingTestServer forwards requests to the given server, but before it
+// forwards the next update once armed, it calls race, so that someone else
+// can publish first
+func racingTestServer(t *testing.T, ts *httptest.Server) (*httptest.Server, func(race func())) {
+	tsURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(tsURL)
+
+	var mutex sync.Mutex
+	var nextRace func()
+	racer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		race := nextRace
+		if r.Method == http.MethodPost {
+			nextRace = nil
+		}
+		mutex.Unlock()
+		if race != nil && r.Method == http.MethodPost {
+			race()
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	return racer, func(race func()) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		nextRace = race
+	}
+}
+
+// A publish which conflicts with someone else's is retried on top of what
+// they published, as the retry policy allows
+func TestPublishRetriesOnConflict(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+	racer, arm := racingTestServer(t, ts)
+	defer racer.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", racer.URL, false)
+	defer os.RemoveAll(baseDir)
+	repo.SetPublishRetryPolicy(PublishRetryPolicy{Retries: 1, Backoff: time.Millisecond})
+	require.NoError(t, repo.Publish())
+
+	// someone else, with the same keys, publishing straight to the server
+	other, _, _ := newRepoToTestRepo(t, repo, baseDir)
+	other.baseURL = ts.URL
+	other.SetChangelist(changelist.NewMemChangelist())
+	publishOther := func(name string) func() {
+		return func() {
+			addTarget(t, other, name, "../fixtures/root-ca.crt")
+			require.NoError(t, other.Publish())
+		}
+	}
+
+	arm(publishOther("theirs"))
+	addTarget(t, repo, "mine", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+	require.Len(t, getChanges(t, repo), 0)
+	targets, err := repo.ListTargets()
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+
+	// without retries, the conflict is reported and the changes are kept
+	repo.SetPublishRetryPolicy(PublishRetryPolicy{})
+	arm(publishOther("theirs-again"))
+	addTarget(t, repo, "mine-again", "../fixtures/intermediate-ca.crt")
+	require.IsType(t, store.ErrVersionConflict{}, repo.Publish())
+	require.Len(t, getChanges(t, repo), 1)
+}
+
 // TestListTarget fakes serving signed metadata files over the test's
 // internal HTTP server to ensure that ListTargets returns the correct number
 // of listed targets.
@@ -3284,6 +3457,175 @@ func TestRotateRootKeyLegacySupport(t *testing.T) {
 	require.Equal(t, newRootCertID, rootRoleCertID(t, userRepo))
 }
 
+// requireBaseRole asserts the number of keys and the threshold of a base role
+func requireBaseRole(t *testing.T, repo *repository, role data.RoleName, numKeys, threshold int) {
+	baseRole, err := repo.tufRepo.GetBaseRole(role)
+	require.NoError(t, err)
+	require.Len(t, baseRole.Keys, numKeys)
+	require.Equal(t, threshold, baseRole.Threshold)
+}
+
+// Initializing a repo with thresholds greater than 1 needs enough root keys,
+// and generates enough targets and snapshot keys to meet them
+func TestInitRepositoryWithThresholds(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	repo, _, rootPubKeyID := createRepoAndKey(t, data.ECDSAKey, tempBaseDir, "docker.com/notary", ts.URL)
+	repo.SetRoleThresholds(map[data.RoleName]int{
+		data.CanonicalRootRole:     2,
+		data.CanonicalTargetsRole:  2,
+		data.CanonicalSnapshotRole: 2,
+	})
+	err = repo.Initialize([]string{rootPubKeyID})
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+	require.Empty(t, repo.GetCryptoService().ListKeys(data.CanonicalTargetsRole))
+
+	secondRootKey, err := repo.GetCryptoService().Create(data.CanonicalRootRole, repo.gun, data.ECDSAKey)
+	require.NoError(t, err)
+	require.NoError(t, repo.Initialize([]string{rootPubKeyID, secondRootKey.ID()}))
+	requireBaseRole(t, repo, data.CanonicalRootRole, 2, 2)
+	requireBaseRole(t, repo, data.CanonicalTargetsRole, 2, 2)
+	requireBaseRole(t, repo, data.CanonicalSnapshotRole, 2, 2)
+	requireBaseRole(t, repo, data.CanonicalTimestampRole, 1, 1)
+
+	addTarget(t, repo, "current", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+
+	// clients verify every role against its threshold
+	userRepo, _, userBaseDir := newRepoToTestRepo(t, repo, "")
+	defer os.RemoveAll(userBaseDir)
+	_, err = userRepo.GetTargetByName("current")
+	require.NoError(t, err)
+	for _, role := range []data.RoleName{data.CanonicalTargetsRole, data.CanonicalSnapshotRole} {
+		require.Len(t, userRepo.tufRepo.Root.Signed.Roles[role].KeyIDs, 2)
+		require.Equal(t, 2, userRepo.tufRepo.Root.Signed.Roles[role].Threshold)
+	}
+	require.Len(t, userRepo.tufRepo.Targets[data.CanonicalTargetsRole].Signatures, 2)
+	require.Len(t, userRepo.tufRepo.Snapshot.Signatures, 2)
+}
+
+// Server managed roles only have one key, so their threshold can't be raised
+func TestInitRepositoryWithInvalidThresholds(t *testing.T) {
+	for _, testCase := range []struct {
+		thresholds         map[data.RoleName]int
+		serverManagedRoles []data.RoleName
+	}{
+		{thresholds: map[data.RoleName]int{data.CanonicalTimestampRole: 2}},
+		{thresholds: map[data.RoleName]int{data.CanonicalSnapshotRole: 2}, serverManagedRoles: []data.RoleName{data.CanonicalSnapshotRole}},
+		{thresholds: map[data.RoleName]int{data.CanonicalTargetsRole: -1}},
+	} {
+		tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+		require.NoError(t, err)
+		defer os.RemoveAll(tempBaseDir)
+
+		repo, _, rootPubKeyID := createRepoAndKey(t, data.ECDSAKey, tempBaseDir, "docker.com/notary", "http://localhost")
+		repo.SetRoleThresholds(testCase.thresholds)
+		err = repo.Initialize([]string{rootPubKeyID}, testCase.serverManagedRoles...)
+		require.Error(t, err)
+		require.IsType(t, data.ErrInvalidRole{}, err)
+	}
+}
+
+// Rotating keys can change a role's threshold, and generates enough keys to
+// meet it, but a threshold which cannot be met is never published
+func TestRotateKeyWithThreshold(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	authorRepo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	require.NoError(t, authorRepo.Publish())
+
+	userRepo, _, userBaseDir := newRepoToTestRepo(t, authorRepo, "")
+	defer os.RemoveAll(userBaseDir)
+	require.NoError(t, userRepo.updateTUF(false))
+
+	authorRepo.SetRoleThresholds(map[data.RoleName]int{data.CanonicalTargetsRole: 2, data.CanonicalRootRole: 2})
+	require.NoError(t, authorRepo.RotateKey(data.CanonicalTargetsRole, false, nil))
+	require.NoError(t, authorRepo.RotateKey(data.CanonicalRootRole, false, nil))
+	require.NoError(t, authorRepo.updateTUF(false))
+	requireBaseRole(t, authorRepo, data.CanonicalTargetsRole, 2, 2)
+	requireBaseRole(t, authorRepo, data.CanonicalRootRole, 2, 2)
+
+	addTarget(t, authorRepo, "current", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, authorRepo.Publish())
+	_, err := userRepo.GetTargetByName("current")
+	require.NoError(t, err)
+	requireBaseRole(t, userRepo, data.CanonicalRootRole, 2, 2)
+
+	// rotating to fewer keys than the threshold is rejected straight away
+	newKey, err := authorRepo.GetCryptoService().Create(data.CanonicalTargetsRole, authorRepo.gun, data.ECDSAKey)
+	require.NoError(t, err)
+	err = authorRepo.RotateKey(data.CanonicalTargetsRole, false, []string{newKey.ID()})
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+
+	// and so is publishing a change which would, such as an imported one, or
+	// a rotation which keeps the current threshold
+	kl, err := json.Marshal(&changelist.TUFRootData{
+		RoleName: data.CanonicalTargetsRole, Keys: data.KeyList{newKey}, Threshold: 3})
+	require.NoError(t, err)
+	require.NoError(t, authorRepo.changelist.Add(changelist.NewTUFChange(
+		changelist.ActionCreate, changelist.ScopeRoot, changelist.TypeBaseRole, data.CanonicalTargetsRole.String(), kl)))
+	err = authorRepo.Publish()
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+	require.NoError(t, authorRepo.changelist.Clear(""))
+
+	authorRepo.SetRoleThresholds(nil)
+	err = authorRepo.RotateKey(data.CanonicalTargetsRole, false, []string{newKey.ID()})
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+
+	require.NoError(t, authorRepo.updateTUF(false))
+	requireBaseRole(t, authorRepo, data.CanonicalTargetsRole, 2, 2)
+
+	// lowering the threshold again works
+	authorRepo.SetRoleThresholds(map[data.RoleName]int{data.CanonicalTargetsRole: 1})
+	require.NoError(t, authorRepo.RotateKey(data.CanonicalTargetsRole, false, []string{newKey.ID()}))
+	require.NoError(t, userRepo.updateTUF(false))
+	requireBaseRole(t, userRepo, data.CanonicalTargetsRole, 1, 1)
+
+	// server managed keys can't have a higher threshold
+	authorRepo.SetRoleThresholds(map[data.RoleName]int{data.CanonicalSnapshotRole: 2})
+	err = authorRepo.RotateKey(data.CanonicalSnapshotRole, true, nil)
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+}
+
+func TestRotateKeyFromThresholdToServerManaged(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	authorRepo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	authorRepo.SetRoleThresholds(map[data.RoleName]int{data.CanonicalSnapshotRole: 2})
+	require.NoError(t, authorRepo.RotateKey(data.CanonicalSnapshotRole, false, nil))
+	require.NoError(t, authorRepo.updateTUF(false))
+	requireBaseRole(t, authorRepo, data.CanonicalSnapshotRole, 2, 2)
+
+	// handing the snapshot key to the server drops the threshold to 1
+	authorRepo.SetRoleThresholds(nil)
+	require.NoError(t, authorRepo.RotateKey(data.CanonicalSnapshotRole, true, nil))
+
+	userRepo, _, userBaseDir := newRepoToTestRepo(t, authorRepo, "")
+	defer os.RemoveAll(userBaseDir)
+	require.NoError(t, userRepo.updateTUF(false))
+	requireBaseRole(t, userRepo, data.CanonicalSnapshotRole, 1, 1)
+
+	// and the server can sign snapshots on its own
+	addTarget(t, authorRepo, "current", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, authorRepo.Publish())
+	_, err := userRepo.GetTargetByName("current")
+	require.NoError(t, err)
+}
+
 // If there is no local cache, notary operations return the remote error code
 func TestRemoteServerUnavailableNoLocalCache(t *testing.T) {
 	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
@@ -3929,6 +4271,42 @@ func TestListRoles(t *testing.T) {
 	require.Len(t, rolesWithSigs, len(data.BaseRoles)+2)
 }
 
+// Witnessing root re-signs it on the next publish even if it is not near
+// expiry, and ListRoles reports when each role expires
+func TestWitnessRoot(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	require.NoError(t, repo.Publish())
+
+	rootExpires := func() time.Time {
+		rolesWithSigs, err := repo.ListRoles()
+		require.NoError(t, err)
+		for _, role := range rolesWithSigs {
+			require.False(t, role.Expires.IsZero(), "%s has no expiry", role.Name)
+			if role.Name == data.CanonicalRootRole {
+				return role.Expires
+			}
+		}
+		t.Fatal("no root role listed")
+		return time.Time{}
+	}
+	require.WithinDuration(t, data.DefaultExpires(data.CanonicalRootRole), rootExpires(), time.Minute)
+
+	repo.SetExpiryPolicy(data.NewExpiryPolicy(data.ExpiryRule{Role: data.CanonicalRootRole, Expiry: time.Hour}))
+	// root is not near expiry, so publishing does not re-sign it
+	require.NoError(t, repo.Publish())
+	require.WithinDuration(t, data.DefaultExpires(data.CanonicalRootRole), rootExpires(), time.Minute)
+
+	witnessed, err := repo.Witness(data.CanonicalRootRole)
+	require.NoError(t, err)
+	require.Equal(t, []data.RoleName{data.CanonicalRootRole}, witnessed)
+	require.NoError(t, repo.Publish())
+	require.WithinDuration(t, time.Now().Add(time.Hour), rootExpires(), time.Minute)
+}
+
 func TestGetAllTargetInfo(t *testing.T) {
 	ts, mux, keys := simpleTestServer(t)
 	defer ts.Close()
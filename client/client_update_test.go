@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 
 	"reflect"
 	"strconv"
@@ -1997,3 +1998,45 @@ func TestLoadTUFRepoBadURL(t *testing.T) {
 	// value
 	require.EqualError(t, err1, err2.Error())
 }
+
+// memoryRemoteStore is a remote store which only serves the metadata in memory
+type memoryRemoteStore struct {
+	*store.MemoryStore
+	store.PublicKeyStore
+}
+
+// TestStapledTrustPinning checks that the OCSP responses stapled to the root are only
+// looked up when pinning to a CA, and only next to the cached root
+func TestStapledTrustPinning(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+	cache, err := store.NewFileStore(tempBaseDir, "json")
+	require.NoError(t, err)
+
+	responses, err := json.Marshal([][]byte{[]byte("stapled")})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempBaseDir, "root.ocsp.json"), responses, 0644))
+
+	// responses on the remote are never used, since nothing can publish them
+	remote := store.NewMemoryStore(nil)
+	remoteResponses, err := json.Marshal([][]byte{[]byte("remote")})
+	require.NoError(t, err)
+	require.NoError(t, remote.Set(trustpinning.StapledOCSPName, remoteResponses))
+
+	options := TUFLoadOptions{
+		GUN:         "docker.com/notary",
+		Cache:       cache,
+		RemoteStore: memoryRemoteStore{MemoryStore: remote, PublicKeyStore: store.OfflineStore{}},
+	}
+	require.Empty(t, options.stapledTrustPinning().StapledOCSP)
+
+	options.TrustPinning.CA = map[string]string{"docker.com/": "root-ca.crt"}
+	require.Equal(t, [][]byte{[]byte("stapled")}, options.stapledTrustPinning().StapledOCSP)
+
+	require.NoError(t, cache.Set(trustpinning.StapledOCSPName, []byte("not a list of responses")))
+	require.Empty(t, options.stapledTrustPinning().StapledOCSP)
+
+	require.NoError(t, cache.Remove(trustpinning.StapledOCSPName))
+	require.Empty(t, options.stapledTrustPinning().StapledOCSP)
+}
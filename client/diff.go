@@ -0,0 +1,302 @@
+package client
+
+import (
+	"sort"
+
+	"github.com/theupdateframework/notary/tuf"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+// DiffType is how a target or role differs between two states of a repository
+type DiffType string
+
+// The ways in which a target or role can differ
+const (
+	DiffAdded   DiffType = "added"
+	DiffRemoved DiffType = "removed"
+	DiffChanged DiffType = "changed"
+)
+
+// RepoDiff describes how a repository changed from one state to another
+type RepoDiff struct {
+	// FromRootVersion and ToRootVersion differ if the root was rotated or
+	// re-signed.  A root version of 0 means there was no root.
+	FromRootVersion int          `json:"from_root_version"`
+	ToRootVersion   int          `json:"to_root_version"`
+	Targets         []TargetDiff `json:"targets"`
+	Roles           []RoleDiff   `json:"roles"`
+}
+
+// TargetDiff is a target which was added to, removed from, or changed in a
+// role.  From is not set for added targets, and To not for removed ones.
+type TargetDiff struct {
+	Role data.RoleName  `json:"role"`
+	Name string         `json:"name"`
+	Type DiffType       `json:"type"`
+	From *data.FileMeta `json:"from,omitempty"`
+	To   *data.FileMeta `json:"to,omitempty"`
+}
+
+// RoleDiff is a base role or delegation whose keys, threshold or paths
+// changed, or a delegation which was added or removed.  Key IDs are canonical.
+type RoleDiff struct {
+	Role          data.RoleName `json:"role"`
+	Type          DiffType      `json:"type"`
+	KeysAdded     []string      `json:"keys_added,omitempty"`
+	KeysRemoved   []string      `json:"keys_removed,omitempty"`
+	FromThreshold int           `json:"from_threshold,omitempty"`
+	ToThreshold   int           `json:"to_threshold,omitempty"`
+	PathsAdded    []string      `json:"paths_added,omitempty"`
+	PathsRemoved  []string      `json:"paths_removed,omitempty"`
+}
+
+// Empty returns whether the two states of the repository were the same
+func (d *RepoDiff) Empty() bool {
+	return d.FromRootVersion == d.ToRootVersion && len(d.Targets) == 0 && len(d.Roles) == 0
+}
+
+// DiffRepos compares two states of a repository, such as two versions, or the
+// published repository and its unpublished changes.  A nil repo is treated as
+// one which has not been initialized.
+func DiffRepos(from, to *tuf.Repo) (*RepoDiff, error) {
+	diff := &RepoDiff{
+		FromRootVersion: rootVersion(from),
+		ToRootVersion:   rootVersion(to),
+		Targets:         diffTargets(from, to),
+		Roles:           []RoleDiff{},
+	}
+
+	fromRoles, err := rolesOf(from)
+	if err != nil {
+		return nil, err
+	}
+	toRoles, err := rolesOf(to)
+	if err != nil {
+		return nil, err
+	}
+	for name, toRole := range toRoles {
+		fromRole, ok := fromRoles[name]
+		if !ok {
+			diff.Roles = append(diff.Roles, RoleDiff{
+				Role:        name,
+				Type:        DiffAdded,
+				KeysAdded:   toRole.keyIDs,
+				ToThreshold: toRole.threshold,
+				PathsAdded:  toRole.paths,
+			})
+			continue
+		}
+		roleDiff := RoleDiff{
+			Role:         name,
+			Type:         DiffChanged,
+			KeysAdded:    subtract(toRole.keyIDs, fromRole.keyIDs),
+			KeysRemoved:  subtract(fromRole.keyIDs, toRole.keyIDs),
+			PathsAdded:   subtract(toRole.paths, fromRole.paths),
+			PathsRemoved: subtract(fromRole.paths, toRole.paths),
+		}
+		if fromRole.threshold != toRole.threshold {
+			roleDiff.FromThreshold = fromRole.threshold
+			roleDiff.ToThreshold = toRole.threshold
+		}
+		if len(roleDiff.KeysAdded) > 0 || len(roleDiff.KeysRemoved) > 0 || roleDiff.FromThreshold != 0 ||
+			len(roleDiff.PathsAdded) > 0 || len(roleDiff.PathsRemoved) > 0 {
+			diff.Roles = append(diff.Roles, roleDiff)
+		}
+	}
+	for name, fromRole := range fromRoles {
+		if _, ok := toRoles[name]; !ok {
+			diff.Roles = append(diff.Roles, RoleDiff{
+				Role:          name,
+				Type:          DiffRemoved,
+				KeysRemoved:   fromRole.keyIDs,
+				FromThreshold: fromRole.threshold,
+				PathsRemoved:  fromRole.paths,
+			})
+		}
+	}
+	sort.Slice(diff.Roles, func(i, j int) bool {
+		return diff.Roles[i].Role < diff.Roles[j].Role
+	})
+	return diff, nil
+}
+
+// DiffSnapshotVersions compares the repository as of two versions of its
+// snapshot, verifying each as NewReadOnlyAtSnapshotVersion does
+func (r *repository) DiffSnapshotVersions(from, to int) (*RepoDiff, error) {
+	options := TUFLoadOptions{
+		GUN:           r.gun,
+		TrustPinning:  r.trustPinning,
+		CryptoService: r.cryptoService,
+		Cache:         r.cache,
+		RemoteStore:   r.remoteStore,
+	}
+	fromRepo, err := LoadTUFRepoAtSnapshotVersion(options, from)
+	if err != nil {
+		return nil, err
+	}
+	toRepo, err := LoadTUFRepoAtSnapshotVersion(options, to)
+	if err != nil {
+		return nil, err
+	}
+	return DiffRepos(fromRepo, toRepo)
+}
+
+// DiffUnpublished compares the published repository with how it will be once
+// the changelist is published.  If the repository has not been published,
+// everything in it is new.
+func (r *repository) DiffUnpublished() (*RepoDiff, error) {
+	cl, err := r.GetChangelist()
+	if err != nil {
+		return nil, err
+	}
+	var published *tuf.Repo
+	switch err := r.updateTUF(false); err.(type) {
+	case nil:
+		published = r.tufRepo
+		// load the repo again to apply the changelist to, since applying it
+		// changes the repo in place
+		if err := r.updateTUF(false); err != nil {
+			return nil, err
+		}
+	case ErrRepositoryNotExist:
+		if err := r.bootstrapRepo(); err != nil {
+			return nil, err
+		}
+		if r.tufRepo == nil {
+			return nil, ErrRepoNotInitialized{}
+		}
+	default:
+		return nil, err
+	}
+	if err := applyChangelist(r.tufRepo, r.invalid, cl); err != nil {
+		return nil, err
+	}
+	return DiffRepos(published, r.tufRepo)
+}
+
+func rootVersion(repo *tuf.Repo) int {
+	if repo == nil || repo.Root == nil {
+		return 0
+	}
+	return repo.Root.Signed.Version
+}
+
+func diffTargets(from, to *tuf.Repo) []TargetDiff {
+	targetDiffs := []TargetDiff{}
+	filesOf := func(repo *tuf.Repo, role data.RoleName) data.Files {
+		if repo == nil || repo.Targets[role] == nil {
+			return nil
+		}
+		return repo.Targets[role].Signed.Targets
+	}
+	roles := make(map[data.RoleName]bool)
+	for _, repo := range []*tuf.Repo{from, to} {
+		if repo != nil {
+			for role := range repo.Targets {
+				roles[role] = true
+			}
+		}
+	}
+	for role := range roles {
+		fromFiles, toFiles := filesOf(from, role), filesOf(to, role)
+		for name, toMeta := range toFiles {
+			toMeta := toMeta
+			fromMeta, ok := fromFiles[name]
+			switch {
+			case !ok:
+				targetDiffs = append(targetDiffs, TargetDiff{Role: role, Name: name, Type: DiffAdded, To: &toMeta})
+			case !fromMeta.Equals(toMeta):
+				targetDiffs = append(targetDiffs, TargetDiff{Role: role, Name: name, Type: DiffChanged, From: &fromMeta, To: &toMeta})
+			}
+		}
+		for name, fromMeta := range fromFiles {
+			fromMeta := fromMeta
+			if _, ok := toFiles[name]; !ok {
+				targetDiffs = append(targetDiffs, TargetDiff{Role: role, Name: name, Type: DiffRemoved, From: &fromMeta})
+			}
+		}
+	}
+	sort.Slice(targetDiffs, func(i, j int) bool {
+		if targetDiffs[i].Role != targetDiffs[j].Role {
+			return targetDiffs[i].Role < targetDiffs[j].Role
+		}
+		return targetDiffs[i].Name < targetDiffs[j].Name
+	})
+	return targetDiffs
+}
+
+// roleSummary is what is compared of a role: its canonical key IDs, threshold
+// and, for delegations, paths
+type roleSummary struct {
+	keyIDs    []string
+	threshold int
+	paths     []string
+}
+
+// rolesOf returns the base roles and delegations of a repo
+func rolesOf(repo *tuf.Repo) (map[data.RoleName]roleSummary, error) {
+	roles := make(map[data.RoleName]roleSummary)
+	if repo == nil || repo.Root == nil {
+		return roles, nil
+	}
+	for _, name := range data.BaseRoles {
+		role, err := repo.GetBaseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		keyIDs, err := canonicalKeyIDs(role.Keys)
+		if err != nil {
+			return nil, err
+		}
+		roles[name] = roleSummary{keyIDs: keyIDs, threshold: role.Threshold}
+	}
+	for _, signedTargets := range repo.Targets {
+		delegations := signedTargets.Signed.Delegations
+		for _, role := range delegations.Roles {
+			keys := make(data.Keys)
+			for _, keyID := range role.KeyIDs {
+				if key, ok := delegations.Keys[keyID]; ok {
+					keys[keyID] = key
+				}
+			}
+			keyIDs, err := canonicalKeyIDs(keys)
+			if err != nil {
+				return nil, err
+			}
+			paths := append([]string{}, role.Paths...)
+			sort.Strings(paths)
+			roles[role.Name] = roleSummary{keyIDs: keyIDs, threshold: role.Threshold, paths: paths}
+		}
+	}
+	return roles, nil
+}
+
+// canonicalKeyIDs returns the sorted canonical IDs of the keys
+func canonicalKeyIDs(keys data.Keys) ([]string, error) {
+	keyIDs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		keyID, err := utils.CanonicalKeyID(key)
+		if err != nil {
+			return nil, err
+		}
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	return keyIDs, nil
+}
+
+// subtract returns the strings in a which are not in b, in the order of a
+func subtract(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	var out []string
+	for _, s := range a {
+		if !inB[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
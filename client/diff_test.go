@@ -0,0 +1,102 @@
+package client
+
+import (
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+func requireTargetDiffs(t *testing.T, diff *RepoDiff, expected map[string]DiffType) {
+	actual := make(map[string]DiffType)
+	for _, targetDiff := range diff.Targets {
+		require.Equal(t, data.CanonicalTargetsRole, targetDiff.Role)
+		actual[targetDiff.Name] = targetDiff.Type
+		require.Equal(t, targetDiff.Type != DiffAdded, targetDiff.From != nil)
+		require.Equal(t, targetDiff.Type != DiffRemoved, targetDiff.To != nil)
+	}
+	require.Equal(t, expected, actual)
+}
+
+func TestDiffUnpublishedAndSnapshotVersions(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+
+	// everything in a repo which has not been published is new
+	first := addTarget(t, repo, "a", "../fixtures/intermediate-ca.crt")
+	diff, err := repo.DiffUnpublished()
+	require.NoError(t, err)
+	require.Equal(t, 0, diff.FromRootVersion)
+	require.Equal(t, 1, diff.ToRootVersion)
+	requireTargetDiffs(t, diff, map[string]DiffType{"a": DiffAdded})
+	require.Equal(t, first.Hashes, diff.Targets[0].To.Hashes)
+	require.Len(t, diff.Roles, len(data.BaseRoles))
+	for _, roleDiff := range diff.Roles {
+		require.Equal(t, DiffAdded, roleDiff.Type)
+		require.Len(t, roleDiff.KeysAdded, 1)
+		require.Equal(t, 1, roleDiff.ToThreshold)
+	}
+
+	require.NoError(t, repo.Publish())
+	diff, err = repo.DiffUnpublished()
+	require.NoError(t, err)
+	require.True(t, diff.Empty())
+
+	// change a, add b, then rotate the root key
+	second := addTarget(t, repo, "a", "../fixtures/root-ca.crt")
+	addTarget(t, repo, "b", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false, nil))
+
+	diff, err = repo.DiffSnapshotVersions(2, 4)
+	require.NoError(t, err)
+	require.Equal(t, 1, diff.FromRootVersion)
+	require.Equal(t, 2, diff.ToRootVersion)
+	requireTargetDiffs(t, diff, map[string]DiffType{"a": DiffChanged, "b": DiffAdded})
+	require.Equal(t, first.Hashes, diff.Targets[0].From.Hashes)
+	require.Equal(t, second.Hashes, diff.Targets[0].To.Hashes)
+	require.Len(t, diff.Roles, 1)
+	require.Equal(t, data.CanonicalRootRole, diff.Roles[0].Role)
+	require.Equal(t, DiffChanged, diff.Roles[0].Type)
+	require.Len(t, diff.Roles[0].KeysAdded, 1)
+	require.Len(t, diff.Roles[0].KeysRemoved, 1)
+	require.Zero(t, diff.Roles[0].FromThreshold)
+
+	diff, err = repo.DiffSnapshotVersions(4, 2)
+	require.NoError(t, err)
+	requireTargetDiffs(t, diff, map[string]DiffType{"a": DiffChanged, "b": DiffRemoved})
+
+	diff, err = repo.DiffSnapshotVersions(3, 3)
+	require.NoError(t, err)
+	require.True(t, diff.Empty())
+
+	_, err = repo.DiffSnapshotVersions(2, 5)
+	require.IsType(t, ErrNotPublished{}, err)
+
+	// staged delegations show up as new roles
+	delgPrivKey, err := utils.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+	delgPubKey := data.PublicKeyFromPrivate(delgPrivKey)
+	delgKeyID, err := utils.CanonicalKeyID(delgPubKey)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddDelegation("targets/releases", []data.PublicKey{delgPubKey}, []string{"release/"}))
+	require.NoError(t, repo.RemoveTarget("b"))
+
+	diff, err = repo.DiffUnpublished()
+	require.NoError(t, err)
+	require.Equal(t, diff.FromRootVersion, diff.ToRootVersion)
+	requireTargetDiffs(t, diff, map[string]DiffType{"b": DiffRemoved})
+	require.Equal(t, []RoleDiff{{
+		Role:        "targets/releases",
+		Type:        DiffAdded,
+		KeysAdded:   []string{delgKeyID},
+		ToThreshold: 1,
+		PathsAdded:  []string{"release/"},
+	}}, diff.Roles)
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"fmt"
 
+	"github.com/theupdateframework/notary/client/changelist"
 	"github.com/theupdateframework/notary/tuf/data"
 )
 
@@ -46,3 +47,29 @@ type ErrRepositoryNotExist struct {
 func (err ErrRepositoryNotExist) Error() string {
 	return fmt.Sprintf("%s does not have trust data for %s", err.remote, err.gun.String())
 }
+
+// ErrNotPublished is returned when asked for a repository as it was at a time,
+// or a version, at which it had not been published
+type ErrNotPublished struct {
+	gun  data.GUN
+	when string
+}
+
+func (err ErrNotPublished) Error() string {
+	return fmt.Sprintf("%s had not been published as of %s", err.gun.String(), err.when)
+}
+
+// ErrChangeNotApplicable is returned when a staged change can no longer be
+// applied to trust data which someone else has published to since it was
+// staged, for example because they removed the delegation it changes
+type ErrChangeNotApplicable struct {
+	Index  int
+	Change changelist.Change
+	Err    error
+}
+
+func (err ErrChangeNotApplicable) Error() string {
+	return fmt.Sprintf(
+		"change #%d (%s %s on %s) can no longer be applied to the latest trust data: %v",
+		err.Index, err.Change.Action(), err.Change.Path(), err.Change.Scope(), err.Err)
+}
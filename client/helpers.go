@@ -41,16 +41,7 @@ func applyChangelist(repo *tuf.Repo, invalid *tuf.Repo, cl changelist.Changelist
 		if err != nil {
 			return err
 		}
-		isDel := data.IsDelegation(c.Scope()) || data.IsWildDelegation(c.Scope())
-		switch {
-		case c.Scope() == changelist.ScopeTargets || isDel:
-			err = applyTargetsChange(repo, invalid, c)
-		case c.Scope() == changelist.ScopeRoot:
-			err = applyRootChange(repo, c)
-		default:
-			return fmt.Errorf("scope not supported: %s", c.Scope().String())
-		}
-		if err != nil {
+		if err := applyChange(repo, invalid, c); err != nil {
 			logrus.Debugf("error attempting to apply change #%d: %s, on scope: %s path: %s type: %s", index, c.Action(), c.Scope(), c.Path(), c.Type())
 			return err
 		}
@@ -60,10 +51,37 @@ func applyChangelist(repo *tuf.Repo, invalid *tuf.Repo, cl changelist.Changelist
 	return nil
 }
 
+// rebaseChangelist applies the changelist to trust data which others have
+// published to since the changes were staged, reporting which change, if any,
+// can no longer be applied
+func rebaseChangelist(repo *tuf.Repo, invalid *tuf.Repo, cl changelist.Changelist) error {
+	for index, c := range cl.List() {
+		if err := applyChange(repo, invalid, c); err != nil {
+			return ErrChangeNotApplicable{Index: index, Change: c, Err: err}
+		}
+	}
+	logrus.Debugf("rebased %d change(s)", len(cl.List()))
+	return nil
+}
+
+func applyChange(repo *tuf.Repo, invalid *tuf.Repo, c changelist.Change) error {
+	isDel := data.IsDelegation(c.Scope()) || data.IsWildDelegation(c.Scope())
+	switch {
+	case c.Scope() == changelist.ScopeTargets || isDel:
+		return applyTargetsChange(repo, invalid, c)
+	case c.Scope() == changelist.ScopeRoot:
+		return applyRootChange(repo, c)
+	default:
+		return fmt.Errorf("scope not supported: %s", c.Scope().String())
+	}
+}
+
 func applyTargetsChange(repo *tuf.Repo, invalid *tuf.Repo, c changelist.Change) error {
 	switch c.Type() {
 	case changelist.TypeTargetsTarget:
 		return changeTargetMeta(repo, c)
+	case changelist.TypeTargetsBatch:
+		return changeTargetsMetaBatch(repo, c)
 	case changelist.TypeTargetsDelegation:
 		return changeTargetsDelegation(repo, c)
 	case changelist.TypeWitness:
@@ -163,11 +181,48 @@ func changeTargetMeta(repo *tuf.Repo, c changelist.Change) error {
 	return err
 }
 
+// changeTargetsMetaBatch adds or removes all of the targets in a batch change
+func changeTargetsMetaBatch(repo *tuf.Repo, c changelist.Change) error {
+	var err error
+	switch c.Action() {
+	case changelist.ActionCreate:
+		files := data.Files{}
+		if err = json.Unmarshal(c.Content(), &files); err != nil {
+			return err
+		}
+		logrus.Debugf("changelist add: %d targets", len(files))
+
+		// Attempt to add the targets to this role
+		if _, err = repo.AddTargets(c.Scope(), files); err != nil {
+			logrus.Errorf("couldn't add targets to %s: %s", c.Scope(), err.Error())
+		}
+
+	case changelist.ActionDelete:
+		var names []string
+		if err = json.Unmarshal(c.Content(), &names); err != nil {
+			return err
+		}
+		logrus.Debugf("changelist remove: %d targets", len(names))
+
+		// Attempt to remove the targets from this role
+		if err = repo.RemoveTargets(c.Scope(), names...); err != nil {
+			logrus.Errorf("couldn't remove targets from %s: %s", c.Scope(), err.Error())
+		}
+
+	default:
+		err = fmt.Errorf("action not yet supported: %s", c.Action())
+	}
+	return err
+}
+
 func applyRootChange(repo *tuf.Repo, c changelist.Change) error {
 	var err error
 	switch c.Type() {
 	case changelist.TypeBaseRole:
 		err = applyRootRoleChange(repo, c)
+	case changelist.TypeWitness:
+		// mark root for re-signing
+		repo.Root.Dirty = true
 	default:
 		err = fmt.Errorf("type of root change not yet supported: %s", c.Type())
 	}
@@ -187,6 +242,11 @@ func applyRootRoleChange(repo *tuf.Repo, c changelist.Change) error {
 		if err != nil {
 			return err
 		}
+		if d.Threshold != 0 {
+			if err := repo.SetBaseThreshold(d.RoleName, d.Threshold); err != nil {
+				return err
+			}
+		}
 	default:
 		return fmt.Errorf("action not yet supported for root: %s", c.Action())
 	}
@@ -253,12 +313,11 @@ func serializeCanonicalRole(tufRepo *tuf.Repo, role data.RoleName, extraSigningK
 	var s *data.Signed
 	switch {
 	case role == data.CanonicalRootRole:
-		s, err = tufRepo.SignRoot(data.DefaultExpires(role), extraSigningKeys)
+		s, err = tufRepo.SignRoot(time.Time{}, extraSigningKeys)
 	case role == data.CanonicalSnapshotRole:
-		s, err = tufRepo.SignSnapshot(data.DefaultExpires(role))
+		s, err = tufRepo.SignSnapshot(time.Time{})
 	case tufRepo.Targets[role] != nil:
-		s, err = tufRepo.SignTargets(
-			role, data.DefaultExpires(data.CanonicalTargetsRole))
+		s, err = tufRepo.SignTargets(role, time.Time{})
 	default:
 		err = fmt.Errorf("%s not supported role to sign on the client", role)
 	}
@@ -146,6 +146,34 @@ func TestApplyChangelist(t *testing.T) {
 	require.False(t, ok)
 }
 
+// Rebasing reports which change can no longer be applied, for example because
+// the delegation it adds a target to has been removed
+func TestRebaseChangelistChangeNotApplicable(t *testing.T) {
+	repo, _, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+
+	hash := sha256.Sum256([]byte{})
+	fjson, err := json.Marshal(&data.FileMeta{Length: 1, Hashes: map[string][]byte{"sha256": hash[:]}})
+	require.NoError(t, err)
+
+	cl := changelist.NewMemChangelist()
+	require.NoError(t, cl.Add(changelist.NewTUFChange(
+		changelist.ActionCreate, changelist.ScopeTargets, changelist.TypeTargetsTarget, "latest", fjson)))
+	require.NoError(t, cl.Add(changelist.NewTUFChange(
+		changelist.ActionCreate, "targets/releases", changelist.TypeTargetsTarget, "v1", fjson)))
+
+	err = rebaseChangelist(repo, nil, cl)
+	require.IsType(t, ErrChangeNotApplicable{}, err)
+	notApplicable := err.(ErrChangeNotApplicable)
+	require.Equal(t, 1, notApplicable.Index)
+	require.Equal(t, "v1", notApplicable.Change.Path())
+	require.IsType(t, data.ErrInvalidRole{}, notApplicable.Err)
+	require.Contains(t, err.Error(), "change #1")
+
+	// applying the changelist reports the underlying error
+	require.IsType(t, data.ErrInvalidRole{}, applyChangelist(repo, nil, cl))
+}
+
 func TestApplyChangelistMulti(t *testing.T) {
 	repo, _, err := testutils.EmptyRepo("docker.com/notary")
 	require.NoError(t, err)
@@ -0,0 +1,350 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/theupdateframework/notary"
+	store "github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+// publishedTimeStore is a remote store which can say when a version of some
+// metadata was published, such as store.HTTPStore
+type publishedTimeStore interface {
+	GetLastModified(name string) (time.Time, error)
+}
+
+// NewReadOnlyAtTime returns a read-only view of a repository as it was at the
+// given time, for looking up what its targets were then.  The lifecycles of
+// targets are also checked at that time.  The cache in baseDir is only read,
+// for the root to verify the history with.
+func NewReadOnlyAtTime(baseDir string, gun data.GUN, baseURL string, rt http.RoundTripper,
+	trustPinning trustpinning.TrustPinConfig, at time.Time) (ReadOnly, error) {
+
+	options, err := historyLoadOptions(baseDir, gun, baseURL, rt, trustPinning)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := LoadTUFRepoAtTime(options, at)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{tufRepo: repo, at: at}, nil
+}
+
+// NewReadOnlyAtSnapshotVersion returns a read-only view of a repository as of
+// the given version of its snapshot.  The cache in baseDir is only read, for
+// the root to verify the history with.
+func NewReadOnlyAtSnapshotVersion(baseDir string, gun data.GUN, baseURL string, rt http.RoundTripper,
+	trustPinning trustpinning.TrustPinConfig, version int) (ReadOnly, error) {
+
+	options, err := historyLoadOptions(baseDir, gun, baseURL, rt, trustPinning)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := LoadTUFRepoAtSnapshotVersion(options, version)
+	if err != nil {
+		return nil, err
+	}
+	return NewReadOnly(repo), nil
+}
+
+func historyLoadOptions(baseDir string, gun data.GUN, baseURL string, rt http.RoundTripper,
+	trustPinning trustpinning.TrustPinConfig) (TUFLoadOptions, error) {
+
+	cache, err := store.NewFileStore(
+		filepath.Join(baseDir, tufDir, filepath.FromSlash(gun.String()), "metadata"),
+		"json",
+	)
+	if err != nil {
+		return TUFLoadOptions{}, err
+	}
+	remoteStore, err := getRemoteStore(baseURL, gun, rt)
+	if err != nil {
+		// baseURL is syntactically invalid
+		return TUFLoadOptions{}, err
+	}
+	return TUFLoadOptions{
+		GUN:          gun,
+		TrustPinning: trustPinning,
+		Cache:        cache,
+		RemoteStore:  remoteStore,
+	}, nil
+}
+
+// LoadTUFRepoAtTime loads a TUF repo as it was at the given time: from the
+// version of the timestamp which was current then, and the snapshot, root and
+// targets it refers to.  The remote store must be able to say when each
+// version of the timestamp was published.  The root is verified by chaining
+// it to the cached root, or to the first root and the trust pinning if it is
+// older than the cached root or none is cached.  Metadata which had expired
+// by the given time is loaded with a warning.
+func LoadTUFRepoAtTime(options TUFLoadOptions, at time.Time) (*tuf.Repo, error) {
+	options.setDefaults()
+	published, ok := options.RemoteStore.(publishedTimeStore)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot say when metadata was published", options.RemoteStore.Location())
+	}
+
+	current, err := options.RemoteStore.GetSized(data.CanonicalTimestampRole.String(), notary.MaxTimestampSize)
+	if err != nil {
+		if _, ok := err.(store.ErrMetaNotFound); ok {
+			return nil, ErrRepositoryNotExist{
+				remote: options.RemoteStore.Location(),
+				gun:    options.GUN,
+			}
+		}
+		return nil, err
+	}
+	latest, err := metaVersion(current)
+	if err != nil {
+		return nil, err
+	}
+	// find the oldest version of the timestamp published after the given time,
+	// so that the one before it is the one which was current then
+	var searchErr error
+	next := sort.Search(latest, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		publishedAt, err := published.GetLastModified(versionedName(i+1, data.CanonicalTimestampRole))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return publishedAt.After(at)
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+	if next == 0 {
+		return nil, ErrNotPublished{gun: options.GUN, when: at.Format(time.RFC3339)}
+	}
+
+	tsJSON, err := options.RemoteStore.GetSized(versionedName(next, data.CanonicalTimestampRole), notary.MaxTimestampSize)
+	if err != nil {
+		return nil, err
+	}
+	// the timestamp is only trusted once it is loaded, after the root, but
+	// it says which snapshot to download
+	ts := &data.SignedTimestamp{}
+	if err := json.Unmarshal(tsJSON, ts); err != nil {
+		return nil, err
+	}
+	snMeta, ok := ts.Signed.Meta[data.CanonicalSnapshotRole.String()]
+	if !ok {
+		return nil, data.ErrMissingMeta{Role: data.CanonicalSnapshotRole.String()}
+	}
+	snJSON, err := options.RemoteStore.GetSized(
+		utils.ConsistentName(data.CanonicalSnapshotRole.String(), snMeta.Hashes[notary.SHA256]), snMeta.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := loadHistory(options, tsJSON, snJSON)
+	if err != nil {
+		return nil, err
+	}
+	warnRolesExpiredAt(repo, at)
+	return repo, nil
+}
+
+// LoadTUFRepoAtSnapshotVersion loads a TUF repo as of the given version of
+// its snapshot, and the root and targets that snapshot refers to.  The root is
+// verified as by LoadTUFRepoAtTime.  There is no timestamp in the loaded repo.
+func LoadTUFRepoAtSnapshotVersion(options TUFLoadOptions, version int) (*tuf.Repo, error) {
+	options.setDefaults()
+	snJSON, err := options.RemoteStore.GetSized(versionedName(version, data.CanonicalSnapshotRole), notary.MaxDownloadSize)
+	if err != nil {
+		if _, ok := err.(store.ErrMetaNotFound); ok {
+			return nil, ErrNotPublished{gun: options.GUN, when: fmt.Sprintf("snapshot version %d", version)}
+		}
+		return nil, err
+	}
+	repo, err := loadHistory(options, nil, snJSON)
+	if err != nil {
+		return nil, err
+	}
+	if repo.Snapshot.Signed.Version != version {
+		return nil, fmt.Errorf("%s served version %d of the snapshot of %s when asked for version %d",
+			options.RemoteStore.Location(), repo.Snapshot.Signed.Version, options.GUN, version)
+	}
+	return repo, nil
+}
+
+// loadHistory verifies and loads the root referred to by the given snapshot,
+// then the timestamp (if any) and snapshot, then every targets role they
+// refer to.  Expired metadata is allowed, since the repo is as it was.
+func loadHistory(options TUFLoadOptions, tsJSON, snJSON []byte) (*tuf.Repo, error) {
+	sn := &data.SignedSnapshot{}
+	if err := json.Unmarshal(snJSON, sn); err != nil {
+		return nil, err
+	}
+	rootMeta, ok := sn.Signed.Meta[data.CanonicalRootRole.String()]
+	if !ok {
+		return nil, data.ErrMissingMeta{Role: data.CanonicalRootRole.String()}
+	}
+	rootJSON, err := options.RemoteStore.GetSized(
+		utils.ConsistentName(data.CanonicalRootRole.String(), rootMeta.Hashes[notary.SHA256]), rootMeta.Length)
+	if err != nil {
+		return nil, err
+	}
+	rootVersion, err := metaVersion(rootJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyHistoricalRoot(options, rootJSON, rootVersion); err != nil {
+		return nil, err
+	}
+
+	// the root was verified above, so it is the trust anchor, and the
+	// snapshot checks that it is the root it refers to
+	builder := tuf.NewRepoBuilder(options.GUN, options.CryptoService, trustpinning.TrustPinConfig{})
+	if err := builder.Load(data.CanonicalRootRole, rootJSON, rootVersion, true); err != nil {
+		return nil, err
+	}
+	if tsJSON != nil {
+		if err := builder.Load(data.CanonicalTimestampRole, tsJSON, 1, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := builder.Load(data.CanonicalSnapshotRole, snJSON, 1, true); err != nil {
+		return nil, err
+	}
+	if err := loadHistoricalTargets(builder, options.RemoteStore); err != nil {
+		return nil, err
+	}
+	repo, _, err := builder.Finish()
+	return repo, err
+}
+
+// verifyHistoricalRoot checks that the given version of the root was trusted,
+// by loading every version of the root in turn from the cached root, if it
+// is no newer, or else from the first root (checked against the trust
+// pinning) through to the cached root.  Each version must be signed by the
+// one before it, as when updating the root.
+func verifyHistoricalRoot(options TUFLoadOptions, rootJSON []byte, version int) error {
+	known := map[int][]byte{version: rootJSON}
+	from, to := 1, version
+	trustPinning := options.TrustPinning
+
+	if cached, err := options.Cache.GetSized(data.CanonicalRootRole.String(), store.NoSizeLimit); err == nil {
+		cachedVersion, err := metaVersion(cached)
+		if err != nil {
+			return err
+		}
+		if cachedVersion == version && !bytes.Equal(cached, rootJSON) {
+			return &trustpinning.ErrValidationFail{
+				Reason: fmt.Sprintf("version %d of the root does not match the cached root", version)}
+		}
+		known[cachedVersion] = cached
+		if cachedVersion <= version {
+			// the cached root is how we pin trust
+			from = cachedVersion
+			trustPinning = trustpinning.TrustPinConfig{}
+		} else {
+			to = cachedVersion
+		}
+	}
+
+	builder := tuf.NewRepoBuilder(options.GUN, options.CryptoService, trustPinning)
+	for v := from; v <= to; v++ {
+		raw, ok := known[v]
+		if !ok {
+			var err error
+			raw, err = options.RemoteStore.GetSized(versionedName(v, data.CanonicalRootRole), store.NoSizeLimit)
+			if err != nil {
+				logrus.Debugf("error downloading %s: %s", versionedName(v, data.CanonicalRootRole), err)
+				return err
+			}
+		}
+		if err := builder.LoadRootForUpdate(raw, v, false); err != nil {
+			logrus.Debugf("%s is invalid: %s", versionedName(v, data.CanonicalRootRole), err)
+			return err
+		}
+		if v == from {
+			// once the first root is trusted, later versions need only be
+			// signed by the version before them
+			builder = builder.BootstrapNewBuilderWithNewTrustpin(trustpinning.TrustPinConfig{})
+		}
+	}
+	return nil
+}
+
+// loadHistoricalTargets loads the targets and delegations which the loaded
+// snapshot refers to, in the same order as tufClient.downloadTargets
+func loadHistoricalTargets(builder tuf.RepoBuilder, remote store.RemoteStore) error {
+	toDownload := []data.DelegationRole{{
+		BaseRole: data.BaseRole{Name: data.CanonicalTargetsRole},
+		Paths:    []string{""},
+	}}
+
+	for len(toDownload) > 0 {
+		role := toDownload[0]
+		toDownload = toDownload[1:]
+
+		consistentInfo := builder.GetConsistentInfo(role.Name)
+		if !consistentInfo.ChecksumKnown() {
+			logrus.Debugf("skipping %s because there is no checksum for it", role.Name)
+			continue
+		}
+		raw, err := remote.GetSized(consistentInfo.ConsistentName(), consistentInfo.Length())
+		if err == nil {
+			err = builder.Load(role.Name, raw, 1, true)
+		}
+		switch err.(type) {
+		case signed.ErrRoleThreshold:
+			if role.Name == data.CanonicalTargetsRole {
+				return err
+			}
+			logrus.Warnf("Error getting %s: %s", role.Name, err)
+		case nil:
+			tgs := &data.SignedTargets{}
+			json.Unmarshal(raw, tgs)
+			toDownload = append(tgs.GetValidDelegations(role), toDownload...)
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// warnRolesExpiredAt warns about the metadata in the repo which had already
+// expired at the given time
+func warnRolesExpiredAt(r *tuf.Repo, at time.Time) {
+	expired := func(role data.RoleName, common data.SignedCommon) {
+		if common.Expires.Before(at) {
+			logrus.Warnf("%s metadata had expired by %s", role, at.Format(time.RFC3339))
+		}
+	}
+	expired(data.CanonicalRootRole, r.Root.Signed.SignedCommon)
+	expired(data.CanonicalTimestampRole, r.Timestamp.Signed.SignedCommon)
+	expired(data.CanonicalSnapshotRole, r.Snapshot.Signed.SignedCommon)
+	for role, signedTOrD := range r.Targets {
+		expired(role, signedTOrD.Signed.SignedCommon)
+	}
+}
+
+// metaVersion returns the version of some metadata, without verifying it
+func metaVersion(raw []byte) (int, error) {
+	meta := &data.SignedMeta{}
+	if err := json.Unmarshal(raw, meta); err != nil {
+		return 0, err
+	}
+	return meta.Signed.Version, nil
+}
+
+func versionedName(version int, role data.RoleName) string {
+	return fmt.Sprintf("%d.%s", version, role)
+}
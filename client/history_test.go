@@ -0,0 +1,164 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	store "github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// publishedAtStore says that each version of the timestamp was published at
+// the time in its map, since the test server publishes them all within a
+// second
+type publishedAtStore struct {
+	store.RemoteStore
+	published map[string]time.Time
+}
+
+func (s publishedAtStore) GetLastModified(name string) (time.Time, error) {
+	at, ok := s.published[name]
+	if !ok {
+		return time.Time{}, store.ErrMetaNotFound{Resource: name}
+	}
+	return at, nil
+}
+
+// publishHistory publishes two versions of the "current" target, rotating
+// the root key in between, so that the server has versions 1 to 3 of the
+// timestamp, 2 to 4 of the snapshot, and 1 to 2 of the root.  It returns the author's repo and
+// base directory, the hashes of each version of the target, and a base
+// directory whose cache has the first version of the root.
+func publishHistory(t *testing.T, url string) (*repository, string, []data.Hashes, string) {
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", url, false)
+	first := addTarget(t, repo, "current", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+
+	oldRootRepo, _, oldRootDir := newRepoToTestRepo(t, repo, "")
+	require.NoError(t, oldRootRepo.updateTUF(false))
+
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false, nil))
+	second := addTarget(t, repo, "current", "../fixtures/root-ca.crt")
+	require.NoError(t, repo.Publish())
+	require.NotEqual(t, first.Hashes, second.Hashes)
+
+	return repo, baseDir, []data.Hashes{first.Hashes, second.Hashes}, oldRootDir
+}
+
+func requireCurrentTarget(t *testing.T, r ReadOnly, hashes data.Hashes) {
+	target, err := r.GetTargetByName("current")
+	require.NoError(t, err)
+	require.Equal(t, hashes, target.Hashes)
+}
+
+func TestReadOnlyAtSnapshotVersion(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, baseDir, hashes, oldRootDir := publishHistory(t, ts.URL)
+	defer os.RemoveAll(baseDir)
+	defer os.RemoveAll(oldRootDir)
+	emptyDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(emptyDir)
+
+	// the author has the newest root cached, the older directory the first
+	// root, and the empty directory no root at all
+	for _, dir := range []string{baseDir, oldRootDir, emptyDir} {
+		r, err := NewReadOnlyAtSnapshotVersion(dir, repo.gun, ts.URL, http.DefaultTransport,
+			trustpinning.TrustPinConfig{}, 2)
+		require.NoError(t, err)
+		requireCurrentTarget(t, r, hashes[0])
+
+		r, err = NewReadOnlyAtSnapshotVersion(dir, repo.gun, ts.URL, http.DefaultTransport,
+			trustpinning.TrustPinConfig{}, 4)
+		require.NoError(t, err)
+		requireCurrentTarget(t, r, hashes[1])
+		roles, err := r.ListRoles()
+		require.NoError(t, err)
+		require.Len(t, roles, 4)
+
+		for _, version := range []int{1, 5} {
+			_, err = NewReadOnlyAtSnapshotVersion(dir, repo.gun, ts.URL, http.DefaultTransport,
+				trustpinning.TrustPinConfig{}, version)
+			require.IsType(t, ErrNotPublished{}, err)
+		}
+	}
+
+	// the history must be verified by the cached root
+	otherRepo, _, otherDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(otherDir)
+	otherRoot, err := otherRepo.cache.GetSized(data.CanonicalRootRole.String(), store.NoSizeLimit)
+	require.NoError(t, err)
+	remote, err := getRemoteStore(ts.URL, repo.gun, http.DefaultTransport)
+	require.NoError(t, err)
+	cache := store.NewMemoryStore(map[data.RoleName][]byte{data.CanonicalRootRole: otherRoot})
+	_, err = LoadTUFRepoAtSnapshotVersion(TUFLoadOptions{GUN: repo.gun, Cache: cache, RemoteStore: remote}, 2)
+	require.IsType(t, &trustpinning.ErrValidationFail{}, err)
+	_, err = LoadTUFRepoAtSnapshotVersion(TUFLoadOptions{GUN: repo.gun, Cache: cache, RemoteStore: remote}, 4)
+	require.IsType(t, &trustpinning.ErrRootRotationFail{}, err)
+}
+
+func TestLoadTUFRepoAtTime(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, baseDir, hashes, oldRootDir := publishHistory(t, ts.URL)
+	defer os.RemoveAll(baseDir)
+	defer os.RemoveAll(oldRootDir)
+
+	remote, err := getRemoteStore(ts.URL, repo.gun, http.DefaultTransport)
+	require.NoError(t, err)
+	start := time.Now().Add(-24 * time.Hour)
+	options := TUFLoadOptions{
+		GUN:   repo.gun,
+		Cache: repo.cache,
+		RemoteStore: publishedAtStore{RemoteStore: remote, published: map[string]time.Time{
+			"1.timestamp": start,
+			"2.timestamp": start.Add(time.Hour),
+			"3.timestamp": start.Add(2 * time.Hour),
+		}},
+	}
+
+	// the version of the timestamp current at each time, and the version of
+	// the target it leads to
+	for at, expected := range map[time.Duration]struct{ timestamp, target int }{
+		0:                {1, 0},
+		time.Hour / 2:    {1, 0},
+		time.Hour:        {2, 0},
+		90 * time.Minute: {2, 0},
+		3 * time.Hour:    {3, 1},
+		48 * time.Hour:   {3, 1},
+	} {
+		tufRepo, err := LoadTUFRepoAtTime(options, start.Add(at))
+		require.NoError(t, err)
+		require.Equal(t, expected.timestamp, tufRepo.Timestamp.Signed.Version, at.String())
+		requireCurrentTarget(t, &reader{tufRepo: tufRepo, at: start.Add(at)}, hashes[expected.target])
+	}
+
+	_, err = LoadTUFRepoAtTime(options, start.Add(-time.Minute))
+	require.IsType(t, ErrNotPublished{}, err)
+
+	// the real server's Last-Modified times work too
+	r, err := NewReadOnlyAtTime(baseDir, repo.gun, ts.URL, http.DefaultTransport,
+		trustpinning.TrustPinConfig{}, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	requireCurrentTarget(t, r, hashes[1])
+	_, err = NewReadOnlyAtTime(baseDir, repo.gun, ts.URL, http.DefaultTransport,
+		trustpinning.TrustPinConfig{}, start)
+	require.IsType(t, ErrNotPublished{}, err)
+
+	// the remote store has to be able to say when metadata was published
+	_, err = LoadTUFRepoAtTime(TUFLoadOptions{GUN: repo.gun, RemoteStore: store.OfflineStore{}}, start)
+	require.Error(t, err)
+
+	remote, err = getRemoteStore(ts.URL, "docker.com/nope", http.DefaultTransport)
+	require.NoError(t, err)
+	_, err = LoadTUFRepoAtTime(TUFLoadOptions{GUN: "docker.com/nope", RemoteStore: remote}, start)
+	require.IsType(t, ErrRepositoryNotExist{}, err)
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"github.com/theupdateframework/notary/client/changelist"
+	store "github.com/theupdateframework/notary/storage"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
 )
@@ -26,8 +27,15 @@ type ReadOnly interface {
 	// the target entry found in the subtree of the highest priority role
 	// will be returned.
 	// See the IMPORTANT section on ListTargets above. Those roles also apply here.
+	// Targets which have been revoked or are outside their validity window (see
+	// data.TargetLifecycle) are rejected with an error.
 	GetTargetByName(name string, roles ...data.RoleName) (*TargetWithRole, error)
 
+	// GetTargetByNameIgnoringLifecycle behaves like GetTargetByName, but also
+	// returns targets which have been revoked or are outside their validity
+	// window.
+	GetTargetByNameIgnoringLifecycle(name string, roles ...data.RoleName) (*TargetWithRole, error)
+
 	// GetAllTargetMetadataByName searches the entire delegation role tree to find
 	// the specified target by name for all roles, and returns a list of
 	// TargetSignedStructs for each time it finds the specified target.
@@ -57,6 +65,24 @@ type Repository interface {
 	// SetLegacyVersion sets the number of versions back to fetch roots to sign with
 	SetLegacyVersions(int)
 
+	// SetExpiryPolicy sets the policy which decides when the metadata signed by
+	// the repository expires.  A nil policy uses the default expiry times.
+	SetExpiryPolicy(*data.ExpiryPolicy)
+
+	// SetPublishRetryPolicy sets how publishes which conflict with someone
+	// else's are retried.  A policy with no retries fails them immediately.
+	SetPublishRetryPolicy(PublishRetryPolicy)
+
+	// SetChangelist sets the changelist which changes are staged in and
+	// published from, such as one opened with NewNamedFileChangelist
+	SetChangelist(changelist.Changelist)
+
+	// SetRoleThresholds sets the thresholds of the root, targets and snapshot
+	// roles to use when initializing the repository and when rotating their
+	// keys.  Roles without a threshold get a threshold of 1 when initializing,
+	// and keep their current threshold when rotating.
+	SetRoleThresholds(map[data.RoleName]int)
+
 	// ----- General management operations -----
 
 	// Initialize creates a new repository by using rootKey as the root Key for the
@@ -86,6 +112,17 @@ type Repository interface {
 	// If roles are unspecified, the default role is "target".
 	RemoveTarget(targetName string, roles ...data.RoleName) error
 
+	// AddTargets creates one changelist entry per role to add all of the targets
+	// to it when the changelist gets applied at publish time.  Every target is
+	// validated before anything is staged.  If roles are unspecified, the default
+	// role is "targets"
+	AddTargets(targets []*Target, roles ...data.RoleName) error
+
+	// RemoveTargets creates one changelist entry per role to remove all of the
+	// targets from it when the changelist gets applied at publish time.
+	// If roles are unspecified, the default role is "targets"
+	RemoveTargets(targetNames []string, roles ...data.RoleName) error
+
 	// ----- Changelist operations -----
 
 	// GetChangelist returns the list of the repository's unpublished changes
@@ -135,15 +172,59 @@ type Repository interface {
 	// roles on the next publish. One change is created per role
 	Witness(roles ...data.RoleName) ([]data.RoleName, error)
 
+	// ----- Threshold signing operations -----
+
+	// ExportSignRequest applies pending changes to the given root, targets or
+	// delegated targets role and returns the next version of it, signed with
+	// whichever of the required keys are available locally, so that it can be
+	// passed to other key holders to meet the role's threshold.
+	ExportSignRequest(role data.RoleName) (*SignRequest, error)
+
+	// PublishSignRequest publishes a SignRequest once its signatures meet the
+	// thresholds of the currently trusted signing roles.
+	PublishSignRequest(req *SignRequest) error
+
+	// ----- Comparison operations -----
+
+	// DiffSnapshotVersions compares the repository as of two versions of its
+	// snapshot: the targets, delegations, keys and thresholds of each role,
+	// and the version of the root.
+	DiffSnapshotVersions(from, to int) (*RepoDiff, error)
+
+	// DiffUnpublished compares the published repository with how it will be
+	// once its unpublished changes are published.
+	DiffUnpublished() (*RepoDiff, error)
+
+	// ----- Offline operations -----
+
+	// ExportBundle updates the repository and returns all of its metadata,
+	// including every available version of root, as a bundle that can be
+	// imported with ImportBundle or served by a storage.BundleStore where
+	// there is no access to a notary server.
+	ExportBundle() (*store.Bundle, error)
+
 	// ----- Key Operations -----
 
 	// RotateKey removes all existing keys associated with the role. If no keys are
-	// specified in keyList, then this creates and adds one new key or delegates
-	// managing the key to the server. If key(s) are specified by keyList, then they are
-	// used for signing the role.
+	// specified in keyList, then this creates and adds enough new keys to meet the
+	// role's threshold, or delegates managing the key to the server. If key(s) are
+	// specified by keyList, then they are used for signing the role.  The role's
+	// threshold is changed to the one set with SetRoleThresholds, if there is one.
 	// These changes are staged in a changelist until publish is called.
 	RotateKey(role data.RoleName, serverManagesKey bool, keyList []string) error
 
+	// PlanRootRotation returns what RotateRoot would do with the same keys:
+	// the current and new root keys, the legacy root versions which co-sign
+	// the new root, and which of their keys are missing locally.
+	PlanRootRotation(keyList []string) (*RootRotationPlan, error)
+
+	// RotateRoot rotates the root keys to the keys in keyList, or to a newly
+	// generated key if it is empty, and publishes the new root immediately.
+	// The new root is first validated as clients trusting the current and
+	// legacy root versions would.  If dryRun is set, it is only validated.
+	// A dry run or a rejected rotation leaves the repository unchanged.
+	RotateRoot(keyList []string, dryRun bool) ([]RootValidation, error)
+
 	// GetCryptoService is the getter for the repository's CryptoService, which is used
 	// to sign all updates.
 	GetCryptoService() signed.CryptoService
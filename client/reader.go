@@ -2,8 +2,10 @@ package client
 
 import (
 	"fmt"
+	"time"
 
 	canonicaljson "github.com/docker/go/canonical/json"
+	"github.com/sirupsen/logrus"
 	store "github.com/theupdateframework/notary/storage"
 	"github.com/theupdateframework/notary/tuf"
 	"github.com/theupdateframework/notary/tuf/data"
@@ -19,6 +21,12 @@ type Target struct {
 	Custom *canonicaljson.RawMessage // the custom data provided to describe the file at TARGETPATH
 }
 
+// Lifecycle returns the validity window and revocation status stored in the
+// target's custom data, or nil if it has none
+func (t Target) Lifecycle() (*data.TargetLifecycle, error) {
+	return data.ParseTargetLifecycle(t.Custom)
+}
+
 // TargetWithRole represents a Target that exists in a particular role - this is
 // produced by ListTargets and GetTargetByName
 type TargetWithRole struct {
@@ -40,9 +48,11 @@ func (f ErrNoSuchTarget) Error() string {
 	return fmt.Sprintf("No valid trust data for %s", string(f))
 }
 
-// RoleWithSignatures is a Role with its associated signatures
+// RoleWithSignatures is a Role with its associated signatures, and the time
+// at which its metadata expires
 type RoleWithSignatures struct {
 	Signatures []data.Signature
+	Expires    time.Time
 	data.Role
 }
 
@@ -55,6 +65,8 @@ func NewReadOnly(repo *tuf.Repo) ReadOnly {
 
 type reader struct {
 	tufRepo *tuf.Repo
+	// at is when the lifecycles of targets are checked, or now if zero
+	at time.Time
 }
 
 // ListTargets lists all targets for the current repository. The list of
@@ -114,7 +126,34 @@ func (r *reader) ListTargets(roles ...data.RoleName) ([]*TargetWithRole, error)
 // the target entry found in the subtree of the highest priority role
 // will be returned.
 // See the IMPORTANT section on ListTargets above. Those roles also apply here.
+// Targets which have been revoked or are outside their validity window are
+// rejected with an error, rather than falling back to lower priority roles.
+// A lifecycle which cannot be parsed is ignored.
 func (r *reader) GetTargetByName(name string, roles ...data.RoleName) (*TargetWithRole, error) {
+	target, err := r.GetTargetByNameIgnoringLifecycle(name, roles...)
+	if err != nil {
+		return nil, err
+	}
+	lifecycle, err := target.Lifecycle()
+	if err != nil {
+		// a lifecycle which can't be parsed restricts nothing
+		logrus.Debugf("ignoring the lifecycle of target %s: %v", name, err)
+	}
+	if lifecycle != nil {
+		at := r.at
+		if at.IsZero() {
+			at = time.Now()
+		}
+		if err := lifecycle.Check(name, at); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// GetTargetByNameIgnoringLifecycle behaves like GetTargetByName, but also
+// returns targets which have been revoked or are outside their validity window.
+func (r *reader) GetTargetByNameIgnoringLifecycle(name string, roles ...data.RoleName) (*TargetWithRole, error) {
 	if len(roles) == 0 {
 		roles = append(roles, data.CanonicalTargetsRole)
 	}
@@ -206,12 +245,19 @@ func (r *reader) ListRoles() ([]RoleWithSignatures, error) {
 		switch role.Name {
 		case data.CanonicalRootRole:
 			roleWithSig.Signatures = r.tufRepo.Root.Signatures
+			roleWithSig.Expires = r.tufRepo.Root.Signed.Expires
 		case data.CanonicalTargetsRole:
 			roleWithSig.Signatures = r.tufRepo.Targets[data.CanonicalTargetsRole].Signatures
+			roleWithSig.Expires = r.tufRepo.Targets[data.CanonicalTargetsRole].Signed.Expires
 		case data.CanonicalSnapshotRole:
 			roleWithSig.Signatures = r.tufRepo.Snapshot.Signatures
+			roleWithSig.Expires = r.tufRepo.Snapshot.Signed.Expires
 		case data.CanonicalTimestampRole:
-			roleWithSig.Signatures = r.tufRepo.Timestamp.Signatures
+			// a repo loaded as of a snapshot version has no timestamp
+			if r.tufRepo.Timestamp != nil {
+				roleWithSig.Signatures = r.tufRepo.Timestamp.Signatures
+				roleWithSig.Expires = r.tufRepo.Timestamp.Signed.Expires
+			}
 		default:
 			if !data.IsDelegation(role.Name) {
 				continue
@@ -219,6 +265,7 @@ func (r *reader) ListRoles() ([]RoleWithSignatures, error) {
 			if _, ok := r.tufRepo.Targets[role.Name]; ok {
 				// We'll only find a signature if we've published any targets with this delegation
 				roleWithSig.Signatures = r.tufRepo.Targets[role.Name].Signatures
+				roleWithSig.Expires = r.tufRepo.Targets[role.Name].Signed.Expires
 			}
 		}
 		roleWithSigs = append(roleWithSigs, roleWithSig)
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	canonicaljson "github.com/docker/go/canonical/json"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/testutils"
+)
+
+func fileMetaWithLifecycle(t *testing.T, lifecycle data.TargetLifecycle) data.FileMeta {
+	hash := sha256.Sum256([]byte{})
+	custom, err := data.SetTargetLifecycle(nil, lifecycle)
+	require.NoError(t, err)
+	return data.FileMeta{Length: 1, Hashes: data.Hashes{"sha256": hash[:]}, Custom: custom}
+}
+
+// GetTargetByName rejects targets which are revoked or outside their validity
+// window, but GetTargetByNameIgnoringLifecycle still returns them
+func TestGetTargetByNameChecksLifecycle(t *testing.T) {
+	tufRepo, _, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	_, err = tufRepo.AddTargets(data.CanonicalTargetsRole, data.Files{
+		"current":  fileMetaWithLifecycle(t, data.TargetLifecycle{NotBefore: &past, NotAfter: &future}),
+		"expired":  fileMetaWithLifecycle(t, data.TargetLifecycle{NotAfter: &past}),
+		"upcoming": fileMetaWithLifecycle(t, data.TargetLifecycle{NotBefore: &future}),
+		"revoked":  fileMetaWithLifecycle(t, data.TargetLifecycle{Revoked: "compromised"}),
+		"plain":    fileMetaWithLifecycle(t, data.TargetLifecycle{}),
+	})
+	require.NoError(t, err)
+
+	reader := NewReadOnly(tufRepo)
+
+	for _, name := range []string{"current", "plain"} {
+		target, err := reader.GetTargetByName(name)
+		require.NoError(t, err)
+		require.Equal(t, name, target.Name)
+	}
+
+	_, err = reader.GetTargetByName("expired")
+	require.IsType(t, data.ErrTargetExpired{}, err)
+	_, err = reader.GetTargetByName("upcoming")
+	require.IsType(t, data.ErrTargetNotYetValid{}, err)
+	_, err = reader.GetTargetByName("revoked")
+	require.Equal(t, data.ErrTargetRevoked{Name: "revoked", Reason: "compromised"}, err)
+
+	for _, name := range []string{"expired", "upcoming", "revoked"} {
+		target, err := reader.GetTargetByNameIgnoringLifecycle(name)
+		require.NoError(t, err)
+		require.Equal(t, name, target.Name)
+		require.Equal(t, data.CanonicalTargetsRole, target.Role)
+	}
+
+	_, err = reader.GetTargetByNameIgnoringLifecycle("missing")
+	require.IsType(t, ErrNoSuchTarget(""), err)
+}
+
+// A malformed lifecycle is treated as no lifecycle, rather than making the
+// target impossible to look up
+func TestGetTargetByNameInvalidLifecycle(t *testing.T) {
+	tufRepo, _, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+
+	hash := sha256.Sum256([]byte{})
+	custom := canonicaljson.RawMessage(`{"notary.lifecycle": {"not_after": "tomorrow"}}`)
+	_, err = tufRepo.AddTargets(data.CanonicalTargetsRole, data.Files{
+		"latest": {Length: 1, Hashes: data.Hashes{"sha256": hash[:]}, Custom: &custom},
+	})
+	require.NoError(t, err)
+
+	target, err := NewReadOnly(tufRepo).GetTargetByName("latest")
+	require.NoError(t, err)
+	require.Equal(t, "latest", target.Name)
+
+	_, err = target.Lifecycle()
+	require.IsType(t, data.ErrInvalidTargetLifecycle{}, err)
+}
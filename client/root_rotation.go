@@ -0,0 +1,280 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+// RootKeySet is a version of the root role: its keys, how many of them have
+// to sign, and which of them cannot sign because their private keys are not
+// available locally.  Key IDs are canonical.
+type RootKeySet struct {
+	Version       int      `json:"version"`
+	KeyIDs        []string `json:"key_ids"`
+	Threshold     int      `json:"threshold"`
+	MissingKeyIDs []string `json:"missing_key_ids"`
+}
+
+// CanSign returns whether enough of the keys are available locally to meet
+// the threshold
+func (s RootKeySet) CanSign() bool {
+	return len(s.KeyIDs)-len(s.MissingKeyIDs) >= s.Threshold
+}
+
+// RootRotationPlan describes what rotating the root keys of a repository
+// would do.  The new root has to be signed by the current root keys, the new
+// root keys and, so that clients which only trust them can still update, the
+// keys of the legacy root versions.  If GenerateKey is set, enough new keys to
+// meet the threshold are generated for the new root, so New has no key IDs yet.
+type RootRotationPlan struct {
+	GUN         data.GUN     `json:"gun"`
+	Current     RootKeySet   `json:"current"`
+	New         RootKeySet   `json:"new"`
+	GenerateKey bool         `json:"generate_key"`
+	Legacy      []RootKeySet `json:"legacy"`
+}
+
+// RootValidation is the result of validating a new root as a client which
+// trusts a previous version of the root would
+type RootValidation struct {
+	Version int    `json:"version"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ErrRootRotationInvalid is returned when clients trusting one of the
+// previous versions of the root would not accept the new root
+type ErrRootRotationInvalid struct {
+	Versions []int
+}
+
+func (err ErrRootRotationInvalid) Error() string {
+	return fmt.Sprintf("the new root would not be accepted by clients trusting root versions %v", err.Versions)
+}
+
+// PlanRootRotation updates the repository and returns what RotateRoot would
+// do with the same keys, without changing anything.  The legacy root versions
+// are the ones set with SetLegacyVersions.
+func (r *repository) PlanRootRotation(keyList []string) (*RootRotationPlan, error) {
+	if err := r.updateTUF(true); err != nil {
+		return nil, err
+	}
+	currentRole, err := r.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	if err != nil {
+		return nil, err
+	}
+	currentVersion := r.tufRepo.Root.Signed.Version
+	newThreshold, err := r.roleThreshold(data.CanonicalRootRole, false)
+	if err != nil {
+		return nil, err
+	}
+	if newThreshold == 0 {
+		newThreshold = currentRole.Threshold
+	}
+
+	plan := &RootRotationPlan{GUN: r.gun, GenerateKey: len(keyList) == 0, Legacy: []RootKeySet{}}
+	if plan.Current, err = r.rootKeySet(currentVersion, currentRole.ListKeys(), currentRole.Threshold); err != nil {
+		return nil, err
+	}
+
+	newKeys := make(data.KeyList, 0, len(keyList))
+	for _, keyID := range keyList {
+		pubKey := r.GetCryptoService().GetKey(keyID)
+		if pubKey == nil {
+			return nil, fmt.Errorf("unable to find key: %s", keyID)
+		}
+		newKeys = append(newKeys, pubKey)
+	}
+	if plan.New, err = r.rootKeySet(currentVersion+1, newKeys, newThreshold); err != nil {
+		return nil, err
+	}
+
+	legacyRoots, err := r.legacyRoots(r.LegacyVersions)
+	if err != nil {
+		return nil, err
+	}
+	for _, legacyRoot := range legacyRoots {
+		if legacyRoot.Signed.Version >= currentVersion {
+			continue
+		}
+		legacyRole, err := legacyRoot.BuildBaseRole(data.CanonicalRootRole)
+		if err != nil {
+			return nil, err
+		}
+		keySet, err := r.rootKeySet(legacyRoot.Signed.Version, legacyRole.ListKeys(), legacyRole.Threshold)
+		if err != nil {
+			return nil, err
+		}
+		plan.Legacy = append(plan.Legacy, keySet)
+	}
+	return plan, nil
+}
+
+// rootKeySet describes a version of the root role, looking up which of its
+// keys are available locally
+func (r *repository) rootKeySet(version int, keys data.KeyList, threshold int) (RootKeySet, error) {
+	keySet := RootKeySet{Version: version, KeyIDs: []string{}, Threshold: threshold, MissingKeyIDs: []string{}}
+	for _, key := range keys {
+		keyID, err := utils.CanonicalKeyID(key)
+		if err != nil {
+			return RootKeySet{}, err
+		}
+		keySet.KeyIDs = append(keySet.KeyIDs, keyID)
+		if _, _, err := r.GetCryptoService().GetPrivateKey(keyID); err != nil {
+			keySet.MissingKeyIDs = append(keySet.MissingKeyIDs, keyID)
+		}
+	}
+	sort.Strings(keySet.KeyIDs)
+	sort.Strings(keySet.MissingKeyIDs)
+	return keySet, nil
+}
+
+// RotateRoot rotates the root keys to the keys in keyList, or to newly
+// generated keys if it is empty, and publishes the new root immediately.  It
+// is signed by the current root keys, the new root keys and the keys of the
+// legacy root versions set with SetLegacyVersions.  The root threshold is
+// changed to the one set with SetRoleThresholds, if there is one.
+//
+// Before publishing, the new root is validated with the trust pinning config
+// as clients trusting the current and each legacy root version would, and
+// ErrRootRotationInvalid is returned if any of them would reject it.  If
+// dryRun is set, the new root is only validated, and any generated key is
+// removed again.  The rotation is built on a copy of the repository's metadata,
+// so a dry run or a rejected rotation leaves the repository unchanged.
+func (r *repository) RotateRoot(keyList []string, dryRun bool) ([]RootValidation, error) {
+	if err := r.updateTUF(true); err != nil {
+		return nil, err
+	}
+	currentRoot, err := copySignedRoot(r.tufRepo.Root)
+	if err != nil {
+		return nil, err
+	}
+	// applying the rotation changes the root in place, and it is the only
+	// metadata that changes until the new root is published
+	rotatedRoot, err := copySignedRoot(r.tufRepo.Root)
+	if err != nil {
+		return nil, err
+	}
+	rotated := *r.tufRepo
+	rotated.Root = rotatedRoot
+	legacyRoots, err := r.legacyRoots(r.LegacyVersions)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, err := r.roleThreshold(data.CanonicalRootRole, false)
+	if err != nil {
+		return nil, err
+	}
+	numKeys := threshold
+	if numKeys == 0 {
+		numKeys = currentRoot.Signed.Roles[data.CanonicalRootRole].Threshold
+	}
+	pubKeyList, err := r.pubKeyListForRotation(data.CanonicalRootRole, false, keyList, numKeys)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun && len(keyList) == 0 {
+		defer r.removeGeneratedKeys(pubKeyList)
+	}
+
+	cl := changelist.NewMemChangelist()
+	if err := r.rootFileKeyChange(cl, data.CanonicalRootRole, changelist.ActionCreate, pubKeyList, threshold); err != nil {
+		return nil, err
+	}
+	if err := applyChangelist(&rotated, r.invalid, cl); err != nil {
+		return nil, err
+	}
+	if err := checkBaseRoleThresholds(&rotated); err != nil {
+		return nil, err
+	}
+
+	legacyKeys := make(data.KeyList, 0)
+	for _, legacyRoot := range legacyRoots {
+		legacyKeys = append(legacyKeys, getOldRootPublicKeys(legacyRoot)...)
+	}
+	updatedFiles := make(map[data.RoleName][]byte)
+	if err := signRootIfNecessary(updatedFiles, &rotated, legacyKeys, false); err != nil {
+		return nil, err
+	}
+
+	previousRoots := []*data.SignedRoot{currentRoot}
+	for _, legacyRoot := range legacyRoots {
+		if legacyRoot.Signed.Version < currentRoot.Signed.Version {
+			previousRoots = append(previousRoots, legacyRoot)
+		}
+	}
+	validations, err := r.validateRotatedRoot(updatedFiles[data.CanonicalRootRole], previousRoots)
+	if err != nil {
+		return nil, err
+	}
+	var invalid []int
+	for _, validation := range validations {
+		if validation.Error != "" {
+			invalid = append(invalid, validation.Version)
+		}
+	}
+	if len(invalid) > 0 {
+		return validations, ErrRootRotationInvalid{Versions: invalid}
+	}
+	if dryRun {
+		return validations, nil
+	}
+	r.tufRepo = &rotated
+	return validations, r.publishSigned(updatedFiles)
+}
+
+// validateRotatedRoot validates the new root as clients trusting each of the
+// previous roots would
+func (r *repository) validateRotatedRoot(rootJSON []byte, previousRoots []*data.SignedRoot) ([]RootValidation, error) {
+	validations := make([]RootValidation, 0, len(previousRoots))
+	for _, prevRoot := range previousRoots {
+		// ValidateRoot marks the signatures it verifies, so give it a fresh copy each time
+		newRoot := &data.Signed{}
+		if err := json.Unmarshal(rootJSON, newRoot); err != nil {
+			return nil, err
+		}
+		validation := RootValidation{Version: prevRoot.Signed.Version}
+		if _, err := trustpinning.ValidateRoot(prevRoot, newRoot, r.gun, r.trustPinning); err != nil {
+			logrus.Debugf("clients trusting root version %d would reject the new root: %s", prevRoot.Signed.Version, err)
+			validation.Error = err.Error()
+		}
+		validations = append(validations, validation)
+	}
+	return validations, nil
+}
+
+// removeGeneratedKeys removes the private keys of root keys generated for a dry run
+func (r *repository) removeGeneratedKeys(keys data.KeyList) {
+	for _, key := range keys {
+		keyID, err := utils.CanonicalKeyID(key)
+		if err == nil {
+			err = r.GetCryptoService().RemoveKey(keyID)
+		}
+		if err != nil {
+			logrus.Warnf("unable to remove root key generated for the dry run: %s", err)
+		}
+	}
+}
+
+func copySignedRoot(root *data.SignedRoot) (*data.SignedRoot, error) {
+	signedRoot, err := root.ToSigned()
+	if err != nil {
+		return nil, err
+	}
+	rootJSON, err := json.Marshal(signedRoot)
+	if err != nil {
+		return nil, err
+	}
+	copied := &data.Signed{}
+	if err := json.Unmarshal(rootJSON, copied); err != nil {
+		return nil, err
+	}
+	return data.RootFromSigned(copied)
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+// canonicalRootKeyID returns the canonical ID of the only root key of the repo
+func canonicalRootKeyID(t *testing.T, repo *repository) string {
+	rootRole, err := repo.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+	keyID, err := utils.CanonicalKeyID(rootRole.Keys[rootRoleCertID(t, repo)])
+	require.NoError(t, err)
+	return keyID
+}
+
+// requireRootUnchanged checks that the repo's root is still the given version,
+// with the given root key, and has no unpublished changes
+func requireRootUnchanged(t *testing.T, repo *repository, version int, keyID string) {
+	require.Equal(t, version, repo.tufRepo.Root.Signed.Version)
+	require.Equal(t, keyID, canonicalRootKeyID(t, repo))
+	require.False(t, repo.tufRepo.Root.Dirty)
+}
+
+func TestPlanRootRotation(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	authorRepo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	require.NoError(t, authorRepo.Publish())
+	firstKeyID := canonicalRootKeyID(t, authorRepo)
+
+	// Without any legacy versions, only the current root keys co-sign
+	plan, err := authorRepo.PlanRootRotation(nil)
+	require.NoError(t, err)
+	require.Equal(t, data.GUN("docker.com/notary"), plan.GUN)
+	require.Equal(t, RootKeySet{Version: 1, KeyIDs: []string{firstKeyID}, Threshold: 1, MissingKeyIDs: []string{}}, plan.Current)
+	require.True(t, plan.GenerateKey)
+	require.Equal(t, RootKeySet{Version: 2, KeyIDs: []string{}, Threshold: 1, MissingKeyIDs: []string{}}, plan.New)
+	require.Empty(t, plan.Legacy)
+
+	require.NoError(t, authorRepo.RotateKey(data.CanonicalRootRole, false, nil))
+	require.NoError(t, authorRepo.updateTUF(false))
+	secondKeyID := canonicalRootKeyID(t, authorRepo)
+
+	// The new keys can be given, and the legacy versions are the previous versions of the root
+	newKey, err := authorRepo.GetCryptoService().Create(data.CanonicalRootRole, authorRepo.gun, data.ECDSAKey)
+	require.NoError(t, err)
+	authorRepo.SetLegacyVersions(SignWithAllOldVersions)
+	plan, err = authorRepo.PlanRootRotation([]string{newKey.ID()})
+	require.NoError(t, err)
+	require.Equal(t, RootKeySet{Version: 2, KeyIDs: []string{secondKeyID}, Threshold: 1, MissingKeyIDs: []string{}}, plan.Current)
+	require.False(t, plan.GenerateKey)
+	require.Equal(t, RootKeySet{Version: 3, KeyIDs: []string{newKey.ID()}, Threshold: 1, MissingKeyIDs: []string{}}, plan.New)
+	require.Equal(t, []RootKeySet{{Version: 1, KeyIDs: []string{firstKeyID}, Threshold: 1, MissingKeyIDs: []string{}}}, plan.Legacy)
+
+	// Keys which are not available locally are listed as missing
+	require.NoError(t, authorRepo.GetCryptoService().RemoveKey(firstKeyID))
+	plan, err = authorRepo.PlanRootRotation([]string{newKey.ID()})
+	require.NoError(t, err)
+	require.Equal(t, []string{firstKeyID}, plan.Legacy[0].MissingKeyIDs)
+	require.False(t, plan.Legacy[0].CanSign())
+	require.True(t, plan.Current.CanSign())
+
+	// New keys which do not exist are an error
+	_, err = authorRepo.PlanRootRotation([]string{"nonexistent"})
+	require.Error(t, err)
+}
+
+func TestRotateRootDryRunAndApply(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	authorRepo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	require.NoError(t, authorRepo.Publish())
+	firstKeyID := canonicalRootKeyID(t, authorRepo)
+
+	// Initialize a user, who trusts the first version of the root
+	userRepo, _, userBaseDir := newRepoToTestRepo(t, authorRepo, "")
+	defer os.RemoveAll(userBaseDir)
+	require.NoError(t, userRepo.updateTUF(false))
+
+	require.NoError(t, authorRepo.RotateKey(data.CanonicalRootRole, false, nil))
+	require.NoError(t, authorRepo.updateTUF(false))
+	secondKeyID := canonicalRootKeyID(t, authorRepo)
+	rootKeys := len(authorRepo.GetCryptoService().ListKeys(data.CanonicalRootRole))
+
+	// A dry run validates the new root as clients trusting the current and legacy roots would,
+	// without publishing it or keeping the generated key
+	authorRepo.SetLegacyVersions(SignWithAllOldVersions)
+	validations, err := authorRepo.RotateRoot(nil, true)
+	require.NoError(t, err)
+	require.Equal(t, []RootValidation{{Version: 2}, {Version: 1}}, validations)
+	require.Len(t, authorRepo.GetCryptoService().ListKeys(data.CanonicalRootRole), rootKeys)
+	requireRootUnchanged(t, authorRepo, 2, secondKeyID)
+	require.NoError(t, authorRepo.updateTUF(false))
+	requireRootUnchanged(t, authorRepo, 2, secondKeyID)
+
+	// Without the first root key, clients trusting the first root would reject the new root
+	require.NoError(t, authorRepo.GetCryptoService().RemoveKey(firstKeyID))
+	validations, err = authorRepo.RotateRoot(nil, true)
+	require.Error(t, err)
+	require.IsType(t, ErrRootRotationInvalid{}, err)
+	require.Equal(t, []int{1}, err.(ErrRootRotationInvalid).Versions)
+	require.Len(t, validations, 2)
+	require.Empty(t, validations[0].Error)
+	require.NotEmpty(t, validations[1].Error)
+
+	// and so would applying it
+	_, err = authorRepo.RotateRoot(nil, false)
+	require.IsType(t, ErrRootRotationInvalid{}, err)
+	requireRootUnchanged(t, authorRepo, 2, secondKeyID)
+	require.NoError(t, authorRepo.updateTUF(false))
+	requireRootUnchanged(t, authorRepo, 2, secondKeyID)
+
+	// Without legacy support, the rotation can be applied, to a given key
+	newKey, err := authorRepo.GetCryptoService().Create(data.CanonicalRootRole, authorRepo.gun, data.ECDSAKey)
+	require.NoError(t, err)
+	authorRepo.SetLegacyVersions(0)
+	validations, err = authorRepo.RotateRoot([]string{newKey.ID()}, false)
+	require.NoError(t, err)
+	require.Equal(t, []RootValidation{{Version: 2}}, validations)
+
+	require.NoError(t, authorRepo.updateTUF(false))
+	require.Equal(t, 3, authorRepo.tufRepo.Root.Signed.Version)
+	require.Equal(t, newKey.ID(), canonicalRootKeyID(t, authorRepo))
+
+	// and the user, who walks through every version of the root, follows it
+	require.NoError(t, userRepo.updateTUF(false))
+	require.Equal(t, newKey.ID(), canonicalRootKeyID(t, userRepo))
+}
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	canonicaljson "github.com/docker/go/canonical/json"
+	"github.com/sirupsen/logrus"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+// SignRequestRole describes one of the roles whose threshold must be met by the
+// signatures on a SignRequest before it can be published
+type SignRequestRole struct {
+	Name      data.RoleName `json:"name"`
+	Threshold int           `json:"threshold"`
+	Keys      data.KeyList  `json:"keys"`
+}
+
+// BaseRole converts the SignRequestRole into a data.BaseRole
+func (s SignRequestRole) BaseRole() data.BaseRole {
+	return data.NewBaseRole(s.Name, s.Threshold, s.Keys...)
+}
+
+// SignRequest is the next version of a root, targets or delegated targets role
+// along with the signatures gathered for it so far.  It can be passed between
+// key holders so that a role whose threshold cannot be met by any one
+// CryptoService can still be signed and published.
+type SignRequest struct {
+	GUN   data.GUN          `json:"gun"`
+	Role  data.RoleName     `json:"role"`
+	Roles []SignRequestRole `json:"signing_roles"`
+	// Signed is the partially signed metadata that will be published
+	Signed *data.Signed `json:"signed"`
+	// Changes are the staged changes which were applied to the metadata, and
+	// which are cleared from the changelist once it has been published
+	Changes []*changelist.TUFChange `json:"changes,omitempty"`
+}
+
+// NewSignRequestFromJSON parses a serialized SignRequest
+func NewSignRequestFromJSON(b []byte) (*SignRequest, error) {
+	req := &SignRequest{}
+	if err := json.Unmarshal(b, req); err != nil {
+		return nil, err
+	}
+	if req.Signed == nil || req.Signed.Signed == nil {
+		return nil, fmt.Errorf("sign request for %s does not contain any metadata", req.Role)
+	}
+	if len(req.Roles) == 0 {
+		return nil, fmt.Errorf("sign request for %s does not list any signing roles", req.Role)
+	}
+	// The signatures are over the canonical serialization of the metadata,
+	// which might not have survived being embedded in the request, so
+	// restore it before anything tries to verify or add signatures.
+	var decoded map[string]interface{}
+	if err := canonicaljson.Unmarshal(*req.Signed.Signed, &decoded); err != nil {
+		return nil, err
+	}
+	canonical, err := canonicaljson.MarshalCanonical(decoded)
+	if err != nil {
+		return nil, err
+	}
+	raw := canonicaljson.RawMessage(canonical)
+	req.Signed.Signed = &raw
+	return req, nil
+}
+
+// Version returns the version of the metadata being signed
+func (req *SignRequest) Version() (int, error) {
+	common := data.SignedCommon{}
+	if err := json.Unmarshal(*req.Signed.Signed, &common); err != nil {
+		return 0, err
+	}
+	return common.Version, nil
+}
+
+// Sign adds signatures from any of the signing roles' keys that are available
+// in the given CryptoService, and returns the IDs of the keys that signed.
+// Existing valid signatures are preserved.
+func (req *SignRequest) Sign(cs signed.CryptoService) ([]string, error) {
+	var allKeys data.KeyList
+	for _, r := range req.Roles {
+		allKeys = append(allKeys, r.Keys...)
+	}
+
+	alreadySigned := make(map[string]struct{}, len(req.Signed.Signatures))
+	for _, sig := range req.Signed.Signatures {
+		alreadySigned[sig.KeyID] = struct{}{}
+	}
+
+	if err := signed.Sign(cs, req.Signed, allKeys, 0, nil); err != nil {
+		return nil, err
+	}
+
+	var signedWith []string
+	for _, sig := range req.Signed.Signatures {
+		if _, ok := alreadySigned[sig.KeyID]; !ok {
+			signedWith = append(signedWith, sig.KeyID)
+		}
+	}
+	return signedWith, nil
+}
+
+// VerifyThresholds checks whether the threshold of every signing role listed
+// in the request has been met
+func (req *SignRequest) VerifyThresholds() error {
+	for _, r := range req.Roles {
+		if err := signed.VerifySignatures(req.Signed, r.BaseRole()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportSignRequest applies any pending changes to the given root, targets
+// or delegated targets role and returns its next version, signed with whatever
+// keys are available locally.  The changelist is left intact, as the changes
+// to the role are only cleared once the request has been published.
+func (r *repository) ExportSignRequest(role data.RoleName) (*SignRequest, error) {
+	if err := r.updateTUF(true); err != nil {
+		return nil, err
+	}
+	if err := applyChangelist(r.tufRepo, r.invalid, r.changelist); err != nil {
+		return nil, err
+	}
+
+	roles, err := r.tufRepo.SigningRoles(role)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := r.tufRepo.PartiallySign(role, time.Time{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &SignRequest{
+		GUN:    r.gun,
+		Role:   role,
+		Signed: s,
+	}
+	for _, c := range r.changelist.List() {
+		if changedRole(c) == role {
+			req.Changes = append(req.Changes,
+				changelist.NewTUFChange(c.Action(), c.Scope(), c.Type(), c.Path(), c.Content()))
+		}
+	}
+	for _, baseRole := range roles {
+		req.Roles = append(req.Roles, SignRequestRole{
+			Name:      baseRole.Name,
+			Threshold: baseRole.Threshold,
+			Keys:      baseRole.ListKeys(),
+		})
+	}
+	return req, nil
+}
+
+// PublishSignRequest verifies that the signatures on the request meet the
+// thresholds of the currently trusted roles (and, for root, of the new root
+// itself) and publishes it, along with a new snapshot if the snapshot key is
+// held locally.  Once published, the changes which were incorporated when the
+// request was exported are cleared from the changelist.  Changes staged since
+// then stay staged.
+func (r *repository) PublishSignRequest(req *SignRequest) error {
+	if req.GUN != r.gun {
+		return fmt.Errorf("sign request is for %s, not %s", req.GUN, r.gun)
+	}
+	if err := r.updateTUF(true); err != nil {
+		return err
+	}
+
+	trustedRoles, err := r.tufRepo.SigningRoles(req.Role)
+	if err != nil {
+		return err
+	}
+
+	version, err := req.Version()
+	if err != nil {
+		return err
+	}
+
+	var (
+		currentVersion int
+		install        func()
+	)
+	switch req.Role {
+	case data.CanonicalRootRole:
+		newRoot, err := data.RootFromSigned(req.Signed)
+		if err != nil {
+			return err
+		}
+		newRootRole, err := newRoot.BuildBaseRole(data.CanonicalRootRole)
+		if err != nil {
+			return err
+		}
+		trustedRoles = append(trustedRoles, newRootRole)
+		currentVersion = r.tufRepo.Root.Signed.Version
+		install = func() { r.tufRepo.Root = newRoot }
+	default:
+		newTargets, err := data.TargetsFromSigned(req.Signed, req.Role)
+		if err != nil {
+			return err
+		}
+		if current, ok := r.tufRepo.Targets[req.Role]; ok {
+			currentVersion = current.Signed.Version
+		}
+		install = func() { r.tufRepo.Targets[req.Role] = newTargets }
+	}
+
+	if version <= currentVersion {
+		return fmt.Errorf("sign request for %s is for version %d, but version %d has already been published",
+			req.Role, version, currentVersion)
+	}
+	for _, trusted := range trustedRoles {
+		if err := signed.VerifySignatures(req.Signed, trusted); err != nil {
+			logrus.Debugf("sign request for %s does not meet the threshold of %s", req.Role, trusted.Name)
+			return err
+		}
+	}
+	install()
+
+	metaJSON, err := json.Marshal(req.Signed)
+	if err != nil {
+		return err
+	}
+	if err := r.publishSigned(map[data.RoleName][]byte{req.Role: metaJSON}); err != nil {
+		return err
+	}
+
+	clearExported := func(cl changelist.Changelist) error {
+		return removeChanges(cl, req.Changes)
+	}
+	if tcl, ok := r.changelist.(changelist.Transactional); ok {
+		err = tcl.Transaction(clearExported)
+	} else {
+		err = clearExported(r.changelist)
+	}
+	if err != nil {
+		// as with a regular publish, the changes have been published, so
+		// this is not a reason to fail
+		logrus.Warnf("Unable to clear the published changes to %s from the changelist: %v", req.Role, err)
+	}
+	return nil
+}
+
+// changedRole returns the role whose metadata a change modifies: changes to
+// delegations modify their parent role
+func changedRole(c changelist.Change) data.RoleName {
+	if c.Type() == changelist.TypeTargetsDelegation {
+		return c.Scope().Parent()
+	}
+	return c.Scope()
+}
+
+// removeChanges removes the first staged change matching each of the given
+// changes from the changelist, leaving any others staged
+func removeChanges(cl changelist.Changelist, changes []*changelist.TUFChange) error {
+	remaining := append([]*changelist.TUFChange{}, changes...)
+	var idxs []int
+	for i, c := range cl.List() {
+		for j, exported := range remaining {
+			if sameChange(c, exported) {
+				idxs = append(idxs, i)
+				remaining = append(remaining[:j], remaining[j+1:]...)
+				break
+			}
+		}
+	}
+	if len(idxs) == 0 {
+		return nil
+	}
+	return cl.Remove(idxs)
+}
+
+func sameChange(a, b changelist.Change) bool {
+	return a.Action() == b.Action() && a.Scope() == b.Scope() && a.Type() == b.Type() &&
+		a.Path() == b.Path() && bytes.Equal(a.Content(), b.Content())
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+// a crypto service standing in for a key holder on another machine
+func offlineCryptoService() signed.CryptoService {
+	return cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
+}
+
+// round trips a sign request through its serialized form, as happens when it
+// is passed between key holders
+func roundTripSignRequest(t *testing.T, req *SignRequest) *SignRequest {
+	b, err := json.Marshal(req)
+	require.NoError(t, err)
+	parsed, err := NewSignRequestFromJSON(b)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestSignRequestTargetsThreshold(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	require.NoError(t, repo.Publish())
+
+	// add a second targets key and require both keys to sign, re-signing the
+	// existing targets so that it stays valid under the new threshold
+	otherKey, err := repo.cryptoService.Create(data.CanonicalTargetsRole, repo.gun, data.ECDSAKey)
+	require.NoError(t, err)
+	require.NoError(t, repo.updateTUF(true))
+	require.NoError(t, repo.tufRepo.AddBaseKeys(data.CanonicalTargetsRole, otherKey))
+	repo.tufRepo.Root.Signed.Roles[data.CanonicalTargetsRole].Threshold = 2
+	updates := make(map[data.RoleName][]byte)
+	require.NoError(t, signRootIfNecessary(updates, repo.tufRepo, nil, false))
+	updates[data.CanonicalTargetsRole], err = serializeCanonicalRole(repo.tufRepo, data.CanonicalTargetsRole, nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.publishSigned(updates))
+
+	// then move the second key to another machine
+	otherCS := offlineCryptoService()
+	privKey, role, err := repo.cryptoService.GetPrivateKey(otherKey.ID())
+	require.NoError(t, err)
+	require.NoError(t, otherCS.AddKey(role, repo.gun, privKey))
+	require.NoError(t, repo.cryptoService.RemoveKey(otherKey.ID()))
+
+	// a regular publish can no longer meet the targets threshold
+	addTarget(t, repo, "current", "../fixtures/intermediate-ca.crt")
+	require.IsType(t, signed.ErrInsufficientSignatures{}, repo.Publish())
+
+	// a new delegation changes the targets metadata too
+	tdJSON, err := json.Marshal(&changelist.TUFDelegation{
+		NewThreshold: 1,
+		AddKeys:      data.KeyList{createKey(t, repo, "targets/releases", false)},
+		AddPaths:     []string{""},
+	})
+	require.NoError(t, err)
+	require.NoError(t, addChange(repo.changelist, newCreateDelegationChange("targets/releases", tdJSON), "targets/releases"))
+
+	req, err := repo.ExportSignRequest(data.CanonicalTargetsRole)
+	require.NoError(t, err)
+	require.Len(t, req.Changes, 2)
+	require.Len(t, req.Signed.Signatures, 1)
+	require.Len(t, req.Roles, 1)
+
+	// changes staged after exporting the request are not part of it
+	addTarget(t, repo, "later", "../fixtures/intermediate-ca.crt")
+	require.Equal(t, 2, req.Roles[0].Threshold)
+	require.IsType(t, signed.ErrRoleThreshold{}, req.VerifyThresholds())
+
+	// publishing without enough signatures fails
+	req = roundTripSignRequest(t, req)
+	require.IsType(t, signed.ErrRoleThreshold{}, repo.PublishSignRequest(req))
+
+	signedWith, err := req.Sign(otherCS)
+	require.NoError(t, err)
+	require.Equal(t, []string{otherKey.ID()}, signedWith)
+	require.Len(t, req.Signed.Signatures, 2)
+	require.NoError(t, req.VerifyThresholds())
+
+	// signing again with the same keys adds nothing
+	req = roundTripSignRequest(t, req)
+	signedWith, err = req.Sign(otherCS)
+	require.NoError(t, err)
+	require.Empty(t, signedWith)
+
+	require.NoError(t, repo.PublishSignRequest(req))
+	// the changes published with the request are no longer staged, but the
+	// ones staged since are
+	staged := repo.changelist.List()
+	require.Len(t, staged, 1)
+	require.Equal(t, "later", staged[0].Path())
+
+	userRepo, _, userDir := newRepoToTestRepo(t, repo, "")
+	defer os.RemoveAll(userDir)
+	_, err = userRepo.GetTargetByName("current")
+	require.NoError(t, err)
+	_, err = userRepo.GetTargetByName("later")
+	require.Error(t, err)
+	delegations := userRepo.tufRepo.Targets[data.CanonicalTargetsRole].Signed.Delegations.Roles
+	require.Len(t, delegations, 1)
+	require.Equal(t, data.RoleName("targets/releases"), delegations[0].Name)
+
+	// the same request cannot be published twice
+	require.Error(t, repo.PublishSignRequest(req))
+}
+
+func TestSignRequestRootRotationToOfflineKey(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+	require.NoError(t, repo.Publish())
+
+	// the new root key, and its certificate, are generated on another machine
+	otherCS := offlineCryptoService()
+	otherKey, err := otherCS.Create(data.CanonicalRootRole, repo.gun, data.ECDSAKey)
+	require.NoError(t, err)
+	otherPrivKey, _, err := otherCS.GetPrivateKey(otherKey.ID())
+	require.NoError(t, err)
+	start := time.Now().AddDate(0, 0, -1)
+	cert, err := cryptoservice.GenerateCertificate(otherPrivKey, repo.gun, start, start.AddDate(1, 0, 0))
+	require.NoError(t, err)
+	otherCertKey := utils.CertToKey(cert)
+
+	kl, err := json.Marshal(&changelist.TUFRootData{
+		RoleName: data.CanonicalRootRole,
+		Keys:     data.KeyList{otherCertKey},
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.changelist.Add(changelist.NewTUFChange(
+		changelist.ActionCreate,
+		changelist.ScopeRoot,
+		changelist.TypeBaseRole,
+		data.CanonicalRootRole.String(),
+		kl,
+	)))
+
+	req, err := repo.ExportSignRequest(data.CanonicalRootRole)
+	require.NoError(t, err)
+	require.Len(t, req.Roles, 2, "both the old and new root roles should be listed")
+	require.Len(t, req.Signed.Signatures, 1)
+
+	// only the old root key has signed, which is not enough to trust the new root
+	req = roundTripSignRequest(t, req)
+	require.Error(t, repo.PublishSignRequest(req))
+
+	_, err = req.Sign(otherCS)
+	require.NoError(t, err)
+	req = roundTripSignRequest(t, req)
+	require.NoError(t, req.VerifyThresholds())
+	require.NoError(t, repo.PublishSignRequest(req))
+
+	userRepo, _, userDir := newRepoToTestRepo(t, repo, "")
+	defer os.RemoveAll(userDir)
+	require.NoError(t, userRepo.updateTUF(false))
+	require.Equal(t, []string{otherCertKey.ID()}, userRepo.tufRepo.Root.Signed.Roles[data.CanonicalRootRole].KeyIDs)
+}
+
+func TestSignRequestDelegation(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _, baseDir := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(baseDir)
+
+	// the delegation requires signatures from both the publisher and the delegate
+	localKey := createKey(t, repo, "targets/releases", false)
+	otherCS := offlineCryptoService()
+	delgKey, err := otherCS.Create("targets/releases", repo.gun, data.ECDSAKey)
+	require.NoError(t, err)
+	tdJSON, err := json.Marshal(&changelist.TUFDelegation{
+		NewThreshold: 2,
+		AddKeys:      data.KeyList{localKey, delgKey},
+		AddPaths:     []string{""},
+	})
+	require.NoError(t, err)
+	require.NoError(t, addChange(repo.changelist, newCreateDelegationChange("targets/releases", tdJSON), "targets/releases"))
+	require.NoError(t, repo.Publish())
+
+	addTarget(t, repo, "current", "../fixtures/intermediate-ca.crt", "targets/releases")
+	require.IsType(t, signed.ErrInsufficientSignatures{}, repo.Publish())
+
+	req, err := repo.ExportSignRequest("targets/releases")
+	require.NoError(t, err)
+	require.Len(t, req.Signed.Signatures, 1)
+
+	req = roundTripSignRequest(t, req)
+	signedWith, err := req.Sign(otherCS)
+	require.NoError(t, err)
+	require.Equal(t, []string{delgKey.ID()}, signedWith)
+	require.NoError(t, repo.PublishSignRequest(roundTripSignRequest(t, req)))
+
+	userRepo, _, userDir := newRepoToTestRepo(t, repo, "")
+	defer os.RemoveAll(userDir)
+	target, err := userRepo.GetTargetByName("current")
+	require.NoError(t, err)
+	require.Equal(t, data.RoleName("targets/releases"), target.Role)
+}
+
+func TestSignRequestInvalid(t *testing.T) {
+	_, err := NewSignRequestFromJSON([]byte(`{"gun": "docker.com/notary", "role": "targets"}`))
+	require.Error(t, err)
+	_, err = NewSignRequestFromJSON([]byte(`not json`))
+	require.Error(t, err)
+
+	repo := &repository{gun: "docker.com/notary"}
+	err = repo.PublishSignRequest(&SignRequest{GUN: "other/gun", Role: data.CanonicalTargetsRole})
+	require.Error(t, err)
+}
@@ -327,6 +327,38 @@ type TUFLoadOptions struct {
 	AlwaysCheckInitialized bool
 }
 
+// setDefaults sets some sane defaults, so nothing has to be provided necessarily
+func (l *TUFLoadOptions) setDefaults() {
+	if l.RemoteStore == nil {
+		l.RemoteStore = store.OfflineStore{}
+	}
+	if l.Cache == nil {
+		l.Cache = store.NewMemoryStore(nil)
+	}
+	if l.CryptoService == nil {
+		l.CryptoService = cryptoservice.EmptyService
+	}
+}
+
+// stapledTrustPinning returns the trust pinning configuration with the OCSP responses
+// stapled to the root, which are kept next to the cached root.  They are only looked up
+// when a CA is pinned, since they are not used otherwise.
+func (l *TUFLoadOptions) stapledTrustPinning() trustpinning.TrustPinConfig {
+	trustPinning := l.TrustPinning
+	if len(trustPinning.CA) == 0 {
+		return trustPinning
+	}
+	stapled, err := l.Cache.GetSized(trustpinning.StapledOCSPName, store.NoSizeLimit)
+	if err != nil {
+		return trustPinning
+	}
+	if err := json.Unmarshal(stapled, &trustPinning.StapledOCSP); err != nil {
+		logrus.Debugf("ignoring invalid stapled OCSP responses: %s", err)
+		trustPinning.StapledOCSP = nil
+	}
+	return trustPinning
+}
+
 // bootstrapClient attempts to bootstrap a root.json to be used as the trust
 // anchor for a repository. The checkInitialized argument indicates whether
 // we should always attempt to contact the server to determine if the repository
@@ -351,9 +383,7 @@ func bootstrapClient(l TUFLoadOptions) (*tufClient, error) {
 	// the old root on disk should not be validated against any trust pinning configuration
 	// because if we have an old root, it itself is the thing that pins trust
 	oldBuilder := tuf.NewRepoBuilder(l.GUN, l.CryptoService, trustpinning.TrustPinConfig{})
-
-	// by default, we want to use the trust pinning configuration on any new root that we download
-	newBuilder := tuf.NewRepoBuilder(l.GUN, l.CryptoService, l.TrustPinning)
+	var newBuilder tuf.RepoBuilder
 
 	// Try to read root from cache first. We will trust this root until we detect a problem
 	// during update which will cause us to download a new root and perform a rotation.
@@ -374,10 +404,15 @@ func bootstrapClient(l TUFLoadOptions) (*tufClient, error) {
 			// old root to verify the new root, so bootstrap a new builder with the old builder
 			// but use the trustpinning to validate the new root
 			minVersion = oldBuilder.GetLoadedVersion(data.CanonicalRootRole)
-			newBuilder = oldBuilder.BootstrapNewBuilderWithNewTrustpin(l.TrustPinning)
+			newBuilder = oldBuilder.BootstrapNewBuilderWithNewTrustpin(l.stapledTrustPinning())
 		}
 	}
 
+	if newBuilder == nil {
+		// by default, we want to use the trust pinning configuration on any new root that we download
+		newBuilder = tuf.NewRepoBuilder(l.GUN, l.CryptoService, l.stapledTrustPinning())
+	}
+
 	if !newBuilder.IsLoaded(data.CanonicalRootRole) || l.AlwaysCheckInitialized {
 		// remoteErr was nil and we were not able to load a root from cache or
 		// are specifically checking for initialization of the repo.
@@ -423,17 +458,7 @@ func bootstrapClient(l TUFLoadOptions) (*tufClient, error) {
 // all the metadata for the repo from the remote (if provided). It loads a TUF repo from cache,
 // from a remote store, or both.
 func LoadTUFRepo(options TUFLoadOptions) (*tuf.Repo, *tuf.Repo, error) {
-	// set some sane defaults, so nothing has to be provided necessarily
-	if options.RemoteStore == nil {
-		options.RemoteStore = store.OfflineStore{}
-	}
-	if options.Cache == nil {
-		options.Cache = store.NewMemoryStore(nil)
-	}
-	if options.CryptoService == nil {
-		options.CryptoService = cryptoservice.EmptyService
-	}
-
+	options.setDefaults()
 	c, err := bootstrapClient(options)
 	if err != nil {
 		if _, ok := err.(store.ErrMetaNotFound); ok {
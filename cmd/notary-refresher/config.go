@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/utils"
+)
+
+const (
+	envPrefix        = "NOTARY_REFRESHER"
+	defaultThreshold = 30 * 24 * time.Hour
+	defaultInterval  = time.Hour
+)
+
+// refresherConfig is the parsed configuration of notary-refresher
+type refresherConfig struct {
+	trustDir  string
+	serverURL string
+	transport http.RoundTripper
+	// guns are the repositories to refresh, in addition to any found in the
+	// server's changefeed if changefeed is set
+	guns       []data.GUN
+	changefeed bool
+	// roles which expire within threshold are re-signed, every interval
+	threshold    time.Duration
+	interval     time.Duration
+	metricsAddr  string
+	expiryPolicy *data.ExpiryPolicy
+}
+
+// parseConfig reads the configuration file and sets the log level
+func parseConfig(configFilePath string) (*refresherConfig, error) {
+	config := viper.New()
+	utils.SetupViper(config, envPrefix)
+
+	if err := utils.ParseViper(config, configFilePath); err != nil {
+		return nil, err
+	}
+
+	lvl, err := utils.ParseLogLevel(config, logrus.InfoLevel)
+	if err != nil {
+		return nil, err
+	}
+	logrus.SetLevel(lvl)
+
+	return parseRefresherConfig(config)
+}
+
+func parseRefresherConfig(config *viper.Viper) (*refresherConfig, error) {
+	rc := &refresherConfig{
+		trustDir:    utils.GetPathRelativeToConfig(config, "trust_dir"),
+		serverURL:   config.GetString("remote_server.url"),
+		changefeed:  config.GetBool("refresher.changefeed"),
+		threshold:   defaultThreshold,
+		interval:    defaultInterval,
+		metricsAddr: config.GetString("refresher.metrics_addr"),
+	}
+	if rc.trustDir == "" {
+		return nil, fmt.Errorf("must provide a trust_dir holding the keys to sign with")
+	}
+	if rc.serverURL == "" {
+		return nil, fmt.Errorf("must provide the url of the remote_server")
+	}
+	for _, gun := range config.GetStringSlice("refresher.guns") {
+		rc.guns = append(rc.guns, data.GUN(gun))
+	}
+	if len(rc.guns) == 0 && !rc.changefeed {
+		return nil, fmt.Errorf("must provide a list of guns to refresh, or enable the changefeed")
+	}
+	if config.IsSet("refresher.threshold") {
+		rc.threshold = config.GetDuration("refresher.threshold")
+	}
+	if config.IsSet("refresher.interval") {
+		rc.interval = config.GetDuration("refresher.interval")
+	}
+	if rc.threshold <= 0 || rc.interval <= 0 {
+		return nil, fmt.Errorf("refresher threshold and interval must be positive durations")
+	}
+
+	expiryPolicy, err := utils.ParseExpiryPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	rc.expiryPolicy = expiryPolicy
+
+	rc.transport, err = getTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// getTransport returns a transport for the remote server which uses the TLS
+// configuration under remote_server.  Token authentication is not supported.
+func getTransport(config *viper.Viper) (http.RoundTripper, error) {
+	clientCert := utils.GetPathRelativeToConfig(config, "remote_server.tls_client_cert")
+	clientKey := utils.GetPathRelativeToConfig(config, "remote_server.tls_client_key")
+	if (clientCert == "") != (clientKey == "") {
+		return nil, fmt.Errorf("either pass both client key and cert, or neither")
+	}
+
+	tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
+		CAFile:             utils.GetPathRelativeToConfig(config, "remote_server.root_ca"),
+		InsecureSkipVerify: config.GetBool("remote_server.skipTLSVerify"),
+		CertFile:           clientCert,
+		KeyFile:            clientKey,
+		ExclusiveRootPools: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure TLS: %s", err.Error())
+	}
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+		DisableKeepAlives:   true,
+	}, nil
+}
+
+// getPassphraseRetriever returns a retriever which reads key passphrases from
+// the same environment variables as the notary client.  As the refresher runs
+// unattended, it never prompts for them.
+func getPassphraseRetriever() notary.PassRetriever {
+	env := map[string]string{
+		"root":       os.Getenv("NOTARY_ROOT_PASSPHRASE"),
+		"targets":    os.Getenv("NOTARY_TARGETS_PASSPHRASE"),
+		"snapshot":   os.Getenv("NOTARY_SNAPSHOT_PASSPHRASE"),
+		"delegation": os.Getenv("NOTARY_DELEGATION_PASSPHRASE"),
+	}
+
+	return func(keyName string, alias string, createNew bool, numAttempts int) (string, bool, error) {
+		if v := env[alias]; v != "" {
+			return v, numAttempts > 1, nil
+		}
+		if v := env["delegation"]; !data.IsBaseRole(data.RoleName(alias)) && v != "" {
+			return v, numAttempts > 1, nil
+		}
+		return "", true, passphrase.ErrNoInput
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func writeConfig(t *testing.T, dir, config string) string {
+	configFile := filepath.Join(dir, "refresher.json")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(config), 0644))
+	return configFile
+}
+
+func TestParseConfig(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "notary-refresher-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	defer logrus.SetLevel(logrus.GetLevel())
+
+	config, err := parseConfig(writeConfig(t, tempDir, `{
+		"trust_dir": "trust",
+		"remote_server": {"url": "https://notary-server:4443"},
+		"refresher": {
+			"guns": ["docker.com/notary"],
+			"changefeed": true,
+			"threshold": "48h",
+			"interval": "10m",
+			"metrics_addr": ":9090"
+		},
+		"expiry": [{"role": "targets", "expiry": "720h"}],
+		"logging": {"level": "debug"}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+	require.Equal(t, filepath.Join(tempDir, "trust"), config.trustDir)
+	require.Equal(t, "https://notary-server:4443", config.serverURL)
+	require.NotNil(t, config.transport)
+	require.Equal(t, []data.GUN{"docker.com/notary"}, config.guns)
+	require.True(t, config.changefeed)
+	require.Equal(t, 48*time.Hour, config.threshold)
+	require.Equal(t, 10*time.Minute, config.interval)
+	require.Equal(t, ":9090", config.metricsAddr)
+	require.Equal(t, 720*time.Hour, config.expiryPolicy.Expiry(testGUN, data.CanonicalTargetsRole))
+
+	// defaults
+	config, err = parseConfig(writeConfig(t, tempDir, `{
+		"trust_dir": "/trust",
+		"remote_server": {"url": "https://notary-server:4443"},
+		"refresher": {"guns": ["docker.com/notary"]}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, "/trust", config.trustDir)
+	require.False(t, config.changefeed)
+	require.Equal(t, defaultThreshold, config.threshold)
+	require.Equal(t, defaultInterval, config.interval)
+	require.Nil(t, config.expiryPolicy)
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "notary-refresher-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	_, err = parseConfig(filepath.Join(tempDir, "nope.json"))
+	require.Error(t, err)
+
+	for _, invalid := range []string{
+		`"remote_server": {"url": "https://notary-server:4443"}, "refresher": {"guns": ["a"]}`,
+		`"trust_dir": "/trust", "refresher": {"guns": ["a"]}`,
+		`"trust_dir": "/trust", "remote_server": {"url": "https://notary-server:4443"}`,
+		`"trust_dir": "/trust", "remote_server": {"url": "https://notary-server:4443"}, "refresher": {"guns": ["a"], "threshold": "-1h"}`,
+		`"trust_dir": "/trust", "remote_server": {"url": "https://notary-server:4443"}, "refresher": {"guns": ["a"], "interval": "0s"}`,
+		`"trust_dir": "/trust", "remote_server": {"url": "https://notary-server:4443", "tls_client_cert": "cert.pem"}, "refresher": {"guns": ["a"]}`,
+		`"trust_dir": "/trust", "remote_server": {"url": "https://notary-server:4443"}, "refresher": {"guns": ["a"]}, "expiry": [{"role": "nope", "expiry": "1h"}]`,
+		`"trust_dir": "/trust", "remote_server": {"url": "https://notary-server:4443"}, "refresher": {"guns": ["a"]}, "logging": {"level": "nope"}`,
+	} {
+		_, err := parseConfig(writeConfig(t, tempDir, fmt.Sprintf("{%s}", invalid)))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestPassphraseRetrieverUsesEnvironment(t *testing.T) {
+	for _, env := range []string{"NOTARY_ROOT_PASSPHRASE", "NOTARY_TARGETS_PASSPHRASE", "NOTARY_DELEGATION_PASSPHRASE"} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	os.Setenv("NOTARY_ROOT_PASSPHRASE", "root-pass")
+	os.Setenv("NOTARY_DELEGATION_PASSPHRASE", "delegation-pass")
+	retriever := getPassphraseRetriever()
+
+	pass, giveUp, err := retriever("key", data.CanonicalRootRole.String(), false, 0)
+	require.NoError(t, err)
+	require.False(t, giveUp)
+	require.Equal(t, "root-pass", pass)
+
+	pass, _, err = retriever("key", "targets/releases", false, 0)
+	require.NoError(t, err)
+	require.Equal(t, "delegation-pass", pass)
+
+	// there is no prompt to fall back to
+	_, giveUp, err = retriever("key", data.CanonicalTargetsRole.String(), false, 0)
+	require.Equal(t, passphrase.ErrNoInput, err)
+	require.True(t, giveUp)
+}
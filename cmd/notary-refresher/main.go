@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary/utils"
+	"github.com/theupdateframework/notary/version"
+)
+
+type cmdFlags struct {
+	configFile string
+	once       bool
+	version    bool
+}
+
+func setupFlags(flagStorage *cmdFlags) {
+	flag.StringVar(&flagStorage.configFile, "config", "", "Path to configuration file")
+	flag.BoolVar(&flagStorage.once, "once", false, "Refresh every repository once and exit")
+	flag.BoolVar(&flagStorage.version, "version", false, "Print the version number of notary-refresher")
+
+	flag.Usage = usage
+}
+
+func main() {
+	flagStorage := cmdFlags{}
+	setupFlags(&flagStorage)
+
+	flag.Parse()
+
+	if flagStorage.version {
+		fmt.Println("notary-refresher " + getVersion())
+		os.Exit(0)
+	}
+
+	logrus.Info(getVersion())
+
+	config, err := parseConfig(flagStorage.configFile)
+	if err != nil {
+		logrus.Fatal(err.Error())
+	}
+
+	c := utils.SetupSignalTrap(utils.LogLevelSignalHandle)
+	if c != nil {
+		defer signal.Stop(c)
+	}
+
+	r := newRefresher(config, getPassphraseRetriever())
+	if flagStorage.once {
+		r.RefreshAll()
+		return
+	}
+
+	if config.metricsAddr != "" {
+		go metricsServer(config.metricsAddr)
+	}
+	r.Run(context.Background())
+}
+
+func usage() {
+	fmt.Println("usage:", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func getVersion() string {
+	return fmt.Sprintf("Version: %s, Git commit: %s, Go version: %s", version.NotaryVersion, version.GitCommit, runtime.Version())
+}
+
+// metricsServer serves the prometheus metrics on addr
+func metricsServer(addr string) {
+	logrus.Infof("Metrics server listening on %s", addr)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", prometheus.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logrus.Fatalf("error listening on metrics interface: %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+// The results of trying to re-sign a role, which are also the labels of the
+// refreshes metric
+const (
+	resultRefreshed  = "refreshed"
+	resultMissingKey = "missing_key"
+	resultFailed     = "failed"
+)
+
+// changeCategoryDeletion is the category of the changefeed records for
+// deleted repositories
+const changeCategoryDeletion = "deletion"
+
+var (
+	secondsToExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "notary_refresher",
+		Name:      "seconds_to_expiry",
+		Help:      "Seconds until the metadata of a role expires, by GUN and role.",
+	}, []string{"gun", "role"})
+	refreshesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "notary_refresher",
+		Name:      "refreshes_total",
+		Help:      "Number of attempts to re-sign roles nearing expiry, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(secondsToExpiry, refreshesTotal)
+}
+
+type changefeedResponse struct {
+	Records []storage.Change `json:"records"`
+}
+
+// refresher re-signs the metadata of roles which are about to expire, in the
+// configured repositories and, optionally, every repository in the server's
+// changefeed
+type refresher struct {
+	config    *refresherConfig
+	retriever notary.PassRetriever
+
+	// discovered holds the repositories found in the changefeed so far, and
+	// cursor the ID of the last change read
+	discovered map[data.GUN]struct{}
+	cursor     string
+	// reported holds the roles of each repository whose expiry is in the
+	// seconds to expiry metric
+	reported map[data.GUN]map[data.RoleName]struct{}
+}
+
+func newRefresher(config *refresherConfig, retriever notary.PassRetriever) *refresher {
+	return &refresher{
+		config:     config,
+		retriever:  retriever,
+		discovered: make(map[data.GUN]struct{}),
+		cursor:     "0",
+		reported:   make(map[data.GUN]map[data.RoleName]struct{}),
+	}
+}
+
+// Run refreshes every repository once per interval, until ctx is done
+func (r *refresher) Run(ctx context.Context) {
+	for {
+		r.RefreshAll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.config.interval):
+		}
+	}
+}
+
+// RefreshAll refreshes every repository, logging any errors
+func (r *refresher) RefreshAll() {
+	if r.config.changefeed {
+		if err := r.readChangefeed(); err != nil {
+			logrus.Errorf("unable to read the changefeed of %s: %v", r.config.serverURL, err)
+		}
+	}
+	guns := r.guns()
+	r.forgetRemoved(guns)
+	for _, gun := range guns {
+		if err := r.Refresh(gun); err != nil {
+			logrus.Errorf("unable to refresh %s: %v", gun, err)
+		}
+	}
+}
+
+// forgetRemoved removes the metrics of repositories which are no longer
+// refreshed, such as those deleted from the server
+func (r *refresher) forgetRemoved(guns []data.GUN) {
+	current := make(map[data.GUN]struct{}, len(guns))
+	for _, gun := range guns {
+		current[gun] = struct{}{}
+	}
+	for gun := range r.reported {
+		if _, ok := current[gun]; !ok {
+			r.report(gun, nil)
+		}
+	}
+}
+
+// report sets the seconds to expiry metric of gun to those of roles, removing
+// it for any roles which are no longer in the repository
+func (r *refresher) report(gun data.GUN, roles map[data.RoleName]time.Duration) {
+	for role := range r.reported[gun] {
+		if _, ok := roles[role]; !ok {
+			secondsToExpiry.DeleteLabelValues(gun.String(), role.String())
+		}
+	}
+	if len(roles) == 0 {
+		delete(r.reported, gun)
+		return
+	}
+	reported := make(map[data.RoleName]struct{}, len(roles))
+	for role, timeToExpiry := range roles {
+		secondsToExpiry.WithLabelValues(gun.String(), role.String()).Set(timeToExpiry.Seconds())
+		reported[role] = struct{}{}
+	}
+	r.reported[gun] = reported
+}
+
+// Refresh updates the expiry metrics of every role of gun, and re-signs the
+// roles which expire within the threshold and which the refresher has the
+// keys for
+func (r *refresher) Refresh(gun data.GUN) error {
+	repo, err := client.NewFileCachedRepository(
+		r.config.trustDir, gun, r.config.serverURL, r.config.transport, r.retriever, trustpinning.TrustPinConfig{})
+	if err != nil {
+		return err
+	}
+	repo.SetExpiryPolicy(r.config.expiryPolicy)
+
+	roles, err := repo.ListRoles()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var expiring []client.RoleWithSignatures
+	timesToExpiry := make(map[data.RoleName]time.Duration, len(roles))
+	for _, role := range roles {
+		if role.Expires.IsZero() {
+			// a delegation which has not been published yet
+			continue
+		}
+		timeToExpiry := role.Expires.Sub(now)
+		timesToExpiry[role.Name] = timeToExpiry
+		if timeToExpiry <= r.config.threshold {
+			expiring = append(expiring, role)
+		}
+	}
+	r.report(gun, timesToExpiry)
+
+	for _, role := range expiring {
+		switch {
+		case role.Name == data.CanonicalTimestampRole:
+			// the server holds the timestamp key, and re-signs it when it expires
+			continue
+		case role.Name == data.CanonicalSnapshotRole && !holdsKey(repo.GetCryptoService(), role.Role):
+			// the server holds the snapshot key, and re-signs it when it expires
+			continue
+		}
+		logrus.Infof("%s metadata of %s expires at %s, re-signing", role.Name, gun, role.Expires)
+		result := r.resign(repo, role.Name)
+		refreshesTotal.WithLabelValues(result).Inc()
+	}
+	return nil
+}
+
+// resign witnesses role and publishes it, returning the result.  Snapshot is
+// signed on every publish, so it does not need to be witnessed.
+func (r *refresher) resign(repo client.Repository, role data.RoleName) string {
+	// the witness is staged in a changelist of its own, so that changes which
+	// someone else has staged in the trust directory are neither published
+	// nor cleared, and a failed witness is not published with the next role
+	repo.SetChangelist(changelist.NewMemChangelist())
+	err := r.publishWitness(repo, role)
+	if err == nil {
+		return resultRefreshed
+	}
+	if sigErr, ok := err.(signed.ErrInsufficientSignatures); ok && sigErr.FoundKeys == 0 {
+		logrus.Debugf("no keys to re-sign %s metadata of %s", role, repo.GetGUN())
+		return resultMissingKey
+	}
+	logrus.Errorf("unable to re-sign %s metadata of %s: %v", role, repo.GetGUN(), err)
+	return resultFailed
+}
+
+func (r *refresher) publishWitness(repo client.Repository, role data.RoleName) error {
+	if role != data.CanonicalSnapshotRole {
+		if _, err := repo.Witness(role); err != nil {
+			return err
+		}
+	}
+	return repo.Publish()
+}
+
+// guns returns the configured and discovered repositories, sorted
+func (r *refresher) guns() []data.GUN {
+	all := make(map[data.GUN]struct{}, len(r.config.guns)+len(r.discovered))
+	for _, gun := range r.config.guns {
+		all[gun] = struct{}{}
+	}
+	for gun := range r.discovered {
+		all[gun] = struct{}{}
+	}
+	guns := make([]data.GUN, 0, len(all))
+	for gun := range all {
+		guns = append(guns, gun)
+	}
+	sort.Slice(guns, func(i, j int) bool { return guns[i] < guns[j] })
+	return guns
+}
+
+// readChangefeed adds the repositories in the server's changefeed since the
+// last call to the discovered ones, and forgets those which were deleted
+func (r *refresher) readChangefeed() error {
+	u, err := url.Parse(r.config.serverURL)
+	if err != nil {
+		return err
+	}
+	u.Path = path.Join(u.Path, "/v2/_trust/changefeed")
+	httpClient := &http.Client{Transport: r.config.transport}
+
+	for {
+		query := url.Values{}
+		query.Set("change_id", r.cursor)
+		query.Set("records", strconv.Itoa(notary.DefaultPageSize))
+		u.RawQuery = query.Encode()
+
+		changes, err := getChanges(httpClient, u.String())
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if change.Category == changeCategoryDeletion {
+				delete(r.discovered, data.GUN(change.GUN))
+			} else {
+				r.discovered[data.GUN(change.GUN)] = struct{}{}
+			}
+			r.cursor = change.ID
+		}
+		if len(changes) < notary.DefaultPageSize {
+			return nil
+		}
+	}
+}
+
+func getChanges(httpClient *http.Client, u string) ([]storage.Change, error) {
+	resp, err := httpClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with %d", u, resp.StatusCode)
+	}
+	out, err := ioutil.ReadAll(io.LimitReader(resp.Body, notary.MaxDownloadSize))
+	if err != nil {
+		return nil, err
+	}
+	var changefeed changefeedResponse
+	if err := json.Unmarshal(out, &changefeed); err != nil {
+		return nil, fmt.Errorf("invalid changefeed response from %s: %v", u, err)
+	}
+	return changefeed.Records, nil
+}
+
+// holdsKey returns whether cs has any of role's keys.  It does not work for
+// root, whose key IDs are those of certificates.
+func holdsKey(cs signed.CryptoService, role data.Role) bool {
+	for _, keyID := range role.KeyIDs {
+		if cs.GetKey(keyID) != nil {
+			return true
+		}
+	}
+	return false
+}
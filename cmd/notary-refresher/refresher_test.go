@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/server"
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+const testGUN data.GUN = "docker.com/notary"
+
+var retriever = passphrase.ConstantRetriever("pass")
+
+func setupServer() *httptest.Server {
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, storage.NewMemStorage())
+	ctx = context.WithValue(ctx, notary.CtxKeyKeyAlgo, data.ECDSAKey)
+
+	// Eat the logs instead of spewing them out
+	var b bytes.Buffer
+	l := logrus.New()
+	l.Out = &b
+	ctx = ctxu.WithLogger(ctx, logrus.NewEntry(l))
+
+	cryptoService := cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(retriever))
+	return httptest.NewServer(server.RootHandler(ctx, nil, cryptoService, nil, nil, nil))
+}
+
+// publishRepo publishes a new repository to the server, whose targets expire
+// in an hour
+func publishRepo(t *testing.T, trustDir, serverURL string, gun data.GUN) {
+	repo, err := client.NewFileCachedRepository(
+		trustDir, gun, serverURL, http.DefaultTransport, retriever, trustpinning.TrustPinConfig{})
+	require.NoError(t, err)
+	repo.SetExpiryPolicy(data.NewExpiryPolicy(data.ExpiryRule{Role: data.CanonicalTargetsRole, Expiry: time.Hour}))
+	rootKey, err := repo.GetCryptoService().Create(data.CanonicalRootRole, gun, data.ECDSAKey)
+	require.NoError(t, err)
+	require.NoError(t, repo.Initialize([]string{rootKey.ID()}))
+	require.NoError(t, repo.Publish())
+}
+
+func testConfig(trustDir, serverURL string, guns ...data.GUN) *refresherConfig {
+	return &refresherConfig{
+		trustDir:  trustDir,
+		serverURL: serverURL,
+		transport: http.DefaultTransport,
+		guns:      guns,
+		threshold: 24 * time.Hour,
+		interval:  time.Hour,
+	}
+}
+
+func roleExpiries(t *testing.T, trustDir, serverURL string, gun data.GUN) map[data.RoleName]time.Time {
+	repo, err := client.NewFileCachedRepository(
+		trustDir, gun, serverURL, http.DefaultTransport, retriever, trustpinning.TrustPinConfig{})
+	require.NoError(t, err)
+	roles, err := repo.ListRoles()
+	require.NoError(t, err)
+	expiries := make(map[data.RoleName]time.Time)
+	for _, role := range roles {
+		expiries[role.Name] = role.Expires
+	}
+	return expiries
+}
+
+func refreshes(t *testing.T, result string) float64 {
+	m := &dto.Metric{}
+	require.NoError(t, refreshesTotal.WithLabelValues(result).Write(m))
+	return m.GetCounter().GetValue()
+}
+
+func requireSecondsToExpiry(t *testing.T, role data.RoleName, expected time.Duration) {
+	m := &dto.Metric{}
+	require.NoError(t, secondsToExpiry.WithLabelValues(testGUN.String(), role.String()).Write(m))
+	require.InDelta(t, expected.Seconds(), m.GetGauge().GetValue(), time.Minute.Seconds())
+}
+
+// stageTarget stages a target in the changelist of gun in trustDir, as someone
+// using the trust directory might, and returns the staged changes
+func stageTarget(t *testing.T, trustDir, serverURL string, gun data.GUN) []changelist.Change {
+	repo, err := client.NewFileCachedRepository(
+		trustDir, gun, serverURL, http.DefaultTransport, retriever, trustpinning.TrustPinConfig{})
+	require.NoError(t, err)
+	target, err := client.NewTarget("staged", "../../fixtures/root-ca.crt", nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddTarget(target))
+	cl, err := repo.GetChangelist()
+	require.NoError(t, err)
+	require.Len(t, cl.List(), 1)
+	return cl.List()
+}
+
+func requireStaged(t *testing.T, trustDir, serverURL string, gun data.GUN, expected []changelist.Change) {
+	repo, err := client.NewFileCachedRepository(
+		trustDir, gun, serverURL, http.DefaultTransport, retriever, trustpinning.TrustPinConfig{})
+	require.NoError(t, err)
+	cl, err := repo.GetChangelist()
+	require.NoError(t, err)
+	require.Equal(t, expected, cl.List())
+}
+
+func TestRefreshResignsRolesNearExpiry(t *testing.T) {
+	ts := setupServer()
+	defer ts.Close()
+	trustDir, err := ioutil.TempDir("", "notary-refresher-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(trustDir)
+
+	publishRepo(t, trustDir, ts.URL, testGUN)
+	before := roleExpiries(t, trustDir, ts.URL, testGUN)
+	staged := stageTarget(t, trustDir, ts.URL, testGUN)
+
+	refreshed, failed := refreshes(t, resultRefreshed), refreshes(t, resultFailed)
+	r := newRefresher(testConfig(trustDir, ts.URL, testGUN), retriever)
+	require.NoError(t, r.Refresh(testGUN))
+	requireSecondsToExpiry(t, data.CanonicalTargetsRole, time.Hour)
+	require.Equal(t, refreshed+1, refreshes(t, resultRefreshed))
+	require.Equal(t, failed, refreshes(t, resultFailed))
+
+	// the change staged in the trust directory was neither published nor cleared
+	requireStaged(t, trustDir, ts.URL, testGUN, staged)
+	repo, err := client.NewFileCachedRepository(
+		trustDir, testGUN, ts.URL, http.DefaultTransport, retriever, trustpinning.TrustPinConfig{})
+	require.NoError(t, err)
+	_, err = repo.GetTargetByName("staged")
+	require.Error(t, err)
+
+	// only targets was re-signed, without the policy it was published with
+	after := roleExpiries(t, trustDir, ts.URL, testGUN)
+	require.WithinDuration(t, data.DefaultExpires(data.CanonicalTargetsRole), after[data.CanonicalTargetsRole], time.Minute)
+	require.Equal(t, before[data.CanonicalRootRole], after[data.CanonicalRootRole])
+
+	// nothing is near expiry any more
+	require.NoError(t, r.Refresh(testGUN))
+	requireSecondsToExpiry(t, data.CanonicalTargetsRole, time.Until(data.DefaultExpires(data.CanonicalTargetsRole)))
+	require.Equal(t, refreshed+1, refreshes(t, resultRefreshed))
+
+	// with a longer threshold, root is re-signed too, and with a configured policy
+	r.config.threshold = 20 * 365 * 24 * time.Hour
+	r.config.expiryPolicy = data.NewExpiryPolicy(data.ExpiryRule{Expiry: 30 * 24 * time.Hour})
+	require.NoError(t, r.Refresh(testGUN))
+	after = roleExpiries(t, trustDir, ts.URL, testGUN)
+	for _, role := range []data.RoleName{data.CanonicalRootRole, data.CanonicalTargetsRole, data.CanonicalSnapshotRole} {
+		require.WithinDuration(t, time.Now().Add(30*24*time.Hour), after[role], time.Minute, role.String())
+	}
+	require.Equal(t, failed, refreshes(t, resultFailed))
+}
+
+func TestRefreshWithoutKeys(t *testing.T) {
+	ts := setupServer()
+	defer ts.Close()
+	trustDir, err := ioutil.TempDir("", "notary-refresher-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(trustDir)
+	otherDir, err := ioutil.TempDir("", "notary-refresher-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(otherDir)
+
+	publishRepo(t, trustDir, ts.URL, testGUN)
+	staged := stageTarget(t, otherDir, ts.URL, testGUN)
+
+	// a refresher with its own trust directory has none of the keys
+	missing, failed := refreshes(t, resultMissingKey), refreshes(t, resultFailed)
+	r := newRefresher(testConfig(otherDir, ts.URL, testGUN), retriever)
+	require.NoError(t, r.Refresh(testGUN))
+	requireSecondsToExpiry(t, data.CanonicalTargetsRole, time.Hour)
+	require.Equal(t, missing+1, refreshes(t, resultMissingKey))
+	require.Equal(t, failed, refreshes(t, resultFailed))
+
+	// the failed witness is not left in the changelist, and the change staged
+	// in the trust directory is not cleared
+	requireStaged(t, otherDir, ts.URL, testGUN, staged)
+
+	// nor is the metadata changed
+	expiries := roleExpiries(t, trustDir, ts.URL, testGUN)
+	require.WithinDuration(t, time.Now().Add(time.Hour), expiries[data.CanonicalTargetsRole], time.Minute)
+
+	// a repository which does not exist cannot be refreshed
+	require.Error(t, r.Refresh("docker.com/nope"))
+}
+
+func TestRefreshAllFromChangefeed(t *testing.T) {
+	ts := setupServer()
+	defer ts.Close()
+	trustDir, err := ioutil.TempDir("", "notary-refresher-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(trustDir)
+
+	publishRepo(t, trustDir, ts.URL, testGUN)
+	publishRepo(t, trustDir, ts.URL, "docker.com/other")
+
+	config := testConfig(trustDir, ts.URL, "docker.com/configured")
+	config.changefeed = true
+	r := newRefresher(config, retriever)
+
+	refreshed := refreshes(t, resultRefreshed)
+	r.RefreshAll()
+	require.Equal(t, []data.GUN{"docker.com/configured", "docker.com/notary", "docker.com/other"}, r.guns())
+	require.Equal(t, refreshed+2, refreshes(t, resultRefreshed))
+
+	// only new changes are read on the next run
+	cursor := r.cursor
+	require.NotEqual(t, "0", cursor)
+	require.NoError(t, r.readChangefeed())
+	require.NotEqual(t, cursor, r.cursor, "the refresh should have published new changes")
+
+	// the metrics of a repository deleted from the server are removed
+	require.NoError(t, client.DeleteTrustData(trustDir, "docker.com/other", ts.URL, http.DefaultTransport, true))
+	r.RefreshAll()
+	require.Equal(t, []data.GUN{"docker.com/configured", "docker.com/notary"}, r.guns())
+	require.False(t, secondsToExpiry.DeleteLabelValues("docker.com/other", data.CanonicalTargetsRole.String()))
+	require.True(t, secondsToExpiry.DeleteLabelValues(testGUN.String(), data.CanonicalTargetsRole.String()))
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/tls"
 	"fmt"
+	"net/http"
 	"path"
 	"strconv"
 	"strings"
@@ -17,10 +18,16 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/passphrase"
 	"github.com/theupdateframework/notary/server"
+	"github.com/theupdateframework/notary/server/expiry"
+	"github.com/theupdateframework/notary/server/replication"
 	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/server/webhook"
 	"github.com/theupdateframework/notary/signer/client"
 	"github.com/theupdateframework/notary/storage/rethinkdb"
+	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
 	"github.com/theupdateframework/notary/utils"
@@ -157,7 +164,9 @@ func getTrustService(configuration *viper.Viper, sFactory signerFactory,
 	}
 
 	keyAlgo := configuration.GetString("trust_service.key_algorithm")
-	if keyAlgo != data.ED25519Key && keyAlgo != data.ECDSAKey && keyAlgo != data.RSAKey {
+	switch keyAlgo {
+	case data.ED25519Key, data.ECDSAKey, data.ECDSAP384Key, data.RSAKey:
+	default:
 		return nil, "", fmt.Errorf("invalid key algorithm configured: %s", keyAlgo)
 	}
 
@@ -227,6 +236,143 @@ func getCacheConfig(configuration *viper.Viper) (current, consistent utils.Cache
 	return
 }
 
+// webhookSubscription is the configuration of a single entry in
+// webhooks.subscriptions
+type webhookSubscription struct {
+	Name        string   `mapstructure:"name"`
+	URL         string   `mapstructure:"url"`
+	Secret      string   `mapstructure:"secret"`
+	GUNPrefixes []string `mapstructure:"gun_prefixes"`
+}
+
+// Parses the optional webhooks configuration, returning a dispatcher which
+// delivers the changefeed of the given store to each subscription, or nil
+// if no subscriptions are configured.  Delivery cursors are kept in memory
+// unless a cursor_dir is given, in which case deliveries resume from where
+// they left off when the server restarts.
+func getWebhooks(configuration *viper.Viper, store storage.MetaStore) (*webhook.Dispatcher, error) {
+	var subs []webhookSubscription
+	if err := configuration.MarshalKey("webhooks.subscriptions", &subs); err != nil {
+		return nil, fmt.Errorf("invalid webhooks configuration: %v", err)
+	}
+	if len(subs) == 0 {
+		return nil, nil
+	}
+
+	subscriptions := make([]webhook.Subscription, 0, len(subs))
+	names := make(map[string]bool)
+	for _, sub := range subs {
+		if sub.Name == "" || sub.URL == "" {
+			return nil, fmt.Errorf("each webhook subscription requires a name and a url")
+		}
+		if names[sub.Name] {
+			return nil, fmt.Errorf("duplicate webhook subscription name %s", sub.Name)
+		}
+		names[sub.Name] = true
+		subscriptions = append(subscriptions, webhook.Subscription{
+			Name:        sub.Name,
+			URL:         sub.URL,
+			Secret:      sub.Secret,
+			GUNPrefixes: sub.GUNPrefixes,
+		})
+	}
+
+	var cursors webhook.CursorStore = webhook.NewMemoryCursorStore()
+	if cursorDir := configuration.GetString("webhooks.cursor_dir"); cursorDir != "" {
+		fileCursors, err := webhook.NewFileCursorStore(cursorDir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to use webhooks.cursor_dir: %v", err)
+		}
+		cursors = fileCursors
+	}
+
+	dispatcher := webhook.NewDispatcher(store, cursors, subscriptions...)
+	if interval := configuration.GetString("webhooks.poll_interval"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("webhooks.poll_interval must be a positive duration, such as 5s")
+		}
+		dispatcher.PollInterval = d
+	}
+	if retries := configuration.GetString("webhooks.max_retries"); retries != "" {
+		n, err := strconv.Atoi(retries)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("webhooks.max_retries must be a non-negative integer")
+		}
+		dispatcher.MaxRetries = n
+	}
+	return dispatcher, nil
+}
+
+// Parses the optional replication configuration, returning a replicator which
+// copies metadata from the configured primary server into the given store,
+// or nil if this server is not a replica
+func getReplication(configuration *viper.Viper, store storage.MetaStore) (*replication.Replicator, error) {
+	primary := configuration.GetString("replication.primary")
+	if primary == "" {
+		return nil, nil
+	}
+
+	rootCA := utils.GetPathRelativeToConfig(configuration, "replication.tls_ca_file")
+	clientCert := utils.GetPathRelativeToConfig(configuration, "replication.tls_client_cert")
+	clientKey := utils.GetPathRelativeToConfig(configuration, "replication.tls_client_key")
+	if clientCert == "" && clientKey != "" || clientCert != "" && clientKey == "" {
+		return nil, fmt.Errorf("either pass both client key and cert, or neither")
+	}
+	tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
+		CAFile:             rootCA,
+		CertFile:           clientCert,
+		KeyFile:            clientKey,
+		ExclusiveRootPools: rootCA != "",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to configure TLS to the replication primary: %s", err.Error())
+	}
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}}
+
+	var cursors webhook.CursorStore = webhook.NewMemoryCursorStore()
+	if cursorDir := configuration.GetString("replication.cursor_dir"); cursorDir != "" {
+		fileCursors, err := webhook.NewFileCursorStore(cursorDir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to use replication.cursor_dir: %v", err)
+		}
+		cursors = fileCursors
+	}
+
+	replicator, err := replication.NewReplicator(primary, client, store, cursors)
+	if err != nil {
+		return nil, err
+	}
+	if wait := configuration.GetString("replication.wait"); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("replication.wait must be a duration of at least 1s, such as 30s")
+		}
+		replicator.Wait = d
+	}
+	return replicator, nil
+}
+
+// Parses the optional expiry_monitor configuration, returning a monitor which
+// scans the given store every expiry_monitor.interval, or nil if no interval
+// is configured
+func getExpiryMonitor(configuration *viper.Viper, store storage.MetaStore) (*expiry.Monitor, error) {
+	interval := configuration.GetString("expiry_monitor.interval")
+	if interval == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("expiry_monitor.interval must be a positive duration, such as 10m")
+	}
+	monitor := expiry.NewMonitor(store)
+	monitor.Interval = d
+	return monitor, nil
+}
+
 func parseServerConfig(configFilePath string, hRegister healthRegister, doBootstrap bool) (context.Context, server.Config, error) {
 	config := viper.New()
 	utils.SetupViper(config, envPrefix)
@@ -257,11 +403,27 @@ func parseServerConfig(configFilePath string, hRegister healthRegister, doBootst
 	}
 	utils.SetUpBugsnag(bugsnagConf)
 
-	trust, keyAlgo, err := getTrustService(config, getNotarySigner, hRegister)
+	var (
+		trust   signed.CryptoService
+		keyAlgo = data.ED25519Key
+	)
+	if config.GetString("replication.primary") != "" {
+		// A replica holds no signing keys, and forwards anything which needs
+		// signing to its primary
+		trust = cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphrase.ConstantRetriever("")))
+	} else {
+		trust, keyAlgo, err = getTrustService(config, getNotarySigner, hRegister)
+		if err != nil {
+			return nil, server.Config{}, err
+		}
+	}
+	ctx = context.WithValue(ctx, notary.CtxKeyKeyAlgo, keyAlgo)
+
+	expiryPolicy, err := utils.ParseExpiryPolicy(config)
 	if err != nil {
 		return nil, server.Config{}, err
 	}
-	ctx = context.WithValue(ctx, notary.CtxKeyKeyAlgo, keyAlgo)
+	ctx = context.WithValue(ctx, notary.CtxKeyExpiryPolicy, expiryPolicy)
 
 	store, err := getStore(config, hRegister, doBootstrap)
 	if err != nil {
@@ -274,6 +436,21 @@ func parseServerConfig(configFilePath string, hRegister healthRegister, doBootst
 		return nil, server.Config{}, err
 	}
 
+	webhooks, err := getWebhooks(config, store)
+	if err != nil {
+		return nil, server.Config{}, err
+	}
+
+	replicator, err := getReplication(config, store)
+	if err != nil {
+		return nil, server.Config{}, err
+	}
+
+	expiryMonitor, err := getExpiryMonitor(config, store)
+	if err != nil {
+		return nil, server.Config{}, err
+	}
+
 	httpAddr, tlsConfig, err := getAddrAndTLSConfig(config)
 	if err != nil {
 		return nil, server.Config{}, err
@@ -288,5 +465,8 @@ func parseServerConfig(configFilePath string, hRegister healthRegister, doBootst
 		RepoPrefixes:                 prefixes,
 		CurrentCacheControlConfig:    currentCache,
 		ConsistentCacheControlConfig: consistentCache,
+		Webhooks:                     webhooks,
+		Replication:                  replicator,
+		ExpiryMonitor:                expiryMonitor,
 	}, nil
 }
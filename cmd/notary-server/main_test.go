@@ -5,7 +5,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -17,10 +20,12 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/theupdateframework/notary"
 	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/server/webhook"
 	"github.com/theupdateframework/notary/signer/client"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
 	"github.com/theupdateframework/notary/utils"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -170,6 +175,32 @@ func TestGetTrustServiceInvalidKeyAlgorithm(t *testing.T) {
 	require.Equal(t, 0, registerCalled)
 }
 
+// Every key algorithm the signer can generate is accepted for a remote trust
+// service.
+func TestGetTrustServiceValidKeyAlgorithm(t *testing.T) {
+	configTemplate := `
+	{
+		"trust_service": {
+			"type": "remote",
+			"hostname": "blah",
+			"port": "1234",
+			"key_algorithm": "%s"
+		}
+	}`
+	var fakeNewSigner = func(_, _ string, _ *tls.Config) (*client.NotarySigner, error) {
+		return &client.NotarySigner{}, nil
+	}
+	var registerCalled = 0
+
+	for _, keyAlgo := range []string{data.ECDSAKey, data.ECDSAP384Key, data.ED25519Key, data.RSAKey} {
+		_, algo, err := getTrustService(configure(fmt.Sprintf(configTemplate, keyAlgo)),
+			fakeNewSigner, fakeRegisterer(&registerCalled))
+		require.NoError(t, err)
+		require.Equal(t, keyAlgo, algo)
+	}
+	require.Equal(t, 4, registerCalled)
+}
+
 // template to be used for testing TLS parsing with the trust service
 var trustTLSConfigTemplate = `
 	{
@@ -404,6 +435,169 @@ func TestGetGUNPRefixes(t *testing.T) {
 	}
 }
 
+func TestGetWebhooks(t *testing.T) {
+	store := storage.NewMemStorage()
+	for _, empty := range []string{`{}`, `{"webhooks": {}}`, `{"webhooks": {"subscriptions": []}}`} {
+		dispatcher, err := getWebhooks(configure(empty), store)
+		require.NoError(t, err)
+		require.Nil(t, dispatcher)
+	}
+
+	invalids := []string{
+		`{"webhooks": {"subscriptions": "nope"}}`,
+		`{"webhooks": {"subscriptions": [{"url": "http://localhost"}]}}`,
+		`{"webhooks": {"subscriptions": [{"name": "ci"}]}}`,
+		`{"webhooks": {"subscriptions": [{"name": "ci", "url": "http://a"}, {"name": "ci", "url": "http://b"}]}}`,
+		`{"webhooks": {"poll_interval": "often", "subscriptions": [{"name": "ci", "url": "http://a"}]}}`,
+		`{"webhooks": {"poll_interval": "-1s", "subscriptions": [{"name": "ci", "url": "http://a"}]}}`,
+		`{"webhooks": {"max_retries": -1, "subscriptions": [{"name": "ci", "url": "http://a"}]}}`,
+	}
+	for _, invalid := range invalids {
+		_, err := getWebhooks(configure(invalid), store)
+		require.Error(t, err, "expected error with %s", invalid)
+	}
+}
+
+func TestGetWebhooksDelivers(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "webhook-cursors")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	var received []*http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r)
+	}))
+	defer ts.Close()
+
+	store := storage.NewMemStorage()
+	dispatcher, err := getWebhooks(configure(fmt.Sprintf(`{"webhooks": {
+		"cursor_dir": "%s",
+		"poll_interval": "1m",
+		"max_retries": 2,
+		"subscriptions": [
+			{"name": "ci", "url": "%s", "secret": "s3cret", "gun_prefixes": ["docker.io/library/"]}
+		]}}`, tempDir, ts.URL)), store)
+	require.NoError(t, err)
+	require.NotNil(t, dispatcher)
+	require.Equal(t, time.Minute, dispatcher.PollInterval)
+	require.Equal(t, 2, dispatcher.MaxRetries)
+	require.NoError(t, dispatcher.DeliverPending(context.Background()))
+
+	for _, gun := range []data.GUN{"docker.io/library/alpine", "docker.io/other/alpine"} {
+		require.NoError(t, store.UpdateCurrent(gun, storage.MetaUpdate{
+			Role: data.CanonicalTimestampRole, Version: 1, Data: []byte("1")}))
+	}
+	require.NoError(t, dispatcher.DeliverPending(context.Background()))
+	require.Len(t, received, 1)
+	require.NotEmpty(t, received[0].Header.Get(webhook.SignatureHeader))
+
+	// the cursor was persisted to the configured directory
+	files, err := ioutil.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
+
+func TestGetReplication(t *testing.T) {
+	store := storage.NewMemStorage()
+	for _, empty := range []string{`{}`, `{"replication": {}}`, `{"replication": {"primary": ""}}`} {
+		replicator, err := getReplication(configure(empty), store)
+		require.NoError(t, err)
+		require.Nil(t, replicator)
+	}
+
+	invalids := []string{
+		`{"replication": {"primary": "notary.example.com"}}`,
+		`{"replication": {"primary": "https://notary.example.com", "wait": "forever"}}`,
+		`{"replication": {"primary": "https://notary.example.com", "wait": "10ms"}}`,
+		`{"replication": {"primary": "https://notary.example.com", "tls_client_cert": "../../fixtures/notary-server.crt"}}`,
+		`{"replication": {"primary": "https://notary.example.com", "tls_ca_file": "/does/not/exist"}}`,
+	}
+	for _, invalid := range invalids {
+		_, err := getReplication(configure(invalid), store)
+		require.Error(t, err, "expected error with %s", invalid)
+	}
+
+	replicator, err := getReplication(configure(`{"replication": {
+		"primary": "https://notary.example.com",
+		"tls_ca_file": "../../fixtures/root-ca.crt",
+		"wait": "10s"}}`), store)
+	require.NoError(t, err)
+	require.NotNil(t, replicator)
+	require.Equal(t, "notary.example.com", replicator.Primary().Host)
+	require.Equal(t, 10*time.Second, replicator.Wait)
+}
+
+func TestGetExpiryMonitor(t *testing.T) {
+	store := storage.NewMemStorage()
+	for _, empty := range []string{`{}`, `{"expiry_monitor": {}}`, `{"expiry_monitor": {"interval": ""}}`} {
+		monitor, err := getExpiryMonitor(configure(empty), store)
+		require.NoError(t, err)
+		require.Nil(t, monitor)
+	}
+
+	for _, invalid := range []string{
+		`{"expiry_monitor": {"interval": "often"}}`,
+		`{"expiry_monitor": {"interval": "0s"}}`,
+		`{"expiry_monitor": {"interval": "-10m"}}`,
+	} {
+		_, err := getExpiryMonitor(configure(invalid), store)
+		require.Error(t, err, "expected error with %s", invalid)
+	}
+
+	monitor, err := getExpiryMonitor(configure(`{"expiry_monitor": {"interval": "5m"}}`), store)
+	require.NoError(t, err)
+	require.NotNil(t, monitor)
+	require.Equal(t, 5*time.Minute, monitor.Interval)
+}
+
+// A replica does not need a trust service, since it never signs anything
+func TestParseReplicaConfigWithoutTrustService(t *testing.T) {
+	config := `{
+		"server": {"http_addr": ":1234"},
+		"storage": {"backend": "memory"},
+		"replication": {"primary": "https://notary.example.com"}
+	}`
+	tempDir, err := ioutil.TempDir("", "replica-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	configPath := filepath.Join(tempDir, "server-config.json")
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0644))
+
+	_, serverConfig, err := parseServerConfig(configPath, fakeRegisterer(new(int)), false)
+	require.NoError(t, err)
+	require.NotNil(t, serverConfig.Replication)
+	require.NotNil(t, serverConfig.Trust)
+	require.Empty(t, serverConfig.Trust.ListAllKeys())
+}
+
+// The expiry policy is passed to the handlers through the context
+func TestParseServerConfigExpiryPolicy(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "expiry-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	configPath := filepath.Join(tempDir, "server-config.json")
+
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(`{
+		"server": {"http_addr": ":1234"},
+		"trust_service": {"type": "local"},
+		"storage": {"backend": "memory"},
+		"expiry": [{"role": "timestamp", "expiry": "1h"}]
+	}`), 0644))
+	ctx, _, err := parseServerConfig(configPath, fakeRegisterer(new(int)), false)
+	require.NoError(t, err)
+	require.Equal(t, data.NewExpiryPolicy(data.ExpiryRule{Role: data.CanonicalTimestampRole, Expiry: time.Hour}),
+		ctx.Value(notary.CtxKeyExpiryPolicy))
+
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(`{
+		"server": {"http_addr": ":1234"},
+		"trust_service": {"type": "local"},
+		"storage": {"backend": "memory"},
+		"expiry": [{"role": "timestamp", "expiry": "-1h"}]
+	}`), 0644))
+	_, _, err = parseServerConfig(configPath, fakeRegisterer(new(int)), false)
+	require.Error(t, err)
+}
+
 // For sanity, make sure we can always parse the sample config
 func TestSampleConfig(t *testing.T) {
 	var registerCalled = 0
@@ -2,9 +2,11 @@ package main
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -22,7 +24,10 @@ import (
 	pb "github.com/theupdateframework/notary/proto"
 	"github.com/theupdateframework/notary/signer"
 	"github.com/theupdateframework/notary/signer/api"
+	"github.com/theupdateframework/notary/signer/audit"
 	"github.com/theupdateframework/notary/signer/keydbstore"
+	"github.com/theupdateframework/notary/signer/kms"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/storage"
 	"github.com/theupdateframework/notary/storage/rethinkdb"
 	"github.com/theupdateframework/notary/trustmanager"
@@ -69,8 +74,14 @@ func parseSignerConfig(configFilePath string, doBootstrap bool) (signer.Config,
 		return signer.Config{}, err
 	}
 
+	// parse the default key policy
+	defaultPolicy, err := getDefaultPolicy(config)
+	if err != nil {
+		return signer.Config{}, err
+	}
+
 	// setup the cryptoservices
-	cryptoServices, err := setUpCryptoservices(config, notary.NotarySupportedBackends, doBootstrap)
+	cryptoServices, auditStore, err := setUpCryptoservices(config, notary.NotarySupportedBackends, doBootstrap)
 	if err != nil {
 		return signer.Config{}, err
 	}
@@ -79,6 +90,9 @@ func parseSignerConfig(configFilePath string, doBootstrap bool) (signer.Config,
 		GRPCAddr:       grpcAddr,
 		TLSConfig:      tlsConfig,
 		CryptoServices: cryptoServices,
+		AuditLog:       audit.NewLog(auditStore),
+		Policy:         policy.NewEnforcer(defaultPolicy),
+		MetricsAddr:    config.GetString("server.metrics_addr"),
 	}, nil
 }
 
@@ -99,29 +113,39 @@ func passphraseRetriever(keyName, alias string, createNew bool, attempts int) (p
 }
 
 // Reads the configuration file for storage setup, and sets up the cryptoservice
-// mapping
+// mapping and the store for the audit log
 func setUpCryptoservices(configuration *viper.Viper, allowedBackends []string, doBootstrap bool) (
-	signer.CryptoServiceIndex, error) {
+	signer.CryptoServiceIndex, audit.Store, error) {
 	backend := configuration.GetString("storage.backend")
 
 	if !tufutils.StrSliceContains(allowedBackends, backend) {
-		return nil, fmt.Errorf("%s is not an allowed backend, must be one of: %s", backend, allowedBackends)
+		return nil, nil, fmt.Errorf("%s is not an allowed backend, must be one of: %s", backend, allowedBackends)
+	}
+
+	keyPolicies, err := getKeyPolicies(configuration)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var keyService signed.CryptoService
+	var auditStore audit.Store
+	// the key database stores can keep a policy for each key
+	var policySetter keyPolicySetter
+	algorithms := []string{data.ED25519Key, data.ECDSAKey, data.ECDSAP384Key}
 	switch backend {
 	case notary.MemoryBackend:
 		keyService = cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(
 			passphrase.ConstantRetriever("memory-db-ignore")))
+		auditStore = audit.NewMemoryStore()
 	case notary.RethinkDBBackend:
 		var sess *gorethink.Session
 		storeConfig, err := utils.ParseRethinkDBStorage(configuration)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		defaultAlias, err := getDefaultAlias(configuration)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		tlsOpts := tlsconfig.Options{
 			CAFile:             storeConfig.CA,
@@ -135,10 +159,12 @@ func setUpCryptoservices(configuration *viper.Viper, allowedBackends []string, d
 			sess, err = rethinkdb.UserConnection(tlsOpts, storeConfig.Source, storeConfig.Username, storeConfig.Password)
 		}
 		if err != nil {
-			return nil, fmt.Errorf("Error starting %s driver: %s", backend, err.Error())
+			return nil, nil, fmt.Errorf("Error starting %s driver: %s", backend, err.Error())
 		}
 		s := keydbstore.NewRethinkDBKeyStore(storeConfig.DBName, storeConfig.Username, storeConfig.Password, passphraseRetriever, defaultAlias, sess)
 		health.RegisterPeriodicFunc("DB operational", time.Minute, s.CheckHealth)
+		auditStore = s
+		policySetter = s
 
 		if doBootstrap {
 			keyService = s
@@ -148,21 +174,69 @@ func setUpCryptoservices(configuration *viper.Viper, allowedBackends []string, d
 	case notary.MySQLBackend, notary.SQLiteBackend, notary.PostgresBackend:
 		storeConfig, err := utils.ParseSQLStorage(configuration)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		defaultAlias, err := getDefaultAlias(configuration)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		dbStore, err := keydbstore.NewSQLKeyDBStore(
 			passphraseRetriever, defaultAlias, storeConfig.Backend, storeConfig.Source)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create a new keydbstore: %v", err)
+			return nil, nil, fmt.Errorf("failed to create a new keydbstore: %v", err)
 		}
 
 		health.RegisterPeriodicFunc(
 			"DB operational", time.Minute, dbStore.HealthCheck)
 		keyService = keydbstore.NewCachedKeyService(dbStore)
+		auditStore = dbStore
+		policySetter = dbStore
+	case notary.KMSBackend:
+		storeConfig, err := utils.ParseKMSStorage(configuration)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile:             storeConfig.CA,
+			CertFile:           storeConfig.Cert,
+			KeyFile:            storeConfig.Key,
+			ExclusiveRootPools: storeConfig.CA != "",
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to configure TLS to the KMS: %s", err.Error())
+		}
+		client := &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+		kmsService, err := kms.NewCryptoService(storeConfig.Source, client, storeConfig.Token)
+		if err != nil {
+			return nil, nil, err
+		}
+		health.RegisterPeriodicFunc("KMS operational", time.Minute, kmsService.CheckHealth)
+		keyService = kmsService
+		// the KMS cannot create ED25519 keys
+		algorithms = kms.SupportedAlgorithms
+		// the signer has no database of its own to keep the audit log in
+		logrus.Warn("the audit log is kept in memory when using the KMS backend, and does not survive restarts")
+		auditStore = audit.NewMemoryStore()
+	case notary.PKCS11Backend:
+		storeConfig, err := utils.ParsePKCS11Storage(configuration)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyService, err = setUpPKCS11(*storeConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the token cannot create ED25519 keys, but can create RSA keys
+		algorithms = pkcs11SupportedAlgorithms
+		// the signer has no database of its own to keep the audit log in
+		logrus.Warn("the audit log is kept in memory when using the PKCS#11 backend, and does not survive restarts")
+		auditStore = audit.NewMemoryStore()
 	}
 
 	if doBootstrap {
@@ -173,10 +247,87 @@ func setUpCryptoservices(configuration *viper.Viper, allowedBackends []string, d
 		os.Exit(0)
 	}
 
+	if len(keyPolicies) > 0 {
+		if policySetter == nil {
+			return nil, nil, fmt.Errorf("key_policies cannot be used with the %s backend, which cannot store a policy for each key", backend)
+		}
+		if err := setKeyPolicies(keyPolicies, policySetter); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	cryptoServices := make(signer.CryptoServiceIndex)
-	cryptoServices[data.ED25519Key] = keyService
-	cryptoServices[data.ECDSAKey] = keyService
-	return cryptoServices, nil
+	for _, algorithm := range algorithms {
+		cryptoServices[algorithm] = keyService
+	}
+	return cryptoServices, auditStore, nil
+}
+
+// getDefaultPolicy returns the policy for keys which do not have their own, or
+// nil if there is none
+func getDefaultPolicy(configuration *viper.Viper) (*policy.KeyPolicy, error) {
+	if !configuration.IsSet("policy") {
+		return nil, nil
+	}
+	defaultPolicy := &policy.KeyPolicy{
+		AllowedGUNPrefixes:     configuration.GetStringSlice("policy.allowed_gun_prefixes"),
+		MaxSignaturesPerMinute: configuration.GetInt("policy.max_signatures_per_minute"),
+		CheckContent:           configuration.GetBool("policy.check_content"),
+	}
+	for _, role := range configuration.GetStringSlice("policy.allowed_roles") {
+		defaultPolicy.AllowedRoles = append(defaultPolicy.AllowedRoles, data.RoleName(role))
+	}
+	if err := defaultPolicy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid key policy: %v", err)
+	}
+	return defaultPolicy, nil
+}
+
+// keyPolicySetter stores the policy of an individual key
+type keyPolicySetter interface {
+	SetKeyPolicy(keyID string, keyPolicy *policy.KeyPolicy) error
+}
+
+// getKeyPolicies returns the policies of individual keys, by key ID.  A key
+// whose policy is null has its own policy removed, so that the default policy
+// applies to it again.
+func getKeyPolicies(configuration *viper.Viper) (map[string]*policy.KeyPolicy, error) {
+	keyPolicies := make(map[string]*policy.KeyPolicy)
+	for keyID, value := range configuration.GetStringMap("key_policies") {
+		// the policies are in the same format as their JSON encoding
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy for key %s: %v", keyID, err)
+		}
+		var keyPolicy *policy.KeyPolicy
+		if err := json.Unmarshal(encoded, &keyPolicy); err != nil {
+			return nil, fmt.Errorf("invalid policy for key %s: %v", keyID, err)
+		}
+		if keyPolicy != nil {
+			if err := keyPolicy.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid policy for key %s: %v", keyID, err)
+			}
+		}
+		keyPolicies[keyID] = keyPolicy
+	}
+	return keyPolicies, nil
+}
+
+// setKeyPolicies stores the policies of individual keys.  Keys which are not
+// in the store are skipped, as they may have been deleted since the
+// configuration was written.
+func setKeyPolicies(keyPolicies map[string]*policy.KeyPolicy, setter keyPolicySetter) error {
+	for keyID, keyPolicy := range keyPolicies {
+		err := setter.SetKeyPolicy(keyID, keyPolicy)
+		if _, ok := err.(trustmanager.ErrKeyNotFound); ok {
+			logrus.Warnf("not setting the policy of key %s, which does not exist", keyID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to set the policy of key %s: %v", keyID, err)
+		}
+	}
+	return nil
 }
 
 func getDefaultAlias(configuration *viper.Viper) (string, error) {
@@ -199,9 +350,12 @@ func setupGRPCServer(signerConfig signer.Config) (*grpc.Server, net.Listener, er
 	//RPC server setup
 	kms := &api.KeyManagementServer{
 		CryptoServices: signerConfig.CryptoServices,
+		AuditLog:       signerConfig.AuditLog,
 	}
 	ss := &api.SignerServer{
 		CryptoServices: signerConfig.CryptoServices,
+		AuditLog:       signerConfig.AuditLog,
+		Policy:         signerConfig.Policy,
 	}
 	hs := ghealth.NewServer()
 
@@ -12,6 +12,7 @@ import (
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary/utils"
 	"github.com/theupdateframework/notary/version"
@@ -83,6 +84,10 @@ func main() {
 		log.Println("RPC server listening on", signerConfig.GRPCAddr)
 	}
 
+	if signerConfig.MetricsAddr != "" {
+		go metricsServer(signerConfig.MetricsAddr)
+	}
+
 	c := utils.SetupSignalTrap(utils.LogLevelSignalHandle)
 	if c != nil {
 		defer signal.Stop(c)
@@ -109,3 +114,13 @@ func debugServer(addr string) {
 		logrus.Fatalf("error listening on debug interface: %v", err)
 	}
 }
+
+// metricsServer serves the prometheus metrics on addr
+func metricsServer(addr string) {
+	logrus.Infof("Metrics server listening on %s", addr)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", prometheus.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logrus.Fatalf("error listening on metrics interface: %v", err)
+	}
+}
@@ -14,7 +14,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/theupdateframework/notary"
 	"github.com/theupdateframework/notary/signer"
+	"github.com/theupdateframework/notary/signer/audit"
 	"github.com/theupdateframework/notary/signer/keydbstore"
+	"github.com/theupdateframework/notary/signer/kms"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/testutils"
@@ -86,7 +89,7 @@ func TestSetupCryptoServicesDBStoreNoDefaultAlias(t *testing.T) {
 	tmpFile.Close()
 	defer os.Remove(tmpFile.Name())
 
-	_, err = setUpCryptoservices(
+	_, _, err = setUpCryptoservices(
 		configure(fmt.Sprintf(
 			`{"storage": {"backend": "%s", "db_url": "%s"}}`,
 			notary.SQLiteBackend, tmpFile.Name())),
@@ -98,7 +101,7 @@ func TestSetupCryptoServicesDBStoreNoDefaultAlias(t *testing.T) {
 
 // If a default alias is not provided to a rethinkdb backend, an error is returned.
 func TestSetupCryptoServicesRethinkDBStoreNoDefaultAlias(t *testing.T) {
-	_, err := setUpCryptoservices(
+	_, _, err := setUpCryptoservices(
 		configure(fmt.Sprintf(
 			`{"storage": {
 				"backend": "%s",
@@ -119,7 +122,7 @@ func TestSetupCryptoServicesRethinkDBStoreNoDefaultAlias(t *testing.T) {
 
 func TestSetupCryptoServicesRethinkDBStoreConnectionFails(t *testing.T) {
 	// We don't have a rethink instance up, so the Connection() call will fail
-	_, err := setUpCryptoservices(
+	_, _, err := setUpCryptoservices(
 		configure(fmt.Sprintf(
 			`{"storage": {
 				"backend": "%s",
@@ -160,14 +163,15 @@ func TestSetupCryptoServicesDBStoreSuccess(t *testing.T) {
 	db.Model(&gormKey).Count(&count)
 	require.Equal(t, 0, count)
 
-	cryptoServices, err := setUpCryptoservices(
+	cryptoServices, auditStore, err := setUpCryptoservices(
 		configure(fmt.Sprintf(
 			`{"storage": {"backend": "%s", "db_url": "%s"},
 			"default_alias": "timestamp"}`,
 			notary.SQLiteBackend, tmpFile.Name())),
 		[]string{notary.SQLiteBackend}, false)
 	require.NoError(t, err)
-	require.Len(t, cryptoServices, 2)
+	require.Len(t, cryptoServices, 3)
+	require.IsType(t, &keydbstore.SQLKeyDBStore{}, auditStore)
 
 	edService, ok := cryptoServices[data.ED25519Key]
 	require.True(t, ok)
@@ -175,7 +179,11 @@ func TestSetupCryptoServicesDBStoreSuccess(t *testing.T) {
 	ecService, ok := cryptoServices[data.ECDSAKey]
 	require.True(t, ok)
 
+	ecP384Service, ok := cryptoServices[data.ECDSAP384Key]
+	require.True(t, ok)
+
 	require.Equal(t, edService, ecService)
+	require.Equal(t, edService, ecP384Service)
 
 	// since the keystores are not exposed by CryptoService, try creating
 	// a key and seeing if it is in the sqlite DB.
@@ -193,10 +201,11 @@ func TestSetupCryptoServicesDBStoreSuccess(t *testing.T) {
 func TestSetupCryptoServicesMemoryStore(t *testing.T) {
 	config := configure(fmt.Sprintf(`{"storage": {"backend": "%s"}}`,
 		notary.MemoryBackend))
-	cryptoServices, err := setUpCryptoservices(config,
+	cryptoServices, auditStore, err := setUpCryptoservices(config,
 		[]string{notary.SQLiteBackend, notary.MemoryBackend}, false)
 	require.NoError(t, err)
-	require.Len(t, cryptoServices, 2)
+	require.Len(t, cryptoServices, 3)
+	require.IsType(t, &audit.MemoryStore{}, auditStore)
 
 	edService, ok := cryptoServices[data.ED25519Key]
 	require.True(t, ok)
@@ -204,7 +213,11 @@ func TestSetupCryptoServicesMemoryStore(t *testing.T) {
 	ecService, ok := cryptoServices[data.ECDSAKey]
 	require.True(t, ok)
 
+	ecP384Service, ok := cryptoServices[data.ECDSAP384Key]
+	require.True(t, ok)
+
 	require.Equal(t, edService, ecService)
+	require.Equal(t, edService, ecP384Service)
 
 	// since the keystores are not exposed by CryptoService, try creating
 	// and getting the key
@@ -215,15 +228,138 @@ func TestSetupCryptoServicesMemoryStore(t *testing.T) {
 	require.NotNil(t, privKey)
 }
 
+// If a KMS backend is specified, a default alias is not needed, and a KMS
+// CryptoService is returned for the algorithms the KMS supports.
+func TestSetupCryptoServicesKMSStore(t *testing.T) {
+	config := configure(fmt.Sprintf(`{"storage": {"backend": "%s"}}`,
+		notary.KMSBackend))
+	_, _, err := setUpCryptoservices(config, []string{notary.KMSBackend}, false)
+	require.Error(t, err)
+
+	config = configure(fmt.Sprintf(`{"storage": {"backend": "%s", "url": "https://kms:8200/v1/transit", "tls_ca_file": "%s"}}`,
+		notary.KMSBackend, "../../fixtures/root-ca.crt"))
+	cryptoServices, auditStore, err := setUpCryptoservices(config, []string{notary.KMSBackend}, false)
+	require.NoError(t, err)
+	require.Len(t, cryptoServices, 2)
+	require.IsType(t, &kms.CryptoService{}, cryptoServices[data.ECDSAKey])
+	require.Equal(t, cryptoServices[data.ECDSAKey], cryptoServices[data.ECDSAP384Key])
+	_, ok := cryptoServices[data.ED25519Key]
+	require.False(t, ok)
+	require.IsType(t, &audit.MemoryStore{}, auditStore)
+}
+
+// If a PKCS#11 backend is specified, the token and a PIN source are required,
+// and the token must be reachable.
+func TestSetupCryptoServicesPKCS11Store(t *testing.T) {
+	config := configure(fmt.Sprintf(`{"storage": {"backend": "%s", "module": "/nonexistent/libsofthsm2.so", "token_label": "notary"}}`,
+		notary.PKCS11Backend))
+	_, _, err := setUpCryptoservices(config, []string{notary.PKCS11Backend}, false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pin_file or pin_env")
+
+	config = configure(fmt.Sprintf(`{"storage": {"backend": "%s", "module": "/nonexistent/libsofthsm2.so", "token_label": "notary", "pin_env": "PKCS11_PIN"}}`,
+		notary.PKCS11Backend))
+	_, _, err = setUpCryptoservices(config, []string{notary.PKCS11Backend}, false)
+	require.Error(t, err)
+}
+
 func TestSetupCryptoServicesInvalidStore(t *testing.T) {
 	config := configure(fmt.Sprintf(`{"storage": {"backend": "%s"}}`,
 		"invalid_backend"))
-	_, err := setUpCryptoservices(config,
+	_, _, err := setUpCryptoservices(config,
 		[]string{notary.SQLiteBackend, notary.MemoryBackend, notary.RethinkDBBackend}, false)
 	require.Error(t, err)
 	require.Equal(t, err.Error(), fmt.Sprintf("%s is not an allowed backend, must be one of: %s", "invalid_backend", []string{notary.SQLiteBackend, notary.MemoryBackend, notary.RethinkDBBackend}))
 }
 
+func TestGetDefaultPolicy(t *testing.T) {
+	defaultPolicy, err := getDefaultPolicy(configure(`{}`))
+	require.NoError(t, err)
+	require.Nil(t, defaultPolicy)
+
+	defaultPolicy, err = getDefaultPolicy(configure(`{"policy": {
+		"allowed_gun_prefixes": ["docker.io/"],
+		"allowed_roles": ["timestamp", "snapshot"],
+		"max_signatures_per_minute": 60,
+		"check_content": true}}`))
+	require.NoError(t, err)
+	require.Equal(t, &policy.KeyPolicy{
+		AllowedGUNPrefixes:     []string{"docker.io/"},
+		AllowedRoles:           []data.RoleName{data.CanonicalTimestampRole, data.CanonicalSnapshotRole},
+		MaxSignaturesPerMinute: 60,
+		CheckContent:           true,
+	}, defaultPolicy)
+
+	_, err = getDefaultPolicy(configure(`{"policy": {"allowed_roles": ["nope"]}}`))
+	require.Error(t, err)
+	_, err = getDefaultPolicy(configure(`{"policy": {"max_signatures_per_minute": -1}}`))
+	require.Error(t, err)
+}
+
+func TestGetKeyPolicies(t *testing.T) {
+	keyPolicies, err := getKeyPolicies(configure(`{}`))
+	require.NoError(t, err)
+	require.Empty(t, keyPolicies)
+
+	keyPolicies, err = getKeyPolicies(configure(`{"key_policies": {
+		"abc123": {"allowed_roles": ["timestamp"], "max_signatures_per_minute": 60},
+		"def456": null}}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]*policy.KeyPolicy{
+		"abc123": {
+			AllowedRoles:           []data.RoleName{data.CanonicalTimestampRole},
+			MaxSignaturesPerMinute: 60,
+		},
+		"def456": nil,
+	}, keyPolicies)
+
+	_, err = getKeyPolicies(configure(`{"key_policies": {"abc123": {"allowed_roles": ["nope"]}}}`))
+	require.Error(t, err)
+	_, err = getKeyPolicies(configure(`{"key_policies": {"abc123": {"max_signatures_per_minute": "many"}}}`))
+	require.Error(t, err)
+}
+
+type recordingPolicySetter struct {
+	policies map[string]*policy.KeyPolicy
+	err      error
+}
+
+func (r *recordingPolicySetter) SetKeyPolicy(keyID string, keyPolicy *policy.KeyPolicy) error {
+	if keyID == "missing" {
+		return trustmanager.ErrKeyNotFound{KeyID: keyID}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	r.policies[keyID] = keyPolicy
+	return nil
+}
+
+// Key policies from the configuration are stored, skipping keys which do not
+// exist, and are refused by backends which cannot store them
+func TestSetKeyPolicies(t *testing.T) {
+	setter := &recordingPolicySetter{policies: make(map[string]*policy.KeyPolicy)}
+	keyPolicies := map[string]*policy.KeyPolicy{
+		"abc123":  {CheckContent: true},
+		"def456":  nil,
+		"missing": {CheckContent: true},
+	}
+	require.NoError(t, setKeyPolicies(keyPolicies, setter))
+	require.Equal(t, map[string]*policy.KeyPolicy{
+		"abc123": {CheckContent: true},
+		"def456": nil,
+	}, setter.policies)
+
+	setter.err = fmt.Errorf("db is down")
+	require.Error(t, setKeyPolicies(keyPolicies, setter))
+
+	config := configure(fmt.Sprintf(`{"storage": {"backend": "%s"}, "key_policies": {"abc123": {"check_content": true}}}`,
+		notary.MemoryBackend))
+	_, _, err := setUpCryptoservices(config, []string{notary.MemoryBackend}, false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "key_policies")
+}
+
 func TestSetupGRPCServerInvalidAddress(t *testing.T) {
 	_, _, err := setupGRPCServer(signer.Config{GRPCAddr: "nope", CryptoServices: make(signer.CryptoServiceIndex)})
 	require.Error(t, err)
@@ -0,0 +1,25 @@
+// +build pkcs11
+
+package main
+
+import (
+	"time"
+
+	"github.com/docker/distribution/health"
+	"github.com/theupdateframework/notary/trustmanager/pkcs11"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+var pkcs11SupportedAlgorithms = pkcs11.SupportedAlgorithms
+
+// setUpPKCS11 returns a CryptoService which generates and keeps keys in the
+// configured PKCS#11 token
+func setUpPKCS11(config pkcs11.Config) (signed.CryptoService, error) {
+	store, err := pkcs11.NewStore(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	cs := pkcs11.NewCryptoService(store)
+	health.RegisterPeriodicFunc("PKCS#11 token operational", time.Minute, cs.CheckHealth)
+	return cs, nil
+}
@@ -0,0 +1,16 @@
+// +build !pkcs11
+
+package main
+
+import (
+	"errors"
+
+	"github.com/theupdateframework/notary/trustmanager/pkcs11"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+var pkcs11SupportedAlgorithms []string
+
+func setUpPKCS11(config pkcs11.Config) (signed.CryptoService, error) {
+	return nil, errors.New("notary-signer was not built with PKCS#11 support")
+}
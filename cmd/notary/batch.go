@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	canonicaljson "github.com/docker/go/canonical/json"
+	"github.com/spf13/cobra"
+	"github.com/theupdateframework/notary"
+	notaryclient "github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var cmdTUFAddBatchTemplate = usageTemplate{
+	Use:   "add-batch [ GUN ] --manifest <file>",
+	Short: "Adds all of the targets listed in a manifest to the trusted collection.",
+	Long:  "Adds the targets listed, with their byte sizes, hashes, custom data and roles, in a JSON or CSV manifest to the local trusted collection identified by the Globally Unique Name.  Every target is validated before any is staged, and one change is staged per role.  This is an offline operation.  Please then use `publish` to push the changes to the remote trusted collection.",
+}
+
+var cmdTUFRemoveBatchTemplate = usageTemplate{
+	Use:   "remove-batch [ GUN ] --manifest <file>",
+	Short: "Removes all of the targets listed in a manifest from the trusted collection.",
+	Long:  "Removes the targets listed in a JSON or CSV manifest from the local trusted collection identified by the Globally Unique Name.  Only the names and roles in the manifest are used.  One change is staged per role.  This is an offline operation.  Please then use `publish` to push the changes to the remote trusted collection.",
+}
+
+// manifestEntry is a target listed in a batch manifest.  Hashes are hex
+// encoded, as addhash takes them.
+type manifestEntry struct {
+	Name   string                    `json:"name"`
+	Length int64                     `json:"length"`
+	Hashes map[string]string         `json:"hashes"`
+	Custom *canonicaljson.RawMessage `json:"custom,omitempty"`
+	Roles  []string                  `json:"roles,omitempty"`
+}
+
+// The columns of a CSV manifest, which must start with a header naming them.
+// Roles are separated by spaces, and custom data is JSON.
+const (
+	csvName   = "name"
+	csvLength = "length"
+	csvSHA256 = notary.SHA256
+	csvSHA512 = notary.SHA512
+	csvRoles  = "roles"
+	csvCustom = "custom"
+)
+
+// readManifest reads a JSON or CSV manifest, depending on its extension
+func readManifest(path string) ([]manifestEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var entries []manifestEntry
+		if err := json.NewDecoder(f).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+		}
+		return entries, nil
+	case ".csv":
+		entries, err := parseCSVManifest(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("manifest must be a .json or .csv file: %s", path)
+}
+
+func parseCSVManifest(r io.Reader) ([]manifestEntry, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header")
+	}
+	columns := make(map[string]int)
+	for i, column := range records[0] {
+		column = strings.ToLower(strings.TrimSpace(column))
+		switch column {
+		case csvName, csvLength, csvSHA256, csvSHA512, csvRoles, csvCustom:
+			columns[column] = i
+		default:
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+	if _, ok := columns[csvName]; !ok {
+		return nil, fmt.Errorf("missing %q column", csvName)
+	}
+	field := func(record []string, column string) string {
+		if i, ok := columns[column]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	entries := make([]manifestEntry, 0, len(records)-1)
+	for line, record := range records[1:] {
+		entry := manifestEntry{
+			Name:   field(record, csvName),
+			Hashes: make(map[string]string),
+			Roles:  strings.Fields(field(record, csvRoles)),
+		}
+		if length := field(record, csvLength); length != "" {
+			if entry.Length, err = strconv.ParseInt(length, 0, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid length %q", line+2, length)
+			}
+		}
+		for _, hashAlgorithm := range []string{csvSHA256, csvSHA512} {
+			if hash := field(record, hashAlgorithm); hash != "" {
+				entry.Hashes[hashAlgorithm] = hash
+			}
+		}
+		if custom := field(record, csvCustom); custom != "" {
+			if !json.Valid([]byte(custom)) {
+				return nil, fmt.Errorf("line %d: custom data is not valid JSON", line+2)
+			}
+			raw := canonicaljson.RawMessage(custom)
+			entry.Custom = &raw
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// roleBatch is the targets to add to, or remove from, one role
+type roleBatch struct {
+	role    data.RoleName
+	targets []*notaryclient.Target
+}
+
+// batchByRole validates every entry in the manifest, then groups them by the
+// roles they are listed for, or defaultRoles if they list none.  Only names
+// and roles are used unless withMeta is set.
+func batchByRole(entries []manifestEntry, defaultRoles []data.RoleName, withMeta bool) ([]roleBatch, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("the manifest does not list any targets")
+	}
+	if len(defaultRoles) == 0 {
+		defaultRoles = []data.RoleName{data.CanonicalTargetsRole}
+	}
+
+	var batches []roleBatch
+	batchIndex := make(map[data.RoleName]int)
+	names := make(map[data.RoleName]map[string]bool)
+	for i, entry := range entries {
+		target, err := manifestTarget(entry, withMeta)
+		if err != nil {
+			return nil, fmt.Errorf("manifest entry %d: %v", i+1, err)
+		}
+		roles := data.NewRoleList(entry.Roles)
+		if len(roles) == 0 {
+			roles = defaultRoles
+		}
+		for _, role := range roles {
+			if role != data.CanonicalTargetsRole && !data.IsDelegation(role) && !data.IsWildDelegation(role) {
+				return nil, fmt.Errorf("manifest entry %d: cannot change the targets of role %s", i+1, role)
+			}
+			if names[role][target.Name] {
+				return nil, fmt.Errorf("manifest entry %d: target %q is listed more than once for role %s", i+1, target.Name, role)
+			}
+			index, ok := batchIndex[role]
+			if !ok {
+				index = len(batches)
+				batchIndex[role] = index
+				batches = append(batches, roleBatch{role: role})
+				names[role] = make(map[string]bool)
+			}
+			names[role][target.Name] = true
+			batches[index].targets = append(batches[index].targets, target)
+		}
+	}
+	return batches, nil
+}
+
+// manifestTarget validates an entry in the manifest, returning its target
+func manifestTarget(entry manifestEntry, withMeta bool) (*notaryclient.Target, error) {
+	if entry.Name == "" {
+		return nil, fmt.Errorf("missing target name")
+	}
+	target := &notaryclient.Target{Name: entry.Name}
+	if !withMeta {
+		return target, nil
+	}
+
+	if entry.Length < 0 {
+		return nil, fmt.Errorf("target %q has a negative length", entry.Name)
+	}
+	for hashAlgorithm := range entry.Hashes {
+		if hashAlgorithm != notary.SHA256 && hashAlgorithm != notary.SHA512 {
+			return nil, fmt.Errorf("target %q has an unsupported hash algorithm %s", entry.Name, hashAlgorithm)
+		}
+	}
+	hashes, err := parseTargetHashes(entry.Hashes[notary.SHA256], entry.Hashes[notary.SHA512])
+	if err != nil {
+		return nil, fmt.Errorf("target %q: %v", entry.Name, err)
+	}
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("target %q has no hashes", entry.Name)
+	}
+	target.Length = entry.Length
+	target.Hashes = hashes
+	target.Custom = entry.Custom
+	if _, err := target.Lifecycle(); err != nil {
+		return nil, fmt.Errorf("target %q: %v", entry.Name, err)
+	}
+	return target, nil
+}
+
+func (t *tufCommander) tufAddBatch(cmd *cobra.Command, args []string) error {
+	return t.tufBatch(cmd, args, true)
+}
+
+func (t *tufCommander) tufRemoveBatch(cmd *cobra.Command, args []string) error {
+	return t.tufBatch(cmd, args, false)
+}
+
+// tufBatch stages the addition or removal of all of the targets in the
+// manifest, once all of them have been validated
+func (t *tufCommander) tufBatch(cmd *cobra.Command, args []string, add bool) error {
+	if len(args) < 1 || t.manifest == "" {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN and a manifest")
+	}
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := data.GUN(args[0])
+
+	entries, err := readManifest(t.manifest)
+	if err != nil {
+		return err
+	}
+	batches, err := batchByRole(entries, data.NewRoleList(t.roles), add)
+	if err != nil {
+		return err
+	}
+
+	// no online operations are performed by staging changes, so the transport
+	// argument should be nil
+	permission := admin
+	if add {
+		permission = readWrite
+	}
+	fact := ConfigureRepo(config, t.retriever, false, permission)
+	nRepo, err := fact(gun)
+	if err != nil {
+		return err
+	}
+
+	for _, batch := range batches {
+		if add {
+			err = nRepo.AddTargets(batch.targets, batch.role)
+		} else {
+			names := make([]string, 0, len(batch.targets))
+			for _, target := range batch.targets {
+				names = append(names, target.Name)
+			}
+			err = nRepo.RemoveTargets(names, batch.role)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	action := "Removal"
+	if add {
+		action = "Addition"
+	}
+	cmd.Printf("%s of %d targets in repository \"%s\" staged for next publish.\n", action, len(entries), gun)
+
+	return maybeAutoPublish(cmd, t.autoPublish, gun, config, t.retriever)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func TestBatchByRole(t *testing.T) {
+	hashes := map[string]string{"sha256": strings.Repeat("ab", 32)}
+	entries := []manifestEntry{
+		{Name: "a", Hashes: hashes},
+		{Name: "b", Hashes: hashes, Roles: []string{"targets/releases", "targets"}},
+		{Name: "c", Hashes: hashes, Roles: []string{"targets/releases"}},
+	}
+
+	batches, err := batchByRole(entries, nil, true)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	require.Equal(t, data.CanonicalTargetsRole, batches[0].role)
+	require.Len(t, batches[0].targets, 2)
+	require.Equal(t, data.RoleName("targets/releases"), batches[1].role)
+	require.Len(t, batches[1].targets, 2)
+
+	// entries without roles go to the default roles
+	batches, err = batchByRole(entries[:1], []data.RoleName{"targets/a", "targets/b"}, true)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+
+	for _, invalid := range [][]manifestEntry{
+		nil,
+		{{Hashes: hashes}},
+		{{Name: "a"}},
+		{{Name: "a", Hashes: map[string]string{"md5": "00"}}},
+		{{Name: "a", Hashes: map[string]string{"sha256": "nothex"}}},
+		{{Name: "a", Hashes: hashes, Length: -1}},
+		{{Name: "a", Hashes: hashes, Roles: []string{"root"}}},
+		{{Name: "a", Hashes: hashes}, {Name: "a", Hashes: hashes}},
+	} {
+		_, err := batchByRole(invalid, nil, true)
+		require.Error(t, err)
+	}
+
+	// removals only need names
+	batches, err = batchByRole([]manifestEntry{{Name: "a"}}, nil, false)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+}
+
+func TestParseCSVManifest(t *testing.T) {
+	entries, err := parseCSVManifest(strings.NewReader(
+		"Name, SHA256, roles\na,abcd,targets/x targets/y\nb,,\n"))
+	require.NoError(t, err)
+	require.Equal(t, []manifestEntry{
+		{Name: "a", Hashes: map[string]string{"sha256": "abcd"}, Roles: []string{"targets/x", "targets/y"}},
+		{Name: "b", Hashes: map[string]string{}, Roles: []string{}},
+	}, entries)
+
+	for _, invalid := range []string{
+		"",
+		"length\n1\n",
+		"name,size\na,1\n",
+		"name,length\na,big\n",
+		"name,custom\na,{\n",
+	} {
+		_, err := parseCSVManifest(strings.NewReader(invalid))
+		require.Error(t, err, invalid)
+	}
+}
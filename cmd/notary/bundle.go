@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/theupdateframework/notary"
+	notaryclient "github.com/theupdateframework/notary/client"
+	store "github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var cmdBundleTemplate = usageTemplate{
+	Use:   "bundle",
+	Short: "Moves trust data to machines without access to a notary server.",
+	Long:  "Exports the trust data for a Global Unique Name to a single file, and imports it on machines which have no network path to the notary server.",
+}
+
+var cmdBundleExportTemplate = usageTemplate{
+	Use:   "export [ GUN ]",
+	Short: "Exports all trust data for a GUN to a bundle file.",
+	Long:  "Downloads the latest trust data for a specific Global Unique Name from the notary server and writes it to a bundle file, along with every version of the root role the server has.",
+}
+
+var cmdBundleImportTemplate = usageTemplate{
+	Use:   "import [ Bundle file ]",
+	Short: "Imports the trust data in a bundle file.",
+	Long:  "Verifies the trust data in a bundle file exactly as if it had been downloaded from a notary server, including root key rotations and trust pinning, and stores it locally. Does not require access to the notary server.",
+}
+
+type bundleCommander struct {
+	// these need to be set
+	configGetter func() (*viper.Viper, error)
+	getRetriever func() notary.PassRetriever
+
+	output string
+}
+
+func (b *bundleCommander) GetCommand() *cobra.Command {
+	cmd := cmdBundleTemplate.ToCommand(nil)
+
+	cmdExport := cmdBundleExportTemplate.ToCommand(b.bundleExport)
+	cmdExport.Flags().StringVarP(&b.output, "output", "o", "", "Filepath to write the bundle to")
+	cmd.AddCommand(cmdExport)
+
+	cmd.AddCommand(cmdBundleImportTemplate.ToCommand(b.bundleImport))
+	return cmd
+}
+
+func (b *bundleCommander) bundleExport(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN")
+	}
+	if b.output == "" {
+		cmd.Usage()
+		return fmt.Errorf("Please provide a file to write the bundle to using the --output flag")
+	}
+	config, err := b.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := data.GUN(args[0])
+
+	fact := ConfigureRepo(config, b.getRetriever(), true, readOnly)
+	nRepo, err := fact(gun)
+	if err != nil {
+		return err
+	}
+	bundle, err := nRepo.ExportBundle()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(b.output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, notary.PrivNoExecPerms)
+	if err != nil {
+		return fmt.Errorf("Error writing bundle to %s: %v", b.output, err)
+	}
+	defer f.Close()
+	if err := bundle.Write(f); err != nil {
+		return fmt.Errorf("Error writing bundle to %s: %v", b.output, err)
+	}
+	cmd.Printf("Exported trust data for %s to %s\n", gun, b.output)
+	return nil
+}
+
+func (b *bundleCommander) bundleImport(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a bundle file")
+	}
+	config, err := b.configGetter()
+	if err != nil {
+		return err
+	}
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("Error reading bundle from %s: %v", args[0], err)
+	}
+	defer f.Close()
+	bundle, err := store.ReadBundle(f)
+	if err != nil {
+		return fmt.Errorf("Error reading bundle from %s: %v", args[0], err)
+	}
+
+	if err := notaryclient.ImportBundle(config.GetString("trust_dir"), bundle, trustPin); err != nil {
+		return err
+	}
+	cmd.Printf("Imported trust data for %s\n", bundle.GUN)
+	return nil
+}
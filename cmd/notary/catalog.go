@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var cmdTUFCatalogTemplate = usageTemplate{
+	Use:   "catalog",
+	Short: "Lists the trusted collections on the remote trust server.",
+	Long:  "Lists the Globally Unique Names of the trusted collections on the remote trust server which you can pull from. This is an online operation.",
+}
+
+func (t *tufCommander) tufCatalog(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		cmd.Usage()
+		return fmt.Errorf("catalog does not take any arguments")
+	}
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+
+	serverURL := getRemoteTrustServer(config)
+	rt, err := getTransport(config, "", readOnly)
+	if err != nil {
+		return err
+	}
+	if rt == nil {
+		return fmt.Errorf("unable to reach the remote trust server at %s", serverURL)
+	}
+	guns, err := getCatalog(serverURL, rt, t.prefix)
+	if err != nil {
+		return err
+	}
+
+	if format := getOutputFormat(config); format != outputTable {
+		return writeStructured(format, append([]data.GUN{}, guns...), cmd.OutOrStdout())
+	}
+	prettyPrintCatalog(guns, cmd.OutOrStdout())
+	return nil
+}
+
+// getCatalog pages through the catalog of the notary server at serverURL,
+// returning every GUN beginning with prefix
+func getCatalog(serverURL string, rt http.RoundTripper, prefix string) ([]data.GUN, error) {
+	base, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	next := &url.URL{Path: path.Join(base.Path, "/v2/_trust/catalog"), RawQuery: query.Encode()}
+	httpClient := &http.Client{Transport: rt}
+
+	guns := []data.GUN{}
+	for next != nil {
+		page := base.ResolveReference(next)
+		resp, err := httpClient.Get(page.String())
+		if err != nil {
+			return nil, err
+		}
+		var catalog struct {
+			Repositories []data.GUN `json:"repositories"`
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err = json.NewDecoder(io.LimitReader(resp.Body, notary.MaxDownloadSize)).Decode(&catalog)
+		case http.StatusUnauthorized:
+			err = fmt.Errorf("not authorized to list the trusted collections on %s", serverURL)
+		default:
+			err = fmt.Errorf("%s responded with %d", page, resp.StatusCode)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		guns = append(guns, catalog.Repositories...)
+
+		if next, err = nextLink(resp.Header.Get("Link")); err != nil {
+			return nil, err
+		}
+	}
+	return guns, nil
+}
+
+// nextLink parses the URL of the next page out of a Link header, returning
+// nil if there is no next page
+func nextLink(link string) (*url.URL, error) {
+	if !strings.HasSuffix(link, `>; rel="next"`) || !strings.HasPrefix(link, "<") {
+		return nil, nil
+	}
+	return url.Parse(strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`))
+}
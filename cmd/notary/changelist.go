@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/theupdateframework/notary"
+	notaryclient "github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var cmdChangelistTemplate = usageTemplate{
+	Use:   "changelist",
+	Short: "Operates on named changelists.",
+	Long:  "Lists the named changelists of a Global Unique Name, and exports and imports changelists so that changes staged by one person can be published by another.  Use the global --changelist flag to stage changes in, or publish, a named changelist.",
+}
+
+var cmdChangelistListTemplate = usageTemplate{
+	Use:   "list [ GUN ]",
+	Short: "Lists the named changelists of a GUN.",
+	Long:  "Lists the named changelists of a specific Global Unique Name, and how many unpublished changes each has.",
+}
+
+var cmdChangelistExportTemplate = usageTemplate{
+	Use:   "export [ GUN ]",
+	Short: "Exports the unpublished changes of a GUN to a file.",
+	Long:  "Writes the unpublished changes of a specific Global Unique Name, in the default changelist or the one named with --changelist, to a file.  The changes are not removed from the changelist.",
+}
+
+var cmdChangelistImportTemplate = usageTemplate{
+	Use:   "import [ GUN ] [ Changelist file ]",
+	Short: "Imports the changes in a file into a changelist.",
+	Long:  "Stages the changes exported to a file for a specific Global Unique Name, in the default changelist or the one named with --changelist, after any changes already staged there.  Either all of the changes are imported, or none are.",
+}
+
+type changelistCommander struct {
+	// these need to be set
+	configGetter func() (*viper.Viper, error)
+	getRetriever func() notary.PassRetriever
+
+	output string
+}
+
+// changelistInfo is a named changelist, as listed by changelist list
+type changelistInfo struct {
+	Name    string `json:"name"`
+	Changes int    `json:"changes"`
+}
+
+func (c *changelistCommander) GetCommand() *cobra.Command {
+	cmd := cmdChangelistTemplate.ToCommand(nil)
+	cmd.AddCommand(cmdChangelistListTemplate.ToCommand(c.changelistList))
+
+	cmdExport := cmdChangelistExportTemplate.ToCommand(c.changelistExport)
+	cmdExport.Flags().StringVarP(&c.output, "output", "o", "", "Filepath to write the changes to")
+	cmd.AddCommand(cmdExport)
+
+	cmd.AddCommand(cmdChangelistImportTemplate.ToCommand(c.changelistImport))
+	return cmd
+}
+
+func (c *changelistCommander) changelistList(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN")
+	}
+	config, err := c.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := data.GUN(args[0])
+	trustDir := config.GetString("trust_dir")
+
+	names, err := notaryclient.ListChangelists(trustDir, gun)
+	if err != nil {
+		return err
+	}
+	changelists := make([]changelistInfo, 0, len(names))
+	for _, name := range names {
+		cl, err := notaryclient.NewNamedFileChangelist(trustDir, gun, name)
+		if err != nil {
+			return err
+		}
+		changelists = append(changelists, changelistInfo{Name: name, Changes: len(cl.List())})
+	}
+
+	if format := getOutputFormat(config); format != outputTable {
+		return writeStructured(format, changelists, cmd.OutOrStdout())
+	}
+	if len(changelists) == 0 {
+		cmd.Printf("No named changelists for %s\n", gun)
+		return nil
+	}
+	tw := initTabWriter([]string{"NAME", "CHANGES"}, cmd.OutOrStdout())
+	for _, info := range changelists {
+		fmt.Fprintf(tw, "%s\t%d\n", info.Name, info.Changes)
+	}
+	tw.Flush()
+	return nil
+}
+
+// getChangelist returns the changelist selected by the configuration for the
+// GUN
+func (c *changelistCommander) getChangelist(config *viper.Viper, gun data.GUN) (changelist.Changelist, error) {
+	// no online operations are performed on changelists, so the transport
+	// argument should be nil
+	fact := ConfigureRepo(config, c.getRetriever(), false, readOnly)
+	nRepo, err := fact(gun)
+	if err != nil {
+		return nil, err
+	}
+	return nRepo.GetChangelist()
+}
+
+func (c *changelistCommander) changelistExport(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN")
+	}
+	if c.output == "" {
+		cmd.Usage()
+		return fmt.Errorf("Please provide a file to write the changes to using the --output flag")
+	}
+	config, err := c.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := data.GUN(args[0])
+
+	cl, err := c.getChangelist(config, gun)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(c.output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, notary.PrivNoExecPerms)
+	if err != nil {
+		return fmt.Errorf("Error writing changes to %s: %v", c.output, err)
+	}
+	defer f.Close()
+	if err := changelist.Export(cl, gun, f); err != nil {
+		return fmt.Errorf("Error writing changes to %s: %v", c.output, err)
+	}
+	cmd.Printf("Exported %d changes for %s to %s\n", len(cl.List()), gun, c.output)
+	return nil
+}
+
+func (c *changelistCommander) changelistImport(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN and a changelist file")
+	}
+	config, err := c.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := data.GUN(args[0])
+
+	cl, err := c.getChangelist(config, gun)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		return fmt.Errorf("Error reading changes from %s: %v", args[1], err)
+	}
+	defer f.Close()
+	n, err := changelist.Import(cl, gun, f)
+	if err != nil {
+		return fmt.Errorf("Error importing changes from %s: %v", args[1], err)
+	}
+	cmd.Printf("Imported %d changes for %s, staged for next publish.\n", n, gun)
+	return nil
+}
@@ -114,6 +114,9 @@ func (d *delegationCommander) delegationPurgeKeys(cmd *cobra.Command, args []str
 	if err != nil {
 		return err
 	}
+	if err := useNamedChangelist(config, nRepo); err != nil {
+		return err
+	}
 
 	err = nRepo.RemoveDelegationKeys("targets/*", d.keyIDs)
 	if err != nil {
@@ -164,6 +167,9 @@ func (d *delegationCommander) delegationsList(cmd *cobra.Command, args []string)
 		return fmt.Errorf("Error retrieving delegation roles for repository %s: %v", gun, err)
 	}
 
+	if format := getOutputFormat(config); format != outputTable {
+		return writeStructured(format, delegationsOutput(delegationRoles), cmd.OutOrStdout())
+	}
 	cmd.Println("")
 	prettyPrintRoles(delegationRoles, cmd.OutOrStdout(), "delegations")
 	cmd.Println("")
@@ -189,6 +195,9 @@ func (d *delegationCommander) delegationRemove(cmd *cobra.Command, args []string
 	if err != nil {
 		return err
 	}
+	if err := useNamedChangelist(config, nRepo); err != nil {
+		return err
+	}
 
 	if d.removeAll {
 		cmd.Println("\nAre you sure you want to remove all data for this delegation? (yes/no)")
@@ -319,6 +328,9 @@ func (d *delegationCommander) delegationAdd(cmd *cobra.Command, args []string) e
 	if err != nil {
 		return err
 	}
+	if err := useNamedChangelist(config, nRepo); err != nil {
+		return err
+	}
 
 	// Add the delegation to the repository
 	err = nRepo.AddDelegation(role, pubKeys, d.paths)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	notaryclient "github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var cmdTUFDiffTemplate = usageTemplate{
+	Use:   "diff [ GUN ] [ <from> <to> ]",
+	Short: "Shows how a trusted collection changed between two versions.",
+	Long:  "Shows the targets, delegations, keys and thresholds which changed in a trusted collection between two versions of its snapshot, or, if no versions are given, what publishing the staged changes would change. This is an online operation.",
+}
+
+func (t *tufCommander) tufDiff(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 && len(args) != 3 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN, and optionally the two snapshot versions to compare")
+	}
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := data.GUN(args[0])
+
+	fact := ConfigureRepo(config, t.retriever, true, readOnly)
+	nRepo, err := fact(gun)
+	if err != nil {
+		return err
+	}
+
+	var diff *notaryclient.RepoDiff
+	if len(args) == 1 {
+		diff, err = nRepo.DiffUnpublished()
+	} else {
+		var versions [2]int
+		for i, arg := range args[1:] {
+			if versions[i], err = strconv.Atoi(arg); err != nil || versions[i] < 1 {
+				return fmt.Errorf("snapshot versions must be positive integers: %s", arg)
+			}
+		}
+		diff, err = nRepo.DiffSnapshotVersions(versions[0], versions[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	format := getOutputFormat(config)
+	if t.jsonOutput {
+		format = outputJSON
+	}
+	if format != outputTable {
+		return writeStructured(format, diff, cmd.OutOrStdout())
+	}
+	prettyPrintDiff(diff, cmd.OutOrStdout())
+	return nil
+}
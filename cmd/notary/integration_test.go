@@ -39,6 +39,7 @@ import (
 	"github.com/theupdateframework/notary/server/storage"
 	nstorage "github.com/theupdateframework/notary/storage"
 	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/trustpinning"
 	"github.com/theupdateframework/notary/tuf/data"
 	testutils "github.com/theupdateframework/notary/tuf/testutils/keys"
 	"github.com/theupdateframework/notary/tuf/utils"
@@ -63,6 +64,7 @@ func runCommand(t *testing.T, tempDir string, args ...string) (string, error) {
 	require.NoError(t, err)
 
 	// Clean up state to mimic running a fresh command next time
+	closeKeyStores()
 	for _, command := range cmd.Commands() {
 		command.ResetFlags()
 	}
@@ -185,6 +187,88 @@ func TestInitWithRootKey(t *testing.T) {
 	require.Error(t, err, "Init with wrong role should error")
 }
 
+// Initializes a repo with thresholds greater than 1, and changes the targets
+// threshold when rotating its keys
+func TestInitAndRotateWithThresholds(t *testing.T) {
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	tempFile, err := ioutil.TempFile("", "targetfile")
+	require.NoError(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	var rootKeyFiles []string
+	for i := 0; i < 2; i++ {
+		privKey, err := utils.GenerateECDSAKey(rand.Reader)
+		require.NoError(t, err)
+		encryptedPEMPrivKey, err := utils.ConvertPrivateKeyToPKCS8(privKey, data.CanonicalRootRole, "", testPassphrase)
+		require.NoError(t, err)
+		rootKeyFile := filepath.Join(tempDir, fmt.Sprintf("encrypted_key%d.key", i))
+		require.NoError(t, ioutil.WriteFile(rootKeyFile, encryptedPEMPrivKey, 0644))
+		rootKeyFiles = append(rootKeyFiles, rootKeyFile)
+	}
+
+	requireThresholds := func(expected map[data.RoleName][2]int) {
+		repo, err := client.NewFileCachedRepository(tempDir, "gun", server.URL, http.DefaultTransport,
+			passphrase.ConstantRetriever(testPassphrase), trustpinning.TrustPinConfig{})
+		require.NoError(t, err)
+		roles, err := repo.ListRoles()
+		require.NoError(t, err)
+		for _, role := range roles {
+			if numKeysAndThreshold, ok := expected[role.Name]; ok {
+				require.Len(t, role.KeyIDs, numKeysAndThreshold[0], role.Name.String())
+				require.Equal(t, numKeysAndThreshold[1], role.Threshold, role.Name.String())
+			}
+		}
+	}
+
+	// a root threshold needs as many root keys, since they are not generated
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "gun", "--rootkey", rootKeyFiles[0], "--root-threshold", "2")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "needs at least 2 root keys")
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "gun", "--root-threshold", "2")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "needs at least 2 root keys")
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "gun",
+		"--rootkey", rootKeyFiles[0], "--rootkey", rootKeyFiles[1], "--root-threshold", "2", "--rootcert", "root.crt")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--rootcert")
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "gun", "-p",
+		"--rootkey", rootKeyFiles[0], "--rootkey", rootKeyFiles[1], "--root-threshold", "2",
+		"--targets-threshold", "2", "--snapshot-threshold", "2")
+	require.NoError(t, err)
+	requireThresholds(map[data.RoleName][2]int{
+		data.CanonicalRootRole:     {2, 2},
+		data.CanonicalTargetsRole:  {2, 2},
+		data.CanonicalSnapshotRole: {2, 2},
+	})
+	assertSuccessfullyPublish(t, tempDir, server.URL, "gun", "target", tempFile.Name())
+
+	// rotating generates enough keys to meet the new threshold
+	_, err = runCommand(t, tempDir, "-s", server.URL, "key", "rotate", "gun", data.CanonicalTargetsRole.String(), "--threshold", "3")
+	require.NoError(t, err)
+	requireThresholds(map[data.RoleName][2]int{data.CanonicalTargetsRole: {3, 3}})
+
+	// and without a threshold, keeps the current one
+	_, err = runCommand(t, tempDir, "-s", server.URL, "key", "rotate", "gun", data.CanonicalTargetsRole.String())
+	require.NoError(t, err)
+	requireThresholds(map[data.RoleName][2]int{data.CanonicalTargetsRole: {3, 3}})
+
+	// the server only manages one key
+	_, err = runCommand(t, tempDir, "-s", server.URL, "key", "rotate", "gun", data.CanonicalSnapshotRole.String(), "-r", "--threshold", "2")
+	require.Error(t, err)
+
+	output := assertSuccessfullyPublish(t, tempDir, server.URL, "gun", "target2", tempFile.Name())
+	require.Contains(t, output, "target")
+}
+
 func TestInitWithRootCert(t *testing.T) {
 	setUp(t)
 
@@ -312,6 +396,395 @@ i85wnaTwOgWv8n6q3tavmnIA/v2QqsTpmI+bhwrPNKQ=
 
 // Initializes a repo, adds a target, publishes the target, lists the target,
 // verifies the target, and then removes the target.
+// Lists the trusted collections on the server
+func TestCatalog(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	// -- tests --
+	output, err := runCommand(t, tempDir, "-s", server.URL, "catalog")
+	require.NoError(t, err)
+	require.Contains(t, output, "No trusted collections found")
+
+	for _, gun := range []string{"docker.io/notary", "docker.io/alpine", "quay.io/notary"} {
+		_, err = runCommand(t, tempDir, "-s", server.URL, "init", gun, "-p")
+		require.NoError(t, err)
+	}
+	// repos which have not been published are not on the server
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "docker.io/unpublished")
+	require.NoError(t, err)
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "catalog")
+	require.NoError(t, err)
+	require.Equal(t, "docker.io/alpine\ndocker.io/notary\nquay.io/notary\n", output)
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "catalog", "--prefix", "docker.io/")
+	require.NoError(t, err)
+	require.Equal(t, "docker.io/alpine\ndocker.io/notary\n", output)
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "catalog", "docker.io/")
+	require.Error(t, err)
+}
+
+// The catalog is read a page at a time
+func TestGetCatalogPages(t *testing.T) {
+	metaStore := storage.NewMemStorage()
+	var expected []data.GUN
+	for i := 0; i < notary.DefaultPageSize+10; i++ {
+		gun := data.GUN(fmt.Sprintf("docker.io/%03d", i))
+		require.NoError(t, metaStore.UpdateCurrent(gun, storage.MetaUpdate{Role: data.CanonicalRootRole, Version: 1, Data: []byte("{}")}))
+		expected = append(expected, gun)
+	}
+	server := httptest.NewServer(setupServerHandler(metaStore))
+	defer server.Close()
+
+	guns, err := getCatalog(server.URL, http.DefaultTransport, "docker.io/")
+	require.NoError(t, err)
+	require.Equal(t, expected, guns)
+}
+
+// list and lookup can show a trusted collection as it was at a snapshot
+// version or a time
+func TestListAndLookupAt(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	var tempFiles, digests []string
+	for _, content := range []string{"first", "second"} {
+		tempFile, err := ioutil.TempFile("", "targetfile")
+		require.NoError(t, err)
+		_, err = tempFile.WriteString(content)
+		require.NoError(t, err)
+		tempFile.Close()
+		defer os.Remove(tempFile.Name())
+		tempFiles = append(tempFiles, tempFile.Name())
+		digest := sha256.Sum256([]byte(content))
+		digests = append(digests, hex.EncodeToString(digest[:]))
+	}
+
+	// -- tests --
+	// publishing the new repo makes version 2 of the snapshot, and each
+	// target version after it one more
+	_, err := runCommand(t, tempDir, "-s", server.URL, "init", "gun", "-p")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "-s", server.URL, "add", "gun", "v1", tempFiles[0], "-p")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "-s", server.URL, "add", "gun", "v1", tempFiles[1], "-p")
+	require.NoError(t, err)
+
+	output, err := runCommand(t, tempDir, "-s", server.URL, "list", "gun", "--at", "2")
+	require.NoError(t, err)
+	require.Contains(t, output, "No targets present")
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "lookup", "gun", "v1", "--at", "3")
+	require.NoError(t, err)
+	require.Contains(t, output, digests[0])
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun", "--at", "4")
+	require.NoError(t, err)
+	require.Contains(t, output, digests[1])
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "lookup", "gun", "v1",
+		"--at", time.Now().Add(time.Minute).Format(time.RFC3339))
+	require.NoError(t, err)
+	require.Contains(t, output, digests[1])
+
+	for _, at := range []string{"5", time.Now().Add(-time.Hour).Format(time.RFC3339), "yesterday"} {
+		_, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun", "--at", at)
+		require.Error(t, err, at)
+	}
+}
+
+// diff shows the changes between two snapshot versions, or those staged
+func TestDiff(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	tempFile, err := ioutil.TempFile("", "targetfile")
+	require.NoError(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	// -- tests --
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "add", "gun", "v1", tempFile.Name())
+	require.NoError(t, err)
+
+	output, err := runCommand(t, tempDir, "-s", server.URL, "diff", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "Root changed from version 0 to version 1")
+	require.Regexp(t, `added\s+v1\s+targets`, output)
+	require.Regexp(t, `added\s+snapshot`, output)
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "gun")
+	require.NoError(t, err)
+	output, err = runCommand(t, tempDir, "-s", server.URL, "diff", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "No differences found")
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "add", "gun", "v2", tempFile.Name(), "-p")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "-s", server.URL, "remove", "gun", "v1", "-p")
+	require.NoError(t, err)
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "diff", "gun", "2", "4")
+	require.NoError(t, err)
+	require.Regexp(t, `removed\s+v1\s+targets`, output)
+	require.Regexp(t, `added\s+v2\s+targets`, output)
+	require.NotContains(t, output, "Root changed")
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "diff", "gun", "2", "3", "--json")
+	require.NoError(t, err)
+	var diff client.RepoDiff
+	require.NoError(t, json.Unmarshal([]byte(output), &diff))
+	require.Len(t, diff.Targets, 1)
+	require.Equal(t, "v2", diff.Targets[0].Name)
+	require.Equal(t, client.DiffAdded, diff.Targets[0].Type)
+	require.Empty(t, diff.Roles)
+
+	for _, args := range [][]string{{"gun", "2"}, {"gun", "2", "nope"}, {"gun", "0", "2"}, {"gun", "2", "5"}} {
+		_, err = runCommand(t, tempDir, append([]string{"-s", server.URL, "diff"}, args...)...)
+		require.Error(t, err, strings.Join(args, " "))
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	tempFile, err := ioutil.TempFile("", "targetfile")
+	require.NoError(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	customFile := filepath.Join(tempDir, "custom.json")
+	require.NoError(t, ioutil.WriteFile(customFile, []byte(`{"built":"today"}`), 0600))
+
+	// -- tests --
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "add", "gun", "v1", tempFile.Name(), "--custom", customFile)
+	require.NoError(t, err)
+
+	output, err := runCommand(t, tempDir, "status", "gun", "--output", "json")
+	require.NoError(t, err)
+	var changes []changeOutput
+	require.NoError(t, json.Unmarshal([]byte(output), &changes))
+	require.Equal(t, []changeOutput{{Index: 0, Action: "create", Scope: data.CanonicalTargetsRole, Type: "target", Path: "v1"}}, changes)
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "gun")
+	require.NoError(t, err)
+	output, err = runCommand(t, tempDir, "status", "gun", "--output", "json")
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", output)
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun", "--output", "json")
+	require.NoError(t, err)
+	var targets []targetOutput
+	require.NoError(t, json.Unmarshal([]byte(output), &targets))
+	require.Len(t, targets, 1)
+	require.Equal(t, "v1", targets[0].Name)
+	require.Equal(t, data.CanonicalTargetsRole, targets[0].Role)
+	require.Len(t, targets[0].Hashes[notary.SHA256], sha256.Size)
+	require.NotNil(t, targets[0].Custom)
+	require.JSONEq(t, `{"built":"today"}`, string(*targets[0].Custom))
+
+	// YAML has the same schema as JSON
+	output, err = runCommand(t, tempDir, "-s", server.URL, "lookup", "gun", "v1", "--output", "yaml")
+	require.NoError(t, err)
+	require.Contains(t, output, "name: v1\n")
+	require.Contains(t, output, "role: targets\n")
+	require.Contains(t, output, "built: today\n")
+
+	output, err = runCommand(t, tempDir, "key", "list", "--output", "json")
+	require.NoError(t, err)
+	var keys []keyOutput
+	require.NoError(t, json.Unmarshal([]byte(output), &keys))
+	require.Len(t, keys, 3)
+	require.Equal(t, data.CanonicalRootRole, keys[0].Role)
+	require.Empty(t, keys[0].GUN)
+	require.Equal(t, data.CanonicalSnapshotRole, keys[1].Role)
+	require.Equal(t, data.CanonicalTargetsRole, keys[2].Role)
+	require.Equal(t, data.GUN("gun"), keys[2].GUN)
+	require.NotEmpty(t, keys[2].Location)
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "delegation", "list", "gun", "--output", "json")
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", output)
+
+	// on commands which write a file, -o/--output names the file rather than the format
+	exported := filepath.Join(tempDir, "exported.pem")
+	_, err = runCommand(t, tempDir, "key", "export", "--output", exported)
+	require.NoError(t, err)
+	pemBytes, err := ioutil.ReadFile(exported)
+	require.NoError(t, err)
+	require.Contains(t, string(pemBytes), "PRIVATE KEY")
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun", "--output", "xml")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "output format must be one of")
+}
+
+func TestBatchTargets(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	sha256Hex := strings.Repeat("ab", sha256.Size)
+	sha512Hex := strings.Repeat("cd", sha512.Size)
+
+	jsonManifest := filepath.Join(tempDir, "targets.json")
+	require.NoError(t, ioutil.WriteFile(jsonManifest, []byte(fmt.Sprintf(`[
+		{"name": "v1", "length": 10, "hashes": {"sha256": %q}},
+		{"name": "v2", "length": 20, "hashes": {"sha256": %q, "sha512": %q}, "custom": {"built": "today"}}
+	]`, sha256Hex, sha256Hex, sha512Hex)), 0600))
+	csvManifest := filepath.Join(tempDir, "targets.csv")
+	require.NoError(t, ioutil.WriteFile(csvManifest, []byte(fmt.Sprintf(
+		"name,length,sha256,custom\nv3,30,%s,\"{\"\"built\"\":\"\"yesterday\"\"}\"\n", sha256Hex)), 0600))
+	invalidManifest := filepath.Join(tempDir, "invalid.csv")
+	require.NoError(t, ioutil.WriteFile(invalidManifest, []byte(fmt.Sprintf(
+		"name,length,sha256\nv4,40,%s\nv5,50,nothex\n", sha256Hex)), 0600))
+
+	// -- tests --
+	_, err := runCommand(t, tempDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+
+	// nothing is staged if any target in the manifest is invalid
+	_, err = runCommand(t, tempDir, "add-batch", "gun", "--manifest", invalidManifest)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "manifest entry 2")
+	_, err = runCommand(t, tempDir, "add-batch", "gun")
+	require.Error(t, err)
+
+	output, err := runCommand(t, tempDir, "add-batch", "gun", "--manifest", jsonManifest)
+	require.NoError(t, err)
+	require.Contains(t, output, "Addition of 2 targets")
+	_, err = runCommand(t, tempDir, "add-batch", "gun", "--manifest", csvManifest)
+	require.NoError(t, err)
+
+	output, err = runCommand(t, tempDir, "status", "gun", "--output", "json")
+	require.NoError(t, err)
+	var changes []changeOutput
+	require.NoError(t, json.Unmarshal([]byte(output), &changes))
+	require.Len(t, changes, 2)
+	require.Equal(t, "2 targets", changes[0].Path)
+	require.Equal(t, "1 target", changes[1].Path)
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "gun")
+	require.NoError(t, err)
+	output, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun", "--output", "json")
+	require.NoError(t, err)
+	var targets []targetOutput
+	require.NoError(t, json.Unmarshal([]byte(output), &targets))
+	require.Len(t, targets, 3)
+	require.Equal(t, "v2", targets[1].Name)
+	require.EqualValues(t, 20, targets[1].Length)
+	require.Equal(t, sha512Hex, hex.EncodeToString(targets[1].Hashes[notary.SHA512]))
+	require.JSONEq(t, `{"built":"today"}`, string(*targets[1].Custom))
+	require.JSONEq(t, `{"built":"yesterday"}`, string(*targets[2].Custom))
+
+	// only the names are needed to remove targets
+	removeManifest := filepath.Join(tempDir, "remove.csv")
+	require.NoError(t, ioutil.WriteFile(removeManifest, []byte("name\nv1\nv3\n"), 0600))
+	output, err = runCommand(t, tempDir, "-s", server.URL, "remove-batch", "gun", "--manifest", removeManifest, "-p")
+	require.NoError(t, err)
+	require.Contains(t, output, "Removal of 2 targets")
+	output, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "v2")
+	require.NotContains(t, output, "v1")
+	require.NotContains(t, output, "v3")
+}
+
+func TestNamedChangelists(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	tempFile, err := ioutil.TempFile("", "targetfile")
+	require.NoError(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+	exported := filepath.Join(tempDir, "release.json")
+
+	// -- tests --
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "add", "gun", "default", tempFile.Name())
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "--changelist", "release-42", "add", "gun", "release", tempFile.Name())
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "--changelist", "bad/name", "add", "gun", "bad", tempFile.Name())
+	require.Error(t, err)
+
+	output, err := runCommand(t, tempDir, "--changelist", "release-42", "status", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "release")
+	require.NotContains(t, output, "default")
+
+	output, err = runCommand(t, tempDir, "changelist", "list", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "release-42")
+
+	// hand the changes over to another changelist, as if to someone else
+	_, err = runCommand(t, tempDir, "--changelist", "release-42", "changelist", "export", "gun", "-o", exported)
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "changelist", "import", "other", exported)
+	require.Error(t, err)
+	output, err = runCommand(t, tempDir, "--changelist", "handover", "changelist", "import", "gun", exported)
+	require.NoError(t, err)
+	require.Contains(t, output, "Imported 1 changes")
+	_, err = runCommand(t, tempDir, "--changelist", "release-42", "reset", "gun", "--all")
+	require.NoError(t, err)
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "--changelist", "handover", "publish", "gun")
+	require.NoError(t, err)
+	output, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "release")
+	require.NotContains(t, output, "default")
+
+	// the default changelist is untouched
+	output, err = runCommand(t, tempDir, "status", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "default")
+}
+
 func TestClientTUFInteraction(t *testing.T) {
 	// -- setup --
 	setUp(t)
@@ -782,6 +1255,195 @@ func TestClientTUFAddByHashInteraction(t *testing.T) {
 	require.Contains(t, output, target4)
 }
 
+// Initialize repo and test that targets which are revoked or outside their
+// validity window are rejected by lookup and verify unless the lifecycle is ignored
+func TestClientTUFTargetLifecycle(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	target256Bytes := sha256.Sum256(nil)
+	targetSHA256Hex := hex.EncodeToString(target256Bytes[:])
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	// -- tests --
+
+	// init repo
+	_, err := runCommand(t, tempDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+
+	// an empty validity window is rejected
+	_, err = runCommand(t, tempDir, "addhash", "gun", "empty", "0", "--sha256", targetSHA256Hex,
+		"--not-before", future, "--not-after", past)
+	require.Error(t, err)
+
+	// so is a badly formatted time
+	_, err = runCommand(t, tempDir, "addhash", "gun", "empty", "0", "--sha256", targetSHA256Hex,
+		"--not-after", "tomorrow")
+	require.Error(t, err)
+
+	_, err = runCommand(t, tempDir, "addhash", "gun", "current", "0", "--sha256", targetSHA256Hex,
+		"--not-before", past, "--not-after", future)
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "addhash", "gun", "expired", "0", "--sha256", targetSHA256Hex,
+		"--not-after", past)
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "addhash", "gun", "upcoming", "0", "--sha256", targetSHA256Hex,
+		"--not-before", future)
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "addhash", "gun", "revoked", "0", "--sha256", targetSHA256Hex,
+		"--revoked", "compromised")
+	require.NoError(t, err)
+
+	// publish repo
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "gun")
+	require.NoError(t, err)
+
+	// list repo - see all targets
+	output, err := runCommand(t, tempDir, "-s", server.URL, "list", "gun")
+	require.NoError(t, err)
+	for _, target := range []string{"current", "expired", "upcoming", "revoked"} {
+		require.Contains(t, output, target)
+	}
+
+	// targets within their validity window can be looked up and verified
+	output, err = runCommand(t, tempDir, "-s", server.URL, "lookup", "gun", "current")
+	require.NoError(t, err)
+	require.Contains(t, output, "current")
+	_, err = runCommand(t, tempDir, "-s", server.URL, "verify", "gun", "current")
+	require.NoError(t, err)
+
+	for _, target := range []string{"expired", "upcoming", "revoked"} {
+		_, err = runCommand(t, tempDir, "-s", server.URL, "lookup", "gun", target)
+		require.Error(t, err)
+		_, err = runCommand(t, tempDir, "-s", server.URL, "verify", "gun", target)
+		require.Error(t, err)
+
+		output, err = runCommand(t, tempDir, "-s", server.URL, "lookup", "gun", target, "--ignore-lifecycle")
+		require.NoError(t, err)
+		require.Contains(t, output, target)
+		_, err = runCommand(t, tempDir, "-s", server.URL, "verify", "gun", target, "--ignore-lifecycle")
+		require.NoError(t, err)
+	}
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "lookup", "gun", "revoked")
+	require.Contains(t, err.Error(), "compromised")
+}
+
+// Metadata published by the client expires according to the expiry policy in
+// the config, unless overridden with publish --expires for the changed roles
+func TestClientTUFExpiry(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, `{"expiry": [
+		{"gun_prefix": "prod/", "role": "targets", "expiry": "48h"},
+		{"gun_prefix": "prod/", "role": "snapshot", "expiry": "24h"}
+	]}`)
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	target256Bytes := sha256.Sum256(nil)
+	targetSHA256Hex := hex.EncodeToString(target256Bytes[:])
+
+	// the local cache is refreshed from the server by listing
+	requireExpires := func(role data.RoleName, expected time.Duration) {
+		_, err := runCommand(t, tempDir, "-s", server.URL, "list", "prod/app")
+		require.NoError(t, err)
+		raw, err := ioutil.ReadFile(filepath.Join(tempDir, "tuf", "prod", "app", "metadata", role.String()+".json"))
+		require.NoError(t, err)
+		meta := &data.SignedMeta{}
+		require.NoError(t, json.Unmarshal(raw, meta))
+		require.WithinDuration(t, time.Now().Add(expected), meta.Signed.Expires, time.Minute, "%s", role)
+	}
+
+	// -- tests --
+
+	_, err := runCommand(t, tempDir, "-s", server.URL, "init", "prod/app", "-p")
+	require.NoError(t, err)
+	requireExpires(data.CanonicalTargetsRole, 48*time.Hour)
+	requireExpires(data.CanonicalSnapshotRole, 24*time.Hour)
+
+	_, err = runCommand(t, tempDir, "addhash", "prod/app", "a", "0", "--sha256", targetSHA256Hex)
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "prod/app", "--expires", "2h")
+	require.NoError(t, err)
+	requireExpires(data.CanonicalTargetsRole, 2*time.Hour)
+	// the snapshot was not changed, so keeps its configured expiry
+	requireExpires(data.CanonicalSnapshotRole, 24*time.Hour)
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "prod/app", "--expires", "-2h")
+	require.Error(t, err)
+}
+
+// Initialize repo, export it to a bundle, and import the bundle into a trust
+// directory which has never been able to reach the server
+func TestClientBundleExportImport(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+	offlineDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(offlineDir)
+
+	server := setupServer()
+	defer server.Close()
+	// nothing is listening here
+	offlineServer := httptest.NewServer(http.NotFoundHandler())
+	offlineServer.Close()
+
+	target256Bytes := sha256.Sum256(nil)
+	targetSHA256Hex := hex.EncodeToString(target256Bytes[:])
+	bundleFile := filepath.Join(tempDir, "gun.tar")
+
+	// -- tests --
+
+	// init repo
+	_, err := runCommand(t, tempDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "addhash", "gun", "v1", "0", "--sha256", targetSHA256Hex)
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "gun")
+	require.NoError(t, err)
+
+	// an output file is required
+	_, err = runCommand(t, tempDir, "-s", server.URL, "bundle", "export", "gun")
+	require.Error(t, err)
+
+	output, err := runCommand(t, tempDir, "-s", server.URL, "bundle", "export", "gun", "-o", bundleFile)
+	require.NoError(t, err)
+	require.Contains(t, output, bundleFile)
+
+	// the offline trust directory knows nothing about the GUN yet
+	_, err = runCommand(t, offlineDir, "-s", offlineServer.URL, "list", "gun")
+	require.Error(t, err)
+
+	// a file which isn't a bundle can't be imported
+	_, err = runCommand(t, offlineDir, "bundle", "import", filepath.Join(tempDir, "config.json"))
+	require.Error(t, err)
+
+	output, err = runCommand(t, offlineDir, "bundle", "import", bundleFile)
+	require.NoError(t, err)
+	require.Contains(t, output, "gun")
+
+	output, err = runCommand(t, offlineDir, "-s", offlineServer.URL, "list", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "v1")
+	output, err = runCommand(t, offlineDir, "-s", offlineServer.URL, "lookup", "gun", "v1")
+	require.NoError(t, err)
+	require.Contains(t, output, "v1")
+}
+
 // Initialize repo and test delegations commands by adding, listing, and removing delegations
 func TestClientDelegationsInteraction(t *testing.T) {
 	setUp(t)
@@ -3027,3 +3689,61 @@ func TestDelegationKeyImportExport(t *testing.T) {
 	_, err = runCommand(t, tempImportingDir, "key", "import", filepath.Join(tempExportedDir, "exported"))
 	require.NoError(t, err)
 }
+
+// Tests exporting, signing and publishing a sign request:
+//  1. init and publish a repo
+//  2. stage a target and export a sign request for the targets role
+//  3. signing the request from a trust dir without any of the keys fails
+//  4. publish the request and ensure the target is listed and no longer staged
+//  5. publishing the same request again fails
+func TestSignRequest(t *testing.T) {
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+	otherDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(otherDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	targetHash := "9d9e890af64dd0f44b8a1538ff5fa0511cc31bf1ab89f3a3522a9a581a70fad8"
+	reqFile := filepath.Join(tempDir, "targets.signrequest")
+
+	// 1. init and publish a repo
+	_, err := runCommand(t, tempDir, "-s", server.URL, "init", "gun", "-p")
+	require.NoError(t, err)
+
+	// 2. stage a target and export a sign request for the targets role
+	_, err = runCommand(t, tempDir, "addhash", "gun", "test_target", "100", "--sha256", targetHash)
+	require.NoError(t, err)
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "sign-request", "export", "gun", "targets")
+	require.Error(t, err, "an output file must be provided")
+
+	output, err := runCommand(t, tempDir, "-s", server.URL, "sign-request", "export", "gun", "targets", "-o", reqFile)
+	require.NoError(t, err)
+	require.Contains(t, output, "targets: 1 of 1 required signatures")
+	require.Contains(t, output, "ready to be published")
+
+	// 3. signing the request from a trust dir without any of the keys fails
+	_, err = runCommand(t, otherDir, "sign-request", "sign", reqFile)
+	require.Error(t, err)
+
+	// 4. publish the request and ensure the target is listed and no longer staged
+	output, err = runCommand(t, tempDir, "-s", server.URL, "sign-request", "publish", reqFile)
+	require.NoError(t, err)
+	require.Contains(t, output, "Successfully published targets")
+
+	output, err = runCommand(t, tempDir, "status", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "No unpublished changes for gun")
+
+	output, err = runCommand(t, otherDir, "-s", server.URL, "list", "gun")
+	require.NoError(t, err)
+	require.Contains(t, output, "test_target")
+
+	// 5. publishing the same request again fails
+	_, err = runCommand(t, tempDir, "-s", server.URL, "sign-request", "publish", reqFile)
+	require.Error(t, err)
+}
@@ -88,6 +88,7 @@ type keyCommander struct {
 	rotateKeyRole          string
 	rotateKeyServerManaged bool
 	rotateKeyFiles         []string
+	rotateKeyThreshold     int
 	legacyVersions         int
 	input                  io.Reader
 
@@ -127,8 +128,10 @@ func (k *keyCommander) GetCommand() *cobra.Command {
 		"key",
 		"k",
 		nil,
-		"New key(s) to rotate to. If not specified, one will be generated.",
+		"New key(s) to rotate to. If not specified, enough to meet the threshold will be generated.",
 	)
+	cmdRotateKey.Flags().IntVar(&k.rotateKeyThreshold, "threshold", 0,
+		"Number of the new keys which have to sign the role. If not specified, the role's threshold is unchanged.")
 	cmd.AddCommand(cmdRotateKey)
 
 	cmdKeysImport := cmdKeyImportTemplate.ToCommand(k.importKeys)
@@ -176,6 +179,9 @@ func (k *keyCommander) keysList(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if format := getOutputFormat(config); format != outputTable {
+		return writeStructured(format, keysOutput(ks), cmd.OutOrStdout())
+	}
 	cmd.Println("")
 	prettyPrintKeys(ks, cmd.OutOrStdout())
 	cmd.Println("")
@@ -188,7 +194,7 @@ func (k *keyCommander) keysGenerate(cmd *cobra.Command, args []string) error {
 	if len(args) > 1 {
 		cmd.Usage()
 		return fmt.Errorf(
-			"Please provide only one Algorithm as an argument to generate (rsa, ecdsa)")
+			"Please provide only one Algorithm as an argument to generate (ecdsa, ecdsa-p384)")
 	}
 
 	// If no param is given to generate, generates an ecdsa key by default
@@ -200,11 +206,15 @@ func (k *keyCommander) keysGenerate(cmd *cobra.Command, args []string) error {
 	}
 
 	allowedCiphers := map[string]bool{
-		data.ECDSAKey: true,
+		data.ECDSAKey:     true,
+		data.ECDSAP384Key: true,
 	}
 
 	if !allowedCiphers[algorithm] {
-		return fmt.Errorf("Algorithm not allowed, possible values are: ECDSA")
+		return fmt.Errorf("Algorithm not allowed, possible values are: ECDSA, ECDSA-P384")
+	}
+	if algorithm == data.ECDSAP384Key && data.RoleName(k.generateRole) == data.CanonicalRootRole {
+		return fmt.Errorf("%s keys cannot be used for the root role", data.ECDSAP384Key)
 	}
 
 	config, err := k.configGetter()
@@ -282,6 +292,9 @@ func generateKeyToFile(role, algorithm string, retriever notary.PassRetriever, o
 		},
 		Bytes: pubKey.Public(),
 	}
+	if pubKey.Algorithm() == data.ECDSAP384Key {
+		pubPEM.Headers[tufutils.KeyAlgorithmHeader] = data.ECDSAP384Key
+	}
 	return ioutil.WriteFile(pubFile, pem.EncodeToMemory(&pubPEM), notary.PrivNoExecPerms)
 }
 
@@ -309,9 +322,14 @@ func (k *keyCommander) keysRotate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	nRepo, err := notaryclient.NewFileCachedRepository(
+	keyStores, err := getConfiguredKeyStores(config, k.getRetriever())
+	if err != nil {
+		return err
+	}
+
+	nRepo, err := notaryclient.NewFileCachedRepositoryWithKeyStores(
 		config.GetString("trust_dir"), gun, getRemoteTrustServer(config),
-		rt, k.getRetriever(), trustPin)
+		rt, k.getRetriever(), trustPin, keyStores...)
 	if err != nil {
 		return err
 	}
@@ -341,6 +359,9 @@ func (k *keyCommander) keysRotate(cmd *cobra.Command, args []string) error {
 		}
 	}
 	nRepo.SetLegacyVersions(k.legacyVersions)
+	if k.rotateKeyThreshold != 0 {
+		nRepo.SetRoleThresholds(map[data.RoleName]int{rotateKeyRole: k.rotateKeyThreshold})
+	}
 	if err := nRepo.RotateKey(rotateKeyRole, k.rotateKeyServerManaged, keyList); err != nil {
 		return err
 	}
@@ -600,6 +621,11 @@ func (k *keyCommander) getKeyStores(
 	ks := []trustmanager.KeyStore{fileKeyStore}
 
 	if withHardware {
+		configured, err := getConfiguredKeyStores(config, retriever)
+		if err != nil {
+			return nil, err
+		}
+
 		var yubiStore trustmanager.KeyStore
 		if hardwareBackup {
 			yubiStore, err = getYubiStore(fileKeyStore, retriever)
@@ -611,6 +637,8 @@ func (k *keyCommander) getKeyStores(
 			// the yubikey store
 			ks = []trustmanager.KeyStore{yubiStore, fileKeyStore}
 		}
+		// the configured stores take priority over any yubikey
+		ks = append(configured, ks...)
 	}
 
 	return ks, nil
@@ -5,6 +5,7 @@ package main
 import (
 	"errors"
 
+	"github.com/spf13/viper"
 	"github.com/theupdateframework/notary"
 	store "github.com/theupdateframework/notary/storage"
 	"github.com/theupdateframework/notary/trustmanager"
@@ -14,6 +15,13 @@ func getYubiStore(fileKeyStore trustmanager.KeyStore, ret notary.PassRetriever)
 	return nil, errors.New("Not built with hardware support")
 }
 
+func getPKCS11Store(config *viper.Viper, ret notary.PassRetriever) (trustmanager.KeyStore, error) {
+	if !config.IsSet("pkcs11") {
+		return nil, nil
+	}
+	return nil, errors.New("Not built with hardware support, so the pkcs11 section of the configuration cannot be used")
+}
+
 func getImporters(baseDir string, _ notary.PassRetriever) ([]trustmanager.Importer, error) {
 	fileStore, err := store.NewPrivateKeyFileStorage(baseDir, notary.KeyExtension)
 	if err != nil {
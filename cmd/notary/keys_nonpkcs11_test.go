@@ -152,3 +152,46 @@ func TestExportImportKeysNoYubikey(t *testing.T) {
 	require.Equal(t, cOrig.Bytes, block.Bytes)
 	require.Len(t, rest, 0)
 }
+
+// Without hardware support, a pkcs11 section in the configuration is an error
+// rather than being silently ignored
+func TestGetPKCS11StoreNotSupported(t *testing.T) {
+	ret := passphrase.ConstantRetriever("pass")
+
+	v := viper.New()
+	ks, err := getPKCS11Store(v, ret)
+	require.NoError(t, err)
+	require.Nil(t, ks)
+
+	v.Set("pkcs11", map[string]interface{}{
+		"module":      "/usr/lib/softhsm/libsofthsm2.so",
+		"token_label": "notary",
+	})
+	_, err = getPKCS11Store(v, ret)
+	require.Error(t, err)
+}
+
+// The key stores in the configuration are only opened for the commands which
+// sign, so a pkcs11 section only fails those without hardware support
+func TestConfigureRepoOnlyOpensKeyStoresToSign(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	v := viper.New()
+	v.SetDefault("trust_dir", tempBaseDir)
+	v.Set("pkcs11", map[string]interface{}{
+		"module":      "/usr/lib/softhsm/libsofthsm2.so",
+		"token_label": "notary",
+	})
+	ret := passphrase.ConstantRetriever("pass")
+
+	_, err = ConfigureRepo(v, ret, false, readOnly)("gun")
+	require.NoError(t, err)
+
+	_, err = ConfigureRepo(v, ret, false, readWrite)("gun")
+	require.Error(t, err)
+
+	_, err = configureSigningRepo(v, ret, false, readOnly)("gun")
+	require.Error(t, err)
+}
@@ -3,16 +3,36 @@
 package main
 
 import (
+	"github.com/spf13/viper"
 	"github.com/theupdateframework/notary"
 	store "github.com/theupdateframework/notary/storage"
 	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/trustmanager/pkcs11"
 	"github.com/theupdateframework/notary/trustmanager/yubikey"
+	"github.com/theupdateframework/notary/utils"
 )
 
 func getYubiStore(fileKeyStore trustmanager.KeyStore, ret notary.PassRetriever) (*yubikey.YubiStore, error) {
 	return yubikey.NewYubiStore(fileKeyStore, ret)
 }
 
+// getPKCS11Store returns a store for the keys in the PKCS#11 token in the
+// "pkcs11" section of the configuration, or nil if there is none
+func getPKCS11Store(config *viper.Viper, ret notary.PassRetriever) (trustmanager.KeyStore, error) {
+	if !config.IsSet("pkcs11") {
+		return nil, nil
+	}
+	pkcs11Config, err := utils.ParsePKCS11Config(config, "pkcs11")
+	if err != nil {
+		return nil, err
+	}
+	pkcs11Store, err := pkcs11.NewStore(*pkcs11Config, ret)
+	if err != nil {
+		return nil, err
+	}
+	return pkcs11Store, nil
+}
+
 func getImporters(baseDir string, ret notary.PassRetriever) ([]trustmanager.Importer, error) {
 
 	var importers []trustmanager.Importer
@@ -105,3 +105,49 @@ func TestGetImporters(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, importers, 2)
 }
+
+func TestGetPKCS11Store(t *testing.T) {
+	ret := passphrase.ConstantRetriever("pass")
+
+	v := viper.New()
+	ks, err := getPKCS11Store(v, ret)
+	require.NoError(t, err)
+	require.Nil(t, ks)
+
+	// the token label or slot is required
+	v.Set("pkcs11", map[string]interface{}{"module": "/usr/lib/softhsm/libsofthsm2.so"})
+	_, err = getPKCS11Store(v, ret)
+	require.Error(t, err)
+
+	v.Set("pkcs11", map[string]interface{}{
+		"module":      "/nonexistent/libsofthsm2.so",
+		"token_label": "notary",
+	})
+	ks, err = getPKCS11Store(v, ret)
+	require.Error(t, err)
+	require.Nil(t, ks)
+}
+
+// The token is only loaded for the commands which sign
+func TestConfigureRepoOnlyOpensKeyStoresToSign(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	v := viper.New()
+	v.SetDefault("trust_dir", tempBaseDir)
+	v.Set("pkcs11", map[string]interface{}{
+		"module":      "/nonexistent/libsofthsm2.so",
+		"token_label": "notary",
+	})
+	ret := passphrase.ConstantRetriever("pass")
+
+	_, err = ConfigureRepo(v, ret, false, readOnly)("gun")
+	require.NoError(t, err)
+
+	_, err = ConfigureRepo(v, ret, false, readWrite)("gun")
+	require.Error(t, err)
+
+	_, err = configureSigningRepo(v, ret, false, readOnly)("gun")
+	require.Error(t, err)
+}
@@ -836,3 +836,49 @@ func TestKeyGeneration(t *testing.T) {
 	_, err = runCommand(t, tempDir, "key", "import", filepath.Join(tempDir, "testkeys-key.pem"))
 	require.EqualError(t, err, "failed to import all keys: invalid key pem block")
 }
+
+func TestKeyGenerationP384(t *testing.T) {
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	output, err := runCommand(t, tempDir, "key", "generate", data.ECDSAP384Key, "--role", "targets")
+	require.NoError(t, err)
+	require.Contains(t, output, "Generated new ecdsa-p384 targets key")
+	assertNumKeys(t, tempDir, 0, 1, false)
+
+	// the key is still an ecdsa-p384 key when read back from the key store
+	fileStore, err := trustmanager.NewKeyFileStore(tempDir, passphrase.ConstantRetriever(testPassphrase))
+	require.NoError(t, err)
+	for keyID := range fileStore.ListKeys() {
+		privK, _, err := fileStore.GetKey(keyID)
+		require.NoError(t, err)
+		require.Equal(t, data.ECDSAP384Key, privK.Algorithm())
+		require.Equal(t, keyID, privK.ID())
+	}
+
+	// ecdsa-p384 keys cannot be wrapped in root certificates
+	_, err = runCommand(t, tempDir, "key", "generate", data.ECDSAP384Key)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot be used for the root role")
+
+	_, err = runCommand(t, tempDir, "key", "generate", data.ECDSAP384Key, "--role", "targets", "-o", filepath.Join(tempDir, "testkeys"))
+	require.NoError(t, err)
+
+	pub, err := ioutil.ReadFile(filepath.Join(tempDir, "testkeys.pem"))
+	require.NoError(t, err)
+	pubK, err := utils.ParsePEMPublicKey(pub)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAP384Key, pubK.Algorithm())
+
+	priv, err := ioutil.ReadFile(filepath.Join(tempDir, "testkeys-key.pem"))
+	require.NoError(t, err)
+	privK, err := utils.ParsePEMPrivateKey(priv, testPassphrase)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAP384Key, privK.Algorithm())
+	require.Equal(t, pubK.ID(), privK.ID())
+
+	// other algorithms are still rejected
+	_, err = runCommand(t, tempDir, "key", "generate", data.RSAKey)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "possible values are: ECDSA, ECDSA-P384")
+}
@@ -70,6 +70,9 @@ type notaryCommander struct {
 	tlsCAFile   string
 	tlsCertFile string
 	tlsKeyFile  string
+
+	outputFormat string
+	changelist   string
 }
 
 func (n *notaryCommander) parseConfig() (*viper.Viper, error) {
@@ -128,6 +131,18 @@ func (n *notaryCommander) parseConfig() (*viper.Viper, error) {
 	if n.remoteTrustServer != "" {
 		config.Set("remote_server.url", n.remoteTrustServer)
 	}
+	if n.outputFormat != "" {
+		config.Set("output", n.outputFormat)
+	}
+	if n.changelist != "" {
+		config.Set("changelist", n.changelist)
+	}
+	// errors are reported in the output format too, even if it was only set in
+	// the config file
+	n.outputFormat = getOutputFormat(config)
+	if err := validOutputFormat(n.outputFormat); err != nil {
+		return nil, err
+	}
 
 	// Expands all the possible ~/ that have been given, either through -d or config
 	// Otherwise just attempt to use whatever the user gave us
@@ -144,7 +159,7 @@ func (n *notaryCommander) GetCommand() *cobra.Command {
 		Short:         "Notary allows the creation of trusted collections.",
 		Long:          "Notary allows the creation and management of collections of signed targets, allowing the signing and validation of arbitrary content.",
 		SilenceUsage:  true, // we don't want to print out usage for EVERY error
-		SilenceErrors: true, // we do our own error reporting with fatalf
+		SilenceErrors: true, // we do our own error reporting with reportError
 		Run: func(cmd *cobra.Command, args []string) {
 			if n.version {
 				fmt.Printf("notary Version: %s, Git commit: %s, Go version: %s\n", version.NotaryVersion, version.GitCommit, runtime.Version())
@@ -174,6 +189,8 @@ func (n *notaryCommander) GetCommand() *cobra.Command {
 	notaryCmd.PersistentFlags().StringVar(&n.tlsCAFile, "tlscacert", "", "Trust certs signed only by this CA")
 	notaryCmd.PersistentFlags().StringVar(&n.tlsCertFile, "tlscert", "", "Path to TLS certificate file")
 	notaryCmd.PersistentFlags().StringVar(&n.tlsKeyFile, "tlskey", "", "Path to TLS key file")
+	notaryCmd.PersistentFlags().StringVar(&n.outputFormat, "output", "", "Output format of the commands which list or look up trust data: table, json or yaml.  Commands which write a file use -o/--output for the file instead")
+	notaryCmd.PersistentFlags().StringVar(&n.changelist, "changelist", "", "Name of the changelist to stage changes in and publish from, instead of the default one")
 
 	cmdKeyGenerator := &keyCommander{
 		configGetter: n.parseConfig,
@@ -191,8 +208,33 @@ func (n *notaryCommander) GetCommand() *cobra.Command {
 		retriever:    n.getRetriever(),
 	}
 
+	cmdSignRequestGenerator := &signRequestCommander{
+		configGetter: n.parseConfig,
+		getRetriever: n.getRetriever,
+	}
+
+	cmdBundleGenerator := &bundleCommander{
+		configGetter: n.parseConfig,
+		getRetriever: n.getRetriever,
+	}
+
+	cmdChangelistGenerator := &changelistCommander{
+		configGetter: n.parseConfig,
+		getRetriever: n.getRetriever,
+	}
+
+	cmdRootGenerator := &rootCommander{
+		configGetter: n.parseConfig,
+		getRetriever: n.getRetriever,
+		input:        os.Stdin,
+	}
+
 	notaryCmd.AddCommand(cmdKeyGenerator.GetCommand())
 	notaryCmd.AddCommand(cmdDelegationGenerator.GetCommand())
+	notaryCmd.AddCommand(cmdSignRequestGenerator.GetCommand())
+	notaryCmd.AddCommand(cmdBundleGenerator.GetCommand())
+	notaryCmd.AddCommand(cmdChangelistGenerator.GetCommand())
+	notaryCmd.AddCommand(cmdRootGenerator.GetCommand())
 
 	cmdTUFGenerator.AddToCommand(&notaryCmd)
 
@@ -202,10 +244,26 @@ func (n *notaryCommander) GetCommand() *cobra.Command {
 func main() {
 	notaryCommander := &notaryCommander{getRetriever: getPassphraseRetriever}
 	notaryCmd := notaryCommander.GetCommand()
-	if err := notaryCmd.Execute(); err != nil {
-		notaryCmd.Println("")
-		fatalf(err.Error())
+	err := notaryCmd.Execute()
+	closeKeyStores()
+	if err != nil {
+		os.Exit(notaryCommander.reportError(err, os.Stdout, os.Stderr))
+	}
+}
+
+// reportError prints the error a command failed with, and returns the exit
+// code for it.  If JSON or YAML output was asked for, the error is written in
+// that format to stderr.
+func (n *notaryCommander) reportError(err error, stdout, stderr io.Writer) int {
+	errType, code := classifyError(err)
+	if n.outputFormat == outputJSON || n.outputFormat == outputYAML {
+		structured := errorOutput{Error: err.Error(), Type: errType, ExitCode: code}
+		if writeStructured(n.outputFormat, structured, stderr) == nil {
+			return code
+		}
 	}
+	fmt.Fprintf(stdout, "\n* fatal: %s\n", err)
+	return code
 }
 
 func fatalf(format string, args ...interface{}) {
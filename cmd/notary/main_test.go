@@ -527,6 +527,79 @@ func TestConfigFileTrustPinning(t *testing.T) {
 	trustPin, err = getTrustPinning(config)
 	require.NoError(t, err)
 	require.Equal(t, "root-ca.crt", trustPin.CA["repo4"])
+
+	tempDir = tempDirWithConfig(t, `{
+		"trust_pinning": {
+		    "ca": {
+		        "repo5": "root-ca.crt"
+		    },
+		    "crl": {
+		        "repo5": "root-ca.crl"
+		    }
+		 }
+	}`)
+	defer os.RemoveAll(tempDir)
+	commander = &notaryCommander{
+		getRetriever: func() notary.PassRetriever { return passphrase.ConstantRetriever("pass") },
+		configFile:   filepath.Join(tempDir, "config.json"),
+	}
+
+	config, err = commander.parseConfig()
+	require.NoError(t, err)
+	trustPin, err = getTrustPinning(config)
+	require.NoError(t, err)
+	require.Equal(t, "root-ca.crl", trustPin.CRL["repo5"])
+
+	tempDir = tempDirWithConfig(t, fmt.Sprintf(`{
+		"trust_pinning": {
+		    "spki": {
+		        "repo6/*": ["%s"]
+		    },
+		    "tofu": {
+		        "repo6/*": false,
+		        "repo6/public": true
+		    },
+		    "min_pinned_keys": {
+		        "repo6/*": 2,
+		        "repo6/public": 0
+		    }
+		 }
+	}`, strings.Repeat("x", notary.SHA256HexSize)))
+	defer os.RemoveAll(tempDir)
+	commander = &notaryCommander{
+		getRetriever: func() notary.PassRetriever { return passphrase.ConstantRetriever("pass") },
+		configFile:   filepath.Join(tempDir, "config.json"),
+	}
+
+	config, err = commander.parseConfig()
+	require.NoError(t, err)
+	trustPin, err = getTrustPinning(config)
+	require.NoError(t, err)
+	require.Equal(t, []string{strings.Repeat("x", notary.SHA256HexSize)}, trustPin.SPKI["repo6/*"])
+	require.Equal(t, map[string]bool{"repo6/*": false, "repo6/public": true}, trustPin.TOFU)
+	require.Equal(t, map[string]int{"repo6/*": 2, "repo6/public": 0}, trustPin.MinPinnedKeys)
+
+	// Check that invalid SPKI, TOFU and minimum pinned keys formats fail
+	for _, invalid := range []string{
+		`"spki": {"repo7": "abc"}`,
+		`"tofu": {"repo7": "yes"}`,
+		`"min_pinned_keys": {"repo7": -1}`,
+		`"min_pinned_keys": {"repo7": 1.5}`,
+		`"min_pinned_keys": {"repo7": "2"}`,
+		`"min_pinned_keys": 2`,
+	} {
+		tempDir = tempDirWithConfig(t, fmt.Sprintf(`{"trust_pinning": {%s}}`, invalid))
+		defer os.RemoveAll(tempDir)
+		commander = &notaryCommander{
+			getRetriever: func() notary.PassRetriever { return passphrase.ConstantRetriever("pass") },
+			configFile:   filepath.Join(tempDir, "config.json"),
+		}
+
+		config, err = commander.parseConfig()
+		require.NoError(t, err)
+		_, err = getTrustPinning(config)
+		require.Error(t, err, invalid)
+	}
 }
 
 // sets the env vars to empty, and returns a function to reset them at the end
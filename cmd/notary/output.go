@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	canonicaljson "github.com/docker/go/canonical/json"
+	"github.com/spf13/viper"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gopkg.in/yaml.v2"
+)
+
+// The formats which --output accepts.  Table is what we have always printed,
+// for people; JSON and YAML have stable schemas, for scripts.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// Exit codes, so that scripts can tell why a command failed without parsing
+// its error message.  2 is left unused: it conventionally means incorrect
+// usage, which notary has always reported with the generic exit code.
+const (
+	exitGeneric             = 1
+	exitRepoNotInitialized  = 3
+	exitRepoNotExist        = 4
+	exitNotPublished        = 5
+	exitInvalidRole         = 6
+	exitRootRotationInvalid = 7
+)
+
+// validOutputFormat returns an error if the format is not one --output accepts
+func validOutputFormat(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("output format must be one of %s, %s or %s: %s", outputTable, outputJSON, outputYAML, format)
+}
+
+// getOutputFormat returns the format to print in, defaulting to a table
+func getOutputFormat(config *viper.Viper) string {
+	if format := config.GetString("output"); format != "" {
+		return format
+	}
+	return outputTable
+}
+
+// writeStructured writes v to the writer as JSON or YAML.  The YAML is
+// converted from the JSON, so both have the same keys and values.
+func writeStructured(format string, v interface{}, writer io.Writer) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == outputYAML {
+		var generic interface{}
+		if err := yaml.Unmarshal(out, &generic); err != nil {
+			return err
+		}
+		if out, err = yaml.Marshal(generic); err != nil {
+			return err
+		}
+		_, err = writer.Write(out)
+		return err
+	}
+	_, err = fmt.Fprintln(writer, string(out))
+	return err
+}
+
+// targetOutput is how a target is printed.  Hashes are base64 encoded, as in
+// the targets metadata.
+type targetOutput struct {
+	Name   string                    `json:"name"`
+	Role   data.RoleName             `json:"role"`
+	Length int64                     `json:"length"`
+	Hashes data.Hashes               `json:"hashes"`
+	Custom *canonicaljson.RawMessage `json:"custom,omitempty"`
+}
+
+func newTargetOutput(t *client.TargetWithRole) targetOutput {
+	return targetOutput{Name: t.Name, Role: t.Role, Length: t.Length, Hashes: t.Hashes, Custom: t.Custom}
+}
+
+// targetsOutput returns the targets sorted by name, as prettyPrintTargets
+// prints them
+func targetsOutput(ts []*client.TargetWithRole) []targetOutput {
+	sort.Stable(targetsSorter(ts))
+	out := make([]targetOutput, 0, len(ts))
+	for _, t := range ts {
+		out = append(out, newTargetOutput(t))
+	}
+	return out
+}
+
+// keyOutput is how a key in one of the key stores is printed
+type keyOutput struct {
+	ID       string        `json:"id"`
+	Role     data.RoleName `json:"role"`
+	GUN      data.GUN      `json:"gun"`
+	Location string        `json:"location"`
+}
+
+func keysOutput(keyStores []trustmanager.KeyStore) []keyOutput {
+	info := listKeyInfo(keyStores)
+	out := make([]keyOutput, 0, len(info))
+	for _, k := range info {
+		out = append(out, keyOutput{ID: k.keyID, Role: k.role, GUN: k.gun, Location: k.location})
+	}
+	return out
+}
+
+// delegationOutput is how a delegation role is printed
+type delegationOutput struct {
+	Name      data.RoleName `json:"name"`
+	Paths     []string      `json:"paths"`
+	Threshold int           `json:"threshold"`
+	KeyIDs    []string      `json:"key_ids"`
+}
+
+func delegationsOutput(rs []data.Role) []delegationOutput {
+	sort.Stable(roleSorter(rs))
+	out := make([]delegationOutput, 0, len(rs))
+	for _, r := range rs {
+		paths := append([]string{}, r.Paths...)
+		sort.Strings(paths)
+		out = append(out, delegationOutput{
+			Name:      r.Name,
+			Paths:     paths,
+			Threshold: r.Threshold,
+			KeyIDs:    append([]string{}, r.KeyIDs...),
+		})
+	}
+	return out
+}
+
+// changeOutput is how an unpublished change is printed.  The index is what
+// reset -n takes.
+type changeOutput struct {
+	Index  int           `json:"index"`
+	Action string        `json:"action"`
+	Scope  data.RoleName `json:"scope"`
+	Type   string        `json:"type"`
+	Path   string        `json:"path"`
+}
+
+func changesOutput(changes []changelist.Change) []changeOutput {
+	out := make([]changeOutput, 0, len(changes))
+	for i, ch := range changes {
+		out = append(out, changeOutput{Index: i, Action: ch.Action(), Scope: ch.Scope(), Type: ch.Type(), Path: ch.Path()})
+	}
+	return out
+}
+
+// errorOutput is how a failed command reports its error on stderr
+type errorOutput struct {
+	Error    string `json:"error"`
+	Type     string `json:"type"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// classifyError returns the type and exit code of an error returned by a
+// command
+func classifyError(err error) (string, int) {
+	switch err.(type) {
+	case client.ErrRepoNotInitialized:
+		return "repository_not_initialized", exitRepoNotInitialized
+	case client.ErrRepositoryNotExist:
+		return "repository_not_exist", exitRepoNotExist
+	case client.ErrNotPublished:
+		return "not_published", exitNotPublished
+	case client.ErrInvalidLocalRole, client.ErrInvalidRemoteRole:
+		return "invalid_role", exitInvalidRole
+	case client.ErrRootRotationInvalid:
+		return "root_rotation_invalid", exitRootRotationInvalid
+	}
+	return "error", exitGeneric
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWriteStructured(t *testing.T) {
+	delegations := delegationsOutput([]data.Role{
+		{Name: "targets/b", Paths: []string{"z", ""}, RootRole: data.RootRole{KeyIDs: []string{"1"}, Threshold: 1}},
+		{Name: "targets/a", RootRole: data.RootRole{Threshold: 2}},
+	})
+
+	var b bytes.Buffer
+	require.NoError(t, writeStructured(outputJSON, delegations, &b))
+	require.JSONEq(t, `[
+		{"name": "targets/a", "paths": [], "threshold": 2, "key_ids": []},
+		{"name": "targets/b", "paths": ["", "z"], "threshold": 1, "key_ids": ["1"]}
+	]`, b.String())
+
+	// the YAML decodes to the same thing as the JSON
+	var fromJSON, fromYAML interface{}
+	require.NoError(t, yaml.Unmarshal(b.Bytes(), &fromJSON))
+	b.Reset()
+	require.NoError(t, writeStructured(outputYAML, delegations, &b))
+	require.NoError(t, yaml.Unmarshal(b.Bytes(), &fromYAML))
+	require.Equal(t, fromJSON, fromYAML)
+}
+
+func TestReportError(t *testing.T) {
+	for err, expected := range map[error]errorOutput{
+		errors.New("oops"):                                            {Type: "error", ExitCode: exitGeneric},
+		client.ErrRepoNotInitialized{}:                                {Type: "repository_not_initialized", ExitCode: exitRepoNotInitialized},
+		client.ErrRepositoryNotExist{}:                                {Type: "repository_not_exist", ExitCode: exitRepoNotExist},
+		client.ErrNotPublished{}:                                      {Type: "not_published", ExitCode: exitNotPublished},
+		client.ErrInvalidRemoteRole{Role: data.CanonicalRootRole}:     {Type: "invalid_role", ExitCode: exitInvalidRole},
+		client.ErrInvalidLocalRole{Role: data.CanonicalTimestampRole}: {Type: "invalid_role", ExitCode: exitInvalidRole},
+	} {
+		expected.Error = err.Error()
+
+		// tables get the error on stdout, as they always have
+		var stdout, stderr bytes.Buffer
+		n := &notaryCommander{outputFormat: outputTable}
+		require.Equal(t, expected.ExitCode, n.reportError(err, &stdout, &stderr))
+		require.Equal(t, "\n* fatal: "+err.Error()+"\n", stdout.String())
+		require.Empty(t, stderr.String())
+
+		stdout.Reset()
+		n.outputFormat = outputJSON
+		require.Equal(t, expected.ExitCode, n.reportError(err, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		var actual errorOutput
+		require.NoError(t, json.Unmarshal(stderr.Bytes(), &actual))
+		require.Equal(t, expected, actual)
+
+		stderr.Reset()
+		n.outputFormat = outputYAML
+		require.Equal(t, expected.ExitCode, n.reportError(err, &stdout, &stderr))
+		require.Contains(t, stderr.String(), "type: "+expected.Type+"\n")
+	}
+
+	// errors which cannot be map keys
+	errType, exitCode := classifyError(client.ErrRootRotationInvalid{Versions: []int{1}})
+	require.Equal(t, "root_rotation_invalid", errType)
+	require.Equal(t, exitRootRotationInvalid, exitCode)
+}
@@ -93,9 +93,9 @@ func (k keyInfoSorter) Less(i, j int) bool {
 	return false
 }
 
-// Given a list of KeyStores in order of listing preference, pretty-prints the
-// root keys and then the signing keys.
-func prettyPrintKeys(keyStores []trustmanager.KeyStore, writer io.Writer) {
+// Given a list of KeyStores in order of listing preference, returns the root
+// keys and then the signing keys.
+func listKeyInfo(keyStores []trustmanager.KeyStore) []keyInfo {
 	var info []keyInfo
 
 	for _, store := range keyStores {
@@ -108,14 +108,19 @@ func prettyPrintKeys(keyStores []trustmanager.KeyStore, writer io.Writer) {
 			})
 		}
 	}
+	sort.Stable(keyInfoSorter(info))
+	return info
+}
 
+// Given a list of KeyStores in order of listing preference, pretty-prints the
+// root keys and then the signing keys.
+func prettyPrintKeys(keyStores []trustmanager.KeyStore, writer io.Writer) {
+	info := listKeyInfo(keyStores)
 	if len(info) == 0 {
 		writer.Write([]byte("No signing keys found.\n"))
 		return
 	}
 
-	sort.Stable(keyInfoSorter(info))
-
 	tw := initTabWriter([]string{"ROLE", "GUN", "KEY ID", "LOCATION"}, writer)
 
 	for _, oneKeyInfo := range info {
@@ -151,6 +156,17 @@ func (r roleSorter) Less(i, j int) bool {
 	return r[i].Name < r[j].Name
 }
 
+// Prints the GUNs in a catalog, one per line.
+func prettyPrintCatalog(guns []data.GUN, writer io.Writer) {
+	if len(guns) == 0 {
+		writer.Write([]byte("\nNo trusted collections found.\n\n"))
+		return
+	}
+	for _, gun := range guns {
+		fmt.Fprintln(writer, gun)
+	}
+}
+
 // Pretty-prints the sorted list of TargetWithRoles.
 func prettyPrintTargets(ts []*client.TargetWithRole, writer io.Writer) {
 	if len(ts) == 0 {
@@ -249,3 +265,169 @@ func prettyPaths(paths []string) []string {
 	}
 	return pp
 }
+
+// Pretty-prints how a repository changed: the root version, then the targets
+// and roles which were added, removed or changed
+func prettyPrintDiff(diff *client.RepoDiff, writer io.Writer) {
+	if diff.Empty() {
+		writer.Write([]byte("\nNo differences found.\n\n"))
+		return
+	}
+	if diff.FromRootVersion != diff.ToRootVersion {
+		fmt.Fprintf(writer, "\nRoot changed from version %d to version %d\n",
+			diff.FromRootVersion, diff.ToRootVersion)
+	}
+
+	if len(diff.Targets) > 0 {
+		fmt.Fprintln(writer)
+		tw := initTabWriter([]string{"CHANGE", "NAME", "ROLE", "DIGEST", "SIZE (BYTES)"}, writer)
+		for _, t := range diff.Targets {
+			// changed targets have a second row for what they changed to
+			var metas []*data.FileMeta
+			if t.From != nil {
+				metas = append(metas, t.From)
+			}
+			if t.To != nil {
+				metas = append(metas, t.To)
+			}
+			for i, meta := range metas {
+				change, name, role := string(t.Type), t.Name, t.Role.String()
+				if i > 0 {
+					change, name, role = "", "", ""
+				}
+				fmt.Fprintf(
+					tw,
+					fiveItemRow,
+					change,
+					name,
+					role,
+					hex.EncodeToString(meta.Hashes["sha256"]),
+					fmt.Sprintf("%d", meta.Length),
+				)
+			}
+		}
+		tw.Flush()
+	}
+
+	if len(diff.Roles) > 0 {
+		fmt.Fprintln(writer)
+		tw := initTabWriter([]string{"CHANGE", "ROLE", "PATHS", "KEY IDS", "THRESHOLD"}, writer)
+		for _, r := range diff.Roles {
+			paths := append(prefixAll("+", prettyPaths(r.PathsAdded)), prefixAll("-", prettyPaths(r.PathsRemoved))...)
+			keyIDs := append(prefixAll("+", r.KeysAdded), prefixAll("-", r.KeysRemoved)...)
+			var threshold, path, kid string
+			switch {
+			case r.Type == client.DiffAdded:
+				threshold = fmt.Sprintf("%d", r.ToThreshold)
+			case r.Type == client.DiffRemoved:
+				threshold = fmt.Sprintf("%d", r.FromThreshold)
+			case r.FromThreshold != r.ToThreshold:
+				threshold = fmt.Sprintf("%d -> %d", r.FromThreshold, r.ToThreshold)
+			}
+			if len(paths) > 0 {
+				path = paths[0]
+			}
+			if len(keyIDs) > 0 {
+				kid = keyIDs[0]
+			}
+			fmt.Fprintf(
+				tw,
+				fiveItemRow,
+				string(r.Type),
+				r.Role,
+				path,
+				kid,
+				threshold,
+			)
+			for i := 1; i < len(paths) || i < len(keyIDs); i++ {
+				path, kid = "", ""
+				if i < len(paths) {
+					path = paths[i]
+				}
+				if i < len(keyIDs) {
+					kid = keyIDs[i]
+				}
+				fmt.Fprintf(tw, fiveItemRow, "", "", path, kid, "")
+			}
+		}
+		tw.Flush()
+	}
+	fmt.Fprintln(writer)
+}
+
+// prefixAll returns the strings, each with the prefix
+func prefixAll(prefix string, strs []string) []string {
+	prefixed := make([]string, 0, len(strs))
+	for _, s := range strs {
+		prefixed = append(prefixed, prefix+s)
+	}
+	return prefixed
+}
+
+// Pretty-prints each version of the root which has to sign the new root, with
+// its keys and which of them are missing locally
+func prettyPrintRootRotationPlan(plan *client.RootRotationPlan, writer io.Writer) {
+	fmt.Fprintf(writer, "\nRoot key rotation for %s\n\n", plan.GUN)
+
+	tw := initTabWriter([]string{"ROOT", "VERSION", "THRESHOLD", "KEY ID", "AVAILABLE"}, writer)
+	printKeySet := func(name string, keySet client.RootKeySet) {
+		missing := make(map[string]bool, len(keySet.MissingKeyIDs))
+		for _, keyID := range keySet.MissingKeyIDs {
+			missing[keyID] = true
+		}
+		keyIDs := keySet.KeyIDs
+		if len(keyIDs) == 0 {
+			keyIDs = []string{"<generated>"}
+		}
+		for i, keyID := range keyIDs {
+			available := "yes"
+			if missing[keyID] {
+				available = "no"
+			}
+			// only the first row of each version has its details
+			if i == 0 {
+				fmt.Fprintf(tw, fiveItemRow, name, fmt.Sprint(keySet.Version), fmt.Sprint(keySet.Threshold), keyID, available)
+			} else {
+				fmt.Fprintf(tw, fiveItemRow, "", "", "", keyID, available)
+			}
+		}
+	}
+	printKeySet("current", plan.Current)
+	printKeySet("new", plan.New)
+	for _, keySet := range plan.Legacy {
+		printKeySet("legacy", keySet)
+	}
+	tw.Flush()
+
+	var cannotSign []string
+	for _, keySet := range append([]client.RootKeySet{plan.Current, plan.New}, plan.Legacy...) {
+		// a generated key is always available
+		if keySet.Version == plan.New.Version && plan.GenerateKey {
+			continue
+		}
+		if !keySet.CanSign() {
+			cannotSign = append(cannotSign, fmt.Sprint(keySet.Version))
+		}
+	}
+	if len(cannotSign) > 0 {
+		fmt.Fprintf(writer, "\nNot enough keys are available locally to meet the threshold of root versions %s\n",
+			strings.Join(cannotSign, ", "))
+	}
+	fmt.Fprintln(writer)
+}
+
+// Pretty-prints whether clients trusting each previous version of the root would
+// accept the new root
+func prettyPrintRootValidations(validations []client.RootValidation, writer io.Writer) {
+	fmt.Fprintln(writer)
+	tw := initTabWriter([]string{"TRUSTED ROOT VERSION", "ACCEPTS NEW ROOT", "REASON"}, writer)
+	for _, validation := range validations {
+		accepted := "yes"
+		if validation.Error != "" {
+			accepted = "no"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", validation.Version, accepted, validation.Error)
+	}
+	tw.Flush()
+	fmt.Fprintln(writer)
+}
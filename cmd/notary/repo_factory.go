@@ -1,13 +1,18 @@
 package main
 
 import (
-	"github.com/spf13/viper"
-
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
 
 	"github.com/theupdateframework/notary"
 	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/utils"
 )
 
 const remoteConfigField = "api"
@@ -15,10 +20,36 @@ const remoteConfigField = "api"
 // RepoFactory takes a GUN and returns an initialized client.Repository, or an error.
 type RepoFactory func(gun data.GUN) (client.Repository, error)
 
+// keyStoreCloser is implemented by the configured key stores which hold
+// something open until they are closed, such as the PKCS#11 module of a token
+type keyStoreCloser interface {
+	Close()
+}
+
+// openedKeyStores are the configured key stores which the running command has
+// opened, for closeKeyStores to close once it has finished
+var openedKeyStores struct {
+	sync.Mutex
+	closers []keyStoreCloser
+}
+
 // ConfigureRepo takes in the configuration parameters and returns a repoFactory that can
 // initialize new client.Repository objects with the correct upstreams and password
-// retrieval mechanisms.
+// retrieval mechanisms.  The key stores in the configuration, such as a PKCS#11
+// token, are only opened if the permission is more than readOnly, since reading
+// trust data needs no private keys.
 func ConfigureRepo(v *viper.Viper, retriever notary.PassRetriever, onlineOperation bool, permission httpAccess) RepoFactory {
+	return configureRepo(v, retriever, onlineOperation, permission, permission != readOnly)
+}
+
+// configureSigningRepo is ConfigureRepo for the commands which only read from
+// the server but still sign with the local keys, such as exporting a sign
+// request, so always opens the key stores in the configuration
+func configureSigningRepo(v *viper.Viper, retriever notary.PassRetriever, onlineOperation bool, permission httpAccess) RepoFactory {
+	return configureRepo(v, retriever, onlineOperation, permission, true)
+}
+
+func configureRepo(v *viper.Viper, retriever notary.PassRetriever, onlineOperation bool, permission httpAccess, withKeyStores bool) RepoFactory {
 	localRepo := func(gun data.GUN) (client.Repository, error) {
 		var rt http.RoundTripper
 		trustPin, err := getTrustPinning(v)
@@ -31,15 +62,103 @@ func ConfigureRepo(v *viper.Viper, retriever notary.PassRetriever, onlineOperati
 				return nil, err
 			}
 		}
-		return client.NewFileCachedRepository(
+		expiryPolicy, err := utils.ParseExpiryPolicy(v)
+		if err != nil {
+			return nil, err
+		}
+		retryPolicy, err := getPublishRetryPolicy(v)
+		if err != nil {
+			return nil, err
+		}
+		var keyStores []trustmanager.KeyStore
+		if withKeyStores {
+			keyStores, err = getConfiguredKeyStores(v, retriever)
+			if err != nil {
+				return nil, err
+			}
+		}
+		repo, err := client.NewFileCachedRepositoryWithKeyStores(
 			v.GetString("trust_dir"),
 			gun,
 			getRemoteTrustServer(v),
 			rt,
 			retriever,
 			trustPin,
+			keyStores...,
 		)
+		if err != nil {
+			return nil, err
+		}
+		repo.SetExpiryPolicy(expiryPolicy)
+		repo.SetPublishRetryPolicy(retryPolicy)
+		if err := useNamedChangelist(v, repo); err != nil {
+			return nil, err
+		}
+		return repo, nil
 	}
 
 	return localRepo
 }
+
+// getConfiguredKeyStores returns the key stores, other than the ones in the
+// trust directory, that the configuration says hold private keys.  The ones
+// which need closing are closed by closeKeyStores.
+func getConfiguredKeyStores(v *viper.Viper, retriever notary.PassRetriever) ([]trustmanager.KeyStore, error) {
+	pkcs11Store, err := getPKCS11Store(v, retriever)
+	if err != nil || pkcs11Store == nil {
+		return nil, err
+	}
+	if closer, ok := pkcs11Store.(keyStoreCloser); ok {
+		openedKeyStores.Lock()
+		openedKeyStores.closers = append(openedKeyStores.closers, closer)
+		openedKeyStores.Unlock()
+	}
+	return []trustmanager.KeyStore{pkcs11Store}, nil
+}
+
+// closeKeyStores closes the configured key stores which have been opened, and
+// should be called once the command has finished with them
+func closeKeyStores() {
+	openedKeyStores.Lock()
+	defer openedKeyStores.Unlock()
+	for _, closer := range openedKeyStores.closers {
+		closer.Close()
+	}
+	openedKeyStores.closers = nil
+}
+
+// getPublishRetryPolicy reads how publishes which conflict with someone else's
+// are retried from the "publish" section of the configuration, defaulting to
+// the client's default policy
+func getPublishRetryPolicy(v *viper.Viper) (client.PublishRetryPolicy, error) {
+	policy := client.DefaultPublishRetryPolicy
+	if v.IsSet("publish.retries") {
+		policy.Retries = v.GetInt("publish.retries")
+		if policy.Retries < 0 {
+			return policy, fmt.Errorf("publish.retries must not be negative: %d", policy.Retries)
+		}
+	}
+	if v.IsSet("publish.backoff") {
+		backoff, err := time.ParseDuration(v.GetString("publish.backoff"))
+		if err != nil || backoff < 0 {
+			return policy, fmt.Errorf("publish.backoff must be a non-negative duration: %v", v.Get("publish.backoff"))
+		}
+		policy.Backoff = backoff
+	}
+	return policy, nil
+}
+
+// useNamedChangelist makes the repository stage changes in, and publish them
+// from, the changelist named in the configuration, if there is one
+func useNamedChangelist(v *viper.Viper, repo client.Repository) error {
+	name := v.GetString("changelist")
+	if name == "" {
+		return nil
+	}
+	cl, err := client.NewNamedFileChangelist(v.GetString("trust_dir"), repo.GetGUN(), name)
+	if err != nil {
+		return err
+	}
+	repo.SetChangelist(cl)
+	return nil
+}
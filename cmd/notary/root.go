@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/theupdateframework/notary"
+	notaryclient "github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var cmdRootTemplate = usageTemplate{
+	Use:   "root",
+	Short: "Operates on the root role of a trusted collection.",
+	Long:  "Operations on the root role of a trusted collection, such as planning and carrying out a root key rotation.",
+}
+
+var cmdRootRotateTemplate = usageTemplate{
+	Use:   "rotate [ GUN ]",
+	Short: "Plans or carries out a root key rotation.",
+	Long:  "With --plan, shows the current and new root keys and thresholds, which previous versions of the root have to co-sign the new root so that clients trusting them can still update, and which of the keys needed to sign are not available locally. With --apply, rotates the root keys, after validating the new root as clients trusting the current and each of those previous versions of the root would. With --apply --dry-run, only validates the new root. This is an online operation.",
+}
+
+type rootCommander struct {
+	// these need to be set
+	configGetter func() (*viper.Viper, error)
+	getRetriever func() notary.PassRetriever
+	input        io.Reader
+
+	// these are for command line parsing - no need to set
+	plan           bool
+	apply          bool
+	dryRun         bool
+	rotateKeyFiles []string
+	threshold      int
+	legacyVersions int
+}
+
+func (r *rootCommander) GetCommand() *cobra.Command {
+	cmd := cmdRootTemplate.ToCommand(nil)
+
+	cmdRotate := cmdRootRotateTemplate.ToCommand(r.rootRotate)
+	cmdRotate.Flags().BoolVar(&r.plan, "plan", false, "Show what the root key rotation would do, without changing anything")
+	cmdRotate.Flags().BoolVar(&r.apply, "apply", false, "Rotate the root keys and publish the new root")
+	cmdRotate.Flags().BoolVar(&r.dryRun, "dry-run", false, "With --apply, only validate the new root, without publishing it")
+	cmdRotate.Flags().StringSliceVarP(
+		&r.rotateKeyFiles,
+		"key",
+		"k",
+		nil,
+		"New root key(s) to rotate to. If not specified, enough to meet the threshold will be generated. They are only kept in the key store once the rotation is applied.",
+	)
+	cmdRotate.Flags().IntVar(&r.threshold, "threshold", 0,
+		"Number of the new root keys which have to sign the root. If not specified, the root threshold is unchanged.")
+	cmdRotate.Flags().IntVarP(&r.legacyVersions, "legacy", "l", 0,
+		"Number of old version's root roles to sign with to support old clients, or -1 for all of them")
+	cmd.AddCommand(cmdRotate)
+	return cmd
+}
+
+func (r *rootCommander) rootRotate(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN")
+	}
+	if r.plan == r.apply {
+		cmd.Usage()
+		return fmt.Errorf("Must specify exactly one of --plan and --apply")
+	}
+	if r.dryRun && !r.apply {
+		cmd.Usage()
+		return fmt.Errorf("--dry-run can only be used with --apply")
+	}
+	if r.legacyVersions < notaryclient.SignWithAllOldVersions {
+		return fmt.Errorf("--legacy must be -1 or a non-negative number of versions: %d", r.legacyVersions)
+	}
+	config, err := r.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := data.GUN(args[0])
+
+	permission := readOnly
+	if r.apply && !r.dryRun {
+		permission = admin
+	}
+	// plans and dry runs need the keys too, to report which of them can sign
+	fact := configureSigningRepo(config, r.getRetriever(), true, permission)
+	nRepo, err := fact(gun)
+	if err != nil {
+		return err
+	}
+
+	// The new keys have to be imported to plan or validate the rotation too, but they
+	// are only kept once it is carried out.  Keys which were already there are kept.
+	var keyList, importedKeys []string
+	keepKeys := false
+	cs := nRepo.GetCryptoService()
+	defer func() {
+		if keepKeys {
+			return
+		}
+		for _, keyID := range importedKeys {
+			if err := cs.RemoveKey(keyID); err != nil {
+				logrus.Warnf("unable to remove imported root key %s: %s", keyID, err)
+			}
+		}
+	}()
+	for _, keyFile := range r.rotateKeyFiles {
+		privKey, err := readKey(data.CanonicalRootRole, keyFile, r.getRetriever())
+		if err != nil {
+			return err
+		}
+		keyList = append(keyList, privKey.ID())
+		if _, _, err := cs.GetPrivateKey(privKey.ID()); err == nil {
+			continue
+		}
+		if err := cs.AddKey(data.CanonicalRootRole, gun, privKey); err != nil {
+			return fmt.Errorf("Error importing key: %v", err)
+		}
+		importedKeys = append(importedKeys, privKey.ID())
+	}
+	nRepo.SetLegacyVersions(r.legacyVersions)
+	if r.threshold != 0 {
+		nRepo.SetRoleThresholds(map[data.RoleName]int{data.CanonicalRootRole: r.threshold})
+	}
+
+	format := getOutputFormat(config)
+	if r.plan {
+		plan, err := nRepo.PlanRootRotation(keyList)
+		if err != nil {
+			return err
+		}
+		if format != outputTable {
+			return writeStructured(format, plan, cmd.OutOrStdout())
+		}
+		prettyPrintRootRotationPlan(plan, cmd.OutOrStdout())
+		return nil
+	}
+
+	if !r.dryRun {
+		cmd.Print("Warning: you are about to rotate your root key.\n\n" +
+			"You must use your old key to sign this root rotation.\n" +
+			"Are you sure you want to proceed?  (yes/no)  ")
+
+		if !askConfirm(r.input) {
+			fmt.Fprintln(cmd.OutOrStdout(), "\nAborting action.")
+			return nil
+		}
+	}
+	// Once the new root may have been published, its keys must not be lost
+	keepKeys = !r.dryRun
+	validations, rotateErr := nRepo.RotateRoot(keyList, r.dryRun)
+	if validations != nil {
+		if format != outputTable {
+			if err := writeStructured(format, validations, cmd.OutOrStdout()); err != nil {
+				return err
+			}
+		} else {
+			prettyPrintRootValidations(validations, cmd.OutOrStdout())
+		}
+	}
+	if rotateErr != nil {
+		return rotateErr
+	}
+	if format == outputTable {
+		if r.dryRun {
+			cmd.Printf("The new root for repository %s would be accepted by clients trusting the root versions above\n", gun)
+		} else {
+			cmd.Printf("Successfully rotated root key for repository %s\n", gun)
+		}
+	}
+	return nil
+}
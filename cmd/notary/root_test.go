@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+func TestRootRotateInvalidArgs(t *testing.T) {
+	setUp(t)
+	r := &rootCommander{
+		configGetter: func() (*viper.Viper, error) { return viper.New(), nil },
+		getRetriever: func() notary.PassRetriever { return passphrase.ConstantRetriever("pass") },
+	}
+	err := r.rootRotate(&cobra.Command{}, []string{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Must specify a GUN")
+
+	err = r.rootRotate(&cobra.Command{}, []string{"gun"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exactly one of --plan and --apply")
+
+	r.plan, r.apply = true, true
+	err = r.rootRotate(&cobra.Command{}, []string{"gun"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exactly one of --plan and --apply")
+
+	r.apply, r.dryRun = false, true
+	err = r.rootRotate(&cobra.Command{}, []string{"gun"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--dry-run can only be used with --apply")
+
+	r.dryRun, r.legacyVersions = false, -2
+	err = r.rootRotate(&cobra.Command{}, []string{"gun"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--legacy")
+}
+
+func TestRootRotatePlanDryRunAndApply(t *testing.T) {
+	setUp(t)
+	// Temporary directory where test files will be created
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	defer os.RemoveAll(tempBaseDir)
+	require.NoError(t, err, "failed to create a temporary directory: %s", err)
+	var gun data.GUN = "docker.com/notary"
+
+	ret := passphrase.ConstantRetriever("pass")
+
+	ts, _ := setUpRepo(t, tempBaseDir, gun, ret)
+	defer ts.Close()
+
+	repo, err := client.NewFileCachedRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, ret, trustpinning.TrustPinConfig{})
+	require.NoError(t, err, "error creating repo: %s", err)
+	require.NoError(t, repo.Publish())
+	rootKeys := repo.GetCryptoService().ListKeys(data.CanonicalRootRole)
+	require.Len(t, rootKeys, 1)
+
+	outputFormat := outputTable
+	input := bytes.NewBuffer(nil)
+	r := &rootCommander{
+		configGetter: func() (*viper.Viper, error) {
+			v := viper.New()
+			v.SetDefault("trust_dir", tempBaseDir)
+			v.SetDefault("remote_server.url", ts.URL)
+			v.SetDefault("output", outputFormat)
+			return v, nil
+		},
+		getRetriever: func() notary.PassRetriever { return ret },
+		input:        input,
+	}
+	run := func() (string, error) {
+		c := &cobra.Command{}
+		out := bytes.NewBuffer(nil)
+		c.SetOutput(out)
+		err := r.rootRotate(c, []string{gun.String()})
+		return out.String(), err
+	}
+
+	// the plan lists the current root key, and that a new one will be generated
+	r.plan = true
+	out, err := run()
+	require.NoError(t, err)
+	require.Contains(t, out, rootKeys[0])
+	require.Contains(t, out, "<generated>")
+	require.NotContains(t, out, "Not enough keys")
+
+	outputFormat = outputJSON
+	out, err = run()
+	require.NoError(t, err)
+	var plan client.RootRotationPlan
+	require.NoError(t, json.Unmarshal([]byte(out), &plan))
+	require.Equal(t, 1, plan.Current.Version)
+	require.Equal(t, []string{rootKeys[0]}, plan.Current.KeyIDs)
+	require.Equal(t, 2, plan.New.Version)
+	require.True(t, plan.GenerateKey)
+	require.Empty(t, plan.Legacy)
+
+	// the key store caches its keys, so look them up with a new repository each time
+	listRootKeys := func() []string {
+		repo, err := client.NewFileCachedRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, ret, trustpinning.TrustPinConfig{})
+		require.NoError(t, err)
+		return repo.GetCryptoService().ListKeys(data.CanonicalRootRole)
+	}
+
+	// a dry run validates the new root without asking, publishing or keeping a new key
+	outputFormat = outputTable
+	r.plan, r.apply, r.dryRun = false, true, true
+	out, err = run()
+	require.NoError(t, err)
+	require.Contains(t, out, "would be accepted")
+	require.NotContains(t, out, "Are you sure")
+	require.Equal(t, rootKeys, listRootKeys())
+
+	// a key file can be used to plan and validate the rotation, without importing it
+	privKey, err := utils.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+	pemBytes, err := utils.ConvertPrivateKeyToPKCS8(privKey, data.CanonicalRootRole, "", "pass")
+	require.NoError(t, err)
+	keyFile := filepath.Join(tempBaseDir, "new_root.key")
+	require.NoError(t, ioutil.WriteFile(keyFile, pemBytes, 0600))
+	r.rotateKeyFiles = []string{keyFile}
+
+	outputFormat = outputJSON
+	r.plan, r.apply, r.dryRun = true, false, false
+	out, err = run()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &plan))
+	require.False(t, plan.GenerateKey)
+	require.Equal(t, []string{privKey.ID()}, plan.New.KeyIDs)
+	require.Equal(t, rootKeys, listRootKeys())
+
+	outputFormat = outputTable
+	r.plan, r.apply, r.dryRun = false, true, true
+	out, err = run()
+	require.NoError(t, err)
+	require.Contains(t, out, "would be accepted")
+	require.Equal(t, rootKeys, listRootKeys())
+
+	// applying it has to be confirmed
+	r.dryRun = false
+	out, err = run()
+	require.NoError(t, err)
+	require.Contains(t, out, "Aborting action")
+	require.Equal(t, rootKeys, listRootKeys())
+
+	input.WriteString("yes\n")
+	out, err = run()
+	require.NoError(t, err)
+	require.Contains(t, out, "Successfully rotated root key")
+
+	// the new root has been published, and the key from the file has been kept
+	require.Len(t, listRootKeys(), 2)
+	require.Contains(t, listRootKeys(), privKey.ID())
+	outputFormat = outputJSON
+	r.plan, r.apply = true, false
+	out, err = run()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &plan))
+	require.Equal(t, 2, plan.Current.Version)
+	require.Equal(t, []string{privKey.ID()}, plan.Current.KeyIDs)
+}
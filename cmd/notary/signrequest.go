@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/theupdateframework/notary"
+	notaryclient "github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var cmdSignRequestTemplate = usageTemplate{
+	Use:   "sign-request",
+	Short: "Operates on partially signed metadata.",
+	Long:  "Gathers signatures from multiple key holders for roles whose threshold cannot be met by a single machine.",
+}
+
+var cmdSignRequestExportTemplate = usageTemplate{
+	Use:   "export [ GUN ] [ Role ]",
+	Short: "Exports the next version of a role, signed with the keys available locally.",
+	Long:  "Applies any staged changes to the root, targets or delegated targets role of a specific Global Unique Name, signs the result with whichever of the required keys are available locally, and writes it to a file to be passed to other key holders. Staged changes are not cleared.",
+}
+
+var cmdSignRequestSignTemplate = usageTemplate{
+	Use:   "sign [ Sign request file ]",
+	Short: "Adds signatures to a sign request.",
+	Long:  "Adds signatures from any of the required keys that are available locally to a sign request, updating the file in place. Does not require access to the notary server.",
+}
+
+var cmdSignRequestPublishTemplate = usageTemplate{
+	Use:   "publish [ Sign request file ]",
+	Short: "Publishes a sign request once enough signatures have been gathered.",
+	Long:  "Publishes a sign request to the notary server, provided the signatures on it meet the thresholds of the currently trusted roles. The snapshot key must be available locally unless it is managed by the server. Once published, the staged changes which were exported with the request are cleared, and any staged since stay staged.",
+}
+
+type signRequestCommander struct {
+	// these need to be set
+	configGetter func() (*viper.Viper, error)
+	getRetriever func() notary.PassRetriever
+
+	output string
+}
+
+func (s *signRequestCommander) GetCommand() *cobra.Command {
+	cmd := cmdSignRequestTemplate.ToCommand(nil)
+
+	cmdExport := cmdSignRequestExportTemplate.ToCommand(s.signRequestExport)
+	cmdExport.Flags().StringVarP(&s.output, "output", "o", "", "Filepath to write the sign request to")
+	cmd.AddCommand(cmdExport)
+
+	cmd.AddCommand(cmdSignRequestSignTemplate.ToCommand(s.signRequestSign))
+	cmd.AddCommand(cmdSignRequestPublishTemplate.ToCommand(s.signRequestPublish))
+	return cmd
+}
+
+func (s *signRequestCommander) signRequestExport(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN and a role")
+	}
+	if s.output == "" {
+		cmd.Usage()
+		return fmt.Errorf("Please provide a file to write the sign request to using the --output flag")
+	}
+	config, err := s.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := data.GUN(args[0])
+	role := data.RoleName(args[1])
+
+	fact := configureSigningRepo(config, s.getRetriever(), true, readOnly)
+	nRepo, err := fact(gun)
+	if err != nil {
+		return err
+	}
+
+	req, err := nRepo.ExportSignRequest(role)
+	if err != nil {
+		return err
+	}
+	if err := writeSignRequest(s.output, req); err != nil {
+		return err
+	}
+	cmd.Printf("Exported sign request for %s in %s to %s\n", role, gun, s.output)
+	printSignRequestStatus(cmd, req)
+	return nil
+}
+
+func (s *signRequestCommander) signRequestSign(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a sign request file")
+	}
+	config, err := s.configGetter()
+	if err != nil {
+		return err
+	}
+	req, err := readSignRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	k := &keyCommander{configGetter: s.configGetter, getRetriever: s.getRetriever}
+	ks, err := k.getKeyStores(config, true, false)
+	if err != nil {
+		return err
+	}
+	signedWith, err := req.Sign(cryptoservice.NewCryptoService(ks...))
+	if err != nil {
+		return err
+	}
+	if len(signedWith) == 0 {
+		return fmt.Errorf("None of the keys still needed to sign %s in %s are available", req.Role, req.GUN)
+	}
+	if err := writeSignRequest(args[0], req); err != nil {
+		return err
+	}
+	cmd.Printf("Signed %s in %s with:\n\t- %s\n", req.Role, req.GUN, strings.Join(signedWith, "\n\t- "))
+	printSignRequestStatus(cmd, req)
+	return nil
+}
+
+func (s *signRequestCommander) signRequestPublish(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a sign request file")
+	}
+	config, err := s.configGetter()
+	if err != nil {
+		return err
+	}
+	req, err := readSignRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	cmd.Println("Pushing", req.Role, "to", req.GUN)
+
+	fact := ConfigureRepo(config, s.getRetriever(), true, readWrite)
+	nRepo, err := fact(req.GUN)
+	if err != nil {
+		return err
+	}
+	if err := nRepo.PublishSignRequest(req); err != nil {
+		return err
+	}
+	cmd.Printf("Successfully published %s for repository %s\n", req.Role, req.GUN)
+	return nil
+}
+
+// printSignRequestStatus prints how many of the signatures each signing role
+// needs have been gathered so far
+func printSignRequestStatus(cmd *cobra.Command, req *notaryclient.SignRequest) {
+	signedBy := make(map[string]struct{}, len(req.Signed.Signatures))
+	for _, sig := range req.Signed.Signatures {
+		signedBy[sig.KeyID] = struct{}{}
+	}
+	for _, role := range req.Roles {
+		found := 0
+		for _, key := range role.Keys {
+			if _, ok := signedBy[key.ID()]; ok {
+				found++
+			}
+		}
+		cmd.Printf("%s: %d of %d required signatures\n", role.Name, found, role.Threshold)
+	}
+	if err := req.VerifyThresholds(); err == nil {
+		cmd.Println("The sign request is ready to be published")
+	}
+}
+
+func readSignRequest(path string) (*notaryclient.SignRequest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading sign request from %s: %v", path, err)
+	}
+	return notaryclient.NewSignRequestFromJSON(b)
+}
+
+func writeSignRequest(path string, req *notaryclient.SignRequest) error {
+	b, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, notary.PrivNoExecPerms)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/theupdateframework/notary"
 	notaryclient "github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/client/changelist"
 	"github.com/theupdateframework/notary/cryptoservice"
 	"github.com/theupdateframework/notary/passphrase"
 	"github.com/theupdateframework/notary/trustmanager"
@@ -117,10 +118,21 @@ type tufCommander struct {
 	roles    []string
 	sha256   string
 	sha512   string
-	rootKey  string
+	rootKeys []string
 	rootCert string
 	custom   string
 
+	rootThreshold     int
+	targetsThreshold  int
+	snapshotThreshold int
+
+	notBefore       string
+	notAfter        string
+	revoked         string
+	ignoreLifecycle bool
+
+	at string
+
 	input  string
 	output string
 	quiet  bool
@@ -132,13 +144,23 @@ type tufCommander struct {
 	deleteRemote bool
 
 	autoPublish bool
+	expires     time.Duration
+
+	prefix string
+
+	manifest string
+
+	jsonOutput bool
 }
 
 func (t *tufCommander) AddToCommand(cmd *cobra.Command) {
 	//
 	cmdTUFInit := cmdTUFInitTemplate.ToCommand(t.tufInit)
-	cmdTUFInit.Flags().StringVar(&t.rootKey, "rootkey", "", "Root key to initialize the repository with")
+	cmdTUFInit.Flags().StringSliceVar(&t.rootKeys, "rootkey", nil, "Root key(s) to initialize the repository with")
 	cmdTUFInit.Flags().StringVar(&t.rootCert, "rootcert", "", "Root certificate must match root key if a root key is supplied, otherwise it must match a key present in keystore")
+	cmdTUFInit.Flags().IntVar(&t.rootThreshold, "root-threshold", notary.MinThreshold, "Number of root keys which have to sign the root. Needs at least as many --rootkey keys")
+	cmdTUFInit.Flags().IntVar(&t.targetsThreshold, "targets-threshold", notary.MinThreshold, "Number of targets keys which have to sign the targets, generating as many targets keys")
+	cmdTUFInit.Flags().IntVar(&t.snapshotThreshold, "snapshot-threshold", notary.MinThreshold, "Number of snapshot keys which have to sign the snapshot, generating as many snapshot keys")
 	cmdTUFInit.Flags().BoolVarP(&t.autoPublish, "publish", "p", false, htAutoPublish)
 	cmd.AddCommand(cmdTUFInit)
 
@@ -149,19 +171,26 @@ func (t *tufCommander) AddToCommand(cmd *cobra.Command) {
 	cmdReset.Flags().BoolVar(&t.resetAll, "all", false, "Reset all changes shown in the status list")
 	cmd.AddCommand(cmdReset)
 
-	cmd.AddCommand(cmdTUFPublishTemplate.ToCommand(t.tufPublish))
+	cmdTUFPublish := cmdTUFPublishTemplate.ToCommand(t.tufPublish)
+	cmdTUFPublish.Flags().DurationVar(&t.expires, "expires", 0, "How long the roles changed by the staged changes are valid for, such as 720h, overriding the configured expiry. The snapshot keeps its configured expiry")
+	cmd.AddCommand(cmdTUFPublish)
 
-	cmd.AddCommand(cmdTUFLookupTemplate.ToCommand(t.tufLookup))
+	cmdTUFLookup := cmdTUFLookupTemplate.ToCommand(t.tufLookup)
+	cmdTUFLookup.Flags().BoolVar(&t.ignoreLifecycle, "ignore-lifecycle", false, htIgnoreLifecycle)
+	cmdTUFLookup.Flags().StringVar(&t.at, "at", "", htAt)
+	cmd.AddCommand(cmdTUFLookup)
 
 	cmdTUFList := cmdTUFListTemplate.ToCommand(t.tufList)
 	cmdTUFList.Flags().StringSliceVarP(
 		&t.roles, "roles", "r", nil, "Delegation roles to list targets for (will shadow targets role)")
+	cmdTUFList.Flags().StringVar(&t.at, "at", "", htAt)
 	cmd.AddCommand(cmdTUFList)
 
 	cmdTUFAdd := cmdTUFAddTemplate.ToCommand(t.tufAdd)
 	cmdTUFAdd.Flags().StringSliceVarP(&t.roles, "roles", "r", nil, "Delegation roles to add this target to")
 	cmdTUFAdd.Flags().BoolVarP(&t.autoPublish, "publish", "p", false, htAutoPublish)
 	cmdTUFAdd.Flags().StringVar(&t.custom, "custom", "", "Path to the file containing custom data for this target")
+	t.addLifecycleFlags(cmdTUFAdd)
 	cmd.AddCommand(cmdTUFAdd)
 
 	cmdTUFRemove := cmdTUFRemoveTemplate.ToCommand(t.tufRemove)
@@ -169,29 +198,57 @@ func (t *tufCommander) AddToCommand(cmd *cobra.Command) {
 	cmdTUFRemove.Flags().BoolVarP(&t.autoPublish, "publish", "p", false, htAutoPublish)
 	cmd.AddCommand(cmdTUFRemove)
 
+	cmdTUFAddBatch := cmdTUFAddBatchTemplate.ToCommand(t.tufAddBatch)
+	cmdTUFAddBatch.Flags().StringVar(&t.manifest, "manifest", "", "Path to the JSON or CSV manifest listing the targets to add")
+	cmdTUFAddBatch.Flags().StringSliceVarP(&t.roles, "roles", "r", nil, "Delegation roles to add targets to which the manifest does not list roles for")
+	cmdTUFAddBatch.Flags().BoolVarP(&t.autoPublish, "publish", "p", false, htAutoPublish)
+	cmd.AddCommand(cmdTUFAddBatch)
+
+	cmdTUFRemoveBatch := cmdTUFRemoveBatchTemplate.ToCommand(t.tufRemoveBatch)
+	cmdTUFRemoveBatch.Flags().StringVar(&t.manifest, "manifest", "", "Path to the JSON or CSV manifest listing the targets to remove")
+	cmdTUFRemoveBatch.Flags().StringSliceVarP(&t.roles, "roles", "r", nil, "Delegation roles to remove targets from which the manifest does not list roles for")
+	cmdTUFRemoveBatch.Flags().BoolVarP(&t.autoPublish, "publish", "p", false, htAutoPublish)
+	cmd.AddCommand(cmdTUFRemoveBatch)
+
 	cmdTUFAddHash := cmdTUFAddHashTemplate.ToCommand(t.tufAddByHash)
 	cmdTUFAddHash.Flags().StringSliceVarP(&t.roles, "roles", "r", nil, "Delegation roles to add this target to")
 	cmdTUFAddHash.Flags().StringVar(&t.sha256, notary.SHA256, "", "hex encoded sha256 of the target to add")
 	cmdTUFAddHash.Flags().StringVar(&t.sha512, notary.SHA512, "", "hex encoded sha512 of the target to add")
 	cmdTUFAddHash.Flags().BoolVarP(&t.autoPublish, "publish", "p", false, htAutoPublish)
 	cmdTUFAddHash.Flags().StringVar(&t.custom, "custom", "", "Path to the file containing custom data for this target")
+	t.addLifecycleFlags(cmdTUFAddHash)
 	cmd.AddCommand(cmdTUFAddHash)
 
 	cmdTUFVerify := cmdTUFVerifyTemplate.ToCommand(t.tufVerify)
 	cmdTUFVerify.Flags().StringVarP(&t.input, "input", "i", "", "Read from a file, instead of STDIN")
 	cmdTUFVerify.Flags().StringVarP(&t.output, "output", "o", "", "Write to a file, instead of STDOUT")
 	cmdTUFVerify.Flags().BoolVarP(&t.quiet, "quiet", "q", false, "No output except for errors")
+	cmdTUFVerify.Flags().BoolVar(&t.ignoreLifecycle, "ignore-lifecycle", false, htIgnoreLifecycle)
 	cmd.AddCommand(cmdTUFVerify)
 
 	cmdWitness := cmdWitnessTemplate.ToCommand(t.tufWitness)
 	cmdWitness.Flags().BoolVarP(&t.autoPublish, "publish", "p", false, htAutoPublish)
 	cmd.AddCommand(cmdWitness)
 
+	cmdTUFCatalog := cmdTUFCatalogTemplate.ToCommand(t.tufCatalog)
+	cmdTUFCatalog.Flags().StringVar(&t.prefix, "prefix", "", "Only list the trusted collections whose GUNs begin with this prefix")
+	cmd.AddCommand(cmdTUFCatalog)
+
+	cmdTUFDiff := cmdTUFDiffTemplate.ToCommand(t.tufDiff)
+	cmdTUFDiff.Flags().BoolVar(&t.jsonOutput, "json", false, "Print the differences as JSON (deprecated, use --output json)")
+	cmd.AddCommand(cmdTUFDiff)
+
 	cmdTUFDeleteGUN := cmdTUFDeleteTemplate.ToCommand(t.tufDeleteGUN)
 	cmdTUFDeleteGUN.Flags().BoolVar(&t.deleteRemote, "remote", false, "Delete remote data for GUN in addition to local cache")
 	cmd.AddCommand(cmdTUFDeleteGUN)
 }
 
+func (t *tufCommander) addLifecycleFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&t.notBefore, "not-before", "", "RFC 3339 timestamp before which this target is not valid")
+	cmd.Flags().StringVar(&t.notAfter, "not-after", "", "RFC 3339 timestamp after which this target has expired")
+	cmd.Flags().StringVar(&t.revoked, "revoked", "", "Mark this target as revoked, giving the reason")
+}
+
 func (t *tufCommander) tufWitness(cmd *cobra.Command, args []string) error {
 	if len(args) < 2 {
 		cmd.Usage()
@@ -225,24 +282,29 @@ func (t *tufCommander) tufWitness(cmd *cobra.Command, args []string) error {
 }
 
 func getTargetHashes(t *tufCommander) (data.Hashes, error) {
+	return parseTargetHashes(t.sha256, t.sha512)
+}
+
+// parseTargetHashes decodes hex encoded hashes, either of which may be empty
+func parseTargetHashes(sha256, sha512 string) (data.Hashes, error) {
 	targetHash := data.Hashes{}
 
-	if t.sha256 != "" {
-		if len(t.sha256) != notary.SHA256HexSize {
+	if sha256 != "" {
+		if len(sha256) != notary.SHA256HexSize {
 			return nil, fmt.Errorf("invalid sha256 hex contents provided")
 		}
-		sha256Hash, err := hex.DecodeString(t.sha256)
+		sha256Hash, err := hex.DecodeString(sha256)
 		if err != nil {
 			return nil, err
 		}
 		targetHash[notary.SHA256] = sha256Hash
 	}
 
-	if t.sha512 != "" {
-		if len(t.sha512) != notary.SHA512HexSize {
+	if sha512 != "" {
+		if len(sha512) != notary.SHA512HexSize {
 			return nil, fmt.Errorf("invalid sha512 hex contents provided")
 		}
-		sha512Hash, err := hex.DecodeString(t.sha512)
+		sha512Hash, err := hex.DecodeString(sha512)
 		if err != nil {
 			return nil, err
 		}
@@ -266,6 +328,39 @@ func getTargetCustom(targetCustomFilename string) (*canonicaljson.RawMessage, er
 	return targetCustom, nil
 }
 
+// getTargetCustomWithLifecycle reads the custom data file, if one was given,
+// and stores in it the lifecycle set by the --not-before, --not-after and
+// --revoked flags
+func (t *tufCommander) getTargetCustomWithLifecycle() (*canonicaljson.RawMessage, error) {
+	var (
+		targetCustom *canonicaljson.RawMessage
+		lifecycle    data.TargetLifecycle
+		err          error
+	)
+	if t.custom != "" {
+		targetCustom, err = getTargetCustom(t.custom)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.notBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339, t.notBefore)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --not-before timestamp: %v", err)
+		}
+		lifecycle.NotBefore = &notBefore
+	}
+	if t.notAfter != "" {
+		notAfter, err := time.Parse(time.RFC3339, t.notAfter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --not-after timestamp: %v", err)
+		}
+		lifecycle.NotAfter = &notAfter
+	}
+	lifecycle.Revoked = t.revoked
+	return data.SetTargetLifecycle(targetCustom, lifecycle)
+}
+
 func (t *tufCommander) tufAddByHash(cmd *cobra.Command, args []string) error {
 	if len(args) < 3 || t.sha256 == "" && t.sha512 == "" {
 		cmd.Usage()
@@ -279,12 +374,9 @@ func (t *tufCommander) tufAddByHash(cmd *cobra.Command, args []string) error {
 	gun := data.GUN(args[0])
 	targetName := args[1]
 	targetSize := args[2]
-	var targetCustom *canonicaljson.RawMessage
-	if t.custom != "" {
-		targetCustom, err = getTargetCustom(t.custom)
-		if err != nil {
-			return err
-		}
+	targetCustom, err := t.getTargetCustomWithLifecycle()
+	if err != nil {
+		return err
 	}
 
 	targetInt64Len, err := strconv.ParseInt(targetSize, 0, 64)
@@ -340,12 +432,9 @@ func (t *tufCommander) tufAdd(cmd *cobra.Command, args []string) error {
 	gun := data.GUN(args[0])
 	targetName := args[1]
 	targetPath := args[2]
-	var targetCustom *canonicaljson.RawMessage
-	if t.custom != "" {
-		targetCustom, err = getTargetCustom(t.custom)
-		if err != nil {
-			return err
-		}
+	targetCustom, err := t.getTargetCustomWithLifecycle()
+	if err != nil {
+		return err
 	}
 
 	// no online operations are performed by add so the transport argument
@@ -408,22 +497,29 @@ func (t *tufCommander) tufDeleteGUN(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// importRootKey imports the root key from path then adds the key to repo
+// importRootKey imports the root keys from paths then adds the keys to repo
 // returns key ids
-func importRootKey(cmd *cobra.Command, rootKey string, nRepo notaryclient.Repository, retriever notary.PassRetriever) ([]string, error) {
+func importRootKey(cmd *cobra.Command, rootKeys []string, nRepo notaryclient.Repository, retriever notary.PassRetriever) ([]string, error) {
 	var rootKeyList []string
 
-	if rootKey != "" {
-		privKey, err := readKey(data.CanonicalRootRole, rootKey, retriever)
-		if err != nil {
-			return nil, err
+	if len(rootKeys) > 0 {
+		for _, rootKey := range rootKeys {
+			privKey, err := readKey(data.CanonicalRootRole, rootKey, retriever)
+			if err != nil {
+				return nil, err
+			}
+			// add root key to repo
+			err = nRepo.GetCryptoService().AddKey(data.CanonicalRootRole, "", privKey)
+			if err != nil {
+				return nil, fmt.Errorf("Error importing key: %v", err)
+			}
+			rootKeyList = append(rootKeyList, privKey.ID())
 		}
-		// add root key to repo
-		err = nRepo.GetCryptoService().AddKey(data.CanonicalRootRole, "", privKey)
-		if err != nil {
-			return nil, fmt.Errorf("Error importing key: %v", err)
+		// every root key given is used
+		if len(rootKeyList) > 1 {
+			cmd.Printf("Root keys found, using: %s\n", strings.Join(rootKeyList, ", "))
+			return rootKeyList, nil
 		}
-		rootKeyList = []string{privKey.ID()}
 	} else {
 		rootKeyList = nRepo.GetCryptoService().ListKeys(data.CanonicalRootRole)
 	}
@@ -474,6 +570,17 @@ func (t *tufCommander) tufInit(cmd *cobra.Command, args []string) error {
 		cmd.Usage()
 		return fmt.Errorf("Must specify a GUN")
 	}
+	// the root keys are not generated, so a root threshold needs as many of them to be given
+	if t.rootThreshold > notary.MinThreshold {
+		if t.rootCert != "" {
+			cmd.Usage()
+			return fmt.Errorf("--rootcert can only be used with a --root-threshold of %d", notary.MinThreshold)
+		}
+		if len(t.rootKeys) < t.rootThreshold {
+			cmd.Usage()
+			return fmt.Errorf("--root-threshold %d needs at least %d root keys, given with --rootkey", t.rootThreshold, t.rootThreshold)
+		}
+	}
 
 	config, err := t.configGetter()
 	if err != nil {
@@ -487,7 +594,13 @@ func (t *tufCommander) tufInit(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	rootKeyIDs, err := importRootKey(cmd, t.rootKey, nRepo, t.retriever)
+	nRepo.SetRoleThresholds(map[data.RoleName]int{
+		data.CanonicalRootRole:     t.rootThreshold,
+		data.CanonicalTargetsRole:  t.targetsThreshold,
+		data.CanonicalSnapshotRole: t.snapshotThreshold,
+	})
+
+	rootKeyIDs, err := importRootKey(cmd, t.rootKeys, nRepo, t.retriever)
 	if err != nil {
 		return err
 	}
@@ -498,7 +611,7 @@ func (t *tufCommander) tufInit(cmd *cobra.Command, args []string) error {
 	}
 
 	// if key is not defined but cert is, then clear the key to allow key to be searched in keystore
-	if t.rootKey == "" && t.rootCert != "" {
+	if len(t.rootKeys) == 0 && t.rootCert != "" {
 		rootKeyIDs = []string{}
 	}
 
@@ -547,8 +660,7 @@ func (t *tufCommander) tufList(cmd *cobra.Command, args []string) error {
 	}
 	gun := data.GUN(args[0])
 
-	fact := ConfigureRepo(config, t.retriever, true, readOnly)
-	nRepo, err := fact(gun)
+	nRepo, err := t.readOnlyRepo(config, gun)
 	if err != nil {
 		return err
 	}
@@ -559,6 +671,9 @@ func (t *tufCommander) tufList(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if format := getOutputFormat(config); format != outputTable {
+		return writeStructured(format, targetsOutput(targetList), cmd.OutOrStdout())
+	}
 	prettyPrintTargets(targetList, cmd.OutOrStdout())
 	return nil
 }
@@ -576,21 +691,58 @@ func (t *tufCommander) tufLookup(cmd *cobra.Command, args []string) error {
 	gun := data.GUN(args[0])
 	targetName := args[1]
 
-	fact := ConfigureRepo(config, t.retriever, true, readOnly)
-	nRepo, err := fact(gun)
+	nRepo, err := t.readOnlyRepo(config, gun)
 	if err != nil {
 		return err
 	}
 
-	target, err := nRepo.GetTargetByName(targetName)
+	target, err := t.getTargetByName(nRepo, targetName)
 	if err != nil {
 		return err
 	}
 
+	if format := getOutputFormat(config); format != outputTable {
+		return writeStructured(format, newTargetOutput(target), cmd.OutOrStdout())
+	}
 	cmd.Println(target.Name, fmt.Sprintf("sha256:%x", target.Hashes["sha256"]), target.Length)
 	return nil
 }
 
+// readOnlyRepo returns the repository for reading, as it was at the time or
+// snapshot version passed with --at, if any
+func (t *tufCommander) readOnlyRepo(config *viper.Viper, gun data.GUN) (notaryclient.ReadOnly, error) {
+	if t.at == "" {
+		return ConfigureRepo(config, t.retriever, true, readOnly)(gun)
+	}
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := getTransport(config, gun, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	if version, err := strconv.Atoi(t.at); err == nil {
+		return notaryclient.NewReadOnlyAtSnapshotVersion(
+			config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, trustPin, version)
+	}
+	at, err := time.Parse(time.RFC3339, t.at)
+	if err != nil {
+		return nil, fmt.Errorf("--at must be a time, such as 2017-03-02T15:04:05Z, or a snapshot version: %s", t.at)
+	}
+	return notaryclient.NewReadOnlyAtTime(
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, trustPin, at)
+}
+
+// getTargetByName looks up the target, only accepting targets which are revoked
+// or outside their validity window if --ignore-lifecycle was passed
+func (t *tufCommander) getTargetByName(nRepo notaryclient.ReadOnly, targetName string) (*notaryclient.TargetWithRole, error) {
+	if t.ignoreLifecycle {
+		return nRepo.GetTargetByNameIgnoringLifecycle(targetName)
+	}
+	return nRepo.GetTargetByName(targetName)
+}
+
 func (t *tufCommander) tufStatus(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		cmd.Usage()
@@ -614,6 +766,9 @@ func (t *tufCommander) tufStatus(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if format := getOutputFormat(config); format != outputTable {
+		return writeStructured(format, changesOutput(cl.List()), cmd.OutOrStdout())
+	}
 	if len(cl.List()) == 0 {
 		cmd.Printf("No unpublished changes for %s\n", gun)
 		return nil
@@ -697,10 +852,58 @@ func (t *tufCommander) tufPublish(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if t.expires < 0 {
+		return fmt.Errorf("--expires must be positive")
+	}
+	if t.expires > 0 {
+		cl, err := nRepo.GetChangelist()
+		if err != nil {
+			return err
+		}
+		expiryPolicy, err := publishExpiryPolicy(config, gun, cl, t.expires)
+		if err != nil {
+			return err
+		}
+		nRepo.SetExpiryPolicy(expiryPolicy)
+	}
 
 	return publishAndPrintToCLI(cmd, nRepo)
 }
 
+// publishExpiryPolicy returns the configured expiry policy, overridden with
+// expires for the roles the changes in the changelist are made to.  Other
+// roles signed by the publish, such as the snapshot, keep their configured
+// expiry.
+func publishExpiryPolicy(config *viper.Viper, gun data.GUN, cl changelist.Changelist, expires time.Duration) (*data.ExpiryPolicy, error) {
+	expiryPolicy, err := utils.ParseExpiryPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	if expiryPolicy == nil {
+		expiryPolicy = data.NewExpiryPolicy()
+	}
+	overridden := make(map[data.RoleName]struct{})
+	for _, c := range cl.List() {
+		role := c.Scope()
+		if data.IsWildDelegation(role) {
+			// a change to several delegations at once
+			role = data.DelegationsExpiryRole
+		}
+		if _, ok := overridden[role]; ok {
+			continue
+		}
+		overridden[role] = struct{}{}
+		// a rule for the exact role and GUN is more specific than any
+		// configured rule which could match the role
+		expiryPolicy.Rules = append(expiryPolicy.Rules, data.ExpiryRule{
+			GUNPrefix: gun.String(),
+			Role:      role,
+			Expiry:    expires,
+		})
+	}
+	return expiryPolicy, nil
+}
+
 func (t *tufCommander) tufRemove(cmd *cobra.Command, args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("Must specify a GUN and target")
@@ -748,13 +951,12 @@ func (t *tufCommander) tufVerify(cmd *cobra.Command, args []string) error {
 	gun := data.GUN(args[0])
 	targetName := args[1]
 
-	fact := ConfigureRepo(config, t.retriever, true, readOnly)
-	nRepo, err := fact(gun)
+	nRepo, err := t.readOnlyRepo(config, gun)
 	if err != nil {
 		return err
 	}
 
-	target, err := nRepo.GetTargetByName(targetName)
+	target, err := t.getTargetByName(nRepo, targetName)
 	if err != nil {
 		return fmt.Errorf("error retrieving target by name:%s, error:%v", targetName, err)
 	}
@@ -963,7 +1165,7 @@ func tokenAuth(trustServerURL string, baseTransport *http.Transport, gun data.GU
 		return nil, fmt.Errorf("Invalid permission requested for token authentication of gun %s", gun)
 	}
 
-	tokenHandler := auth.NewTokenHandler(authTransport, ps, gun.String(), actions...)
+	tokenHandler := newTokenHandler(authTransport, ps, gun, actions...)
 	basicHandler := auth.NewBasicHandler(ps)
 
 	modifier := auth.NewAuthorizer(challengeManager, tokenHandler, basicHandler)
@@ -974,7 +1176,20 @@ func tokenAuth(trustServerURL string, baseTransport *http.Transport, gun data.GU
 
 	// Try to authenticate read only repositories using basic username/password authentication
 	return newAuthRoundTripper(transport.NewTransport(baseTransport, modifier),
-		transport.NewTransport(baseTransport, auth.NewAuthorizer(challengeManager, auth.NewTokenHandler(authTransport, passwordStore{anonymous: false}, gun.String(), actions...)))), nil
+		transport.NewTransport(baseTransport, auth.NewAuthorizer(challengeManager, newTokenHandler(authTransport, passwordStore{anonymous: false}, gun, actions...)))), nil
+}
+
+// newTokenHandler requests tokens with the given actions on gun or, if there
+// is no gun, with access to the catalog of all repositories
+func newTokenHandler(authTransport http.RoundTripper, creds auth.CredentialStore, gun data.GUN, actions ...string) auth.AuthenticationHandler {
+	if gun == "" {
+		return auth.NewTokenHandlerWithOptions(auth.TokenHandlerOptions{
+			Transport:   authTransport,
+			Credentials: creds,
+			Scopes:      []auth.Scope{auth.RegistryScope{Name: "catalog", Actions: []string{"*"}}},
+		})
+	}
+	return auth.NewTokenHandler(authTransport, creds, gun.String(), actions...)
 }
 
 func getRemoteTrustServer(config *viper.Viper) string {
@@ -985,30 +1200,90 @@ func getRemoteTrustServer(config *viper.Viper) string {
 }
 
 func getTrustPinning(config *viper.Viper) (trustpinning.TrustPinConfig, error) {
+	// Need to parse out Certs and SPKI sections from config
+	resultCertMap, err := getTrustPinningIDs(config, "trust_pinning.certs")
+	if err != nil {
+		return trustpinning.TrustPinConfig{}, err
+	}
+	resultSPKIMap, err := getTrustPinningIDs(config, "trust_pinning.spki")
+	if err != nil {
+		return trustpinning.TrustPinConfig{}, err
+	}
+	tofuMap := config.GetStringMap("trust_pinning.tofu")
+	resultTOFUMap := make(map[string]bool)
+	for gun, tofu := range tofuMap {
+		enabled, ok := tofu.(bool)
+		if !ok {
+			return trustpinning.TrustPinConfig{}, fmt.Errorf("invalid format for trust_pinning.tofu")
+		}
+		resultTOFUMap[gun] = enabled
+	}
+	resultMinPinnedKeysMap, err := getMinPinnedKeys(config)
+	if err != nil {
+		return trustpinning.TrustPinConfig{}, err
+	}
+	return trustpinning.TrustPinConfig{
+		DisableTOFU:   config.GetBool("trust_pinning.disable_tofu"),
+		TOFU:          resultTOFUMap,
+		CA:            config.GetStringMapString("trust_pinning.ca"),
+		CRL:           config.GetStringMapString("trust_pinning.crl"),
+		Certs:         resultCertMap,
+		SPKI:          resultSPKIMap,
+		MinPinnedKeys: resultMinPinnedKeysMap,
+	}, nil
+}
+
+// getMinPinnedKeys parses the trust_pinning section mapping GUNs to the minimum number
+// of root keys which have to match the pinned trust
+func getMinPinnedKeys(config *viper.Viper) (map[string]int, error) {
+	const key = "trust_pinning.min_pinned_keys"
+	minMap, ok := config.Get(key).(map[string]interface{})
+	if !ok && config.IsSet(key) {
+		return nil, fmt.Errorf("invalid format for %s", key)
+	}
+	resultMap := make(map[string]int)
+	for gun, min := range minMap {
+		var count int
+		switch v := min.(type) {
+		case int:
+			count = v
+		case float64:
+			count = int(v)
+			if float64(count) != v {
+				return nil, fmt.Errorf("invalid value for %s: %v", key, min)
+			}
+		default:
+			return nil, fmt.Errorf("invalid format for %s", key)
+		}
+		if count < 0 {
+			return nil, fmt.Errorf("invalid value for %s: %d", key, count)
+		}
+		resultMap[gun] = count
+	}
+	return resultMap, nil
+}
+
+// getTrustPinningIDs parses a trust_pinning section mapping GUNs to lists of IDs
+func getTrustPinningIDs(config *viper.Viper, section string) (map[string][]string, error) {
 	var ok bool
-	// Need to parse out Certs section from config
-	certMap := config.GetStringMap("trust_pinning.certs")
+	certMap := config.GetStringMap(section)
 	resultCertMap := make(map[string][]string)
 	for gun, certSlice := range certMap {
 		var castedCertSlice []interface{}
 		if castedCertSlice, ok = certSlice.([]interface{}); !ok {
-			return trustpinning.TrustPinConfig{}, fmt.Errorf("invalid format for trust_pinning.certs")
+			return nil, fmt.Errorf("invalid format for %s", section)
 		}
 		certsForGun := make([]string, len(castedCertSlice))
 		for idx, certIDInterface := range castedCertSlice {
 			if certID, ok := certIDInterface.(string); ok {
 				certsForGun[idx] = certID
 			} else {
-				return trustpinning.TrustPinConfig{}, fmt.Errorf("invalid format for trust_pinning.certs")
+				return nil, fmt.Errorf("invalid format for %s", section)
 			}
 		}
 		resultCertMap[gun] = certsForGun
 	}
-	return trustpinning.TrustPinConfig{
-		DisableTOFU: config.GetBool("trust_pinning.disable_tofu"),
-		CA:          config.GetStringMapString("trust_pinning.ca"),
-		Certs:       resultCertMap,
-	}, nil
+	return resultCertMap, nil
 }
 
 // authRoundTripper tries to authenticate the requests via multiple HTTP transactions (until first succeed)
@@ -1059,12 +1334,32 @@ func maybeAutoPublish(cmd *cobra.Command, doPublish bool, gun data.GUN, config *
 		return err
 	}
 
-	nRepo, err := notaryclient.NewFileCachedRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, passRetriever, trustPin)
+	expiryPolicy, err := utils.ParseExpiryPolicy(config)
+	if err != nil {
+		return err
+	}
+
+	retryPolicy, err := getPublishRetryPolicy(config)
 	if err != nil {
 		return err
 	}
 
+	keyStores, err := getConfiguredKeyStores(config, passRetriever)
+	if err != nil {
+		return err
+	}
+
+	nRepo, err := notaryclient.NewFileCachedRepositoryWithKeyStores(
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, passRetriever, trustPin, keyStores...)
+	if err != nil {
+		return err
+	}
+	nRepo.SetExpiryPolicy(expiryPolicy)
+	nRepo.SetPublishRetryPolicy(retryPolicy)
+	if err := useNamedChangelist(config, nRepo); err != nil {
+		return err
+	}
+
 	cmd.Println("Auto-publishing changes to", nRepo.GetGUN())
 	return publishAndPrintToCLI(cmd, nRepo)
 }
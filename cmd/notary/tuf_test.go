@@ -11,11 +11,13 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution/registry/client/auth"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+	notaryclient "github.com/theupdateframework/notary/client"
 	"github.com/theupdateframework/notary/tuf/data"
 )
 
@@ -270,6 +272,50 @@ func TestConfigureRepoAdmin(t *testing.T) {
 	repo.ListRoles()
 }
 
+func TestGetPublishRetryPolicy(t *testing.T) {
+	v := viper.New()
+	policy, err := getPublishRetryPolicy(v)
+	require.NoError(t, err)
+	require.Equal(t, notaryclient.DefaultPublishRetryPolicy, policy)
+
+	v.Set("publish.retries", 5)
+	v.Set("publish.backoff", "2s")
+	policy, err = getPublishRetryPolicy(v)
+	require.NoError(t, err)
+	require.Equal(t, notaryclient.PublishRetryPolicy{Retries: 5, Backoff: 2 * time.Second}, policy)
+
+	for key, invalid := range map[string]interface{}{
+		"publish.retries": -1,
+		"publish.backoff": "soon",
+	} {
+		v := viper.New()
+		v.Set(key, invalid)
+		_, err := getPublishRetryPolicy(v)
+		require.Error(t, err)
+	}
+}
+
+type recordingCloser struct {
+	closed int
+}
+
+func (r *recordingCloser) Close() {
+	r.closed++
+}
+
+func TestCloseKeyStores(t *testing.T) {
+	closer := &recordingCloser{}
+	openedKeyStores.closers = append(openedKeyStores.closers, closer)
+
+	closeKeyStores()
+	require.Equal(t, 1, closer.closed)
+	require.Empty(t, openedKeyStores.closers)
+
+	// the stores are only closed once
+	closeKeyStores()
+	require.Equal(t, 1, closer.closed)
+}
+
 func TestStatusUnstageAndReset(t *testing.T) {
 	setUp(t)
 	tempBaseDir := tempDirWithConfig(t, "{}")
@@ -10,6 +10,12 @@ import (
 const (
 	// The help text of auto publish
 	htAutoPublish string = "Automatically attempt to publish after staging the change. Will also publish existing staged changes."
+
+	// The help text of ignoring target lifecycles
+	htIgnoreLifecycle string = "Accept the target even if it has been revoked or is outside its validity window"
+
+	// The help text of viewing a trusted collection as it was
+	htAt string = "View the trusted collection as it was at a time (RFC 3339, e.g. 2017-03-02T15:04:05Z) or snapshot version"
 )
 
 // getPayload is a helper function to get the content used to be verified
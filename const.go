@@ -12,7 +12,7 @@ const (
 	MaxTimestampSize int64 = 1 << 20
 	// MinRSABitSize is the minimum bit size for RSA keys allowed in notary
 	MinRSABitSize = 2048
-	// MinThreshold requires a minimum of one threshold for roles; currently we do not support a higher threshold
+	// MinThreshold requires a minimum of one threshold for roles, and is the default threshold
 	MinThreshold = 1
 	// SHA256HexSize is how big a SHA256 hex is in number of characters
 	SHA256HexSize = 64
@@ -58,6 +58,8 @@ const (
 	SQLiteBackend    = "sqlite3"
 	RethinkDBBackend = "rethinkdb"
 	FileBackend      = "file"
+	KMSBackend       = "kms"
+	PKCS11Backend    = "pkcs11"
 
 	DefaultImportRole = "delegation"
 
@@ -83,6 +85,8 @@ const (
 	CtxKeyKeyAlgo
 	CtxKeyCryptoSvc
 	CtxKeyRepo
+	CtxKeyExpiryPolicy
+	CtxKeyRepoFilter
 )
 
 // NotarySupportedBackends contains the backends we would like to support at present
@@ -92,4 +96,6 @@ var NotarySupportedBackends = []string{
 	SQLiteBackend,
 	RethinkDBBackend,
 	PostgresBackend,
+	KMSBackend,
+	PKCS11Backend,
 }
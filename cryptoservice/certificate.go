@@ -13,6 +13,11 @@ import (
 
 // GenerateCertificate generates an X509 Certificate from a template, given a GUN and validity interval
 func GenerateCertificate(rootKey data.PrivateKey, gun data.GUN, startTime, endTime time.Time) (*x509.Certificate, error) {
+	// Certificate key IDs are always computed as if the key were an ecdsa
+	// key, so an ecdsa-p384 key could never be found again from its certificate
+	if rootKey.Algorithm() == data.ECDSAP384Key {
+		return nil, fmt.Errorf("key type not supported for Certificate generation: %s", rootKey.Algorithm())
+	}
 	signer := rootKey.CryptoSigner()
 	if signer == nil {
 		return nil, fmt.Errorf("key type not supported for Certificate generation: %s", rootKey.Algorithm())
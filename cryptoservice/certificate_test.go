@@ -36,3 +36,12 @@ func TestGenerateCertificate(t *testing.T) {
 	// Check CommonName
 	require.EqualValues(t, cert.Subject.CommonName, gun)
 }
+
+func TestGenerateCertificateECDSAP384(t *testing.T) {
+	privKey, err := utils.GenerateECDSAP384Key(rand.Reader)
+	require.NoError(t, err, "could not generate key")
+
+	startTime := time.Now()
+	_, err = GenerateCertificate(privKey, "docker.com/notary", startTime, startTime.AddDate(10, 0, 0))
+	require.Error(t, err)
+}
@@ -20,9 +20,10 @@ import (
 )
 
 var algoToSigType = map[string]data.SigAlgorithm{
-	data.ECDSAKey:   data.ECDSASignature,
-	data.ED25519Key: data.EDDSASignature,
-	data.RSAKey:     data.RSAPSSSignature,
+	data.ECDSAKey:     data.ECDSASignature,
+	data.ECDSAP384Key: data.ECDSAP384Signature,
+	data.ED25519Key:   data.EDDSASignature,
+	data.RSAKey:       data.RSAPSSSignature,
 }
 
 var passphraseRetriever = func(string, string, bool, int) (string, bool, error) { return "", false, nil }
@@ -430,4 +431,10 @@ func TestCryptoSignerInterfaceBehavior(t *testing.T) {
 
 	cs = NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
 	interfaces.AddListKeyCryptoServiceInterfaceBehaviorTests(t, cs, data.ECDSAKey)
+
+	cs = NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
+	interfaces.CreateGetKeyCryptoServiceInterfaceBehaviorTests(t, cs, data.ECDSAP384Key)
+
+	cs = NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
+	interfaces.AddGetKeyCryptoServiceInterfaceBehaviorTests(t, cs, data.ECDSAP384Key)
 }
@@ -0,0 +1,15 @@
+CREATE TABLE `audit_events` (
+	`id` int(11) NOT NULL AUTO_INCREMENT,
+	`sequence` bigint NOT NULL,
+	`timestamp` datetime NOT NULL,
+	`action` varchar(50) NOT NULL,
+	`key_id` varchar(255) NOT NULL,
+	`gun` varchar(255) NOT NULL,
+	`role` varchar(255) NOT NULL,
+	`client` varchar(255) NOT NULL,
+	`digest` varchar(64) NOT NULL,
+	`previous_hash` varchar(64) NOT NULL,
+	`hash` varchar(64) NOT NULL,
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `sequence` (`sequence`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
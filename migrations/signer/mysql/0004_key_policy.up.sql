@@ -0,0 +1 @@
+ALTER TABLE `private_keys` ADD COLUMN `policy` VARCHAR(2048) NOT NULL DEFAULT '';
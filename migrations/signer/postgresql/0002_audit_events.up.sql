@@ -0,0 +1,14 @@
+CREATE TABLE "audit_events" (
+	"id" serial PRIMARY KEY,
+	"sequence" bigint NOT NULL,
+	"timestamp" timestamp NOT NULL,
+	"action" varchar(50) NOT NULL,
+	"key_id" varchar(255) NOT NULL,
+	"gun" varchar(255) NOT NULL,
+	"role" varchar(255) NOT NULL,
+	"client" varchar(255) NOT NULL,
+	"digest" varchar(64) NOT NULL,
+	"previous_hash" varchar(64) NOT NULL,
+	"hash" varchar(64) NOT NULL,
+	CONSTRAINT "sequence" UNIQUE ("sequence")
+);
@@ -0,0 +1 @@
+ALTER TABLE "private_keys" ADD COLUMN "policy" varchar(2048) NOT NULL DEFAULT '';
@@ -18,6 +18,9 @@ It has these top-level messages:
 	Signature
 	SignatureRequest
 	Void
+	ListAuditEventsRequest
+	AuditEvent
+	ListAuditEventsResponse
 */
 package proto
 
@@ -258,6 +261,139 @@ func (m *Void) String() string            { return proto1.CompactTextString(m) }
 func (*Void) ProtoMessage()               {}
 func (*Void) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
+// ListAuditEventsRequest requests up to limit audit events, starting with the one
+// after the event with the sequence number after.
+type ListAuditEventsRequest struct {
+	After uint64 `protobuf:"varint,1,opt,name=after" json:"after,omitempty"`
+	Limit uint32 `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
+}
+
+func (m *ListAuditEventsRequest) Reset()                    { *m = ListAuditEventsRequest{} }
+func (m *ListAuditEventsRequest) String() string            { return proto1.CompactTextString(m) }
+func (*ListAuditEventsRequest) ProtoMessage()               {}
+func (*ListAuditEventsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ListAuditEventsRequest) GetAfter() uint64 {
+	if m != nil {
+		return m.After
+	}
+	return 0
+}
+
+func (m *ListAuditEventsRequest) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+// AuditEvent records a single key creation, key deletion or signature.  Each event
+// includes the hash of the event before it, so that tampering with the trail can be detected.
+type AuditEvent struct {
+	Sequence     uint64 `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
+	Timestamp    string `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
+	Action       string `protobuf:"bytes,3,opt,name=action" json:"action,omitempty"`
+	KeyID        string `protobuf:"bytes,4,opt,name=keyID" json:"keyID,omitempty"`
+	Gun          string `protobuf:"bytes,5,opt,name=gun" json:"gun,omitempty"`
+	Role         string `protobuf:"bytes,6,opt,name=role" json:"role,omitempty"`
+	Client       string `protobuf:"bytes,7,opt,name=client" json:"client,omitempty"`
+	Digest       string `protobuf:"bytes,8,opt,name=digest" json:"digest,omitempty"`
+	PreviousHash string `protobuf:"bytes,9,opt,name=previousHash" json:"previousHash,omitempty"`
+	Hash         string `protobuf:"bytes,10,opt,name=hash" json:"hash,omitempty"`
+}
+
+func (m *AuditEvent) Reset()                    { *m = AuditEvent{} }
+func (m *AuditEvent) String() string            { return proto1.CompactTextString(m) }
+func (*AuditEvent) ProtoMessage()               {}
+func (*AuditEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *AuditEvent) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+func (m *AuditEvent) GetTimestamp() string {
+	if m != nil {
+		return m.Timestamp
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetAction() string {
+	if m != nil {
+		return m.Action
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetKeyID() string {
+	if m != nil {
+		return m.KeyID
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetGun() string {
+	if m != nil {
+		return m.Gun
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetRole() string {
+	if m != nil {
+		return m.Role
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetClient() string {
+	if m != nil {
+		return m.Client
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetDigest() string {
+	if m != nil {
+		return m.Digest
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetPreviousHash() string {
+	if m != nil {
+		return m.PreviousHash
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetHash() string {
+	if m != nil {
+		return m.Hash
+	}
+	return ""
+}
+
+// ListAuditEventsResponse returns audit events in order of their sequence numbers.
+type ListAuditEventsResponse struct {
+	Events []*AuditEvent `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
+}
+
+func (m *ListAuditEventsResponse) Reset()                    { *m = ListAuditEventsResponse{} }
+func (m *ListAuditEventsResponse) String() string            { return proto1.CompactTextString(m) }
+func (*ListAuditEventsResponse) ProtoMessage()               {}
+func (*ListAuditEventsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *ListAuditEventsResponse) GetEvents() []*AuditEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
 func init() {
 	proto1.RegisterType((*CreateKeyRequest)(nil), "proto.CreateKeyRequest")
 	proto1.RegisterType((*KeyInfo)(nil), "proto.KeyInfo")
@@ -268,6 +404,9 @@ func init() {
 	proto1.RegisterType((*Signature)(nil), "proto.Signature")
 	proto1.RegisterType((*SignatureRequest)(nil), "proto.SignatureRequest")
 	proto1.RegisterType((*Void)(nil), "proto.Void")
+	proto1.RegisterType((*ListAuditEventsRequest)(nil), "proto.ListAuditEventsRequest")
+	proto1.RegisterType((*AuditEvent)(nil), "proto.AuditEvent")
+	proto1.RegisterType((*ListAuditEventsResponse)(nil), "proto.ListAuditEventsResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -287,6 +426,8 @@ type KeyManagementClient interface {
 	DeleteKey(ctx context.Context, in *KeyID, opts ...grpc.CallOption) (*Void, error)
 	// GetKeyInfo returns the PublicKey associated with a KeyID
 	GetKeyInfo(ctx context.Context, in *KeyID, opts ...grpc.CallOption) (*GetKeyInfoResponse, error)
+	// ListAuditEvents returns the audit trail of key creations, key deletions and signatures
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error)
 }
 
 type keyManagementClient struct {
@@ -324,6 +465,15 @@ func (c *keyManagementClient) GetKeyInfo(ctx context.Context, in *KeyID, opts ..
 	return out, nil
 }
 
+func (c *keyManagementClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	out := new(ListAuditEventsResponse)
+	err := grpc.Invoke(ctx, "/proto.KeyManagement/ListAuditEvents", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for KeyManagement service
 
 type KeyManagementServer interface {
@@ -333,6 +483,8 @@ type KeyManagementServer interface {
 	DeleteKey(context.Context, *KeyID) (*Void, error)
 	// GetKeyInfo returns the PublicKey associated with a KeyID
 	GetKeyInfo(context.Context, *KeyID) (*GetKeyInfoResponse, error)
+	// ListAuditEvents returns the audit trail of key creations, key deletions and signatures
+	ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
 }
 
 func RegisterKeyManagementServer(s *grpc.Server, srv KeyManagementServer) {
@@ -393,6 +545,24 @@ func _KeyManagement_GetKeyInfo_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _KeyManagement_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyManagementServer).ListAuditEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.KeyManagement/ListAuditEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyManagementServer).ListAuditEvents(ctx, req.(*ListAuditEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _KeyManagement_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.KeyManagement",
 	HandlerType: (*KeyManagementServer)(nil),
@@ -409,6 +579,10 @@ var _KeyManagement_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetKeyInfo",
 			Handler:    _KeyManagement_GetKeyInfo_Handler,
 		},
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _KeyManagement_ListAuditEvents_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "signer.proto",
@@ -483,30 +657,41 @@ var _Signer_serviceDesc = grpc.ServiceDesc{
 func init() { proto1.RegisterFile("signer.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 388 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xac, 0x53, 0x3f, 0xef, 0xd3, 0x40,
-	0x0c, 0x4d, 0xf3, 0xeb, 0x1f, 0x9d, 0x1b, 0xaa, 0xc8, 0x4b, 0x43, 0xc5, 0x80, 0x6e, 0x2a, 0x4b,
-	0x87, 0x76, 0x80, 0x85, 0x01, 0x11, 0x09, 0x55, 0x11, 0x52, 0x95, 0x4a, 0xdd, 0x18, 0xd2, 0x62,
-	0x42, 0xd4, 0xf4, 0x2e, 0x24, 0x97, 0x21, 0x13, 0x5f, 0x88, 0x0f, 0x89, 0x72, 0x49, 0xae, 0x69,
-	0x41, 0x88, 0x4a, 0xbf, 0x29, 0xf6, 0xb3, 0xfd, 0xfc, 0xec, 0xf8, 0xc0, 0x29, 0x92, 0x58, 0x50,
-	0xbe, 0xca, 0x72, 0xa9, 0x24, 0x8e, 0xf4, 0x87, 0x1f, 0xc0, 0xfd, 0x98, 0x53, 0xa4, 0x28, 0xa0,
-	0x2a, 0xa4, 0x1f, 0x25, 0x15, 0x0a, 0x5f, 0x01, 0x8b, 0xd2, 0x58, 0xe6, 0x89, 0xfa, 0x7e, 0xf1,
-	0x06, 0xaf, 0x07, 0x4b, 0x16, 0x5e, 0x01, 0x74, 0xe1, 0x29, 0x2e, 0x85, 0x67, 0x6b, 0xbc, 0x36,
-	0x11, 0x61, 0x98, 0xcb, 0x94, 0xbc, 0x27, 0x0d, 0x69, 0x9b, 0x7f, 0x81, 0x49, 0x40, 0xd5, 0x56,
-	0x7c, 0x93, 0xc8, 0x61, 0x74, 0xa6, 0x6a, 0xeb, 0x6b, 0xaa, 0xe9, 0xda, 0x69, 0x04, 0xac, 0xea,
-	0xb0, 0x1f, 0x36, 0x21, 0x5c, 0xf5, 0x5b, 0xda, 0x3a, 0xcf, 0x6d, 0xf3, 0x3e, 0x74, 0x78, 0x4f,
-	0x04, 0x9f, 0xc3, 0x48, 0xd7, 0xe3, 0x0c, 0xec, 0x96, 0x99, 0x85, 0xf6, 0xd6, 0xe7, 0x6f, 0x80,
-	0x99, 0x82, 0x7f, 0x0f, 0xc2, 0x33, 0xc0, 0x4f, 0xa4, 0x5a, 0x95, 0x21, 0x15, 0x99, 0x14, 0x05,
-	0xe1, 0x12, 0x26, 0xe7, 0x06, 0x6a, 0xf5, 0xce, 0x7a, 0x7a, 0xeb, 0xc4, 0x2e, 0x5c, 0xb3, 0x67,
-	0xe5, 0x31, 0x4d, 0x4e, 0x01, 0x55, 0x5a, 0xb3, 0x13, 0x5e, 0x81, 0xbf, 0x2e, 0x65, 0x0f, 0x6c,
-	0x67, 0x12, 0x9e, 0xa9, 0x11, 0xff, 0x09, 0x6c, 0x9f, 0xc4, 0x22, 0x52, 0x65, 0xfe, 0x88, 0xfa,
-	0x07, 0x37, 0x8e, 0x1e, 0x4c, 0x4e, 0x52, 0x28, 0x12, 0x4a, 0x8f, 0xe4, 0x84, 0x9d, 0xcb, 0x77,
-	0xe0, 0x1a, 0x01, 0xdd, 0x09, 0xfd, 0xcf, 0x3f, 0xef, 0x31, 0xda, 0xb7, 0x8c, 0x63, 0x18, 0x1e,
-	0x64, 0xf2, 0x75, 0xfd, 0x6b, 0x00, 0x2f, 0x02, 0xaa, 0x3e, 0x47, 0x22, 0x8a, 0xe9, 0x42, 0x42,
-	0xe1, 0x3b, 0x60, 0xe6, 0x5c, 0x71, 0xde, 0xb2, 0xde, 0x1f, 0xf0, 0xa2, 0x1b, 0xc4, 0x2c, 0x9b,
-	0x5b, 0xb8, 0x04, 0xe6, 0x53, 0x4a, 0x4d, 0xe5, 0x8d, 0x9e, 0xc5, 0xb4, 0xf5, 0xea, 0x9e, 0xdc,
-	0xc2, 0xb7, 0x00, 0xd7, 0xbb, 0xb8, 0x4b, 0x7d, 0xd9, 0x7a, 0x7f, 0x1e, 0x0e, 0xb7, 0xd6, 0xef,
-	0x61, 0xbc, 0xd7, 0x4f, 0x0c, 0x37, 0x30, 0xac, 0x2d, 0xa3, 0xf0, 0x7e, 0x3f, 0x46, 0xa1, 0x09,
-	0x70, 0xeb, 0x38, 0xd6, 0xd0, 0xe6, 0x77, 0x00, 0x00, 0x00, 0xff, 0xff, 0x45, 0x92, 0x2c, 0xe0,
-	0xa8, 0x03, 0x00, 0x00,
+	// 569 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x54, 0x4b, 0x6f, 0xd3, 0x40,
+	0x10, 0x4e, 0x9d, 0xc4, 0xa9, 0x27, 0x69, 0x09, 0x2b, 0xd4, 0x98, 0x08, 0x50, 0xb5, 0xa7, 0xf4,
+	0x92, 0x43, 0x7a, 0x80, 0x0b, 0x87, 0x0a, 0x23, 0x88, 0x02, 0x52, 0xe5, 0x48, 0xbd, 0x71, 0x70,
+	0x93, 0xa9, 0xb3, 0xaa, 0xb3, 0x36, 0xde, 0x75, 0xa5, 0x9c, 0x40, 0xfc, 0x72, 0xb4, 0x0f, 0xdb,
+	0x79, 0x14, 0x44, 0x25, 0x4e, 0xde, 0xf9, 0x66, 0xf6, 0x9b, 0x87, 0xbf, 0x59, 0xe8, 0x09, 0x16,
+	0x73, 0xcc, 0xc7, 0x59, 0x9e, 0xca, 0x94, 0xb4, 0xf5, 0x87, 0xde, 0x40, 0xff, 0x43, 0x8e, 0x91,
+	0xc4, 0x19, 0x6e, 0x42, 0xfc, 0x5e, 0xa0, 0x90, 0xe4, 0x15, 0x78, 0x51, 0x12, 0xa7, 0x39, 0x93,
+	0xab, 0xb5, 0x7f, 0x74, 0x7e, 0x34, 0xf2, 0xc2, 0x1a, 0x20, 0x7d, 0x68, 0xc6, 0x05, 0xf7, 0x1d,
+	0x8d, 0xab, 0x23, 0x21, 0xd0, 0xca, 0xd3, 0x04, 0xfd, 0xa6, 0x86, 0xf4, 0x99, 0x7e, 0x83, 0xce,
+	0x0c, 0x37, 0x53, 0x7e, 0x97, 0x12, 0x0a, 0xed, 0x7b, 0xdc, 0x4c, 0x03, 0x4d, 0xd5, 0x9d, 0xf4,
+	0x4c, 0x01, 0x63, 0xe5, 0x0e, 0x42, 0xe3, 0x22, 0xe3, 0xed, 0x94, 0x8e, 0x8e, 0xeb, 0xdb, 0xb8,
+	0xab, 0x12, 0xdf, 0x2a, 0x82, 0x0e, 0xa0, 0xad, 0xef, 0x93, 0x53, 0x70, 0x2c, 0xb3, 0x17, 0x3a,
+	0xd3, 0x80, 0x5e, 0x80, 0x57, 0x5d, 0xf8, 0x7b, 0x23, 0x34, 0x03, 0xf2, 0x09, 0xa5, 0xad, 0x32,
+	0x44, 0x91, 0xa5, 0x5c, 0x20, 0x19, 0x41, 0xe7, 0xde, 0x40, 0xb6, 0xde, 0xd3, 0xad, 0x7a, 0x55,
+	0x60, 0xe9, 0x56, 0xec, 0x59, 0x71, 0x9b, 0xb0, 0xc5, 0x0c, 0x37, 0xba, 0xe6, 0x5e, 0x58, 0x03,
+	0x8f, 0x0e, 0x65, 0x0e, 0xde, 0x75, 0x15, 0xf0, 0x9f, 0x12, 0xd1, 0x1f, 0xe0, 0xcd, 0x59, 0xcc,
+	0x23, 0x59, 0xe4, 0x4f, 0xa9, 0xfe, 0x89, 0x13, 0x27, 0x3e, 0x74, 0x16, 0x29, 0x97, 0xc8, 0xa5,
+	0x6e, 0xa9, 0x17, 0x96, 0x26, 0xbd, 0x86, 0x7e, 0x55, 0x40, 0x29, 0xa1, 0x7f, 0xf9, 0xe7, 0x5b,
+	0x8c, 0xce, 0x2e, 0xa3, 0x0b, 0xad, 0x9b, 0x94, 0x2d, 0x69, 0x00, 0x67, 0x5f, 0x98, 0x90, 0x57,
+	0xc5, 0x92, 0xc9, 0x8f, 0x0f, 0xc8, 0xa5, 0x28, 0xf9, 0x5f, 0x40, 0x3b, 0xba, 0x93, 0x98, 0x6b,
+	0xfe, 0x56, 0x68, 0x0c, 0x85, 0x26, 0x6c, 0xcd, 0x0c, 0xdf, 0x49, 0x68, 0x0c, 0xfa, 0xcb, 0x01,
+	0xa8, 0x29, 0xc8, 0x10, 0x8e, 0x85, 0x62, 0xe1, 0x0b, 0xb4, 0xb7, 0x2b, 0x5b, 0x4d, 0x5a, 0xb2,
+	0x35, 0x0a, 0x19, 0xad, 0x33, 0xab, 0xf0, 0x1a, 0x20, 0x67, 0xe0, 0x46, 0x0b, 0xc9, 0x52, 0x6e,
+	0x7f, 0xaa, 0xb5, 0x54, 0x5a, 0xd3, 0x6c, 0x4b, 0xc3, 0xb6, 0x3d, 0xbb, 0x27, 0xed, 0xc3, 0x3d,
+	0x71, 0x6b, 0x49, 0x28, 0xce, 0x45, 0xc2, 0xd4, 0x0c, 0x3a, 0x86, 0xd3, 0x58, 0x0a, 0x5f, 0xb2,
+	0x18, 0x85, 0xf4, 0x8f, 0x0d, 0x6e, 0x2c, 0x42, 0xa1, 0x97, 0xe5, 0xf8, 0xc0, 0xd2, 0x42, 0x7c,
+	0x8e, 0xc4, 0xca, 0xf7, 0xb4, 0x77, 0x07, 0x53, 0x79, 0x56, 0xca, 0x07, 0x26, 0x8f, 0x3a, 0xd3,
+	0x00, 0x06, 0x07, 0xa3, 0xb4, 0x8a, 0xbf, 0x00, 0x17, 0x35, 0xe2, 0x1f, 0x9d, 0x37, 0x47, 0xdd,
+	0xc9, 0xf3, 0x52, 0x06, 0x55, 0x6c, 0x68, 0x03, 0x26, 0x3f, 0x1d, 0x38, 0x99, 0xe1, 0xe6, 0x6b,
+	0xc4, 0xa3, 0x18, 0xd7, 0xaa, 0xce, 0x77, 0xe0, 0x55, 0xef, 0x07, 0x19, 0xd8, 0x9b, 0xfb, 0x2f,
+	0xca, 0xb0, 0x54, 0x56, 0xa5, 0x7e, 0xda, 0x20, 0x23, 0xf0, 0x02, 0x4c, 0xd0, 0xdc, 0xdc, 0x11,
+	0xc8, 0xb0, 0x6b, 0x2d, 0x2d, 0x82, 0x06, 0x79, 0x0b, 0x50, 0x2f, 0xea, 0x5e, 0xe8, 0x4b, 0x6b,
+	0x1d, 0x6e, 0x32, 0x6d, 0x90, 0x10, 0x9e, 0xed, 0x35, 0x4d, 0x5e, 0xdb, 0xf8, 0xc7, 0x75, 0x35,
+	0x7c, 0xf3, 0x27, 0x77, 0xc9, 0x39, 0x79, 0x0f, 0xee, 0x5c, 0xbf, 0xa3, 0xe4, 0x12, 0x5a, 0xea,
+	0x54, 0x75, 0xbd, 0xbf, 0x04, 0xc3, 0xfe, 0xbe, 0x83, 0x36, 0x6e, 0x5d, 0x0d, 0x5d, 0xfe, 0x1e,
+	0x00, 0x4a, 0xe9, 0x7b, 0xe3, 0x8d, 0x05, 0x00, 0x00,
 }
@@ -0,0 +1,176 @@
+// Package expiry reports when the metadata held by a notary server expires.
+package expiry
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// DefaultInterval is how often a Monitor scans its store, unless told otherwise
+const DefaultInterval = 10 * time.Minute
+
+// buckets are the upper bounds of the histograms of seconds to expiry: already
+// expired, an hour, a day, a week, 30 days, 90 days, 180 days, a year, 3 years
+// and 10 years
+var buckets = []float64{0, 3600, 86400, 604800, 2592000, 7776000, 15552000, 31536000, 94608000, 315360000}
+
+var secondsToExpiryDesc = prometheus.NewDesc(
+	"notary_server_metadata_seconds_to_expiry",
+	"Seconds until the current metadata of each repository expires, by role.  Delegations are counted together.",
+	[]string{"role"}, nil,
+)
+
+// Record describes when the current version of a role's metadata expires
+type Record struct {
+	GUN     data.GUN      `json:"gun"`
+	Role    data.RoleName `json:"role"`
+	Version int           `json:"version"`
+	Expires time.Time     `json:"expires"`
+}
+
+// Scan calls fn with a Record for the current metadata of every role of every
+// GUN in store.  Metadata which cannot be parsed is logged and skipped.
+func Scan(store storage.MetaStore, fn func(Record)) error {
+	return store.WalkCurrent(func(gun data.GUN, update storage.MetaUpdate) error {
+		var meta data.SignedMeta
+		if err := json.Unmarshal(update.Data, &meta); err != nil {
+			logrus.Warnf("unable to parse %s metadata of %s: %v", update.Role, gun, err)
+			return nil
+		}
+		fn(Record{GUN: gun, Role: update.Role, Version: meta.Signed.Version, Expires: meta.Signed.Expires})
+		return nil
+	})
+}
+
+// Expiring returns the Records for the current metadata in store which
+// expires before the given time, soonest first.  If any roles are given, only
+// the metadata of those roles is returned, with data.DelegationsExpiryRole
+// standing for every delegation.
+func Expiring(store storage.MetaStore, before time.Time, roles ...data.RoleName) ([]Record, error) {
+	records := []Record{}
+	err := Scan(store, func(record Record) {
+		if record.Expires.Before(before) && matchesRole(record.Role, roles) {
+			records = append(records, record)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Expires.Before(records[j].Expires)
+	})
+	return records, nil
+}
+
+func matchesRole(role data.RoleName, roles []data.RoleName) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if r == role || (r == data.DelegationsExpiryRole && data.IsDelegation(role)) {
+			return true
+		}
+	}
+	return false
+}
+
+// histogram holds the bucket counts of a constant prometheus histogram
+type histogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	for _, bound := range buckets {
+		if v <= bound {
+			h.buckets[bound]++
+		}
+	}
+}
+
+// Monitor is a prometheus.Collector which exports a histogram, per role, of
+// how long the current metadata of every repository in a store has until it
+// expires.  The store is scanned every Interval rather than on each scrape.
+type Monitor struct {
+	store    storage.MetaStore
+	Interval time.Duration
+
+	lock       sync.Mutex
+	histograms map[string]*histogram
+}
+
+// NewMonitor returns a Monitor for store, which has not scanned it yet
+func NewMonitor(store storage.MetaStore) *Monitor {
+	return &Monitor{
+		store:      store,
+		Interval:   DefaultInterval,
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// Run scans the store every Interval until ctx is done
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Update(); err != nil {
+			logrus.Errorf("unable to scan metadata for expiry: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update scans the store and replaces the exported histograms
+func (m *Monitor) Update() error {
+	now := time.Now()
+	histograms := make(map[string]*histogram)
+	err := Scan(m.store, func(record Record) {
+		label := record.Role.String()
+		if data.IsDelegation(record.Role) {
+			label = data.DelegationsExpiryRole.String()
+		}
+		h, ok := histograms[label]
+		if !ok {
+			h = &histogram{buckets: make(map[float64]uint64, len(buckets))}
+			histograms[label] = h
+		}
+		h.observe(record.Expires.Sub(now).Seconds())
+	})
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.histograms = histograms
+	m.lock.Unlock()
+	return nil
+}
+
+// Describe implements prometheus.Collector
+func (m *Monitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- secondsToExpiryDesc
+}
+
+// Collect implements prometheus.Collector
+func (m *Monitor) Collect(ch chan<- prometheus.Metric) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for role, h := range m.histograms {
+		ch <- prometheus.MustNewConstHistogram(secondsToExpiryDesc, h.count, h.sum, h.buckets, role)
+	}
+}
@@ -0,0 +1,158 @@
+package expiry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/testutils"
+)
+
+// addMeta stores metadata for role in gun which only has a version and an
+// expiry
+func addMeta(t *testing.T, store storage.MetaStore, gun data.GUN, role data.RoleName, version int, expires time.Time) {
+	meta := fmt.Sprintf(`{"signed": {"_type": "Targets", "version": %d, "expires": %q}, "signatures": []}`,
+		version, expires.Format(time.RFC3339))
+	require.NoError(t, store.UpdateCurrent(gun, storage.MetaUpdate{Role: role, Version: version, Data: []byte(meta)}))
+}
+
+func TestExpiring(t *testing.T) {
+	store := storage.NewMemStorage()
+	now := time.Now().Truncate(time.Second)
+
+	// only the current version counts
+	addMeta(t, store, "docker.com/a", data.CanonicalRootRole, 1, now.Add(time.Hour))
+	addMeta(t, store, "docker.com/a", data.CanonicalRootRole, 2, now.Add(365*24*time.Hour))
+	addMeta(t, store, "docker.com/a", data.CanonicalTargetsRole, 1, now.Add(48*time.Hour))
+	addMeta(t, store, "docker.com/a", "targets/releases", 3, now.Add(-time.Hour))
+	addMeta(t, store, "docker.com/b", data.CanonicalTargetsRole, 4, now.Add(2*time.Hour))
+	// metadata which cannot be parsed is skipped
+	require.NoError(t, store.UpdateCurrent("docker.com/c", storage.MetaUpdate{
+		Role: data.CanonicalTargetsRole, Version: 1, Data: []byte("garbage")}))
+
+	records, err := Expiring(store, now.Add(72*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, []Record{
+		{GUN: "docker.com/a", Role: "targets/releases", Version: 3, Expires: now.Add(-time.Hour)},
+		{GUN: "docker.com/b", Role: data.CanonicalTargetsRole, Version: 4, Expires: now.Add(2 * time.Hour)},
+		{GUN: "docker.com/a", Role: data.CanonicalTargetsRole, Version: 1, Expires: now.Add(48 * time.Hour)},
+	}, normalize(records))
+
+	records, err = Expiring(store, now)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, data.RoleName("targets/releases"), records[0].Role)
+
+	records, err = Expiring(store, now.Add(72*time.Hour), data.DelegationsExpiryRole, data.CanonicalRootRole)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, data.RoleName("targets/releases"), records[0].Role)
+
+	records, err = Expiring(storage.NewMemStorage(), now)
+	require.NoError(t, err)
+	require.NotNil(t, records)
+	require.Empty(t, records)
+}
+
+// normalize strips the monotonic clock readings and locations from the
+// expiry times, so that they can be compared
+func normalize(records []Record) []Record {
+	for i := range records {
+		records[i].Expires = records[i].Expires.Round(0).Local()
+	}
+	return records
+}
+
+func TestScanRealMetadata(t *testing.T) {
+	store := storage.NewMemStorage()
+	metadata, _, err := testutils.NewRepoMetadata("docker.com/notary", "targets/a")
+	require.NoError(t, err)
+	for role, meta := range metadata {
+		require.NoError(t, store.UpdateCurrent("docker.com/notary", storage.MetaUpdate{Role: role, Version: 1, Data: meta}))
+	}
+
+	roles := make(map[data.RoleName]Record)
+	require.NoError(t, Scan(store, func(record Record) {
+		roles[record.Role] = record
+	}))
+	require.Len(t, roles, len(metadata))
+	for role := range metadata {
+		require.Equal(t, 1, roles[role].Version)
+		require.True(t, roles[role].Expires.After(time.Now()), role.String())
+	}
+}
+
+func collect(t *testing.T, m *Monitor) map[string]*dto.Histogram {
+	ch := make(chan prometheus.Metric, 10)
+	m.Collect(ch)
+	close(ch)
+	histograms := make(map[string]*dto.Histogram)
+	for metric := range ch {
+		var out dto.Metric
+		require.NoError(t, metric.Write(&out))
+		require.Len(t, out.Label, 1)
+		histograms[out.Label[0].GetValue()] = out.Histogram
+	}
+	return histograms
+}
+
+func bucketCounts(h *dto.Histogram) map[float64]uint64 {
+	counts := make(map[float64]uint64)
+	for _, b := range h.Bucket {
+		counts[b.GetUpperBound()] = b.GetCumulativeCount()
+	}
+	return counts
+}
+
+func TestMonitor(t *testing.T) {
+	store := storage.NewMemStorage()
+	now := time.Now()
+
+	m := NewMonitor(store)
+	require.Equal(t, DefaultInterval, m.Interval)
+	require.Empty(t, collect(t, m))
+
+	addMeta(t, store, "docker.com/a", data.CanonicalTargetsRole, 1, now.Add(-time.Hour))
+	addMeta(t, store, "docker.com/b", data.CanonicalTargetsRole, 1, now.Add(2*time.Hour))
+	addMeta(t, store, "docker.com/a", "targets/a", 1, now.Add(10*24*time.Hour))
+	addMeta(t, store, "docker.com/a", "targets/b", 1, now.Add(100*24*time.Hour))
+	addMeta(t, store, "docker.com/a", data.CanonicalRootRole, 1, now.Add(5*365*24*time.Hour))
+
+	// nothing changes until the store is scanned again
+	require.Empty(t, collect(t, m))
+	require.NoError(t, m.Update())
+	histograms := collect(t, m)
+	require.Len(t, histograms, 3)
+
+	targets := histograms[data.CanonicalTargetsRole.String()]
+	require.Equal(t, uint64(2), targets.GetSampleCount())
+	require.InDelta(t, time.Hour.Seconds(), targets.GetSampleSum(), 60)
+	counts := bucketCounts(targets)
+	require.Equal(t, uint64(1), counts[0])
+	require.Equal(t, uint64(1), counts[3600])
+	require.Equal(t, uint64(2), counts[86400])
+
+	delegations := histograms[data.DelegationsExpiryRole.String()]
+	require.Equal(t, uint64(2), delegations.GetSampleCount())
+	counts = bucketCounts(delegations)
+	require.Equal(t, uint64(0), counts[604800])
+	require.Equal(t, uint64(1), counts[2592000])
+	require.Equal(t, uint64(2), counts[15552000])
+
+	root := histograms[data.CanonicalRootRole.String()]
+	counts = bucketCounts(root)
+	require.Equal(t, uint64(0), counts[94608000])
+	require.Equal(t, uint64(1), counts[315360000])
+
+	require.NoError(t, store.Delete("docker.com/a"))
+	require.NoError(t, m.Update())
+	histograms = collect(t, m)
+	require.Len(t, histograms, 1)
+	require.Equal(t, uint64(1), histograms[data.CanonicalTargetsRole.String()].GetSampleCount())
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/server/errors"
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/utils"
+)
+
+// maxCatalogPageSize is the most repositories a single catalog request may
+// return
+const maxCatalogPageSize = 1000
+
+type catalogResponse struct {
+	Repositories []data.GUN `json:"repositories"`
+}
+
+// Catalog lists, in ascending order, the repositories which the requester
+// can pull from.  The n parameter is the most to list, the last parameter
+// the repository to list from (exclusive), and the prefix parameter limits
+// the list to repositories beginning with it.  If there may be more
+// repositories, a Link header to the next page is set.
+func Catalog(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var (
+		logger = ctxu.GetLogger(ctx)
+		qs     = r.URL.Query()
+		prefix = qs.Get("prefix")
+		last   = data.GUN(qs.Get("last"))
+		n      = notary.DefaultPageSize
+	)
+	store, ok := ctx.Value(notary.CtxKeyMetaStore).(storage.MetaStore)
+	if !ok {
+		logger.Errorf("%d GET unable to retrieve storage", http.StatusInternalServerError)
+		return errors.ErrNoStorage.WithDetail(nil)
+	}
+	if qs.Get("n") != "" {
+		pageSize, err := strconv.Atoi(qs.Get("n"))
+		if err != nil || pageSize < 1 {
+			logger.Errorf("%d GET invalid n: %s", http.StatusBadRequest, qs.Get("n"))
+			return errors.ErrInvalidParams.WithDetail(
+				fmt.Sprintf("invalid n parameter: %s", qs.Get("n")),
+			)
+		}
+		n = pageSize
+	}
+	if n > maxCatalogPageSize {
+		n = maxCatalogPageSize
+	}
+	// no filter means that the server does not authorize requests
+	filter, _ := ctx.Value(notary.CtxKeyRepoFilter).(utils.RepoFilter)
+
+	repos := make([]data.GUN, 0, n)
+	for len(repos) < n {
+		guns, err := store.ListGUNs(prefix, last, n)
+		if err != nil {
+			logger.Errorf("%d GET could not list repositories: %s", http.StatusInternalServerError, err.Error())
+			return errors.ErrUnknown.WithDetail(err)
+		}
+		for _, gun := range guns {
+			last = gun
+			if filter == nil || filter(gun.String()) {
+				repos = append(repos, gun)
+				if len(repos) == n {
+					break
+				}
+			}
+		}
+		if len(guns) < n {
+			break
+		}
+	}
+
+	out, err := json.Marshal(&catalogResponse{Repositories: repos})
+	if err != nil {
+		logger.Errorf("%d GET could not json.Marshal catalogResponse", http.StatusInternalServerError)
+		return errors.ErrUnknown.WithDetail(err)
+	}
+	if len(repos) == n {
+		next := url.Values{}
+		next.Set("n", strconv.Itoa(n))
+		next.Set("last", last.String())
+		if prefix != "" {
+			next.Set("prefix", prefix)
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	w.Write(out)
+	return nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/server/errors"
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/utils"
+)
+
+func getCatalog(t *testing.T, ctx context.Context, query string) ([]data.GUN, string) {
+	req, err := http.NewRequest("GET", "/v2/_trust/catalog?"+query, nil)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	require.NoError(t, Catalog(ctx, rec, req))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp catalogResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Repositories)
+	return resp.Repositories, rec.Header().Get("Link")
+}
+
+func TestCatalog(t *testing.T) {
+	s := storage.NewMemStorage()
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, s)
+
+	guns, link := getCatalog(t, ctx, "")
+	require.Empty(t, guns)
+	require.Empty(t, link)
+
+	for _, gun := range []data.GUN{"docker.io/a", "docker.io/b", "docker.io/c", "quay.io/a"} {
+		require.NoError(t, s.UpdateCurrent(gun, storage.MetaUpdate{Role: data.CanonicalRootRole, Version: 1, Data: []byte("{}")}))
+	}
+
+	guns, link = getCatalog(t, ctx, "")
+	require.Equal(t, []data.GUN{"docker.io/a", "docker.io/b", "docker.io/c", "quay.io/a"}, guns)
+	require.Empty(t, link)
+
+	guns, link = getCatalog(t, ctx, "n=2&prefix=docker.io/")
+	require.Equal(t, []data.GUN{"docker.io/a", "docker.io/b"}, guns)
+	require.Equal(t, `</v2/_trust/catalog?last=docker.io%2Fb&n=2&prefix=docker.io%2F>; rel="next"`, link)
+
+	guns, link = getCatalog(t, ctx, "n=2&prefix=docker.io/&last=docker.io/b")
+	require.Equal(t, []data.GUN{"docker.io/c"}, guns)
+	require.Empty(t, link)
+
+	// repositories the requester cannot pull are skipped, without shortening
+	// the page
+	filtered := context.WithValue(ctx, notary.CtxKeyRepoFilter, utils.RepoFilter(func(gun string) bool {
+		return !strings.HasSuffix(gun, "/b")
+	}))
+	guns, link = getCatalog(t, filtered, "n=1&last=docker.io/a")
+	require.Equal(t, []data.GUN{"docker.io/c"}, guns)
+	require.NotEmpty(t, link)
+	guns, _ = getCatalog(t, filtered, "n=2")
+	require.Equal(t, []data.GUN{"docker.io/a", "docker.io/c"}, guns)
+	guns, link = getCatalog(t, filtered, "n=100")
+	require.Equal(t, []data.GUN{"docker.io/a", "docker.io/c", "quay.io/a"}, guns)
+	require.Empty(t, link)
+}
+
+func TestCatalogErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, storage.NewMemStorage())
+	for _, query := range []string{"n=0", "n=-1", "n=nope"} {
+		req, err := http.NewRequest("GET", "/v2/_trust/catalog?"+query, nil)
+		require.NoError(t, err)
+		err = Catalog(ctx, httptest.NewRecorder(), req)
+		require.Error(t, err, query)
+		require.Contains(t, err.Error(), errors.ErrInvalidParams.Message())
+	}
+
+	req, err := http.NewRequest("GET", "/v2/_trust/catalog", nil)
+	require.NoError(t, err)
+	err = Catalog(context.Background(), httptest.NewRecorder(), req)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), errors.ErrNoStorage.Message())
+}
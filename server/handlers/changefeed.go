@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	ctxu "github.com/docker/distribution/context"
 	"github.com/gorilla/mux"
@@ -20,20 +22,60 @@ type changefeedResponse struct {
 	Records         []storage.Change `json:"records"`
 }
 
-// Changefeed returns a list of changes according to the provided filters
+// maxChangefeedWait is the longest a long-polling changefeed request may
+// wait for new changes
+const maxChangefeedWait = 60 * time.Second
+
+// changefeedKeepAlive is how often a comment is sent on an idle changefeed
+// stream, which also re-checks for changes the store may not have notified.
+// It's a var so that the tests can turn it down.
+var changefeedKeepAlive = 15 * time.Second
+
+// Changefeed returns a list of changes according to the provided filters.
+//
+// If the request accepts text/event-stream, the connection is instead held
+// open and each change is sent as a server-sent event as it is committed,
+// using the change ID as the event ID so that a reconnecting client resumes
+// from its Last-Event-ID.  Otherwise, if a wait parameter (in seconds) is
+// given and there are no changes after change_id, the response is held until
+// there are, or until the wait expires.  In both cases a change_id of -1
+// means only changes committed after the request are returned.
 func Changefeed(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	var (
-		vars                = mux.Vars(r)
-		logger              = ctxu.GetLogger(ctx)
-		qs                  = r.URL.Query()
-		gun                 = vars["gun"]
-		changeID            = qs.Get("change_id")
-		store, records, err = checkChangefeedInputs(logger, ctx.Value(notary.CtxKeyMetaStore), qs.Get("records"))
+		vars      = mux.Vars(r)
+		logger    = ctxu.GetLogger(ctx)
+		qs        = r.URL.Query()
+		gun       = vars["gun"]
+		changeID  = qs.Get("change_id")
+		pageSize  = qs.Get("records")
+		streaming = strings.Contains(r.Header.Get("Accept"), "text/event-stream")
 	)
+	if streaming && pageSize == "" {
+		// the page size only matters to a stream while it catches up
+		pageSize = "0"
+	}
+	store, records, err := checkChangefeedInputs(logger, ctx.Value(notary.CtxKeyMetaStore), pageSize)
 	if err != nil {
 		// err already logged and in correct format.
 		return err
 	}
+	if streaming {
+		if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+			changeID = lastEventID
+		}
+		return streamChangefeed(r.Context(), logger, w, store, gun, changeID, records)
+	}
+	if wait := qs.Get("wait"); wait != "" {
+		timeout, err := checkChangefeedWait(logger, wait)
+		if err != nil {
+			return err
+		}
+		out, err := waitChangefeed(r.Context(), logger, store, gun, changeID, records, timeout)
+		if err == nil {
+			w.Write(out)
+		}
+		return err
+	}
 	out, err := changefeed(logger, store, gun, changeID, records)
 	if err == nil {
 		w.Write(out)
@@ -42,16 +84,27 @@ func Changefeed(ctx context.Context, w http.ResponseWriter, r *http.Request) err
 }
 
 func changefeed(logger ctxu.Logger, store storage.MetaStore, gun, changeID string, records int64) ([]byte, error) {
+	changes, err := getChanges(logger, store, gun, changeID, records)
+	if err != nil {
+		return nil, err
+	}
+	return marshalChanges(logger, changes)
+}
+
+func getChanges(logger ctxu.Logger, store storage.MetaStore, gun, changeID string, records int64) ([]storage.Change, error) {
 	changes, err := store.GetChanges(changeID, int(records), gun)
 	switch err.(type) {
 	case nil:
-		// no error to return
+		return changes, nil
 	case storage.ErrBadQuery:
 		return nil, errors.ErrInvalidParams.WithDetail(err)
 	default:
 		logger.Errorf("%d GET could not retrieve records: %s", http.StatusInternalServerError, err.Error())
 		return nil, errors.ErrUnknown.WithDetail(err)
 	}
+}
+
+func marshalChanges(logger ctxu.Logger, changes []storage.Change) ([]byte, error) {
 	out, err := json.Marshal(&changefeedResponse{
 		NumberOfRecords: len(changes),
 		Records:         changes,
@@ -63,6 +116,149 @@ func changefeed(logger ctxu.Logger, store storage.MetaStore, gun, changeID strin
 	return out, nil
 }
 
+// watchChanges starts watching the store for new changes.  It must be called
+// before the first GetChanges so that no change can be missed in between.
+func watchChanges(logger ctxu.Logger, store storage.MetaStore) (<-chan struct{}, func(), error) {
+	notify, stop, err := store.WatchChanges()
+	if err != nil {
+		logger.Errorf("%d GET could not watch for changes: %s", http.StatusInternalServerError, err.Error())
+		return nil, nil, errors.ErrUnknown.WithDetail(err)
+	}
+	return notify, stop, nil
+}
+
+// resolveChangeID replaces a change ID of -1, which for GetChanges means
+// counting back from the most recent change, with the ID of the most recent
+// change, so that following it returns only changes committed from now on
+func resolveChangeID(logger ctxu.Logger, store storage.MetaStore, changeID string) (string, error) {
+	if changeID != "-1" {
+		return changeID, nil
+	}
+	latest, err := getChanges(logger, store, "", "-1", 1)
+	if err != nil {
+		return "", err
+	}
+	if len(latest) == 0 {
+		return "0", nil
+	}
+	return latest[len(latest)-1].ID, nil
+}
+
+func waitChangefeed(ctx context.Context, logger ctxu.Logger, store storage.MetaStore, gun, changeID string, records int64, timeout time.Duration) ([]byte, error) {
+	notify, stop, err := watchChanges(logger, store)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	if changeID, err = resolveChangeID(logger, store, changeID); err != nil {
+		return nil, err
+	}
+
+	expired := time.After(timeout)
+	for {
+		changes, err := getChanges(logger, store, gun, changeID, records)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 {
+			return marshalChanges(logger, changes)
+		}
+		select {
+		case <-notify:
+		case <-expired:
+			return marshalChanges(logger, nil)
+		case <-ctx.Done():
+			return marshalChanges(logger, nil)
+		}
+	}
+}
+
+func streamChangefeed(ctx context.Context, logger ctxu.Logger, w http.ResponseWriter, store storage.MetaStore, gun, changeID string, records int64) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logger.Errorf("%d GET changefeed streaming is not supported by the response writer", http.StatusInternalServerError)
+		return errors.ErrUnknown.WithDetail("streaming not supported")
+	}
+	notify, stop, err := watchChanges(logger, store)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	if changeID, err = resolveChangeID(logger, store, changeID); err != nil {
+		return err
+	}
+	// query once before sending any headers, so that an invalid change ID is
+	// still reported as a normal error response
+	if records < 0 {
+		// a stream only ever moves forwards
+		records = -records
+	}
+	changes, err := getChanges(logger, store, gun, changeID, records)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(changefeedKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		for _, change := range changes {
+			payload, err := json.Marshal(change)
+			if err != nil {
+				logger.Errorf("changefeed stream could not json.Marshal change %s: %s", change.ID, err.Error())
+				return nil
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\ndata: %s\n\n", change.ID, payload); err != nil {
+				return nil
+			}
+			changeID = change.ID
+		}
+		flusher.Flush()
+
+		// a full page means there may be more to catch up on before waiting
+		if int64(len(changes)) < records {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-notify:
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return nil
+				}
+				flusher.Flush()
+			}
+		}
+
+		changes, err = getChanges(logger, store, gun, changeID, records)
+		if err != nil {
+			// the response has already started, so all we can do is end it
+			// and let the client reconnect from its last event
+			return nil
+		}
+	}
+}
+
+// checkChangefeedWait parses the number of seconds a long-polling request
+// should wait, capping it at maxChangefeedWait
+func checkChangefeedWait(logger ctxu.Logger, w string) (time.Duration, error) {
+	seconds, err := strconv.ParseInt(w, 10, 32)
+	if err != nil || seconds < 0 {
+		logger.Errorf("%d GET invalid wait: %s", http.StatusBadRequest, w)
+		return 0, errors.ErrInvalidParams.WithDetail(
+			fmt.Sprintf("invalid wait parameter: %s", w),
+		)
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if timeout > maxChangefeedWait {
+		timeout = maxChangefeedWait
+	}
+	return timeout, nil
+}
+
 func checkChangefeedInputs(logger ctxu.Logger, s interface{}, r string) (
 	store storage.MetaStore, pageSize int64, err error) {
 
@@ -1,14 +1,22 @@
 package handlers
 
 import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	ctxu "github.com/docker/distribution/context"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"github.com/theupdateframework/notary"
 	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+	"golang.org/x/net/context"
 )
 
 type changefeedArgs struct {
@@ -149,3 +157,113 @@ func Test_checkChangefeedInputs(t *testing.T) {
 
 	}
 }
+
+func publishChange(t *testing.T, s storage.MetaStore, version int) {
+	require.NoError(t, s.UpdateCurrent("alpine", storage.MetaUpdate{
+		Role:    data.CanonicalTimestampRole,
+		Version: version,
+		Data:    []byte{byte(version)},
+	}))
+}
+
+func Test_waitChangefeed(t *testing.T) {
+	s := storage.NewMemStorage()
+	logger := logrus.New()
+	publishChange(t, s, 1)
+
+	// existing changes are returned without waiting
+	out, err := waitChangefeed(context.Background(), logger, s, "", "0", notary.DefaultPageSize, time.Minute)
+	require.NoError(t, err)
+	require.Contains(t, string(out), `"count":1`)
+
+	// no new changes before the wait expires
+	start := time.Now()
+	out, err = waitChangefeed(context.Background(), logger, s, "", "1", notary.DefaultPageSize, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, `{"count":0,"records":null}`, string(out))
+	require.True(t, time.Since(start) >= 100*time.Millisecond)
+
+	// a change published while waiting is returned, and -1 skips the existing
+	// changes
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		publishChange(t, s, 2)
+	}()
+	out, err = waitChangefeed(context.Background(), logger, s, "", "-1", notary.DefaultPageSize, time.Minute)
+	require.NoError(t, err)
+	var resp changefeedResponse
+	require.NoError(t, json.Unmarshal(out, &resp))
+	require.Len(t, resp.Records, 1)
+	require.Equal(t, "2", resp.Records[0].ID)
+
+	// the wait ends if the client goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out, err = waitChangefeed(ctx, logger, s, "", "2", notary.DefaultPageSize, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, `{"count":0,"records":null}`, string(out))
+
+	_, err = waitChangefeed(context.Background(), logger, s, "", "not_a_number", notary.DefaultPageSize, time.Minute)
+	require.Error(t, err)
+}
+
+func Test_checkChangefeedWait(t *testing.T) {
+	logger := logrus.New()
+	valids := map[string]time.Duration{
+		"0":    0,
+		"30":   30 * time.Second,
+		"3600": maxChangefeedWait,
+	}
+	for wait, expected := range valids {
+		timeout, err := checkChangefeedWait(logger, wait)
+		require.NoError(t, err)
+		require.Equal(t, expected, timeout)
+	}
+	for _, invalid := range []string{"-1", "1s", "not_a_number"} {
+		_, err := checkChangefeedWait(logger, invalid)
+		require.Error(t, err, "expected error with %s", invalid)
+	}
+}
+
+// unnotifiedStore never notifies watchers, like a SQL store whose changes are
+// written by another server
+type unnotifiedStore struct {
+	storage.MetaStore
+}
+
+func (s unnotifiedStore) WatchChanges() (<-chan struct{}, func(), error) {
+	return make(chan struct{}), func() {}, nil
+}
+
+func TestChangefeedStreamKeepAlive(t *testing.T) {
+	defer func(d time.Duration) { changefeedKeepAlive = d }(changefeedKeepAlive)
+	changefeedKeepAlive = 10 * time.Millisecond
+
+	s := storage.NewMemStorage()
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, unnotifiedStore{s})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, Changefeed(ctx, w, r))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"?change_id=-1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body := bufio.NewReader(res.Body)
+
+	line, err := body.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, ": keep-alive\n", line)
+
+	// the change is picked up on the next keep-alive even though the store
+	// never notified it
+	publishChange(t, s, 1)
+	for !strings.HasPrefix(line, "id: ") {
+		line, err = body.ReadString('\n')
+		require.NoError(t, err)
+	}
+	require.Equal(t, "id: 1\n", line)
+}
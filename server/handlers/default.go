@@ -96,7 +96,21 @@ func atomicUpdateHandler(ctx context.Context, w http.ResponseWriter, r *http.Req
 			Data:    inBuf.Bytes(),
 		})
 	}
-	updates, err = validateUpdate(cryptoService, gun, updates, store)
+	// if someone else has published these versions since the client downloaded
+	// the metadata it updated, it has to rebase its changes on theirs, whether
+	// or not they would still validate.  This has to be checked before
+	// validating the update, which would otherwise fail against the newer
+	// metadata and be reported as an invalid update, which clients do not
+	// retry, rather than as a conflict.  It costs one lookup per role in the
+	// update.
+	for _, update := range updates {
+		if _, _, err := store.GetVersion(gun, update.Role, update.Version); err == nil {
+			logger.Infof("400 POST %s version %d already exists", update.Role, update.Version)
+			return errors.ErrOldVersion.WithDetail(storage.ErrOldVersion{})
+		}
+	}
+	expiryPolicy, _ := ctx.Value(notary.CtxKeyExpiryPolicy).(*data.ExpiryPolicy)
+	updates, err = validateUpdateWithPolicy(cryptoService, gun, updates, store, expiryPolicy)
 	if err != nil {
 		serializable, serializableError := validation.NewSerializableError(err)
 		if serializableError != nil {
@@ -412,6 +412,41 @@ func TestAtomicUpdateValidationFailurePropagated(t *testing.T) {
 	require.IsType(t, validation.ErrBadHierarchy{}, serializable.Error)
 }
 
+// an update to a version someone else has already published is reported as a
+// version error, even if it would not otherwise validate, so that the client
+// knows to rebase its changes
+func TestAtomicUpdateExistingVersionIsVersionError(t *testing.T) {
+	metaStore := storage.NewMemStorage()
+	var gun data.GUN = "testGUN"
+	vars := map[string]string{"gun": gun.String()}
+
+	repo, cs, err := testutils.EmptyRepo(gun)
+	require.NoError(t, err)
+
+	state := handlerState{store: metaStore, crypto: mustCopyKeys(t, cs, data.CanonicalTimestampRole)}
+
+	r, tg, sn, ts, err := testutils.Sign(repo)
+	require.NoError(t, err)
+	rs, tgs, _, _, err := testutils.Serialize(r, tg, sn, ts)
+	require.NoError(t, err)
+	require.NoError(t, metaStore.UpdateCurrent(gun, storage.MetaUpdate{
+		Role: data.CanonicalTargetsRole, Version: repo.Targets[data.CanonicalTargetsRole].Signed.Version, Data: tgs}))
+
+	req, err := store.NewMultiPartMetaRequest("", map[string][]byte{
+		data.CanonicalRootRole.String():    rs,
+		data.CanonicalTargetsRole.String(): tgs,
+	})
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+
+	err = atomicUpdateHandler(getContext(state), rw, req, vars)
+	require.Error(t, err)
+	errorObj, ok := err.(errcode.Error)
+	require.True(t, ok, "Expected an errcode.Error, got %v", err)
+	require.Equal(t, errors.ErrOldVersion, errorObj.Code)
+}
+
 type failStore struct {
 	storage.MetaStore
 }
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/server/errors"
+	"github.com/theupdateframework/notary/server/expiry"
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// defaultExpiringWithin is how far ahead Expiring looks if no within
+// parameter is given
+const defaultExpiringWithin = 30 * 24 * time.Hour
+
+type expiringResponse struct {
+	NumberOfRecords int             `json:"count"`
+	Records         []expiry.Record `json:"records"`
+}
+
+// Expiring reports the current metadata of every repository which expires
+// within the duration given by the within parameter, soonest first.  The role
+// parameter, which may be repeated, limits the report to the given roles, or
+// to every delegation for "delegations".
+func Expiring(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var (
+		logger = ctxu.GetLogger(ctx)
+		qs     = r.URL.Query()
+		within = defaultExpiringWithin
+		roles  []data.RoleName
+	)
+	store, ok := ctx.Value(notary.CtxKeyMetaStore).(storage.MetaStore)
+	if !ok {
+		logger.Errorf("%d GET unable to retrieve storage", http.StatusInternalServerError)
+		return errors.ErrNoStorage.WithDetail(nil)
+	}
+	if qs.Get("within") != "" {
+		d, err := time.ParseDuration(qs.Get("within"))
+		if err != nil || d < 0 {
+			logger.Errorf("%d GET invalid within: %s", http.StatusBadRequest, qs.Get("within"))
+			return errors.ErrInvalidParams.WithDetail("within must be a non-negative duration, such as 720h")
+		}
+		within = d
+	}
+	for _, role := range qs["role"] {
+		roleName := data.RoleName(role)
+		if roleName != data.DelegationsExpiryRole && !data.ValidRole(roleName) {
+			logger.Errorf("%d GET invalid role: %s", http.StatusBadRequest, role)
+			return errors.ErrInvalidParams.WithDetail("invalid role: " + role)
+		}
+		roles = append(roles, roleName)
+	}
+
+	records, err := expiry.Expiring(store, time.Now().Add(within), roles...)
+	if err != nil {
+		logger.Errorf("%d GET could not scan metadata: %s", http.StatusInternalServerError, err.Error())
+		return errors.ErrUnknown.WithDetail(err)
+	}
+	out, err := json.Marshal(&expiringResponse{
+		NumberOfRecords: len(records),
+		Records:         records,
+	})
+	if err != nil {
+		logger.Errorf("%d GET could not json.Marshal expiringResponse", http.StatusInternalServerError)
+		return errors.ErrUnknown.WithDetail(err)
+	}
+	w.Write(out)
+	return nil
+}
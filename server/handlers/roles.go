@@ -76,7 +76,8 @@ func getMaybeServerSigned(ctx context.Context, store storage.MetaStore, gun data
 	if role != data.CanonicalTimestampRole && role != data.CanonicalSnapshotRole {
 		return nil, nil, fmt.Errorf("role %s cannot be server signed", role.String())
 	}
-	lastModified, out, err = timestamp.GetOrCreateTimestamp(gun, store, cryptoService)
+	expiryPolicy, _ := ctx.Value(notary.CtxKeyExpiryPolicy).(*data.ExpiryPolicy)
+	lastModified, out, err = timestamp.GetOrCreateTimestampWithPolicy(gun, store, cryptoService, expiryPolicy)
 	if err != nil {
 		switch err.(type) {
 		case *storage.ErrNoKey, storage.ErrNotFound:
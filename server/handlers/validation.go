@@ -23,6 +23,13 @@ import (
 // created and added if snapshotting has been delegated to the
 // server
 func validateUpdate(cs signed.CryptoService, gun data.GUN, updates []storage.MetaUpdate, store storage.MetaStore) ([]storage.MetaUpdate, error) {
+	return validateUpdateWithPolicy(cs, gun, updates, store, nil)
+}
+
+// validateUpdateWithPolicy is validateUpdate, but generated snapshots and
+// timestamps expire according to expiryPolicy
+func validateUpdateWithPolicy(cs signed.CryptoService, gun data.GUN, updates []storage.MetaUpdate, store storage.MetaStore,
+	expiryPolicy *data.ExpiryPolicy) ([]storage.MetaUpdate, error) {
 
 	// some delegated targets role may be invalid based on other updates
 	// that have been made by other clients. We'll rebuild the slice of
@@ -34,7 +41,7 @@ func validateUpdate(cs signed.CryptoService, gun data.GUN, updates []storage.Met
 		roles[v.Role] = v
 	}
 
-	builder := tuf.NewRepoBuilder(gun, cs, trustpinning.TrustPinConfig{})
+	builder := tuf.NewRepoBuilderWithExpiryPolicy(gun, cs, trustpinning.TrustPinConfig{}, expiryPolicy)
 	if err := loadFromStore(gun, data.CanonicalRootRole, builder, store); err != nil {
 		if _, ok := err.(storage.ErrNotFound); !ok {
 			return nil, err
@@ -0,0 +1,71 @@
+package replication
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/server/timestamp"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// noKeys is used to check whether the current timestamp can be served
+// without signing a new one
+var noKeys = cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphrase.ConstantRetriever("")))
+
+// currentServerSignedPath matches requests for the current timestamp or
+// snapshot, which the primary may need to re-sign before they can be served
+var currentServerSignedPath = regexp.MustCompile(`^/v2/(.+)/_trust/tuf/(?:timestamp|snapshot)\.json$`)
+
+// Handler returns a handler which forwards every request that a replica
+// cannot serve to the primary, and passes the rest to next.  The primary
+// serves all writes, any request for a timestamp or snapshot key (which
+// may require a key to be created), and requests for the current timestamp
+// or snapshot when the local copy has expired, since only the primary can
+// sign new ones.  Anything the primary writes is then copied back by the
+// replicator.  Requests are forwarded with their credentials, so the primary
+// authorizes them exactly as if they had been made to it directly.
+func (r *Replicator) Handler(next http.Handler) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(r.primary)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = r.primary.Host
+	}
+	proxy.Transport = r.client.Transport
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.forward(req) {
+			logrus.Debugf("replication: forwarding %s %s to %s", req.Method, req.URL.Path, r.primary)
+			proxy.ServeHTTP(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// forward returns whether a request must be served by the primary
+func (r *Replicator) forward(req *http.Request) bool {
+	if !strings.HasPrefix(req.URL.Path, "/v2/") {
+		return false
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return true
+	}
+	if strings.HasSuffix(req.URL.Path, ".key") {
+		return true
+	}
+	if m := currentServerSignedPath.FindStringSubmatch(req.URL.Path); m != nil {
+		// The replica holds no signing keys, so this fails exactly when the
+		// current timestamp or snapshot would need to be re-signed
+		_, _, err := timestamp.GetOrCreateTimestamp(data.GUN(m[1]), r.store, noKeys)
+		return err != nil
+	}
+	return false
+}
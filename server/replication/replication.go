@@ -0,0 +1,466 @@
+// Package replication keeps a read-only notary server's MetaStore in sync
+// with a primary notary server, by following the primary's changefeed.
+package replication
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/server/webhook"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+const (
+	// CursorName is the name under which the ID of the last change copied
+	// from the primary is kept in the CursorStore
+	CursorName = "replication"
+
+	changeCategoryUpdate   = "update"
+	changeCategoryDeletion = "deletion"
+
+	defaultWait          = 30 * time.Second
+	defaultRetryInterval = 5 * time.Second
+)
+
+var (
+	lagSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "notary_server",
+		Subsystem: "replication",
+		Name:      "lag_seconds",
+		Help:      "Age in seconds of the oldest change on the primary which has not yet been copied, or 0 when up to date.",
+	})
+	changesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "notary_server",
+		Subsystem: "replication",
+		Name:      "changes_total",
+		Help:      "Number of changes read from the primary's changefeed, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(lagSeconds)
+	prometheus.MustRegister(changesTotal)
+}
+
+type changefeedResponse struct {
+	Records []storage.Change `json:"records"`
+}
+
+// Replicator copies every version of the TUF metadata on a primary notary
+// server into a local MetaStore, in changefeed order.  All metadata is
+// verified in the same way the primary verified it before it is stored, so
+// a replica never serves metadata that the primary would not have accepted.
+type Replicator struct {
+	primary *url.URL
+	client  *http.Client
+	store   storage.MetaStore
+	cursors webhook.CursorStore
+
+	// Wait is how long each request to the primary's changefeed is held
+	// open waiting for new changes
+	Wait time.Duration
+	// RetryInterval is how long to wait before retrying after a failure
+	// to read or apply a change
+	RetryInterval time.Duration
+}
+
+// NewReplicator returns a Replicator which copies metadata from the notary
+// server at the primary URL into store, persisting its position in the
+// primary's changefeed to cursors.  If client is nil, http.DefaultClient is
+// used.
+func NewReplicator(primary string, client *http.Client, store storage.MetaStore, cursors webhook.CursorStore) (*Replicator, error) {
+	u, err := url.Parse(primary)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("replication requires an absolute primary URL, got %q", primary)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Replicator{
+		primary:       u,
+		client:        client,
+		store:         store,
+		cursors:       cursors,
+		Wait:          defaultWait,
+		RetryInterval: defaultRetryInterval,
+	}, nil
+}
+
+// Primary returns the URL of the primary server
+func (r *Replicator) Primary() *url.URL {
+	return r.primary
+}
+
+// Run copies changes from the primary until the context is cancelled
+func (r *Replicator) Run(ctx context.Context) {
+	for {
+		if err := r.Sync(ctx, r.Wait); err != nil {
+			logrus.Errorf("replication from %s: %v", r.primary, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.RetryInterval):
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
+// Sync copies all outstanding changes from the primary, and returns once it
+// has caught up.  If there are no outstanding changes, it first waits up to
+// wait for one to be made.  Changes are applied in order, and the cursor only
+// advances once a change has been applied, so Sync resumes from the first
+// change that failed.
+func (r *Replicator) Sync(ctx context.Context, wait time.Duration) error {
+	cursor, err := r.cursors.GetCursor(CursorName)
+	if _, ok := err.(webhook.ErrNoCursor); ok {
+		// a new replica copies everything the primary has
+		cursor = "0"
+	} else if err != nil {
+		return err
+	}
+
+	for {
+		changes, err := r.getChanges(ctx, cursor, wait)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			lagSeconds.Set(0)
+			return nil
+		}
+		lagSeconds.Set(time.Since(changes[0].CreatedAt).Seconds())
+		for _, change := range changes {
+			if err := r.apply(ctx, change); err != nil {
+				changesTotal.WithLabelValues("failed").Inc()
+				return fmt.Errorf("unable to apply change %s to %s: %v", change.ID, change.GUN, err)
+			}
+			changesTotal.WithLabelValues("applied").Inc()
+			cursor = change.ID
+			if err := r.cursors.SetCursor(CursorName, cursor); err != nil {
+				return err
+			}
+		}
+		// only the first request waits, the rest drain the backlog
+		wait = 0
+	}
+}
+
+func (r *Replicator) getChanges(ctx context.Context, cursor string, wait time.Duration) ([]storage.Change, error) {
+	query := url.Values{}
+	query.Set("change_id", cursor)
+	query.Set("records", strconv.Itoa(notary.DefaultPageSize))
+	if wait > 0 {
+		query.Set("wait", strconv.Itoa(int(wait/time.Second)))
+	}
+	out, err := r.get(ctx, "/v2/_trust/changefeed", query)
+	if err != nil {
+		return nil, err
+	}
+	var resp changefeedResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("invalid changefeed response from %s: %v", r.primary, err)
+	}
+	return resp.Records, nil
+}
+
+// get returns the body of a GET request to the primary, or storage.ErrNotFound
+// if the primary responds with a 404
+func (r *Replicator) get(ctx context.Context, p string, query url.Values) ([]byte, error) {
+	u := *r.primary
+	u.Path = path.Join(u.Path, p)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, storage.ErrNotFound{}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s responded with %d", u.String(), resp.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, notary.MaxDownloadSize))
+}
+
+func (r *Replicator) apply(ctx context.Context, change storage.Change) error {
+	gun := data.GUN(change.GUN)
+	switch change.Category {
+	case changeCategoryDeletion:
+		return r.store.Delete(gun)
+	case changeCategoryUpdate:
+		return r.applyUpdate(ctx, gun, change)
+	default:
+		logrus.Warnf("replication: ignoring change %s with unknown category %s", change.ID, change.Category)
+		return nil
+	}
+}
+
+// applyUpdate copies the timestamp a change refers to, and any other
+// metadata it requires that is not already stored locally
+func (r *Replicator) applyUpdate(ctx context.Context, gun data.GUN, change storage.Change) error {
+	_, _, err := r.store.GetChecksum(gun, data.CanonicalTimestampRole, change.SHA256)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(storage.ErrNotFound); !ok {
+		return err
+	}
+
+	tsJSON, err := r.fetchChecksum(ctx, gun, data.CanonicalTimestampRole, change.SHA256)
+	if _, ok := err.(storage.ErrNotFound); ok {
+		// the GUN has since been deleted on the primary, and a later change
+		// will delete it here too
+		logrus.Infof("replication: timestamp %s for %s is no longer on the primary, skipping", change.SHA256, gun)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ts := &data.SignedTimestamp{}
+	if err := json.Unmarshal(tsJSON, ts); err != nil {
+		return err
+	}
+	if ts.Signed.Version != change.Version {
+		return fmt.Errorf("timestamp %s has version %d, but the change is for version %d",
+			change.SHA256, ts.Signed.Version, change.Version)
+	}
+
+	snapshotMeta, err := ts.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	snapshotChecksum, err := sha256Hex(*snapshotMeta, data.CanonicalSnapshotRole)
+	if err != nil {
+		return err
+	}
+	snapshotJSON, snapshotFetched, err := r.getChecksum(ctx, gun, data.CanonicalSnapshotRole, snapshotChecksum)
+	if err != nil {
+		return err
+	}
+	sn := &data.SignedSnapshot{}
+	if err := json.Unmarshal(snapshotJSON, sn); err != nil {
+		return err
+	}
+
+	rootMeta, ok := sn.Signed.Meta[data.CanonicalRootRole.String()]
+	if !ok {
+		return data.ErrMissingMeta{Role: data.CanonicalRootRole.String()}
+	}
+	rootChecksum, err := sha256Hex(rootMeta, data.CanonicalRootRole)
+	if err != nil {
+		return err
+	}
+	rootJSON, rootFetched, err := r.getChecksum(ctx, gun, data.CanonicalRootRole, rootChecksum)
+	if err != nil {
+		return err
+	}
+	builder, updates, err := r.loadRoot(ctx, gun, rootJSON, rootFetched)
+	if err != nil {
+		return err
+	}
+
+	if err := builder.Load(data.CanonicalTimestampRole, tsJSON, 1, true); err != nil {
+		return err
+	}
+	if err := builder.Load(data.CanonicalSnapshotRole, snapshotJSON, 1, true); err != nil {
+		return err
+	}
+
+	// targets are loaded parents first, so that each delegation can be
+	// verified against the keys its parent delegates to it
+	var targetRoles []string
+	for role := range sn.Signed.Meta {
+		if data.IsDelegation(data.RoleName(role)) || data.RoleName(role) == data.CanonicalTargetsRole {
+			targetRoles = append(targetRoles, role)
+		}
+	}
+	sort.Slice(targetRoles, func(i, j int) bool {
+		di, dj := strings.Count(targetRoles[i], "/"), strings.Count(targetRoles[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return targetRoles[i] < targetRoles[j]
+	})
+	for _, name := range targetRoles {
+		role := data.RoleName(name)
+		checksum, err := sha256Hex(sn.Signed.Meta[name], role)
+		if err != nil {
+			return err
+		}
+		raw, fetched, err := r.getChecksum(ctx, gun, role, checksum)
+		if err != nil {
+			return err
+		}
+		if err := builder.Load(role, raw, 1, true); err != nil {
+			return err
+		}
+		if fetched {
+			if updates, err = appendUpdate(updates, role, raw); err != nil {
+				return err
+			}
+		}
+	}
+
+	if snapshotFetched {
+		if updates, err = appendUpdate(updates, data.CanonicalSnapshotRole, snapshotJSON); err != nil {
+			return err
+		}
+	}
+	updates = append(updates, storage.MetaUpdate{
+		Role:    data.CanonicalTimestampRole,
+		Version: ts.Signed.Version,
+		Data:    tsJSON,
+	})
+	return r.store.UpdateMany(gun, updates)
+}
+
+// loadRoot returns a builder with the given root loaded, verified against the
+// current local root.  If the root has been rotated more than once since the
+// current local root, every intermediate version is fetched from the primary
+// and verified in turn, and returned as updates along with the new root.
+func (r *Replicator) loadRoot(ctx context.Context, gun data.GUN, rootJSON []byte, fetched bool) (tuf.RepoBuilder, []storage.MetaUpdate, error) {
+	var updates []storage.MetaUpdate
+	builder := tuf.NewRepoBuilder(gun, cryptoservice.EmptyService, trustpinning.TrustPinConfig{})
+
+	newVersion, err := metaVersion(rootJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+	currentVersion := 0
+	_, current, err := r.store.GetCurrent(gun, data.CanonicalRootRole)
+	switch err.(type) {
+	case nil:
+		if currentVersion, err = metaVersion(current); err != nil {
+			return nil, nil, err
+		}
+		if err := builder.Load(data.CanonicalRootRole, current, 1, true); err != nil {
+			return nil, nil, err
+		}
+	case storage.ErrNotFound:
+	default:
+		return nil, nil, err
+	}
+
+	switch {
+	case newVersion < currentVersion:
+		return nil, nil, fmt.Errorf("root version %d is older than the current version %d", newVersion, currentVersion)
+	case newVersion == currentVersion:
+		if !bytes.Equal(rootJSON, current) {
+			return nil, nil, fmt.Errorf("root version %d differs from the current root of the same version", newVersion)
+		}
+		return builder, nil, nil
+	case !fetched:
+		return nil, nil, fmt.Errorf("root version %d is stored, but is newer than the current version %d", newVersion, currentVersion)
+	}
+
+	for version := currentVersion + 1; version <= newVersion; version++ {
+		raw := rootJSON
+		if version < newVersion {
+			raw, err = r.get(ctx, fmt.Sprintf("/v2/%s/_trust/tuf/%d.root.json", gun, version), nil)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to fetch root version %d: %v", version, err)
+			}
+		}
+		if builder.IsLoaded(data.CanonicalRootRole) {
+			builder = builder.BootstrapNewBuilder()
+		}
+		if err := builder.Load(data.CanonicalRootRole, raw, version, true); err != nil {
+			return nil, nil, err
+		}
+		if loaded := builder.GetLoadedVersion(data.CanonicalRootRole); loaded != version {
+			return nil, nil, fmt.Errorf("expected root version %d, got version %d", version, loaded)
+		}
+		updates = append(updates, storage.MetaUpdate{Role: data.CanonicalRootRole, Version: version, Data: raw})
+	}
+	return builder, updates, nil
+}
+
+// getChecksum returns the metadata with the given checksum from the local
+// store if it is there, and otherwise from the primary.  The returned bool is
+// true if it was fetched from the primary.
+func (r *Replicator) getChecksum(ctx context.Context, gun data.GUN, role data.RoleName, checksum string) ([]byte, bool, error) {
+	_, out, err := r.store.GetChecksum(gun, role, checksum)
+	if err == nil {
+		return out, false, nil
+	}
+	if _, ok := err.(storage.ErrNotFound); !ok {
+		return nil, false, err
+	}
+	out, err = r.fetchChecksum(ctx, gun, role, checksum)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// fetchChecksum fetches metadata from the primary by its checksum, and
+// verifies that the checksum matches
+func (r *Replicator) fetchChecksum(ctx context.Context, gun data.GUN, role data.RoleName, checksum string) ([]byte, error) {
+	out, err := r.get(ctx, fmt.Sprintf("/v2/%s/_trust/tuf/%s.%s.json", gun, role, checksum), nil)
+	if err != nil {
+		return nil, err
+	}
+	actual := sha256.Sum256(out)
+	if hex.EncodeToString(actual[:]) != checksum {
+		return nil, fmt.Errorf("%s %s from the primary does not match its checksum", role, checksum)
+	}
+	return out, nil
+}
+
+func appendUpdate(updates []storage.MetaUpdate, role data.RoleName, raw []byte) ([]storage.MetaUpdate, error) {
+	version, err := metaVersion(raw)
+	if err != nil {
+		return nil, err
+	}
+	return append(updates, storage.MetaUpdate{Role: role, Version: version, Data: raw}), nil
+}
+
+func metaVersion(raw []byte) (int, error) {
+	meta := data.SignedMeta{}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return 0, err
+	}
+	return meta.Signed.Version, nil
+}
+
+func sha256Hex(meta data.FileMeta, role data.RoleName) (string, error) {
+	checksum, ok := meta.Hashes[notary.SHA256]
+	if !ok {
+		return "", data.ErrMissingMeta{Role: role.String()}
+	}
+	return hex.EncodeToString(checksum), nil
+}
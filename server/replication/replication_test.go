@@ -0,0 +1,249 @@
+package replication_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/server"
+	"github.com/theupdateframework/notary/server/replication"
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/server/webhook"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"github.com/theupdateframework/notary/tuf/testutils"
+	"golang.org/x/net/context"
+)
+
+// newPrimary returns a test notary server backed by a MemStorage, which signs
+// timestamps and snapshots with cs
+func newPrimary(t *testing.T, cs signed.CryptoService) (*httptest.Server, storage.MetaStore) {
+	s := storage.NewMemStorage()
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, s)
+	ctx = context.WithValue(ctx, notary.CtxKeyKeyAlgo, data.ED25519Key)
+	return httptest.NewServer(server.RootHandler(ctx, nil, cs, nil, nil, nil)), s
+}
+
+// newReplica returns a replicator from the primary, and the replica's store
+func newReplica(t *testing.T, primary *httptest.Server) (*replication.Replicator, storage.MetaStore, webhook.CursorStore) {
+	s := storage.NewMemStorage()
+	cursors := webhook.NewMemoryCursorStore()
+	r, err := replication.NewReplicator(primary.URL, nil, s, cursors)
+	require.NoError(t, err)
+	return r, s, cursors
+}
+
+func updatesFor(meta map[data.RoleName][]byte, version int) []storage.MetaUpdate {
+	var updates []storage.MetaUpdate
+	for role, d := range meta {
+		updates = append(updates, storage.MetaUpdate{Role: role, Version: version, Data: d})
+	}
+	return updates
+}
+
+func requireReplicated(t *testing.T, gun data.GUN, primary, replica storage.MetaStore, roles ...data.RoleName) {
+	for _, role := range roles {
+		_, expected, err := primary.GetCurrent(gun, role)
+		require.NoError(t, err)
+		_, actual, err := replica.GetCurrent(gun, role)
+		require.NoError(t, err, "%s was not replicated", role)
+		require.Equal(t, expected, actual, "%s was not replicated", role)
+	}
+}
+
+func TestNewReplicatorInvalidPrimary(t *testing.T) {
+	for _, primary := range []string{"", "notary.example.com", "/v2/", "://"} {
+		_, err := replication.NewReplicator(primary, nil, storage.NewMemStorage(), webhook.NewMemoryCursorStore())
+		require.Error(t, err, primary)
+	}
+}
+
+func TestReplicateUpdatesAndDeletions(t *testing.T) {
+	gun := data.GUN("docker.com/notary")
+	delegation := data.RoleName("targets/a")
+	repo, cs, err := testutils.EmptyRepo(gun, delegation)
+	require.NoError(t, err)
+	_, err = repo.InitTargets(delegation)
+	require.NoError(t, err)
+	primary, primaryStore := newPrimary(t, cs)
+	defer primary.Close()
+	r, replicaStore, cursors := newReplica(t, primary)
+
+	// nothing to replicate yet
+	require.NoError(t, r.Sync(context.Background(), 0))
+	_, err = cursors.GetCursor(replication.CursorName)
+	require.Error(t, err)
+
+	meta, err := testutils.SignAndSerialize(repo)
+	require.NoError(t, err)
+	require.NoError(t, primaryStore.UpdateMany(gun, updatesFor(meta, 1)))
+
+	require.NoError(t, r.Sync(context.Background(), 0))
+	requireReplicated(t, gun, primaryStore, replicaStore, data.CanonicalRootRole, data.CanonicalTargetsRole,
+		delegation, data.CanonicalSnapshotRole, data.CanonicalTimestampRole)
+	changes, err := primaryStore.GetChanges("0", 10, "")
+	require.NoError(t, err)
+	cursor, err := cursors.GetCursor(replication.CursorName)
+	require.NoError(t, err)
+	require.Equal(t, changes[len(changes)-1].ID, cursor)
+	// the replica records the change in its own changefeed
+	replicaChanges, err := replicaStore.GetChanges("0", 10, "")
+	require.NoError(t, err)
+	require.Len(t, replicaChanges, 1)
+
+	// rotate the root key, and publish two root versions at once so that
+	// the replica has to fetch the intermediate version to verify the chain
+	newRootKey, err := testutils.CreateKey(cs, gun, data.CanonicalRootRole, data.ECDSAKey)
+	require.NoError(t, err)
+	require.NoError(t, repo.ReplaceBaseKeys(data.CanonicalRootRole, newRootKey))
+	meta2, err := testutils.SignAndSerialize(repo)
+	require.NoError(t, err)
+	meta3, err := testutils.SignAndSerialize(repo)
+	require.NoError(t, err)
+	require.NoError(t, primaryStore.UpdateMany(gun, append(
+		[]storage.MetaUpdate{{Role: data.CanonicalRootRole, Version: 2, Data: meta2[data.CanonicalRootRole]}},
+		updatesFor(meta3, 3)...)))
+
+	require.NoError(t, r.Sync(context.Background(), 0))
+	requireReplicated(t, gun, primaryStore, replicaStore, data.CanonicalRootRole, data.CanonicalTargetsRole,
+		delegation, data.CanonicalSnapshotRole, data.CanonicalTimestampRole)
+	_, root2, err := replicaStore.GetVersion(gun, data.CanonicalRootRole, 2)
+	require.NoError(t, err)
+	require.Equal(t, meta2[data.CanonicalRootRole], root2)
+
+	require.NoError(t, primaryStore.Delete(gun))
+	require.NoError(t, r.Sync(context.Background(), 0))
+	_, _, err = replicaStore.GetCurrent(gun, data.CanonicalRootRole)
+	require.IsType(t, storage.ErrNotFound{}, err)
+}
+
+// A replica that starts after a GUN has been deleted skips the changes made
+// before the deletion, since the primary no longer has their metadata
+func TestReplicateSkipsDeletedGUN(t *testing.T) {
+	gun := data.GUN("docker.com/notary")
+	meta, cs, err := testutils.NewRepoMetadata(gun)
+	require.NoError(t, err)
+	primary, primaryStore := newPrimary(t, cs)
+	defer primary.Close()
+	r, replicaStore, _ := newReplica(t, primary)
+
+	require.NoError(t, primaryStore.UpdateMany(gun, updatesFor(meta, 1)))
+	require.NoError(t, primaryStore.Delete(gun))
+
+	require.NoError(t, r.Sync(context.Background(), 0))
+	_, _, err = replicaStore.GetCurrent(gun, data.CanonicalRootRole)
+	require.IsType(t, storage.ErrNotFound{}, err)
+}
+
+// Metadata which the primary would not have accepted is never replicated
+func TestReplicateRejectsInvalidMetadata(t *testing.T) {
+	gun := data.GUN("docker.com/notary")
+	meta, cs, err := testutils.NewRepoMetadata(gun)
+	require.NoError(t, err)
+
+	swizzler := testutils.NewMetadataSwizzler(gun, meta, cs)
+	require.NoError(t, swizzler.InvalidateMetadataSignatures(data.CanonicalTargetsRole))
+	require.NoError(t, swizzler.UpdateSnapshotHashes())
+	require.NoError(t, swizzler.UpdateTimestampHash())
+	invalid := make(map[data.RoleName][]byte)
+	for role := range meta {
+		invalid[role], err = swizzler.MetadataCache.GetSized(role.String(), -1)
+		require.NoError(t, err)
+	}
+
+	primary, primaryStore := newPrimary(t, cs)
+	defer primary.Close()
+	r, replicaStore, cursors := newReplica(t, primary)
+
+	require.NoError(t, primaryStore.UpdateMany(gun, updatesFor(invalid, 1)))
+	require.Error(t, r.Sync(context.Background(), 0))
+	for role := range meta {
+		_, _, err = replicaStore.GetCurrent(gun, role)
+		require.IsType(t, storage.ErrNotFound{}, err, "%s was replicated", role)
+	}
+	_, err = cursors.GetCursor(replication.CursorName)
+	require.Error(t, err)
+}
+
+func TestHandlerForwardsToPrimary(t *testing.T) {
+	gun := data.GUN("docker.com/notary")
+	meta, cs, err := testutils.NewRepoMetadata(gun)
+	require.NoError(t, err)
+	primary, primaryStore := newPrimary(t, cs)
+	defer primary.Close()
+	r, replicaStore, _ := newReplica(t, primary)
+
+	// the replica holds no signing keys
+	noKeys := cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphrase.ConstantRetriever("")))
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, replicaStore)
+	replica := httptest.NewServer(r.Handler(server.RootHandler(ctx, nil, noKeys, nil, nil, nil)))
+	defer replica.Close()
+
+	require.NoError(t, primaryStore.UpdateMany(gun, updatesFor(meta, 1)))
+	require.NoError(t, r.Sync(context.Background(), 0))
+
+	get := func(p string) (int, []byte) {
+		resp, err := http.Get(replica.URL + p)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, body
+	}
+
+	// reads are served locally, and keys come from the primary
+	status, body := get("/v2/docker.com/notary/_trust/tuf/targets.json")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, meta[data.CanonicalTargetsRole], body)
+	status, body = get("/v2/docker.com/notary/_trust/tuf/timestamp.json")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, meta[data.CanonicalTimestampRole], body)
+	status, _ = get("/v2/docker.com/notary/_trust/tuf/timestamp.key")
+	require.Equal(t, http.StatusOK, status)
+
+	// an expired timestamp is re-signed by the primary, and then replicated
+	swizzler := testutils.NewMetadataSwizzler(gun, meta, cs)
+	require.NoError(t, swizzler.OffsetMetadataVersion(data.CanonicalTimestampRole, 1))
+	require.NoError(t, swizzler.ExpireMetadata(data.CanonicalTimestampRole))
+	expired, err := swizzler.MetadataCache.GetSized(data.CanonicalTimestampRole.String(), -1)
+	require.NoError(t, err)
+	update := []storage.MetaUpdate{{Role: data.CanonicalTimestampRole, Version: 2, Data: expired}}
+	require.NoError(t, primaryStore.UpdateMany(gun, update))
+	require.NoError(t, replicaStore.UpdateMany(gun, update))
+
+	status, body = get("/v2/docker.com/notary/_trust/tuf/timestamp.json")
+	require.Equal(t, http.StatusOK, status)
+	ts := &data.SignedTimestamp{}
+	require.NoError(t, json.Unmarshal(body, ts))
+	require.Equal(t, 3, ts.Signed.Version)
+	require.False(t, signed.IsExpired(ts.Signed.Expires))
+
+	require.NoError(t, r.Sync(context.Background(), 0))
+	_, current, err := replicaStore.GetCurrent(gun, data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.Equal(t, body, current)
+
+	// writes go to the primary
+	req, err := http.NewRequest("DELETE", replica.URL+"/v2/docker.com/notary/_trust/tuf/", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_, _, err = primaryStore.GetCurrent(gun, data.CanonicalRootRole)
+	require.IsType(t, storage.ErrNotFound{}, err)
+	_, _, err = replicaStore.GetCurrent(gun, data.CanonicalRootRole)
+	require.NoError(t, err)
+
+	status, body = get("/v2/docker.com/notary/_trust/tuf/root.json")
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, bytes.Equal(meta[data.CanonicalRootRole], body))
+}
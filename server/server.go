@@ -14,7 +14,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary/server/errors"
+	"github.com/theupdateframework/notary/server/expiry"
 	"github.com/theupdateframework/notary/server/handlers"
+	"github.com/theupdateframework/notary/server/replication"
+	"github.com/theupdateframework/notary/server/webhook"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
 	"github.com/theupdateframework/notary/utils"
@@ -43,6 +46,16 @@ type Config struct {
 	RepoPrefixes                 []string
 	ConsistentCacheControlConfig utils.CacheControlConfig
 	CurrentCacheControlConfig    utils.CacheControlConfig
+	// Webhooks, if set, is run alongside the server to deliver changefeed
+	// events to subscribed endpoints
+	Webhooks *webhook.Dispatcher
+	// Replication, if set, makes this server a read-only replica of the
+	// replicator's primary: metadata is copied from the primary, and
+	// requests which the replica cannot serve are forwarded to it
+	Replication *replication.Replicator
+	// ExpiryMonitor, if set, is run alongside the server to export
+	// histograms of when the stored metadata expires
+	ExpiryMonitor *expiry.Monitor
 }
 
 // Run sets up and starts a TLS server that can be cancelled using the
@@ -76,12 +89,32 @@ func Run(ctx context.Context, conf Config) error {
 		}
 	}
 
+	handler := RootHandler(
+		ctx, ac, conf.Trust,
+		conf.ConsistentCacheControlConfig, conf.CurrentCacheControlConfig,
+		conf.RepoPrefixes)
+	if conf.Replication != nil {
+		logrus.Info("Replicating from ", conf.Replication.Primary())
+		handler = conf.Replication.Handler(handler)
+		go conf.Replication.Run(ctx)
+	}
+
 	svr := http.Server{
-		Addr: conf.Addr,
-		Handler: RootHandler(
-			ctx, ac, conf.Trust,
-			conf.ConsistentCacheControlConfig, conf.CurrentCacheControlConfig,
-			conf.RepoPrefixes),
+		Addr:    conf.Addr,
+		Handler: handler,
+	}
+
+	if conf.Webhooks != nil {
+		logrus.Info("Starting webhook dispatcher")
+		go conf.Webhooks.Run(ctx)
+	}
+
+	if conf.ExpiryMonitor != nil {
+		logrus.Info("Starting expiry monitor")
+		if err := prometheus.Register(conf.ExpiryMonitor); err != nil {
+			return err
+		}
+		go conf.ExpiryMonitor.Run(ctx)
 	}
 
 	logrus.Info("Starting on ", conf.Addr)
@@ -231,6 +264,26 @@ func RootHandler(ctx context.Context, ac auth.AccessController, trust signed.Cry
 		authWrapper,
 		repoPrefixes,
 	))
+	r.Methods("GET").Path("/v2/_trust/catalog").Handler(CreateHandler(
+		"Catalog",
+		handlers.Catalog,
+		notFoundError,
+		false,
+		nil,
+		[]string{"pull"},
+		authWrapper,
+		repoPrefixes,
+	))
+	r.Methods("GET").Path("/_notary_server/expiring").Handler(CreateHandler(
+		"Expiring",
+		handlers.Expiring,
+		notFoundError,
+		false,
+		nil,
+		[]string{"*"},
+		authWrapper,
+		repoPrefixes,
+	))
 	r.Methods("GET").Path("/_notary_server/health").HandlerFunc(health.StatusHandler)
 	r.Methods("GET").Path("/metrics").Handler(prometheus.Handler())
 	r.Methods("GET", "POST", "PUT", "HEAD", "DELETE").Path("/{other:.*}").Handler(
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
@@ -13,6 +14,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/docker/distribution/registry/auth"
 	_ "github.com/docker/distribution/registry/auth/silly"
 	"github.com/stretchr/testify/require"
 	"github.com/theupdateframework/notary"
@@ -159,6 +161,119 @@ func TestMetricsEndpoint(t *testing.T) {
 	require.Equal(t, http.StatusOK, res.StatusCode)
 }
 
+// The expiring metadata report requires admin access
+func TestExpiringEndpoint(t *testing.T) {
+	store := storage.NewMemStorage()
+	metadata, _, err := testutils.NewRepoMetadata("gun")
+	require.NoError(t, err)
+	for _, role := range data.BaseRoles {
+		require.NoError(t, store.UpdateCurrent("gun", storage.MetaUpdate{Role: role, Version: 1, Data: metadata[role]}))
+	}
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, store)
+
+	ac, err := auth.GetAccessController("silly", map[string]interface{}{"realm": "notary", "service": "notary"})
+	require.NoError(t, err)
+	ts := httptest.NewServer(RootHandler(ctx, ac, signed.NewEd25519(), nil, nil, nil))
+	defer ts.Close()
+
+	get := func(query string, authorized bool) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL+"/_notary_server/expiring"+query, nil)
+		require.NoError(t, err)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer admin")
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	res := get("", false)
+	res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	for _, invalid := range []string{"?within=soon", "?within=-1h", "?role=nope"} {
+		res = get(invalid, true)
+		res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, invalid)
+	}
+
+	var report struct {
+		Count   int `json:"count"`
+		Records []struct {
+			GUN     string        `json:"gun"`
+			Role    data.RoleName `json:"role"`
+			Version int           `json:"version"`
+		} `json:"records"`
+	}
+	res = get("?within=87600h", true)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&report))
+	res.Body.Close()
+	require.Equal(t, len(data.BaseRoles), report.Count)
+	// timestamp expires soonest
+	require.Equal(t, data.CanonicalTimestampRole, report.Records[0].Role)
+	require.Equal(t, "gun", report.Records[0].GUN)
+
+	res = get("?within=87600h&role=root&role=targets", true)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&report))
+	res.Body.Close()
+	require.Equal(t, 2, report.Count)
+
+	res = get("?within=0s", true)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&report))
+	res.Body.Close()
+	require.Equal(t, 0, report.Count)
+	require.Empty(t, report.Records)
+}
+
+func TestCatalogEndpoint(t *testing.T) {
+	store := storage.NewMemStorage()
+	for _, gun := range []data.GUN{"docker.io/a", "docker.io/b", "quay.io/a"} {
+		require.NoError(t, store.UpdateCurrent(gun, storage.MetaUpdate{Role: data.CanonicalRootRole, Version: 1, Data: []byte("{}")}))
+	}
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, store)
+
+	ac, err := auth.GetAccessController("silly", map[string]interface{}{"realm": "notary", "service": "notary"})
+	require.NoError(t, err)
+	ts := httptest.NewServer(RootHandler(ctx, ac, signed.NewEd25519(), nil, nil, nil))
+	defer ts.Close()
+
+	get := func(path string, authorized bool) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		require.NoError(t, err)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer user")
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	res := get("/v2/_trust/catalog", false)
+	res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	var catalog struct {
+		Repositories []string `json:"repositories"`
+	}
+	res = get("/v2/_trust/catalog?n=1&prefix=docker.io/", true)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&catalog))
+	res.Body.Close()
+	require.Equal(t, []string{"docker.io/a"}, catalog.Repositories)
+
+	// follow the link to the next page
+	link := res.Header.Get("Link")
+	require.True(t, strings.HasPrefix(link, "<") && strings.HasSuffix(link, `>; rel="next"`), link)
+	res = get(link[1:strings.Index(link, ">")], true)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&catalog))
+	res.Body.Close()
+	require.Equal(t, []string{"docker.io/b"}, catalog.Repositories)
+}
+
 // GetKeys supports only the timestamp and snapshot key endpoints
 func TestGetKeysEndpoint(t *testing.T) {
 	ctx := context.WithValue(
@@ -400,3 +515,84 @@ func TestRotateKeyEndpoint(t *testing.T) {
 		require.Equal(t, expectedStatus, res.StatusCode)
 	}
 }
+
+// readEvent reads the next server-sent event, skipping comments
+func readEvent(t *testing.T, r *bufio.Reader) (id string, change storage.Change) {
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && id != "":
+			return
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &change))
+		}
+	}
+}
+
+func streamChangefeed(t *testing.T, url, lastEventID string) (*http.Response, *bufio.Reader) {
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return res, bufio.NewReader(res.Body)
+}
+
+func TestChangefeedStream(t *testing.T) {
+	metaStore := storage.NewMemStorage()
+	ctx := context.WithValue(context.Background(), notary.CtxKeyMetaStore, metaStore)
+	handler := RootHandler(ctx, nil, signed.NewEd25519(), nil, nil, nil)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	publish := func(gun data.GUN, version int) {
+		require.NoError(t, metaStore.UpdateCurrent(gun, storage.MetaUpdate{
+			Role:    data.CanonicalTimestampRole,
+			Version: version,
+			Data:    []byte{byte(version)},
+		}))
+	}
+	publish("alpine", 1)
+	publish("busybox", 1)
+
+	// an invalid change ID is reported before the stream starts
+	res, _ := streamChangefeed(t, ts.URL+"/v2/_trust/changefeed?change_id=nope", "")
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// existing changes are sent first, then new ones as they are committed
+	res, events := streamChangefeed(t, ts.URL+"/v2/_trust/changefeed?change_id=0", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	id, change := readEvent(t, events)
+	require.Equal(t, "1", id)
+	require.Equal(t, "alpine", change.GUN)
+	id, _ = readEvent(t, events)
+	require.Equal(t, "2", id)
+
+	publish("alpine", 2)
+	id, change = readEvent(t, events)
+	require.Equal(t, "3", id)
+	require.Equal(t, "alpine", change.GUN)
+	require.Equal(t, 2, change.Version)
+	res.Body.Close()
+
+	// a reconnecting client resumes after its Last-Event-ID, and the GUN
+	// filter applies to the stream
+	publish("busybox", 2)
+	publish("alpine", 3)
+	res, events = streamChangefeed(t, ts.URL+"/v2/alpine/_trust/changefeed", "1")
+	defer res.Body.Close()
+	id, _ = readEvent(t, events)
+	require.Equal(t, "3", id)
+	id, change = readEvent(t, events)
+	require.Equal(t, "5", id)
+	require.Equal(t, 3, change.Version)
+}
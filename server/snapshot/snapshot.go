@@ -68,6 +68,13 @@ func RotateSnapshotKey(gun data.GUN, store storage.MetaStore, crypto signed.Cryp
 // or nil as the time modified
 func GetOrCreateSnapshot(gun data.GUN, checksum string, store storage.MetaStore, cryptoService signed.CryptoService) (
 	*time.Time, []byte, error) {
+	return GetOrCreateSnapshotWithPolicy(gun, checksum, store, cryptoService, nil)
+}
+
+// GetOrCreateSnapshotWithPolicy is GetOrCreateSnapshot, but generated
+// snapshots expire according to expiryPolicy
+func GetOrCreateSnapshotWithPolicy(gun data.GUN, checksum string, store storage.MetaStore, cryptoService signed.CryptoService,
+	expiryPolicy *data.ExpiryPolicy) (*time.Time, []byte, error) {
 
 	lastModified, currentJSON, err := store.GetChecksum(gun, data.CanonicalSnapshotRole, checksum)
 	if err != nil {
@@ -84,7 +91,7 @@ func GetOrCreateSnapshot(gun data.GUN, checksum string, store storage.MetaStore,
 		return lastModified, currentJSON, nil
 	}
 
-	builder := tuf.NewRepoBuilder(gun, cryptoService, trustpinning.TrustPinConfig{})
+	builder := tuf.NewRepoBuilderWithExpiryPolicy(gun, cryptoService, trustpinning.TrustPinConfig{}, expiryPolicy)
 
 	// load the current root to ensure we use the correct snapshot key.
 	_, rootJSON, err := store.GetCurrent(gun, data.CanonicalRootRole)
@@ -45,6 +45,16 @@ type MetaStore interface {
 	// not found, it returns storage.ErrNotFound
 	GetVersion(gun data.GUN, tufRole data.RoleName, version int) (created *time.Time, data []byte, err error)
 
+	// WalkCurrent calls fn with the latest version of every role of every
+	// GUN, in no particular order, stopping at the first error fn returns.
+	WalkCurrent(fn func(gun data.GUN, update MetaUpdate) error) error
+
+	// ListGUNs returns, in ascending order, up to limit GUNs which have
+	// metadata, starting after the GUN last.  If prefix is not empty, only
+	// GUNs beginning with it are returned.  A limit of 0 or less means there
+	// is no limit.
+	ListGUNs(prefix string, last data.GUN, limit int) ([]data.GUN, error)
+
 	// Delete removes all metadata for a given GUN.  It does not return an
 	// error if no metadata exists for the given GUN.
 	Delete(gun data.GUN) error
@@ -59,4 +69,10 @@ type MetaStore interface {
 	// the given changeID.
 	// The returned []Change should always be ordered oldest to newest.
 	GetChanges(changeID string, records int, filterName string) ([]Change, error)
+
+	// WatchChanges returns a channel which receives a value whenever changes
+	// written after the call may be available from GetChanges, and a function
+	// which must be called to stop watching.  Notifications are coalesced, so
+	// a single value may stand for several changes.
+	WatchChanges() (<-chan struct{}, func(), error)
 }
@@ -42,6 +42,7 @@ type MemStorage struct {
 	keys      map[string]map[string]*key
 	checksums map[string]map[string]ver
 	changes   []Change
+	notifier  *changeNotifier
 }
 
 // NewMemStorage instantiates a memStorage instance
@@ -50,6 +51,7 @@ func NewMemStorage() *MemStorage {
 		tufMeta:   make(map[string]verList),
 		keys:      make(map[string]map[string]*key),
 		checksums: make(map[string]map[string]ver),
+		notifier:  newChangeNotifier(),
 	}
 }
 
@@ -93,6 +95,7 @@ func (st *MemStorage) writeChange(gun data.GUN, version int, checksum string) {
 		Category:  changeCategoryUpdate,
 	}
 	st.changes = append(st.changes, c)
+	st.notifier.notify()
 }
 
 // UpdateMany updates multiple TUF records
@@ -184,13 +187,72 @@ func (st *MemStorage) GetVersion(gun data.GUN, role data.RoleName, version int)
 	return nil, nil, ErrNotFound{}
 }
 
+// WalkCurrent calls fn with the latest version of every role of every GUN
+func (st *MemStorage) WalkCurrent(fn func(gun data.GUN, update MetaUpdate) error) error {
+	type current struct {
+		gun    data.GUN
+		update MetaUpdate
+	}
+	st.lock.Lock()
+	all := make([]current, 0, len(st.tufMeta))
+	for id, space := range st.tufMeta {
+		if len(space) == 0 {
+			continue
+		}
+		// role names cannot contain dots, so the role follows the last one
+		i := strings.LastIndex(id, ".")
+		latest := space[len(space)-1]
+		all = append(all, current{
+			gun:    data.GUN(id[:i]),
+			update: MetaUpdate{Role: data.RoleName(id[i+1:]), Version: latest.version, Data: latest.data},
+		})
+	}
+	st.lock.Unlock()
+
+	// fn is called without the lock held, so that it can use the store
+	for _, c := range all {
+		if err := fn(c.gun, c.update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListGUNs returns, in ascending order, up to limit GUNs with the given prefix
+// which have metadata, starting after last
+func (st *MemStorage) ListGUNs(prefix string, last data.GUN, limit int) ([]data.GUN, error) {
+	st.lock.Lock()
+	seen := make(map[data.GUN]struct{})
+	for id, space := range st.tufMeta {
+		if len(space) == 0 {
+			continue
+		}
+		gun := data.GUN(id[:strings.LastIndex(id, ".")])
+		if gun > last && strings.HasPrefix(gun.String(), prefix) {
+			seen[gun] = struct{}{}
+		}
+	}
+	st.lock.Unlock()
+
+	guns := make([]data.GUN, 0, len(seen))
+	for gun := range seen {
+		guns = append(guns, gun)
+	}
+	sort.Slice(guns, func(i, j int) bool { return guns[i] < guns[j] })
+	if limit > 0 && len(guns) > limit {
+		guns = guns[:limit]
+	}
+	return guns, nil
+}
+
 // Delete deletes all the metadata for a given GUN
 func (st *MemStorage) Delete(gun data.GUN) error {
 	st.lock.Lock()
 	defer st.lock.Unlock()
 	l := len(st.tufMeta)
 	for k := range st.tufMeta {
-		if strings.HasPrefix(k, gun.String()) {
+		// role names cannot contain dots, so the GUN precedes the last one
+		if k[:strings.LastIndex(k, ".")] == gun.String() {
 			delete(st.tufMeta, k)
 		}
 	}
@@ -206,6 +268,7 @@ func (st *MemStorage) Delete(gun data.GUN) error {
 		CreatedAt: time.Now(),
 	}
 	st.changes = append(st.changes, c)
+	st.notifier.notify()
 	return nil
 }
 
@@ -286,6 +349,12 @@ func (st *MemStorage) GetChanges(changeID string, records int, filterName string
 	return getFilteredChanges(toInspect, filterName, records, reversed), nil
 }
 
+// WatchChanges notifies the watcher of each change written to this MemStorage
+func (st *MemStorage) WatchChanges() (<-chan struct{}, func(), error) {
+	notify, stop := st.notifier.watch()
+	return notify, stop, nil
+}
+
 func getFilteredChanges(toInspect []Change, filterName string, records int, reversed bool) []Change {
 	res := make([]Change, 0, records)
 	if reversed {
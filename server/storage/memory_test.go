@@ -97,6 +97,24 @@ func TestMemoryGetChanges(t *testing.T) {
 	testGetChanges(t, s)
 }
 
+func TestMemoryWatchChanges(t *testing.T) {
+	s := NewMemStorage()
+
+	testWatchChanges(t, s)
+}
+
+func TestMemoryWalkCurrent(t *testing.T) {
+	s := NewMemStorage()
+
+	testWalkCurrent(t, s)
+}
+
+func TestMemoryListGUNs(t *testing.T) {
+	s := NewMemStorage()
+
+	testListGUNs(t, s)
+}
+
 func TestGetVersion(t *testing.T) {
 	s := NewMemStorage()
 	testGetVersion(t, s)
@@ -0,0 +1,41 @@
+package storage
+
+import "sync"
+
+// changeNotifier wakes any number of watchers when a change is written to the
+// changefeed by this process
+type changeNotifier struct {
+	lock     sync.Mutex
+	watchers map[chan struct{}]struct{}
+}
+
+func newChangeNotifier() *changeNotifier {
+	return &changeNotifier{watchers: make(map[chan struct{}]struct{})}
+}
+
+// watch returns a channel which receives a value after each call to notify,
+// and a function to stop watching.  The channel is buffered by one, so
+// notifications are coalesced for a watcher which has not yet caught up.
+func (n *changeNotifier) watch() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	n.lock.Lock()
+	n.watchers[ch] = struct{}{}
+	n.lock.Unlock()
+	return ch, func() {
+		n.lock.Lock()
+		delete(n.watchers, ch)
+		n.lock.Unlock()
+	}
+}
+
+// notify wakes all watchers without blocking
+func (n *changeNotifier) notify() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for ch := range n.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -177,3 +177,24 @@ func TestRethinkDBGetChanges(t *testing.T) {
 
 	testGetChanges(t, dbStore)
 }
+
+func TestRethinkDBWatchChanges(t *testing.T) {
+	dbStore, cleanup := rethinkDBSetup(t)
+	defer cleanup()
+
+	testWatchChanges(t, dbStore)
+}
+
+func TestRethinkDBWalkCurrent(t *testing.T) {
+	dbStore, cleanup := rethinkDBSetup(t)
+	defer cleanup()
+
+	testWalkCurrent(t, dbStore)
+}
+
+func TestRethinkDBListGUNs(t *testing.T) {
+	dbStore, cleanup := rethinkDBSetup(t)
+	defer cleanup()
+
+	testListGUNs(t, dbStore)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sort"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary/storage/rethinkdb"
@@ -280,6 +281,67 @@ func (rdb RethinkDB) GetVersion(gun data.GUN, role data.RoleName, version int) (
 	return &file.CreatedAt, file.Data, err
 }
 
+// WalkCurrent calls fn with the latest version of every role of every GUN
+func (rdb RethinkDB) WalkCurrent(fn func(gun data.GUN, update MetaUpdate) error) error {
+	var file RDBTUFFile
+	res, err := gorethink.DB(rdb.dbName).Table(file.TableName(), gorethink.TableOpts{ReadMode: "majority"}).Group(
+		"gun", "role",
+	).Max("version").Ungroup().Field("reduction").Run(rdb.sess)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	for res.Next(&file) {
+		update := MetaUpdate{Role: data.RoleName(file.Role), Version: file.Version, Data: file.Data}
+		if err := fn(data.GUN(file.Gun), update); err != nil {
+			return err
+		}
+	}
+	return res.Err()
+}
+
+// ListGUNs returns, in ascending order, up to limit GUNs with the given prefix
+// which have metadata, starting after last
+func (rdb RethinkDB) ListGUNs(prefix string, last data.GUN, limit int) ([]data.GUN, error) {
+	var (
+		lower interface{} = gorethink.MinVal
+		upper interface{} = gorethink.MaxVal
+		opts              = gorethink.BetweenOpts{Index: "gun", LeftBound: "open", RightBound: "open"}
+	)
+	if last != "" {
+		lower = last.String()
+	}
+	if prefix != "" {
+		if prefix > last.String() {
+			lower = prefix
+			opts.LeftBound = "closed"
+		}
+		// every string beginning with the prefix sorts before this one
+		upper = prefix + string(utf8.MaxRune)
+	}
+	term := gorethink.DB(rdb.dbName).Table(RDBTUFFile{}.TableName(), gorethink.TableOpts{ReadMode: "majority"}).Between(
+		lower, upper, opts,
+	).Distinct(gorethink.DistinctOpts{Index: "gun"})
+	if limit > 0 {
+		term = term.Limit(limit)
+	}
+	res, err := term.Run(rdb.sess)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var names []string
+	if err := res.All(&names); err != nil {
+		return nil, err
+	}
+	guns := make([]data.GUN, 0, len(names))
+	for _, name := range names {
+		guns = append(guns, data.GUN(name))
+	}
+	return guns, nil
+}
+
 // Delete removes all metadata for a given GUN.  It does not return an
 // error if no metadata exists for the given GUN.
 func (rdb RethinkDB) Delete(gun data.GUN) error {
@@ -427,6 +489,33 @@ func (rdb RethinkDB) GetChanges(changeID string, pageSize int, filterName string
 	return changes, res.All(&changes)
 }
 
+// WatchChanges uses a RethinkDB changefeed on the changes table, so that the
+// watcher is notified of changes written by any server sharing the database.
+// Because GetChanges does not return changes until they are older than the
+// blackout time, each notification is delayed by the same amount.
+func (rdb RethinkDB) WatchChanges() (<-chan struct{}, func(), error) {
+	cursor, err := gorethink.DB(rdb.dbName).Table(Change{}.TableName()).Changes().Run(rdb.sess)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to watch changes table: %s", err.Error())
+	}
+	notify := make(chan struct{}, 1)
+	go func() {
+		var ch interface{}
+		for cursor.Next(&ch) {
+			time.AfterFunc(time.Duration(blackoutTime)*time.Second, func() {
+				select {
+				case notify <- struct{}{}:
+				default:
+				}
+			})
+		}
+		if err := cursor.Err(); err != nil {
+			logrus.Warnf("stopped watching changes table: %s", err.Error())
+		}
+	}()
+	return notify, func() { cursor.Close() }, nil
+}
+
 // bound creates the correct boundary based in the index that should be used for
 // querying the changefeed.
 func (rdb RethinkDB) bound(changeID, filterName string) ([]interface{}, string) {
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -17,6 +18,7 @@ import (
 // See server/storage/models.go
 type SQLStorage struct {
 	gorm.DB
+	notifier *changeNotifier
 }
 
 // NewSQLStorage is a convenience method to create a SQLStorage
@@ -26,7 +28,8 @@ func NewSQLStorage(dialect string, args ...interface{}) (*SQLStorage, error) {
 		return nil, err
 	}
 	return &SQLStorage{
-		DB: *gormDB,
+		DB:       *gormDB,
+		notifier: newChangeNotifier(),
 	}, nil
 }
 
@@ -89,7 +92,19 @@ func (db *SQLStorage) UpdateCurrent(gun data.GUN, update MetaUpdate) error {
 	}(); err != nil {
 		return rb(err)
 	}
-	return tx.Commit().Error
+	return db.commit(tx, update.Role == data.CanonicalTimestampRole)
+}
+
+// commit commits the transaction, and if it wrote to the changefeed notifies
+// any watchers once the change is visible to them
+func (db *SQLStorage) commit(tx *gorm.DB, changed bool) error {
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	if changed {
+		db.notifier.notify()
+	}
+	return nil
 }
 
 type rollback func(error) error
@@ -118,8 +133,9 @@ func (db *SQLStorage) UpdateMany(gun data.GUN, updates []MetaUpdate) error {
 		return err
 	}
 	var (
-		query *gorm.DB
-		added = make(map[uint]bool)
+		query   *gorm.DB
+		added   = make(map[uint]bool)
+		changed bool
 	)
 	if err := func() error {
 		for _, update := range updates {
@@ -155,6 +171,7 @@ func (db *SQLStorage) UpdateMany(gun data.GUN, updates []MetaUpdate) error {
 				if err := db.writeChangefeed(tx, gun, update.Version, hexChecksum); err != nil {
 					return err
 				}
+				changed = true
 			}
 			added[row.ID] = true
 		}
@@ -162,7 +179,7 @@ func (db *SQLStorage) UpdateMany(gun data.GUN, updates []MetaUpdate) error {
 	}(); err != nil {
 		return rb(err)
 	}
-	return tx.Commit().Error
+	return db.commit(tx, changed)
 }
 
 func (db *SQLStorage) writeChangefeed(tx *gorm.DB, gun data.GUN, version int, checksum string) error {
@@ -227,6 +244,62 @@ func isReadErr(q *gorm.DB, row TUFFile) error {
 	return nil
 }
 
+// WalkCurrent calls fn with the latest version of every role of every GUN
+func (db *SQLStorage) WalkCurrent(fn func(gun data.GUN, update MetaUpdate) error) error {
+	rows, err := db.Model(&TUFFile{}).Select(
+		"tuf_files.gun, tuf_files.role, tuf_files.version, tuf_files.data",
+	).Joins(
+		"JOIN (SELECT gun, role, MAX(version) AS version FROM tuf_files GROUP BY gun, role) latest " +
+			"ON tuf_files.gun = latest.gun AND tuf_files.role = latest.role AND tuf_files.version = latest.version",
+	).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			gun, role string
+			update    MetaUpdate
+		)
+		if err := rows.Scan(&gun, &role, &update.Version, &update.Data); err != nil {
+			return err
+		}
+		update.Role = data.RoleName(role)
+		if err := fn(data.GUN(gun), update); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListGUNs returns, in ascending order, up to limit GUNs with the given prefix
+// which have metadata, starting after last
+func (db *SQLStorage) ListGUNs(prefix string, last data.GUN, limit int) ([]data.GUN, error) {
+	q := db.Model(&TUFFile{}).Where("gun > ?", last.String())
+	if prefix != "" {
+		// "!" is used as the escape character because the default differs
+		// between databases, and a backslash needs escaping in MySQL
+		q = q.Where("gun LIKE ? ESCAPE '!'", likeEscaper.Replace(prefix)+"%")
+	}
+	// gorm drops the ORDER BY from DISTINCT queries, so group instead
+	q = q.Group("gun").Order("gun")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var names []string
+	if err := q.Pluck("gun", &names).Error; err != nil {
+		return nil, err
+	}
+	guns := make([]data.GUN, 0, len(names))
+	for _, name := range names {
+		guns = append(guns, data.GUN(name))
+	}
+	return guns, nil
+}
+
+// likeEscaper escapes the characters which are special in a LIKE pattern
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 // Delete deletes all the records for a specific GUN - we have to do a hard delete using Unscoped
 // otherwise we can't insert for that GUN again
 func (db *SQLStorage) Delete(gun data.GUN) error {
@@ -234,6 +307,7 @@ func (db *SQLStorage) Delete(gun data.GUN) error {
 	if err != nil {
 		return err
 	}
+	var changed bool
 	if err := func() error {
 		res := tx.Unscoped().Where(&TUFFile{Gun: gun.String()}).Delete(TUFFile{})
 		if err := res.Error; err != nil {
@@ -248,11 +322,12 @@ func (db *SQLStorage) Delete(gun data.GUN) error {
 			GUN:      gun.String(),
 			Category: changeCategoryDeletion,
 		}
+		changed = true
 		return tx.Create(c).Error
 	}(); err != nil {
 		return rb(err)
 	}
-	return tx.Commit().Error
+	return db.commit(tx, changed)
 }
 
 // CheckHealth asserts that the tuf_files table is present
@@ -320,3 +395,11 @@ func (db *SQLStorage) GetChanges(changeID string, records int, filterName string
 
 	return changes, nil
 }
+
+// WatchChanges notifies the watcher of each change committed through this
+// SQLStorage.  Changes written by other servers sharing the same database
+// are not notified, so watchers should also check GetChanges periodically.
+func (db *SQLStorage) WatchChanges() (<-chan struct{}, func(), error) {
+	notify, stop := db.notifier.watch()
+	return notify, stop, nil
+}
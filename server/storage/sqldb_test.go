@@ -248,6 +248,27 @@ func TestSQLGetChanges(t *testing.T) {
 	testGetChanges(t, s)
 }
 
+func TestSQLWatchChanges(t *testing.T) {
+	s, cleanup := sqldbSetup(t)
+	defer cleanup()
+
+	testWatchChanges(t, s)
+}
+
+func TestSQLWalkCurrent(t *testing.T) {
+	s, cleanup := sqldbSetup(t)
+	defer cleanup()
+
+	testWalkCurrent(t, s)
+}
+
+func TestSQLListGUNs(t *testing.T) {
+	s, cleanup := sqldbSetup(t)
+	defer cleanup()
+
+	testListGUNs(t, s)
+}
+
 func TestSQLDBGetVersion(t *testing.T) {
 	dbStore, cleanup := sqldbSetup(t)
 	defer cleanup()
@@ -458,3 +458,170 @@ func testGetChanges(t *testing.T, s MetaStore) {
 	require.NotEqual(t, "alpine", c[0].GUN)
 
 }
+
+func testWatchChanges(t *testing.T, s MetaStore) {
+	blackoutTime = 0
+	notify, stop, err := s.WatchChanges()
+	require.NoError(t, err)
+
+	requireNotified := func(msg string) {
+		select {
+		case <-notify:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no notification: %s", msg)
+		}
+	}
+	requireNotNotified := func(msg string) {
+		select {
+		case <-notify:
+			t.Fatalf("unexpected notification: %s", msg)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	require.NoError(t, s.UpdateCurrent("alpine", MetaUpdate{
+		Role:    data.CanonicalRootRole,
+		Version: 1,
+		Data:    []byte{'1'},
+	}))
+	requireNotNotified("only timestamps are written to the changefeed")
+
+	require.NoError(t, s.UpdateCurrent("alpine", MetaUpdate{
+		Role:    data.CanonicalTimestampRole,
+		Version: 1,
+		Data:    []byte{'1'},
+	}))
+	requireNotified("timestamp updated")
+
+	require.NoError(t, s.UpdateMany("alpine", []MetaUpdate{
+		{
+			Role:    data.CanonicalRootRole,
+			Version: 2,
+			Data:    []byte{'2'},
+		},
+		{
+			Role:    data.CanonicalTimestampRole,
+			Version: 2,
+			Data:    []byte{'2'},
+		},
+	}))
+	requireNotified("timestamp updated along with another role")
+
+	require.Error(t, s.UpdateCurrent("alpine", MetaUpdate{
+		Role:    data.CanonicalTimestampRole,
+		Version: 2,
+		Data:    []byte{'2'},
+	}))
+	requireNotNotified("failed update")
+
+	require.NoError(t, s.Delete("nonexistent"))
+	requireNotNotified("nothing was deleted")
+
+	require.NoError(t, s.Delete("alpine"))
+	requireNotified("GUN deleted")
+
+	changes, err := s.GetChanges("0", 10, "")
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	stop()
+	require.NoError(t, s.UpdateCurrent("alpine", MetaUpdate{
+		Role:    data.CanonicalTimestampRole,
+		Version: 3,
+		Data:    []byte{'3'},
+	}))
+	requireNotNotified("watching was stopped")
+}
+
+func testWalkCurrent(t *testing.T, s MetaStore) {
+	walk := func() map[string]MetaUpdate {
+		current := make(map[string]MetaUpdate)
+		require.NoError(t, s.WalkCurrent(func(gun data.GUN, update MetaUpdate) error {
+			current[entryKey(gun, update.Role)] = update
+			return nil
+		}))
+		return current
+	}
+	require.Empty(t, walk())
+
+	var expected []StoredTUFMeta
+	for _, gun := range []data.GUN{"docker.io/alpine", "docker.io/alpine.v2"} {
+		for version := 1; version <= 3; version++ {
+			for _, role := range []data.RoleName{data.CanonicalRootRole, "targets/a"} {
+				tufObj := SampleCustomTUFObj(gun, role, version, nil)
+				require.NoError(t, s.UpdateCurrent(gun, MakeUpdate(tufObj)))
+				if version == 3 {
+					expected = append(expected, tufObj)
+				}
+			}
+		}
+	}
+	current := walk()
+	require.Len(t, current, len(expected))
+	for _, tufObj := range expected {
+		require.Equal(t, MakeUpdate(tufObj), current[entryKey(tufObj.Gun, tufObj.Role)])
+	}
+
+	// an error from fn stops the walk
+	calls := 0
+	err := s.WalkCurrent(func(data.GUN, MetaUpdate) error {
+		calls++
+		return fmt.Errorf("stop")
+	})
+	require.EqualError(t, err, "stop")
+	require.Equal(t, 1, calls)
+
+	require.NoError(t, s.Delete("docker.io/alpine.v2"))
+	require.Len(t, walk(), 2)
+}
+
+func testListGUNs(t *testing.T, s MetaStore) {
+	guns, err := s.ListGUNs("", "", 0)
+	require.NoError(t, err)
+	require.Empty(t, guns)
+
+	for _, gun := range []data.GUN{"quay.io/b", "docker.io/b", "docker.io/a", "docker_io/c", "docker.io/a.b"} {
+		for version := 1; version <= 2; version++ {
+			for _, role := range []data.RoleName{data.CanonicalRootRole, data.CanonicalTargetsRole} {
+				require.NoError(t, s.UpdateCurrent(gun, MakeUpdate(SampleCustomTUFObj(gun, role, version, nil))))
+			}
+		}
+	}
+
+	guns, err = s.ListGUNs("", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"docker.io/a", "docker.io/a.b", "docker.io/b", "docker_io/c", "quay.io/b"}, guns)
+
+	// paging through
+	guns, err = s.ListGUNs("", "", 2)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"docker.io/a", "docker.io/a.b"}, guns)
+	guns, err = s.ListGUNs("", "docker.io/a.b", 2)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"docker.io/b", "docker_io/c"}, guns)
+	guns, err = s.ListGUNs("", "docker_io/c", 2)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"quay.io/b"}, guns)
+
+	// the prefix is matched literally, not as a pattern
+	guns, err = s.ListGUNs("docker.", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"docker.io/a", "docker.io/a.b", "docker.io/b"}, guns)
+	guns, err = s.ListGUNs("docker_", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"docker_io/c"}, guns)
+	guns, err = s.ListGUNs("docker.io/", "docker.io/a", 1)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"docker.io/a.b"}, guns)
+	guns, err = s.ListGUNs("docker.io/", "a", 1)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"docker.io/a"}, guns)
+	guns, err = s.ListGUNs("docker.io/", "docker.io/b", 0)
+	require.NoError(t, err)
+	require.Empty(t, guns)
+
+	require.NoError(t, s.Delete("docker.io/a"))
+	guns, err = s.ListGUNs("docker.io/", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, []data.GUN{"docker.io/a.b", "docker.io/b"}, guns)
+}
@@ -73,6 +73,13 @@ func RotateTimestampKey(gun data.GUN, store storage.MetaStore, crypto signed.Cry
 // it is also saved in the store
 func GetOrCreateTimestamp(gun data.GUN, store storage.MetaStore, cryptoService signed.CryptoService) (
 	*time.Time, []byte, error) {
+	return GetOrCreateTimestampWithPolicy(gun, store, cryptoService, nil)
+}
+
+// GetOrCreateTimestampWithPolicy is GetOrCreateTimestamp, but generated
+// timestamps and snapshots expire according to expiryPolicy
+func GetOrCreateTimestampWithPolicy(gun data.GUN, store storage.MetaStore, cryptoService signed.CryptoService,
+	expiryPolicy *data.ExpiryPolicy) (*time.Time, []byte, error) {
 
 	updates := []storage.MetaUpdate{}
 
@@ -96,7 +103,7 @@ func GetOrCreateTimestamp(gun data.GUN, store storage.MetaStore, cryptoService s
 		return nil, nil, data.ErrMissingMeta{Role: data.CanonicalSnapshotRole.String()}
 	}
 	snapshotSHA256Hex := hex.EncodeToString(snapshotSHA256Bytes[:])
-	snapshotTime, snapshot, err := snapshot.GetOrCreateSnapshot(gun, snapshotSHA256Hex, store, cryptoService)
+	snapshotTime, snapshot, err := snapshot.GetOrCreateSnapshotWithPolicy(gun, snapshotSHA256Hex, store, cryptoService, expiryPolicy)
 	if err != nil {
 		logrus.Debug("Previous timestamp, but no valid snapshot for GUN ", gun)
 		return nil, nil, err
@@ -116,7 +123,7 @@ func GetOrCreateTimestamp(gun data.GUN, store storage.MetaStore, cryptoService s
 		return lastModified, timestampJSON, nil
 	}
 
-	tsUpdate, err := createTimestamp(gun, prev, snapshot, store, cryptoService)
+	tsUpdate, err := createTimestamp(gun, prev, snapshot, store, cryptoService, expiryPolicy)
 	if err != nil {
 		logrus.Error("Failed to create a new timestamp")
 		return nil, nil, err
@@ -150,9 +157,9 @@ func snapshotExpired(ts *data.SignedTimestamp, snapshot []byte) bool {
 // version number one higher than prev. The store is used to lookup the current
 // snapshot, this function does not save the newly generated timestamp.
 func createTimestamp(gun data.GUN, prev *data.SignedTimestamp, snapshot []byte, store storage.MetaStore,
-	cryptoService signed.CryptoService) (*storage.MetaUpdate, error) {
+	cryptoService signed.CryptoService, expiryPolicy *data.ExpiryPolicy) (*storage.MetaUpdate, error) {
 
-	builder := tuf.NewRepoBuilder(gun, cryptoService, trustpinning.TrustPinConfig{})
+	builder := tuf.NewRepoBuilderWithExpiryPolicy(gun, cryptoService, trustpinning.TrustPinConfig{}, expiryPolicy)
 
 	// load the current root to ensure we use the correct timestamp key.
 	_, root, err := store.GetCurrent(gun, data.CanonicalRootRole)
@@ -143,6 +143,36 @@ func TestGetTimestampOldTimestampExpired(t *testing.T) {
 	require.True(t, signedMeta.Signed.Expires.After(time.Now()))
 }
 
+// A regenerated timestamp expires according to the expiry policy
+func TestGetTimestampUsesExpiryPolicy(t *testing.T) {
+	store := storage.NewMemStorage()
+	repo, crypto, err := testutils.EmptyRepo("prod/app")
+	require.NoError(t, err)
+
+	meta, err := testutils.SignAndSerialize(repo)
+	require.NoError(t, err)
+
+	_, err = repo.SignTimestamp(time.Now().AddDate(-1, -1, -1))
+	require.NoError(t, err)
+	timestampJSON, err := json.Marshal(repo.Timestamp)
+	require.NoError(t, err)
+
+	require.NoError(t, store.UpdateMany("prod/app", []storage.MetaUpdate{
+		{Role: data.CanonicalRootRole, Version: 0, Data: meta[data.CanonicalRootRole]},
+		{Role: data.CanonicalSnapshotRole, Version: 0, Data: meta[data.CanonicalSnapshotRole]},
+		{Role: data.CanonicalTimestampRole, Version: 1, Data: timestampJSON},
+	}))
+
+	policy := data.NewExpiryPolicy(
+		data.ExpiryRule{GUNPrefix: "prod/", Role: data.CanonicalTimestampRole, Expiry: time.Hour})
+	_, gottenTimestamp, err := GetOrCreateTimestampWithPolicy("prod/app", store, crypto, policy)
+	require.NoError(t, err)
+
+	signedMeta := &data.SignedMeta{}
+	require.NoError(t, json.Unmarshal(gottenTimestamp, signedMeta))
+	require.WithinDuration(t, time.Now().Add(time.Hour), signedMeta.Signed.Expires, time.Minute)
+}
+
 // If the root or snapshot is missing or corrupt, no timestamp can be generated
 func TestCannotMakeNewTimestampIfNoRootOrSnapshot(t *testing.T) {
 	repo, crypto, err := testutils.EmptyRepo("gun")
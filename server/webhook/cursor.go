@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/theupdateframework/notary"
+)
+
+// ErrNoCursor is returned by a CursorStore when no cursor has been stored for
+// a subscription
+type ErrNoCursor struct {
+	Name string
+}
+
+func (err ErrNoCursor) Error() string {
+	return fmt.Sprintf("no delivery cursor for webhook %s", err.Name)
+}
+
+// CursorStore persists the ID of the last change delivered to each
+// subscription, so that deliveries resume where they left off after a restart
+type CursorStore interface {
+	GetCursor(name string) (string, error)
+	SetCursor(name, changeID string) error
+}
+
+// MemoryCursorStore is a CursorStore that does not persist across restarts
+type MemoryCursorStore struct {
+	lock    sync.Mutex
+	cursors map[string]string
+}
+
+// NewMemoryCursorStore instantiates a MemoryCursorStore
+func NewMemoryCursorStore() *MemoryCursorStore {
+	return &MemoryCursorStore{cursors: make(map[string]string)}
+}
+
+// GetCursor returns the stored cursor for the subscription
+func (m *MemoryCursorStore) GetCursor(name string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	cursor, ok := m.cursors[name]
+	if !ok {
+		return "", ErrNoCursor{Name: name}
+	}
+	return cursor, nil
+}
+
+// SetCursor stores the cursor for the subscription
+func (m *MemoryCursorStore) SetCursor(name, changeID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.cursors[name] = changeID
+	return nil
+}
+
+// FileCursorStore is a CursorStore that keeps one file per subscription in a
+// directory
+type FileCursorStore struct {
+	baseDir string
+}
+
+// NewFileCursorStore creates the directory if necessary and returns a
+// FileCursorStore using it
+func NewFileCursorStore(baseDir string) (*FileCursorStore, error) {
+	if err := os.MkdirAll(baseDir, notary.PrivExecPerms); err != nil {
+		return nil, err
+	}
+	return &FileCursorStore{baseDir: baseDir}, nil
+}
+
+func (f *FileCursorStore) path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid webhook name: %q", name)
+	}
+	return filepath.Join(f.baseDir, name+".cursor"), nil
+}
+
+// GetCursor returns the stored cursor for the subscription
+func (f *FileCursorStore) GetCursor(name string) (string, error) {
+	p, err := f.path(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return "", ErrNoCursor{Name: name}
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// SetCursor stores the cursor for the subscription, replacing the file
+// atomically so a crash never leaves a partially written cursor behind
+func (f *FileCursorStore) SetCursor(name, changeID string) error {
+	p, err := f.path(name)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(f.baseDir, name+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(changeID); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testCursorStore(t *testing.T, cursors CursorStore) {
+	_, err := cursors.GetCursor("ci")
+	require.IsType(t, ErrNoCursor{}, err)
+
+	require.NoError(t, cursors.SetCursor("ci", "10"))
+	require.NoError(t, cursors.SetCursor("ci", "11"))
+	require.NoError(t, cursors.SetCursor("other", "3"))
+
+	cursor, err := cursors.GetCursor("ci")
+	require.NoError(t, err)
+	require.Equal(t, "11", cursor)
+	cursor, err = cursors.GetCursor("other")
+	require.NoError(t, err)
+	require.Equal(t, "3", cursor)
+}
+
+func TestMemoryCursorStore(t *testing.T) {
+	testCursorStore(t, NewMemoryCursorStore())
+}
+
+func TestFileCursorStore(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "webhook-cursors")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	cursors, err := NewFileCursorStore(tempDir)
+	require.NoError(t, err)
+	testCursorStore(t, cursors)
+
+	// no temporary files are left behind
+	files, err := ioutil.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	for _, name := range []string{"", "..", "a/b"} {
+		require.Error(t, cursors.SetCursor(name, "1"))
+		_, err := cursors.GetCursor(name)
+		require.Error(t, err)
+	}
+}
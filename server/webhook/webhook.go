@@ -0,0 +1,237 @@
+// Package webhook delivers changefeed events from a notary server's
+// MetaStore to subscribed HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/server/storage"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body,
+	// prefixed with "sha256=", when the subscription has a secret
+	SignatureHeader = "X-Notary-Signature"
+	// EventHeader carries the category of the change, i.e. update or deletion
+	EventHeader = "X-Notary-Event"
+	// DeliveryHeader carries the changefeed ID of the change
+	DeliveryHeader = "X-Notary-Delivery"
+
+	defaultPollInterval = 5 * time.Second
+	defaultMaxRetries   = 5
+	defaultRetryBackoff = time.Second
+	defaultTimeout      = 10 * time.Second
+)
+
+// Subscription is an HTTP endpoint to be notified of changes to any GUN
+// starting with one of its prefixes.  A subscription with no prefixes is
+// notified of changes to every GUN.
+type Subscription struct {
+	Name        string
+	URL         string
+	Secret      string
+	GUNPrefixes []string
+}
+
+// Matches returns whether changes to the given GUN should be delivered to
+// this subscription
+func (s Subscription) Matches(gun string) bool {
+	if len(s.GUNPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range s.GUNPrefixes {
+		if strings.HasPrefix(gun, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Signature returns the value of the SignatureHeader for the given payload
+// and secret, so that receivers can verify a delivery came from the server.
+func Signature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher watches the changefeed of a MetaStore and POSTs each new change
+// to the subscriptions that match its GUN.  Changes are delivered to each
+// subscription in order, and a subscription's cursor only advances once a
+// change has been delivered, so an endpoint which is down will receive the
+// changes it missed once it comes back.
+type Dispatcher struct {
+	store         storage.MetaStore
+	cursors       CursorStore
+	subscriptions []Subscription
+	client        *http.Client
+
+	// PollInterval is how often the changefeed is checked for new changes
+	PollInterval time.Duration
+	// MaxRetries is how many times a failed delivery is retried before
+	// giving up until the next poll
+	MaxRetries int
+	// RetryBackoff is how long to wait before the first retry, doubling
+	// with each subsequent attempt
+	RetryBackoff time.Duration
+}
+
+// NewDispatcher returns a Dispatcher for the given subscriptions, which
+// persists each subscription's position in the changefeed to cursors.
+func NewDispatcher(store storage.MetaStore, cursors CursorStore, subscriptions ...Subscription) *Dispatcher {
+	return &Dispatcher{
+		store:         store,
+		cursors:       cursors,
+		subscriptions: subscriptions,
+		client:        &http.Client{Timeout: defaultTimeout},
+		PollInterval:  defaultPollInterval,
+		MaxRetries:    defaultMaxRetries,
+		RetryBackoff:  defaultRetryBackoff,
+	}
+}
+
+// Run delivers changes until the context is cancelled.  Changes are delivered
+// as soon as the store notifies them, and otherwise every PollInterval.
+func (d *Dispatcher) Run(ctx context.Context) {
+	notify, stop, err := d.store.WatchChanges()
+	if err != nil {
+		logrus.Warnf("webhooks will only poll for changes: %v", err)
+	} else {
+		defer stop()
+	}
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.DeliverPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverPending delivers all outstanding changes to every subscription,
+// returning the first error encountered.  A failing subscription does not
+// prevent delivery to the others.
+func (d *Dispatcher) DeliverPending(ctx context.Context) error {
+	var firstErr error
+	for _, sub := range d.subscriptions {
+		if err := d.deliverSubscription(ctx, sub); err != nil {
+			logrus.Errorf("webhook %s: %v", sub.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (d *Dispatcher) deliverSubscription(ctx context.Context, sub Subscription) error {
+	cursor, err := d.cursors.GetCursor(sub.Name)
+	if _, ok := err.(ErrNoCursor); ok {
+		// A new subscription only receives changes made after it was added
+		cursor, err = d.latestChangeID()
+		if err != nil {
+			return err
+		}
+		if err := d.cursors.SetCursor(sub.Name, cursor); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	for {
+		changes, err := d.store.GetChanges(cursor, notary.DefaultPageSize, "")
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		for _, change := range changes {
+			if sub.Matches(change.GUN) {
+				if err := d.deliverWithRetries(ctx, sub, change); err != nil {
+					return err
+				}
+			}
+			cursor = change.ID
+			if err := d.cursors.SetCursor(sub.Name, cursor); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// latestChangeID returns the ID of the most recent change, or "0" if there
+// have not been any
+func (d *Dispatcher) latestChangeID() (string, error) {
+	changes, err := d.store.GetChanges("-1", 1, "")
+	if err != nil {
+		return "", err
+	}
+	if len(changes) == 0 {
+		return "0", nil
+	}
+	return changes[len(changes)-1].ID, nil
+}
+
+func (d *Dispatcher) deliverWithRetries(ctx context.Context, sub Subscription, change storage.Change) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	backoff := d.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = d.deliver(ctx, sub, change, payload)
+		if err == nil || attempt >= d.MaxRetries {
+			return err
+		}
+		logrus.Debugf("webhook %s: delivery of change %s failed, retrying in %s: %v",
+			sub.Name, change.ID, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, sub Subscription, change storage.Change, payload []byte) error {
+	req, err := http.NewRequest("POST", sub.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, change.Category)
+	req.Header.Set(DeliveryHeader, change.ID)
+	if sub.Secret != "" {
+		req.Header.Set(SignatureHeader, Signature(sub.Secret, payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with %d", sub.URL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+
+	"github.com/theupdateframework/notary/server/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+type delivery struct {
+	change    storage.Change
+	event     string
+	signature string
+	body      []byte
+}
+
+// recorder is a webhook endpoint which fails the first failures requests
+type recorder struct {
+	lock       sync.Mutex
+	failures   int
+	deliveries []delivery
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	d := delivery{
+		event:     req.Header.Get(EventHeader),
+		signature: req.Header.Get(SignatureHeader),
+		body:      body,
+	}
+	json.Unmarshal(body, &d.change)
+	r.deliveries = append(r.deliveries, d)
+}
+
+func (r *recorder) GUNs() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var guns []string
+	for _, d := range r.deliveries {
+		guns = append(guns, d.change.GUN)
+	}
+	return guns
+}
+
+func publishTimestamp(t *testing.T, store storage.MetaStore, gun data.GUN, version int) {
+	require.NoError(t, store.UpdateCurrent(gun, storage.MetaUpdate{
+		Role:    data.CanonicalTimestampRole,
+		Version: version,
+		Data:    []byte{byte(version)},
+	}))
+}
+
+func newTestDispatcher(store storage.MetaStore, cursors CursorStore, subs ...Subscription) *Dispatcher {
+	d := NewDispatcher(store, cursors, subs...)
+	d.RetryBackoff = time.Millisecond
+	return d
+}
+
+func TestDispatcherDeliversSignedChanges(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	store := storage.NewMemStorage()
+	d := newTestDispatcher(store, NewMemoryCursorStore(),
+		Subscription{Name: "ci", URL: ts.URL, Secret: "s3cret"})
+
+	// no changes yet
+	require.NoError(t, d.DeliverPending(context.Background()))
+	require.Empty(t, rec.deliveries)
+
+	publishTimestamp(t, store, "docker.com/notary", 1)
+	require.NoError(t, store.Delete("docker.com/notary"))
+	require.NoError(t, d.DeliverPending(context.Background()))
+
+	require.Len(t, rec.deliveries, 2)
+	require.Equal(t, "update", rec.deliveries[0].event)
+	require.Equal(t, "docker.com/notary", rec.deliveries[0].change.GUN)
+	require.Equal(t, 1, rec.deliveries[0].change.Version)
+	require.Equal(t, "deletion", rec.deliveries[1].event)
+	for _, del := range rec.deliveries {
+		require.Equal(t, Signature("s3cret", del.body), del.signature)
+		require.NotEqual(t, Signature("wrong", del.body), del.signature)
+	}
+
+	// nothing is delivered twice
+	require.NoError(t, d.DeliverPending(context.Background()))
+	require.Len(t, rec.deliveries, 2)
+}
+
+func TestDispatcherFiltersByGUNPrefix(t *testing.T) {
+	rec, allRec := &recorder{}, &recorder{}
+	ts, allTS := httptest.NewServer(rec), httptest.NewServer(allRec)
+	defer ts.Close()
+	defer allTS.Close()
+
+	store := storage.NewMemStorage()
+	d := newTestDispatcher(store, NewMemoryCursorStore(),
+		Subscription{Name: "library", URL: ts.URL, GUNPrefixes: []string{"docker.io/library/", "quay.io/"}},
+		Subscription{Name: "all", URL: allTS.URL})
+	require.NoError(t, d.DeliverPending(context.Background()))
+
+	publishTimestamp(t, store, "docker.io/library/alpine", 1)
+	publishTimestamp(t, store, "docker.io/other/alpine", 1)
+	publishTimestamp(t, store, "quay.io/alpine", 1)
+	require.NoError(t, d.DeliverPending(context.Background()))
+
+	require.Equal(t, []string{"docker.io/library/alpine", "quay.io/alpine"}, rec.GUNs())
+	require.Len(t, allRec.deliveries, 3)
+	require.Empty(t, allRec.deliveries[0].signature, "no signature without a secret")
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	rec := &recorder{failures: 2}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	store := storage.NewMemStorage()
+	cursors := NewMemoryCursorStore()
+	d := newTestDispatcher(store, cursors, Subscription{Name: "ci", URL: ts.URL})
+	require.NoError(t, d.DeliverPending(context.Background()))
+
+	publishTimestamp(t, store, "docker.com/notary", 1)
+	require.NoError(t, d.DeliverPending(context.Background()))
+	require.Len(t, rec.deliveries, 1)
+
+	// once retries are exhausted the cursor does not move, so the change is
+	// delivered on a later attempt
+	rec.failures = 3
+	d.MaxRetries = 1
+	publishTimestamp(t, store, "docker.com/notary", 2)
+	require.Error(t, d.DeliverPending(context.Background()))
+	require.Len(t, rec.deliveries, 1)
+	cursor, err := cursors.GetCursor("ci")
+	require.NoError(t, err)
+	require.Equal(t, "1", cursor)
+
+	require.NoError(t, d.DeliverPending(context.Background()))
+	require.Len(t, rec.deliveries, 2)
+	require.Equal(t, 2, rec.deliveries[1].change.Version)
+}
+
+func TestDispatcherResumesFromPersistedCursor(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	tempDir, err := ioutil.TempDir("", "webhook-cursors")
+	require.NoError(t, err)
+	cursors, err := NewFileCursorStore(tempDir)
+	require.NoError(t, err)
+
+	// changes made before the subscription existed are not delivered
+	store := storage.NewMemStorage()
+	publishTimestamp(t, store, "docker.com/old", 1)
+	sub := Subscription{Name: "ci", URL: ts.URL}
+	require.NoError(t, newTestDispatcher(store, cursors, sub).DeliverPending(context.Background()))
+	require.Empty(t, rec.deliveries)
+
+	// changes made while the server was down are delivered after a restart
+	publishTimestamp(t, store, "docker.com/new", 1)
+	cursors, err = NewFileCursorStore(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, newTestDispatcher(store, cursors, sub).DeliverPending(context.Background()))
+	require.Equal(t, []string{"docker.com/new"}, rec.GUNs())
+}
+
+func TestDispatcherRun(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	store := storage.NewMemStorage()
+	d := newTestDispatcher(store, NewMemoryCursorStore(), Subscription{Name: "ci", URL: ts.URL})
+	d.PollInterval = time.Hour
+	require.NoError(t, d.DeliverPending(context.Background()))
+	publishTimestamp(t, store, "docker.com/notary", 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(rec.GUNs()) == 1 }, time.Second, 10*time.Millisecond)
+
+	// changes are delivered as soon as the store notifies them, without
+	// waiting for the next poll
+	publishTimestamp(t, store, "docker.com/notary", 2)
+	require.Eventually(t, func() bool { return len(rec.GUNs()) == 2 }, time.Second, 10*time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatcher did not stop when its context was cancelled")
+	}
+}
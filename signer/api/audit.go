@@ -0,0 +1,92 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/theupdateframework/notary/signer"
+	"github.com/theupdateframework/notary/signer/audit"
+	"github.com/theupdateframework/notary/trustmanager"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// keyInfoGetter is implemented by the crypto services which know the GUN of
+// each of their keys
+type keyInfoGetter interface {
+	GetKeyInfo(keyID string) (trustmanager.KeyInfo, error)
+}
+
+// clientIdentity returns the identity of the caller from its TLS client
+// certificate, or an empty string if it did not present one
+func clientIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+	state := tlsInfo.State
+	certs := state.PeerCertificates
+	if len(state.VerifiedChains) > 0 {
+		certs = state.VerifiedChains[0]
+	}
+	if len(certs) == 0 {
+		return ""
+	}
+	if certs[0].Subject.CommonName != "" {
+		return certs[0].Subject.CommonName
+	}
+	return certs[0].Subject.String()
+}
+
+// findKeyInfo returns the GUN and role of a key, as far as the crypto services
+// know them
+func findKeyInfo(cryptoServices signer.CryptoServiceIndex, keyID string) trustmanager.KeyInfo {
+	for _, service := range cryptoServices {
+		if getter, ok := service.(keyInfoGetter); ok {
+			if info, err := getter.GetKeyInfo(keyID); err == nil {
+				return info
+			}
+		}
+	}
+	for _, service := range cryptoServices {
+		if _, role, err := service.GetPrivateKey(keyID); err == nil {
+			return trustmanager.KeyInfo{Role: role}
+		}
+	}
+	return trustmanager.KeyInfo{}
+}
+
+// payloadDigest returns the hex encoded SHA256 digest of a signed payload
+func payloadDigest(payload []byte) string {
+	digest := sha256.Sum256(payload)
+	return hex.EncodeToString(digest[:])
+}
+
+// recordAuditEvent appends an event for the caller to the audit log, if there
+// is one.  digest is the digest of the signed payload, or empty.
+func recordAuditEvent(ctx context.Context, log *audit.Log, action, keyID string, info trustmanager.KeyInfo, digest string) error {
+	if log == nil {
+		return nil
+	}
+	event := audit.Event{
+		Action: action,
+		KeyID:  keyID,
+		GUN:    info.Gun,
+		Role:   info.Role,
+		Client: clientIdentity(ctx),
+		Digest: digest,
+	}
+	recorded, err := log.Record(event)
+	if err != nil {
+		ctxu.GetLogger(ctx).Errorf("%s: failed to record audit event for KeyID %s: %v", action, keyID, err)
+		return err
+	}
+	ctxu.GetLogger(ctx).Debugf("%s: recorded audit event %d for KeyID %s", action, recorded.Sequence, keyID)
+	return nil
+}
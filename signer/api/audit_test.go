@@ -0,0 +1,60 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pb "github.com/theupdateframework/notary/proto"
+	"github.com/theupdateframework/notary/signer/audit"
+	"github.com/theupdateframework/notary/tuf/utils"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestClientIdentity(t *testing.T) {
+	cert, err := utils.LoadCertFromFile("../../fixtures/notary-server.crt")
+	require.NoError(t, err)
+
+	require.Empty(t, clientIdentity(context.Background()))
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{})
+	require.Empty(t, clientIdentity(ctx))
+
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+	require.Empty(t, clientIdentity(ctx))
+
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+	require.Equal(t, "notary-server", clientIdentity(ctx))
+}
+
+// tamperingStore modifies every event it returns
+type tamperingStore struct {
+	*audit.MemoryStore
+}
+
+func (s tamperingStore) GetAuditEvents(after uint64, limit int) ([]audit.Event, error) {
+	events, err := s.MemoryStore.GetAuditEvents(after, limit)
+	for i := range events {
+		events[i].KeyID = "tampered"
+	}
+	return events, err
+}
+
+func TestListAuditEventsTampered(t *testing.T) {
+	store := tamperingStore{audit.NewMemoryStore()}
+	auditLog := audit.NewLog(store)
+	_, err := auditLog.Record(audit.Event{Action: audit.ActionCreateKey, KeyID: "keyid"})
+	require.NoError(t, err)
+
+	s := &KeyManagementServer{AuditLog: auditLog}
+	_, err = s.ListAuditEvents(context.Background(), &pb.ListAuditEventsRequest{})
+	require.Error(t, err)
+	require.Equal(t, codes.DataLoss, grpc.Code(err))
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"github.com/theupdateframework/notary/signer"
+	"github.com/theupdateframework/notary/signer/policy"
+	"github.com/theupdateframework/notary/trustmanager"
+	"google.golang.org/grpc/codes"
+)
+
+// keyPolicyGetter is implemented by the crypto services which store policies
+// for their keys
+type keyPolicyGetter interface {
+	GetKeyPolicy(keyID string) (*policy.KeyPolicy, error)
+}
+
+// findKeyPolicy returns the key's own policy, or nil if it does not have one
+func findKeyPolicy(cryptoServices signer.CryptoServiceIndex, keyID string) (*policy.KeyPolicy, error) {
+	for _, service := range cryptoServices {
+		getter, ok := service.(keyPolicyGetter)
+		if !ok {
+			continue
+		}
+		keyPolicy, err := getter.GetKeyPolicy(keyID)
+		if _, notFound := err.(trustmanager.ErrKeyNotFound); notFound {
+			continue
+		}
+		return keyPolicy, err
+	}
+	return nil, nil
+}
+
+// policyViolationCode returns the GRPC status code for a policy violation
+func policyViolationCode(err error) codes.Code {
+	violation, ok := err.(policy.ErrViolation)
+	if !ok {
+		return codes.Internal
+	}
+	switch violation.Reason {
+	case policy.ReasonRateLimit:
+		return codes.ResourceExhausted
+	case policy.ReasonContent:
+		return codes.InvalidArgument
+	default:
+		return codes.PermissionDenied
+	}
+}
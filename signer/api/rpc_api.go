@@ -3,9 +3,12 @@ package api
 import (
 	"crypto/rand"
 	"fmt"
+	"time"
 
 	ctxu "github.com/docker/distribution/context"
 	"github.com/theupdateframework/notary/signer"
+	"github.com/theupdateframework/notary/signer/audit"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 	"golang.org/x/net/context"
@@ -16,14 +19,26 @@ import (
 	pb "github.com/theupdateframework/notary/proto"
 )
 
+// Limits on the number of audit events returned by ListAuditEvents
+const (
+	defaultAuditEventsLimit = 100
+	maxAuditEventsLimit     = 1000
+)
+
 //KeyManagementServer implements the KeyManagementServer grpc interface
 type KeyManagementServer struct {
 	CryptoServices signer.CryptoServiceIndex
+	// AuditLog, if set, records every key creation and deletion
+	AuditLog *audit.Log
 }
 
 //SignerServer implements the SignerServer grpc interface
 type SignerServer struct {
 	CryptoServices signer.CryptoServiceIndex
+	// AuditLog, if set, records every signature
+	AuditLog *audit.Log
+	// Policy, if set, restricts what each key may sign
+	Policy *policy.Enforcer
 }
 
 //CreateKey returns a PublicKey created using KeyManagementServer's SigningService
@@ -47,6 +62,11 @@ func (s *KeyManagementServer) CreateKey(ctx context.Context, req *pb.CreateKeyRe
 	}
 	logger.Info("CreateKey: Created KeyID ", tufKey.ID())
 
+	keyInfo := trustmanager.KeyInfo{Gun: data.GUN(req.Gun), Role: data.RoleName(req.Role)}
+	if err := recordAuditEvent(ctx, s.AuditLog, audit.ActionCreateKey, tufKey.ID(), keyInfo, ""); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "Key creation for KeyID %s could not be audited", tufKey.ID())
+	}
+
 	return &pb.PublicKey{
 		KeyInfo: &pb.KeyInfo{
 			KeyID:     &pb.KeyID{ID: tufKey.ID()},
@@ -59,6 +79,11 @@ func (s *KeyManagementServer) CreateKey(ctx context.Context, req *pb.CreateKeyRe
 //DeleteKey deletes they key associated with a KeyID
 func (s *KeyManagementServer) DeleteKey(ctx context.Context, keyID *pb.KeyID) (*pb.Void, error) {
 	logger := ctxu.GetLogger(ctx)
+	// look the key up before it is gone, for the audit log
+	var keyInfo trustmanager.KeyInfo
+	if s.AuditLog != nil {
+		keyInfo = findKeyInfo(s.CryptoServices, keyID.ID)
+	}
 	// delete key ID from all services
 	for _, service := range s.CryptoServices {
 		if err := service.RemoveKey(keyID.ID); err != nil {
@@ -67,6 +92,10 @@ func (s *KeyManagementServer) DeleteKey(ctx context.Context, keyID *pb.KeyID) (*
 		}
 	}
 
+	if err := recordAuditEvent(ctx, s.AuditLog, audit.ActionDeleteKey, keyID.ID, keyInfo, ""); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "Key deletion for KeyID %s could not be audited", keyID.ID)
+	}
+
 	return &pb.Void{}, nil
 }
 
@@ -92,9 +121,55 @@ func (s *KeyManagementServer) GetKeyInfo(ctx context.Context, keyID *pb.KeyID) (
 	}, nil
 }
 
+//ListAuditEvents returns the audit events after the requested one, once it has verified that they have not been tampered with
+func (s *KeyManagementServer) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	logger := ctxu.GetLogger(ctx)
+
+	if s.AuditLog == nil {
+		return nil, grpc.Errorf(codes.Unimplemented, "audit log is not enabled")
+	}
+
+	limit := int(req.Limit)
+	switch {
+	case limit == 0:
+		limit = defaultAuditEventsLimit
+	case limit > maxAuditEventsLimit:
+		limit = maxAuditEventsLimit
+	}
+
+	events, err := s.AuditLog.List(req.After, limit)
+	switch err.(type) {
+	case nil:
+		break
+	case audit.ErrTampered:
+		logger.Errorf("ListAuditEvents: %s", err.Error())
+		return nil, grpc.Errorf(codes.DataLoss, err.Error())
+	default:
+		logger.Errorf("ListAuditEvents: failed to list audit events: %s", err.Error())
+		return nil, grpc.Errorf(codes.Internal, "Listing audit events failed")
+	}
+
+	resp := &pb.ListAuditEventsResponse{Events: make([]*pb.AuditEvent, 0, len(events))}
+	for _, event := range events {
+		resp.Events = append(resp.Events, &pb.AuditEvent{
+			Sequence:     event.Sequence,
+			Timestamp:    event.Timestamp.Format(time.RFC3339),
+			Action:       event.Action,
+			KeyID:        event.KeyID,
+			Gun:          event.GUN.String(),
+			Role:         event.Role.String(),
+			Client:       event.Client,
+			Digest:       event.Digest,
+			PreviousHash: event.PreviousHash,
+			Hash:         event.Hash,
+		})
+	}
+	return resp, nil
+}
+
 //Sign signs a message and returns the signature using a private key associate with the KeyID from the SignatureRequest
 func (s *SignerServer) Sign(ctx context.Context, sr *pb.SignatureRequest) (*pb.Signature, error) {
-	privKey, _, err := findKeyByID(s.CryptoServices, sr.KeyID)
+	privKey, role, err := findKeyByID(s.CryptoServices, sr.KeyID)
 
 	logger := ctxu.GetLogger(ctx)
 
@@ -110,6 +185,24 @@ func (s *SignerServer) Sign(ctx context.Context, sr *pb.SignatureRequest) (*pb.S
 
 	}
 
+	var keyInfo trustmanager.KeyInfo
+	if s.AuditLog != nil || s.Policy != nil {
+		keyInfo = findKeyInfo(s.CryptoServices, sr.KeyID.ID)
+		keyInfo.Role = role
+	}
+
+	if s.Policy != nil {
+		keyPolicy, err := findKeyPolicy(s.CryptoServices, sr.KeyID.ID)
+		if err != nil {
+			logger.Errorf("Sign: getting the policy of key %s failed: %s", sr.KeyID.ID, err.Error())
+			return nil, grpc.Errorf(codes.Internal, "Getting the policy for KeyID %s failed", sr.KeyID.ID)
+		}
+		if err := s.Policy.Check(sr.KeyID.ID, keyInfo, keyPolicy, sr.Content); err != nil {
+			logger.Errorf("Sign: %s", err.Error())
+			return nil, grpc.Errorf(policyViolationCode(err), err.Error())
+		}
+	}
+
 	sig, err := privKey.Sign(rand.Reader, sr.Content, nil)
 	if err != nil {
 		logger.Errorf("Sign: signing failed for KeyID %s on hash %s", sr.KeyID.ID, sr.Content)
@@ -118,6 +211,10 @@ func (s *SignerServer) Sign(ctx context.Context, sr *pb.SignatureRequest) (*pb.S
 
 	logger.Info("Sign: Signed ", string(sr.Content), " with KeyID ", sr.KeyID.ID)
 
+	if err := recordAuditEvent(ctx, s.AuditLog, audit.ActionSign, sr.KeyID.ID, keyInfo, payloadDigest(sr.Content)); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "Signing for KeyID %s could not be audited", sr.KeyID.ID)
+	}
+
 	signature := &pb.Signature{
 		KeyInfo: &pb.KeyInfo{
 			KeyID:     &pb.KeyID{ID: privKey.ID()},
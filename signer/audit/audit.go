@@ -0,0 +1,180 @@
+// Package audit implements notary-signer's append-only audit trail of key
+// creations, key deletions and signatures.
+//
+// Every event records the hash of the event before it, and its own hash covers
+// all of its fields including that previous hash, so the events form a hash
+// chain.  Modifying, removing or reordering any stored event breaks the chain
+// from that point on, which Verify detects.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// The actions which are recorded in the audit trail
+const (
+	ActionCreateKey = "CreateKey"
+	ActionDeleteKey = "DeleteKey"
+	ActionSign      = "Sign"
+)
+
+// maxAppendAttempts is how many times an event is re-chained when another
+// signer sharing the same database appends an event at the same time
+const maxAppendAttempts = 5
+
+// Event is a single entry in the audit trail
+type Event struct {
+	// Sequence is the position of the event in the trail, starting at 1
+	Sequence  uint64
+	Timestamp time.Time
+	Action    string
+	KeyID     string
+	GUN       data.GUN
+	Role      data.RoleName
+	// Client is the identity of the caller, taken from its TLS client
+	// certificate
+	Client string
+	// Digest is the hex encoded SHA256 digest of the signed payload, if any
+	Digest       string
+	PreviousHash string
+	Hash         string
+}
+
+// ComputeHash returns the hash of every field of the event other than Hash
+func (e Event) ComputeHash() string {
+	// canonical JSON of a list of strings is an unambiguous encoding
+	encoded, err := json.MarshalCanonical([]string{
+		strconv.FormatUint(e.Sequence, 10),
+		e.Timestamp.UTC().Format(time.RFC3339Nano),
+		e.Action,
+		e.KeyID,
+		e.GUN.String(),
+		e.Role.String(),
+		e.Client,
+		e.Digest,
+		e.PreviousHash,
+	})
+	if err != nil {
+		// marshalling a list of strings cannot fail
+		panic(err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrTampered is returned when the audit trail's hash chain is broken
+type ErrTampered struct {
+	Sequence uint64
+	Reason   string
+}
+
+func (err ErrTampered) Error() string {
+	return fmt.Sprintf("audit event %d has been tampered with: %s", err.Sequence, err.Reason)
+}
+
+// Verify checks that events form an unbroken hash chain following previous,
+// which is the event before the first one in events, or nil if events starts
+// at the beginning of the trail.
+func Verify(previous *Event, events []Event) error {
+	for _, event := range events {
+		expectedSequence, expectedHash := uint64(1), ""
+		if previous != nil {
+			expectedSequence, expectedHash = previous.Sequence+1, previous.Hash
+		}
+		switch {
+		case event.Sequence != expectedSequence:
+			return ErrTampered{Sequence: expectedSequence, Reason: "event is missing"}
+		case event.PreviousHash != expectedHash:
+			return ErrTampered{Sequence: event.Sequence, Reason: "previous hash does not match"}
+		case event.Hash != event.ComputeHash():
+			return ErrTampered{Sequence: event.Sequence, Reason: "hash does not match"}
+		}
+		e := event
+		previous = &e
+	}
+	return nil
+}
+
+// Store persists audit events
+type Store interface {
+	// LastAuditEvent returns the most recent event, or nil if there are none
+	LastAuditEvent() (*Event, error)
+	// AddAuditEvent stores an event.  It must fail if an event with the same
+	// sequence number already exists.
+	AddAuditEvent(event Event) error
+	// GetAuditEvents returns up to limit events, in order, starting with the
+	// one after the event with sequence number after
+	GetAuditEvents(after uint64, limit int) ([]Event, error)
+}
+
+// Log appends events to a Store, chaining each one to the last
+type Log struct {
+	store   Store
+	lock    sync.Mutex
+	nowFunc func() time.Time
+}
+
+// NewLog returns a Log which records events in store
+func NewLog(store Store) *Log {
+	return &Log{store: store, nowFunc: time.Now}
+}
+
+// Record sets the sequence number, timestamp and hashes of event, and appends
+// it to the trail
+func (l *Log) Record(event Event) (Event, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var err error
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		var last *Event
+		last, err = l.store.LastAuditEvent()
+		if err != nil {
+			return Event{}, err
+		}
+		event.Sequence, event.PreviousHash = 1, ""
+		if last != nil {
+			event.Sequence, event.PreviousHash = last.Sequence+1, last.Hash
+		}
+		// not all databases store sub-second precision
+		event.Timestamp = l.nowFunc().UTC().Truncate(time.Second)
+		event.Hash = event.ComputeHash()
+		if err = l.store.AddAuditEvent(event); err == nil {
+			return event, nil
+		}
+	}
+	return Event{}, fmt.Errorf("failed to record audit event: %v", err)
+}
+
+// List returns up to limit events starting with the one after the event with
+// sequence number after, once it has verified that they follow on from it
+func (l *Log) List(after uint64, limit int) ([]Event, error) {
+	var previous *Event
+	start := after
+	if after > 0 {
+		start--
+		limit++
+	}
+	events, err := l.store.GetAuditEvents(start, limit)
+	if err != nil {
+		return nil, err
+	}
+	if after > 0 && len(events) > 0 {
+		if events[0].Sequence != after {
+			return nil, ErrTampered{Sequence: after, Reason: "event is missing"}
+		}
+		previous, events = &events[0], events[1:]
+	}
+	if err := Verify(previous, events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
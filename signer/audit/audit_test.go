@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var auditTime = time.Date(2017, 1, 2, 3, 4, 5, 6, time.UTC)
+
+func recordEvents(t *testing.T, l *Log, n int) []Event {
+	var events []Event
+	for i := 0; i < n; i++ {
+		event, err := l.Record(Event{
+			Action: ActionSign,
+			KeyID:  "keyid",
+			GUN:    "docker.com/notary",
+			Role:   data.CanonicalTimestampRole,
+			Client: "notary-server",
+			Digest: "abcd",
+		})
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestRecordChainsEvents(t *testing.T) {
+	store := NewMemoryStore()
+	l := NewLog(store)
+	l.nowFunc = func() time.Time { return auditTime }
+
+	events := recordEvents(t, l, 3)
+	for i, event := range events {
+		require.Equal(t, uint64(i+1), event.Sequence)
+		require.Equal(t, auditTime.Truncate(time.Second), event.Timestamp)
+		require.Equal(t, event.ComputeHash(), event.Hash)
+		if i == 0 {
+			require.Empty(t, event.PreviousHash)
+		} else {
+			require.Equal(t, events[i-1].Hash, event.PreviousHash)
+		}
+	}
+	// identical events still have different hashes
+	require.NotEqual(t, events[0].Hash, events[1].Hash)
+	require.NoError(t, Verify(nil, events))
+
+	listed, err := l.List(0, 10)
+	require.NoError(t, err)
+	require.Equal(t, events, listed)
+
+	listed, err = l.List(1, 1)
+	require.NoError(t, err)
+	require.Equal(t, events[1:2], listed)
+
+	listed, err = l.List(3, 10)
+	require.NoError(t, err)
+	require.Empty(t, listed)
+}
+
+// Another writer appending to the same store between reading the last event
+// and adding a new one just makes the log re-chain the new event
+func TestRecordRetriesConflicts(t *testing.T) {
+	store := NewMemoryStore()
+	l := NewLog(store)
+	other := NewLog(store)
+	racing := &racingStore{MemoryStore: store, race: func() { recordEvents(t, other, 1) }}
+	l.store = racing
+
+	event, err := l.Record(Event{Action: ActionCreateKey, KeyID: "keyid"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), event.Sequence)
+
+	listed, err := l.List(0, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	require.Equal(t, event, listed[1])
+}
+
+type racingStore struct {
+	*MemoryStore
+	race func()
+}
+
+func (r *racingStore) AddAuditEvent(event Event) error {
+	if r.race != nil {
+		race := r.race
+		r.race = nil
+		race()
+	}
+	return r.MemoryStore.AddAuditEvent(event)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	store := NewMemoryStore()
+	events := recordEvents(t, NewLog(store), 4)
+
+	copyEvents := func() []Event {
+		return append([]Event(nil), events...)
+	}
+
+	modified := copyEvents()
+	modified[1].KeyID = "otherkey"
+	require.Equal(t, ErrTampered{Sequence: 2, Reason: "hash does not match"}, Verify(nil, modified))
+
+	// recomputing the modified event's hash breaks the link to the next one
+	modified[1].Hash = modified[1].ComputeHash()
+	require.Equal(t, ErrTampered{Sequence: 3, Reason: "previous hash does not match"}, Verify(nil, modified))
+
+	removed := append(copyEvents()[:1], events[2:]...)
+	require.Equal(t, ErrTampered{Sequence: 2, Reason: "event is missing"}, Verify(nil, removed))
+
+	reordered := copyEvents()
+	reordered[1], reordered[2] = reordered[2], reordered[1]
+	require.Error(t, Verify(nil, reordered))
+
+	require.NoError(t, Verify(&events[1], events[2:]))
+	require.Error(t, Verify(&events[0], events[2:]))
+
+	// List detects tampering in the stored events too
+	store.events[2].Client = "someone else"
+	l := NewLog(store)
+	_, err := l.List(0, 10)
+	require.IsType(t, ErrTampered{}, err)
+	_, err = l.List(1, 1)
+	require.NoError(t, err)
+	_, err = l.List(2, 10)
+	require.IsType(t, ErrTampered{}, err)
+	store.events = append(store.events[:1], store.events[2:]...)
+	_, err = l.List(1, 10)
+	require.Equal(t, ErrTampered{Sequence: 2, Reason: "event is missing"}, err)
+	_, err = l.List(2, 10)
+	require.Equal(t, ErrTampered{Sequence: 2, Reason: "event is missing"}, err)
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryStore is an in-memory Store, which does not survive restarts
+type MemoryStore struct {
+	lock   sync.RWMutex
+	events []Event
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// LastAuditEvent returns the most recent event, or nil if there are none
+func (m *MemoryStore) LastAuditEvent() (*Event, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if len(m.events) == 0 {
+		return nil, nil
+	}
+	last := m.events[len(m.events)-1]
+	return &last, nil
+}
+
+// AddAuditEvent stores an event, which must directly follow the last one
+func (m *MemoryStore) AddAuditEvent(event Event) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if event.Sequence != uint64(len(m.events))+1 {
+		return fmt.Errorf("audit event %d does not follow event %d", event.Sequence, len(m.events))
+	}
+	m.events = append(m.events, event)
+	return nil
+}
+
+// GetAuditEvents returns up to limit events, in order, starting with the one
+// after the event with sequence number after
+func (m *MemoryStore) GetAuditEvents(after uint64, limit int) ([]Event, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var events []Event
+	for _, event := range m.events {
+		if limit >= 0 && len(events) >= limit {
+			break
+		}
+		if event.Sequence > after {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -80,6 +80,8 @@ func (pk *RemotePrivateKey) SignatureAlgorithm() data.SigAlgorithm {
 	switch pk.PublicKey.Algorithm() {
 	case data.ECDSAKey, data.ECDSAx509Key:
 		return data.ECDSASignature
+	case data.ECDSAP384Key:
+		return data.ECDSAP384Signature
 	case data.RSAKey, data.RSAx509Key:
 		return data.RSAPSSSignature
 	case data.ED25519Key:
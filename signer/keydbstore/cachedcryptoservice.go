@@ -1,8 +1,11 @@
 package keydbstore
 
 import (
+	"fmt"
 	"sync"
 
+	"github.com/theupdateframework/notary/signer/policy"
+	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
 )
@@ -74,3 +77,27 @@ func (s *cachedKeyService) RemoveKey(keyID string) error {
 	delete(s.cachedKeys, keyID)
 	return s.CryptoService.RemoveKey(keyID)
 }
+
+// GetKeyInfo returns the GUN and role of a key from the underlying store, if
+// it can provide them
+func (s *cachedKeyService) GetKeyInfo(keyID string) (trustmanager.KeyInfo, error) {
+	infoGetter, ok := s.CryptoService.(interface {
+		GetKeyInfo(keyID string) (trustmanager.KeyInfo, error)
+	})
+	if !ok {
+		return trustmanager.KeyInfo{}, fmt.Errorf("%T does not record key information", s.CryptoService)
+	}
+	return infoGetter.GetKeyInfo(keyID)
+}
+
+// GetKeyPolicy returns the key's own policy from the underlying store, or nil
+// if the store does not record policies
+func (s *cachedKeyService) GetKeyPolicy(keyID string) (*policy.KeyPolicy, error) {
+	policyGetter, ok := s.CryptoService.(interface {
+		GetKeyPolicy(keyID string) (*policy.KeyPolicy, error)
+	})
+	if !ok {
+		return nil, nil
+	}
+	return policyGetter.GetKeyPolicy(keyID)
+}
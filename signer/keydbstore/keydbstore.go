@@ -3,10 +3,12 @@ package keydbstore
 import (
 	"crypto"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
 
 	"github.com/sirupsen/logrus"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/utils"
 )
@@ -29,7 +31,7 @@ func (a activatingPrivateKey) Sign(rand io.Reader, digest []byte, opts crypto.Si
 }
 
 // helper function to generate private keys for the signer databases - does not implement RSA since that is not
-// supported by the signer
+// supported by the signer.  ECDSA keys may be on either the P-256 (ecdsa) or P-384 (ecdsa-p384) curve.
 func generatePrivateKey(algorithm string) (data.PrivateKey, error) {
 	var privKey data.PrivateKey
 	var err error
@@ -39,6 +41,11 @@ func generatePrivateKey(algorithm string) (data.PrivateKey, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate EC key: %v", err)
 		}
+	case data.ECDSAP384Key:
+		privKey, err = utils.GenerateECDSAP384Key(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate EC P-384 key: %v", err)
+		}
 	case data.ED25519Key:
 		privKey, err = utils.GenerateED25519Key(rand.Reader)
 		if err != nil {
@@ -49,3 +56,32 @@ func generatePrivateKey(algorithm string) (data.PrivateKey, error) {
 	}
 	return privKey, nil
 }
+
+// encodePolicy serializes a key policy for the database, where an empty string
+// means that the key has no policy of its own
+func encodePolicy(keyPolicy *policy.KeyPolicy) (string, error) {
+	if keyPolicy == nil {
+		return "", nil
+	}
+	if err := keyPolicy.Validate(); err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(keyPolicy)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// decodePolicy parses a key policy from the database, returning nil if the key
+// has no policy of its own
+func decodePolicy(keyID, encoded string) (*policy.KeyPolicy, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	keyPolicy := &policy.KeyPolicy{}
+	if err := json.Unmarshal([]byte(encoded), keyPolicy); err != nil {
+		return nil, fmt.Errorf("invalid policy for key %s: %v", keyID, err)
+	}
+	return keyPolicy, nil
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/signer/audit"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
 	"github.com/theupdateframework/notary/tuf/utils"
@@ -222,6 +223,22 @@ func testCreateKey(t *testing.T, dbStore signed.CryptoService) (data.PrivateKey,
 	return activeED25519Key, inactiveED25519Key, inactiveECDSAKey
 }
 
+func testCreateP384Key(t *testing.T, dbStore signed.CryptoService) {
+	createdKey, err := dbStore.Create(data.CanonicalTimestampRole, "gun", data.ECDSAP384Key)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAP384Key, createdKey.Algorithm())
+	requireGetPubKeySuccess(t, dbStore, data.CanonicalTimestampRole.String(), createdKey)
+
+	privKey, _, err := dbStore.GetPrivateKey(createdKey.ID())
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAP384Signature, privKey.SignatureAlgorithm())
+
+	msg := []byte("successful")
+	sig, err := privKey.Sign(rand.Reader, msg, nil)
+	require.NoError(t, err)
+	require.NoError(t, signed.Verifiers[data.ECDSAP384Signature].Verify(createdKey, sig, msg))
+}
+
 func testUnimplementedInterfaceMethods(t *testing.T, dbStore signed.CryptoService) {
 	// add one key to the db
 	testKey, err := utils.GenerateECDSAKey(rand.Reader)
@@ -234,3 +251,39 @@ func testUnimplementedInterfaceMethods(t *testing.T, dbStore signed.CryptoServic
 	require.Nil(t, dbStore.ListAllKeys())
 	require.Nil(t, dbStore.ListKeys(data.CanonicalTimestampRole))
 }
+
+// audit events are stored in order, and a second event with the same sequence
+// number cannot be added
+func testAuditEvents(t *testing.T, store audit.Store) {
+	last, err := store.LastAuditEvent()
+	require.NoError(t, err)
+	require.Nil(t, last)
+
+	l := audit.NewLog(store)
+	var events []audit.Event
+	for _, action := range []string{audit.ActionCreateKey, audit.ActionSign, audit.ActionDeleteKey} {
+		event, err := l.Record(audit.Event{
+			Action: action,
+			KeyID:  "keyid",
+			GUN:    "gun",
+			Role:   data.CanonicalTimestampRole,
+			Client: "notary-server",
+		})
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+
+	last, err = store.LastAuditEvent()
+	require.NoError(t, err)
+	require.Equal(t, events[2], *last)
+
+	require.Error(t, store.AddAuditEvent(events[1]))
+
+	stored, err := store.GetAuditEvents(0, 10)
+	require.NoError(t, err)
+	require.Equal(t, events, stored)
+	stored, err = store.GetAuditEvents(1, 1)
+	require.NoError(t, err)
+	require.Equal(t, events[1:2], stored)
+	require.NoError(t, audit.Verify(nil, events))
+}
@@ -0,0 +1,121 @@
+package keydbstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/theupdateframework/notary/signer/audit"
+	"github.com/theupdateframework/notary/storage/rethinkdb"
+	"github.com/theupdateframework/notary/tuf/data"
+	gorethink "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// RDBAuditEvent represents an audit.Event in the rethink database
+type RDBAuditEvent struct {
+	Sequence     uint64        `gorethink:"sequence"`
+	Timestamp    time.Time     `gorethink:"timestamp"`
+	Action       string        `gorethink:"action"`
+	KeyID        string        `gorethink:"key_id"`
+	Gun          data.GUN      `gorethink:"gun"`
+	Role         data.RoleName `gorethink:"role"`
+	Client       string        `gorethink:"client"`
+	Digest       string        `gorethink:"digest"`
+	PreviousHash string        `gorethink:"previous_hash"`
+	Hash         string        `gorethink:"hash"`
+}
+
+// AuditEventsRethinkTable is the table definition for notary signer's audit
+// events.  The sequence number is the primary key, so two events with the
+// same number can never both be inserted.
+var AuditEventsRethinkTable = rethinkdb.Table{
+	Name:       RDBAuditEvent{}.TableName(),
+	PrimaryKey: "sequence",
+	Config: map[string]string{
+		"write_acks": "majority",
+	},
+}
+
+// TableName sets a specific table name for our RDBAuditEvent
+func (r RDBAuditEvent) TableName() string {
+	return "audit_events"
+}
+
+func (r RDBAuditEvent) toEvent() audit.Event {
+	return audit.Event{
+		Sequence:     r.Sequence,
+		Timestamp:    r.Timestamp.UTC(),
+		Action:       r.Action,
+		KeyID:        r.KeyID,
+		GUN:          r.Gun,
+		Role:         r.Role,
+		Client:       r.Client,
+		Digest:       r.Digest,
+		PreviousHash: r.PreviousHash,
+		Hash:         r.Hash,
+	}
+}
+
+// LastAuditEvent returns the most recent audit event, or nil if there are none
+func (rdb RethinkDBKeyStore) LastAuditEvent() (*audit.Event, error) {
+	res, err := gorethink.DB(rdb.dbName).Table(AuditEventsRethinkTable.Name).
+		OrderBy(gorethink.OrderByOpts{Index: gorethink.Desc("sequence")}).Limit(1).Run(rdb.sess)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var dbEvent RDBAuditEvent
+	if err := res.One(&dbEvent); err != nil {
+		if err == gorethink.ErrEmptyResult {
+			return nil, nil
+		}
+		return nil, err
+	}
+	event := dbEvent.toEvent()
+	return &event, nil
+}
+
+// AddAuditEvent stores an audit event.  The sequence number is the primary
+// key, so this fails if another signer has already stored an event with the
+// same number.
+func (rdb RethinkDBKeyStore) AddAuditEvent(event audit.Event) error {
+	dbEvent := RDBAuditEvent{
+		Sequence:     event.Sequence,
+		Timestamp:    event.Timestamp,
+		Action:       event.Action,
+		KeyID:        event.KeyID,
+		Gun:          event.GUN,
+		Role:         event.Role,
+		Client:       event.Client,
+		Digest:       event.Digest,
+		PreviousHash: event.PreviousHash,
+		Hash:         event.Hash,
+	}
+	_, err := gorethink.DB(rdb.dbName).Table(dbEvent.TableName()).Insert(dbEvent).RunWrite(rdb.sess)
+	if err != nil {
+		return fmt.Errorf("failed to add audit event %d to database: %s", event.Sequence, err.Error())
+	}
+	return nil
+}
+
+// GetAuditEvents returns up to limit audit events, in order, starting with
+// the one after the event with sequence number after
+func (rdb RethinkDBKeyStore) GetAuditEvents(after uint64, limit int) ([]audit.Event, error) {
+	res, err := gorethink.DB(rdb.dbName).Table(AuditEventsRethinkTable.Name).
+		Between(after, gorethink.MaxVal, gorethink.BetweenOpts{LeftBound: "open"}).
+		OrderBy(gorethink.OrderByOpts{Index: "sequence"}).Limit(limit).Run(rdb.sess)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var dbEvents []RDBAuditEvent
+	if err := res.All(&dbEvents); err != nil {
+		return nil, err
+	}
+	events := make([]audit.Event, 0, len(dbEvents))
+	for _, dbEvent := range dbEvents {
+		events = append(events, dbEvent.toEvent())
+	}
+	return events, nil
+}
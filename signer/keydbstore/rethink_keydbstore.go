@@ -7,6 +7,7 @@ import (
 
 	jose "github.com/dvsekhvalnov/jose2go"
 	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/storage/rethinkdb"
 	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
@@ -45,6 +46,9 @@ type RDBPrivateKey struct {
 
 	// whether this key is active or not
 	LastUsed time.Time `gorethink:"last_used"`
+
+	// the JSON encoded policy.KeyPolicy restricting the key, if any
+	Policy string `gorethink:"policy"`
 }
 
 // gorethink can't handle an UnmarshalJSON function (see https://github.com/gorethink/gorethink/issues/201),
@@ -64,6 +68,7 @@ func rdbPrivateKeyFromJSON(jsonData []byte) (interface{}, error) {
 		Public          []byte        `json:"public"`
 		Private         []byte        `json:"private"`
 		LastUsed        time.Time     `json:"last_used"`
+		Policy          string        `json:"policy"`
 	}{}
 	if err := json.Unmarshal(jsonData, &a); err != nil {
 		return RDBPrivateKey{}, err
@@ -84,6 +89,7 @@ func rdbPrivateKeyFromJSON(jsonData []byte) (interface{}, error) {
 		Public:          a.Public,
 		Private:         a.Private,
 		LastUsed:        a.LastUsed,
+		Policy:          a.Policy,
 	}, nil
 
 }
@@ -215,6 +221,42 @@ func (rdb *RethinkDBKeyStore) GetKey(keyID string) data.PublicKey {
 	return data.NewPublicKey(dbPrivateKey.Algorithm, dbPrivateKey.Public)
 }
 
+// GetKeyInfo returns the GUN and role of a key
+func (rdb *RethinkDBKeyStore) GetKeyInfo(keyID string) (trustmanager.KeyInfo, error) {
+	dbPrivateKey, _, err := rdb.getKey(keyID)
+	if err != nil {
+		return trustmanager.KeyInfo{}, err
+	}
+	return trustmanager.KeyInfo{Gun: dbPrivateKey.Gun, Role: dbPrivateKey.Role}, nil
+}
+
+// GetKeyPolicy returns the key's own policy, or nil if it does not have one
+func (rdb *RethinkDBKeyStore) GetKeyPolicy(keyID string) (*policy.KeyPolicy, error) {
+	dbPrivateKey, _, err := rdb.getKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return decodePolicy(keyID, dbPrivateKey.Policy)
+}
+
+// SetKeyPolicy sets the key's own policy, or removes it if keyPolicy is nil
+func (rdb *RethinkDBKeyStore) SetKeyPolicy(keyID string, keyPolicy *policy.KeyPolicy) error {
+	encoded, err := encodePolicy(keyPolicy)
+	if err != nil {
+		return err
+	}
+	res, err := gorethink.DB(rdb.dbName).Table(PrivateKeysRethinkTable.Name).Get(keyID).Update(map[string]interface{}{
+		"policy": encoded,
+	}).RunWrite(rdb.sess)
+	if err != nil {
+		return err
+	}
+	if res.Skipped > 0 {
+		return trustmanager.ErrKeyNotFound{KeyID: keyID}
+	}
+	return nil
+}
+
 // ListKeys always returns nil. This method is here to satisfy the CryptoService interface
 func (rdb RethinkDBKeyStore) ListKeys(role data.RoleName) []string {
 	return nil
@@ -310,6 +352,7 @@ func (rdb RethinkDBKeyStore) Create(role data.RoleName, gun data.GUN, algorithm
 func (rdb RethinkDBKeyStore) Bootstrap() error {
 	if err := rethinkdb.SetupDB(rdb.sess, rdb.dbName, []rethinkdb.Table{
 		PrivateKeysRethinkTable,
+		AuditEventsRethinkTable,
 	}); err != nil {
 		return err
 	}
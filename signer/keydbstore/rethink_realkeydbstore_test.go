@@ -13,10 +13,12 @@ import (
 	"github.com/docker/go-connections/tlsconfig"
 	"github.com/dvsekhvalnov/jose2go"
 	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/storage/rethinkdb"
 	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
+	"github.com/theupdateframework/notary/tuf/utils"
 	gorethink "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
@@ -40,7 +42,7 @@ func rethinkDBSetup(t *testing.T, dbName string) (*RethinkDBKeyStore, func()) {
 
 	cleanup()
 
-	err := rethinkdb.SetupDB(session, dbName, []rethinkdb.Table{PrivateKeysRethinkTable})
+	err := rethinkdb.SetupDB(session, dbName, []rethinkdb.Table{PrivateKeysRethinkTable, AuditEventsRethinkTable})
 	require.NoError(t, err)
 
 	dbStore := NewRethinkDBKeyStore(dbName, "", "", multiAliasRetriever, validAliases[0], session)
@@ -237,8 +239,45 @@ func TestRethinkCreateKey(t *testing.T) {
 	require.True(t, rdbKeys[pendingECDSAKey.ID()].LastUsed.Equal(time.Time{}))
 }
 
+func TestRethinkCreateP384Key(t *testing.T) {
+	dbStore, cleanup := rethinkDBSetup(t, "signerP384CreationTests")
+	defer cleanup()
+	testCreateP384Key(t, dbStore)
+}
+
 func TestRethinkUnimplementedInterfaceBehavior(t *testing.T) {
 	dbStore, cleanup := rethinkDBSetup(t, "signerInterfaceTests")
 	defer cleanup()
 	testUnimplementedInterfaceMethods(t, dbStore)
 }
+
+func TestRethinkAuditEvents(t *testing.T) {
+	dbStore, cleanup := rethinkDBSetup(t, "signerAuditEvents")
+	defer cleanup()
+	testAuditEvents(t, dbStore)
+}
+
+func TestRethinkKeyPolicy(t *testing.T) {
+	dbStore, cleanup := rethinkDBSetup(t, "signerKeyPolicy")
+	defer cleanup()
+
+	testKey, err := utils.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+	require.IsType(t, trustmanager.ErrKeyNotFound{}, dbStore.SetKeyPolicy(testKey.ID(), &policy.KeyPolicy{}))
+	require.NoError(t, dbStore.AddKey(data.CanonicalTimestampRole, "gun", testKey))
+
+	keyPolicy, err := dbStore.GetKeyPolicy(testKey.ID())
+	require.NoError(t, err)
+	require.Nil(t, keyPolicy)
+
+	expected := &policy.KeyPolicy{AllowedRoles: []data.RoleName{data.CanonicalTimestampRole}, CheckContent: true}
+	require.NoError(t, dbStore.SetKeyPolicy(testKey.ID(), expected))
+	keyPolicy, err = dbStore.GetKeyPolicy(testKey.ID())
+	require.NoError(t, err)
+	require.Equal(t, expected, keyPolicy)
+
+	require.NoError(t, dbStore.SetKeyPolicy(testKey.ID(), nil))
+	keyPolicy, err = dbStore.GetKeyPolicy(testKey.ID())
+	require.NoError(t, err)
+	require.Nil(t, keyPolicy)
+}
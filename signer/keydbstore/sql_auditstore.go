@@ -0,0 +1,94 @@
+package keydbstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/theupdateframework/notary/signer/audit"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// GormAuditEvent represents an audit.Event in the database
+type GormAuditEvent struct {
+	ID           uint      `gorm:"primary_key" sql:"not null"`
+	Sequence     uint64    `sql:"type:bigint;not null;unique;index:sequence_idx"`
+	Timestamp    time.Time `sql:"type:datetime;not null"`
+	Action       string    `sql:"type:varchar(50);not null"`
+	KeyID        string    `sql:"type:varchar(255);not null"`
+	Gun          string    `sql:"type:varchar(255);not null"`
+	Role         string    `sql:"type:varchar(255);not null"`
+	Client       string    `sql:"type:varchar(255);not null"`
+	Digest       string    `sql:"type:varchar(64);not null"`
+	PreviousHash string    `sql:"type:varchar(64);not null"`
+	Hash         string    `sql:"type:varchar(64);not null"`
+}
+
+// TableName sets a specific table name for our GormAuditEvent
+func (g GormAuditEvent) TableName() string {
+	return "audit_events"
+}
+
+func (g GormAuditEvent) toEvent() audit.Event {
+	return audit.Event{
+		Sequence:     g.Sequence,
+		Timestamp:    g.Timestamp.UTC(),
+		Action:       g.Action,
+		KeyID:        g.KeyID,
+		GUN:          data.GUN(g.Gun),
+		Role:         data.RoleName(g.Role),
+		Client:       g.Client,
+		Digest:       g.Digest,
+		PreviousHash: g.PreviousHash,
+		Hash:         g.Hash,
+	}
+}
+
+// LastAuditEvent returns the most recent audit event, or nil if there are none
+func (s *SQLKeyDBStore) LastAuditEvent() (*audit.Event, error) {
+	dbEvent := GormAuditEvent{}
+	query := s.db.Order("sequence desc").First(&dbEvent)
+	if query.RecordNotFound() {
+		return nil, nil
+	}
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	event := dbEvent.toEvent()
+	return &event, nil
+}
+
+// AddAuditEvent stores an audit event.  The sequence number is unique, so this
+// fails if another signer has already stored an event with the same number.
+func (s *SQLKeyDBStore) AddAuditEvent(event audit.Event) error {
+	dbEvent := GormAuditEvent{
+		Sequence:     event.Sequence,
+		Timestamp:    event.Timestamp,
+		Action:       event.Action,
+		KeyID:        event.KeyID,
+		Gun:          event.GUN.String(),
+		Role:         event.Role.String(),
+		Client:       event.Client,
+		Digest:       event.Digest,
+		PreviousHash: event.PreviousHash,
+		Hash:         event.Hash,
+	}
+	if err := s.db.Create(&dbEvent).Error; err != nil {
+		return fmt.Errorf("failed to add audit event %d to database: %v", event.Sequence, err)
+	}
+	return nil
+}
+
+// GetAuditEvents returns up to limit audit events, in order, starting with
+// the one after the event with sequence number after
+func (s *SQLKeyDBStore) GetAuditEvents(after uint64, limit int) ([]audit.Event, error) {
+	var dbEvents []GormAuditEvent
+	query := s.db.Where("sequence > ?", after).Order("sequence").Limit(limit).Find(&dbEvents)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	events := make([]audit.Event, 0, len(dbEvents))
+	for _, dbEvent := range dbEvents {
+		events = append(events, dbEvent.toEvent())
+	}
+	return events, nil
+}
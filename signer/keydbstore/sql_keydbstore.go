@@ -7,6 +7,7 @@ import (
 	jose "github.com/dvsekhvalnov/jose2go"
 	"github.com/jinzhu/gorm"
 	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 )
@@ -39,6 +40,8 @@ type GormPrivateKey struct {
 	Public          string    `sql:"type:blob;not null"`
 	Private         string    `sql:"type:blob;not null"`
 	LastUsed        time.Time `sql:"type:datetime;null;default:null"`
+	// Policy is the JSON encoded policy.KeyPolicy restricting the key, if any
+	Policy string `sql:"type:varchar(2048);not null;default:''"`
 }
 
 // TableName sets a specific table name for our GormPrivateKey
@@ -228,6 +231,41 @@ func (s *SQLKeyDBStore) GetKey(keyID string) data.PublicKey {
 	return data.NewPublicKey(privKey.Algorithm, []byte(privKey.Public))
 }
 
+// GetKeyInfo returns the GUN and role of a key, without decrypting it
+func (s *SQLKeyDBStore) GetKeyInfo(keyID string) (trustmanager.KeyInfo, error) {
+	dbPrivateKey := GormPrivateKey{}
+	if s.db.Where(&GormPrivateKey{KeyID: keyID}).First(&dbPrivateKey).RecordNotFound() {
+		return trustmanager.KeyInfo{}, trustmanager.ErrKeyNotFound{KeyID: keyID}
+	}
+	return trustmanager.KeyInfo{Gun: data.GUN(dbPrivateKey.Gun), Role: data.RoleName(dbPrivateKey.Role)}, nil
+}
+
+// GetKeyPolicy returns the key's own policy, or nil if it does not have one
+func (s *SQLKeyDBStore) GetKeyPolicy(keyID string) (*policy.KeyPolicy, error) {
+	dbPrivateKey := GormPrivateKey{}
+	if s.db.Where(&GormPrivateKey{KeyID: keyID}).First(&dbPrivateKey).RecordNotFound() {
+		return nil, trustmanager.ErrKeyNotFound{KeyID: keyID}
+	}
+	return decodePolicy(keyID, dbPrivateKey.Policy)
+}
+
+// SetKeyPolicy sets the key's own policy, or removes it if keyPolicy is nil
+func (s *SQLKeyDBStore) SetKeyPolicy(keyID string, keyPolicy *policy.KeyPolicy) error {
+	encoded, err := encodePolicy(keyPolicy)
+	if err != nil {
+		return err
+	}
+	// use a map so that an empty policy is not skipped as a zero value
+	query := s.db.Model(GormPrivateKey{}).Where("key_id = ?", keyID).Updates(map[string]interface{}{"policy": encoded})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return trustmanager.ErrKeyNotFound{KeyID: keyID}
+	}
+	return nil
+}
+
 // HealthCheck verifies that DB exists and is query-able
 func (s *SQLKeyDBStore) HealthCheck() error {
 	dbPrivateKey := GormPrivateKey{}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/dvsekhvalnov/jose2go"
 	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/signer/policy"
+	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
 )
@@ -23,6 +25,7 @@ func SetupSQLDB(t *testing.T, dbtype, dburl string) *SQLKeyDBStore {
 
 	// Create the DB tables if they don't exist
 	dbStore.db.CreateTable(&GormPrivateKey{})
+	dbStore.db.CreateTable(&GormAuditEvent{})
 
 	// verify that the table is empty
 	var count int
@@ -183,8 +186,63 @@ func TestSQLCreateKey(t *testing.T) {
 	require.True(t, gormKeys[pendingECDSAKey.ID()].LastUsed.Equal(time.Time{}))
 }
 
+func TestSQLCreateP384Key(t *testing.T) {
+	dbStore, cleanup := sqldbSetup(t)
+	defer cleanup()
+	testCreateP384Key(t, dbStore)
+}
+
 func TestSQLUnimplementedInterfaceBehavior(t *testing.T) {
 	dbStore, cleanup := sqldbSetup(t)
 	defer cleanup()
 	testUnimplementedInterfaceMethods(t, dbStore)
 }
+
+func TestSQLAuditEvents(t *testing.T) {
+	dbStore, cleanup := sqldbSetup(t)
+	defer cleanup()
+	testAuditEvents(t, dbStore)
+}
+
+func TestSQLKeyPolicy(t *testing.T) {
+	dbStore, cleanup := sqldbSetup(t)
+	defer cleanup()
+
+	_, err := dbStore.GetKeyPolicy("keyid")
+	require.IsType(t, trustmanager.ErrKeyNotFound{}, err)
+	require.IsType(t, trustmanager.ErrKeyNotFound{}, dbStore.SetKeyPolicy("keyid", &policy.KeyPolicy{}))
+
+	// the key's contents do not matter, so add it without encrypting anything
+	require.NoError(t, dbStore.db.Create(&GormPrivateKey{KeyID: "keyid", Gun: "gun", Role: "timestamp"}).Error)
+	keyPolicy, err := dbStore.GetKeyPolicy("keyid")
+	require.NoError(t, err)
+	require.Nil(t, keyPolicy)
+
+	expected := &policy.KeyPolicy{
+		AllowedGUNPrefixes:     []string{"docker.io/"},
+		AllowedRoles:           []data.RoleName{data.CanonicalTimestampRole},
+		MaxSignaturesPerMinute: 10,
+		CheckContent:           true,
+	}
+	require.NoError(t, dbStore.SetKeyPolicy("keyid", expected))
+	keyPolicy, err = dbStore.GetKeyPolicy("keyid")
+	require.NoError(t, err)
+	require.Equal(t, expected, keyPolicy)
+
+	// an empty policy is different from no policy
+	require.NoError(t, dbStore.SetKeyPolicy("keyid", &policy.KeyPolicy{}))
+	keyPolicy, err = dbStore.GetKeyPolicy("keyid")
+	require.NoError(t, err)
+	require.Equal(t, &policy.KeyPolicy{}, keyPolicy)
+
+	require.NoError(t, dbStore.SetKeyPolicy("keyid", nil))
+	keyPolicy, err = dbStore.GetKeyPolicy("keyid")
+	require.NoError(t, err)
+	require.Nil(t, keyPolicy)
+
+	require.Error(t, dbStore.SetKeyPolicy("keyid", &policy.KeyPolicy{MaxSignaturesPerMinute: -1}))
+
+	info, err := dbStore.GetKeyInfo("keyid")
+	require.NoError(t, err)
+	require.Equal(t, trustmanager.KeyInfo{Gun: "gun", Role: data.CanonicalTimestampRole}, info)
+}
@@ -0,0 +1,460 @@
+// Package kms provides a signed.CryptoService which keeps its private keys in
+// an external key management service, using a transit-style HTTP API.  The
+// private keys never leave the KMS: the signer only ever sees public keys and
+// signatures.
+//
+// The API is made up of the following requests, relative to a base URL:
+//
+//	POST   keys             create a key, given {"type": ..., "labels": {...}}
+//	GET    keys             list all keys, as {"keys": [...]}
+//	DELETE keys/{name}      delete a key
+//	POST   keys/{name}/sign sign a digest, given {"digest": ...}, returning
+//	                        {"signature": ...}
+//
+// Keys are represented as {"name": ..., "type": ..., "public_key": ...,
+// "labels": {...}}, where the public key is a base64 encoded PKIX DER public
+// key.  The key types are "ecdsa-p256" and "ecdsa-p384", and signatures are
+// base64 encoded ASN.1 DER ECDSA signatures of the given digest, which is a
+// SHA-256 digest for ecdsa-p256 keys and a SHA-384 digest for ecdsa-p384 keys.
+// The role and GUN of each key are stored in its labels, so that the KMS holds
+// all of the state for its keys.
+package kms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+const (
+	// KeyTypeECDSAP256 is the KMS key type for data.ECDSAKey keys
+	KeyTypeECDSAP256 = "ecdsa-p256"
+	// KeyTypeECDSAP384 is the KMS key type for data.ECDSAP384Key keys
+	KeyTypeECDSAP384 = "ecdsa-p384"
+
+	roleLabel = "role"
+	gunLabel  = "gun"
+)
+
+// SupportedAlgorithms are the key algorithms which keys can be created with
+var SupportedAlgorithms = []string{data.ECDSAKey, data.ECDSAP384Key}
+
+// Key is a key as represented by the KMS API
+type Key struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	PublicKey []byte            `json:"public_key"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// CreateKeyRequest is the body of a request to create a key
+type CreateKeyRequest struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ListKeysResponse is the response to a request to list keys
+type ListKeysResponse struct {
+	Keys []Key `json:"keys"`
+}
+
+// SignRequest is the body of a request to sign a digest
+type SignRequest struct {
+	Digest []byte `json:"digest"`
+}
+
+// SignResponse is the response to a request to sign a digest
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+// ErrKMS is returned when the KMS responds to a request with an error
+type ErrKMS struct {
+	StatusCode int
+	Message    string
+}
+
+func (err ErrKMS) Error() string {
+	return fmt.Sprintf("KMS responded with %d: %s", err.StatusCode, err.Message)
+}
+
+// kmsKey is a key which has been created in or listed from the KMS
+type kmsKey struct {
+	name   string
+	gun    data.GUN
+	role   data.RoleName
+	public data.PublicKey
+}
+
+// CryptoService is a signed.CryptoService whose keys are held by a KMS.  The
+// public keys and roles are cached, and the cache is refreshed from the KMS
+// whenever a key is not found in it.
+type CryptoService struct {
+	baseURL *url.URL
+	client  *http.Client
+	token   string
+
+	lock sync.Mutex
+	keys map[string]kmsKey
+}
+
+// NewCryptoService returns a CryptoService using the KMS at baseURL.  If token
+// is not empty, it is sent with every request as a bearer token.  If client is
+// nil, http.DefaultClient is used.
+func NewCryptoService(baseURL string, client *http.Client, token string) (*CryptoService, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("KMS requires an absolute URL, got %q", baseURL)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &CryptoService{
+		baseURL: u,
+		client:  client,
+		token:   token,
+		keys:    make(map[string]kmsKey),
+	}, nil
+}
+
+// Create creates a new key in the KMS, and returns its public key
+func (s *CryptoService) Create(role data.RoleName, gun data.GUN, algorithm string) (data.PublicKey, error) {
+	var keyType string
+	switch algorithm {
+	case data.ECDSAKey:
+		keyType = KeyTypeECDSAP256
+	case data.ECDSAP384Key:
+		keyType = KeyTypeECDSAP384
+	default:
+		return nil, fmt.Errorf("%s keys are not supported by the KMS", algorithm)
+	}
+
+	var created Key
+	err := s.do("POST", "keys", CreateKeyRequest{
+		Type:   keyType,
+		Labels: map[string]string{roleLabel: role.String(), gunLabel: gun.String()},
+	}, &created)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.cache(created)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("created %s key %s in the KMS as %s", role, key.public.ID(), key.name)
+	return key.public, nil
+}
+
+// AddKey returns an error, since private keys cannot be imported into the KMS
+func (s *CryptoService) AddKey(role data.RoleName, gun data.GUN, key data.PrivateKey) error {
+	return errors.New("private keys cannot be added to the KMS")
+}
+
+// GetKey returns the public key with the given ID, or nil if the KMS does not
+// have it
+func (s *CryptoService) GetKey(keyID string) data.PublicKey {
+	key, err := s.getKey(keyID)
+	if err != nil {
+		return nil
+	}
+	return key.public
+}
+
+// GetPrivateKey returns a data.PrivateKey which signs using the KMS.  No
+// private key material is available from it.
+func (s *CryptoService) GetPrivateKey(keyID string) (data.PrivateKey, data.RoleName, error) {
+	key, err := s.getKey(keyID)
+	if err != nil {
+		return nil, "", err
+	}
+	return &PrivateKey{PublicKey: key.public, name: key.name, service: s}, key.role, nil
+}
+
+// GetKeyInfo returns the GUN and role of a key, from its labels in the KMS
+func (s *CryptoService) GetKeyInfo(keyID string) (trustmanager.KeyInfo, error) {
+	key, err := s.getKey(keyID)
+	if err != nil {
+		return trustmanager.KeyInfo{}, err
+	}
+	return trustmanager.KeyInfo{Gun: key.gun, Role: key.role}, nil
+}
+
+// RemoveKey deletes the key from the KMS.  It is not an error if the key does
+// not exist.
+func (s *CryptoService) RemoveKey(keyID string) error {
+	key, err := s.getKey(keyID)
+	if _, ok := err.(trustmanager.ErrKeyNotFound); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = s.do("DELETE", path.Join("keys", key.name), nil, nil)
+	if kmsErr, ok := err.(ErrKMS); err != nil && (!ok || kmsErr.StatusCode != http.StatusNotFound) {
+		return err
+	}
+	s.lock.Lock()
+	delete(s.keys, keyID)
+	s.lock.Unlock()
+	return nil
+}
+
+// ListKeys returns the IDs of the keys in the KMS for the given role
+func (s *CryptoService) ListKeys(role data.RoleName) []string {
+	var ids []string
+	for id, r := range s.ListAllKeys() {
+		if r == role {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ListAllKeys returns the IDs and roles of all the keys in the KMS
+func (s *CryptoService) ListAllKeys() map[string]data.RoleName {
+	if err := s.refresh(); err != nil {
+		logrus.Errorf("unable to list keys in the KMS: %v", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	keys := make(map[string]data.RoleName, len(s.keys))
+	for id, key := range s.keys {
+		keys[id] = key.role
+	}
+	return keys
+}
+
+// CheckHealth returns an error if the KMS cannot list its keys
+func (s *CryptoService) CheckHealth() error {
+	return s.refresh()
+}
+
+func (s *CryptoService) getKey(keyID string) (kmsKey, error) {
+	s.lock.Lock()
+	key, ok := s.keys[keyID]
+	s.lock.Unlock()
+	if ok {
+		return key, nil
+	}
+	if err := s.refresh(); err != nil {
+		return kmsKey{}, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if key, ok = s.keys[keyID]; !ok {
+		return kmsKey{}, trustmanager.ErrKeyNotFound{KeyID: keyID}
+	}
+	return key, nil
+}
+
+// refresh replaces the cache with the keys currently in the KMS
+func (s *CryptoService) refresh() error {
+	var list ListKeysResponse
+	if err := s.do("GET", "keys", nil, &list); err != nil {
+		return err
+	}
+	keys := make(map[string]kmsKey, len(list.Keys))
+	for _, k := range list.Keys {
+		key, err := parseKey(k)
+		if err != nil {
+			logrus.Warnf("ignoring KMS key %s: %v", k.Name, err)
+			continue
+		}
+		keys[key.public.ID()] = key
+	}
+	s.lock.Lock()
+	s.keys = keys
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *CryptoService) cache(k Key) (kmsKey, error) {
+	key, err := parseKey(k)
+	if err != nil {
+		return kmsKey{}, err
+	}
+	s.lock.Lock()
+	s.keys[key.public.ID()] = key
+	s.lock.Unlock()
+	return key, nil
+}
+
+func parseKey(k Key) (kmsKey, error) {
+	if k.Name == "" {
+		return kmsKey{}, errors.New("key has no name")
+	}
+	if _, err := x509.ParsePKIXPublicKey(k.PublicKey); err != nil {
+		return kmsKey{}, fmt.Errorf("invalid public key: %v", err)
+	}
+	var public data.PublicKey
+	switch k.Type {
+	case KeyTypeECDSAP256:
+		public = data.NewECDSAPublicKey(k.PublicKey)
+	case KeyTypeECDSAP384:
+		public = data.NewECDSAP384PublicKey(k.PublicKey)
+	default:
+		return kmsKey{}, fmt.Errorf("unsupported key type %s", k.Type)
+	}
+	return kmsKey{
+		name:   k.Name,
+		gun:    data.GUN(k.Labels[gunLabel]),
+		role:   data.RoleName(k.Labels[roleLabel]),
+		public: public,
+	}, nil
+}
+
+// do makes a request to the KMS, encoding in as the JSON body if it is not
+// nil, and decoding the JSON response into out if it is not nil
+func (s *CryptoService) do(method, p string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	u := *s.baseURL
+	u.Path = path.Join(u.Path, p)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, notary.MaxDownloadSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return ErrKMS{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(respBody))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// PrivateKey is a key held by the KMS, so no private key bytes are available.
+// It is only good for signing.
+type PrivateKey struct {
+	data.PublicKey
+	name    string
+	service *CryptoService
+}
+
+// Private returns nil bytes
+func (k *PrivateKey) Private() []byte {
+	return nil
+}
+
+// SignatureAlgorithm returns the signing algorithm of the key
+func (k *PrivateKey) SignatureAlgorithm() data.SigAlgorithm {
+	if k.Algorithm() == data.ECDSAP384Key {
+		return data.ECDSAP384Signature
+	}
+	return data.ECDSASignature
+}
+
+// CryptoSigner returns a crypto.Signer that signs using the KMS
+func (k *PrivateKey) CryptoSigner() crypto.Signer {
+	return &signer{key: k}
+}
+
+type ecdsaSig struct {
+	R, S *big.Int
+}
+
+// Sign hashes msg and has the KMS sign the digest, returning the signature in
+// the format notary uses for ECDSA signatures
+func (k *PrivateKey) Sign(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var (
+		digest      []byte
+		octetLength int
+	)
+	if k.Algorithm() == data.ECDSAP384Key {
+		d := sha512.Sum384(msg)
+		digest, octetLength = d[:], 48
+	} else {
+		d := sha256.Sum256(msg)
+		digest, octetLength = d[:], 32
+	}
+	sigASN1, err := k.signDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := ecdsaSig{}
+	if _, err := asn1.Unmarshal(sigASN1, &sig); err != nil {
+		return nil, fmt.Errorf("invalid signature from the KMS: %v", err)
+	}
+	rBytes, sBytes := sig.R.Bytes(), sig.S.Bytes()
+	if len(rBytes) > octetLength || len(sBytes) > octetLength {
+		return nil, errors.New("invalid signature from the KMS: signature is too long")
+	}
+	// MUST include leading zeros in the output
+	rBuf := make([]byte, octetLength-len(rBytes), octetLength)
+	sBuf := make([]byte, octetLength-len(sBytes), octetLength)
+	rBuf = append(rBuf, rBytes...)
+	sBuf = append(sBuf, sBytes...)
+	return append(rBuf, sBuf...), nil
+}
+
+func (k *PrivateKey) signDigest(digest []byte) ([]byte, error) {
+	var resp SignResponse
+	err := k.service.do("POST", path.Join("keys", k.name, "sign"), SignRequest{Digest: digest}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// signer implements crypto.Signer for a PrivateKey, signing digests
+type signer struct {
+	key *PrivateKey
+}
+
+// Public returns the crypto public key
+func (s *signer) Public() crypto.PublicKey {
+	publicKey, err := x509.ParsePKIXPublicKey(s.key.Public())
+	if err != nil {
+		return nil
+	}
+	return publicKey
+}
+
+// Sign has the KMS sign the digest, returning an ASN.1 DER signature
+func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.key.signDigest(digest)
+}
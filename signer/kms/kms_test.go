@@ -0,0 +1,241 @@
+package kms
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	cjson "github.com/docker/go/canonical/json"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+const testToken = "kms-token"
+
+// fakeKMS is an in-memory implementation of the KMS API
+type fakeKMS struct {
+	lock    sync.Mutex
+	keys    map[string]*ecdsa.PrivateKey
+	info    map[string]Key
+	counter int
+	// corrupt, if set, makes the KMS return signatures which are not ASN.1
+	corrupt bool
+}
+
+func newFakeKMS(t *testing.T) (*httptest.Server, *fakeKMS) {
+	f := &fakeKMS{keys: make(map[string]*ecdsa.PrivateKey), info: make(map[string]Key)}
+	return httptest.NewServer(http.StripPrefix("/v1/transit", f)), f
+}
+
+func (f *fakeKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		http.Error(w, "permission denied", http.StatusUnauthorized)
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "GET" && len(parts) == 1 && parts[0] == "keys":
+		list := ListKeysResponse{Keys: []Key{}}
+		for _, key := range f.info {
+			list.Keys = append(list.Keys, key)
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == "POST" && len(parts) == 1 && parts[0] == "keys":
+		var req CreateKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var curve elliptic.Curve
+		switch req.Type {
+		case KeyTypeECDSAP256:
+			curve = elliptic.P256()
+		case KeyTypeECDSAP384:
+			curve = elliptic.P384()
+		default:
+			http.Error(w, "unsupported key type", http.StatusBadRequest)
+			return
+		}
+		priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		public, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f.counter++
+		key := Key{Name: fmt.Sprintf("notary-%d", f.counter), Type: req.Type, PublicKey: public, Labels: req.Labels}
+		f.keys[key.Name] = priv
+		f.info[key.Name] = key
+		json.NewEncoder(w).Encode(key)
+	case r.Method == "DELETE" && len(parts) == 2 && parts[0] == "keys":
+		if _, ok := f.keys[parts[1]]; !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		delete(f.keys, parts[1])
+		delete(f.info, parts[1])
+	case r.Method == "POST" && len(parts) == 3 && parts[0] == "keys" && parts[2] == "sign":
+		priv, ok := f.keys[parts[1]]
+		if !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		var req SignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sig, err := ecdsa.SignASN1(rand.Reader, priv, req.Digest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if f.corrupt {
+			sig = []byte("not a signature")
+		}
+		json.NewEncoder(w).Encode(SignResponse{Signature: sig})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestNewCryptoServiceInvalidURL(t *testing.T) {
+	for _, u := range []string{"", "kms.example.com", "/v1/transit", "://"} {
+		_, err := NewCryptoService(u, nil, testToken)
+		require.Error(t, err, u)
+	}
+}
+
+func TestCreateAndSign(t *testing.T) {
+	ts, _ := newFakeKMS(t)
+	defer ts.Close()
+	cs, err := NewCryptoService(ts.URL+"/v1/transit", nil, testToken)
+	require.NoError(t, err)
+
+	for _, algorithm := range SupportedAlgorithms {
+		pubKey, err := cs.Create(data.CanonicalTimestampRole, "docker.com/notary", algorithm)
+		require.NoError(t, err)
+		require.Equal(t, algorithm, pubKey.Algorithm())
+		require.Equal(t, pubKey, cs.GetKey(pubKey.ID()))
+
+		privKey, role, err := cs.GetPrivateKey(pubKey.ID())
+		require.NoError(t, err)
+		require.Equal(t, data.CanonicalTimestampRole, role)
+		require.Nil(t, privKey.Private())
+		require.Equal(t, pubKey.ID(), privKey.ID())
+
+		msg := []byte("sign me")
+		sig, err := privKey.Sign(rand.Reader, msg, nil)
+		require.NoError(t, err)
+		require.NoError(t, signed.Verifiers[privKey.SignatureAlgorithm()].Verify(pubKey, sig, msg))
+
+		// signed.Sign finds the key through the CryptoService
+		s := &data.Signed{Signed: &cjson.RawMessage{'{', '}'}}
+		require.NoError(t, signed.Sign(cs, s, []data.PublicKey{pubKey}, 1, nil))
+		require.Len(t, s.Signatures, 1)
+		require.NoError(t, signed.VerifySignature(*s.Signed, &s.Signatures[0], pubKey))
+	}
+}
+
+func TestCreateUnsupportedAlgorithm(t *testing.T) {
+	ts, _ := newFakeKMS(t)
+	defer ts.Close()
+	cs, err := NewCryptoService(ts.URL+"/v1/transit", nil, testToken)
+	require.NoError(t, err)
+
+	for _, algorithm := range []string{data.ED25519Key, data.RSAKey} {
+		_, err = cs.Create(data.CanonicalTimestampRole, "docker.com/notary", algorithm)
+		require.Error(t, err)
+	}
+	require.Empty(t, cs.ListAllKeys())
+
+	privKey, err := utils.GenerateKey(data.ECDSAKey)
+	require.NoError(t, err)
+	require.Error(t, cs.AddKey(data.CanonicalTimestampRole, "docker.com/notary", privKey))
+}
+
+// The KMS holds all the state, so keys created by one signer can be used by
+// another
+func TestKeysAreSharedThroughKMS(t *testing.T) {
+	ts, _ := newFakeKMS(t)
+	defer ts.Close()
+	cs1, err := NewCryptoService(ts.URL+"/v1/transit", nil, testToken)
+	require.NoError(t, err)
+	cs2, err := NewCryptoService(ts.URL+"/v1/transit", nil, testToken)
+	require.NoError(t, err)
+
+	tsKey, err := cs1.Create(data.CanonicalTimestampRole, "docker.com/notary", data.ECDSAKey)
+	require.NoError(t, err)
+	snKey, err := cs1.Create(data.CanonicalSnapshotRole, "docker.com/notary", data.ECDSAKey)
+	require.NoError(t, err)
+
+	require.Equal(t, tsKey, cs2.GetKey(tsKey.ID()))
+	info, err := cs2.GetKeyInfo(tsKey.ID())
+	require.NoError(t, err)
+	require.Equal(t, trustmanager.KeyInfo{Gun: "docker.com/notary", Role: data.CanonicalTimestampRole}, info)
+	require.Equal(t, []string{snKey.ID()}, cs2.ListKeys(data.CanonicalSnapshotRole))
+	require.Equal(t, map[string]data.RoleName{
+		tsKey.ID(): data.CanonicalTimestampRole,
+		snKey.ID(): data.CanonicalSnapshotRole,
+	}, cs2.ListAllKeys())
+
+	require.NoError(t, cs2.RemoveKey(tsKey.ID()))
+	require.Nil(t, cs2.GetKey(tsKey.ID()))
+	// cs1 still has the key cached, but the KMS can no longer sign with it
+	privKey, _, err := cs1.GetPrivateKey(tsKey.ID())
+	require.NoError(t, err)
+	_, err = privKey.Sign(rand.Reader, []byte("sign me"), nil)
+	require.Error(t, err)
+	// removing it again, or removing a key that never existed, is not an error
+	require.NoError(t, cs1.RemoveKey(tsKey.ID()))
+	require.NoError(t, cs1.RemoveKey("nope"))
+
+	_, _, err = cs1.GetPrivateKey(tsKey.ID())
+	require.IsType(t, trustmanager.ErrKeyNotFound{}, err)
+}
+
+func TestKMSErrors(t *testing.T) {
+	ts, fake := newFakeKMS(t)
+	defer ts.Close()
+
+	unauthorized, err := NewCryptoService(ts.URL+"/v1/transit", nil, "wrong")
+	require.NoError(t, err)
+	_, err = unauthorized.Create(data.CanonicalTimestampRole, "docker.com/notary", data.ECDSAKey)
+	require.IsType(t, ErrKMS{}, err)
+	require.Equal(t, http.StatusUnauthorized, err.(ErrKMS).StatusCode)
+	require.Error(t, unauthorized.CheckHealth())
+	_, _, err = unauthorized.GetPrivateKey("nope")
+	require.IsType(t, ErrKMS{}, err)
+
+	cs, err := NewCryptoService(ts.URL+"/v1/transit", nil, testToken)
+	require.NoError(t, err)
+	require.NoError(t, cs.CheckHealth())
+	pubKey, err := cs.Create(data.CanonicalTimestampRole, "docker.com/notary", data.ECDSAKey)
+	require.NoError(t, err)
+	privKey, _, err := cs.GetPrivateKey(pubKey.ID())
+	require.NoError(t, err)
+	fake.corrupt = true
+	_, err = privKey.Sign(rand.Reader, []byte("sign me"), nil)
+	require.Error(t, err)
+
+	ts.Close()
+	require.Error(t, cs.CheckHealth())
+}
@@ -0,0 +1,193 @@
+// Package policy restricts what notary-signer's keys can be used to sign.
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// The reasons for which a signing request can be refused, which are also the
+// labels of the violations metric
+const (
+	ReasonGUN       = "gun"
+	ReasonRole      = "role"
+	ReasonContent   = "content"
+	ReasonRateLimit = "rate_limit"
+)
+
+// rateWindow is the period over which MaxSignaturesPerMinute is counted
+const rateWindow = time.Minute
+
+var violationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "notary_signer",
+	Subsystem: "policy",
+	Name:      "violations_total",
+	Help:      "Number of signing requests refused by a key policy, by reason.",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(violationsTotal)
+}
+
+// KeyPolicy restricts the use of a key.  The zero value allows everything.
+type KeyPolicy struct {
+	// AllowedGUNPrefixes, if not empty, lists the prefixes one of which the
+	// key's GUN must start with
+	AllowedGUNPrefixes []string `json:"allowed_gun_prefixes,omitempty"`
+	// AllowedRoles, if not empty, lists the roles the key may have
+	AllowedRoles []data.RoleName `json:"allowed_roles,omitempty"`
+	// MaxSignaturesPerMinute, if positive, limits how often the key can sign
+	MaxSignaturesPerMinute int `json:"max_signatures_per_minute,omitempty"`
+	// CheckContent requires that everything the key signs is valid metadata
+	// for the key's role, which must be timestamp or snapshot
+	CheckContent bool `json:"check_content,omitempty"`
+}
+
+// Validate returns an error if the policy is invalid
+func (p KeyPolicy) Validate() error {
+	if p.MaxSignaturesPerMinute < 0 {
+		return fmt.Errorf("max_signatures_per_minute cannot be negative")
+	}
+	for _, role := range p.AllowedRoles {
+		if !data.ValidRole(role) {
+			return fmt.Errorf("invalid role in allowed_roles: %s", role)
+		}
+	}
+	return nil
+}
+
+// ErrViolation is returned when a key's policy does not allow a signature
+type ErrViolation struct {
+	KeyID   string
+	Reason  string
+	Message string
+}
+
+func (err ErrViolation) Error() string {
+	return fmt.Sprintf("policy does not allow key %s to sign: %s", err.KeyID, err.Message)
+}
+
+// Enforcer checks signing requests against the policies of their keys
+type Enforcer struct {
+	defaultPolicy *KeyPolicy
+
+	lock sync.Mutex
+	// recent holds the times of each key's signatures within the last
+	// rateWindow, oldest first
+	recent  map[string][]time.Time
+	nowFunc func() time.Time
+}
+
+// NewEnforcer returns an Enforcer which applies defaultPolicy to keys which
+// do not have a policy of their own.  If defaultPolicy is nil, such keys are
+// unrestricted.
+func NewEnforcer(defaultPolicy *KeyPolicy) *Enforcer {
+	return &Enforcer{
+		defaultPolicy: defaultPolicy,
+		recent:        make(map[string][]time.Time),
+		nowFunc:       time.Now,
+	}
+}
+
+// Check returns an ErrViolation if the key described by keyID and info may not
+// sign content, under keyPolicy or, if that is nil, the default policy.  A
+// request which is allowed counts towards the key's rate limit.
+func (e *Enforcer) Check(keyID string, info trustmanager.KeyInfo, keyPolicy *KeyPolicy, content []byte) error {
+	if keyPolicy == nil {
+		keyPolicy = e.defaultPolicy
+	}
+	if keyPolicy == nil {
+		return nil
+	}
+	if err := e.check(keyID, info, *keyPolicy, content); err != nil {
+		violationsTotal.WithLabelValues(err.Reason).Inc()
+		return *err
+	}
+	return nil
+}
+
+func (e *Enforcer) check(keyID string, info trustmanager.KeyInfo, p KeyPolicy, content []byte) *ErrViolation {
+	if len(p.AllowedGUNPrefixes) > 0 && !hasPrefix(info.Gun.String(), p.AllowedGUNPrefixes) {
+		return &ErrViolation{KeyID: keyID, Reason: ReasonGUN,
+			Message: fmt.Sprintf("GUN %q is not allowed", info.Gun)}
+	}
+	if len(p.AllowedRoles) > 0 && !hasRole(info.Role, p.AllowedRoles) {
+		return &ErrViolation{KeyID: keyID, Reason: ReasonRole,
+			Message: fmt.Sprintf("role %q is not allowed", info.Role)}
+	}
+	if p.CheckContent {
+		if err := checkContent(info.Role, content); err != nil {
+			return &ErrViolation{KeyID: keyID, Reason: ReasonContent, Message: err.Error()}
+		}
+	}
+	if p.MaxSignaturesPerMinute > 0 && !e.allow(keyID, p.MaxSignaturesPerMinute) {
+		return &ErrViolation{KeyID: keyID, Reason: ReasonRateLimit,
+			Message: fmt.Sprintf("more than %d signatures per minute", p.MaxSignaturesPerMinute)}
+	}
+	return nil
+}
+
+// allow records a signature by keyID, unless it has already made max within
+// the last rateWindow
+func (e *Enforcer) allow(keyID string, max int) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := e.nowFunc()
+	recent := e.recent[keyID]
+	for len(recent) > 0 && now.Sub(recent[0]) >= rateWindow {
+		recent = recent[1:]
+	}
+	if len(recent) >= max {
+		e.recent[keyID] = recent
+		return false
+	}
+	e.recent[keyID] = append(recent, now)
+	return true
+}
+
+// checkContent returns an error if content is not valid metadata for role.
+// TUF metadata does not name its GUN, so the GUN of the key is checked by
+// AllowedGUNPrefixes instead.
+func checkContent(role data.RoleName, content []byte) error {
+	raw := json.RawMessage(content)
+	signed := &data.Signed{Signed: &raw}
+	var err error
+	switch role {
+	case data.CanonicalTimestampRole:
+		_, err = data.TimestampFromSigned(signed)
+	case data.CanonicalSnapshotRole:
+		_, err = data.SnapshotFromSigned(signed)
+	default:
+		return fmt.Errorf("content can only be checked for timestamp and snapshot keys, not %q", role)
+	}
+	if err != nil {
+		return fmt.Errorf("content is not valid %s metadata: %v", role, err)
+	}
+	return nil
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRole(role data.RoleName, roles []data.RoleName) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
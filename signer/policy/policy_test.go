@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/testutils"
+)
+
+var timestampKey = trustmanager.KeyInfo{Gun: "docker.io/library/alpine", Role: data.CanonicalTimestampRole}
+
+func violations(t *testing.T, reason string) float64 {
+	m := &dto.Metric{}
+	require.NoError(t, violationsTotal.WithLabelValues(reason).Write(m))
+	return m.GetCounter().GetValue()
+}
+
+func requireViolation(t *testing.T, reason string, err error) {
+	require.Error(t, err)
+	require.IsType(t, ErrViolation{}, err)
+	require.Equal(t, reason, err.(ErrViolation).Reason)
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, KeyPolicy{}.Validate())
+	require.NoError(t, KeyPolicy{AllowedRoles: []data.RoleName{data.CanonicalTimestampRole}, MaxSignaturesPerMinute: 1}.Validate())
+	require.Error(t, KeyPolicy{MaxSignaturesPerMinute: -1}.Validate())
+	require.Error(t, KeyPolicy{AllowedRoles: []data.RoleName{"nope"}}.Validate())
+}
+
+func TestNoPolicyAllowsEverything(t *testing.T) {
+	e := NewEnforcer(nil)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, e.Check("keyid", timestampKey, nil, []byte("anything")))
+	}
+	require.NoError(t, e.Check("keyid", timestampKey, &KeyPolicy{}, []byte("anything")))
+}
+
+// A key's own policy replaces the default one
+func TestKeyPolicyOverridesDefault(t *testing.T) {
+	e := NewEnforcer(&KeyPolicy{AllowedGUNPrefixes: []string{"docker.com/"}})
+	requireViolation(t, ReasonGUN, e.Check("keyid", timestampKey, nil, nil))
+	require.NoError(t, e.Check("keyid", timestampKey, &KeyPolicy{AllowedGUNPrefixes: []string{"docker.io/"}}, nil))
+}
+
+func TestGUNAndRoleRestrictions(t *testing.T) {
+	e := NewEnforcer(&KeyPolicy{
+		AllowedGUNPrefixes: []string{"docker.com/", "docker.io/library/"},
+		AllowedRoles:       []data.RoleName{data.CanonicalTimestampRole},
+	})
+	gunViolations, roleViolations := violations(t, ReasonGUN), violations(t, ReasonRole)
+
+	require.NoError(t, e.Check("keyid", timestampKey, nil, nil))
+	requireViolation(t, ReasonGUN, e.Check("keyid", trustmanager.KeyInfo{Gun: "docker.io/other", Role: data.CanonicalTimestampRole}, nil, nil))
+	requireViolation(t, ReasonRole, e.Check("keyid", trustmanager.KeyInfo{Gun: "docker.com/notary", Role: data.CanonicalSnapshotRole}, nil, nil))
+
+	require.Equal(t, gunViolations+1, violations(t, ReasonGUN))
+	require.Equal(t, roleViolations+1, violations(t, ReasonRole))
+}
+
+func TestContentCheck(t *testing.T) {
+	meta, _, err := testutils.NewRepoMetadata("docker.io/library/alpine")
+	require.NoError(t, err)
+	signedPart := func(role data.RoleName) []byte {
+		s := &data.Signed{}
+		require.NoError(t, json.Unmarshal(meta[role], s))
+		return *s.Signed
+	}
+	snapshotKey := trustmanager.KeyInfo{Gun: timestampKey.Gun, Role: data.CanonicalSnapshotRole}
+	targetsKey := trustmanager.KeyInfo{Gun: timestampKey.Gun, Role: data.CanonicalTargetsRole}
+
+	e := NewEnforcer(&KeyPolicy{CheckContent: true})
+	require.NoError(t, e.Check("keyid", timestampKey, nil, signedPart(data.CanonicalTimestampRole)))
+	require.NoError(t, e.Check("keyid", snapshotKey, nil, signedPart(data.CanonicalSnapshotRole)))
+
+	requireViolation(t, ReasonContent, e.Check("keyid", timestampKey, nil, signedPart(data.CanonicalSnapshotRole)))
+	requireViolation(t, ReasonContent, e.Check("keyid", snapshotKey, nil, signedPart(data.CanonicalTimestampRole)))
+	requireViolation(t, ReasonContent, e.Check("keyid", timestampKey, nil, []byte("not json")))
+	requireViolation(t, ReasonContent, e.Check("keyid", targetsKey, nil, signedPart(data.CanonicalTargetsRole)))
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	e := NewEnforcer(&KeyPolicy{MaxSignaturesPerMinute: 2})
+	e.nowFunc = func() time.Time { return now }
+
+	require.NoError(t, e.Check("key1", timestampKey, nil, nil))
+	now = now.Add(30 * time.Second)
+	require.NoError(t, e.Check("key1", timestampKey, nil, nil))
+	requireViolation(t, ReasonRateLimit, e.Check("key1", timestampKey, nil, nil))
+	// the limit is per key
+	require.NoError(t, e.Check("key2", timestampKey, nil, nil))
+
+	// refused requests do not count towards the limit, so once the first
+	// signature is a minute old another one is allowed
+	now = now.Add(30 * time.Second)
+	require.NoError(t, e.Check("key1", timestampKey, nil, nil))
+	requireViolation(t, ReasonRateLimit, e.Check("key1", timestampKey, nil, nil))
+
+	// requests refused for other reasons do not count either
+	e.defaultPolicy.AllowedRoles = []data.RoleName{data.CanonicalSnapshotRole}
+	requireViolation(t, ReasonRole, e.Check("key2", timestampKey, nil, nil))
+	e.defaultPolicy.AllowedRoles = nil
+	require.NoError(t, e.Check("key2", timestampKey, nil, nil))
+}
@@ -4,6 +4,8 @@ package signer_test
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -13,6 +15,7 @@ import (
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	"github.com/stretchr/testify/require"
 	"github.com/theupdateframework/notary"
@@ -20,7 +23,9 @@ import (
 	pb "github.com/theupdateframework/notary/proto"
 	"github.com/theupdateframework/notary/signer"
 	"github.com/theupdateframework/notary/signer/api"
+	"github.com/theupdateframework/notary/signer/audit"
 	"github.com/theupdateframework/notary/signer/client"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
@@ -76,6 +81,10 @@ func (s stubServer) GetKeyInfo(ctx context.Context, keyID *pb.KeyID) (*pb.GetKey
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (s stubServer) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (s stubServer) Sign(ctx context.Context, sr *pb.SignatureRequest) (*pb.Signature, error) {
 	return nil, fmt.Errorf("not implemented")
 }
@@ -232,9 +241,10 @@ var constPass = func(string, string, bool, int) (string, bool, error) {
 func setUpSignerServer(t *testing.T, store trustmanager.KeyStore) *grpc.Server {
 	cryptoService := cryptoservice.NewCryptoService(store)
 	cryptoServices := signer.CryptoServiceIndex{
-		data.ED25519Key: cryptoService,
-		data.RSAKey:     cryptoService,
-		data.ECDSAKey:   cryptoService,
+		data.ED25519Key:   cryptoService,
+		data.RSAKey:       cryptoService,
+		data.ECDSAKey:     cryptoService,
+		data.ECDSAP384Key: cryptoService,
 	}
 
 	//server setup
@@ -300,6 +310,113 @@ func TestCryptoSignerInterfaceBehavior(t *testing.T) {
 
 	interfaces.EmptyCryptoServiceInterfaceBehaviorTests(t, signerClient)
 	interfaces.CreateGetKeyCryptoServiceInterfaceBehaviorTests(t, signerClient, data.ECDSAKey)
+	interfaces.CreateGetKeyCryptoServiceInterfaceBehaviorTests(t, signerClient, data.ECDSAP384Key)
 	// can't test AddKey, because the signer does not support adding keys, and can't test listing
 	// keys because the signer doesn't support listing keys.
 }
+
+// Key creation, signing and key deletion are recorded in the audit log, which
+// can be listed over GRPC
+func TestAuditEventsAreRecorded(t *testing.T) {
+	memStore := trustmanager.NewKeyMemoryStore(constPass)
+	cryptoService := cryptoservice.NewCryptoService(memStore)
+	cryptoServices := signer.CryptoServiceIndex{data.ECDSAKey: cryptoService}
+	auditLog := audit.NewLog(audit.NewMemoryStore())
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterKeyManagementServer(grpcServer, &api.KeyManagementServer{CryptoServices: cryptoServices, AuditLog: auditLog})
+	pb.RegisterSignerServer(grpcServer, &api.SignerServer{CryptoServices: cryptoServices, AuditLog: auditLog})
+	signerClient, conn, cleanup := setUpSignerClient(t, grpcServer)
+	defer cleanup()
+
+	pubKey, err := signerClient.Create(data.CanonicalTimestampRole, "docker.com/notary", data.ECDSAKey)
+	require.NoError(t, err)
+	privKey, _, err := signerClient.GetPrivateKey(pubKey.ID())
+	require.NoError(t, err)
+	msg := []byte("message!")
+	_, err = privKey.Sign(rand.Reader, msg, nil)
+	require.NoError(t, err)
+	require.NoError(t, signerClient.RemoveKey(pubKey.ID()))
+
+	kmClient := pb.NewKeyManagementClient(conn)
+	resp, err := kmClient.ListAuditEvents(context.Background(), &pb.ListAuditEventsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 3)
+
+	digest := sha256.Sum256(msg)
+	for i, expected := range []struct{ action, digest string }{
+		{audit.ActionCreateKey, ""},
+		{audit.ActionSign, hex.EncodeToString(digest[:])},
+		{audit.ActionDeleteKey, ""},
+	} {
+		event := resp.Events[i]
+		require.Equal(t, uint64(i+1), event.Sequence)
+		require.Equal(t, expected.action, event.Action)
+		require.Equal(t, expected.digest, event.Digest)
+		require.Equal(t, pubKey.ID(), event.KeyID)
+		require.Equal(t, "docker.com/notary", event.Gun)
+		require.Equal(t, data.CanonicalTimestampRole.String(), event.Role)
+		require.NotEmpty(t, event.Hash)
+		_, err := time.Parse(time.RFC3339, event.Timestamp)
+		require.NoError(t, err)
+	}
+
+	resp, err = kmClient.ListAuditEvents(context.Background(), &pb.ListAuditEventsRequest{After: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 1)
+	require.Equal(t, audit.ActionSign, resp.Events[0].Action)
+}
+
+func TestListAuditEventsWithoutAuditLog(t *testing.T) {
+	_, conn, cleanup := setUpSignerClient(t, setUpSignerServer(t, trustmanager.NewKeyMemoryStore(constPass)))
+	defer cleanup()
+
+	_, err := pb.NewKeyManagementClient(conn).ListAuditEvents(context.Background(), &pb.ListAuditEventsRequest{})
+	require.Error(t, err)
+	require.Equal(t, codes.Unimplemented, grpc.Code(err))
+}
+
+// Signing requests which the key's policy does not allow are refused with
+// an appropriate GRPC status code
+func TestSignEnforcesPolicy(t *testing.T) {
+	memStore := trustmanager.NewKeyMemoryStore(constPass)
+	cryptoService := cryptoservice.NewCryptoService(memStore)
+	cryptoServices := signer.CryptoServiceIndex{data.ECDSAKey: cryptoService}
+	enforcer := policy.NewEnforcer(&policy.KeyPolicy{
+		AllowedGUNPrefixes:     []string{"docker.com/"},
+		AllowedRoles:           []data.RoleName{data.CanonicalTimestampRole},
+		MaxSignaturesPerMinute: 1,
+	})
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterKeyManagementServer(grpcServer, &api.KeyManagementServer{CryptoServices: cryptoServices})
+	pb.RegisterSignerServer(grpcServer, &api.SignerServer{CryptoServices: cryptoServices, Policy: enforcer})
+	signerClient, _, cleanup := setUpSignerClient(t, grpcServer)
+	defer cleanup()
+
+	sign := func(role data.RoleName, gun data.GUN) error {
+		pubKey, err := signerClient.Create(role, gun, data.ECDSAKey)
+		require.NoError(t, err)
+		privKey, _, err := signerClient.GetPrivateKey(pubKey.ID())
+		require.NoError(t, err)
+		_, err = privKey.Sign(rand.Reader, []byte("message!"), nil)
+		if err == nil {
+			// the key has now used up its allowance
+			_, err = privKey.Sign(rand.Reader, []byte("message!"), nil)
+			require.Error(t, err)
+			require.Equal(t, codes.ResourceExhausted, grpc.Code(err))
+			return nil
+		}
+		return err
+	}
+
+	require.NoError(t, sign(data.CanonicalTimestampRole, "docker.com/notary"))
+
+	err := sign(data.CanonicalSnapshotRole, "docker.com/notary")
+	require.Error(t, err)
+	require.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	err = sign(data.CanonicalTimestampRole, "docker.io/notary")
+	require.Error(t, err)
+	require.Equal(t, codes.PermissionDenied, grpc.Code(err))
+}
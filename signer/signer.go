@@ -4,6 +4,8 @@ import (
 	"crypto/tls"
 
 	pb "github.com/theupdateframework/notary/proto"
+	"github.com/theupdateframework/notary/signer/audit"
+	"github.com/theupdateframework/notary/signer/policy"
 	"github.com/theupdateframework/notary/trustmanager"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
@@ -44,4 +46,7 @@ type Config struct {
 	TLSConfig      *tls.Config
 	CryptoServices CryptoServiceIndex
 	PendingKeyFunc func(trustmanager.KeyInfo) (data.PublicKey, error)
+	AuditLog       *audit.Log
+	Policy         *policy.Enforcer
+	MetricsAddr    string
 }
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+const (
+	bundleManifestName = "bundle.json"
+	bundleMetadataDir  = "metadata"
+	bundleVersion      = 1
+)
+
+// bundleManifest is the first entry in a bundle archive, and identifies the
+// trusted collection the metadata belongs to
+type bundleManifest struct {
+	Version int      `json:"version"`
+	GUN     data.GUN `json:"gun"`
+}
+
+// Bundle is the TUF metadata for a single GUN, for use where there is no
+// network path to a notary server.  Metadata is keyed by the name it would be
+// requested from a server by, such as "root", "2.root" or "targets/releases".
+// The metadata is stored as signed by the server and key holders, so no
+// trust is placed in the bundle itself: everything in it is verified in the
+// same way as metadata downloaded from a server.
+type Bundle struct {
+	GUN      data.GUN
+	Metadata map[string][]byte
+}
+
+// NewBundle returns an empty Bundle for the given GUN
+func NewBundle(gun data.GUN) *Bundle {
+	return &Bundle{GUN: gun, Metadata: make(map[string][]byte)}
+}
+
+// Write writes the bundle to w as a tar archive
+func (b *Bundle) Write(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	manifest, err := json.Marshal(bundleManifest{Version: bundleVersion, GUN: b.GUN})
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, bundleManifestName, manifest); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(b.Metadata))
+	for name := range b.Metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeTarFile(tw, path.Join(bundleMetadataDir, name+".json"), b.Metadata[name]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, contents []byte) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(contents)),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(contents)
+	return err
+}
+
+// ReadBundle reads a bundle written by Bundle.Write
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	tr := tar.NewReader(r)
+	var bundle *Bundle
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Size > notary.MaxDownloadSize {
+			return nil, ErrMaliciousServer{}
+		}
+		contents, err := ioutil.ReadAll(io.LimitReader(tr, notary.MaxDownloadSize))
+		if err != nil {
+			return nil, err
+		}
+
+		if bundle == nil {
+			if hdr.Name != bundleManifestName {
+				return nil, fmt.Errorf("invalid bundle: expected %s as the first entry, found %s", bundleManifestName, hdr.Name)
+			}
+			var manifest bundleManifest
+			if err := json.Unmarshal(contents, &manifest); err != nil {
+				return nil, fmt.Errorf("invalid bundle manifest: %s", err)
+			}
+			if manifest.Version != bundleVersion {
+				return nil, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+			}
+			if manifest.GUN == "" {
+				return nil, fmt.Errorf("invalid bundle manifest: no GUN")
+			}
+			bundle = NewBundle(manifest.GUN)
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if !strings.HasPrefix(name, bundleMetadataDir+"/") || !strings.HasSuffix(name, ".json") {
+			return nil, fmt.Errorf("invalid bundle: unexpected entry %s", hdr.Name)
+		}
+		name = strings.TrimSuffix(strings.TrimPrefix(name, bundleMetadataDir+"/"), ".json")
+		bundle.Metadata[name] = contents
+	}
+	if bundle == nil {
+		return nil, fmt.Errorf("invalid bundle: no %s found", bundleManifestName)
+	}
+	return bundle, nil
+}
+
+// NewBundleStore returns a read-only RemoteStore which serves the metadata in
+// a bundle, so that a repository can be loaded from it instead of a notary
+// server.  Any operation which would modify the remote returns ErrOffline.
+func NewBundleStore(bundle *Bundle) *BundleStore {
+	consistent := make(map[string][]byte)
+	for name, d := range bundle.Metadata {
+		checksum := sha256.Sum256(d)
+		consistent[utils.ConsistentName(name, checksum[:])] = d
+	}
+	return &BundleStore{bundle: bundle, consistent: consistent}
+}
+
+// BundleStore implements a read-only RemoteStore backed by a Bundle
+type BundleStore struct {
+	bundle     *Bundle
+	consistent map[string][]byte
+}
+
+// GetSized returns the metadata with the given name, which may be a plain
+// role name, a versioned root or a consistent name.  ErrMaliciousServer is
+// returned if it is larger than size.
+func (b *BundleStore) GetSized(name string, size int64) ([]byte, error) {
+	d, ok := b.bundle.Metadata[name]
+	if !ok {
+		d, ok = b.consistent[name]
+	}
+	if !ok {
+		return nil, ErrMetaNotFound{Resource: name}
+	}
+	if size == NoSizeLimit {
+		size = notary.MaxDownloadSize
+	}
+	if int64(len(d)) > size {
+		return nil, ErrMaliciousServer{}
+	}
+	return d, nil
+}
+
+// Set returns ErrOffline
+func (b *BundleStore) Set(name string, blob []byte) error {
+	return ErrOffline{}
+}
+
+// SetMulti returns ErrOffline
+func (b *BundleStore) SetMulti(map[string][]byte) error {
+	return ErrOffline{}
+}
+
+// Remove returns ErrOffline
+func (b *BundleStore) Remove(name string) error {
+	return ErrOffline{}
+}
+
+// RemoveAll returns ErrOffline
+func (b *BundleStore) RemoveAll() error {
+	return ErrOffline{}
+}
+
+// GetKey returns ErrOffline
+func (b *BundleStore) GetKey(role data.RoleName) ([]byte, error) {
+	return nil, ErrOffline{}
+}
+
+// RotateKey returns ErrOffline
+func (b *BundleStore) RotateKey(role data.RoleName) ([]byte, error) {
+	return nil, ErrOffline{}
+}
+
+// Location returns a human readable name for the storage location
+func (b *BundleStore) Location() string {
+	return fmt.Sprintf("bundle for %s", b.bundle.GUN)
+}
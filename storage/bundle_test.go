@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	bundle := NewBundle("docker.com/notary")
+	bundle.Metadata["root"] = []byte("root2")
+	bundle.Metadata["1.root"] = []byte("root1")
+	bundle.Metadata["2.root"] = []byte("root2")
+	bundle.Metadata["targets"] = []byte("targets")
+	bundle.Metadata["targets/a/b"] = []byte("delegation")
+
+	var buf bytes.Buffer
+	require.NoError(t, bundle.Write(&buf))
+	read, err := ReadBundle(&buf)
+	require.NoError(t, err)
+	require.Equal(t, bundle, read)
+}
+
+func TestReadBundleInvalid(t *testing.T) {
+	writeArchive := func(files ...string) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for i := 0; i < len(files); i += 2 {
+			require.NoError(t, writeTarFile(tw, files[i], []byte(files[i+1])))
+		}
+		require.NoError(t, tw.Close())
+		return &buf
+	}
+
+	// not a tar archive
+	_, err := ReadBundle(bytes.NewBufferString("not a bundle"))
+	require.Error(t, err)
+
+	// no manifest
+	_, err = ReadBundle(writeArchive())
+	require.Error(t, err)
+	_, err = ReadBundle(writeArchive("metadata/root.json", "root"))
+	require.Error(t, err)
+
+	// invalid manifests
+	_, err = ReadBundle(writeArchive(bundleManifestName, "{"))
+	require.Error(t, err)
+	_, err = ReadBundle(writeArchive(bundleManifestName, `{"version": 1}`))
+	require.Error(t, err)
+	_, err = ReadBundle(writeArchive(bundleManifestName, `{"version": 2, "gun": "docker.com/notary"}`))
+	require.Error(t, err)
+
+	// entries outside the metadata directory
+	manifest := `{"version": 1, "gun": "docker.com/notary"}`
+	_, err = ReadBundle(writeArchive(bundleManifestName, manifest, "root.json", "root"))
+	require.Error(t, err)
+	_, err = ReadBundle(writeArchive(bundleManifestName, manifest, "metadata/../root.json", "root"))
+	require.Error(t, err)
+	_, err = ReadBundle(writeArchive(bundleManifestName, manifest, "metadata/root.txt", "root"))
+	require.Error(t, err)
+
+	bundle, err := ReadBundle(writeArchive(bundleManifestName, manifest, "metadata/root.json", "root"))
+	require.NoError(t, err)
+	require.Equal(t, data.GUN("docker.com/notary"), bundle.GUN)
+	require.Equal(t, map[string][]byte{"root": []byte("root")}, bundle.Metadata)
+}
+
+func TestBundleStore(t *testing.T) {
+	bundle := NewBundle("docker.com/notary")
+	bundle.Metadata["root"] = []byte("root2")
+	bundle.Metadata["1.root"] = []byte("root1")
+	bundle.Metadata["targets"] = []byte("targets")
+	s := NewBundleStore(bundle)
+
+	d, err := s.GetSized("1.root", NoSizeLimit)
+	require.NoError(t, err)
+	require.Equal(t, []byte("root1"), d)
+
+	checksum := sha256.Sum256([]byte("targets"))
+	d, err = s.GetSized(utils.ConsistentName("targets", checksum[:]), 7)
+	require.NoError(t, err)
+	require.Equal(t, []byte("targets"), d)
+
+	_, err = s.GetSized("targets", 6)
+	require.IsType(t, ErrMaliciousServer{}, err)
+
+	_, err = s.GetSized("snapshot", NoSizeLimit)
+	require.IsType(t, ErrMetaNotFound{}, err)
+
+	// the store is read only
+	require.IsType(t, ErrOffline{}, s.Set("root", []byte("root3")))
+	require.IsType(t, ErrOffline{}, s.SetMulti(map[string][]byte{"root": []byte("root3")}))
+	require.IsType(t, ErrOffline{}, s.Remove("root"))
+	require.IsType(t, ErrOffline{}, s.RemoveAll())
+	_, err = s.GetKey(data.CanonicalTimestampRole)
+	require.IsType(t, ErrOffline{}, err)
+	_, err = s.RotateKey(data.CanonicalTimestampRole)
+	require.IsType(t, ErrOffline{}, err)
+	d, err = s.GetSized("root", NoSizeLimit)
+	require.NoError(t, err)
+	require.Equal(t, []byte("root2"), d)
+}
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary"
@@ -94,6 +95,19 @@ func (err ErrInvalidOperation) Error() string {
 	return "trust server rejected operation."
 }
 
+// ErrVersionConflict indicates that the server rejected an update because
+// newer versions of the metadata had been published since it was made, for
+// example by someone else publishing at the same time.
+type ErrVersionConflict struct{}
+
+func (err ErrVersionConflict) Error() string {
+	return "trust server rejected operation: newer trust data has been published."
+}
+
+// versionErrorCode is the error code the server responds with when an update
+// conflicts with newer versions of the metadata
+const versionErrorCode = "VERSION"
+
 // HTTPStore manages pulling and pushing metadata from and to a remote
 // service over HTTP. It assumes the URL structure of the remote service
 // maps identically to the structure of the TUF repo:
@@ -154,7 +168,8 @@ func tryUnmarshalError(resp *http.Response, defaultError error) error {
 	}
 	var parsedErrors struct {
 		Errors []struct {
-			Detail validation.SerializableError `json:"detail"`
+			Code   string          `json:"code"`
+			Detail json.RawMessage `json:"detail"`
 		} `json:"errors"`
 	}
 	if err := json.Unmarshal(bodyBytes, &parsedErrors); err != nil {
@@ -163,7 +178,14 @@ func tryUnmarshalError(resp *http.Response, defaultError error) error {
 	if len(parsedErrors.Errors) != 1 {
 		return defaultError
 	}
-	err = parsedErrors.Errors[0].Detail.Error
+	if parsedErrors.Errors[0].Code == versionErrorCode {
+		return ErrVersionConflict{}
+	}
+	var detail validation.SerializableError
+	if err := json.Unmarshal(parsedErrors.Errors[0].Detail, &detail); err != nil {
+		return defaultError
+	}
+	err = detail.Error
 	if err == nil {
 		return defaultError
 	}
@@ -373,6 +395,32 @@ func (s HTTPStore) RotateKey(role data.RoleName) ([]byte, error) {
 	return body, nil
 }
 
+// GetLastModified returns when the remote server says the named metadata was
+// published, from its Last-Modified header
+func (s HTTPStore) GetLastModified(name string) (time.Time, error) {
+	url, err := s.buildMetaURL(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := s.roundTrip.RoundTrip(req)
+	if err != nil {
+		return time.Time{}, NetworkError{Wrapped: err}
+	}
+	defer resp.Body.Close()
+	if err := translateStatusToError(resp, name); err != nil {
+		return time.Time{}, err
+	}
+	lastModified := resp.Header.Get("Last-Modified")
+	if lastModified == "" {
+		return time.Time{}, fmt.Errorf("%s did not say when %s was published", s.Location(), name)
+	}
+	return time.Parse(time.RFC1123, lastModified)
+}
+
 // Location returns a human readable name for the storage location
 func (s HTTPStore) Location() string {
 	return s.baseURL.Host
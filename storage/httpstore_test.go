@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/go/canonical/json"
 	"github.com/stretchr/testify/require"
@@ -92,6 +93,36 @@ func TestHTTPStoreGetAllMeta(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestHTTPStoreGetLastModified(t *testing.T) {
+	published := time.Date(2017, time.March, 2, 15, 4, 5, 0, time.UTC)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata/1.timestamp.json":
+			w.Header().Set("Last-Modified", published.Format(time.RFC1123))
+			w.Write([]byte("{}"))
+		case "/metadata/2.timestamp.json":
+			w.Write([]byte("{}"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	remote, err := NewHTTPStore(server.URL, "metadata", "json", "key", &http.Transport{})
+	require.NoError(t, err)
+	store := remote.(*HTTPStore)
+
+	lastModified, err := store.GetLastModified("1.timestamp")
+	require.NoError(t, err)
+	require.True(t, published.Equal(lastModified))
+
+	_, err = store.GetLastModified("2.timestamp")
+	require.Error(t, err)
+
+	_, err = store.GetLastModified("3.timestamp")
+	require.IsType(t, ErrMetaNotFound{}, err)
+}
+
 func TestSetSingleAndSetMultiMeta(t *testing.T) {
 	metas := map[string][]byte{
 		data.CanonicalRootRole.String():    []byte("root data"),
@@ -240,6 +271,23 @@ func TestTranslateErrorsWhenCannotParse400(t *testing.T) {
 	}
 }
 
+// If it's a 400 because the update conflicts with newer metadata, a
+// VersionConflict is returned whatever the detail is.
+func TestTranslateErrorsVersionConflict(t *testing.T) {
+	for _, body := range []string{
+		`{"errors": [{"code": "VERSION", "message": "newer", "detail": {}}]}`,
+		`{"errors": [{"code": "VERSION"}]}`,
+	} {
+		errorResp := http.Response{
+			StatusCode: http.StatusBadRequest,
+			Body:       ioutil.NopCloser(bytes.NewBuffer([]byte(body))),
+		}
+
+		err := translateStatusToError(&errorResp, "")
+		require.IsType(t, ErrVersionConflict{}, err)
+	}
+}
+
 // Cut off error reading after a certain size
 func TestTranslateErrorsLimitsErrorSize(t *testing.T) {
 	// if the error message itself is the max error size, then extra JSON surrounding it will put it over
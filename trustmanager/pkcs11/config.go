@@ -0,0 +1,77 @@
+// Package pkcs11 provides a KeyStore for private keys kept in any PKCS#11
+// token, such as a network HSM or SoftHSM, addressed by its module, token label
+// or slot and PIN.  Unlike the yubikey package, it makes no assumptions about
+// the token's slots, PINs or supported key types beyond what PKCS#11 requires.
+//
+// Only Config is available when not building with the pkcs11 tag.
+package pkcs11
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Config says which token holds the keys, and how to log in to it
+type Config struct {
+	// Module is the path to the PKCS#11 library for the token
+	Module string
+	// TokenLabel selects the token by its label
+	TokenLabel string
+	// Slot selects the token by its slot ID, if there is no TokenLabel
+	Slot *uint
+	// PINFile is the path to a file containing the user PIN of the token
+	PINFile string
+	// PINEnv is the name of an environment variable containing the user PIN
+	// of the token
+	PINEnv string
+}
+
+// Validate returns an error if the Config does not identify exactly one token
+func (c Config) Validate() error {
+	switch {
+	case c.Module == "":
+		return errors.New("must provide the path to a PKCS#11 module")
+	case c.TokenLabel == "" && c.Slot == nil:
+		return errors.New("must provide either a token label or a slot")
+	case c.TokenLabel != "" && c.Slot != nil:
+		return errors.New("provide either a token label or a slot, not both")
+	case c.PINFile != "" && c.PINEnv != "":
+		return errors.New("provide either a PIN file or a PIN environment variable, not both")
+	}
+	return nil
+}
+
+// Token returns a user friendly name for the configured token
+func (c Config) Token() string {
+	if c.TokenLabel != "" {
+		return c.TokenLabel
+	}
+	if c.Slot != nil {
+		return fmt.Sprintf("slot %d", *c.Slot)
+	}
+	return ""
+}
+
+// PIN returns the user PIN from the configured file or environment variable.
+// If neither is configured, ok is false and the PIN has to be asked for.
+func (c Config) PIN() (pin string, ok bool, err error) {
+	switch {
+	case c.PINFile != "":
+		contents, err := ioutil.ReadFile(c.PINFile)
+		if err != nil {
+			return "", false, fmt.Errorf("unable to read the PIN file: %v", err)
+		}
+		pin = strings.TrimRight(string(contents), "\r\n")
+	case c.PINEnv != "":
+		pin = os.Getenv(c.PINEnv)
+	default:
+		return "", false, nil
+	}
+	if pin == "" {
+		return "", false, errors.New("the configured PIN is empty")
+	}
+	return pin, true, nil
+}
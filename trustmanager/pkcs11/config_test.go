@@ -0,0 +1,57 @@
+package pkcs11
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	slot := uint(0)
+	require.NoError(t, Config{Module: "/usr/lib/softhsm/libsofthsm2.so", TokenLabel: "notary"}.Validate())
+	require.NoError(t, Config{Module: "/usr/lib/softhsm/libsofthsm2.so", Slot: &slot, PINEnv: "PIN"}.Validate())
+
+	invalid := []Config{
+		{TokenLabel: "notary"},
+		{Module: "/usr/lib/softhsm/libsofthsm2.so"},
+		{Module: "/usr/lib/softhsm/libsofthsm2.so", TokenLabel: "notary", Slot: &slot},
+		{Module: "/usr/lib/softhsm/libsofthsm2.so", TokenLabel: "notary", PINFile: "pin", PINEnv: "PIN"},
+	}
+	for _, config := range invalid {
+		require.Error(t, config.Validate(), "%+v", config)
+	}
+}
+
+func TestConfigPIN(t *testing.T) {
+	_, ok, err := Config{}.PIN()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	tempDir, err := ioutil.TempDir("", "pkcs11-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	pinFile := filepath.Join(tempDir, "pin")
+	require.NoError(t, ioutil.WriteFile(pinFile, []byte("1234\n"), 0600))
+
+	pin, ok, err := Config{PINFile: pinFile}.PIN()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "1234", pin)
+
+	_, _, err = Config{PINFile: filepath.Join(tempDir, "nonexistent")}.PIN()
+	require.Error(t, err)
+
+	require.NoError(t, os.Setenv("NOTARY_TEST_PKCS11_CONFIG_PIN", "5678"))
+	defer os.Unsetenv("NOTARY_TEST_PKCS11_CONFIG_PIN")
+	pin, ok, err = Config{PINEnv: "NOTARY_TEST_PKCS11_CONFIG_PIN"}.PIN()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "5678", pin)
+
+	// an empty PIN is a misconfiguration
+	_, _, err = Config{PINEnv: "NOTARY_TEST_PKCS11_UNSET_PIN"}.PIN()
+	require.Error(t, err)
+}
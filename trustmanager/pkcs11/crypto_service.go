@@ -0,0 +1,37 @@
+// +build pkcs11
+
+package pkcs11
+
+import (
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// SupportedAlgorithms are the key algorithms which can be generated in, and
+// imported into, a PKCS#11 token
+var SupportedAlgorithms = []string{data.ECDSAKey, data.ECDSAP384Key, data.RSAKey}
+
+// CryptoService is a signed.CryptoService for the keys in a Store, which
+// generates new keys inside the token rather than importing them
+type CryptoService struct {
+	*cryptoservice.CryptoService
+	store *Store
+}
+
+// NewCryptoService returns a CryptoService for the keys in a Store
+func NewCryptoService(store *Store) *CryptoService {
+	return &CryptoService{
+		CryptoService: cryptoservice.NewCryptoService(store),
+		store:         store,
+	}
+}
+
+// Create generates a new key inside the token
+func (s *CryptoService) Create(role data.RoleName, gun data.GUN, algorithm string) (data.PublicKey, error) {
+	return s.store.GenerateKey(role, gun, algorithm)
+}
+
+// CheckHealth returns an error if the token cannot be reached
+func (s *CryptoService) CheckHealth() error {
+	return s.store.CheckHealth()
+}
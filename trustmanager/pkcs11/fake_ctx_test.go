@@ -0,0 +1,301 @@
+// +build pkcs11
+
+package pkcs11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/miekg/pkcs11"
+)
+
+// fakeToken is an in-memory PKCS#11 token, which only supports what the Store
+// requires
+type fakeToken struct {
+	label    string
+	pin      string
+	loggedIn bool
+	sessions int
+	objects  map[pkcs11.ObjectHandle][]*pkcs11.Attribute
+	signers  map[pkcs11.ObjectHandle]crypto.Signer
+	nextObj  pkcs11.ObjectHandle
+
+	found      []pkcs11.ObjectHandle
+	signingKey crypto.Signer
+	mechanism  uint
+	generated  int
+	logins     int
+}
+
+func newFakeToken(label, pin string) *fakeToken {
+	return &fakeToken{
+		label:   label,
+		pin:     pin,
+		objects: make(map[pkcs11.ObjectHandle][]*pkcs11.Attribute),
+		signers: make(map[pkcs11.ObjectHandle]crypto.Signer),
+	}
+}
+
+// fakeCtx is an IPKCS11Ctx for a fakeToken in slot 5, with an empty slot 1
+type fakeCtx struct {
+	token *fakeToken
+}
+
+func (f *fakeToken) loader(module string) IPKCS11Ctx {
+	return &fakeCtx{token: f}
+}
+
+func (c *fakeCtx) Destroy()          {}
+func (c *fakeCtx) Initialize() error { return nil }
+func (c *fakeCtx) Finalize() error   { return nil }
+
+func (c *fakeCtx) GetSlotList(tokenPresent bool) ([]uint, error) {
+	return []uint{1, 5}, nil
+}
+
+func (c *fakeCtx) GetTokenInfo(slotID uint) (pkcs11.TokenInfo, error) {
+	if slotID != 5 {
+		return pkcs11.TokenInfo{}, pkcs11.Error(pkcs11.CKR_TOKEN_NOT_PRESENT)
+	}
+	return pkcs11.TokenInfo{Label: c.token.label}, nil
+}
+
+func (c *fakeCtx) OpenSession(slotID uint, flags uint) (pkcs11.SessionHandle, error) {
+	if slotID != 5 {
+		return 0, pkcs11.Error(pkcs11.CKR_SLOT_ID_INVALID)
+	}
+	c.token.sessions++
+	return pkcs11.SessionHandle(c.token.sessions), nil
+}
+
+func (c *fakeCtx) CloseSession(sh pkcs11.SessionHandle) error {
+	c.token.sessions--
+	if c.token.sessions == 0 {
+		c.token.loggedIn = false
+	}
+	return nil
+}
+
+func (c *fakeCtx) Login(sh pkcs11.SessionHandle, userType uint, pin string) error {
+	c.token.logins++
+	if c.token.loggedIn {
+		return pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)
+	}
+	if userType != pkcs11.CKU_USER || pin != c.token.pin {
+		return pkcs11.Error(pkcs11.CKR_PIN_INCORRECT)
+	}
+	c.token.loggedIn = true
+	return nil
+}
+
+func (c *fakeCtx) addObject(attrs []*pkcs11.Attribute) pkcs11.ObjectHandle {
+	c.token.nextObj++
+	c.token.objects[c.token.nextObj] = attrs
+	return c.token.nextObj
+}
+
+func (c *fakeCtx) CreateObject(sh pkcs11.SessionHandle, temp []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	if !c.token.loggedIn {
+		return 0, pkcs11.Error(pkcs11.CKR_USER_NOT_LOGGED_IN)
+	}
+	obj := c.addObject(temp)
+	if !hasAttribute(temp, pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY)) {
+		return obj, nil
+	}
+	if hasAttribute(temp, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA)) {
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{
+				N: bigAttribute(temp, pkcs11.CKA_MODULUS),
+				E: int(bigAttribute(temp, pkcs11.CKA_PUBLIC_EXPONENT).Int64()),
+			},
+			D: bigAttribute(temp, pkcs11.CKA_PRIVATE_EXPONENT),
+			Primes: []*big.Int{
+				bigAttribute(temp, pkcs11.CKA_PRIME_1),
+				bigAttribute(temp, pkcs11.CKA_PRIME_2),
+			},
+		}
+		key.Precompute()
+		c.token.signers[obj] = key
+		return obj, nil
+	}
+	curve, err := attributeCurve(temp)
+	if err != nil {
+		return 0, err
+	}
+	key := &ecdsa.PrivateKey{D: bigAttribute(temp, pkcs11.CKA_VALUE)}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(key.D.Bytes())
+	c.token.signers[obj] = key
+	return obj, nil
+}
+
+func (c *fakeCtx) DestroyObject(sh pkcs11.SessionHandle, oh pkcs11.ObjectHandle) error {
+	if !c.token.loggedIn {
+		return pkcs11.Error(pkcs11.CKR_USER_NOT_LOGGED_IN)
+	}
+	delete(c.token.objects, oh)
+	delete(c.token.signers, oh)
+	return nil
+}
+
+func (c *fakeCtx) GetAttributeValue(sh pkcs11.SessionHandle, o pkcs11.ObjectHandle, a []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
+	attrs, ok := c.token.objects[o]
+	if !ok {
+		return nil, pkcs11.Error(pkcs11.CKR_OBJECT_HANDLE_INVALID)
+	}
+	var res []*pkcs11.Attribute
+	for _, want := range a {
+		for _, attr := range attrs {
+			if attr.Type == want.Type {
+				res = append(res, pkcs11.NewAttribute(attr.Type, attr.Value))
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c *fakeCtx) FindObjectsInit(sh pkcs11.SessionHandle, temp []*pkcs11.Attribute) error {
+	c.token.found = nil
+	for obj, attrs := range c.token.objects {
+		// private objects can only be seen when logged in
+		if !c.token.loggedIn && hasAttribute(attrs, pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true)) {
+			continue
+		}
+		matches := true
+		for _, want := range temp {
+			matches = matches && hasAttribute(attrs, want)
+		}
+		if matches {
+			c.token.found = append(c.token.found, obj)
+		}
+	}
+	return nil
+}
+
+func (c *fakeCtx) FindObjects(sh pkcs11.SessionHandle, max int) ([]pkcs11.ObjectHandle, bool, error) {
+	if max > len(c.token.found) {
+		max = len(c.token.found)
+	}
+	objs := c.token.found[:max]
+	c.token.found = c.token.found[max:]
+	return objs, false, nil
+}
+
+func (c *fakeCtx) FindObjectsFinal(sh pkcs11.SessionHandle) error {
+	c.token.found = nil
+	return nil
+}
+
+func (c *fakeCtx) GenerateKeyPair(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, public, private []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
+	if !c.token.loggedIn {
+		return 0, 0, pkcs11.Error(pkcs11.CKR_USER_NOT_LOGGED_IN)
+	}
+	var signer crypto.Signer
+	switch m[0].Mechanism {
+	case pkcs11.CKM_EC_KEY_PAIR_GEN:
+		curve, err := attributeCurve(public)
+		if err != nil {
+			return 0, 0, err
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return 0, 0, err
+		}
+		point, _ := asn1.Marshal(elliptic.Marshal(curve, key.X, key.Y))
+		public = append(public, pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, point))
+		signer = key
+	case pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN:
+		// smaller than what is asked for, to keep the tests fast
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return 0, 0, err
+		}
+		public = append(public,
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, key.N.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, big.NewInt(int64(key.E)).Bytes()))
+		signer = key
+	default:
+		return 0, 0, pkcs11.Error(pkcs11.CKR_MECHANISM_INVALID)
+	}
+	c.token.generated++
+	pubObj := c.addObject(public)
+	privObj := c.addObject(private)
+	c.token.signers[privObj] = signer
+	return pubObj, privObj, nil
+}
+
+func (c *fakeCtx) SignInit(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error {
+	if !c.token.loggedIn {
+		return pkcs11.Error(pkcs11.CKR_USER_NOT_LOGGED_IN)
+	}
+	signer, ok := c.token.signers[o]
+	if !ok {
+		return pkcs11.Error(pkcs11.CKR_KEY_HANDLE_INVALID)
+	}
+	c.token.signingKey = signer
+	c.token.mechanism = m[0].Mechanism
+	return nil
+}
+
+func (c *fakeCtx) Sign(sh pkcs11.SessionHandle, message []byte) ([]byte, error) {
+	key := c.token.signingKey
+	c.token.signingKey = nil
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		if c.token.mechanism != pkcs11.CKM_ECDSA {
+			return nil, pkcs11.Error(pkcs11.CKR_MECHANISM_INVALID)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, message)
+		if err != nil {
+			return nil, err
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return append(padBytes(r.Bytes(), size), padBytes(s.Bytes(), size)...), nil
+	case *rsa.PrivateKey:
+		switch c.token.mechanism {
+		case pkcs11.CKM_RSA_PKCS_PSS:
+			// the parameters are not decoded, notary only uses SHA256
+			return rsa.SignPSS(rand.Reader, k, crypto.SHA256, message,
+				&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		case pkcs11.CKM_RSA_PKCS:
+			return rsa.SignPKCS1v15(rand.Reader, k, 0, message)
+		}
+		return nil, pkcs11.Error(pkcs11.CKR_MECHANISM_INVALID)
+	}
+	return nil, pkcs11.Error(pkcs11.CKR_OPERATION_NOT_INITIALIZED)
+}
+
+func hasAttribute(attrs []*pkcs11.Attribute, want *pkcs11.Attribute) bool {
+	for _, attr := range attrs {
+		if attr.Type == want.Type && bytes.Equal(attr.Value, want.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func bigAttribute(attrs []*pkcs11.Attribute, typ uint) *big.Int {
+	for _, attr := range attrs {
+		if attr.Type == typ {
+			return new(big.Int).SetBytes(attr.Value)
+		}
+	}
+	return nil
+}
+
+func attributeCurve(attrs []*pkcs11.Attribute) (elliptic.Curve, error) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384()} {
+		params, _ := curveParams(curve)
+		if hasAttribute(attrs, pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params)) {
+			return curve, nil
+		}
+	}
+	return nil, errors.New("unsupported curve")
+}
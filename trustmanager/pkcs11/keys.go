@@ -0,0 +1,437 @@
+// +build pkcs11
+
+package pkcs11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/miekg/pkcs11"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// the size of RSA keys generated in the token
+const rsaKeySize = 4096
+
+var (
+	oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+
+	// DER DigestInfo prefixes for PKCS #1 v1.5 signatures, as in crypto/rsa
+	pkcs1Prefixes = map[crypto.Hash][]byte{
+		crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+		crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+		crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+	}
+
+	// PKCS#11 hash mechanisms and MGF1 functions for RSA-PSS signatures
+	pssHashes = map[crypto.Hash][2]uint{
+		crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+		crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+		crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+	}
+)
+
+// curveParams returns the DER encoded CKA_EC_PARAMS of a curve
+func curveParams(curve elliptic.Curve) ([]byte, error) {
+	switch curve {
+	case elliptic.P256():
+		return asn1.Marshal(oidP256)
+	case elliptic.P384():
+		return asn1.Marshal(oidP384)
+	}
+	return nil, fmt.Errorf("unsupported curve %s", curve.Params().Name)
+}
+
+// algorithmCurve returns the curve of an ECDSA key algorithm
+func algorithmCurve(algorithm string) (elliptic.Curve, bool) {
+	switch algorithm {
+	case data.ECDSAKey:
+		return elliptic.P256(), true
+	case data.ECDSAP384Key:
+		return elliptic.P384(), true
+	}
+	return nil, false
+}
+
+// commonTemplate returns the attributes shared by both objects of a key
+func commonTemplate(class, keyType uint, id []byte, label string) []*pkcs11.Attribute {
+	return []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+}
+
+// privateTemplate returns the attributes of a private key object that can
+// only be used for signing, and never leaves the token
+func privateTemplate(keyType uint, id []byte, label string) []*pkcs11.Attribute {
+	return append(commonTemplate(pkcs11.CKO_PRIVATE_KEY, keyType, id, label),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+	)
+}
+
+// publicTemplate returns the attributes of a public key object
+func publicTemplate(keyType uint, id []byte, label string) []*pkcs11.Attribute {
+	return append(commonTemplate(pkcs11.CKO_PUBLIC_KEY, keyType, id, label),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+	)
+}
+
+// importTemplates returns the templates of the public and private key
+// objects which import a private key into the token
+func importTemplates(privKey data.PrivateKey, id []byte, label string) (pub, priv []*pkcs11.Attribute, err error) {
+	switch privKey.Algorithm() {
+	case data.ECDSAKey, data.ECDSAP384Key:
+		ecdsaPrivKey, err := x509.ParseECPrivateKey(privKey.Private())
+		if err != nil {
+			return nil, nil, err
+		}
+		params, err := curveParams(ecdsaPrivKey.Curve)
+		if err != nil {
+			return nil, nil, err
+		}
+		point, err := asn1.Marshal(elliptic.Marshal(ecdsaPrivKey.Curve, ecdsaPrivKey.X, ecdsaPrivKey.Y))
+		if err != nil {
+			return nil, nil, err
+		}
+		size := (ecdsaPrivKey.Curve.Params().BitSize + 7) / 8
+		pub = append(publicTemplate(pkcs11.CKK_EC, id, label),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, point),
+		)
+		priv = append(privateTemplate(pkcs11.CKK_EC, id, label),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, padBytes(ecdsaPrivKey.D.Bytes(), size)),
+		)
+		return pub, priv, nil
+	case data.RSAKey:
+		rsaPrivKey, err := x509.ParsePKCS1PrivateKey(privKey.Private())
+		if err != nil {
+			return nil, nil, err
+		}
+		rsaPrivKey.Precompute()
+		exponent := big.NewInt(int64(rsaPrivKey.E)).Bytes()
+		pub = append(publicTemplate(pkcs11.CKK_RSA, id, label),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, rsaPrivKey.N.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, exponent),
+		)
+		priv = append(privateTemplate(pkcs11.CKK_RSA, id, label),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, rsaPrivKey.N.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, exponent),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE_EXPONENT, rsaPrivKey.D.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIME_1, rsaPrivKey.Primes[0].Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIME_2, rsaPrivKey.Primes[1].Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_EXPONENT_1, rsaPrivKey.Precomputed.Dp.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_EXPONENT_2, rsaPrivKey.Precomputed.Dq.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_COEFFICIENT, rsaPrivKey.Precomputed.Qinv.Bytes()),
+		)
+		return pub, priv, nil
+	}
+	return nil, nil, fmt.Errorf("PKCS#11 tokens do not support storing %s keys", privKey.Algorithm())
+}
+
+// generateTemplates returns the mechanism and templates of the public and
+// private key objects which generate a key of the given algorithm
+func generateTemplates(algorithm string, id []byte, label string) (m *pkcs11.Mechanism, pub, priv []*pkcs11.Attribute, err error) {
+	if curve, ok := algorithmCurve(algorithm); ok {
+		params, err := curveParams(curve)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pub = append(publicTemplate(pkcs11.CKK_EC, id, label),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params))
+		priv = privateTemplate(pkcs11.CKK_EC, id, label)
+		return pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil), pub, priv, nil
+	}
+	if algorithm == data.RSAKey {
+		pub = append(publicTemplate(pkcs11.CKK_RSA, id, label),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, rsaKeySize),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}))
+		priv = privateTemplate(pkcs11.CKK_RSA, id, label)
+		return pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil), pub, priv, nil
+	}
+	return nil, nil, nil, fmt.Errorf("PKCS#11 tokens do not support generating %s keys", algorithm)
+}
+
+// getPublicKey reads the public key of a public key object in the token
+func getPublicKey(ctx IPKCS11Ctx, session pkcs11.SessionHandle, obj pkcs11.ObjectHandle) (data.PublicKey, error) {
+	attrs, err := ctx.GetAttributeValue(session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) != 1 {
+		return nil, errors.New("public key has no key type")
+	}
+
+	// CK_ULONGs are in the native byte order, so compare them encoded
+	switch keyType := attrs[0].Value; {
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC).Value):
+		return getECDSAPublicKey(ctx, session, obj)
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA).Value):
+		return getRSAPublicKey(ctx, session, obj)
+	}
+	return nil, errors.New("public key is neither an ECDSA nor an RSA key")
+}
+
+func getECDSAPublicKey(ctx IPKCS11Ctx, session pkcs11.SessionHandle, obj pkcs11.ObjectHandle) (data.PublicKey, error) {
+	attrs, err := ctx.GetAttributeValue(session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var params, point []byte
+	for _, a := range attrs {
+		switch a.Type {
+		case pkcs11.CKA_EC_PARAMS:
+			params = a.Value
+		case pkcs11.CKA_EC_POINT:
+			point = a.Value
+		}
+	}
+
+	var (
+		oid   asn1.ObjectIdentifier
+		curve elliptic.Curve
+	)
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, fmt.Errorf("invalid EC parameters: %v", err)
+	}
+	switch {
+	case oid.Equal(oidP256):
+		curve = elliptic.P256()
+	case oid.Equal(oidP384):
+		curve = elliptic.P384()
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", oid)
+	}
+
+	// the point should be a DER encoded octet string, but some modules
+	// return it unwrapped
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) > 0 {
+		raw = point
+	}
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, errors.New("invalid EC point")
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+	if err != nil {
+		return nil, err
+	}
+	return data.NewECDSAPublicKey(pubBytes), nil
+}
+
+// withAlgorithm returns the public key read from the token as a key of the
+// given explicit type, if there is one
+func withAlgorithm(pubKey data.PublicKey, algorithm string) (data.PublicKey, error) {
+	if algorithm != data.ECDSAP384Key {
+		return pubKey, nil
+	}
+	ecdsaPubKey, err := x509.ParsePKIXPublicKey(pubKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := ecdsaPubKey.(*ecdsa.PublicKey); !ok || k.Curve != elliptic.P384() {
+		return nil, errors.New("ecdsa-p384 key must use the P-384 curve")
+	}
+	return data.NewECDSAP384PublicKey(pubKey.Public()), nil
+}
+
+func getRSAPublicKey(ctx IPKCS11Ctx, session pkcs11.SessionHandle, obj pkcs11.ObjectHandle) (data.PublicKey, error) {
+	attrs, err := ctx.GetAttributeValue(session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	rsaPubKey := &rsa.PublicKey{}
+	for _, a := range attrs {
+		switch a.Type {
+		case pkcs11.CKA_MODULUS:
+			rsaPubKey.N = new(big.Int).SetBytes(a.Value)
+		case pkcs11.CKA_PUBLIC_EXPONENT:
+			rsaPubKey.E = int(new(big.Int).SetBytes(a.Value).Int64())
+		}
+	}
+	if rsaPubKey.N == nil || rsaPubKey.E == 0 {
+		return nil, errors.New("incomplete RSA public key")
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(rsaPubKey)
+	if err != nil {
+		return nil, err
+	}
+	return data.NewRSAPublicKey(pubBytes), nil
+}
+
+// padBytes left-zero-pads b to size bytes
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+// PrivateKey represents a private key inside of a PKCS#11 token
+type PrivateKey struct {
+	data.PublicKey
+	store *Store
+	id    []byte
+}
+
+// Private is not implemented in hardware keys
+func (k *PrivateKey) Private() []byte {
+	// We cannot return the private material from the token
+	return nil
+}
+
+// SignatureAlgorithm returns which algorithm this key uses to sign
+func (k *PrivateKey) SignatureAlgorithm() data.SigAlgorithm {
+	switch k.Algorithm() {
+	case data.RSAKey:
+		return data.RSAPSSSignature
+	case data.ECDSAP384Key:
+		return data.ECDSAP384Signature
+	}
+	return data.ECDSASignature
+}
+
+// CryptoSigner returns a crypto.Signer that signs digests using the token.
+// Needed for certificate generation only.
+func (k *PrivateKey) CryptoSigner() crypto.Signer {
+	return &signer{key: k}
+}
+
+// Sign hashes msg and has the token sign the digest, returning the signature
+// in the format notary uses for the key's signature algorithm
+func (k *PrivateKey) Sign(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch k.Algorithm() {
+	case data.RSAKey:
+		digest := sha256.Sum256(msg)
+		return k.signPSS(digest[:], crypto.SHA256, sha256.Size)
+	case data.ECDSAP384Key:
+		digest := sha512.Sum384(msg)
+		return k.signECDSA(digest[:])
+	}
+	digest := sha256.Sum256(msg)
+	return k.signECDSA(digest[:])
+}
+
+// signECDSA returns the r||s signature of a digest, each of which is as long
+// as the key's curve size
+func (k *PrivateKey) signECDSA(digest []byte) ([]byte, error) {
+	octetLength, err := k.octetLength()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := k.store.sign(k.id, pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil), digest)
+	if err != nil {
+		return nil, err
+	}
+	// PKCS#11 ECDSA signatures are already r||s, including leading zeros
+	if len(sig) != 2*octetLength {
+		return nil, fmt.Errorf("invalid signature from the PKCS#11 token: expected %d bytes, got %d", 2*octetLength, len(sig))
+	}
+	return sig, nil
+}
+
+// octetLength returns the length in bytes of each half of the ECDSA signatures
+// of the key, which depends on its curve rather than on its type
+func (k *PrivateKey) octetLength() (int, error) {
+	publicKey, err := x509.ParsePKIXPublicKey(k.Public())
+	if err != nil {
+		return 0, err
+	}
+	ecdsaPubKey, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return 0, errors.New("key is not an ECDSA key")
+	}
+	return (ecdsaPubKey.Curve.Params().BitSize + 7) >> 3, nil
+}
+
+func (k *PrivateKey) signPSS(digest []byte, hash crypto.Hash, saltLength int) ([]byte, error) {
+	mechanisms, ok := pssHashes[hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash for RSA-PSS: %v", hash)
+	}
+	params := pkcs11.NewPSSParams(mechanisms[0], mechanisms[1], uint(saltLength))
+	return k.store.sign(k.id, pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params), digest)
+}
+
+func (k *PrivateKey) signPKCS1v15(digest []byte, hash crypto.Hash) ([]byte, error) {
+	prefix, ok := pkcs1Prefixes[hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash for RSA PKCS #1 v1.5: %v", hash)
+	}
+	return k.store.sign(k.id, pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil),
+		append(append([]byte{}, prefix...), digest...))
+}
+
+type ecdsaSig struct {
+	R, S *big.Int
+}
+
+// signer implements crypto.Signer for a PrivateKey, signing digests
+type signer struct {
+	key *PrivateKey
+}
+
+// Public returns the crypto public key
+func (s *signer) Public() crypto.PublicKey {
+	publicKey, err := x509.ParsePKIXPublicKey(s.key.Public())
+	if err != nil {
+		return nil
+	}
+	return publicKey
+}
+
+// Sign signs a digest, returning ASN.1 encoded ECDSA signatures, and RSA-PSS
+// or PKCS #1 v1.5 signatures depending on opts, as crypto/x509 expects
+func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if s.key.Algorithm() == data.RSAKey {
+		pssOpts, ok := opts.(*rsa.PSSOptions)
+		if !ok {
+			return s.key.signPKCS1v15(digest, opts.HashFunc())
+		}
+		saltLength := pssOpts.SaltLength
+		if saltLength == rsa.PSSSaltLengthEqualsHash || saltLength == rsa.PSSSaltLengthAuto {
+			saltLength = opts.HashFunc().Size()
+		}
+		return s.key.signPSS(digest, opts.HashFunc(), saltLength)
+	}
+
+	sig, err := s.key.signECDSA(digest)
+	if err != nil {
+		return nil, err
+	}
+	octetLength := len(sig) / 2
+	return asn1.Marshal(ecdsaSig{
+		R: new(big.Int).SetBytes(sig[:octetLength]),
+		S: new(big.Int).SetBytes(sig[octetLength:]),
+	})
+}
@@ -0,0 +1,535 @@
+// +build pkcs11
+
+package pkcs11
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/sirupsen/logrus"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+const (
+	userPinPrompt = "User Pin"
+	// the number of objects to ask for per call to FindObjects
+	findBatchSize = 16
+	// the length of the random CKA_ID given to keys generated on the token
+	generatedIDSize = 16
+	// the prefix of the CKA_LABEL of ecdsa-p384 keys
+	p384LabelPrefix = data.ECDSAP384Key + ":"
+)
+
+// ErrTokenNotFound is returned when none of the slots of the module holds the
+// configured token
+type ErrTokenNotFound struct {
+	Token string
+}
+
+func (err ErrTokenNotFound) Error() string {
+	return fmt.Sprintf("no PKCS#11 token found matching %s", err.Token)
+}
+
+// tokenKey is a key kept in the token, as found by listing its public keys
+type tokenKey struct {
+	// id is the CKA_ID shared by the public and private key objects
+	id   []byte
+	role data.RoleName
+	gun  data.GUN
+	pub  data.PublicKey
+}
+
+// Store is a KeyStore for private keys inside a PKCS#11 token.  The objects of
+// each key are labelled with the key's role and GUN, and other objects in the
+// token are ignored.
+type Store struct {
+	// mu serializes sessions, because logging out of the last session logs
+	// every session out of the token
+	mu            sync.Mutex
+	config        Config
+	passRetriever notary.PassRetriever
+	ctx           IPKCS11Ctx
+	slot          uint
+	// pin is the PIN of the last successful login, if it was asked for
+	pin       string
+	configPIN bool
+	keys      map[string]tokenKey
+}
+
+// NewStore loads the PKCS#11 module in the Config and returns a Store for the
+// keys in the configured token.  If the Config does not provide a PIN, it is
+// asked for using passRetriever the first time it is needed.  Close should
+// be called when the Store is no longer needed.
+func NewStore(config Config, passRetriever notary.PassRetriever) (*Store, error) {
+	return newStore(config, passRetriever, defaultLoader)
+}
+
+func newStore(config Config, passRetriever notary.PassRetriever, libLoader pkcs11LibLoader) (*Store, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	pin, configPIN, err := config.PIN()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := libLoader(config.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", config.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("found PKCS#11 module %s, but initialize error %s", config.Module, err)
+	}
+	slot, err := findSlot(ctx, config)
+	if err != nil {
+		finalizeAndDestroy(ctx)
+		return nil, err
+	}
+
+	s := &Store{
+		config:        config,
+		passRetriever: passRetriever,
+		ctx:           ctx,
+		slot:          slot,
+		pin:           pin,
+		configPIN:     configPIN,
+		keys:          make(map[string]tokenKey),
+	}
+	if err := s.refresh(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	logrus.Debugf("Initialized PKCS#11 module %s for token %s", config.Module, config.Token())
+	return s, nil
+}
+
+// findSlot returns the slot holding the token selected by the Config
+func findSlot(ctx IPKCS11Ctx, config Config) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots: %v", err)
+	}
+	for _, slot := range slots {
+		if config.TokenLabel == "" {
+			if slot == *config.Slot {
+				return slot, nil
+			}
+			continue
+		}
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			logrus.Debugf("Failed to get the token info of slot %d: %v", slot, err)
+			continue
+		}
+		if info.Label == config.TokenLabel {
+			return slot, nil
+		}
+	}
+	return 0, ErrTokenNotFound{Token: config.Token()}
+}
+
+// Name returns a user friendly name for the location this store
+// keeps its data
+func (s *Store) Name() string {
+	return "pkcs11 token " + s.config.Token()
+}
+
+// Close unloads the PKCS#11 module.  The Store cannot be used afterwards.
+func (s *Store) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finalizeAndDestroy(s.ctx)
+}
+
+// CheckHealth returns an error if the token cannot be reached
+func (s *Store) CheckHealth() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.ctx.GetTokenInfo(s.slot)
+	return err
+}
+
+// ListKeys returns the keys in the token
+func (s *Store) ListKeys() map[string]trustmanager.KeyInfo {
+	if err := s.refresh(); err != nil {
+		logrus.Debugf("Failed to list the keys in the PKCS#11 token: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make(map[string]trustmanager.KeyInfo)
+	for keyID, key := range s.keys {
+		res[keyID] = trustmanager.KeyInfo{Role: key.role, Gun: key.gun}
+	}
+	return res
+}
+
+// AddKey imports a key into the token.  ECDSA keys on the P-256 and P-384
+// curves, and RSA keys, are supported.
+func (s *Store) AddKey(keyInfo trustmanager.KeyInfo, privKey data.PrivateKey) error {
+	keyID := privKey.ID()
+	if _, ok := s.getKey(keyID); ok {
+		return nil
+	}
+	id, err := hex.DecodeString(keyID)
+	if err != nil {
+		return fmt.Errorf("invalid key ID %s: %v", keyID, err)
+	}
+	label := keyLabel(keyInfo.Role, keyInfo.Gun, privKey.Algorithm())
+	publicTemplate, privateTemplate, err := importTemplates(privKey, id, label)
+	if err != nil {
+		return err
+	}
+
+	err = s.withSession(true, func(session pkcs11.SessionHandle) error {
+		if _, err := s.ctx.CreateObject(session, privateTemplate); err != nil {
+			return fmt.Errorf("error importing private key: %v", err)
+		}
+		if _, err := s.ctx.CreateObject(session, publicTemplate); err != nil {
+			return fmt.Errorf("error importing public key: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.setKey(keyID, tokenKey{
+		id:   id,
+		role: keyInfo.Role,
+		gun:  keyInfo.Gun,
+		pub:  data.PublicKeyFromPrivate(privKey),
+	})
+	return nil
+}
+
+// GenerateKey generates a key of the given algorithm inside the token, so
+// that its private material never leaves it, and returns its public key
+func (s *Store) GenerateKey(role data.RoleName, gun data.GUN, algorithm string) (data.PublicKey, error) {
+	id := make([]byte, generatedIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	mechanism, publicTemplate, privateTemplate, err := generateTemplates(algorithm, id, keyLabel(role, gun, algorithm))
+	if err != nil {
+		return nil, err
+	}
+
+	var pubKey data.PublicKey
+	err = s.withSession(true, func(session pkcs11.SessionHandle) error {
+		pubObj, _, err := s.ctx.GenerateKeyPair(session, []*pkcs11.Mechanism{mechanism}, publicTemplate, privateTemplate)
+		if err != nil {
+			return fmt.Errorf("failed to generate %s key in the token: %v", algorithm, err)
+		}
+		pubKey, err = getPublicKey(s.ctx, session, pubObj)
+		if err != nil {
+			return err
+		}
+		pubKey, err = withAlgorithm(pubKey, algorithm)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("generated new %s key in the PKCS#11 token for role: %s and keyID: %s", algorithm, role.String(), pubKey.ID())
+	s.setKey(pubKey.ID(), tokenKey{id: id, role: role, gun: gun, pub: pubKey})
+	return pubKey, nil
+}
+
+// GetKey returns a PrivateKey which signs using the key in the token
+func (s *Store) GetKey(keyID string) (data.PrivateKey, data.RoleName, error) {
+	key, ok := s.getKey(keyID)
+	if !ok {
+		// the key may have been added by someone else
+		if err := s.refresh(); err != nil {
+			return nil, "", err
+		}
+		if key, ok = s.getKey(keyID); !ok {
+			return nil, "", trustmanager.ErrKeyNotFound{KeyID: keyID}
+		}
+	}
+	return &PrivateKey{PublicKey: key.pub, store: s, id: key.id}, key.role, nil
+}
+
+// GetKeyInfo returns the role and GUN of a key in the token
+func (s *Store) GetKeyInfo(keyID string) (trustmanager.KeyInfo, error) {
+	key, ok := s.getKey(keyID)
+	if !ok {
+		return trustmanager.KeyInfo{}, fmt.Errorf("Could not find info for keyID %s", keyID)
+	}
+	return trustmanager.KeyInfo{Role: key.role, Gun: key.gun}, nil
+}
+
+// RemoveKey deletes the objects of a key from the token
+func (s *Store) RemoveKey(keyID string) error {
+	key, ok := s.getKey(keyID)
+	if !ok {
+		return nil
+	}
+	err := s.withSession(true, func(session pkcs11.SessionHandle) error {
+		objs, err := findObjects(s.ctx, session, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, key.id),
+		})
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			if err := s.ctx.DestroyObject(session, obj); err != nil {
+				return fmt.Errorf("failed to remove key %s from the token: %v", keyID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.keys, keyID)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Store) getKey(keyID string) (tokenKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[keyID]
+	return key, ok
+}
+
+func (s *Store) setKey(keyID string, key tokenKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[keyID] = key
+}
+
+// refresh replaces the cached keys with the keys listed in the token.  Public
+// key objects are not private, so no login is needed.
+func (s *Store) refresh() error {
+	keys := make(map[string]tokenKey)
+	err := s.withSession(false, func(session pkcs11.SessionHandle) error {
+		objs, err := findObjects(s.ctx, session, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		})
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			attrs, err := s.ctx.GetAttributeValue(session, obj, []*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+				pkcs11.NewAttribute(pkcs11.CKA_LABEL, nil),
+			})
+			if err != nil {
+				logrus.Debugf("Failed to get the attributes of object %v: %v", obj, err)
+				continue
+			}
+			var (
+				id    []byte
+				label string
+			)
+			for _, a := range attrs {
+				switch a.Type {
+				case pkcs11.CKA_ID:
+					id = a.Value
+				case pkcs11.CKA_LABEL:
+					label = string(a.Value)
+				}
+			}
+			role, gun, algorithm, ok := parseKeyLabel(label)
+			if !ok || len(id) == 0 {
+				// not one of our keys
+				continue
+			}
+			pubKey, err := getPublicKey(s.ctx, session, obj)
+			if err == nil {
+				pubKey, err = withAlgorithm(pubKey, algorithm)
+			}
+			if err != nil {
+				logrus.Debugf("Skipping key %s in the PKCS#11 token: %v", label, err)
+				continue
+			}
+			keys[pubKey.ID()] = tokenKey{id: id, role: role, gun: gun, pub: pubKey}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+// sign signs the payload with the private key object with the given CKA_ID
+func (s *Store) sign(id []byte, mechanism *pkcs11.Mechanism, payload []byte) ([]byte, error) {
+	var sig []byte
+	err := s.withSession(true, func(session pkcs11.SessionHandle) error {
+		objs, err := findObjects(s.ctx, session, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		})
+		if err != nil {
+			return err
+		}
+		if len(objs) != 1 {
+			return fmt.Errorf("expected 1 private key in the token, found %d", len(objs))
+		}
+		if err := s.ctx.SignInit(session, []*pkcs11.Mechanism{mechanism}, objs[0]); err != nil {
+			return err
+		}
+		// a call to Sign, whether or not Sign fails, will clear the SignInit
+		sig, err = s.ctx.Sign(session, payload)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign using the PKCS#11 token: %v", err)
+	}
+	if len(sig) == 0 {
+		return nil, errors.New("failed to sign using the PKCS#11 token: empty signature")
+	}
+	return sig, nil
+}
+
+// withSession runs f in a new read/write session with the token, logged in
+// as the user if login is true
+func (s *Store) withSession(login bool, f func(pkcs11.SessionHandle) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// CKF_SERIAL_SESSION: TRUE if cryptographic functions are performed in serial with the application; FALSE if the functions may be performed in parallel with the application.
+	// CKF_RW_SESSION: TRUE if the session is read/write; FALSE if the session is read-only
+	session, err := s.ctx.OpenSession(s.slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return fmt.Errorf("failed to start a session with PKCS#11 token %s: %v", s.config.Token(), err)
+	}
+	// closing the only session also logs out
+	defer func() {
+		if err := s.ctx.CloseSession(session); err != nil {
+			logrus.Debugf("Error closing session: %s", err.Error())
+		}
+	}()
+
+	if login {
+		if err := s.login(session); err != nil {
+			return err
+		}
+	}
+	return f(session)
+}
+
+// login logs in as the user, using the configured PIN, or else the PIN that
+// last worked, or else asking for the PIN
+func (s *Store) login(session pkcs11.SessionHandle) error {
+	if s.pin != "" {
+		err := loginErr(s.ctx.Login(session, pkcs11.CKU_USER, s.pin))
+		if err == nil {
+			return nil
+		}
+		if s.configPIN {
+			return fmt.Errorf("failed to log in to PKCS#11 token %s: %v", s.config.Token(), err)
+		}
+		s.pin = ""
+	}
+	if s.passRetriever == nil {
+		return trustmanager.ErrPasswordInvalid{}
+	}
+
+	for attempts := 0; ; attempts++ {
+		pin, giveup, err := s.passRetriever(userPinPrompt, s.config.Token(), false, attempts)
+		// Check if the passphrase retriever got an error or if it is telling us to give up
+		if giveup || err != nil {
+			return trustmanager.ErrPasswordInvalid{}
+		}
+		if attempts > 2 {
+			return trustmanager.ErrAttemptsExceeded{}
+		}
+
+		// attempt to login. Loop if failed
+		if err := loginErr(s.ctx.Login(session, pkcs11.CKU_USER, pin)); err == nil {
+			s.pin = pin
+			return nil
+		}
+	}
+}
+
+// loginErr treats already being logged in as success
+func loginErr(err error) error {
+	if err == pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		return nil
+	}
+	return err
+}
+
+// findObjects returns all the objects in the token matching the template
+func findObjects(ctx IPKCS11Ctx, session pkcs11.SessionHandle, template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return nil, fmt.Errorf("failed to search the token: %v", err)
+	}
+	var objs []pkcs11.ObjectHandle
+	for {
+		o, _, err := ctx.FindObjects(session, findBatchSize)
+		if err != nil {
+			ctx.FindObjectsFinal(session)
+			return nil, fmt.Errorf("failed to search the token: %v", err)
+		}
+		if len(o) == 0 {
+			break
+		}
+		objs = append(objs, o...)
+	}
+	if err := ctx.FindObjectsFinal(session); err != nil {
+		return nil, fmt.Errorf("failed to search the token: %v", err)
+	}
+	return objs, nil
+}
+
+func finalizeAndDestroy(ctx IPKCS11Ctx) {
+	err := ctx.Finalize()
+	if err != nil {
+		logrus.Debugf("Error finalizing: %s", err.Error())
+	}
+	ctx.Destroy()
+}
+
+// keyLabel returns the CKA_LABEL of the objects of a key, which records its
+// role and GUN.  Role names cannot contain "@", so the label can be parsed
+// unambiguously.  The label of an ecdsa-p384 key is prefixed with its type,
+// because the token cannot tell it apart from an ecdsa key on the P-384 curve.
+func keyLabel(role data.RoleName, gun data.GUN, algorithm string) string {
+	label := role.String()
+	if gun != "" {
+		label += "@" + gun.String()
+	}
+	if algorithm == data.ECDSAP384Key {
+		label = p384LabelPrefix + label
+	}
+	return label
+}
+
+// parseKeyLabel returns the role, GUN and explicit key type recorded in a
+// CKA_LABEL, and whether the label is one written by keyLabel
+func parseKeyLabel(label string) (data.RoleName, data.GUN, string, bool) {
+	var algorithm string
+	if strings.HasPrefix(label, p384LabelPrefix) {
+		label = strings.TrimPrefix(label, p384LabelPrefix)
+		algorithm = data.ECDSAP384Key
+	}
+	parts := strings.SplitN(label, "@", 2)
+	role := data.RoleName(parts[0])
+	if !data.ValidRole(role) {
+		return "", "", "", false
+	}
+	if len(parts) == 1 {
+		return role, "", algorithm, true
+	}
+	return role, data.GUN(parts[1]), algorithm, true
+}
@@ -0,0 +1,236 @@
+// +build pkcs11
+
+package pkcs11
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+const (
+	testPIN    = "1234"
+	testPINEnv = "NOTARY_TEST_PKCS11_PIN"
+)
+
+func testConfig() Config {
+	return Config{Module: "/fake/module.so", TokenLabel: "notary", PINEnv: testPINEnv}
+}
+
+func newTestStore(t *testing.T, token *fakeToken) *Store {
+	require.NoError(t, os.Setenv(testPINEnv, testPIN))
+	s, err := newStore(testConfig(), nil, token.loader)
+	require.NoError(t, err)
+	return s
+}
+
+// requireSigns checks that a private key from the store produces signatures
+// which verify against its public key
+func requireSigns(t *testing.T, s *Store, keyID string) {
+	privKey, _, err := s.GetKey(keyID)
+	require.NoError(t, err)
+	msg := []byte("sign me")
+	sig, err := privKey.Sign(rand.Reader, msg, nil)
+	require.NoError(t, err)
+	v := signed.Verifiers[privKey.SignatureAlgorithm()]
+	require.NoError(t, v.Verify(data.PublicKeyFromPrivate(privKey), sig, msg))
+}
+
+func TestNewStoreFindsToken(t *testing.T) {
+	token := newFakeToken("notary", testPIN)
+
+	s, err := newStore(Config{Module: "/fake/module.so", TokenLabel: "notary"}, nil, token.loader)
+	require.NoError(t, err)
+	require.Equal(t, uint(5), s.slot)
+	require.Equal(t, "pkcs11 token notary", s.Name())
+
+	slot := uint(5)
+	s, err = newStore(Config{Module: "/fake/module.so", Slot: &slot}, nil, token.loader)
+	require.NoError(t, err)
+	require.Equal(t, uint(5), s.slot)
+
+	_, err = newStore(Config{Module: "/fake/module.so", TokenLabel: "other"}, nil, token.loader)
+	require.Equal(t, ErrTokenNotFound{Token: "other"}, err)
+
+	slot = 2
+	_, err = newStore(Config{Module: "/fake/module.so", Slot: &slot}, nil, token.loader)
+	require.Equal(t, ErrTokenNotFound{Token: "slot 2"}, err)
+}
+
+func TestGenerateKeyAndSign(t *testing.T) {
+	token := newFakeToken("notary", testPIN)
+	s := newTestStore(t, token)
+
+	for _, algorithm := range SupportedAlgorithms {
+		pubKey, err := s.GenerateKey(data.CanonicalRootRole, "", algorithm)
+		require.NoError(t, err, algorithm)
+		require.Equal(t, algorithm, pubKey.Algorithm())
+		requireSigns(t, s, pubKey.ID())
+	}
+	require.Equal(t, len(SupportedAlgorithms), token.generated)
+
+	_, err := s.GenerateKey(data.CanonicalRootRole, "", data.ED25519Key)
+	require.Error(t, err)
+}
+
+func TestAddKeyListAndRemove(t *testing.T) {
+	token := newFakeToken("notary", testPIN)
+	s := newTestStore(t, token)
+
+	ecdsaKey, err := utils.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := utils.GenerateECDSAP384Key(rand.Reader)
+	require.NoError(t, err)
+	// an ecdsa key on the P-384 curve keeps its ecdsa type
+	legacyP384Key := newECDSAP384CurveKey(t)
+	rsaPrivKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaKey, err := utils.RSAToPrivateKey(rsaPrivKey)
+	require.NoError(t, err)
+
+	require.NoError(t, s.AddKey(trustmanager.KeyInfo{Role: data.CanonicalRootRole}, ecdsaKey))
+	require.NoError(t, s.AddKey(trustmanager.KeyInfo{Role: data.CanonicalTargetsRole, Gun: "docker.io/library/alpine"}, p384Key))
+	require.NoError(t, s.AddKey(trustmanager.KeyInfo{Role: "targets/releases", Gun: "example.com/repo"}, rsaKey))
+	require.NoError(t, s.AddKey(trustmanager.KeyInfo{Role: data.CanonicalSnapshotRole, Gun: "docker.io/library/alpine"}, legacyP384Key))
+	// adding a key twice succeeds
+	require.NoError(t, s.AddKey(trustmanager.KeyInfo{Role: data.CanonicalRootRole}, ecdsaKey))
+
+	ed25519Key, err := utils.GenerateED25519Key(rand.Reader)
+	require.NoError(t, err)
+	require.Error(t, s.AddKey(trustmanager.KeyInfo{Role: data.CanonicalRootRole}, ed25519Key))
+
+	// objects which were not written by the store are ignored
+	(&fakeCtx{token: token}).addObject(publicTemplate(0, []byte{1}, "some other key"))
+
+	// a new store finds the keys in the token
+	expected := map[string]trustmanager.KeyInfo{
+		ecdsaKey.ID(): {Role: data.CanonicalRootRole},
+		p384Key.ID():  {Role: data.CanonicalTargetsRole, Gun: "docker.io/library/alpine"},
+		rsaKey.ID():   {Role: "targets/releases", Gun: "example.com/repo"},
+
+		legacyP384Key.ID(): {Role: data.CanonicalSnapshotRole, Gun: "docker.io/library/alpine"},
+	}
+	other := newTestStore(t, token)
+	require.Equal(t, expected, other.ListKeys())
+	for keyID, info := range expected {
+		requireSigns(t, other, keyID)
+		keyInfo, err := other.GetKeyInfo(keyID)
+		require.NoError(t, err)
+		require.Equal(t, info, keyInfo)
+
+		// the public key in the token is the one that was imported
+		privKey, role, err := other.GetKey(keyID)
+		require.NoError(t, err)
+		require.Equal(t, info.Role, role)
+		require.Equal(t, keyID, privKey.ID())
+		require.Nil(t, privKey.Private())
+	}
+
+	require.NoError(t, other.RemoveKey(rsaKey.ID()))
+	delete(expected, rsaKey.ID())
+	require.Equal(t, expected, s.ListKeys())
+	_, _, err = s.GetKey(rsaKey.ID())
+	require.Equal(t, trustmanager.ErrKeyNotFound{KeyID: rsaKey.ID()}, err)
+}
+
+func TestLoginAsksForPIN(t *testing.T) {
+	token := newFakeToken("notary", testPIN)
+	asked := 0
+	retriever := func(keyName, alias string, createNew bool, attempts int) (string, bool, error) {
+		asked++
+		require.Equal(t, userPinPrompt, keyName)
+		require.Equal(t, "notary", alias)
+		if attempts == 0 {
+			return "wrong", false, nil
+		}
+		return testPIN, false, nil
+	}
+	config := testConfig()
+	config.PINEnv = ""
+	s, err := newStore(config, retriever, token.loader)
+	require.NoError(t, err)
+	// listing keys does not need the PIN
+	require.Equal(t, 0, asked)
+
+	pubKey, err := s.GenerateKey(data.CanonicalRootRole, "", data.ECDSAKey)
+	require.NoError(t, err)
+	require.Equal(t, 2, asked)
+
+	// the PIN is remembered
+	requireSigns(t, s, pubKey.ID())
+	require.Equal(t, 2, asked)
+
+	// giving up fails the login
+	s, err = newStore(config, passphrase.ConstantRetriever("wrong"), token.loader)
+	require.NoError(t, err)
+	_, err = s.GenerateKey(data.CanonicalRootRole, "", data.ECDSAKey)
+	require.Equal(t, trustmanager.ErrAttemptsExceeded{}, err)
+
+	// a configured PIN is never asked for, even if it is wrong
+	require.NoError(t, os.Setenv(testPINEnv, "wrong"))
+	defer os.Unsetenv(testPINEnv)
+	s, err = newStore(testConfig(), retriever, token.loader)
+	require.NoError(t, err)
+	_, err = s.GenerateKey(data.CanonicalRootRole, "", data.ECDSAKey)
+	require.Error(t, err)
+	require.Equal(t, 2, asked)
+}
+
+func TestCertificateFromTokenKey(t *testing.T) {
+	token := newFakeToken("notary", testPIN)
+	s := newTestStore(t, token)
+
+	rsaPrivKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaKey, err := utils.RSAToPrivateKey(rsaPrivKey)
+	require.NoError(t, err)
+	require.NoError(t, s.AddKey(trustmanager.KeyInfo{Role: data.CanonicalRootRole}, rsaKey))
+	p384Key := newECDSAP384CurveKey(t)
+	require.NoError(t, s.AddKey(trustmanager.KeyInfo{Role: data.CanonicalRootRole}, p384Key))
+
+	for _, keyID := range []string{rsaKey.ID(), p384Key.ID()} {
+		privKey, _, err := s.GetKey(keyID)
+		require.NoError(t, err)
+		startTime := time.Now()
+		cert, err := cryptoservice.GenerateCertificate(privKey, "docker.io/library/alpine", startTime, startTime.AddDate(10, 0, 0))
+		require.NoError(t, err)
+		require.NoError(t, cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature))
+	}
+}
+
+// newECDSAP384CurveKey returns an ecdsa key on the P-384 curve
+func newECDSAP384CurveKey(t *testing.T) data.PrivateKey {
+	ecdsaPrivKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	privKey, err := utils.ECDSAToPrivateKey(ecdsaPrivKey)
+	require.NoError(t, err)
+	return privKey
+}
+
+func TestCryptoServiceCreatesKeysInToken(t *testing.T) {
+	token := newFakeToken("notary", testPIN)
+	cs := NewCryptoService(newTestStore(t, token))
+
+	pubKey, err := cs.Create(data.CanonicalTimestampRole, "docker.io/library/alpine", data.ECDSAKey)
+	require.NoError(t, err)
+	require.Equal(t, 1, token.generated)
+	require.Equal(t, []string{pubKey.ID()}, cs.ListKeys(data.CanonicalTimestampRole))
+
+	privKey, role, err := cs.GetPrivateKey(pubKey.ID())
+	require.NoError(t, err)
+	require.Equal(t, data.CanonicalTimestampRole, role)
+	require.Equal(t, pubKey.ID(), privKey.ID())
+	require.NoError(t, cs.CheckHealth())
+}
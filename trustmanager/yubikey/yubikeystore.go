@@ -706,6 +706,12 @@ func (s *YubiStore) addKey(keyID string, role data.RoleName, privKey data.Privat
 			"yubikey only supports storing root keys, got %s for key: %s", role, keyID)
 	}
 
+	// The yubikey only supports ECDSA keys on the P-256 curve
+	if privKey.Algorithm() != data.ECDSAKey {
+		return false, fmt.Errorf(
+			"yubikey only supports storing ecdsa keys, got %s for key: %s", privKey.Algorithm(), keyID)
+	}
+
 	ctx, session, err := SetupHSMEnv(pkcs11Lib, s.libLoader)
 	if err != nil {
 		logrus.Debugf("No yubikey found, using alternative key storage: %s", err.Error())
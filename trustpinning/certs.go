@@ -68,12 +68,23 @@ GUN, we attempt to validate that the certificates present in the downloaded root
 file match the pinned ID.
 
 If the Certs section is empty for this GUN, we check if the trust_pinning
+section specifies an SPKI section with this GUN.  If so, we attempt to validate
+that the public keys of the certificates present in the downloaded root file
+match the pinned hashes.
+
+If the SPKI section is also empty for this GUN, we check if the trust_pinning
 section specifies a CA section specified in the config for this GUN.  If so, we check
 that the specified CA is valid and has signed a certificate included in the downloaded
-root file.  The specified CA can be a prefix for this GUN.
+root file.  The specified CA can be a prefix for this GUN.  If the trust_pinning section
+also specifies CRLs for this GUN, or OCSP responses have been stapled to the root,
+certificates which they revoke are not trusted, and neither are certificates whose
+CRL or stapled OCSP response is out of date.
+
+When pinning to Certs, SPKI or a CA, at least as many of the root keys as
+MinPinnedKeys sets for this GUN, or a wildcard matching it, have to match.
 
-If both the Certs and CA configs do not match this GUN, we fall back to the TOFU
-section in the config: if true, we trust certificates specified in the root for
+If none of the Certs, SPKI and CA configs match this GUN, we fall back to TOFUS,
+unless it is disabled for this GUN: we trust certificates specified in the root for
 this GUN. If later we see a different certificate for that certificate, we return
 an ErrValidationFailed error.
 
@@ -143,7 +154,7 @@ func ValidateRoot(prevRoot *data.SignedRoot, root *data.Signed, gun data.GUN, tr
 
 	// Regardless of having a previous root or not, confirm that the new root validates against the trust pinning
 	logrus.Debugf("checking root against trust_pinning config for %s", gun)
-	trustPinCheckFunc, err := NewTrustPinChecker(trustPinning, gun, !havePrevRoot)
+	trustPinChecker, trustPinCheckFunc, err := newTrustPinChecker(trustPinning, gun, !havePrevRoot)
 	if err != nil {
 		return nil, &ErrValidationFail{Reason: err.Error()}
 	}
@@ -158,8 +169,16 @@ func ValidateRoot(prevRoot *data.SignedRoot, root *data.Signed, gun data.GUN, tr
 		validPinnedCerts[id] = cert
 	}
 	if len(validPinnedCerts) == 0 {
+		if trustPinChecker.revocation != nil && len(trustPinChecker.revocation.revoked) > 0 {
+			return nil, &ErrValidationFail{Reason: trustPinChecker.revocation.revoked[0].Error()}
+		}
 		return nil, &ErrValidationFail{Reason: "unable to match any certificates to trust_pinning config"}
 	}
+	if min := minPinnedKeys(gun, trustPinning); trustPinChecker.pinned() && len(validPinnedCerts) < min {
+		return nil, &ErrValidationFail{Reason: fmt.Sprintf(
+			"only %d root keys match the trust_pinning config, but %d are required",
+			len(validPinnedCerts), min)}
+	}
 	certsFromRoot = validPinnedCerts
 
 	// Validate the integrity of the new root (does it have valid signatures)
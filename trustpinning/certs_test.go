@@ -2,13 +2,19 @@ package trustpinning_test
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -253,6 +259,45 @@ func TestValidateRootWithPinnedCert(t *testing.T) {
 	require.Equal(t, typedSignedRoot, validatedSignedRoot)
 }
 
+func TestValidateRootWithPinnedP384Cert(t *testing.T) {
+	var gun data.GUN = "docker.com/notary"
+	cs := cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
+
+	// TUF key with PEM-encoded x509 certificate for an ecdsa key on the P-384 curve
+	ecdsaPrivKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	privKey, err := utils.ECDSAToPrivateKey(ecdsaPrivKey)
+	require.NoError(t, err)
+	require.NoError(t, cs.AddKey(data.CanonicalRootRole, gun, privKey))
+	startTime := time.Now()
+	cert, err := cryptoservice.GenerateCertificate(privKey, gun, startTime, startTime.AddDate(1, 0, 0))
+	require.NoError(t, err)
+	rootKey := data.NewECDSAx509PublicKey(utils.CertToPEM(cert))
+
+	rootRole, err := data.NewRole(data.CanonicalRootRole, 1, []string{rootKey.ID()}, nil)
+	require.NoError(t, err)
+	testRoot, err := data.NewRoot(
+		map[string]data.PublicKey{rootKey.ID(): rootKey},
+		map[data.RoleName]*data.RootRole{
+			data.CanonicalRootRole:      &rootRole.RootRole,
+			data.CanonicalTimestampRole: &rootRole.RootRole,
+			data.CanonicalTargetsRole:   &rootRole.RootRole,
+			data.CanonicalSnapshotRole:  &rootRole.RootRole},
+		false,
+	)
+	require.NoError(t, err)
+	testRoot.Signed.Version = 1
+	signedTestRoot, err := testRoot.ToSigned()
+	require.NoError(t, err)
+	require.NoError(t, signed.Sign(cs, signedTestRoot, []data.PublicKey{rootKey}, 1, nil))
+	require.Equal(t, data.ECDSASignature, signedTestRoot.Signatures[0].Method)
+
+	validatedRoot, err := trustpinning.ValidateRoot(nil, signedTestRoot, gun,
+		trustpinning.TrustPinConfig{Certs: map[string][]string{gun.String(): {rootKey.ID()}}, DisableTOFU: true})
+	require.NoError(t, err)
+	require.True(t, validatedRoot.Signatures[0].IsValid)
+}
+
 func TestValidateRootWithPinnedCertAndIntermediates(t *testing.T) {
 	now := time.Now()
 	memStore := trustmanager.NewKeyMemoryStore(passphraseRetriever)
@@ -1317,3 +1362,202 @@ func TestWildcardMatching(t *testing.T) {
 		require.Equal(t, trustpinning.MatchCNToGun(tt.CN, data.GUN(tt.gun)), tt.out)
 	}
 }
+
+func spkiHash(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestValidateRootWithPinnedSPKI(t *testing.T) {
+	f := newPinnedRootFixture(t)
+	defer os.RemoveAll(f.dir)
+	leafSPKI := spkiHash(f.leafCert)
+
+	// The SPKI hash of the leaf cert can be pinned for the GUN, or a wildcard
+	require.NoError(t, validateRoot(f.root, trustpinning.TrustPinConfig{
+		SPKI: map[string][]string{pinnedRootGUN.String(): {leafSPKI}}, DisableTOFU: true}))
+	require.NoError(t, validateRoot(f.root, trustpinning.TrustPinConfig{
+		SPKI: map[string][]string{"docker.io/notary/*": {strings.ToUpper(leafSPKI)}}, DisableTOFU: true}))
+
+	// The SPKI hash of the intermediate is not enough
+	err := validateRoot(f.root, trustpinning.TrustPinConfig{
+		SPKI: map[string][]string{pinnedRootGUN.String(): {spkiHash(f.intCert)}}, DisableTOFU: true})
+	require.Error(t, err)
+	require.IsType(t, &trustpinning.ErrValidationFail{}, err)
+
+	// Invalid hashes are rejected
+	err = validateRoot(f.root, trustpinning.TrustPinConfig{
+		SPKI: map[string][]string{pinnedRootGUN.String(): {leafSPKI, "invalidHash"}}, DisableTOFU: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid SPKI hash")
+
+	// Certs takes priority over SPKI, which takes priority over CA
+	require.Error(t, validateRoot(f.root, trustpinning.TrustPinConfig{
+		Certs: map[string][]string{pinnedRootGUN.String(): {"invalidID"}},
+		SPKI:  map[string][]string{pinnedRootGUN.String(): {leafSPKI}}, DisableTOFU: true}))
+	require.Error(t, validateRoot(f.root, trustpinning.TrustPinConfig{
+		SPKI: map[string][]string{pinnedRootGUN.String(): {spkiHash(f.intCert)}},
+		CA:   map[string]string{"docker.io/notary": f.caFilepath}, DisableTOFU: true}))
+
+	// Re-issuing the leaf cert for the same key changes its ID, but not its SPKI hash
+	template := *f.leafCert
+	template.SerialNumber = big.NewInt(1234)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(notary.Year)
+	reissued, err := x509.CreateCertificate(rand.Reader, &template, f.intCert, f.leafCert.PublicKey, f.intSigner.CryptoSigner())
+	require.NoError(t, err)
+	bundle := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: reissued}), sampleCertChain(t).intermediateCert...)
+	reissuedRoot := f.signRoot(t, bundle)
+
+	originalID := f.root.Signatures[0].KeyID
+	require.Error(t, validateRoot(reissuedRoot, trustpinning.TrustPinConfig{
+		Certs: map[string][]string{pinnedRootGUN.String(): {originalID}}, DisableTOFU: true}))
+	require.NoError(t, validateRoot(reissuedRoot, trustpinning.TrustPinConfig{
+		SPKI: map[string][]string{pinnedRootGUN.String(): {leafSPKI}}, DisableTOFU: true}))
+}
+
+func TestValidateRootWithMinPinnedKeys(t *testing.T) {
+	f := newPinnedRootFixture(t)
+	defer os.RemoveAll(f.dir)
+
+	// The root has a single root key, which matches the pinned SPKI hash and CA
+	for _, trustPinning := range []trustpinning.TrustPinConfig{
+		{SPKI: map[string][]string{pinnedRootGUN.String(): {spkiHash(f.leafCert)}}},
+		{CA: map[string]string{"docker.io/notary": f.caFilepath}},
+	} {
+		trustPinning.MinPinnedKeys = map[string]int{pinnedRootGUN.String(): 1}
+		require.NoError(t, validateRoot(f.root, trustPinning))
+
+		trustPinning.MinPinnedKeys = map[string]int{pinnedRootGUN.String(): 2}
+		err := validateRoot(f.root, trustPinning)
+		require.Error(t, err)
+		require.IsType(t, &trustpinning.ErrValidationFail{}, err)
+		require.Contains(t, err.Error(), "only 1 root keys match the trust_pinning config, but 2 are required")
+
+		// The most specific wildcard matching the GUN is used, and other GUNs are not affected
+		trustPinning.MinPinnedKeys = map[string]int{"docker.io/*": 1, "docker.io/notary*": 2}
+		require.Error(t, validateRoot(f.root, trustPinning))
+		trustPinning.MinPinnedKeys = map[string]int{"docker.io/*": 2, "docker.io/notary*": 1}
+		require.NoError(t, validateRoot(f.root, trustPinning))
+		trustPinning.MinPinnedKeys = map[string]int{"docker.io/other": 2}
+		require.NoError(t, validateRoot(f.root, trustPinning))
+	}
+
+	// MinPinnedKeys is not used with TOFUS
+	require.NoError(t, validateRoot(f.root, trustpinning.TrustPinConfig{
+		MinPinnedKeys: map[string]int{pinnedRootGUN.String(): 2}}))
+}
+
+func TestValidateRootWithPerGUNTOFUS(t *testing.T) {
+	rootMeta := sampleRootData(t).rootMeta
+	gun := data.GUN("docker.com/notary")
+	validate := func(trustPinning trustpinning.TrustPinConfig) error {
+		rootCopy := *rootMeta
+		rootCopy.Signatures = append([]data.Signature{}, rootMeta.Signatures...)
+		_, err := trustpinning.ValidateRoot(nil, &rootCopy, gun, trustPinning)
+		return err
+	}
+
+	// TOFUS can be enabled for a GUN, or a wildcard, even though it is disabled in general
+	require.NoError(t, validate(trustpinning.TrustPinConfig{
+		TOFU: map[string]bool{gun.String(): true}, DisableTOFU: true}))
+	require.NoError(t, validate(trustpinning.TrustPinConfig{
+		TOFU: map[string]bool{"docker.com/*": true}, DisableTOFU: true}))
+	require.Error(t, validate(trustpinning.TrustPinConfig{
+		TOFU: map[string]bool{"docker.io/*": true}, DisableTOFU: true}))
+
+	// and disabled, even though it is enabled in general
+	require.Error(t, validate(trustpinning.TrustPinConfig{
+		TOFU: map[string]bool{gun.String(): false}}))
+	require.Error(t, validate(trustpinning.TrustPinConfig{
+		TOFU: map[string]bool{"docker.com/*": false}}))
+
+	// The GUN itself takes priority over wildcards, and then the most specific wildcard
+	require.NoError(t, validate(trustpinning.TrustPinConfig{
+		TOFU: map[string]bool{"docker.com/*": false, gun.String(): true}}))
+	require.Error(t, validate(trustpinning.TrustPinConfig{
+		TOFU: map[string]bool{"*": true, "docker.com/*": true, "docker.com/no*": false}}))
+}
+
+const pinnedRootGUN data.GUN = "docker.io/notary/leaf"
+
+// pinnedRootFixture is a root for pinnedRootGUN signed by the sample leaf certificate,
+// and the directory containing the pinned CA
+type pinnedRootFixture struct {
+	dir                          string
+	cs                           signed.CryptoService
+	root                         *data.Signed
+	caCert, intCert, leafCert    *x509.Certificate
+	caFilepath                   string
+	caSigner, intSigner, otherCA data.PrivateKey
+}
+
+func newPinnedRootFixture(t *testing.T) *pinnedRootFixture {
+	chain := sampleCertChain(t)
+	f := &pinnedRootFixture{}
+
+	var err error
+	f.caCert, err = helpers.ParseCertificatePEM(chain.rootCert)
+	require.NoError(t, err)
+	f.intCert, err = helpers.ParseCertificatePEM(chain.intermediateCert)
+	require.NoError(t, err)
+	f.leafCert, err = helpers.ParseCertificatePEM(chain.leafCert)
+	require.NoError(t, err)
+	f.caSigner = chain.rootKey
+	f.intSigner = chain.intermediateKey
+	f.otherCA, err = utils.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+
+	f.dir, err = ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	f.caFilepath = filepath.Join(f.dir, "root-ca.crt")
+	require.NoError(t, ioutil.WriteFile(f.caFilepath, chain.rootCert, 0644))
+
+	memStore := trustmanager.NewKeyMemoryStore(passphraseRetriever)
+	f.cs = cryptoservice.NewCryptoService(memStore)
+	require.NoError(t, f.cs.AddKey(data.CanonicalRootRole, pinnedRootGUN, chain.leafKey))
+	f.root = f.signRoot(t, append(append([]byte{}, chain.leafCert...), chain.intermediateCert...))
+	return f
+}
+
+// signRoot returns a root whose only root key is the given leaf certificate bundle for the
+// sample leaf key, signed by that key
+func (f *pinnedRootFixture) signRoot(t *testing.T, certBundle []byte) *data.Signed {
+	rootKey := data.NewECDSAx509PublicKey(certBundle)
+	otherKey, err := f.cs.Create(data.CanonicalTargetsRole, pinnedRootGUN, data.ED25519Key)
+	require.NoError(t, err)
+
+	root := data.SignedRoot{
+		Signatures: make([]data.Signature, 0),
+		Signed: data.Root{
+			SignedCommon: data.SignedCommon{
+				Type:    "Root",
+				Expires: time.Now().Add(time.Hour),
+				Version: 1,
+			},
+			Keys: map[string]data.PublicKey{
+				rootKey.ID():  rootKey,
+				otherKey.ID(): otherKey,
+			},
+			Roles: map[data.RoleName]*data.RootRole{
+				data.CanonicalRootRole:      {KeyIDs: []string{rootKey.ID()}, Threshold: 1},
+				data.CanonicalTargetsRole:   {KeyIDs: []string{otherKey.ID()}, Threshold: 1},
+				data.CanonicalSnapshotRole:  {KeyIDs: []string{otherKey.ID()}, Threshold: 1},
+				data.CanonicalTimestampRole: {KeyIDs: []string{otherKey.ID()}, Threshold: 1},
+			},
+		},
+		Dirty: true,
+	}
+	signedRoot, err := root.ToSigned()
+	require.NoError(t, err)
+	require.NoError(t, signed.Sign(f.cs, signedRoot, []data.PublicKey{rootKey}, 1, nil))
+	return signedRoot
+}
+
+// validateRoot validates a copy of a root, since ValidateRoot marks its signatures
+func validateRoot(root *data.Signed, trustPinning trustpinning.TrustPinConfig) error {
+	rootCopy := *root
+	rootCopy.Signatures = append([]data.Signature{}, root.Signatures...)
+	_, err := trustpinning.ValidateRoot(nil, &rootCopy, pinnedRootGUN, trustPinning)
+	return err
+}
@@ -0,0 +1,199 @@
+package trustpinning
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/theupdateframework/notary/tuf/data"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	crlPEMType = "X509 CRL"
+
+	// StapledOCSPName is the name of the metadata, kept next to the cached root, which
+	// holds the OCSP responses stapled to the root's certificates: a JSON list of base64
+	// encoded DER responses.
+	StapledOCSPName = "root.ocsp"
+)
+
+// errCertRevoked is returned when a certificate in the chain from a root
+// leaf certificate to a pinned CA has been revoked
+type errCertRevoked struct {
+	CommonName string
+	Serial     string
+	Source     string
+}
+
+func (err errCertRevoked) Error() string {
+	return fmt.Sprintf("certificate with CN %s and serial %s has been revoked by %s",
+		err.CommonName, err.Serial, err.Source)
+}
+
+// revocationChecker rejects certificates which have been revoked by the CRLs configured
+// for a GUN, or by the OCSP responses stapled to the root
+type revocationChecker struct {
+	crlFilepath   string
+	crls          []*pkix.CertificateList
+	ocspResponses [][]byte
+	// revoked holds the reasons certificates have been rejected, so that they
+	// can be reported once validation fails
+	revoked []error
+}
+
+// loadRevocationChecker loads the CRLs for the most specific GUN prefix in the trust
+// pinning config, and parses its stapled OCSP responses.  Returns nil if there are
+// neither.
+func loadRevocationChecker(gun data.GUN, trustPinConfig TrustPinConfig) (*revocationChecker, error) {
+	crlFilepath, haveCRL := getFilepathByPrefix(gun, trustPinConfig.CRL)
+	if !haveCRL && len(trustPinConfig.StapledOCSP) == 0 {
+		return nil, nil
+	}
+
+	c := &revocationChecker{}
+	if haveCRL {
+		logrus.Debugf("trust-pinning using CRLs at: %s", crlFilepath)
+		crls, err := loadCRLsFromFile(crlFilepath)
+		if err != nil {
+			return nil, err
+		}
+		c.crlFilepath = crlFilepath
+		c.crls = crls
+	}
+	for _, der := range trustPinConfig.StapledOCSP {
+		// A response which can't be parsed can't revoke anything, so it is no worse than
+		// one which has not been stapled
+		if _, err := ocsp.ParseResponse(der, nil); err != nil {
+			logrus.Debugf("ignoring invalid stapled OCSP response: %s", err)
+			continue
+		}
+		c.ocspResponses = append(c.ocspResponses, der)
+	}
+	return c, nil
+}
+
+// check returns an error if every chain contains a revoked certificate, and
+// records it.  The CA root of each chain is trusted, so it is not checked.
+func (c *revocationChecker) check(chains [][]*x509.Certificate) error {
+	if c == nil {
+		return nil
+	}
+	var err error
+	for _, chain := range chains {
+		if err = c.checkChain(chain); err == nil {
+			return nil
+		}
+	}
+	if err != nil {
+		c.revoked = append(c.revoked, err)
+	}
+	return err
+}
+
+func (c *revocationChecker) checkChain(chain []*x509.Certificate) error {
+	for i := 0; i < len(chain)-1; i++ {
+		if err := c.checkCert(chain[i], chain[i+1], time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCert returns an error if the certificate has been revoked, or if the revocation
+// information about it is out of date, since it may not list a recent revocation
+func (c *revocationChecker) checkCert(cert, issuer *x509.Certificate, now time.Time) error {
+	revoked := errCertRevoked{CommonName: cert.Subject.CommonName, Serial: cert.SerialNumber.String()}
+
+	for _, crl := range c.crls {
+		// Only CRLs issued by this certificate's issuer can revoke it
+		if err := issuer.CheckCRLSignature(crl); err != nil {
+			continue
+		}
+		for _, entry := range crl.TBSCertList.RevokedCertificates {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				revoked.Source = fmt.Sprintf("the CRL at %s", c.crlFilepath)
+				return revoked
+			}
+		}
+		if crl.HasExpired(now) {
+			return fmt.Errorf("the CRL at %s for certificate with CN %s expired on %s",
+				c.crlFilepath, cert.Subject.CommonName, crl.TBSCertList.NextUpdate)
+		}
+	}
+
+	// A stale response is only an error if there is no fresh one about the same certificate
+	var (
+		staleErr error
+		fresh    bool
+	)
+	for _, raw := range c.ocspResponses {
+		// This fails if the response is about another certificate, or is not signed by its issuer
+		resp, err := ocsp.ParseResponseForCert(raw, cert, issuer)
+		if err != nil {
+			continue
+		}
+		if resp.Status == ocsp.Revoked {
+			revoked.Source = "a stapled OCSP response"
+			return revoked
+		}
+		if now.Before(resp.ThisUpdate) || (!resp.NextUpdate.IsZero() && now.After(resp.NextUpdate)) {
+			staleErr = fmt.Errorf("the stapled OCSP response for certificate with CN %s is only valid from %s to %s",
+				cert.Subject.CommonName, resp.ThisUpdate, resp.NextUpdate)
+			continue
+		}
+		fresh = true
+	}
+	if fresh {
+		return nil
+	}
+	return staleErr
+}
+
+// loadCRLsFromFile loads either a bundle of PEM encoded CRLs, or a single DER
+// encoded CRL.  Expired CRLs are only rejected when checking a certificate, since
+// they may not list recently revoked certificates.
+func loadCRLsFromFile(filepath string) ([]*pkix.CertificateList, error) {
+	ders, err := loadPEMOrDERFile(filepath, crlPEMType)
+	if err != nil {
+		return nil, fmt.Errorf("could not load CRLs: %v", err)
+	}
+	var crls []*pkix.CertificateList
+	for _, der := range ders {
+		crl, err := x509.ParseDERCRL(der)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse CRL at %s: %v", filepath, err)
+		}
+		crls = append(crls, crl)
+	}
+	return crls, nil
+}
+
+// loadPEMOrDERFile returns the contents of every PEM block of the given type in
+// a file, or the whole file if it does not contain PEM
+func loadPEMOrDERFile(filepath, pemType string) ([][]byte, error) {
+	contents, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	var ders [][]byte
+	for rest := contents; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != pemType {
+			return nil, fmt.Errorf("unexpected PEM block type %s in %s", block.Type, filepath)
+		}
+		ders = append(ders, block.Bytes)
+	}
+	if len(ders) == 0 {
+		ders = append(ders, contents)
+	}
+	return ders, nil
+}
@@ -0,0 +1,187 @@
+package trustpinning_test
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+	"golang.org/x/crypto/ocsp"
+)
+
+// validate validates the fixture's root against its pinned CA
+func (f *pinnedRootFixture) validate(t *testing.T, config trustpinning.TrustPinConfig) error {
+	config.CA = map[string]string{"docker.io/notary": f.caFilepath}
+	config.DisableTOFU = true
+	return validateRoot(f.root, config)
+}
+
+// writeCRL writes a PEM encoded CRL, signed by the given issuer, which revokes the given serials
+func (f *pinnedRootFixture) writeCRL(t *testing.T, name string, issuer *x509.Certificate, key data.PrivateKey,
+	nextUpdate time.Time, serials ...*big.Int) string {
+
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range serials {
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: time.Now()})
+	}
+	crl, err := issuer.CreateCRL(rand.Reader, key.CryptoSigner(), revoked, time.Now().Add(-time.Hour), nextUpdate)
+	require.NoError(t, err)
+
+	path := filepath.Join(f.dir, name)
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0644))
+	return path
+}
+
+// ocspResponse returns a DER encoded OCSP response about the leaf certificate, signed by the
+// intermediate, which is valid for the two hours before nextUpdate
+func (f *pinnedRootFixture) ocspResponse(t *testing.T, status int, nextUpdate time.Time) []byte {
+	resp, err := ocsp.CreateResponse(f.intCert, f.intCert, ocsp.Response{
+		Status:       status,
+		SerialNumber: f.leafCert.SerialNumber,
+		ThisUpdate:   nextUpdate.Add(-2 * time.Hour),
+		NextUpdate:   nextUpdate,
+		RevokedAt:    time.Now().Add(-time.Minute),
+	}, f.intSigner.CryptoSigner())
+	require.NoError(t, err)
+	return resp
+}
+
+func requireRevoked(t *testing.T, err error, source string) {
+	require.Error(t, err)
+	require.IsType(t, &trustpinning.ErrValidationFail{}, err)
+	require.Contains(t, err.Error(), "has been revoked by "+source)
+}
+
+func TestValidateRootWithPinnedCAAndCRL(t *testing.T) {
+	f := newPinnedRootFixture(t)
+	defer os.RemoveAll(f.dir)
+
+	// Without any revocation information, the leaf cert is trusted through the pinned CA
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{}))
+
+	// A CRL from the intermediate which does not list the leaf cert doesn't change that
+	crlFilepath := f.writeCRL(t, "empty.crl", f.intCert, f.intSigner, time.Now().Add(time.Hour), big.NewInt(1))
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": crlFilepath}}))
+
+	// The CRL is only used for GUNs it is configured for
+	crlFilepath = f.writeCRL(t, "leaf.crl", f.intCert, f.intSigner, time.Now().Add(time.Hour), f.leafCert.SerialNumber)
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/other": crlFilepath}}))
+
+	// Revoking the leaf cert fails validation
+	err := f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": crlFilepath}})
+	requireRevoked(t, err, "the CRL at "+crlFilepath)
+	require.Contains(t, err.Error(), "docker.io/notary/leaf")
+
+	// The most specific GUN prefix is used
+	emptyFilepath := filepath.Join(f.dir, "empty.crl")
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/": crlFilepath, "docker.io/notary/": emptyFilepath}}))
+
+	// A DER encoded CRL can be used too
+	block, _ := pem.Decode(mustReadFile(t, crlFilepath))
+	derFilepath := filepath.Join(f.dir, "leaf.der")
+	require.NoError(t, ioutil.WriteFile(derFilepath, block.Bytes, 0644))
+	requireRevoked(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": derFilepath}}), "the CRL at "+derFilepath)
+
+	// Revoking the intermediate cert with a CRL from the pinned CA fails validation
+	crlFilepath = f.writeCRL(t, "int.crl", f.caCert, f.caSigner, time.Now().Add(time.Hour), f.intCert.SerialNumber)
+	err = f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": crlFilepath}})
+	requireRevoked(t, err, "the CRL at "+crlFilepath)
+	require.Contains(t, err.Error(), "docker.io/notary/intermediate")
+}
+
+func TestValidateRootWithPinnedCAAndInvalidCRL(t *testing.T) {
+	f := newPinnedRootFixture(t)
+	defer os.RemoveAll(f.dir)
+
+	// A CRL which is not signed by a certificate in the chain is ignored
+	otherCert, err := cryptoservice.GenerateCertificate(f.otherCA, "docker.io/notary/other", time.Now(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	otherCert.IsCA = true
+	crlFilepath := f.writeCRL(t, "other.crl", otherCert, f.otherCA, time.Now().Add(time.Hour), f.leafCert.SerialNumber)
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": crlFilepath}}))
+
+	// So is an expired one
+	crlFilepath = f.writeCRL(t, "other-expired.crl", otherCert, f.otherCA, time.Now().Add(-time.Minute))
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": crlFilepath}}))
+
+	// But an expired CRL which is signed by the issuer of a certificate in the chain fails
+	// validation, even if it does not revoke anything
+	crlFilepath = f.writeCRL(t, "expired.crl", f.intCert, f.intSigner, time.Now().Add(-time.Minute))
+	err = f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": crlFilepath}})
+	require.Error(t, err)
+	require.IsType(t, &trustpinning.ErrValidationFail{}, err)
+	require.Contains(t, err.Error(), "expired")
+
+	// As do missing and invalid CRL files
+	require.Error(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": filepath.Join(f.dir, "nonexistent")}}))
+	invalidFilepath := filepath.Join(f.dir, "invalid.crl")
+	require.NoError(t, ioutil.WriteFile(invalidFilepath, []byte("ABSOLUTELY NOT A CRL"), 0644))
+	require.Error(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": invalidFilepath}}))
+	require.Error(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL: map[string]string{"docker.io/notary": f.caFilepath}}))
+}
+
+func TestValidateRootWithPinnedCAAndStapledOCSP(t *testing.T) {
+	f := newPinnedRootFixture(t)
+	defer os.RemoveAll(f.dir)
+
+	good := f.ocspResponse(t, ocsp.Good, time.Now().Add(time.Hour))
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{StapledOCSP: [][]byte{good}}))
+
+	revoked := f.ocspResponse(t, ocsp.Revoked, time.Now().Add(time.Hour))
+	requireRevoked(t, f.validate(t, trustpinning.TrustPinConfig{StapledOCSP: [][]byte{revoked}}),
+		"a stapled OCSP response")
+
+	// Every stapled response is checked
+	requireRevoked(t, f.validate(t, trustpinning.TrustPinConfig{StapledOCSP: [][]byte{good, revoked}}),
+		"a stapled OCSP response")
+
+	// Both a CRL and stapled OCSP responses can be used
+	crlFilepath := f.writeCRL(t, "empty.crl", f.intCert, f.intSigner, time.Now().Add(time.Hour))
+	requireRevoked(t, f.validate(t, trustpinning.TrustPinConfig{
+		CRL:         map[string]string{"docker.io/notary": crlFilepath},
+		StapledOCSP: [][]byte{revoked}}), "a stapled OCSP response")
+
+	// A response past its next update fails validation, unless there is a fresh one too
+	stale := f.ocspResponse(t, ocsp.Good, time.Now().Add(-time.Minute))
+	err := f.validate(t, trustpinning.TrustPinConfig{StapledOCSP: [][]byte{stale}})
+	require.Error(t, err)
+	require.IsType(t, &trustpinning.ErrValidationFail{}, err)
+	require.Contains(t, err.Error(), "stapled OCSP response")
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{StapledOCSP: [][]byte{stale, good}}))
+
+	// As does one which is not valid yet
+	future := f.ocspResponse(t, ocsp.Good, time.Now().Add(3*time.Hour))
+	require.Error(t, f.validate(t, trustpinning.TrustPinConfig{StapledOCSP: [][]byte{future}}))
+
+	// Invalid responses are ignored, as if they had not been stapled
+	require.NoError(t, f.validate(t, trustpinning.TrustPinConfig{
+		StapledOCSP: [][]byte{[]byte("ABSOLUTELY NOT AN OCSP RESPONSE")}}))
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return contents
+}
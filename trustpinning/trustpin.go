@@ -1,7 +1,9 @@
 package trustpinning
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"strings"
 
@@ -14,23 +16,44 @@ import (
 // This struct represents the preferred way to bootstrap trust for this repository
 // This is fully optional. If left at the default, uninitialized value Notary will use TOFU over
 // HTTPS.
-// You can use this to provide certificates or a CA to pin to as a root of trust for a GUN.
+// You can use this to provide certificates, public keys or a CA to pin to as a root of trust for a GUN.
 // These are used with the following precedence:
 //
 // 1. Certs
-// 2. CA
-// 3. TOFUS (TOFU over HTTPS)
+// 2. SPKI
+// 3. CA
+// 4. TOFUS (TOFU over HTTPS)
 //
 // Only one trust pinning option will be used to validate a particular GUN.
 type TrustPinConfig struct {
 	// CA maps a GUN prefix to file paths containing the root CA.
 	// This file can contain multiple root certificates, bundled in separate PEM blocks.
 	CA map[string]string
+	// CRL maps a GUN prefix to file paths containing certificate revocation lists,
+	// which are checked when validating against a pinned CA.
+	// This file can contain multiple CRLs, bundled in separate PEM blocks, or a single DER encoded CRL.
+	CRL map[string]string
+	// StapledOCSP holds the DER encoded OCSP responses stapled to the root being validated,
+	// which are checked when validating against a pinned CA.  Unlike the other fields, it
+	// is not read from the config file: clients fill it in from the StapledOCSPName metadata
+	// kept next to the cached root.
+	StapledOCSP [][]byte
 	// Certs maps a GUN to a list of certificate IDs
 	Certs map[string][]string
+	// SPKI maps a GUN to a list of hex encoded SHA256 hashes of the subject public key info
+	// of root certificates.  Unlike certificate IDs, these stay the same when a certificate
+	// is re-issued for the same key.
+	SPKI map[string][]string
+	// TOFU maps a GUN to whether "Trust On First Use" of new key data is enabled for it,
+	// overriding DisableTOFU.
+	TOFU map[string]bool
 	// DisableTOFU, when true, disables "Trust On First Use" of new key data
 	// This is false by default, which means new key data will always be trusted the first time it is seen.
 	DisableTOFU bool
+	// MinPinnedKeys maps a GUN to the minimum number of root keys which have to match the
+	// Certs, SPKI or CA pinned for it.  As with TOFU, the GUN can be wildcarded.  It is not
+	// used with TOFUS.
+	MinPinnedKeys map[string]int
 }
 
 type trustPinChecker struct {
@@ -38,6 +61,8 @@ type trustPinChecker struct {
 	config        TrustPinConfig
 	pinnedCAPool  *x509.CertPool
 	pinnedCertIDs []string
+	pinnedSPKIs   []string
+	revocation    *revocationChecker
 }
 
 // CertChecker is a function type that will be used to check leaf certs against pinned trust
@@ -45,27 +70,42 @@ type CertChecker func(leafCert *x509.Certificate, intCerts []*x509.Certificate)
 
 // NewTrustPinChecker returns a new certChecker function from a TrustPinConfig for a GUN
 func NewTrustPinChecker(trustPinConfig TrustPinConfig, gun data.GUN, firstBootstrap bool) (CertChecker, error) {
-	t := trustPinChecker{gun: gun, config: trustPinConfig}
+	_, checker, err := newTrustPinChecker(trustPinConfig, gun, firstBootstrap)
+	return checker, err
+}
+
+// newTrustPinChecker is like NewTrustPinChecker, but also returns the trustPinChecker so that
+// the reasons for rejecting revoked certificates can be reported
+func newTrustPinChecker(trustPinConfig TrustPinConfig, gun data.GUN, firstBootstrap bool) (*trustPinChecker, CertChecker, error) {
+	t := &trustPinChecker{gun: gun, config: trustPinConfig}
 	// Determine the mode, and if it's even valid
 	if pinnedCerts, ok := trustPinConfig.Certs[gun.String()]; ok {
 		logrus.Debugf("trust-pinning using Cert IDs")
 		t.pinnedCertIDs = pinnedCerts
-		return t.certsCheck, nil
+		return t, t.certsCheck, nil
 	}
 	var ok bool
 	t.pinnedCertIDs, ok = wildcardMatch(gun, trustPinConfig.Certs)
 	if ok {
-		return t.certsCheck, nil
+		return t, t.certsCheck, nil
+	}
+
+	if pinnedSPKIs, ok := trustPinConfig.SPKI[gun.String()]; ok {
+		logrus.Debugf("trust-pinning using SPKI hashes")
+		return t.withSPKIs(pinnedSPKIs)
+	}
+	if pinnedSPKIs, ok := wildcardMatch(gun, trustPinConfig.SPKI); ok {
+		return t.withSPKIs(pinnedSPKIs)
 	}
 
-	if caFilepath, err := getPinnedCAFilepathByPrefix(gun, trustPinConfig); err == nil {
+	if caFilepath, ok := getFilepathByPrefix(gun, trustPinConfig.CA); ok {
 		logrus.Debugf("trust-pinning using root CA bundle at: %s", caFilepath)
 
 		// Try to add the CA certs from its bundle file to our certificate store,
 		// and use it to validate certs in the root.json later
 		caCerts, err := utils.LoadCertBundleFromFile(caFilepath)
 		if err != nil {
-			return nil, fmt.Errorf("could not load root cert from CA path")
+			return nil, nil, fmt.Errorf("could not load root cert from CA path")
 		}
 		// Now only consider certificates that are direct children from this CA cert chain
 		caRootPool := x509.NewCertPool()
@@ -78,18 +118,25 @@ func NewTrustPinChecker(trustPinConfig TrustPinConfig, gun data.GUN, firstBootst
 		}
 		// If we didn't have any valid CA certs, error out
 		if len(caRootPool.Subjects()) == 0 {
-			return nil, fmt.Errorf("invalid CA certs provided")
+			return nil, nil, fmt.Errorf("invalid CA certs provided")
 		}
 		t.pinnedCAPool = caRootPool
-		return t.caCheck, nil
+
+		// Load any CRLs and stapled OCSP responses, which are used to reject revoked certificates
+		// in the chain from the leaf cert to the CA root
+		t.revocation, err = loadRevocationChecker(gun, trustPinConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, t.caCheck, nil
 	}
 
 	// If TOFUs is disabled and we don't have any previous trusted root data for this GUN, we error out
-	if trustPinConfig.DisableTOFU && firstBootstrap {
-		return nil, fmt.Errorf("invalid trust pinning specified")
+	if !tofuEnabled(gun, trustPinConfig) && firstBootstrap {
+		return nil, nil, fmt.Errorf("invalid trust pinning specified")
 
 	}
-	return t.tofusCheck, nil
+	return t, t.tofusCheck, nil
 }
 
 func (t trustPinChecker) certsCheck(leafCert *x509.Certificate, intCerts []*x509.Certificate) bool {
@@ -103,6 +150,21 @@ func (t trustPinChecker) certsCheck(leafCert *x509.Certificate, intCerts []*x509
 	return utils.StrSliceContains(t.pinnedCertIDs, key.ID())
 }
 
+func (t *trustPinChecker) withSPKIs(pinnedSPKIs []string) (*trustPinChecker, CertChecker, error) {
+	for _, spki := range pinnedSPKIs {
+		if hash, err := hex.DecodeString(spki); err != nil || len(hash) != sha256.Size {
+			return nil, nil, fmt.Errorf("invalid SPKI hash: %s", spki)
+		}
+		t.pinnedSPKIs = append(t.pinnedSPKIs, strings.ToLower(spki))
+	}
+	return t, t.spkiCheck, nil
+}
+
+func (t trustPinChecker) spkiCheck(leafCert *x509.Certificate, intCerts []*x509.Certificate) bool {
+	hash := sha256.Sum256(leafCert.RawSubjectPublicKeyInfo)
+	return utils.StrSliceContains(t.pinnedSPKIs, hex.EncodeToString(hash[:]))
+}
+
 func (t trustPinChecker) caCheck(leafCert *x509.Certificate, intCerts []*x509.Certificate) bool {
 	// Use intermediate certificates included in the root TUF metadata for our validation
 	caIntPool := x509.NewCertPool()
@@ -111,35 +173,74 @@ func (t trustPinChecker) caCheck(leafCert *x509.Certificate, intCerts []*x509.Ce
 	}
 	// Attempt to find a valid certificate chain from the leaf cert to CA root
 	// Use this certificate if such a valid chain exists (possibly using intermediates)
-	var err error
-	if _, err = leafCert.Verify(x509.VerifyOptions{Roots: t.pinnedCAPool, Intermediates: caIntPool}); err == nil {
-		return true
+	chains, err := leafCert.Verify(x509.VerifyOptions{Roots: t.pinnedCAPool, Intermediates: caIntPool})
+	if err != nil {
+		logrus.Debugf("unable to find a valid certificate chain from leaf cert to CA root: %s", err)
+		return false
+	}
+	// Only use this certificate if none of the certificates in its chain have been revoked
+	if err := t.revocation.check(chains); err != nil {
+		logrus.Debugf("rejecting leaf cert with CN %s: %s", leafCert.Subject.CommonName, err)
+		return false
 	}
-	logrus.Debugf("unable to find a valid certificate chain from leaf cert to CA root: %s", err)
-	return false
+	return true
 }
 
 func (t trustPinChecker) tofusCheck(leafCert *x509.Certificate, intCerts []*x509.Certificate) bool {
 	return true
 }
 
-// Will return the CA filepath corresponding to the most specific (longest) entry in the map that is still a prefix
-// of the provided gun.  Returns an error if no entry matches this GUN as a prefix.
-func getPinnedCAFilepathByPrefix(gun data.GUN, t TrustPinConfig) (string, error) {
+// pinned returns whether certificates are checked against pinned Certs, SPKI or CA, rather than TOFUS
+func (t trustPinChecker) pinned() bool {
+	return t.pinnedCertIDs != nil || t.pinnedSPKIs != nil || t.pinnedCAPool != nil
+}
+
+// tofuEnabled returns whether TOFUS may be used for a GUN, from its entry or most specific
+// wildcarded entry in the TOFU map, or else from DisableTOFU
+func tofuEnabled(gun data.GUN, t TrustPinConfig) bool {
+	if enabled, ok := t.TOFU[gun.String()]; ok {
+		return enabled
+	}
+	patterns := make([]string, 0, len(t.TOFU))
+	for gunPrefix := range t.TOFU {
+		patterns = append(patterns, gunPrefix)
+	}
+	if gunPrefix, ok := longestWildcard(gun, patterns); ok {
+		return t.TOFU[gunPrefix]
+	}
+	return !t.DisableTOFU
+}
+
+// minPinnedKeys returns the minimum number of root keys which have to match the pinned
+// trust for the GUN, or 0 if neither the GUN nor a wildcard matching it sets one
+func minPinnedKeys(gun data.GUN, t TrustPinConfig) int {
+	if min, ok := t.MinPinnedKeys[gun.String()]; ok {
+		return min
+	}
+	patterns := make([]string, 0, len(t.MinPinnedKeys))
+	for gunPrefix := range t.MinPinnedKeys {
+		patterns = append(patterns, gunPrefix)
+	}
+	if gunPrefix, ok := longestWildcard(gun, patterns); ok {
+		return t.MinPinnedKeys[gunPrefix]
+	}
+	return 0
+}
+
+// Will return the filepath corresponding to the most specific (longest) entry in the map that is still a prefix
+// of the provided gun.  Returns false if no entry matches this GUN as a prefix.
+func getFilepathByPrefix(gun data.GUN, filepaths map[string]string) (string, bool) {
 	specificGUN := ""
-	specificCAFilepath := ""
-	foundCA := false
-	for gunPrefix, caFilepath := range t.CA {
+	specificFilepath := ""
+	found := false
+	for gunPrefix, filepath := range filepaths {
 		if strings.HasPrefix(gun.String(), gunPrefix) && len(gunPrefix) >= len(specificGUN) {
 			specificGUN = gunPrefix
-			specificCAFilepath = caFilepath
-			foundCA = true
+			specificFilepath = filepath
+			found = true
 		}
 	}
-	if !foundCA {
-		return "", fmt.Errorf("could not find pinned CA for GUN: %s", gun)
-	}
-	return specificCAFilepath, nil
+	return specificFilepath, found
 }
 
 // wildcardMatch will attempt to match the most specific (longest prefix) wildcarded
@@ -147,17 +248,28 @@ func getPinnedCAFilepathByPrefix(gun data.GUN, t TrustPinConfig) (string, error)
 // it is impossible to have two different prefixes of equal length.
 // This logic also solves the issue of Go's randomization of map iteration.
 func wildcardMatch(gun data.GUN, certs map[string][]string) ([]string, bool) {
-	var (
-		longest = ""
-		ids     []string
-	)
-	for gunPrefix, keyIDs := range certs {
+	patterns := make([]string, 0, len(certs))
+	for gunPrefix := range certs {
+		patterns = append(patterns, gunPrefix)
+	}
+	longest, ok := longestWildcard(gun, patterns)
+	if !ok {
+		return nil, false
+	}
+	ids := certs[longest]
+	return ids, ids != nil
+}
+
+// longestWildcard returns the most specific (longest prefix) wildcarded pattern
+// matching the GUN.  Patterns which are not wildcarded are ignored.
+func longestWildcard(gun data.GUN, patterns []string) (string, bool) {
+	longest := ""
+	for _, gunPrefix := range patterns {
 		if strings.HasSuffix(gunPrefix, "*") {
 			if strings.HasPrefix(gun.String(), gunPrefix[:len(gunPrefix)-1]) && len(gunPrefix) > len(longest) {
 				longest = gunPrefix
-				ids = keyIDs
 			}
 		}
 	}
-	return ids, ids != nil
+	return longest, longest != ""
 }
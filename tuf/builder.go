@@ -2,6 +2,7 @@ package tuf
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/docker/go/canonical/json"
 	"github.com/theupdateframework/notary"
@@ -98,7 +99,16 @@ func (f finishedBuilder) GetConsistentInfo(roleName data.RoleName) ConsistentInf
 
 // NewRepoBuilder is the only way to get a pre-built RepoBuilder
 func NewRepoBuilder(gun data.GUN, cs signed.CryptoService, trustpin trustpinning.TrustPinConfig) RepoBuilder {
-	return NewBuilderFromRepo(gun, NewRepo(cs), trustpin)
+	return NewRepoBuilderWithExpiryPolicy(gun, cs, trustpin, nil)
+}
+
+// NewRepoBuilderWithExpiryPolicy is like NewRepoBuilder, but the snapshots and
+// timestamps generated by the builder expire according to the given policy
+func NewRepoBuilderWithExpiryPolicy(gun data.GUN, cs signed.CryptoService, trustpin trustpinning.TrustPinConfig,
+	expiryPolicy *data.ExpiryPolicy) RepoBuilder {
+	repo := NewRepo(cs)
+	repo.SetExpiryPolicy(gun, expiryPolicy)
+	return NewBuilderFromRepo(gun, repo, trustpin)
 }
 
 // NewBuilderFromRepo allows us to bootstrap a builder given existing repo data.
@@ -158,9 +168,17 @@ func (rb *repoBuilder) Finish() (*Repo, *Repo, error) {
 	return rb.repo, rb.invalidRoles, nil
 }
 
+// newRepo returns an empty repo with the same crypto service and expiry
+// policy as the builder's repo
+func (rb *repoBuilder) newRepo() *Repo {
+	repo := NewRepo(rb.repo.cryptoService)
+	repo.SetExpiryPolicy(rb.repo.gun, rb.repo.expiryPolicy)
+	return repo
+}
+
 func (rb *repoBuilder) BootstrapNewBuilder() RepoBuilder {
 	return &repoBuilderWrapper{RepoBuilder: &repoBuilder{
-		repo:                 NewRepo(rb.repo.cryptoService),
+		repo:                 rb.newRepo(),
 		invalidRoles:         NewRepo(nil),
 		gun:                  rb.gun,
 		loadedNotChecksummed: make(map[data.RoleName][]byte),
@@ -173,7 +191,7 @@ func (rb *repoBuilder) BootstrapNewBuilder() RepoBuilder {
 
 func (rb *repoBuilder) BootstrapNewBuilderWithNewTrustpin(trustpin trustpinning.TrustPinConfig) RepoBuilder {
 	return &repoBuilderWrapper{RepoBuilder: &repoBuilder{
-		repo:                 NewRepo(rb.repo.cryptoService),
+		repo:                 rb.newRepo(),
 		gun:                  rb.gun,
 		loadedNotChecksummed: make(map[data.RoleName][]byte),
 		trustpin:             trustpin,
@@ -345,7 +363,7 @@ func (rb *repoBuilder) GenerateSnapshot(prev *data.SignedSnapshot) ([]byte, int,
 		rb.repo.Snapshot = prev
 	}
 
-	sgnd, err := rb.repo.SignSnapshot(data.DefaultExpires(data.CanonicalSnapshotRole))
+	sgnd, err := rb.repo.SignSnapshot(time.Time{})
 	if err != nil {
 		rb.repo.Snapshot = nil
 		return nil, 0, err
@@ -404,7 +422,7 @@ func (rb *repoBuilder) GenerateTimestamp(prev *data.SignedTimestamp) ([]byte, in
 		rb.repo.Timestamp = prev
 	}
 
-	sgnd, err := rb.repo.SignTimestamp(data.DefaultExpires(data.CanonicalTimestampRole))
+	sgnd, err := rb.repo.SignTimestamp(time.Time{})
 	if err != nil {
 		rb.repo.Timestamp = nil
 		return nil, 0, err
@@ -1,6 +1,9 @@
 package data
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // ErrInvalidMetadata is the error to be returned when metadata is invalid
 type ErrInvalidMetadata struct {
@@ -51,3 +54,44 @@ type ErrCertExpired struct {
 func (e ErrCertExpired) Error() string {
 	return fmt.Sprintf("certificate with CN %s is expired", e.CN)
 }
+
+// ErrInvalidTargetLifecycle is returned when a target's lifecycle in its
+// custom data is malformed, or cannot be stored in its custom data
+type ErrInvalidTargetLifecycle struct {
+	msg string
+}
+
+func (e ErrInvalidTargetLifecycle) Error() string {
+	return fmt.Sprintf("invalid target lifecycle: %s", e.msg)
+}
+
+// ErrTargetRevoked is returned when a target has been revoked
+type ErrTargetRevoked struct {
+	Name   string
+	Reason string
+}
+
+func (e ErrTargetRevoked) Error() string {
+	return fmt.Sprintf("target %s has been revoked: %s", e.Name, e.Reason)
+}
+
+// ErrTargetNotYetValid is returned when a target's validity window has not
+// started yet
+type ErrTargetNotYetValid struct {
+	Name      string
+	NotBefore time.Time
+}
+
+func (e ErrTargetNotYetValid) Error() string {
+	return fmt.Sprintf("target %s is not valid until %s", e.Name, e.NotBefore.Format(time.RFC3339))
+}
+
+// ErrTargetExpired is returned when a target's validity window has ended
+type ErrTargetExpired struct {
+	Name     string
+	NotAfter time.Time
+}
+
+func (e ErrTargetExpired) Error() string {
+	return fmt.Sprintf("target %s expired at %s", e.Name, e.NotAfter.Format(time.RFC3339))
+}
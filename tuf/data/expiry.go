@@ -0,0 +1,101 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DelegationsExpiryRole can be used as the role of an ExpiryRule to match
+// every delegated targets role
+const DelegationsExpiryRole RoleName = "delegations"
+
+// ExpiryRule sets how long metadata for a role is valid in repositories whose
+// GUN starts with GUNPrefix
+type ExpiryRule struct {
+	// GUNPrefix is the prefix of the GUNs the rule applies to.  The empty
+	// prefix matches every GUN.
+	GUNPrefix string
+	// Role is the role the rule applies to.  It may also be
+	// DelegationsExpiryRole, or empty to match every role.
+	Role   RoleName
+	Expiry time.Duration
+}
+
+// ExpiryPolicy decides how long newly signed metadata is valid, per GUN and
+// role.  A nil policy uses the default expiry times.
+//
+// When several rules match, the one with the most specific role wins: an
+// exact role name, then DelegationsExpiryRole, then (for delegations only)
+// the targets role, then the empty role.  Between rules with equally specific
+// roles, the one with the longest GUN prefix wins.
+type ExpiryPolicy struct {
+	Rules []ExpiryRule
+}
+
+// NewExpiryPolicy returns a policy with the given rules
+func NewExpiryPolicy(rules ...ExpiryRule) *ExpiryPolicy {
+	return &ExpiryPolicy{Rules: rules}
+}
+
+// Validate returns an error if any of the policy's rules is invalid
+func (p *ExpiryPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, rule := range p.Rules {
+		if rule.Expiry <= 0 {
+			return fmt.Errorf("expiry for role %q and GUN prefix %q must be positive", rule.Role, rule.GUNPrefix)
+		}
+		if rule.Role != "" && rule.Role != DelegationsExpiryRole && !ValidRole(rule.Role) {
+			return fmt.Errorf("invalid role in expiry policy: %s", rule.Role)
+		}
+	}
+	return nil
+}
+
+// Expiry returns how long metadata for role in the repository gun is valid
+func (p *ExpiryPolicy) Expiry(gun GUN, role RoleName) time.Duration {
+	if p != nil {
+		best, bestSpecificity := -1, -1
+		for i, rule := range p.Rules {
+			specificity := roleSpecificity(rule.Role, role)
+			if specificity < 0 || !strings.HasPrefix(gun.String(), rule.GUNPrefix) {
+				continue
+			}
+			if specificity > bestSpecificity ||
+				(specificity == bestSpecificity && len(rule.GUNPrefix) > len(p.Rules[best].GUNPrefix)) {
+				best, bestSpecificity = i, specificity
+			}
+		}
+		if best >= 0 {
+			return p.Rules[best].Expiry
+		}
+	}
+	if IsDelegation(role) {
+		role = CanonicalTargetsRole
+	}
+	return defaultExpiryTimes[role]
+}
+
+// Expires returns when metadata for role in the repository gun, signed now,
+// should expire
+func (p *ExpiryPolicy) Expires(gun GUN, role RoleName) time.Time {
+	return time.Now().Add(p.Expiry(gun, role))
+}
+
+// roleSpecificity returns how specifically a rule for ruleRole matches role,
+// or -1 if it does not match it at all
+func roleSpecificity(ruleRole, role RoleName) int {
+	switch {
+	case ruleRole == role:
+		return 3
+	case ruleRole == DelegationsExpiryRole && IsDelegation(role):
+		return 2
+	case ruleRole == CanonicalTargetsRole && IsDelegation(role):
+		return 1
+	case ruleRole == "":
+		return 0
+	}
+	return -1
+}
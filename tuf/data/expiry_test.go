@@ -0,0 +1,81 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiryPolicyValidate(t *testing.T) {
+	var nilPolicy *ExpiryPolicy
+	require.NoError(t, nilPolicy.Validate())
+	require.NoError(t, NewExpiryPolicy(
+		ExpiryRule{Expiry: time.Hour},
+		ExpiryRule{GUNPrefix: "prod/", Role: CanonicalTargetsRole, Expiry: time.Hour},
+		ExpiryRule{Role: "targets/releases", Expiry: time.Hour},
+		ExpiryRule{Role: DelegationsExpiryRole, Expiry: time.Hour},
+	).Validate())
+
+	require.Error(t, NewExpiryPolicy(ExpiryRule{Role: CanonicalTargetsRole}).Validate())
+	require.Error(t, NewExpiryPolicy(ExpiryRule{Role: CanonicalTargetsRole, Expiry: -time.Hour}).Validate())
+	require.Error(t, NewExpiryPolicy(ExpiryRule{Role: "nope", Expiry: time.Hour}).Validate())
+}
+
+func TestExpiryPolicyDefaults(t *testing.T) {
+	var nilPolicy *ExpiryPolicy
+	for _, policy := range []*ExpiryPolicy{nilPolicy, NewExpiryPolicy(), NewExpiryPolicy(
+		ExpiryRule{GUNPrefix: "prod/", Expiry: time.Hour})} {
+
+		for _, role := range BaseRoles {
+			require.Equal(t, defaultExpiryTimes[role], policy.Expiry("docker.com/notary", role))
+		}
+		require.Equal(t, defaultExpiryTimes[CanonicalTargetsRole], policy.Expiry("docker.com/notary", "targets/a"))
+	}
+}
+
+func TestExpiryPolicyPrecedence(t *testing.T) {
+	policy := NewExpiryPolicy(
+		ExpiryRule{Role: CanonicalTimestampRole, Expiry: time.Hour},
+		ExpiryRule{Role: DelegationsExpiryRole, Expiry: 7 * 24 * time.Hour},
+		ExpiryRule{GUNPrefix: "prod/", Role: CanonicalTargetsRole, Expiry: 30 * 24 * time.Hour},
+		ExpiryRule{GUNPrefix: "prod/", Expiry: 60 * 24 * time.Hour},
+		ExpiryRule{GUNPrefix: "prod/app", Role: "targets/releases", Expiry: 2 * time.Hour},
+		ExpiryRule{GUNPrefix: "prod/app", Role: CanonicalTimestampRole, Expiry: 3 * time.Hour},
+	)
+	require.NoError(t, policy.Validate())
+
+	for _, c := range []struct {
+		gun      GUN
+		role     RoleName
+		expected time.Duration
+	}{
+		// no matching rules
+		{"dev/app", CanonicalTargetsRole, defaultExpiryTimes[CanonicalTargetsRole]},
+		{"dev/app", CanonicalRootRole, defaultExpiryTimes[CanonicalRootRole]},
+		// matching role
+		{"dev/app", CanonicalTimestampRole, time.Hour},
+		{"prod/app", CanonicalTargetsRole, 30 * 24 * time.Hour},
+		// any delegation
+		{"dev/app", "targets/a", 7 * 24 * time.Hour},
+		{"prod/app", "targets/a", 7 * 24 * time.Hour},
+		// the exact role wins over any delegation
+		{"prod/app", "targets/releases", 2 * time.Hour},
+		// the longest GUN prefix wins for the same role
+		{"prod/app", CanonicalTimestampRole, 3 * time.Hour},
+		{"prod/other", CanonicalTimestampRole, time.Hour},
+		// a rule for any role applies to the roles without a rule of their own
+		{"prod/app", CanonicalRootRole, 60 * 24 * time.Hour},
+		{"prod/app", CanonicalSnapshotRole, 60 * 24 * time.Hour},
+	} {
+		require.Equal(t, c.expected, policy.Expiry(c.gun, c.role), "%s %s", c.gun, c.role)
+	}
+
+	// without a delegations rule, delegations expire like the targets role
+	policy = NewExpiryPolicy(ExpiryRule{GUNPrefix: "prod/", Role: CanonicalTargetsRole, Expiry: time.Hour})
+	require.Equal(t, time.Hour, policy.Expiry("prod/app", "targets/a"))
+	require.Equal(t, defaultExpiryTimes[CanonicalTargetsRole], policy.Expiry("dev/app", "targets/a"))
+
+	expires := policy.Expires("prod/app", CanonicalTargetsRole)
+	require.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+}
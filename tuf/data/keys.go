@@ -3,8 +3,10 @@ package data
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/hex"
@@ -88,7 +90,7 @@ func (ks KeyList) IDs() []string {
 
 func typedPublicKey(tk TUFKey) PublicKey {
 	switch tk.Algorithm() {
-	case ECDSAKey:
+	case ECDSAKey, ECDSAP384Key:
 		return &ECDSAPublicKey{TUFKey: tk}
 	case ECDSAx509Key:
 		return &ECDSAx509PublicKey{TUFKey: tk}
@@ -106,7 +108,7 @@ func typedPrivateKey(tk TUFKey) (PrivateKey, error) {
 	private := tk.Value.Private
 	tk.Value.Private = nil
 	switch tk.Algorithm() {
-	case ECDSAKey:
+	case ECDSAKey, ECDSAP384Key:
 		return NewECDSAPrivateKey(
 			&ECDSAPublicKey{
 				TUFKey: tk,
@@ -237,7 +239,8 @@ func (k TUFKey) Public() []byte {
 // Public key types
 
 // ECDSAPublicKey represents an ECDSA key using a raw serialization
-// of the public key
+// of the public key.  Its type is ECDSAKey, or ECDSAP384Key for keys that
+// were explicitly created as P-384 keys.
 type ECDSAPublicKey struct {
 	TUFKey
 }
@@ -284,6 +287,19 @@ func NewECDSAPublicKey(public []byte) *ECDSAPublicKey {
 	}
 }
 
+// NewECDSAP384PublicKey initializes a new public key with the ECDSAP384Key type
+func NewECDSAP384PublicKey(public []byte) *ECDSAPublicKey {
+	return &ECDSAPublicKey{
+		TUFKey: TUFKey{
+			Type: ECDSAP384Key,
+			Value: KeyPair{
+				Public:  public,
+				Private: nil,
+			},
+		},
+	}
+}
+
 // NewECDSAx509PublicKey initializes a new public key with the ECDSAx509Key type
 func NewECDSAx509PublicKey(public []byte) *ECDSAx509PublicKey {
 	return &ECDSAx509PublicKey{
@@ -382,6 +398,9 @@ func NewECDSAPrivateKey(public PublicKey, private []byte) (*ECDSAPrivateKey, err
 	if err != nil {
 		return nil, err
 	}
+	if public.Algorithm() == ECDSAP384Key && ecdsaPrivKey.Curve != elliptic.P384() {
+		return nil, errors.New("ecdsa-p384 key must use the P-384 curve")
+	}
 	return &ECDSAPrivateKey{
 		PublicKey:  public,
 		privateKey: privateKey{private: private},
@@ -441,14 +460,22 @@ type ecdsaSig struct {
 	S *big.Int
 }
 
-// Sign creates an ecdsa signature
+// Sign creates an ecdsa signature, hashing the message with SHA-384 for
+// ECDSAP384Key keys and SHA-256 otherwise
 func (k ECDSAPrivateKey) Sign(rand io.Reader, msg []byte, opts crypto.SignerOpts) (signature []byte, err error) {
 	ecdsaPrivKey, ok := k.CryptoSigner().(*ecdsa.PrivateKey)
 	if !ok {
 		return nil, errors.New("signer was based on the wrong key type")
 	}
-	hashed := sha256.Sum256(msg)
-	sigASN1, err := ecdsaPrivKey.Sign(rand, hashed[:], opts)
+	var hashed []byte
+	if k.Algorithm() == ECDSAP384Key {
+		digest := sha512.Sum384(msg)
+		hashed = digest[:]
+	} else {
+		digest := sha256.Sum256(msg)
+		hashed = digest[:]
+	}
+	sigASN1, err := ecdsaPrivKey.Sign(rand, hashed, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -498,6 +525,9 @@ func (k UnknownPrivateKey) Sign(rand io.Reader, msg []byte, opts crypto.SignerOp
 
 // SignatureAlgorithm returns the SigAlgorithm for a ECDSAPrivateKey
 func (k ECDSAPrivateKey) SignatureAlgorithm() SigAlgorithm {
+	if k.Algorithm() == ECDSAP384Key {
+		return ECDSAP384Signature
+	}
 	return ECDSASignature
 }
 
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"math/big"
+	"testing"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestECDSAKey(t *testing.T, curve elliptic.Curve) (pub, priv []byte) {
+	ecdsaPrivKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	require.NoError(t, err)
+	pub, err = x509.MarshalPKIXPublicKey(&ecdsaPrivKey.PublicKey)
+	require.NoError(t, err)
+	priv, err = x509.MarshalECPrivateKey(ecdsaPrivKey)
+	require.NoError(t, err)
+	return pub, priv
+}
+
+func TestECDSAP384PrivateKey(t *testing.T) {
+	pub, priv := newTestECDSAKey(t, elliptic.P384())
+	privKey, err := NewECDSAPrivateKey(NewECDSAP384PublicKey(pub), priv)
+	require.NoError(t, err)
+	require.Equal(t, ECDSAP384Key, privKey.Algorithm())
+	require.Equal(t, ECDSAP384Signature, privKey.SignatureAlgorithm())
+
+	sig, err := privKey.Sign(rand.Reader, []byte("message"), nil)
+	require.NoError(t, err)
+	require.Len(t, sig, 96)
+
+	// the key type survives serialization
+	serialized, err := json.MarshalCanonical(TUFKey{
+		Type:  ECDSAP384Key,
+		Value: KeyPair{Public: pub, Private: priv},
+	})
+	require.NoError(t, err)
+	unmarshalled, err := UnmarshalPrivateKey(serialized)
+	require.NoError(t, err)
+	require.Equal(t, ECDSAP384Key, unmarshalled.Algorithm())
+	require.Equal(t, privKey.ID(), unmarshalled.ID())
+
+	pubKey := NewPublicKey(ECDSAP384Key, pub)
+	require.IsType(t, &ECDSAPublicKey{}, pubKey)
+	require.Equal(t, privKey.ID(), pubKey.ID())
+}
+
+func TestECDSAP384PrivateKeyWrongCurve(t *testing.T) {
+	pub, priv := newTestECDSAKey(t, elliptic.P256())
+	_, err := NewECDSAPrivateKey(NewECDSAP384PublicKey(pub), priv)
+	require.Error(t, err)
+
+	// P-256 keys sign with the plain ecdsa signature algorithm
+	privKey, err := NewECDSAPrivateKey(NewECDSAPublicKey(pub), priv)
+	require.NoError(t, err)
+	require.Equal(t, ECDSASignature, privKey.SignatureAlgorithm())
+	sig, err := privKey.Sign(rand.Reader, []byte("message"), nil)
+	require.NoError(t, err)
+	require.Len(t, sig, 64)
+}
+
+func TestECDSAPrivateKeyOnP384Curve(t *testing.T) {
+	pub, priv := newTestECDSAKey(t, elliptic.P384())
+	privKey, err := NewECDSAPrivateKey(NewECDSAPublicKey(pub), priv)
+	require.NoError(t, err)
+	require.Equal(t, ECDSAKey, privKey.Algorithm())
+	require.Equal(t, ECDSASignature, privKey.SignatureAlgorithm())
+
+	// ecdsa keys on the P-384 curve still hash with SHA-256
+	msg := []byte("message")
+	sig, err := privKey.Sign(rand.Reader, msg, nil)
+	require.NoError(t, err)
+	require.Len(t, sig, 96)
+
+	ecdsaPubKey, ok := privKey.CryptoSigner().Public().(*ecdsa.PublicKey)
+	require.True(t, ok)
+	digest := sha256.Sum256(msg)
+	r, s := new(big.Int).SetBytes(sig[:48]), new(big.Int).SetBytes(sig[48:])
+	require.True(t, ecdsa.Verify(ecdsaPubKey, digest[:], r, s))
+}
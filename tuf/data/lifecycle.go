@@ -0,0 +1,134 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/docker/go/canonical/json"
+)
+
+// TargetLifecycleKey is the key in a target's custom data under which its
+// TargetLifecycle is stored.  It is namespaced so that it does not collide
+// with custom data which other tools store under their own keys.
+const TargetLifecycleKey = "notary.lifecycle"
+
+// TargetLifecycle restricts when a target should be trusted.  It is optional,
+// and stored under TargetLifecycleKey when a target's custom data is a JSON
+// object, so that it can be combined with arbitrary other custom data.
+type TargetLifecycle struct {
+	// NotBefore is the time before which the target is not yet valid
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// NotAfter is the time after which the target has expired
+	NotAfter *time.Time `json:"not_after,omitempty"`
+	// Revoked is the reason the target was revoked, if it has been
+	Revoked string `json:"revoked,omitempty"`
+}
+
+// IsEmpty returns whether the lifecycle places no restrictions on the target
+func (l TargetLifecycle) IsEmpty() bool {
+	return l.NotBefore == nil && l.NotAfter == nil && l.Revoked == ""
+}
+
+// Validate returns an error if the validity window is empty
+func (l TargetLifecycle) Validate() error {
+	if l.NotBefore != nil && l.NotAfter != nil && !l.NotBefore.Before(*l.NotAfter) {
+		return ErrInvalidTargetLifecycle{
+			msg: fmt.Sprintf("not_before (%s) must be before not_after (%s)",
+				l.NotBefore.Format(time.RFC3339), l.NotAfter.Format(time.RFC3339)),
+		}
+	}
+	return nil
+}
+
+// Check returns an error if the target with the given name has been revoked
+// or is outside its validity window at the given time
+func (l TargetLifecycle) Check(name string, now time.Time) error {
+	if l.Revoked != "" {
+		return ErrTargetRevoked{Name: name, Reason: l.Revoked}
+	}
+	if l.NotBefore != nil && now.Before(*l.NotBefore) {
+		return ErrTargetNotYetValid{Name: name, NotBefore: *l.NotBefore}
+	}
+	if l.NotAfter != nil && now.After(*l.NotAfter) {
+		return ErrTargetExpired{Name: name, NotAfter: *l.NotAfter}
+	}
+	return nil
+}
+
+// customFields returns the custom data as a map of its fields.  ok is false
+// if the custom data is not a JSON object.
+func customFields(custom *json.RawMessage) (fields map[string]*json.RawMessage, ok bool) {
+	fields = make(map[string]*json.RawMessage)
+	if custom == nil {
+		return fields, true
+	}
+	trimmed := bytes.TrimSpace(*custom)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return fields, true
+	}
+	if trimmed[0] != '{' {
+		return nil, false
+	}
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return nil, false
+	}
+	return fields, true
+}
+
+// ParseTargetLifecycle returns the TargetLifecycle stored in a target's custom
+// data, or nil if it does not have one.  An error is returned if the stored
+// lifecycle is malformed.
+func ParseTargetLifecycle(custom *json.RawMessage) (*TargetLifecycle, error) {
+	fields, ok := customFields(custom)
+	if !ok {
+		// custom data which isn't an object can't hold a lifecycle
+		return nil, nil
+	}
+	raw, ok := fields[TargetLifecycleKey]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	var lifecycle TargetLifecycle
+	if err := json.Unmarshal(*raw, &lifecycle); err != nil {
+		return nil, ErrInvalidTargetLifecycle{msg: err.Error()}
+	}
+	if err := lifecycle.Validate(); err != nil {
+		return nil, err
+	}
+	return &lifecycle, nil
+}
+
+// SetTargetLifecycle returns a copy of a target's custom data with the given
+// lifecycle stored in it, preserving any other fields.  An empty lifecycle
+// removes any existing one.  The custom data must be empty or a JSON object.
+func SetTargetLifecycle(custom *json.RawMessage, lifecycle TargetLifecycle) (*json.RawMessage, error) {
+	if err := lifecycle.Validate(); err != nil {
+		return nil, err
+	}
+	fields, ok := customFields(custom)
+	if lifecycle.IsEmpty() {
+		if _, hasLifecycle := fields[TargetLifecycleKey]; !hasLifecycle {
+			return custom, nil
+		}
+		delete(fields, TargetLifecycleKey)
+	} else if !ok {
+		return nil, ErrInvalidTargetLifecycle{msg: "custom data must be a JSON object to hold a lifecycle"}
+	} else {
+		raw, err := json.MarshalCanonical(lifecycle)
+		if err != nil {
+			return nil, err
+		}
+		lifecycleJSON := json.RawMessage(raw)
+		fields[TargetLifecycleKey] = &lifecycleJSON
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	raw, err := json.MarshalCanonical(fields)
+	if err != nil {
+		return nil, err
+	}
+	result := json.RawMessage(raw)
+	return &result, nil
+}
@@ -0,0 +1,101 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/stretchr/testify/require"
+)
+
+func rawCustom(s string) *json.RawMessage {
+	raw := json.RawMessage(s)
+	return &raw
+}
+
+func TestParseTargetLifecycle(t *testing.T) {
+	// custom data without a lifecycle
+	for _, custom := range []*json.RawMessage{nil, rawCustom("null"), rawCustom(`"Lorem ipsum"`),
+		rawCustom(`[1, 2]`), rawCustom(`{"other": true}`), rawCustom(`{"lifecycle": "some other tool's data"}`)} {
+		lifecycle, err := ParseTargetLifecycle(custom)
+		require.NoError(t, err)
+		require.Nil(t, lifecycle)
+	}
+
+	lifecycle, err := ParseTargetLifecycle(rawCustom(
+		`{"other": true, "notary.lifecycle": {"not_before": "2017-01-01T00:00:00Z", "not_after": "2018-01-01T00:00:00Z", "revoked": "CVE-2017-0001"}}`))
+	require.NoError(t, err)
+	require.NotNil(t, lifecycle)
+	require.True(t, lifecycle.NotBefore.Equal(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)))
+	require.True(t, lifecycle.NotAfter.Equal(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)))
+	require.Equal(t, "CVE-2017-0001", lifecycle.Revoked)
+
+	// malformed lifecycles are rejected
+	_, err = ParseTargetLifecycle(rawCustom(`{"notary.lifecycle": {"not_before": "yesterday"}}`))
+	require.IsType(t, ErrInvalidTargetLifecycle{}, err)
+	_, err = ParseTargetLifecycle(rawCustom(
+		`{"notary.lifecycle": {"not_before": "2018-01-01T00:00:00Z", "not_after": "2017-01-01T00:00:00Z"}}`))
+	require.IsType(t, ErrInvalidTargetLifecycle{}, err)
+}
+
+func TestSetTargetLifecycle(t *testing.T) {
+	notBefore := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.AddDate(1, 0, 0)
+	lifecycle := TargetLifecycle{NotBefore: &notBefore, NotAfter: &notAfter, Revoked: "compromised"}
+
+	// other custom fields are preserved
+	custom, err := SetTargetLifecycle(rawCustom(`{"other": "data"}`), lifecycle)
+	require.NoError(t, err)
+	require.Equal(t,
+		`{"notary.lifecycle":{"not_after":"2018-01-01T00:00:00Z","not_before":"2017-01-01T00:00:00Z","revoked":"compromised"},"other":"data"}`,
+		string(*custom))
+	parsed, err := ParseTargetLifecycle(custom)
+	require.NoError(t, err)
+	require.Equal(t, lifecycle.Revoked, parsed.Revoked)
+	require.True(t, notBefore.Equal(*parsed.NotBefore))
+	require.True(t, notAfter.Equal(*parsed.NotAfter))
+
+	// a lifecycle can be added to empty custom data
+	custom, err = SetTargetLifecycle(nil, TargetLifecycle{Revoked: "compromised"})
+	require.NoError(t, err)
+	require.Equal(t, `{"notary.lifecycle":{"revoked":"compromised"}}`, string(*custom))
+
+	// an empty lifecycle removes the existing one, and leaves other custom data untouched
+	custom, err = SetTargetLifecycle(custom, TargetLifecycle{})
+	require.NoError(t, err)
+	require.Nil(t, custom)
+	custom, err = SetTargetLifecycle(rawCustom(`"Lorem ipsum"`), TargetLifecycle{})
+	require.NoError(t, err)
+	require.Equal(t, `"Lorem ipsum"`, string(*custom))
+
+	// custom data which is not an object can't hold a lifecycle
+	_, err = SetTargetLifecycle(rawCustom(`"Lorem ipsum"`), lifecycle)
+	require.IsType(t, ErrInvalidTargetLifecycle{}, err)
+
+	// nor can an empty validity window
+	_, err = SetTargetLifecycle(nil, TargetLifecycle{NotBefore: &notAfter, NotAfter: &notBefore})
+	require.IsType(t, ErrInvalidTargetLifecycle{}, err)
+}
+
+func TestTargetLifecycleCheck(t *testing.T) {
+	notBefore := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.AddDate(1, 0, 0)
+	lifecycle := TargetLifecycle{NotBefore: &notBefore, NotAfter: &notAfter}
+
+	require.NoError(t, TargetLifecycle{}.Check("latest", notBefore))
+	require.NoError(t, lifecycle.Check("latest", notBefore.AddDate(0, 6, 0)))
+	require.NoError(t, lifecycle.Check("latest", notBefore))
+	require.NoError(t, lifecycle.Check("latest", notAfter))
+
+	err := lifecycle.Check("latest", notBefore.Add(-time.Second))
+	require.Equal(t, ErrTargetNotYetValid{Name: "latest", NotBefore: notBefore}, err)
+
+	err = lifecycle.Check("latest", notAfter.Add(time.Second))
+	require.Equal(t, ErrTargetExpired{Name: "latest", NotAfter: notAfter}, err)
+
+	// revocation takes effect regardless of the validity window
+	lifecycle.Revoked = "compromised"
+	err = lifecycle.Check("latest", notBefore.AddDate(0, 6, 0))
+	require.Equal(t, ErrTargetRevoked{Name: "latest", Reason: "compromised"}, err)
+	require.Contains(t, err.Error(), "compromised")
+}
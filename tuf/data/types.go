@@ -91,6 +91,7 @@ const (
 	RSAPSSSignature      SigAlgorithm = "rsapss"
 	RSAPKCS1v15Signature SigAlgorithm = "rsapkcs1v15"
 	ECDSASignature       SigAlgorithm = "ecdsa"
+	ECDSAP384Signature   SigAlgorithm = "ecdsa-p384"
 	PyCryptoSignature    SigAlgorithm = "pycrypto-pkcs#1 pss"
 )
 
@@ -101,6 +102,7 @@ const (
 	RSAx509Key   = "rsa-x509"
 	ECDSAKey     = "ecdsa"
 	ECDSAx509Key = "ecdsa-x509"
+	ECDSAP384Key = "ecdsa-p384"
 )
 
 // TUFTypes is the set of metadata types
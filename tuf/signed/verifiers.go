@@ -3,8 +3,10 @@ package signed
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -28,6 +30,7 @@ var Verifiers = map[data.SigAlgorithm]Verifier{
 	data.RSAPKCS1v15Signature: RSAPKCS1v15Verifier{},
 	data.PyCryptoSignature:    RSAPyCryptoVerifier{},
 	data.ECDSASignature:       ECDSAVerifier{},
+	data.ECDSAP384Signature:   ECDSAP384Verifier{},
 	data.EDDSASignature:       Ed25519Verifier{},
 }
 
@@ -206,42 +209,82 @@ type ECDSAVerifier struct{}
 
 // Verify does the actual check.
 func (v ECDSAVerifier) Verify(key data.PublicKey, sig []byte, msg []byte) error {
-	algorithm := key.Algorithm()
+	switch key.Algorithm() {
+	case data.ECDSAKey, data.ECDSAx509Key:
+	default:
+		// only accept ECDSA keys.
+		logrus.Debugf("invalid key type for ECDSA verifier: %s", key.Algorithm())
+		return ErrInvalidKeyType{}
+	}
+	ecdsaPubKey, err := parseECDSAPublicKey(key)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(msg)
+	return verifyECDSA(ecdsaPubKey, digest[:], sig)
+}
+
+// ECDSAP384Verifier checks ECDSA signatures made with a key on the P-384
+// curve over the SHA-384 digest of the message
+type ECDSAP384Verifier struct{}
+
+// Verify does the actual check.
+func (v ECDSAP384Verifier) Verify(key data.PublicKey, sig []byte, msg []byte) error {
+	switch key.Algorithm() {
+	case data.ECDSAP384Key, data.ECDSAKey, data.ECDSAx509Key:
+	default:
+		logrus.Debugf("invalid key type for ECDSA P-384 verifier: %s", key.Algorithm())
+		return ErrInvalidKeyType{}
+	}
+	ecdsaPubKey, err := parseECDSAPublicKey(key)
+	if err != nil {
+		return err
+	}
+	if ecdsaPubKey.Curve != elliptic.P384() {
+		logrus.Debugf("key %s is not on the P-384 curve", key.ID())
+		return ErrInvalidKeyType{}
+	}
+	digest := sha512.Sum384(msg)
+	return verifyECDSA(ecdsaPubKey, digest[:], sig)
+}
+
+// parseECDSAPublicKey extracts the ECDSA public key from either a raw or an
+// x509 encoded TUF key
+func parseECDSAPublicKey(key data.PublicKey) (*ecdsa.PublicKey, error) {
 	var pubKey crypto.PublicKey
 
-	switch algorithm {
+	switch key.Algorithm() {
 	case data.ECDSAx509Key:
 		pemCert, _ := pem.Decode([]byte(key.Public()))
 		if pemCert == nil {
 			logrus.Debugf("failed to decode PEM-encoded x509 certificate for keyID: %s", key.ID())
 			logrus.Debugf("certificate bytes: %s", string(key.Public()))
-			return ErrInvalid
+			return nil, ErrInvalid
 		}
 		cert, err := x509.ParseCertificate(pemCert.Bytes)
 		if err != nil {
 			logrus.Debugf("failed to parse x509 certificate: %s\n", err)
-			return ErrInvalid
+			return nil, ErrInvalid
 		}
 		pubKey = cert.PublicKey
-	case data.ECDSAKey:
+	default:
 		var err error
 		pubKey, err = x509.ParsePKIXPublicKey(key.Public())
 		if err != nil {
 			logrus.Debugf("Failed to parse private key for keyID: %s, %s\n", key.ID(), err)
-			return ErrInvalid
+			return nil, ErrInvalid
 		}
-	default:
-		// only accept ECDSA keys.
-		logrus.Debugf("invalid key type for ECDSA verifier: %s", algorithm)
-		return ErrInvalidKeyType{}
 	}
 
 	ecdsaPubKey, ok := pubKey.(*ecdsa.PublicKey)
 	if !ok {
 		logrus.Debugf("value isn't an ECDSA public key")
-		return ErrInvalid
+		return nil, ErrInvalid
 	}
+	return ecdsaPubKey, nil
+}
 
+func verifyECDSA(ecdsaPubKey *ecdsa.PublicKey, digest []byte, sig []byte) error {
 	sigLength := len(sig)
 	expectedOctetLength := 2 * ((ecdsaPubKey.Params().BitSize + 7) >> 3)
 	if sigLength != expectedOctetLength {
@@ -253,9 +296,7 @@ func (v ECDSAVerifier) Verify(key data.PublicKey, sig []byte, msg []byte) error
 	r := new(big.Int).SetBytes(rBytes)
 	s := new(big.Int).SetBytes(sBytes)
 
-	digest := sha256.Sum256(msg)
-
-	if !ecdsa.Verify(ecdsaPubKey, digest[:], r, s) {
+	if !ecdsa.Verify(ecdsaPubKey, digest, r, s) {
 		logrus.Debugf("failed ECDSA signature validation")
 		return ErrInvalid
 	}
@@ -8,14 +8,17 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/hex"
 	"fmt"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
 )
 
 type KeyTemplate struct {
@@ -418,6 +421,72 @@ func TestECDSAVerifierWithInvalidSignature(t *testing.T) {
 
 }
 
+func TestECDSAP384Verifier(t *testing.T) {
+	testECDSAKey, err := utils.GenerateECDSAP384Key(rand.Reader)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAP384Key, testECDSAKey.Algorithm())
+	require.Equal(t, data.ECDSAP384Signature, testECDSAKey.SignatureAlgorithm())
+
+	message := []byte("test data for signing")
+	signedData, err := testECDSAKey.Sign(rand.Reader, message, nil)
+	require.NoError(t, err)
+
+	ecdsaVerifier := ECDSAP384Verifier{}
+	require.NoError(t, ecdsaVerifier.Verify(testECDSAKey, signedData, message))
+
+	// the signature is over the SHA-384 digest, so can't be verified as a
+	// P-256 style ecdsa signature
+	err = ECDSAVerifier{}.Verify(testECDSAKey, signedData, message)
+	require.IsType(t, ErrInvalidKeyType{}, err)
+	hashed := sha256.Sum256(message)
+	signedSHA256, err := ecdsaSign(testECDSAKey, hashed[:])
+	require.NoError(t, err)
+	require.Error(t, ecdsaVerifier.Verify(testECDSAKey, signedSHA256, message))
+
+	// Make sure an invalid signature fails verification
+	signedData[0]++
+	require.Error(t, ecdsaVerifier.Verify(testECDSAKey, signedData, message))
+}
+
+func TestECDSAP384x509Verifier(t *testing.T) {
+	testECDSAKey, err := utils.GenerateECDSAP384Key(rand.Reader)
+	require.NoError(t, err)
+
+	startTime := time.Now()
+	template, err := utils.NewCertificate("docker.com/notary", startTime, startTime.AddDate(1, 0, 0))
+	require.NoError(t, err)
+	signer := testECDSAKey.CryptoSigner()
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certBytes)
+	require.NoError(t, err)
+	certKey := utils.CertToKey(cert)
+	require.Equal(t, data.ECDSAx509Key, certKey.Algorithm())
+
+	message := []byte("test data for signing")
+	signedData, err := testECDSAKey.Sign(rand.Reader, message, nil)
+	require.NoError(t, err)
+	require.NoError(t, ECDSAP384Verifier{}.Verify(certKey, signedData, message))
+}
+
+func TestECDSAP384VerifierWithInvalidKey(t *testing.T) {
+	message := []byte("test data for signing")
+	hashed := sha512.Sum384(message)
+
+	// keys which are not on the P-384 curve are rejected
+	testECDSAKey, err := utils.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+	signedData, err := ecdsaSign(testECDSAKey, hashed[:])
+	require.NoError(t, err)
+	err = ECDSAP384Verifier{}.Verify(testECDSAKey, signedData, message)
+	require.IsType(t, ErrInvalidKeyType{}, err)
+
+	testED25519Key, err := utils.GenerateED25519Key(rand.Reader)
+	require.NoError(t, err)
+	err = ECDSAP384Verifier{}.Verify(testED25519Key, signedData, message)
+	require.IsType(t, ErrInvalidKeyType{}, err)
+}
+
 func TestED25519VerifierInvalidKeyType(t *testing.T) {
 	key := data.NewPublicKey("bad_type", nil)
 	v := Ed25519Verifier{}
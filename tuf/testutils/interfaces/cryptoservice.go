@@ -93,6 +93,8 @@ func AddGetKeyCryptoServiceInterfaceBehaviorTests(t *testing.T, cs signed.Crypto
 			addedPrivKey, err = testutils.GetRSAKey(2048)
 		case data.ECDSAKey:
 			addedPrivKey, err = utils.GenerateECDSAKey(rand.Reader)
+		case data.ECDSAP384Key:
+			addedPrivKey, err = utils.GenerateECDSAP384Key(rand.Reader)
 		case data.ED25519Key:
 			addedPrivKey, err = utils.GenerateED25519Key(rand.Reader)
 		default:
@@ -125,6 +127,8 @@ func AddListKeyCryptoServiceInterfaceBehaviorTests(t *testing.T, cs signed.Crypt
 			addedPrivKey, err = testutils.GetRSAKey(2048)
 		case data.ECDSAKey:
 			addedPrivKey, err = utils.GenerateECDSAKey(rand.Reader)
+		case data.ECDSAP384Key:
+			addedPrivKey, err = utils.GenerateECDSAP384Key(rand.Reader)
 		case data.ED25519Key:
 			addedPrivKey, err = utils.GenerateED25519Key(rand.Reader)
 		default:
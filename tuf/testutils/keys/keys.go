@@ -10,12 +10,12 @@ import (
 	"github.com/theupdateframework/notary/tuf/utils"
 )
 
-// CreateOrAddKey creates a new key in case of ECDSA or ED25519 inside the cryptoservice with the given role and gun
+// CreateOrAddKey creates a new key in case of ECDSA (P-256 or P-384) or ED25519 inside the cryptoservice with the given role and gun
 // while in the case of RSA, it takes a key from the map that includes multiple pre-existing RSA keys and adds them
 // to the keystore, returning the public key.
 func CreateOrAddKey(cs signed.CryptoService, role data.RoleName, gun data.GUN, keyAlgorithm string) (pubKey data.PublicKey, err error) {
 	switch keyAlgorithm {
-	case data.ECDSAKey, data.ED25519Key:
+	case data.ECDSAKey, data.ECDSAP384Key, data.ED25519Key:
 		pubKey, err = cs.Create(role, gun, keyAlgorithm)
 		if err != nil {
 			return
@@ -68,6 +68,11 @@ type Repo struct {
 	// If we know what the original was, we'll if and how to handle root
 	// rotations.
 	originalRootRole data.BaseRole
+
+	// gun and expiryPolicy decide when metadata signed with a zero expiry
+	// time expires
+	gun          data.GUN
+	expiryPolicy *data.ExpiryPolicy
 }
 
 // NewRepo initializes a Repo instance with a CryptoService.
@@ -80,6 +85,24 @@ func NewRepo(cryptoService signed.CryptoService) *Repo {
 	}
 }
 
+// SetExpiryPolicy sets the GUN of the repo, and the policy used to choose the
+// expiry time of metadata signed with a zero expiry time.  A nil policy uses
+// the default expiry times.
+func (tr *Repo) SetExpiryPolicy(gun data.GUN, policy *data.ExpiryPolicy) {
+	tr.gun = gun
+	tr.expiryPolicy = policy
+}
+
+// expires returns the given expiry time if it is set, and otherwise the
+// expiry time of newly signed metadata for role according to the repo's
+// expiry policy
+func (tr *Repo) expires(role data.RoleName, expires time.Time) time.Time {
+	if expires.IsZero() {
+		return tr.expiryPolicy.Expires(tr.gun, role)
+	}
+	return expires
+}
+
 // AddBaseKeys is used to add keys to the role in root.json
 func (tr *Repo) AddBaseKeys(role data.RoleName, keys ...data.PublicKey) error {
 	if tr.Root == nil {
@@ -126,6 +149,27 @@ func (tr *Repo) ReplaceBaseKeys(role data.RoleName, keys ...data.PublicKey) erro
 	return tr.AddBaseKeys(role, keys...)
 }
 
+// SetBaseThreshold is used to set the threshold of the given role in root.json
+func (tr *Repo) SetBaseThreshold(role data.RoleName, threshold int) error {
+	if tr.Root == nil {
+		return ErrNotLoaded{Role: data.CanonicalRootRole}
+	}
+	rootRole, ok := tr.Root.Signed.Roles[role]
+	if !ok || data.IsDelegation(role) {
+		return data.ErrInvalidRole{Role: role, Reason: "invalid base role name"}
+	}
+	if threshold < notary.MinThreshold {
+		return data.ErrInvalidRole{Role: role, Reason: fmt.Sprintf("threshold must be at least %d", notary.MinThreshold)}
+	}
+	if rootRole.Threshold == threshold {
+		return nil
+	}
+	rootRole.Threshold = threshold
+	tr.Root.Dirty = true
+	tr.markRoleDirty(role)
+	return nil
+}
+
 // RemoveBaseKeys is used to remove keys from the roles in root.json
 func (tr *Repo) RemoveBaseKeys(role data.RoleName, keyIDs ...string) error {
 	if tr.Root == nil {
@@ -894,7 +938,8 @@ func (tr *Repo) UpdateTimestamp(s *data.Signed) error {
 // carried in tr.Root.Keys and the private key is available (i.e. probably previously
 // trusted keys, to allow rollover).  If there are any errors, attempt to put root
 // back to the way it was (so version won't be incremented, for instance).
-// Extra signing keys can be added to support older clients
+// Extra signing keys can be added to support older clients.  If expires is the
+// zero time, the expiry time comes from the repo's expiry policy.
 func (tr *Repo) SignRoot(expires time.Time, extraSigningKeys data.KeyList) (*data.Signed, error) {
 	logrus.Debug("signing root...")
 
@@ -921,7 +966,7 @@ func (tr *Repo) SignRoot(expires time.Time, extraSigningKeys data.KeyList) (*dat
 		rolesToSignWith = append(rolesToSignWith, tr.originalRootRole)
 	}
 
-	tempRoot.Signed.Expires = expires
+	tempRoot.Signed.Expires = tr.expires(data.CanonicalRootRole, expires)
 	tempRoot.Signed.Version++
 	rolesToSignWith = append(rolesToSignWith, currRoot)
 
@@ -945,7 +990,8 @@ func oldRootVersionName(version int) string {
 	return fmt.Sprintf("%s.%v", data.CanonicalRootRole, version)
 }
 
-// SignTargets signs the targets file for the given top level or delegated targets role
+// SignTargets signs the targets file for the given top level or delegated targets role.
+// If expires is the zero time, the expiry time comes from the repo's expiry policy.
 func (tr *Repo) SignTargets(role data.RoleName, expires time.Time) (*data.Signed, error) {
 	logrus.Debugf("sign targets called for role %s", role)
 	if _, ok := tr.Targets[role]; !ok {
@@ -954,7 +1000,7 @@ func (tr *Repo) SignTargets(role data.RoleName, expires time.Time) (*data.Signed
 			Reason: "SignTargets called with non-existent targets role",
 		}
 	}
-	tr.Targets[role].Signed.Expires = expires
+	tr.Targets[role].Signed.Expires = tr.expires(role, expires)
 	tr.Targets[role].Signed.Version++
 	signed, err := tr.Targets[role].ToSigned()
 	if err != nil {
@@ -985,7 +1031,8 @@ func (tr *Repo) SignTargets(role data.RoleName, expires time.Time) (*data.Signed
 	return signed, nil
 }
 
-// SignSnapshot updates the snapshot based on the current targets and root then signs it
+// SignSnapshot updates the snapshot based on the current targets and root then signs it.
+// If expires is the zero time, the expiry time comes from the repo's expiry policy.
 func (tr *Repo) SignSnapshot(expires time.Time) (*data.Signed, error) {
 	logrus.Debug("signing snapshot...")
 	signedRoot, err := tr.Root.ToSigned()
@@ -1008,7 +1055,7 @@ func (tr *Repo) SignSnapshot(expires time.Time) (*data.Signed, error) {
 		}
 		targets.Dirty = false
 	}
-	tr.Snapshot.Signed.Expires = expires
+	tr.Snapshot.Signed.Expires = tr.expires(data.CanonicalSnapshotRole, expires)
 	tr.Snapshot.Signed.Version++
 	signed, err := tr.Snapshot.ToSigned()
 	if err != nil {
@@ -1026,7 +1073,8 @@ func (tr *Repo) SignSnapshot(expires time.Time) (*data.Signed, error) {
 	return signed, nil
 }
 
-// SignTimestamp updates the timestamp based on the current snapshot then signs it
+// SignTimestamp updates the timestamp based on the current snapshot then signs it.
+// If expires is the zero time, the expiry time comes from the repo's expiry policy.
 func (tr *Repo) SignTimestamp(expires time.Time) (*data.Signed, error) {
 	logrus.Debug("SignTimestamp")
 	signedSnapshot, err := tr.Snapshot.ToSigned()
@@ -1037,7 +1085,7 @@ func (tr *Repo) SignTimestamp(expires time.Time) (*data.Signed, error) {
 	if err != nil {
 		return nil, err
 	}
-	tr.Timestamp.Signed.Expires = expires
+	tr.Timestamp.Signed.Expires = tr.expires(data.CanonicalTimestampRole, expires)
 	tr.Timestamp.Signed.Version++
 	signed, err := tr.Timestamp.ToSigned()
 	if err != nil {
@@ -1056,12 +1104,122 @@ func (tr *Repo) SignTimestamp(expires time.Time) (*data.Signed, error) {
 	return signed, nil
 }
 
+// SigningRoles returns the roles whose thresholds must be met by the signatures
+// on the next version of the given root or targets role.  For root this
+// includes the previously trusted root role if it has been changed since the
+// root was loaded, so that the rotation can be verified by existing clients.
+func (tr *Repo) SigningRoles(role data.RoleName) ([]data.BaseRole, error) {
+	switch {
+	case role == data.CanonicalRootRole:
+		currRoot, err := tr.GetBaseRole(data.CanonicalRootRole)
+		if err != nil {
+			return nil, err
+		}
+		if !tr.originalRootRole.Equals(currRoot) {
+			return []data.BaseRole{tr.originalRootRole, currRoot}, nil
+		}
+		return []data.BaseRole{currRoot}, nil
+	case role == data.CanonicalTargetsRole:
+		targets, err := tr.GetBaseRole(role)
+		if err != nil {
+			return nil, err
+		}
+		return []data.BaseRole{targets}, nil
+	case data.IsDelegation(role):
+		delgRole, err := tr.GetDelegationRole(role)
+		if err != nil {
+			return nil, err
+		}
+		return []data.BaseRole{delgRole.BaseRole}, nil
+	default:
+		return nil, data.ErrInvalidRole{
+			Role:   role,
+			Reason: "only root and targets roles can be signed by multiple parties",
+		}
+	}
+}
+
+// PartiallySign produces the next version of the given root or targets role,
+// signed with whichever of the required keys are available in the
+// CryptoService.  Unlike SignRoot and SignTargets the role thresholds do not
+// need to be met, and the repo itself is left unmodified, so that the result
+// can be passed on to other key holders to add their signatures.  If expires
+// is the zero time, the expiry time comes from the repo's expiry policy.
+func (tr *Repo) PartiallySign(role data.RoleName, expires time.Time, extraSigningKeys data.KeyList) (*data.Signed, error) {
+	logrus.Debugf("partially signing %s", role)
+	rolesToSignWith, err := tr.SigningRoles(role)
+	if err != nil {
+		return nil, err
+	}
+
+	expires = tr.expires(role, expires)
+	var s *data.Signed
+	if role == data.CanonicalRootRole {
+		s, err = nextRootVersion(tr.Root, expires)
+	} else {
+		if _, ok := tr.Targets[role]; !ok {
+			return nil, data.ErrInvalidRole{
+				Role:   role,
+				Reason: "PartiallySign called with non-existent targets role",
+			}
+		}
+		s, err = nextTargetsVersion(tr.Targets[role], expires)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tr.signWithThreshold(s, rolesToSignWith, extraSigningKeys, false)
+}
+
+// nextRootVersion returns the next version of a copy of the given root,
+// leaving the original untouched
+func nextRootVersion(root *data.SignedRoot, expires time.Time) (*data.Signed, error) {
+	rootBytes, err := root.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	tempRoot := data.SignedRoot{}
+	if err := json.Unmarshal(rootBytes, &tempRoot); err != nil {
+		return nil, err
+	}
+	tempRoot.Signed.Expires = expires
+	tempRoot.Signed.Version++
+	return tempRoot.ToSigned()
+}
+
+// nextTargetsVersion returns the next version of a copy of the given targets,
+// leaving the original untouched
+func nextTargetsVersion(targets *data.SignedTargets, expires time.Time) (*data.Signed, error) {
+	targetsBytes, err := targets.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	tempTargets := data.SignedTargets{}
+	if err := json.Unmarshal(targetsBytes, &tempTargets); err != nil {
+		return nil, err
+	}
+	tempTargets.Signed.Expires = expires
+	tempTargets.Signed.Version++
+	return tempTargets.ToSigned()
+}
+
 func (tr Repo) sign(signedData *data.Signed, roles []data.BaseRole, optionalKeys []data.PublicKey) (*data.Signed, error) {
+	return tr.signWithThreshold(signedData, roles, optionalKeys, true)
+}
+
+// signWithThreshold signs with the keys of each of the given roles, failing if
+// enforceThreshold is set and a role's threshold cannot be met locally
+func (tr Repo) signWithThreshold(signedData *data.Signed, roles []data.BaseRole, optionalKeys []data.PublicKey, enforceThreshold bool) (*data.Signed, error) {
 	validKeys := optionalKeys
 	for _, r := range roles {
 		roleKeys := r.ListKeys()
 		validKeys = append(roleKeys, validKeys...)
-		if err := signed.Sign(tr.cryptoService, signedData, roleKeys, r.Threshold, validKeys); err != nil {
+		minSignatures := 0
+		if enforceThreshold {
+			minSignatures = r.Threshold
+		}
+		if err := signed.Sign(tr.cryptoService, signedData, roleKeys, minSignatures, validKeys); err != nil {
 			return nil, err
 		}
 	}
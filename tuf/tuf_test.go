@@ -1070,6 +1070,47 @@ func TestReplaceBaseKeysInRoot(t *testing.T) {
 	}
 }
 
+// changing the threshold of a role marks root as dirty as well as the role
+func TestSetBaseThresholdInRoot(t *testing.T) {
+	for _, role := range data.BaseRoles {
+		ed25519 := signed.NewEd25519()
+		repo := initRepo(t, ed25519)
+		repo.Root.Dirty = false
+		repo.Snapshot.Dirty = false
+		repo.Targets[data.CanonicalTargetsRole].Dirty = false
+		repo.Timestamp.Dirty = false
+
+		// setting the same threshold changes nothing
+		require.NoError(t, repo.SetBaseThreshold(role, 1))
+		require.False(t, repo.Root.Dirty)
+
+		require.NoError(t, repo.SetBaseThreshold(role, 2))
+		require.Equal(t, 2, repo.Root.Signed.Roles[role].Threshold)
+		require.True(t, repo.Root.Dirty)
+
+		switch role {
+		case data.CanonicalSnapshotRole:
+			require.True(t, repo.Snapshot.Dirty)
+		case data.CanonicalTargetsRole:
+			require.True(t, repo.Targets[data.CanonicalTargetsRole].Dirty)
+		case data.CanonicalTimestampRole:
+			require.True(t, repo.Timestamp.Dirty)
+		case data.CanonicalRootRole:
+			// the previous root's threshold still has to be met when signing
+			require.Equal(t, 1, repo.originalRootRole.Threshold)
+		}
+
+		err := repo.SetBaseThreshold(role, 0)
+		require.Error(t, err)
+		require.IsType(t, data.ErrInvalidRole{}, err)
+	}
+
+	repo := initRepo(t, signed.NewEd25519())
+	err := repo.SetBaseThreshold("targets/a", 2)
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+}
+
 func TestGetAllRoles(t *testing.T) {
 	ed25519 := signed.NewEd25519()
 	repo := initRepo(t, ed25519)
@@ -1528,3 +1569,115 @@ func TestRootKeyRotation(t *testing.T) {
 	}
 	verifySignatureList(t, signedObj, expectedSigningKeys...)
 }
+
+func TestPartiallySignRootBelowThreshold(t *testing.T) {
+	cs := signed.NewEd25519()
+	repo := initRepo(t, cs)
+	origRoot, err := repo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+	origVersion := repo.Root.Signed.Version
+
+	// the second root key lives in a different crypto service
+	otherCS := signed.NewEd25519()
+	otherKey, err := otherCS.Create(data.CanonicalRootRole, testGUN, data.ED25519Key)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddBaseKeys(data.CanonicalRootRole, otherKey))
+	repo.Root.Signed.Roles[data.CanonicalRootRole].Threshold = 2
+
+	_, err = repo.SignRoot(data.DefaultExpires(data.CanonicalRootRole), nil)
+	require.IsType(t, signed.ErrInsufficientSignatures{}, err)
+
+	roles, err := repo.SigningRoles(data.CanonicalRootRole)
+	require.NoError(t, err)
+	require.Len(t, roles, 2)
+	require.True(t, roles[0].Equals(origRoot))
+	newRoot := roles[1]
+	require.Equal(t, 2, newRoot.Threshold)
+
+	s, err := repo.PartiallySign(data.CanonicalRootRole, data.DefaultExpires(data.CanonicalRootRole), nil)
+	require.NoError(t, err)
+	require.Len(t, s.Signatures, 1)
+	require.Equal(t, origVersion, repo.Root.Signed.Version, "repo should not have been modified")
+
+	partialRoot, err := data.RootFromSigned(s)
+	require.NoError(t, err)
+	require.Equal(t, origVersion+1, partialRoot.Signed.Version)
+
+	require.NoError(t, signed.VerifySignatures(s, origRoot))
+	require.IsType(t, signed.ErrRoleThreshold{}, signed.VerifySignatures(s, newRoot))
+
+	// the other key holder adds their signature, keeping the existing one
+	require.NoError(t, signed.Sign(otherCS, s, newRoot.ListKeys(), 0, nil))
+	require.Len(t, s.Signatures, 2)
+	require.NoError(t, signed.VerifySignatures(s, newRoot))
+}
+
+func TestPartiallySignTargets(t *testing.T) {
+	cs := signed.NewEd25519()
+	repo := initRepo(t, cs)
+	origVersion := repo.Targets[data.CanonicalTargetsRole].Signed.Version
+
+	s, err := repo.PartiallySign(data.CanonicalTargetsRole, data.DefaultExpires(data.CanonicalTargetsRole), nil)
+	require.NoError(t, err)
+	require.Equal(t, origVersion, repo.Targets[data.CanonicalTargetsRole].Signed.Version)
+
+	targets, err := data.TargetsFromSigned(s, data.CanonicalTargetsRole)
+	require.NoError(t, err)
+	require.Equal(t, origVersion+1, targets.Signed.Version)
+
+	targetsRole, err := repo.GetBaseRole(data.CanonicalTargetsRole)
+	require.NoError(t, err)
+	require.NoError(t, signed.VerifySignatures(s, targetsRole))
+
+	// delegations which have not been loaded cannot be signed
+	_, err = repo.PartiallySign("targets/missing", data.DefaultExpires(data.CanonicalTargetsRole), nil)
+	require.Error(t, err)
+}
+
+func TestPartiallySignInvalidRole(t *testing.T) {
+	repo := initRepo(t, signed.NewEd25519())
+	for _, role := range []data.RoleName{data.CanonicalSnapshotRole, data.CanonicalTimestampRole} {
+		_, err := repo.PartiallySign(role, data.DefaultExpires(role), nil)
+		require.IsType(t, data.ErrInvalidRole{}, err)
+	}
+}
+
+// Metadata signed with a zero expiry time expires according to the repo's
+// expiry policy, but an explicit expiry time always wins
+func TestSignWithExpiryPolicy(t *testing.T) {
+	repo := initRepo(t, signed.NewEd25519())
+	repo.SetExpiryPolicy("prod/app", data.NewExpiryPolicy(
+		data.ExpiryRule{GUNPrefix: "prod/", Expiry: 24 * time.Hour},
+		data.ExpiryRule{GUNPrefix: "prod/", Role: data.CanonicalTimestampRole, Expiry: time.Hour},
+	))
+
+	_, err := repo.SignRoot(time.Time{}, nil)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(24*time.Hour), repo.Root.Signed.Expires, time.Minute)
+	_, err = repo.SignTargets(data.CanonicalTargetsRole, time.Time{})
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(24*time.Hour), repo.Targets[data.CanonicalTargetsRole].Signed.Expires, time.Minute)
+	_, err = repo.SignSnapshot(time.Time{})
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(24*time.Hour), repo.Snapshot.Signed.Expires, time.Minute)
+	_, err = repo.SignTimestamp(time.Time{})
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), repo.Timestamp.Signed.Expires, time.Minute)
+
+	s, err := repo.PartiallySign(data.CanonicalTargetsRole, time.Time{}, nil)
+	require.NoError(t, err)
+	targets, err := data.TargetsFromSigned(s, data.CanonicalTargetsRole)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(24*time.Hour), targets.Signed.Expires, time.Minute)
+
+	expires := time.Now().Add(10 * time.Hour).UTC().Round(time.Second)
+	_, err = repo.SignTimestamp(expires)
+	require.NoError(t, err)
+	require.True(t, expires.Equal(repo.Timestamp.Signed.Expires))
+
+	// without a policy, the default expiry times are used
+	repo.SetExpiryPolicy("prod/app", nil)
+	_, err = repo.SignTimestamp(time.Time{})
+	require.NoError(t, err)
+	require.WithinDuration(t, data.DefaultExpires(data.CanonicalTimestampRole), repo.Timestamp.Signed.Expires, time.Minute)
+}
@@ -250,7 +250,7 @@ func convertTUFKeyToPKCS8(priv data.PrivateKey) ([]byte, error) {
 		pkey.PrivateKeyAlgorithm = make([]asn1.ObjectIdentifier, 1)
 		pkey.PrivateKeyAlgorithm[0] = oidPublicKeyRSA
 		pkey.PrivateKey = priv.Private()
-	case data.ECDSAKey, data.ECDSAx509Key:
+	case data.ECDSAKey, data.ECDSAx509Key, data.ECDSAP384Key:
 		// To extract Curve value, parsing ECDSA key to *ecdsa.PrivateKey
 		eckey, err := x509.ParseECPrivateKey(priv.Private())
 		if err != nil {
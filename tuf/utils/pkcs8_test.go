@@ -62,6 +62,10 @@ func TestConvertTUFKeyToPKCS8(t *testing.T) {
 	require.NoError(t, err)
 	keys = append(keys, ecKey)
 
+	ecP384Key, err := GenerateECDSAP384Key(rand.Reader)
+	require.NoError(t, err)
+	keys = append(keys, ecP384Key)
+
 	edKey, err := GenerateED25519Key(rand.Reader)
 	require.NoError(t, err)
 	keys = append(keys, edKey)
@@ -22,6 +22,11 @@ import (
 	"golang.org/x/crypto/ed25519"
 )
 
+// KeyAlgorithmHeader is the PEM header that records that a key is an
+// ecdsa-p384 key, which cannot be told apart from an ecdsa key on the P-384
+// curve by its encoding alone
+const KeyAlgorithmHeader = "algorithm"
+
 // CanonicalKeyID returns the ID of the public bytes version of a TUF key.
 // On regular RSA/ECDSA TUF keys, this is just the key ID.  On X509 RSA/ECDSA
 // TUF keys, this is the key ID of the public key part of the key in the leaf cert
@@ -158,10 +163,18 @@ func parsePEMPrivateKey(pemBytes []byte, passphrase string, fips bool) (data.Pri
 		}
 		return parseLegacyPrivateKey(block, passphrase)
 	case "ENCRYPTED PRIVATE KEY", "PRIVATE KEY":
-		if passphrase == "" {
-			return ParsePKCS8ToTufKey(block.Bytes, nil)
+		var password []byte
+		if passphrase != "" {
+			password = []byte(passphrase)
+		}
+		privKey, err := ParsePKCS8ToTufKey(block.Bytes, password)
+		if err != nil {
+			return nil, err
 		}
-		return ParsePKCS8ToTufKey(block.Bytes, []byte(passphrase))
+		if block.Headers[KeyAlgorithmHeader] == data.ECDSAP384Key {
+			return ECDSAToP384PrivateKey(privKey)
+		}
+		return privKey, nil
 	default:
 		return nil, fmt.Errorf("unsupported key type %q", block.Type)
 	}
@@ -278,7 +291,7 @@ func ParsePEMPublicKey(pubKeyBytes []byte) (data.PublicKey, error) {
 		}
 		return CertToKey(cert), nil
 	case "PUBLIC KEY":
-		keyType, err := keyTypeForPublicKey(pemBlock.Bytes)
+		keyType, err := keyTypeForPublicKey(pemBlock)
 		if err != nil {
 			return nil, err
 		}
@@ -288,13 +301,19 @@ func ParsePEMPublicKey(pubKeyBytes []byte) (data.PublicKey, error) {
 	}
 }
 
-func keyTypeForPublicKey(pubKeyBytes []byte) (string, error) {
-	pub, err := x509.ParsePKIXPublicKey(pubKeyBytes)
+func keyTypeForPublicKey(pemBlock *pem.Block) (string, error) {
+	pub, err := x509.ParsePKIXPublicKey(pemBlock.Bytes)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse pem encoded public key: %v", err)
 	}
-	switch pub.(type) {
+	switch pub := pub.(type) {
 	case *ecdsa.PublicKey:
+		if pemBlock.Headers[KeyAlgorithmHeader] == data.ECDSAP384Key {
+			if pub.Curve != elliptic.P384() {
+				return "", errors.New("ecdsa-p384 key must use the P-384 curve")
+			}
+			return data.ECDSAP384Key, nil
+		}
 		return data.ECDSAKey, nil
 	case *rsa.PublicKey:
 		return data.RSAKey, nil
@@ -344,6 +363,8 @@ func GenerateKey(algorithm string) (data.PrivateKey, error) {
 	switch algorithm {
 	case data.ECDSAKey:
 		return GenerateECDSAKey(rand.Reader)
+	case data.ECDSAP384Key:
+		return GenerateECDSAP384Key(rand.Reader)
 	case data.ED25519Key:
 		return GenerateED25519Key(rand.Reader)
 	}
@@ -382,6 +403,28 @@ func GenerateECDSAKey(random io.Reader) (data.PrivateKey, error) {
 	return tufPrivKey, nil
 }
 
+// GenerateECDSAP384Key generates an ECDSA Private key on the P-384 curve and
+// returns a TUF PrivateKey
+func GenerateECDSAP384Key(random io.Reader) (data.PrivateKey, error) {
+	ecdsaPrivKey, err := ecdsa.GenerateKey(elliptic.P384(), random)
+	if err != nil {
+		return nil, err
+	}
+
+	tufPrivKey, err := ECDSAToPrivateKey(ecdsaPrivKey)
+	if err != nil {
+		return nil, err
+	}
+	tufPrivKey, err = ECDSAToP384PrivateKey(tufPrivKey)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Debugf("generated ECDSA P-384 key with keyID: %s", tufPrivKey.ID())
+
+	return tufPrivKey, nil
+}
+
 // GenerateED25519Key generates an ED25519 private key and returns a TUF
 // PrivateKey. The serialization format we use is just the public key bytes
 // followed by the private key bytes
@@ -423,6 +466,15 @@ func ECDSAToPrivateKey(ecdsaPrivKey *ecdsa.PrivateKey) (data.PrivateKey, error)
 	return data.NewECDSAPrivateKey(pubKey, ecdsaPrivKeyBytes)
 }
 
+// ECDSAToP384PrivateKey converts an ECDSA private key on the P-384 curve to a
+// TUF data.PrivateKey of the ECDSAP384Key type
+func ECDSAToP384PrivateKey(privKey data.PrivateKey) (data.PrivateKey, error) {
+	if privKey.Algorithm() != data.ECDSAKey {
+		return nil, fmt.Errorf("cannot convert a %s key to an %s key", privKey.Algorithm(), data.ECDSAP384Key)
+	}
+	return data.NewECDSAPrivateKey(data.NewECDSAP384PublicKey(privKey.Public()), privKey.Private())
+}
+
 // ED25519ToPrivateKey converts a serialized ED25519 key to a TUF
 // data.PrivateKey type
 func ED25519ToPrivateKey(privKeyBytes []byte) (data.PrivateKey, error) {
@@ -485,6 +537,10 @@ func ConvertPrivateKeyToPKCS8(key data.PrivateKey, role data.RoleName, gun data.
 		headers["gun"] = gun.String()
 	}
 
+	if key.Algorithm() == data.ECDSAP384Key {
+		headers[KeyAlgorithmHeader] = data.ECDSAP384Key
+	}
+
 	return pem.EncodeToMemory(&pem.Block{Bytes: der, Type: blockType, Headers: headers}), nil
 }
 
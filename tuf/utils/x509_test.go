@@ -287,6 +287,96 @@ func TestECDSAX509PublickeyID(t *testing.T) {
 	require.Equal(t, tufPrivKey.ID(), tufID)
 }
 
+func TestECDSAP384X509PublickeyID(t *testing.T) {
+	startTime := time.Now()
+	template, err := NewCertificate("something", startTime, startTime.AddDate(10, 0, 0))
+	require.NoError(t, err)
+
+	privKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	// P-384 keys that were not explicitly created as ecdsa-p384 keys keep
+	// the ecdsa type, so that their IDs do not change
+	tufPrivKey, err := ECDSAToPrivateKey(privKey)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAKey, tufPrivKey.Algorithm())
+	require.Equal(t, data.ECDSASignature, tufPrivKey.SignatureAlgorithm())
+
+	derBytes, err := x509.CreateCertificate(
+		rand.Reader, template, template, &privKey.PublicKey, privKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(derBytes)
+	require.NoError(t, err)
+	require.NoError(t, ValidateCertificate(cert, true))
+
+	tufKey := CertToKey(cert)
+	require.Equal(t, data.ECDSAx509Key, tufKey.Algorithm())
+	tufID, err := X509PublicKeyID(tufKey)
+	require.NoError(t, err)
+
+	require.Equal(t, tufPrivKey.ID(), tufID)
+}
+
+func TestECDSAP384KeyOperations(t *testing.T) {
+	ecKey, err := GenerateKey(data.ECDSAP384Key)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAP384Key, ecKey.Algorithm())
+	require.Equal(t, data.ECDSAP384Signature, ecKey.SignatureAlgorithm())
+
+	// the key type survives a round trip through PKCS#8, encrypted or not
+	for _, passphrase := range []string{"", "ponies"} {
+		ecPEM, err := ConvertPrivateKeyToPKCS8(ecKey, data.CanonicalRootRole, "", passphrase)
+		require.NoError(t, err)
+		decodedECKey, err := ParsePEMPrivateKey(ecPEM, passphrase)
+		require.NoError(t, err)
+		require.Equal(t, ecKey.Private(), decodedECKey.Private())
+		require.Equal(t, ecKey.ID(), decodedECKey.ID())
+		require.Equal(t, data.ECDSAP384Key, decodedECKey.Algorithm())
+	}
+
+	// as does parsing a PEM encoded public key with the algorithm header
+	pubKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:    "PUBLIC KEY",
+		Headers: map[string]string{KeyAlgorithmHeader: data.ECDSAP384Key},
+		Bytes:   ecKey.Public(),
+	})
+	pubKey, err := ParsePEMPublicKey(pubKeyPEM)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAP384Key, pubKey.Algorithm())
+	require.Equal(t, ecKey.ID(), pubKey.ID())
+
+	// without the header, P-384 keys are parsed as ecdsa keys
+	pubKeyPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecKey.Public()})
+	pubKey, err = ParsePEMPublicKey(pubKeyPEM)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAKey, pubKey.Algorithm())
+
+	ecDER, err := ConvertTUFKeyToPKCS8(ecKey, nil)
+	require.NoError(t, err)
+	decodedECKey, err := ParsePEMPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER}), "")
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAKey, decodedECKey.Algorithm())
+	require.Equal(t, data.ECDSASignature, decodedECKey.SignatureAlgorithm())
+
+	// a P-256 key cannot claim to be an ecdsa-p384 key
+	p256Key, err := GenerateKey(data.ECDSAKey)
+	require.NoError(t, err)
+	pubKeyPEM = pem.EncodeToMemory(&pem.Block{
+		Type:    "PUBLIC KEY",
+		Headers: map[string]string{KeyAlgorithmHeader: data.ECDSAP384Key},
+		Bytes:   p256Key.Public(),
+	})
+	_, err = ParsePEMPublicKey(pubKeyPEM)
+	require.Error(t, err)
+
+	// P-256 keys keep the plain ecdsa type
+	pubKeyPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: p256Key.Public()})
+	pubKey, err = ParsePEMPublicKey(pubKeyPEM)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAKey, pubKey.Algorithm())
+}
+
 func TestExtractPrivateKeyAttributes(t *testing.T) {
 	testExtractPrivateKeyAttributes(t)
 	testExtractPrivateKeyAttributesWithFIPS(t)
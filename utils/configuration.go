@@ -9,6 +9,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
 
 	bugsnag_hook "github.com/Shopify/logrus-bugsnag"
 	"github.com/bugsnag/bugsnag-go"
@@ -18,6 +19,8 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/trustmanager/pkcs11"
+	"github.com/theupdateframework/notary/tuf/data"
 )
 
 // Storage is a configuration about what storage backend a server should use
@@ -37,6 +40,16 @@ type RethinkDBStorage struct {
 	Password string
 }
 
+// KMSStorage is configuration about a key management service which holds
+// private keys
+type KMSStorage struct {
+	Storage
+	CA    string
+	Cert  string
+	Key   string
+	Token string
+}
+
 // GetPathRelativeToConfig gets a configuration key which is a path, and if
 // it is not empty or an absolute path, returns the absolute path relative
 // to the configuration file
@@ -178,6 +191,130 @@ func ParseRethinkDBStorage(configuration *viper.Viper) (*RethinkDBStorage, error
 	return &store, nil
 }
 
+// ParseKMSStorage tries to parse out KMSStorage from a Viper.  The URL of the
+// KMS is required, but TLS configuration and a token are optional.
+func ParseKMSStorage(configuration *viper.Viper) (*KMSStorage, error) {
+	store := KMSStorage{
+		Storage: Storage{
+			Backend: configuration.GetString("storage.backend"),
+			Source:  configuration.GetString("storage.url"),
+		},
+		CA:    GetPathRelativeToConfig(configuration, "storage.tls_ca_file"),
+		Cert:  GetPathRelativeToConfig(configuration, "storage.client_cert_file"),
+		Key:   GetPathRelativeToConfig(configuration, "storage.client_key_file"),
+		Token: configuration.GetString("storage.token"),
+	}
+
+	switch {
+	case store.Backend != notary.KMSBackend:
+		return nil, fmt.Errorf(
+			"%s is not a supported KMS backend",
+			store.Backend,
+		)
+	case store.Source == "":
+		return nil, fmt.Errorf(
+			"must provide a non-empty url for %s",
+			store.Backend,
+		)
+	case (store.Cert == "") != (store.Key == ""):
+		return nil, fmt.Errorf(
+			"either pass both a client cert and key for %s, or neither",
+			store.Backend,
+		)
+	}
+	return &store, nil
+}
+
+// ParsePKCS11Config tries to parse out the configuration of a PKCS#11 token
+// from a section of a Viper.  The module is required, as is either a token
+// label or a slot, and a PIN file or environment variable is optional.  The
+// module and PIN file are relative to the config file.
+func ParsePKCS11Config(configuration *viper.Viper, section string) (*pkcs11.Config, error) {
+	config := pkcs11.Config{
+		Module:     GetPathRelativeToConfig(configuration, section+".module"),
+		TokenLabel: configuration.GetString(section + ".token_label"),
+		PINFile:    GetPathRelativeToConfig(configuration, section+".pin_file"),
+		PINEnv:     configuration.GetString(section + ".pin_env"),
+	}
+	if configuration.IsSet(section + ".slot") {
+		slot := configuration.GetInt(section + ".slot")
+		if slot < 0 {
+			return nil, fmt.Errorf("invalid PKCS#11 slot %d", slot)
+		}
+		uSlot := uint(slot)
+		config.Slot = &uSlot
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid PKCS#11 configuration: %v", err)
+	}
+	return &config, nil
+}
+
+// ParsePKCS11Storage tries to parse out the configuration of a PKCS#11 token
+// holding private keys from the storage section of a Viper.  Since there is
+// no one to ask for the PIN, a PIN file or environment variable is required.
+func ParsePKCS11Storage(configuration *viper.Viper) (*pkcs11.Config, error) {
+	backend := configuration.GetString("storage.backend")
+	if backend != notary.PKCS11Backend {
+		return nil, fmt.Errorf("%s is not a supported PKCS#11 backend", backend)
+	}
+	config, err := ParsePKCS11Config(configuration, "storage")
+	if err != nil {
+		return nil, err
+	}
+	if config.PINFile == "" && config.PINEnv == "" {
+		return nil, fmt.Errorf("must provide a pin_file or pin_env for %s", backend)
+	}
+	return config, nil
+}
+
+// ParseExpiryPolicy tries to parse out a data.ExpiryPolicy from the list of
+// rules under "expiry" in a Viper, each of which has an optional "gun_prefix"
+// and "role", and an "expiry" duration.  If no rules are provided, returns a
+// nil pointer.
+func ParseExpiryPolicy(configuration *viper.Viper) (*data.ExpiryPolicy, error) {
+	if !configuration.IsSet("expiry") {
+		return nil, nil
+	}
+	rawRules, ok := configuration.Get("expiry").([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expiry must be a list of rules")
+	}
+	policy := &data.ExpiryPolicy{}
+	for _, rawRule := range rawRules {
+		fields, ok := rawRule.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid expiry rule: %v", rawRule)
+		}
+		var rule data.ExpiryRule
+		for key, value := range fields {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid value for %s in expiry rule: %v", key, value)
+			}
+			switch key {
+			case "gun_prefix":
+				rule.GUNPrefix = s
+			case "role":
+				rule.Role = data.RoleName(s)
+			case "expiry":
+				d, err := time.ParseDuration(s)
+				if err != nil {
+					return nil, fmt.Errorf("invalid expiry %q: %v", s, err)
+				}
+				rule.Expiry = d
+			default:
+				return nil, fmt.Errorf("unknown field in expiry rule: %s", key)
+			}
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
 // ParseBugsnag tries to parse out a Bugsnag Configuration from a Viper.
 // If no values are provided, returns a nil pointer.
 func ParseBugsnag(configuration *viper.Viper) (*bugsnag.Configuration, error) {
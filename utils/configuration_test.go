@@ -9,12 +9,15 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/bugsnag/bugsnag-go"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/trustmanager/pkcs11"
+	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/utils"
 )
 
@@ -332,6 +335,127 @@ func TestParseRethinkStorageDBStoreEmptyUsername(t *testing.T) {
 	require.Contains(t, err.Error(), "requires a username to connect to the db")
 }
 
+// ParseKMSStorage requires a url, and either both or neither of a client cert
+// and key
+func TestParseKMSStorageInvalid(t *testing.T) {
+	invalids := []string{
+		`{"storage": {"backend": "mysql", "url": "https://kms:8200/v1/transit"}}`,
+		`{"storage": {"backend": "kms"}}`,
+		`{"storage": {"backend": "kms", "url": "https://kms:8200/v1/transit", "client_cert_file": "/tls/cert.pem"}}`,
+		`{"storage": {"backend": "kms", "url": "https://kms:8200/v1/transit", "client_key_file": "/tls/key.pem"}}`,
+	}
+	for _, invalid := range invalids {
+		_, err := ParseKMSStorage(configure(invalid))
+		require.Error(t, err, "expected error with %s", invalid)
+	}
+}
+
+func TestParseKMSStorage(t *testing.T) {
+	config := configure(`{
+		"storage": {
+			"backend": "kms",
+			"url": "https://kms:8200/v1/transit",
+			"tls_ca_file": "/tls/ca.pem",
+			"client_cert_file": "/tls/cert.pem",
+			"client_key_file": "/tls/key.pem"
+		}
+	}`)
+
+	vars := map[string]string{"STORAGE_TOKEN": "s3cret"}
+	setupEnvironmentVariables(t, vars)
+	defer cleanupEnvironmentVariables(t, vars)
+
+	expected := KMSStorage{
+		Storage: Storage{
+			Backend: "kms",
+			Source:  "https://kms:8200/v1/transit",
+		},
+		CA:    "/tls/ca.pem",
+		Cert:  "/tls/cert.pem",
+		Key:   "/tls/key.pem",
+		Token: "s3cret",
+	}
+
+	store, err := ParseKMSStorage(config)
+	require.NoError(t, err)
+	require.Equal(t, expected, *store)
+}
+
+// ParsePKCS11Storage requires a module, either a token label or a slot, and
+// a PIN file or environment variable
+func TestParsePKCS11StorageInvalid(t *testing.T) {
+	invalids := []string{
+		`{"storage": {"backend": "kms", "module": "/usr/lib/softhsm/libsofthsm2.so", "token_label": "notary", "pin_env": "PIN"}}`,
+		`{"storage": {"backend": "pkcs11", "token_label": "notary", "pin_env": "PIN"}}`,
+		`{"storage": {"backend": "pkcs11", "module": "/usr/lib/softhsm/libsofthsm2.so", "pin_env": "PIN"}}`,
+		`{"storage": {"backend": "pkcs11", "module": "/usr/lib/softhsm/libsofthsm2.so", "token_label": "notary", "slot": 1, "pin_env": "PIN"}}`,
+		`{"storage": {"backend": "pkcs11", "module": "/usr/lib/softhsm/libsofthsm2.so", "slot": -1, "pin_env": "PIN"}}`,
+		`{"storage": {"backend": "pkcs11", "module": "/usr/lib/softhsm/libsofthsm2.so", "token_label": "notary"}}`,
+	}
+	for _, invalid := range invalids {
+		_, err := ParsePKCS11Storage(configure(invalid))
+		require.Error(t, err, "expected error with %s", invalid)
+	}
+}
+
+func TestParsePKCS11Storage(t *testing.T) {
+	config := configure(`{
+		"storage": {
+			"backend": "pkcs11",
+			"module": "/usr/lib/softhsm/libsofthsm2.so",
+			"slot": 0,
+			"pin_file": "/secrets/pin"
+		}
+	}`)
+
+	slot := uint(0)
+	expected := pkcs11.Config{
+		Module:  "/usr/lib/softhsm/libsofthsm2.so",
+		Slot:    &slot,
+		PINFile: "/secrets/pin",
+	}
+
+	store, err := ParsePKCS11Storage(config)
+	require.NoError(t, err)
+	require.Equal(t, expected, *store)
+}
+
+func TestParseExpiryPolicyInvalid(t *testing.T) {
+	invalids := []string{
+		`{"expiry": {"role": "targets", "expiry": "720h"}}`,
+		`{"expiry": ["720h"]}`,
+		`{"expiry": [{"role": "targets", "expiry": 720}]}`,
+		`{"expiry": [{"role": "targets", "expiry": "a month"}]}`,
+		`{"expiry": [{"role": "targets"}]}`,
+		`{"expiry": [{"role": "nope", "expiry": "720h"}]}`,
+		`{"expiry": [{"role": "targets", "expiry": "720h", "gun": "prod/"}]}`,
+	}
+	for _, invalid := range invalids {
+		_, err := ParseExpiryPolicy(configure(invalid))
+		require.Error(t, err, "expected error with %s", invalid)
+	}
+}
+
+func TestParseExpiryPolicy(t *testing.T) {
+	policy, err := ParseExpiryPolicy(configure(`{}`))
+	require.NoError(t, err)
+	require.Nil(t, policy)
+
+	policy, err = ParseExpiryPolicy(configure(`{
+		"expiry": [
+			{"gun_prefix": "prod/", "role": "targets", "expiry": "720h"},
+			{"role": "delegations", "expiry": "168h"},
+			{"expiry": "8760h"}
+		]
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, data.NewExpiryPolicy(
+		data.ExpiryRule{GUNPrefix: "prod/", Role: data.CanonicalTargetsRole, Expiry: 720 * time.Hour},
+		data.ExpiryRule{Role: data.DelegationsExpiryRole, Expiry: 168 * time.Hour},
+		data.ExpiryRule{Expiry: 8760 * time.Hour},
+	), policy)
+}
+
 func TestParseSQLStorageWithEnvironmentVariables(t *testing.T) {
 	config := configure(`{
 		"storage": {
@@ -3,11 +3,13 @@ package utils
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/token"
 	"github.com/gorilla/mux"
 	"golang.org/x/net/context"
 
@@ -95,12 +97,28 @@ func serveError(log ctxu.Logger, w http.ResponseWriter, err error) {
 	return
 }
 
+// RepoFilter reports whether a request has access to the repository gun.
+// Routes which list repositories find one in their request's context, and
+// only list the repositories it allows.
+type RepoFilter func(gun string) bool
+
+// isListing reports whether a route without a GUN lists repositories, rather
+// than being an admin route: listings only require the requester to be
+// authenticated, and then filter what they list by pull access.
+func isListing(actions []string) bool {
+	return len(actions) == 1 && actions[0] == "pull"
+}
+
 func (root *rootHandler) doAuth(ctx context.Context, gun string, w http.ResponseWriter, r *http.Request) (context.Context, error) {
 	var access []auth.Access
-	if gun == "" {
-		access = buildCatalogRecord(root.actions...)
-	} else {
+	listing := false
+	switch {
+	case gun != "":
 		access = buildAccessRecords(gun, root.actions...)
+	case isListing(root.actions):
+		listing = true
+	default:
+		access = buildCatalogRecord(root.actions...)
 	}
 
 	log := ctxu.GetRequestLogger(ctx)
@@ -120,9 +138,78 @@ func (root *rootHandler) doAuth(ctx context.Context, gun string, w http.Response
 		errcode.ServeJSON(w, errcode.ErrorCodeUnauthorized)
 		return nil, err
 	}
+	if listing {
+		authCtx = context.WithValue(authCtx, notary.CtxKeyRepoFilter, root.repoFilter(authCtx, r))
+	}
 	return authCtx, nil
 }
 
+// repoFilter returns a RepoFilter which checks each repository for the
+// route's actions, unless the requester has catalog access to all of them.
+// If the requester was authorized with a token, it already lists everything
+// the requester has access to, so the access controller is not asked again.
+func (root *rootHandler) repoFilter(ctx context.Context, r *http.Request) RepoFilter {
+	if filter, ok := root.tokenRepoFilter(ctx, r); ok {
+		return filter
+	}
+	if _, err := root.auth.Authorized(ctx, buildCatalogRecord()...); err == nil {
+		return func(string) bool { return true }
+	}
+	return func(gun string) bool {
+		_, err := root.auth.Authorized(ctx, buildAccessRecords(gun, root.actions...)...)
+		return err == nil
+	}
+}
+
+// tokenRepoFilter returns a RepoFilter which checks each repository for the
+// route's actions against the scopes in the request's token, if the request
+// was authorized with one
+func (root *rootHandler) tokenRepoFilter(ctx context.Context, r *http.Request) (RepoFilter, bool) {
+	// only the token access controller records the resources it authorized
+	if auth.AuthorizedResources(ctx) == nil {
+		return nil, false
+	}
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return nil, false
+	}
+	// the token has already been verified by the access controller
+	t, err := token.NewToken(parts[1])
+	if err != nil || t.Claims == nil {
+		return nil, false
+	}
+
+	catalog := buildCatalogRecord()[0]
+	allowed := make(map[string][]string)
+	for _, access := range t.Claims.Access {
+		switch {
+		case access.Type == catalog.Type && access.Name == catalog.Name && hasAction(access.Actions, catalog.Action):
+			return func(string) bool { return true }, true
+		case access.Type == "repository":
+			allowed[access.Name] = append(allowed[access.Name], access.Actions...)
+		}
+	}
+	return func(gun string) bool {
+		for _, action := range root.actions {
+			if !hasAction(allowed[gun], action) {
+				return false
+			}
+		}
+		return true
+	}, true
+}
+
+// hasAction reports whether actions grants action, as the token access
+// controller decides it
+func hasAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == "*" || a == action {
+			return true
+		}
+	}
+	return false
+}
+
 func buildAccessRecords(repo string, actions ...string) []auth.Access {
 	requiredAccess := make([]auth.Access, 0, len(actions))
 	for _, action := range actions {
@@ -2,19 +2,29 @@ package utils
 
 import (
 	"bytes"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/token"
+	"github.com/docker/libtrust"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/context"
 
+	"github.com/theupdateframework/notary"
 	"github.com/theupdateframework/notary/tuf/signed"
 )
 
@@ -364,3 +374,158 @@ func TestDoAuthWildcardImage(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, http.StatusUnauthorized, rec.Code)
 }
+
+// scopedAccessController only authorizes the access it was granted
+type scopedAccessController []auth.Access
+
+func (ac scopedAccessController) Authorized(ctx context.Context, access ...auth.Access) (context.Context, error) {
+	for _, a := range access {
+		granted := false
+		for _, g := range ac {
+			granted = granted || a == g
+		}
+		if !granted {
+			return nil, errors.New("insufficient scope")
+		}
+	}
+	return ctx, nil
+}
+
+func TestDoAuthListing(t *testing.T) {
+	doAuth := func(ac auth.AccessController, actions ...string) (context.Context, error) {
+		r := rootHandler{
+			auth:    ac,
+			actions: actions,
+		}
+		return r.doAuth(
+			context.Background(),
+			"",
+			httptest.NewRecorder(),
+			&http.Request{URL: &url.URL{}, Body: ioutil.NopCloser(bytes.NewBuffer(nil))},
+		)
+	}
+	pullAlpine := buildAccessRecords("docker.io/library/alpine", "pull")
+	ac := scopedAccessController(append(pullAlpine, buildAccessRecords("docker.io/library/debian", "push")...))
+
+	// other routes without a GUN need catalog access
+	_, err := doAuth(ac, "*")
+	require.Error(t, err)
+
+	// listings only need the requester to be authenticated, and filter by
+	// pull access
+	ctx, err := doAuth(ac, "pull")
+	require.NoError(t, err)
+	filter, ok := ctx.Value(notary.CtxKeyRepoFilter).(RepoFilter)
+	require.True(t, ok)
+	require.True(t, filter("docker.io/library/alpine"))
+	require.False(t, filter("docker.io/library/debian"))
+	require.False(t, filter("docker.io/library/ubuntu"))
+
+	// catalog access allows every repository
+	ctx, err = doAuth(scopedAccessController(buildCatalogRecord()), "pull")
+	require.NoError(t, err)
+	filter = ctx.Value(notary.CtxKeyRepoFilter).(RepoFilter)
+	require.True(t, filter("docker.io/library/ubuntu"))
+
+	_, err = doAuth(TestingAccessController{Err: errors.New("unauthenticated")}, "pull")
+	require.Error(t, err)
+}
+
+// countingAccessController counts the calls to the access controller it wraps
+type countingAccessController struct {
+	auth.AccessController
+	calls int
+}
+
+func (ac *countingAccessController) Authorized(ctx context.Context, access ...auth.Access) (context.Context, error) {
+	ac.calls++
+	return ac.AccessController.Authorized(ctx, access...)
+}
+
+// tokenAccessController returns a token access controller, and a function
+// which issues tokens it accepts granting the given access
+func tokenAccessController(t *testing.T) (auth.AccessController, func(...*token.ResourceActions) string) {
+	rootKey, err := libtrust.GenerateECP256PrivateKey()
+	require.NoError(t, err)
+	rootCert, err := libtrust.GenerateCACert(rootKey, rootKey)
+	require.NoError(t, err)
+	bundle, err := ioutil.TempFile("", "notary-test-")
+	require.NoError(t, err)
+	defer os.Remove(bundle.Name())
+	require.NoError(t, pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: rootCert.Raw}))
+	require.NoError(t, bundle.Close())
+
+	ac, err := auth.GetAccessController("token", map[string]interface{}{
+		"realm":          "https://auth.docker.io/token",
+		"issuer":         "auth.docker.io",
+		"service":        "notary",
+		"rootcertbundle": bundle.Name(),
+	})
+	require.NoError(t, err)
+
+	issue := func(access ...*token.ResourceActions) string {
+		jwk, err := rootKey.PublicKey().MarshalJSON()
+		require.NoError(t, err)
+		rawJWK := json.RawMessage(jwk)
+		header, err := json.Marshal(token.Header{Type: "JWT", SigningAlg: "ES256", RawJWK: &rawJWK})
+		require.NoError(t, err)
+		now := time.Now()
+		claims, err := json.Marshal(token.ClaimSet{
+			Issuer:     "auth.docker.io",
+			Subject:    "user",
+			Audience:   "notary",
+			Expiration: now.Add(time.Hour).Unix(),
+			NotBefore:  now.Add(-time.Minute).Unix(),
+			IssuedAt:   now.Unix(),
+			JWTID:      "jwt",
+			Access:     access,
+		})
+		require.NoError(t, err)
+		payload := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+		sig, _, err := rootKey.Sign(strings.NewReader(payload), crypto.SHA256)
+		require.NoError(t, err)
+		return payload + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	return ac, issue
+}
+
+// With a token, the repositories listed are filtered by the scopes in the
+// token, without asking the access controller about each one
+func TestDoAuthListingWithToken(t *testing.T) {
+	ac, issue := tokenAccessController(t)
+	doAuth := func(rawToken string) (context.Context, *countingAccessController, error) {
+		counting := &countingAccessController{AccessController: ac}
+		r := rootHandler{
+			auth:    counting,
+			actions: []string{"pull"},
+		}
+		req := &http.Request{URL: &url.URL{}, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewBuffer(nil))}
+		req.Header.Set("Authorization", "Bearer "+rawToken)
+		ctx, err := r.doAuth(ctxu.WithRequest(context.Background(), req), "", httptest.NewRecorder(), req)
+		return ctx, counting, err
+	}
+
+	ctx, counting, err := doAuth(issue(
+		&token.ResourceActions{Type: "repository", Name: "docker.io/library/alpine", Actions: []string{"pull"}},
+		&token.ResourceActions{Type: "repository", Name: "docker.io/library/busybox", Actions: []string{"*"}},
+		&token.ResourceActions{Type: "repository", Name: "docker.io/library/debian", Actions: []string{"push"}},
+	))
+	require.NoError(t, err)
+	filter, ok := ctx.Value(notary.CtxKeyRepoFilter).(RepoFilter)
+	require.True(t, ok)
+	require.True(t, filter("docker.io/library/alpine"))
+	require.True(t, filter("docker.io/library/busybox"))
+	require.False(t, filter("docker.io/library/debian"))
+	require.False(t, filter("docker.io/library/ubuntu"))
+	require.Equal(t, 1, counting.calls)
+
+	// catalog access allows every repository
+	ctx, counting, err = doAuth(issue(&token.ResourceActions{Type: "registry", Name: "catalog", Actions: []string{"*"}}))
+	require.NoError(t, err)
+	filter = ctx.Value(notary.CtxKeyRepoFilter).(RepoFilter)
+	require.True(t, filter("docker.io/library/ubuntu"))
+	require.Equal(t, 1, counting.calls)
+
+	_, _, err = doAuth("not-a-token")
+	require.Error(t, err)
+}